@@ -1,22 +1,60 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
 
+	"github.com/13rac1/cclogs/internal/codec"
 	"github.com/13rac1/cclogs/internal/config"
 	"github.com/13rac1/cclogs/internal/discover"
 	"github.com/13rac1/cclogs/internal/doctor"
+	"github.com/13rac1/cclogs/internal/export"
+	"github.com/13rac1/cclogs/internal/fetch"
+	"github.com/13rac1/cclogs/internal/identity"
+	"github.com/13rac1/cclogs/internal/lock"
 	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/migrate"
+	"github.com/13rac1/cclogs/internal/msg"
+	"github.com/13rac1/cclogs/internal/notify"
 	"github.com/13rac1/cclogs/internal/output"
+	"github.com/13rac1/cclogs/internal/redactor"
+	"github.com/13rac1/cclogs/internal/runs"
+	"github.com/13rac1/cclogs/internal/s3errors"
+	"github.com/13rac1/cclogs/internal/search"
+	"github.com/13rac1/cclogs/internal/selftest"
+	"github.com/13rac1/cclogs/internal/stats"
+	"github.com/13rac1/cclogs/internal/telemetry"
+	"github.com/13rac1/cclogs/internal/transcript"
+	"github.com/13rac1/cclogs/internal/trash"
 	"github.com/13rac1/cclogs/internal/types"
+	"github.com/13rac1/cclogs/internal/units"
 	"github.com/13rac1/cclogs/internal/uploader"
+	"github.com/13rac1/cclogs/internal/verify"
+	"github.com/13rac1/cclogs/internal/watch"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gopkg.in/yaml.v3"
 )
 
 var (
@@ -28,13 +66,30 @@ var (
 var (
 	configPath        string
 	defaultConfigPath string
+	strictConfig      bool
+	noManifestCache   bool
+	targetName        string
 )
 
 func main() {
-	if err := rootCmd.Execute(); err != nil {
+	os.Exit(run())
+}
+
+// run executes the command line and returns the exit status. It is separate
+// from main so its deferred calls run before the process exits.
+func run() int {
+	// Cancel the root context on Ctrl+C so in-flight S3 calls abort promptly
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	// Restore the default handling once cancelled, so a second Ctrl+C during
+	// an upload's grace period exits at once
+	context.AfterFunc(ctx, stop)
+
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
-		os.Exit(1)
+		return exitCode(err)
 	}
+	return 0
 }
 
 var rootCmd = &cobra.Command{
@@ -42,157 +97,2697 @@ var rootCmd = &cobra.Command{
 	Short:   "Claude Code Log Shipper - upload session logs to S3",
 	Version: fmt.Sprintf("%s (commit: %s, built: %s)", version, commit, date),
 	Long: `cclogs discovers Claude Code session logs (*.jsonl files) from ~/.claude/projects/
-and uploads them to S3-compatible storage for backup and archival.`,
+and uploads them to S3-compatible storage for backup and archival.
+
+Exit codes:
+  0    success
+  1    any other error; also uploads pending (status --short/--json) and
+       problems found (verify, manifest fsck)
+  2    the config is missing or invalid (also in upload preflight)
+  3    S3 could not be reached, or refused the credentials (also in
+       upload preflight)
+  4    upload finished, but some files failed
+  5    doctor or selftest checks failed, or upload preflight found a
+       problem with the projects root
+  6    upload --dry-run --fail-if-pending found files not backed up
+  7    upload --fail-on-severity found secrets at or above the severity
+  130  interrupted`,
+	// A command run with --json owns stdout for its document; everything
+	// else written through output.Human goes to stderr
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		machine, _ := cmd.Flags().GetBool("json")
+		output.SetMachine(machine)
+	},
 }
 
 var (
-	jsonOutput bool
-	dryRun     bool
-	noRedact   bool
-	debug      bool
+	jsonOutput           bool
+	listByHost           bool
+	listVerbose          bool
+	listRedactionPreview bool
+	listSampleLines      int
+	listLimit            int
+	listOffset           int
+	listNoPager          bool
+	listSource           string
+	listFiles            bool
+	dryRun               bool
+	noRedact             bool
+	debug                bool
+	allowShrink          bool
+	uploadSince          string
+	uploadExcludeContent string
+	preflight            bool
+	noPreflight          bool
+	uploadOrder          string
+	uploadLimit          int
+	uploadThreads        int
+	uploadEvery          time.Duration
+	uploadWaitLock       bool
+	uploadIgnoreLock     bool
+	uploadAllTargets     bool
+	uploadMax            string
+	noManifest           bool
+	noResume             bool
+	datePartition        bool
+	failFast             bool
+	failIfPending        bool
+	uploadYes            bool
+	uploadJoinArchive    bool
+	failSeverity         string
+	estimateCompress     bool
+	dryRunSummary        bool
+	uploadJSON           bool
 )
 
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List local and remote projects with JSONL counts",
 	Long: `Lists all Claude Code projects both locally and in remote storage,
-showing the count of .jsonl files for each project.`,
+showing the count of .jsonl files for each project.
+
+Projects are sorted by name, so --limit and --offset page through them
+stably. On a terminal, a table taller than the screen is shown through
+$PAGER (default less -R) unless --no-pager is given. --json always lists
+every project, unless --limit is given too; the document's "page" field then
+records the window shown.
+
+--source picks where remote counts come from: the manifest, a live listing
+of the bucket, or auto (the default), which uses the manifest but lists the
+bucket instead when the manifest is missing or its file count disagrees with
+a listing of the prefix. A disagreement is reported so the manifest can be
+rebuilt. With s3.key_layout by_host, remote counts always come from each
+machine's manifest.
+
+--json --files lists each remote project's logs from the manifest, with the
+redaction patterns that matched in each when it was uploaded.
+
+With --redaction-preview, lists the local projects with what redaction would
+find in them instead: the first --sample-lines lines of each log are redacted
+as an upload would, and matches are counted per project and pattern. Nothing
+is uploaded or written.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		cfg, err := loadConfig()
 		if err != nil {
 			return err
 		}
+		if listSampleLines < 0 {
+			return fmt.Errorf("--sample-lines must not be negative")
+		}
+		if listLimit < 0 || listOffset < 0 {
+			return fmt.Errorf("--limit and --offset must not be negative")
+		}
+		if listFiles && !jsonOutput {
+			return fmt.Errorf("--files requires --json")
+		}
+		switch listSource {
+		case listSourceManifest, listSourceListing, listSourceAuto:
+		default:
+			return fmt.Errorf("--source must be manifest, listing, or auto, got %q", listSource)
+		}
 
-		localProjects, err := discover.DiscoverLocal(cfg.Local.ProjectsRoot)
+		localProjects, err := discover.DiscoverLocal(cfg.Local)
 		if err != nil {
 			return fmt.Errorf("discovering local projects: %w", err)
 		}
 
+		if listRedactionPreview {
+			preview, err := uploader.PreviewRedaction(cmd.Context(), localProjects, cfg.Local.Extensions, listSampleLines)
+			if err != nil {
+				return err
+			}
+			if jsonOutput {
+				data, err := json.MarshalIndent(preview, "", "  ")
+				if err != nil {
+					return fmt.Errorf("marshaling JSON: %w", err)
+				}
+				fmt.Println(string(data))
+				return nil
+			}
+			preview.Print(output.Human())
+			return nil
+		}
+
+		byHost := cfg.S3.KeyLayout == config.KeyLayoutByHost
+		if byHost && listSource == listSourceListing {
+			return fmt.Errorf("--source listing is not supported with s3.key_layout by_host")
+		}
+
 		// Discover remote projects from manifest if S3 is configured
 		var remoteProjects []types.Project
 		if cfg.S3.Bucket != "" {
 			s3Client, err := config.NewS3Client(cmd.Context(), cfg)
 			if err == nil {
-				manifestKey := computeManifestKey(cfg.S3.Prefix)
-				m, err := manifest.Load(cmd.Context(), s3Client, cfg.S3.Bucket, manifestKey)
+				if byHost {
+					remoteProjects = discoverRemoteByHost(cmd.Context(), s3Client, cfg, listByHost, listFiles)
+				} else {
+					remoteProjects = discoverRemoteFlat(cmd.Context(), s3Client, cfg, listSource, listFiles)
+				}
+			}
+		}
+
+		// When breaking out by host, local projects belong to this machine
+		if byHost && listByHost {
+			for i := range localProjects {
+				localProjects[i].Name = cfg.Local.MachineID + "/" + localProjects[i].Name
+			}
+		}
+
+		// Merge local and remote projects
+		merged := mergeProjects(localProjects, remoteProjects)
+
+		switch {
+		case jsonOutput && cmd.Flags().Changed("limit"):
+			err = output.PrintJSONPage(merged, cfg, listOffset, listLimit)
+		case jsonOutput:
+			// Complete unless --limit asks otherwise
+			err = output.PrintJSON(merged, cfg)
+		default:
+			output.Paged(!listNoPager, func(w io.Writer) {
+				output.PrintProjectsPage(w, merged, listVerbose, listOffset, listLimit)
+			})
+		}
+		if err != nil {
+			return fmt.Errorf("printing JSON output: %w", err)
+		}
+		return nil
+	},
+}
+
+var uploadCmd = &cobra.Command{
+	Use:   "upload",
+	Short: "Upload local JSONL logs to remote storage",
+	Long: `Discovers all .jsonl files in local Claude Code projects and uploads them
+to S3-compatible storage. Safe to run repeatedly from multiple machines.
+
+` + units.TimeHelp,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+
+		var since time.Time
+		if uploadSince != "" {
+			since, err = units.ParseTime(uploadSince, time.Now(), time.Local)
+			if err != nil {
+				return fmt.Errorf("--since: %w", err)
+			}
+		}
+
+		var excludeContent *regexp.Regexp
+		if uploadExcludeContent != "" {
+			excludeContent, err = regexp.Compile(uploadExcludeContent)
+			if err != nil {
+				return fmt.Errorf("--exclude-content-pattern: %w", err)
+			}
+		}
+
+		if uploadLimit < 0 {
+			return fmt.Errorf("--limit must not be negative")
+		}
+		var maxBytes types.ByteSize
+		if uploadMax != "" {
+			maxBytes, err = types.ParseByteSize(uploadMax)
+			if err != nil {
+				return fmt.Errorf("--max-bytes: %w", err)
+			}
+		}
+		if err := uploader.SortFiles(nil, uploadOrder); err != nil {
+			return fmt.Errorf("--order: %w", err)
+		}
+
+		if uploadThreads < 0 {
+			return fmt.Errorf("--threads must not be negative")
+		}
+		if uploadThreads > 0 {
+			cfg.Discovery.Concurrency = uploadThreads
+		}
+
+		if failIfPending && !dryRun {
+			return fmt.Errorf("--fail-if-pending requires --dry-run")
+		}
+		if estimateCompress && !dryRun {
+			return fmt.Errorf("--estimate-compression requires --dry-run")
+		}
+		if dryRunSummary && !dryRun {
+			return fmt.Errorf("--summary requires --dry-run")
+		}
+		if uploadJSON && !dryRun {
+			return fmt.Errorf("--json requires --dry-run")
+		}
+		if uploadEvery < 0 {
+			return fmt.Errorf("--every must not be negative")
+		}
+		if uploadEvery > 0 && dryRun {
+			return fmt.Errorf("--every cannot be combined with --dry-run")
+		}
+		if uploadAllTargets && targetName != "" {
+			return fmt.Errorf("--all-targets cannot be combined with --target")
+		}
+		if uploadWaitLock && uploadIgnoreLock {
+			return fmt.Errorf("--wait-lock and --ignore-lock cannot be combined")
+		}
+		if datePartition && noManifest {
+			return fmt.Errorf("--date-partition cannot be combined with --no-manifest")
+		}
+		if failSeverity != "" {
+			if noRedact {
+				return fmt.Errorf("--fail-on-severity needs redaction; remove --no-redact")
+			}
+			if failSeverity, err = redactor.ParseSeverity(failSeverity); err != nil {
+				return fmt.Errorf("--fail-on-severity: %w", err)
+			}
+		}
+
+		if err := boundUploadMemory(cfg); err != nil {
+			return err
+		}
+
+		stopTelemetry := startTelemetry(cfg)
+		defer stopTelemetry()
+
+		// runTarget performs one upload to the destination cfg points at and
+		// returns the exit code it calls for
+		runTarget := func(cfg *types.Config) (code int, err error) {
+			ctx, span := telemetry.Tracer().Start(ctx, "upload", trace.WithAttributes(attribute.Bool("upload.dry_run", dryRun)))
+			defer func() {
+				span.SetAttributes(attribute.Int("upload.exit_code", code))
+				telemetry.EndSpan(span, err)
+			}()
+
+			runPreflight := preflight && !noPreflight
+			receipt := runs.NewReceipt("upload", cfg, map[string]string{
+				"dry_run":              strconv.FormatBool(dryRun),
+				"no_redact":            strconv.FormatBool(noRedact),
+				"debug":                strconv.FormatBool(debug),
+				"allow_shrink":         strconv.FormatBool(allowShrink),
+				"since":                uploadSince,
+				"preflight":            strconv.FormatBool(runPreflight),
+				"order":                uploadOrder,
+				"limit":                strconv.Itoa(uploadLimit),
+				"max_bytes":            maxBytes.String(),
+				"no_manifest":          strconv.FormatBool(noManifest),
+				"no_resume":            strconv.FormatBool(noResume),
+				"target":               cfg.TargetName,
+				"date_partition":       strconv.FormatBool(datePartition),
+				"fail_fast":            strconv.FormatBool(failFast),
+				"fail_if_pending":      strconv.FormatBool(failIfPending),
+				"yes":                  strconv.FormatBool(uploadYes),
+				"join_archive":         strconv.FormatBool(uploadJoinArchive),
+				"fail_on_severity":     failSeverity,
+				"threads":              strconv.Itoa(uploadThreads),
+				"every":                uploadEvery.String(),
+				"wait_lock":            strconv.FormatBool(uploadWaitLock),
+				"ignore_lock":          strconv.FormatBool(uploadIgnoreLock),
+				"estimate_compression": strconv.FormatBool(estimateCompress),
+				"summary":              strconv.FormatBool(dryRunSummary),
+				"exclude_content":      uploadExcludeContent,
+			}, time.Now())
+			if debug {
+				printOptions(receipt)
+			}
+
+			// Fail fast on problems doctor would report, before any discovery
+			if runPreflight {
+				receipt.Preflight = doctor.Preflight(ctx, cfg, configPath, dryRun && !failIfPending)
+				if failures := doctor.Failures(receipt.Preflight); len(failures) > 0 {
+					fmt.Fprintln(output.Human(), "Preflight checks failed:")
+					doctor.PrintResults(failures)
+					fmt.Fprintln(output.Human(), "Run 'cclogs doctor' for a full report, or use --no-preflight to skip these checks.")
+					stage, _, _ := strings.Cut(failures[0].Name, ".")
+					err := &preflightError{Stage: stage, Message: failures[0].Message}
+					saveReceipt(receipt, nil, err)
+					return 0, err
+				}
+			}
+
+			// One upload at a time on this machine, whether started by hand,
+			// by --every, or by watch
+			if !dryRun {
+				l, err := acquireUploadLock(ctx, uploadWaitLock)
+				if err != nil {
+					saveReceipt(receipt, nil, err)
+					return 0, err
+				}
+				defer func() { _ = l.Release() }()
+			}
+
+			// Create S3 client (nil for dry-run, unless pending files must be
+			// told apart from ones the manifest already has)
+			var client *s3.Client
+			if !dryRun || failIfPending {
+				client, err = config.NewS3Client(ctx, cfg)
+				if err != nil {
+					err = fmt.Errorf("creating S3 client: %w", err)
+					saveReceipt(receipt, nil, err)
+					return 0, err
+				}
+			}
+
+			// Machines sharing the prefix take turns, if configured
+			if !dryRun && cfg.Upload.RemoteLock {
+				rl, err := acquireRemoteLock(ctx, cfg, client, uploadWaitLock, uploadIgnoreLock)
+				if err != nil {
+					saveReceipt(receipt, nil, err)
+					return 0, err
+				}
+				if rl != nil {
+					defer releaseRemoteLock(cfg, rl)
+				}
+			}
+
+			// Without a manifest every existing object looks unmanaged
+			if !dryRun && !uploadYes && !noManifest {
+				if err := confirmFirstUpload(ctx, cfg, client); err != nil {
+					saveReceipt(receipt, nil, err)
+					return 0, err
+				}
+			}
+			if !dryRun {
+				if err := checkArchive(ctx, cfg, client, uploadJoinArchive); err != nil {
+					saveReceipt(receipt, nil, err)
+					return 0, err
+				}
+			}
+
+			// Create uploader
+			u := uploader.New(cfg, client, noRedact, debug)
+			u.SetAllowShrink(allowShrink)
+			u.SetSince(since)
+			u.SetNoManifest(noManifest)
+			u.SetResumeDir(resumeDir())
+			u.SetNoResume(noResume)
+			if datePartition {
+				u.SetDatePartition(time.Now())
+			}
+			u.SetFailFast(failFast)
+			u.SetExcludeContent(excludeContent)
+			u.SetEstimateCompression(dryRun && (estimateCompress || compressionConfigured(cfg)))
+			u.SetSummaryOnly(dryRunSummary)
+
+			// Discover files
+			files, err := u.DiscoverFiles(ctx)
+			if err != nil {
+				return 0, fmt.Errorf("discovering files: %w", err)
+			}
+			recordProjects(cfg, files)
+
+			// Queue in the requested order, capped for incremental catch-up
+			if err := uploader.SortFiles(files, uploadOrder); err != nil {
+				return 0, fmt.Errorf("--order: %w", err)
+			}
+			files, deferred := uploader.Limit(files, uploadLimit, int64(maxBytes))
+
+			// In dry-run mode, process files with redaction but don't upload
+			if dryRun {
+				result, err := u.DryRunProcess(ctx, files)
+				saveReceipt(receipt, result, err)
+				if err != nil {
+					return 0, fmt.Errorf("processing files: %w", err)
+				}
+				printDeferred(deferred)
+				if dryRunSummary || uploadJSON {
+					if err := printPlan(uploader.NewPlan(files, dryRunThroughput(cfg)), deferred, result.Compression); err != nil {
+						return 0, err
+					}
+				}
+				if secretsFound(result.RedactionStats) {
+					return exitSecretsFound, nil
+				}
+				if pending := result.Uploaded + deferred.Files; failIfPending && pending > 0 {
+					fmt.Fprintf(os.Stderr, "%d files pending upload\n", pending)
+					return exitPending, nil
+				}
+				return 0, nil
+			}
+
+			// Perform upload
+			started := time.Now()
+			result, err := u.Upload(ctx, files)
+			saveReceipt(receipt, result, err)
+			if err == nil {
+				recordThroughput(cfg, result.UploadedBytes, time.Since(started))
+			}
+			if !errors.Is(err, context.Canceled) {
+				notifyRun(ctx, cfg, result, err)
+			}
+			if err != nil {
+				// Interrupted by Ctrl+C: progress was saved and summarized already
+				if errors.Is(err, context.Canceled) {
+					return 130, nil
+				}
+				// Some files failed: the rest were uploaded and recorded
+				if errors.Is(err, uploader.ErrPartialFailure) {
+					printErrorGuidance(result.Failures[0].Err, cfg)
+					printDeferred(deferred)
+					fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+					return partialExitCode(result), nil
+				}
+				printErrorGuidance(err, cfg)
+				return 0, fmt.Errorf("uploading files: %w", err)
+			}
+
+			printDeferred(deferred)
+			if secretsFound(result.RedactionStats) {
+				return exitSecretsFound, nil
+			}
+			return 0, nil
+		}
+
+		// run performs one upload, to every target with --all-targets
+		run := func() (int, error) {
+			if !uploadAllTargets {
+				return runTarget(cfg)
+			}
+			return uploadAllTargetsOnce(ctx, cfg, runTarget)
+		}
+
+		if uploadEvery == 0 {
+			code, err := run()
+			stopTelemetry() // Flushed before exitFunc skips the deferred call
+			if code != 0 {
+				exitFunc(code)
+			}
+			return err
+		}
+		return uploadEveryInterval(ctx, uploadEvery, run)
+	},
+}
+
+// uploadAllTargetsOnce runs run for each configured target in turn. A target
+// that fails is reported and the rest are still uploaded to; the result is
+// that of the first failed target, if any. Ctrl+C stops before the next one.
+func uploadAllTargetsOnce(ctx context.Context, cfg *types.Config, run func(*types.Config) (int, error)) (int, error) {
+	var firstCode int
+	var firstErr error
+	var failed []string
+	targets := config.Targets(cfg)
+	for i, t := range targets {
+		if i > 0 {
+			fmt.Fprintln(output.Human())
+		}
+		fmt.Fprintf(output.Human(), "Target %s (s3://%s/%s):\n", t.TargetName, t.S3.Bucket, t.S3.Prefix)
+		useTarget(t)
+		code, err := run(t)
+		if code == 130 || ctx.Err() != nil {
+			return code, err
+		}
+		if code == 0 && err == nil {
+			continue
+		}
+		failed = append(failed, t.TargetName)
+		if len(failed) == 1 {
+			firstCode, firstErr = code, err
+		} else if err != nil {
+			// Only the first error is returned; report the others here
+			fmt.Fprintf(os.Stderr, "Error: target %s: %v\n", t.TargetName, err)
+		}
+	}
+	if len(failed) > 0 {
+		fmt.Fprintf(os.Stderr, "%d of %d targets did not finish cleanly: %s\n", len(failed), len(targets), strings.Join(failed, ", "))
+	}
+	if firstErr != nil {
+		return 0, fmt.Errorf("uploading to target %s: %w", failed[0], firstErr)
+	}
+	return firstCode, nil
+}
+
+// uploadEveryInterval repeats run, starting each run interval after the
+// previous one ended so runs never overlap, until interrupted. A failed run
+// is reported and retried at the next interval instead of ending the loop.
+func uploadEveryInterval(ctx context.Context, interval time.Duration, run func() (int, error)) error {
+	for {
+		code, err := run()
+		if ctx.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		} else if code != 0 {
+			fmt.Fprintf(os.Stderr, "Run finished with exit status %d\n", code)
+		}
+
+		fmt.Fprintf(output.Human(), "Next upload at %s\n", time.Now().Add(interval).Format(time.RFC3339))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(interval):
+		}
+	}
+}
+
+// acquireUploadLock takes the lock that keeps uploads on this machine from
+// overlapping. With wait it waits for the current holder to finish.
+func acquireUploadLock(ctx context.Context, wait bool) (*lock.Lock, error) {
+	dir := config.StateDir(configPath)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("creating state directory: %w", err)
+	}
+	path := filepath.Join(dir, lock.File)
+
+	l, err := lock.Acquire(path)
+	var held *lock.HeldError
+	if !errors.As(err, &held) {
+		return l, err
+	}
+	if !wait {
+		return nil, fmt.Errorf("%w; use --wait-lock to wait for it", err)
+	}
+	fmt.Fprintf(os.Stderr, "Waiting: %v\n", err)
+	return lock.Wait(ctx, path)
+}
+
+// acquireRemoteLock takes the advisory lock object next to the manifest. With
+// wait it waits for the current holder; with ignore it warns and returns a
+// nil lock instead of failing. An endpoint found to lack conditional writes
+// gets no lock, with a warning.
+func acquireRemoteLock(ctx context.Context, cfg *types.Config, client *s3.Client, wait, ignore bool) (*lock.Remote, error) {
+	if !capabilities(cfg).Supports(selftest.FeatureConditionalWrite) {
+		fmt.Fprintf(os.Stderr, "Warning: upload.remote_lock skipped: %s does not support conditional writes (doctor --capabilities)\n", endpointName(cfg))
+		return nil, nil
+	}
+	key := config.KeyPrefix(cfg) + lock.RemoteFile
+	holder := lock.Holder{Machine: cfg.Local.MachineID, Hostname: cfg.Identity.Hostname, PID: os.Getpid()}
+
+	rl, err := lock.AcquireRemote(ctx, client, cfg.S3.Bucket, key, holder, lock.DefaultTTL)
+	var held *lock.RemoteHeldError
+	if !errors.As(err, &held) {
+		return rl, err
+	}
+	switch {
+	case ignore:
+		fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		fmt.Fprintln(os.Stderr, "Warning: uploading anyway (--ignore-lock); both runs may upload the same files")
+		return nil, nil
+	case wait:
+		fmt.Fprintf(os.Stderr, "Waiting: %v\n", err)
+		return lock.WaitRemote(ctx, client, cfg.S3.Bucket, key, holder, lock.DefaultTTL)
+	default:
+		return nil, fmt.Errorf("%w; use --wait-lock to wait for it or --ignore-lock to upload anyway", err)
+	}
+}
+
+// releaseRemoteLock deletes the remote lock. It runs after Ctrl+C too, so it
+// does not use the run's context.
+func releaseRemoteLock(cfg *types.Config, rl *lock.Remote) {
+	ctx, cancel := config.WithOperationTimeout(context.Background(), cfg.S3.OperationTimeout)
+	defer cancel()
+	if err := rl.Release(ctx); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: releasing remote lock: %v\n", err)
+	}
+}
+
+var (
+	watchSettle   time.Duration
+	watchInterval time.Duration
+)
+
+var watchCmd = &cobra.Command{
+	Use:   "watch",
+	Short: "Keep running and upload session logs once they stop changing",
+	Long: `Uploads pending files, then polls the projects root and uploads each
+changed .jsonl file once it has not changed for --settle. Active sessions
+append to their log constantly, so a session is uploaded when it pauses rather
+than after every line. Project directories created while watching are picked
+up, and each cycle reloads the manifest so uploads from other machines are
+seen. Stops cleanly on Ctrl+C or SIGTERM, for use under launchd or systemd.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if watchSettle <= 0 || watchInterval <= 0 {
+			return fmt.Errorf("--settle and --interval must be positive")
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+		if err := boundUploadMemory(cfg); err != nil {
+			return err
+		}
+
+		stopTelemetry := startTelemetry(cfg)
+		defer stopTelemetry()
+
+		ctx := cmd.Context()
+		client, err := config.NewS3Client(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("creating S3 client: %w", err)
+		}
+		if err := confirmFirstUpload(ctx, cfg, client); err != nil {
+			return err
+		}
+		if err := checkArchive(ctx, cfg, client, false); err != nil {
+			return err
+		}
+
+		tracker := watch.New(cfg.Local.ProjectsRoot, cfg.Local.Extensions, watchSettle)
+		if err := tracker.Prime(); err != nil {
+			return err
+		}
+
+		// Catch up on changes made while not watching
+		watchCycle(ctx, cfg, client, nil)
+		fmt.Fprintf(output.Human(), "Watching %s (settle %s, poll every %s)\n", cfg.Local.ProjectsRoot, watchSettle, watchInterval)
+
+		ticker := time.NewTicker(watchInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				if n := tracker.Pending(); n > 0 {
+					fmt.Fprintf(output.Human(), "Stopped watching; %d changed files were still settling and will upload next run.\n", n)
+				} else {
+					fmt.Fprintln(output.Human(), "Stopped watching.")
+				}
+				return nil
+			case now := <-ticker.C:
+				changed, err := tracker.Poll(now)
 				if err != nil {
-					fmt.Fprintf(os.Stderr, "Warning: could not load manifest: %v\n", err)
-					m = manifest.New()
+					fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+					continue
 				}
-				remoteProjects = discover.DiscoverFromManifest(m, cfg.S3.Prefix)
+				if len(changed) > 0 {
+					watchCycle(ctx, cfg, client, changed)
+				}
+			}
+		}
+	},
+}
+
+// watchCycle uploads paths (every pending file if nil) with a fresh uploader,
+// so the manifest is reloaded each cycle, and logs the result on one line.
+func watchCycle(ctx context.Context, cfg *types.Config, client *s3.Client, paths []string) {
+	ctx, span := telemetry.Tracer().Start(ctx, "watch.cycle", trace.WithAttributes(attribute.Int("watch.changed", len(paths))))
+	defer span.End()
+
+	l, err := acquireUploadLock(ctx, true)
+	if err != nil {
+		if ctx.Err() == nil {
+			fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+		}
+		return
+	}
+	defer func() { _ = l.Release() }()
+
+	if cfg.Upload.RemoteLock {
+		rl, err := acquireRemoteLock(ctx, cfg, client, true, false)
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
 			}
+			return
+		}
+		if rl != nil {
+			defer releaseRemoteLock(cfg, rl)
+		}
+	}
+
+	u := uploader.New(cfg, client, false, false)
+	u.SetOutput(io.Discard, os.Stderr)
+	u.SetResumeDir(resumeDir())
+
+	files, err := u.DiscoverFiles(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: discovering files: %v\n", err)
+		return
+	}
+	if paths != nil {
+		files = slices.DeleteFunc(files, func(f uploader.FileUpload) bool {
+			return !slices.Contains(paths, f.LocalPath)
+		})
+	}
+
+	result, err := u.Upload(ctx, files)
+	if errors.Is(err, context.Canceled) {
+		return
+	}
+	if err != nil && !errors.Is(err, uploader.ErrPartialFailure) {
+		fmt.Fprintf(os.Stderr, "Warning: uploading files: %v\n", err)
+		return
+	}
+	fmt.Fprintf(output.Human(), "%s uploaded %s, %d skipped, %d failed\n", time.Now().Format(time.RFC3339),
+		uploader.Deferred{Files: result.Uploaded, Bytes: result.UploadedBytes}, result.Skipped, len(result.Failures))
+	for _, f := range result.Failures {
+		fmt.Fprintf(os.Stderr, "  %s: %v\n", f.Path, f.Err)
+	}
+}
+
+var doctorCapabilities bool
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "Validate configuration and connectivity",
+	Long: `Checks that the configuration is valid, local projects root exists,
+and remote S3 connectivity works.
+
+With --capabilities, also probes which optional S3 features the endpoint
+supports (conditional writes, checksum headers, tagging, CopyObject, and
+DeleteObjects) using tiny objects under <prefix>/.selftest/capabilities/,
+which are deleted afterwards. The results are saved in the state file, and
+later runs avoid the features found missing instead of failing mid-run.
+Credentials that cannot write skip the probe.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		allPassed := doctor.RunChecks(cfg, configPath, false)
+		if doctorCapabilities && !probeCapabilities(cmd.Context(), cfg) {
+			allPassed = false
+		}
+		if !allPassed {
+			exitFunc(exitChecksFailed)
+		}
+		return nil
+	},
+}
+
+// probeCapabilities runs the capability probe, prints its results, and
+// records what it found. It reports whether the probe ran without failures.
+func probeCapabilities(ctx context.Context, cfg *types.Config) bool {
+	client, err := config.NewS3Client(ctx, cfg)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: creating S3 client: %v\n", err)
+		return false
+	}
+
+	features, results := selftest.ProbeCapabilities(ctx, cfg, client)
+	fmt.Fprintf(output.Human(), "\nCapabilities of s3://%s (%s):\n", cfg.S3.Bucket, endpointName(cfg))
+	doctor.PrintResults(results)
+	if features != nil {
+		err := identity.RecordCapabilities(cfg.Identity.StatePath, identity.Capabilities{
+			Endpoint:  cfg.S3.Endpoint,
+			Bucket:    cfg.S3.Bucket,
+			CheckedAt: time.Now().UTC(),
+			Features:  features,
+		})
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: saving capabilities: %v\n", err)
+		}
+	}
+	return doctor.Passed(results)
+}
+
+// endpointName returns the configured endpoint, or "AWS" for the default.
+func endpointName(cfg *types.Config) string {
+	if cfg.S3.Endpoint == "" {
+		return "AWS"
+	}
+	return cfg.S3.Endpoint
+}
+
+// capabilities returns what doctor --capabilities recorded about the
+// configured endpoint and bucket, or nil if it was not probed.
+func capabilities(cfg *types.Config) *identity.Capabilities {
+	return identity.LoadCapabilities(cfg.Identity.StatePath, cfg.S3.Endpoint, cfg.S3.Bucket)
+}
+
+var selftestCmd = &cobra.Command{
+	Use:   "selftest",
+	Short: "Run a harmless end-to-end upload against a disposable prefix",
+	Long: `Creates a tiny synthetic project with planted fake secrets in a temp dir,
+then discovers, redacts, and uploads it under <prefix>/.selftest/, records it in
+a scoped manifest, and verifies the object. Everything it created is deleted
+afterwards, even when a stage fails. Real projects and the real manifest are
+never touched. Exits non-zero if any stage fails.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+
+		client, err := config.NewS3Client(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("creating S3 client: %w", err)
+		}
+
+		results := selftest.Run(ctx, cfg, client, selftest.Options{})
+		fmt.Fprintf(output.Human(), "\nSelf-test against s3://%s/%s:\n", cfg.S3.Bucket, selftest.Prefix(cfg))
+		doctor.PrintResults(results)
+		if !doctor.Passed(results) {
+			exitFunc(exitChecksFailed)
+		}
+		return nil
+	},
+}
+
+var (
+	verifySample   string
+	verifyProject  string
+	verifyDeep     bool
+	verifyMaxBytes string
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that uploaded objects match the manifest",
+	Long: `Checks each manifest entry against remote storage, confirming the object
+exists and its size matches. With --deep, downloads each object and compares
+its SHA-256 to the hash recorded at upload time. Exits non-zero on problems.
+
+--sample checks N objects or a percentage (e.g. 5%), chosen the same way all
+day and differently the next. Deep samples take the objects verified least
+recently first, and each object that matches is stamped in the manifest so
+coverage accumulates across runs; status reports it. --max-bytes caps how
+much a deep run downloads.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts := verify.Options{Project: verifyProject, Deep: verifyDeep, Now: time.Now()}
+		if err := parseSample(verifySample, &opts); err != nil {
+			return fmt.Errorf("--sample: %w", err)
+		}
+		if verifyMaxBytes != "" {
+			if !verifyDeep {
+				return fmt.Errorf("--max-bytes requires --deep")
+			}
+			maxBytes, err := types.ParseByteSize(verifyMaxBytes)
+			if err != nil {
+				return fmt.Errorf("--max-bytes: %w", err)
+			}
+			opts.MaxBytes = int64(maxBytes)
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+
+		client, err := config.NewS3Client(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("creating S3 client: %w", err)
+		}
+
+		m, err := manifest.Load(ctx, client, cfg.S3.Bucket, manifest.ConfigKey(cfg), cfg.S3.OperationTimeout)
+		if err != nil {
+			return fmt.Errorf("loading manifest: %w", err)
+		}
+
+		opts.Prefix = config.KeyPrefix(cfg)
+		opts.Timeout = cfg.S3.OperationTimeout
+		results, err := verify.Run(ctx, client, cfg.S3.Bucket, m, opts)
+		if err != nil {
+			return fmt.Errorf("verifying objects: %w", err)
+		}
+
+		verify.PrintResults(output.Human(), results)
+		if verifyDeep {
+			if err := recordVerified(ctx, cfg, client, results, opts.Now); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not record verification in the manifest: %v\n", err)
+			}
+		}
+		if verify.HasProblems(results) {
+			exitFunc(1)
+		}
+		return nil
+	},
+}
+
+// parseSample reads --sample: a count ("50") or a percentage ("5%").
+func parseSample(s string, opts *verify.Options) error {
+	if s == "" {
+		return nil
+	}
+	if p, ok := strings.CutSuffix(s, "%"); ok {
+		percent, err := strconv.ParseFloat(p, 64)
+		if err != nil || percent <= 0 || percent > 100 {
+			return fmt.Errorf("invalid percentage %q (want more than 0%% and at most 100%%)", s)
+		}
+		opts.Percent = percent
+		return nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return fmt.Errorf("invalid sample %q (want a count or a percentage such as 5%%)", s)
+	}
+	opts.Sample = n
+	return nil
+}
+
+// recordVerified stamps the objects a deep verify matched in the manifest and
+// reports how much of the archive has been deep-verified recently. The
+// manifest is reloaded under the upload lock so entries uploaded during the
+// run are kept.
+func recordVerified(ctx context.Context, cfg *types.Config, client *s3.Client, results []verify.Result, at time.Time) error {
+	l, err := acquireUploadLock(ctx, true)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = l.Release() }()
+
+	key := manifest.ConfigKey(cfg)
+	m, err := manifest.Load(ctx, client, cfg.S3.Bucket, key, cfg.S3.OperationTimeout)
+	if err != nil {
+		return fmt.Errorf("loading manifest: %w", err)
+	}
+	if verify.Record(m, results, at) > 0 {
+		if err := manifest.Save(ctx, client, cfg.S3.Bucket, key, m, cfg.S3.OperationTimeout); err != nil {
+			return fmt.Errorf("saving manifest: %w", err)
+		}
+	}
+
+	verified, total := m.VerifiedSince(config.KeyPrefix(cfg), at.Add(-uploader.DeepVerifyWindow))
+	fmt.Fprintf(output.Human(), "Deep-verified: %s of the archive (%d of %d objects) in the last %d days\n",
+		uploader.CoveragePercent(verified, total), verified, total, int(uploader.DeepVerifyWindow.Hours()/24))
+	return nil
+}
+
+var (
+	cclsConfigPath        string
+	defaultCclsConfigPath string
+)
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate-from-ccls",
+	Short: "Migrate config and upload state from ccls",
+	Long: `Copies the ccls config (~/.ccls/config.yaml) to the cclogs config path and
+builds a manifest from the existing bucket contents, so files uploaded by ccls
+are recognized and not uploaded again. Safe to run more than once.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		copied, err := migrate.MigrateConfig(cclsConfigPath, configPath)
+		if err != nil {
+			return fmt.Errorf("migrating config: %w", err)
+		}
+		if copied {
+			fmt.Fprintf(output.Human(), "Copied config %s → %s\n", cclsConfigPath, configPath)
+		} else {
+			fmt.Fprintf(output.Human(), "Config: nothing to copy (using %s)\n", configPath)
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+
+		client, err := config.NewS3Client(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("creating S3 client: %w", err)
+		}
+
+		manifestKey := manifest.ConfigKey(cfg)
+		m, err := manifest.Load(ctx, client, cfg.S3.Bucket, manifestKey, cfg.S3.OperationTimeout)
+		if err != nil {
+			return fmt.Errorf("loading manifest: %w", err)
+		}
+
+		// Discover local files without consulting the manifest
+		files, err := uploader.New(cfg, nil, true, false).DiscoverFiles(ctx)
+		if err != nil {
+			return fmt.Errorf("discovering files: %w", err)
+		}
+		local := make(map[string]migrate.LocalFile, len(files))
+		for _, f := range files {
+			local[f.S3Key] = migrate.LocalFile{Mtime: f.ModTime, Size: f.Size, Project: f.ProjectDir}
+		}
+
+		before := len(m.Files)
+		added, err := migrate.BootstrapManifest(ctx, client, cfg.S3.Bucket, config.KeyPrefix(cfg), cfg.Local.Extensions, m, local, cfg.S3.OperationTimeout)
+		if err != nil {
+			return fmt.Errorf("bootstrapping manifest: %w", err)
+		}
+
+		if added > 0 {
+			if err := manifest.Save(ctx, client, cfg.S3.Bucket, manifestKey, m, cfg.S3.OperationTimeout); err != nil {
+				return fmt.Errorf("saving manifest: %w", err)
+			}
+		}
+
+		fmt.Fprintf(output.Human(), "Manifest: %d existing entries, %d added from bucket listing\n", before, added)
+		return nil
+	},
+}
+
+var (
+	manifestShowJSON   bool
+	manifestYes        bool
+	manifestFsckRemote bool
+	manifestFsckRepair bool
+	manifestRestoreRef string
+	manifestFindTags   []string
+	manifestFindJSON   bool
+)
+
+var manifestCmd = &cobra.Command{
+	Use:   "manifest",
+	Short: "Inspect, rebuild, or restore the upload manifest",
+	Long: `The manifest records every uploaded log: its source mtime and size, the
+stored object's size and hash, and its project. Uploads compare local files
+against it to decide what to skip.`,
+}
+
+var manifestShowCmd = &cobra.Command{
+	Use:   "show [project]",
+	Short: "Print the manifest with per-project totals",
+	Long: `Prints the number of files, source and stored sizes, newest file, and
+uploading machines of each project in the manifest. With a project, lists
+that project's entries instead.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		client, err := config.NewS3Client(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("creating S3 client: %w", err)
+		}
+
+		key := manifest.ConfigKey(cfg)
+		m, err := manifest.Load(ctx, client, cfg.S3.Bucket, key, cfg.S3.OperationTimeout)
+		if err != nil {
+			return fmt.Errorf("loading manifest: %w", err)
+		}
+
+		prefix := config.KeyPrefix(cfg)
+		projects := m.Summarize(prefix)
+		project := ""
+		if len(args) == 1 {
+			project = args[0]
+			projects = slices.DeleteFunc(projects, func(p manifest.ProjectSummary) bool { return p.Project != project })
+			if len(projects) == 0 {
+				return fmt.Errorf("project %q is not in the manifest", project)
+			}
+		}
+
+		if manifestShowJSON {
+			files := m.Files
+			if project != "" {
+				files = make(map[string]manifest.FileEntry)
+				for k, e := range m.Files {
+					if m.Project(k, prefix) == project {
+						files[k] = e
+					}
+				}
+			}
+			data, err := json.MarshalIndent(struct {
+				Key        string                        `json:"key"`
+				Version    int                           `json:"version"`
+				LastUpload time.Time                     `json:"last_upload,omitzero"`
+				Projects   []manifest.ProjectSummary     `json:"projects"`
+				Files      map[string]manifest.FileEntry `json:"files"`
+			}{key, m.Version, m.LastUpload, projects, files}, "", "  ")
+			if err != nil {
+				return fmt.Errorf("marshaling JSON: %w", err)
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		fmt.Fprintf(output.Human(), "Manifest: s3://%s/%s\n", cfg.S3.Bucket, key)
+		if !m.LastUpload.IsZero() {
+			fmt.Fprintf(output.Human(), "Last upload: %s\n", m.LastUpload.Local().Format(time.RFC3339))
+		}
+		if project != "" {
+			output.PrintManifestFiles(m, prefix, project)
+			return nil
+		}
+		output.PrintManifestProjects(projects)
+		return nil
+	},
+}
+
+var manifestRebuildCmd = &cobra.Command{
+	Use:   "rebuild",
+	Short: "Reconstruct the manifest from a bucket listing",
+	Long: `Lists every object under the prefix and builds a new manifest from it, for
+when the manifest was lost or objects were deleted by hand. Objects are
+matched against s3.key_template (compression suffixes included) to find
+their project and machine; other objects are ignored.
+
+Entries of the current manifest are kept for objects whose stored size is
+unchanged; other objects are read with HEAD for the source size and content
+hash the uploader records. A local file that has not changed since its
+object was written keeps its mtime, so the next upload skips it.
+
+The new manifest replaces the old one only after confirmation, or with --yes.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		client, err := config.NewS3Client(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("creating S3 client: %w", err)
+		}
+
+		// Hold off uploads so none saves a manifest in between
+		l, err := acquireUploadLock(ctx, false)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = l.Release() }()
+		if cfg.Upload.RemoteLock {
+			rl, err := acquireRemoteLock(ctx, cfg, client, false, false)
+			if err != nil {
+				return err
+			}
+			if rl != nil {
+				defer releaseRemoteLock(cfg, rl)
+			}
+		}
+
+		key := manifest.ConfigKey(cfg)
+		previous, err := manifest.Load(ctx, client, cfg.S3.Bucket, key, cfg.S3.OperationTimeout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: ignoring the current manifest: %v\n", err)
+			previous = nil
+		}
+
+		local := make(map[string]manifest.LocalFile)
+		files, err := uploader.New(cfg, nil, true, false).DiscoverFiles(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: local files not compared: %v\n", err)
+		}
+		for _, f := range files {
+			local[f.S3Key] = manifest.LocalFile{Mtime: f.ModTime, Size: f.Size, Project: f.ProjectDir}
+		}
+
+		prefix := config.KeyPrefix(cfg)
+		m, stats, err := manifest.Rebuild(ctx, client, cfg.S3.Bucket, manifest.RebuildOptions{
+			Prefix:       cfg.S3.Prefix,
+			ListPrefix:   prefix,
+			Template:     config.KeyTemplate(cfg),
+			Extensions:   cfg.Local.Extensions,
+			ProjectDepth: cfg.Local.ProjectDepth,
+			Previous:     previous,
+			Local:        local,
+			Timeout:      cfg.S3.OperationTimeout,
+		})
+		if err != nil {
+			return fmt.Errorf("rebuilding manifest: %w", err)
+		}
+
+		output.PrintManifestProjects(m.Summarize(prefix))
+		fmt.Fprintf(output.Human(), "Rebuilt from %d objects: %d entries kept, %d added, %d removed, %d objects ignored\n",
+			stats.Objects+stats.Ignored+stats.Older, stats.Kept, stats.Added, stats.Removed, stats.Ignored)
+		if stats.Older > 0 {
+			fmt.Fprintf(output.Human(), "%d older dated copies were superseded by newer uploads of the same file\n", stats.Older)
+		}
+
+		if !manifestYes {
+			if !stdinIsTerminal() {
+				fmt.Fprintln(output.Human(), "Manifest not saved. Run with --yes to replace it.")
+				return nil
+			}
+			if !askYesNo(os.Stdin, os.Stderr, fmt.Sprintf("Replace the manifest at s3://%s/%s?", cfg.S3.Bucket, key)) {
+				fmt.Fprintln(output.Human(), "Manifest not saved.")
+				return nil
+			}
+		}
+
+		if err := manifest.Save(ctx, client, cfg.S3.Bucket, key, m, cfg.S3.OperationTimeout); err != nil {
+			return fmt.Errorf("saving manifest: %w", err)
+		}
+		fmt.Fprintf(output.Human(), "Saved manifest with %d entries to s3://%s/%s\n", len(m.Files), cfg.S3.Bucket, key)
+		return nil
+	},
+}
+
+var manifestFsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Check the manifest for inconsistencies",
+	Long: `Downloads the manifest and checks it: that it decodes and has a supported
+version, that every key falls under the prefix, that no mtime lies in the
+future, and that no size is negative. With --remote each entry's object is
+also checked with HEAD. Problems are listed grouped by kind.
+
+--repair drops the entries whose objects are missing and saves the manifest,
+so the next upload sends those files again. It implies --remote. Other
+problems are only reported; a manifest that cannot be decoded can be
+replaced with 'cclogs manifest rebuild'.
+
+Exits with status 1 when problems remain.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		client, err := config.NewS3Client(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("creating S3 client: %w", err)
+		}
+
+		remaining, err := manifestFsck(ctx, cfg, client)
+		if err != nil {
+			return err
+		}
+		if remaining > 0 {
+			exitFunc(1)
+		}
+		return nil
+	},
+}
+
+var manifestFindCmd = &cobra.Command{
+	Use:   "find --tag <pattern>",
+	Short: "List uploaded logs in which a redaction pattern matched",
+	Long: `Lists the manifest entries whose logs had matches of any of the given
+redaction patterns (e.g. AWS_SECRET, EMAIL) when they were uploaded, with
+every pattern that matched in each. The answer comes from the manifest alone;
+nothing is downloaded. Logs uploaded with --no-redact record no patterns.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(manifestFindTags) == 0 {
+			return fmt.Errorf("--tag is required")
+		}
+		known := redactor.Tags()
+		for _, tag := range manifestFindTags {
+			if !slices.Contains(known, strings.ToUpper(tag)) {
+				return fmt.Errorf("--tag: unknown pattern %q (known: %s)", tag, strings.Join(known, ", "))
+			}
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		client, err := config.NewS3Client(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("creating S3 client: %w", err)
+		}
+
+		key := manifest.ConfigKey(cfg)
+		m, err := manifest.Load(ctx, client, cfg.S3.Bucket, key, cfg.S3.OperationTimeout)
+		if err != nil {
+			return fmt.Errorf("loading manifest: %w", err)
+		}
+
+		found := findTagged(m, config.KeyPrefix(cfg), manifestFindTags)
+		if manifestFindJSON {
+			data, err := json.MarshalIndent(found, "", "  ")
+			if err != nil {
+				return fmt.Errorf("marshaling JSON: %w", err)
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		for _, f := range found {
+			fmt.Printf("%s\t%s\n", f.Key, strings.Join(f.Tags, ","))
+		}
+		fmt.Fprintf(output.Human(), "%d of %d logs matched\n", len(found), len(m.Files))
+		return nil
+	},
+}
+
+// taggedFile is a manifest entry found by manifest find.
+type taggedFile struct {
+	Key     string   `json:"key"`
+	Project string   `json:"project"`
+	Tags    []string `json:"tags"` // Every pattern that matched, not only the ones searched for
+}
+
+// findTagged returns the entries of m in which any of tags matched, in key order.
+func findTagged(m *manifest.Manifest, prefix string, tags []string) []taggedFile {
+	found := make([]taggedFile, 0)
+	for _, key := range m.FindTags(tags) {
+		found = append(found, taggedFile{Key: key, Project: m.Project(key, prefix), Tags: m.Files[key].Tags()})
+	}
+	return found
+}
+
+var manifestRestoreCmd = &cobra.Command{
+	Use:   "restore --from <backup>",
+	Short: "Roll the manifest back to a backup",
+	Long: `Each save first copies the manifest into .manifest-history/ next to it,
+keeping the newest s3.manifest_history copies. Without --from, lists them,
+newest first. --from takes a backup's name, its key, or the start of its
+name such as the timestamp; the backup must be a readable manifest.
+
+The current manifest is backed up before it is replaced, so a restore can be
+undone. It is replaced only after confirmation, or with --yes.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		client, err := config.NewS3Client(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("creating S3 client: %w", err)
+		}
+
+		key := manifest.ConfigKey(cfg)
+		backups, err := manifest.Backups(ctx, client, cfg.S3.Bucket, key, cfg.S3.OperationTimeout)
+		if err != nil {
+			return err
+		}
+		if manifestRestoreRef == "" {
+			if len(backups) == 0 {
+				fmt.Fprintf(output.Human(), "No backups under s3://%s/%s\n", cfg.S3.Bucket, manifest.HistoryPrefix(key))
+				return nil
+			}
+			for _, b := range backups {
+				fmt.Fprintf(output.Human(), "%s  %s  %s\n", path.Base(b.Key),
+					b.LastModified.Local().Format(time.RFC3339), output.FormatSize(b.Size))
+			}
+			fmt.Fprintln(output.Human(), "Restore one with --from <name>.")
+			return nil
+		}
+
+		b, err := manifest.FindBackup(backups, manifestRestoreRef)
+		if err != nil {
+			return err
+		}
+
+		// Hold off uploads so none saves a manifest in between
+		l, err := acquireUploadLock(ctx, false)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = l.Release() }()
+		if cfg.Upload.RemoteLock {
+			rl, err := acquireRemoteLock(ctx, cfg, client, false, false)
+			if err != nil {
+				return err
+			}
+			if rl != nil {
+				defer releaseRemoteLock(cfg, rl)
+			}
+		}
+
+		if !manifestYes {
+			if !stdinIsTerminal() {
+				fmt.Fprintln(output.Human(), "Manifest not restored. Run with --yes to replace it.")
+				return nil
+			}
+			if !askYesNo(os.Stdin, os.Stderr, fmt.Sprintf("Replace the manifest at s3://%s/%s with %s?", cfg.S3.Bucket, key, path.Base(b.Key))) {
+				fmt.Fprintln(output.Human(), "Manifest not restored.")
+				return nil
+			}
+		}
+
+		m, err := manifest.Restore(ctx, client, cfg.S3.Bucket, key, b.Key, cfg.S3.OperationTimeout)
+		if err != nil {
+			return fmt.Errorf("restoring manifest: %w", err)
+		}
+		fmt.Fprintf(output.Human(), "Restored manifest with %d entries from %s\n", len(m.Files), path.Base(b.Key))
+		return nil
+	},
+}
+
+// manifestFsck checks the manifest, prints its problems grouped by kind, and
+// with --repair drops the entries of missing objects. It returns the number
+// of problems left. Locks are released before it returns, so the caller may
+// exit.
+func manifestFsck(ctx context.Context, cfg *types.Config, client *s3.Client) (int, error) {
+	if manifestFsckRepair {
+		manifestFsckRemote = true
+		// Hold off uploads so none saves a manifest in between
+		l, err := acquireUploadLock(ctx, false)
+		if err != nil {
+			return 0, err
+		}
+		defer func() { _ = l.Release() }()
+		if cfg.Upload.RemoteLock {
+			rl, err := acquireRemoteLock(ctx, cfg, client, false, false)
+			if err != nil {
+				return 0, err
+			}
+			if rl != nil {
+				defer releaseRemoteLock(cfg, rl)
+			}
+		}
+	}
+
+	key := manifest.ConfigKey(cfg)
+	data, err := manifest.Fetch(ctx, client, cfg.S3.Bucket, key, cfg.S3.OperationTimeout)
+	if err != nil {
+		return 0, err
+	}
+	if data == nil {
+		fmt.Fprintf(output.Human(), "No manifest at s3://%s/%s\n", cfg.S3.Bucket, key)
+		return 0, nil
+	}
+
+	report := manifest.Fsck(data, config.KeyPrefix(cfg), time.Now())
+	if manifestFsckRemote && !report.Fatal() {
+		missing, err := manifest.CheckObjects(ctx, client, cfg.S3.Bucket, report.Manifest, cfg.S3.OperationTimeout)
+		if err != nil {
+			return 0, fmt.Errorf("checking objects: %w", err)
+		}
+		report.Problems = append(report.Problems, missing...)
+	}
+
+	fmt.Fprintf(output.Human(), "Manifest: s3://%s/%s\n", cfg.S3.Bucket, key)
+	groups := report.ByKind()
+	for _, kind := range manifest.ProblemKinds {
+		g := groups[kind]
+		if len(g) == 0 {
+			continue
+		}
+		fmt.Fprintf(output.Human(), "\n%s (%d):\n", kind, len(g))
+		for _, p := range g {
+			if p.Key == "" {
+				fmt.Fprintf(output.Human(), "  %s\n", p.Detail)
+			} else {
+				fmt.Fprintf(output.Human(), "  %s: %s\n", p.Key, p.Detail)
+			}
+		}
+	}
+
+	remaining := len(report.Problems)
+	if manifestFsckRepair {
+		if missing := groups[manifest.ProblemMissing]; len(missing) > 0 {
+			for _, p := range missing {
+				delete(report.Manifest.Files, p.Key)
+			}
+			if err := manifest.Save(ctx, client, cfg.S3.Bucket, key, report.Manifest, cfg.S3.OperationTimeout); err != nil {
+				return 0, fmt.Errorf("saving manifest: %w", err)
+			}
+			fmt.Fprintf(output.Human(), "\nDropped %d entries for missing objects\n", len(missing))
+			remaining -= len(missing)
+		}
+	}
+
+	if remaining > 0 {
+		fmt.Fprintf(output.Human(), "\n%d problems found\n", remaining)
+	} else {
+		fmt.Fprintf(output.Human(), "No problems found in %d entries\n", len(report.Manifest.Files))
+	}
+	return remaining, nil
+}
+
+var (
+	catRaw    bool
+	catSource sourceFlags
+)
+
+var catCmd = &cobra.Command{
+	Use:   "cat <project/file>",
+	Short: "Print a session log to stdout",
+	Long: `Streams a session log to stdout without saving it to disk. The argument is
+the file path relative to the projects root, e.g. my-app/session.jsonl.
+The local file is read by default, unredacted. --remote reads the uploaded
+copy instead; compressed objects are decompressed unless --raw is given.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		src, err := logSource(cmd.Context(), cfg, !catSource.remote, catRaw)
+		if err != nil {
+			return err
+		}
+
+		body, err := src.Open(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+		defer func() { _ = body.Close() }()
+
+		if _, err := io.Copy(os.Stdout, body); err != nil {
+			return fmt.Errorf("reading %s: %w", args[0], err)
+		}
+		return nil
+	},
+}
+
+var (
+	exportFormat   string
+	exportOutput   string
+	exportSource   sourceFlags
+	exportNoRedact bool
+	exportSplit    bool
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export <project/file>",
+	Short: "Render a session log as a readable transcript",
+	Long: `Renders a session log as a conversation transcript in Markdown (default) or
+HTML: each user and assistant turn with its text, thinking, tool calls, and
+tool output. The argument is the file path relative to the projects root,
+e.g. my-app/session.jsonl.
+
+The local log is read by default and redacted on the way out, as an upload
+would be; --no-redact skips that. --remote reads the uploaded copy, which was
+redacted when it was uploaded. Lines that can't be parsed are skipped and
+counted on stderr.
+
+A file Claude Code appended to after a crash can hold several sessions, told
+apart by their sessionId. --split writes one transcript per session, named
+after --output with the session number before the extension (s-1.html,
+s-2.html, ...). A file with a single session, or without session IDs, is
+written to --output as usual.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, err := export.ParseFormat(exportFormat)
+		if err != nil {
+			return fmt.Errorf("--format: %w", err)
+		}
+		if exportSplit && exportOutput == "" {
+			return fmt.Errorf("--split requires --output")
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		src, err := logSource(cmd.Context(), cfg, !exportSource.remote, false)
+		if err != nil {
+			return err
+		}
+
+		body, err := src.Open(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+		defer func() { _ = body.Close() }()
+
+		var r io.Reader = body
+		if !exportSource.remote && !exportNoRedact {
+			r = redactor.StreamRedact(body)
+		}
+
+		if !exportSplit {
+			return exportTranscript(r, format, args[0], exportOutput)
+		}
+
+		// The log is read once to find its sessions, then once per session
+		var log bytes.Buffer
+		if _, err := io.Copy(&log, r); err != nil {
+			return fmt.Errorf("reading %s: %w", args[0], err)
+		}
+		sessions, err := transcript.Split(bytes.NewReader(log.Bytes()))
+		if err != nil {
+			return fmt.Errorf("reading %s: %w", args[0], err)
+		}
+		if len(sessions) <= 1 {
+			return exportTranscript(bytes.NewReader(log.Bytes()), format, args[0], exportOutput)
+		}
+
+		for i, session := range sessions {
+			var part bytes.Buffer
+			if err := transcript.Extract(bytes.NewReader(log.Bytes()), session, &part); err != nil {
+				return fmt.Errorf("reading %s: %w", args[0], err)
+			}
+			title := fmt.Sprintf("%s (session %s)", args[0], session.ID)
+			if err := exportTranscript(&part, format, title, splitOutputPath(exportOutput, i+1)); err != nil {
+				return err
+			}
+		}
+		return nil
+	},
+}
+
+// exportTranscript renders the log read from r to path, or to stdout if path
+// is empty.
+func exportTranscript(r io.Reader, format, title, path string) error {
+	entries, stats, err := export.Parse(r)
+	if err != nil {
+		return fmt.Errorf("reading %s: %w", title, err)
+	}
+
+	var buf bytes.Buffer
+	if err := export.Render(&buf, format, title, entries); err != nil {
+		return fmt.Errorf("rendering %s: %w", title, err)
+	}
+
+	if path == "" {
+		if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+			return fmt.Errorf("writing transcript: %w", err)
+		}
+	} else if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		return fmt.Errorf("writing transcript: %w", err)
+	}
+
+	if stats.Skipped > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: skipped %d lines that could not be parsed\n", stats.Skipped)
+	}
+	if path != "" {
+		fmt.Fprintf(os.Stderr, "Exported %d messages to %s\n", stats.Messages, path)
+	}
+	return nil
+}
+
+// splitOutputPath returns the file --split writes session n to: path with
+// "-n" before its extension.
+func splitOutputPath(path string, n int) string {
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), n, ext)
+}
+
+// sourceFlags are the flags shared by the log-reading commands (cat, export,
+// search, stats): --local and --remote pick where logs are read from, local
+// logs being the default, and --project narrows commands that read many logs.
+type sourceFlags struct {
+	local   bool
+	remote  bool
+	project string
+}
+
+// register adds --local and --remote to cmd, and --project when project is
+// set.
+func (f *sourceFlags) register(cmd *cobra.Command, project bool) {
+	cmd.Flags().BoolVar(&f.local, "local", false, "read local logs from the projects root (the default)")
+	cmd.Flags().BoolVar(&f.remote, "remote", false, "read the uploaded copies in the bucket instead of local logs")
+	cmd.MarkFlagsMutuallyExclusive("local", "remote")
+	if project {
+		cmd.Flags().StringVar(&f.project, "project", "", "only read this project")
+	}
+}
+
+// logSource returns where log-reading commands read from: the projects root
+// when local is set, otherwise the bucket via the manifest. raw only applies to the
+// bucket, where it skips decompression.
+func logSource(ctx context.Context, cfg *types.Config, local, raw bool) (fetch.Source, error) {
+	if local {
+		return fetch.LocalSource{
+			Root:          cfg.Local.ProjectsRoot,
+			Extensions:    cfg.Local.Extensions,
+			ProjectDepth:  cfg.Local.ProjectDepth,
+			ProjectMarker: cfg.Local.ProjectMarker,
+		}, nil
+	}
+
+	client, err := config.NewS3Client(ctx, cfg)
+	if err != nil {
+		return nil, fmt.Errorf("creating S3 client: %w", err)
+	}
+
+	m, err := manifest.Load(ctx, client, cfg.S3.Bucket, manifest.ConfigKey(cfg), cfg.S3.OperationTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not load manifest: %v\n", err)
+		m = manifest.New()
+	}
+	return fetch.RemoteSource{Config: cfg, Client: client, Manifest: m, Raw: raw}, nil
+}
+
+var (
+	searchSource     sourceFlags
+	searchRole       string
+	searchIgnoreCase bool
+	searchFixed      bool
+	searchJSON       bool
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search <pattern>",
+	Short: "Search session logs for a regular expression",
+	Long: `Searches session logs line by line for a regular expression (or a literal
+string with --fixed-strings) and prints each match as project/file:line with
+the match and some surrounding text. Local logs are searched by default, as
+they are on disk; --remote searches the uploaded, redacted copies instead,
+streaming and decompressing each object.
+
+--role keeps only matches in user or assistant messages, read from each
+line's JSON.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if searchRole != "" && searchRole != search.RoleUser && searchRole != search.RoleAssistant {
+			return fmt.Errorf("--role must be %s or %s, got %q", search.RoleUser, search.RoleAssistant, searchRole)
+		}
+		re, err := search.Compile(args[0], searchFixed, searchIgnoreCase)
+		if err != nil {
+			return err
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		src, err := logSource(cmd.Context(), cfg, !searchSource.remote, false)
+		if err != nil {
+			return err
+		}
+
+		matches := []search.Match{}
+		result, err := search.Run(cmd.Context(), src, search.Options{Pattern: re, Project: searchSource.project, Role: searchRole}, func(m search.Match) error {
+			if searchJSON {
+				matches = append(matches, m)
+				return nil
+			}
+			role := ""
+			if m.Role != "" {
+				role = "[" + m.Role + "] "
+			}
+			fmt.Printf("%s/%s:%d: %s%s\n", m.Project, m.Path, m.Line, role, m.Text)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, f := range result.Failures {
+			fmt.Fprintf(os.Stderr, "Warning: could not search %s: %v\n", f.Ref, f.Err)
+		}
+
+		if searchJSON {
+			data, err := json.MarshalIndent(matches, "", "  ")
+			if err != nil {
+				return fmt.Errorf("marshaling JSON: %w", err)
+			}
+			fmt.Println(string(data))
+		} else {
+			fmt.Fprintf(output.Human(), "\n%d matches in %d files searched\n", result.Matches, result.Files)
+		}
+
+		if len(result.Failures) > 0 {
+			return fmt.Errorf("%d of %d logs could not be searched", len(result.Failures), len(result.Failures)+result.Files)
+		}
+		return nil
+	},
+}
+
+var (
+	statsSource sourceFlags
+	statsBy     string
+	statsJSON   bool
+)
+
+var statsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Report token usage from session logs",
+	Long: `Reads the token usage recorded in session logs and prints totals per
+project and month, per model, and per day (or week with --by week), with the
+busiest periods. Local logs are read by default; --remote reads the uploaded
+copies listed in the manifest instead.
+
+A response logged on several lines is counted once. Lines without usage are
+ignored and lines that aren't JSON are skipped.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if statsBy != stats.BucketDay && statsBy != stats.BucketWeek {
+			return fmt.Errorf("--by must be %s or %s, got %q", stats.BucketDay, stats.BucketWeek, statsBy)
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		src, err := logSource(cmd.Context(), cfg, !statsSource.remote, false)
+		if err != nil {
+			return err
+		}
+
+		report, err := stats.Run(cmd.Context(), src, stats.Options{Project: statsSource.project, Bucket: statsBy})
+		if err != nil {
+			return err
+		}
+
+		for _, f := range report.Failures {
+			fmt.Fprintf(os.Stderr, "Warning: could not read %s: %v\n", f.Ref, f.Err)
+		}
+
+		if statsJSON {
+			data, err := json.MarshalIndent(report, "", "  ")
+			if err != nil {
+				return fmt.Errorf("marshaling JSON: %w", err)
+			}
+			fmt.Println(string(data))
+		} else {
+			stats.PrintReport(output.Human(), report)
+		}
+
+		if len(report.Failures) > 0 {
+			return fmt.Errorf("%d of %d logs could not be read", len(report.Failures), len(report.Failures)+report.Files)
+		}
+		return nil
+	},
+}
+
+var (
+	downloadProject     string
+	downloadAll         bool
+	downloadOverwrite   bool
+	downloadConcurrency int
+)
+
+var downloadCmd = &cobra.Command{
+	Use:   "download <dest>",
+	Short: "Restore stored logs from S3 to a local directory",
+	Long: `Downloads the stored logs of one project (--project) or all projects (--all)
+into <dest>/<project>/..., decompressing them and restoring their original
+modification times. Objects are listed from the manifest, or from the bucket
+if the manifest can't be read.
+
+Existing files with the expected size are skipped, as are files that are
+larger locally; --overwrite replaces them. Restored logs are the uploaded,
+redacted copies.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if (downloadProject == "") == !downloadAll {
+			return fmt.Errorf("specify exactly one of --project or --all")
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+
+		client, err := config.NewS3Client(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("creating S3 client: %w", err)
+		}
+
+		var targets []fetch.Target
+		m, err := manifest.Load(ctx, client, cfg.S3.Bucket, manifest.ConfigKey(cfg), cfg.S3.OperationTimeout)
+		if err == nil && len(m.Files) > 0 {
+			targets = fetch.Targets(cfg, m, downloadProject)
+		} else {
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not load manifest, listing the bucket instead: %v\n", err)
+			}
+			objects, err := uploader.ListRemoteFiles(ctx, client, cfg.S3.Bucket, config.KeyPrefix(cfg), cfg.S3.OperationTimeout)
+			if err != nil {
+				return fmt.Errorf("listing objects: %w", err)
+			}
+			targets = fetch.ListedTargets(cfg, objects, downloadProject)
+		}
+
+		if len(targets) == 0 {
+			if downloadProject != "" {
+				return fmt.Errorf("no stored logs for project %q", downloadProject)
+			}
+			fmt.Fprintln(output.Human(), "No stored logs found.")
+			return nil
+		}
+
+		_, err = fetch.Download(ctx, client, cfg.S3.Bucket, targets, fetch.DownloadOptions{
+			Dest:        args[0],
+			Overwrite:   downloadOverwrite,
+			Concurrency: downloadConcurrency,
+			Out:         output.Human(),
+		})
+		if errors.Is(err, context.Canceled) {
+			exitFunc(130)
+			return nil
+		}
+		return err
+	},
+}
+
+var (
+	pruneOlderThan string
+	pruneTrash     bool
+	pruneYes       bool
+)
+
+var pruneLocalCmd = &cobra.Command{
+	Use:   "prune-local",
+	Short: "Delete old local logs that are already uploaded",
+	Long: `Lists local logs last modified before --older-than whose manifest entry
+matches their current size and modification time, i.e. logs the archive
+already holds in their current form. Files pending upload are never touched.
+--older-than takes a duration (30d), a date (midnight local time), or an
+RFC3339 timestamp.
+
+Without --yes nothing is deleted; the listing shows what would be removed and
+how much space it would free. --trash moves files to the trash instead of
+deleting them.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cutoff, err := units.ParseTime(pruneOlderThan, time.Now(), time.Local)
+		if err != nil {
+			return fmt.Errorf("--older-than: %w", err)
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		ctx := cmd.Context()
+		client, err := config.NewS3Client(ctx, cfg)
+		if err != nil {
+			return fmt.Errorf("creating S3 client: %w", err)
+		}
+
+		files, err := uploader.New(cfg, client, false, false).Prunable(ctx, cutoff)
+		if err != nil {
+			return err
+		}
+		if len(files) == 0 {
+			fmt.Fprintf(output.Human(), "No uploaded logs older than %s to prune.\n", pruneOlderThan)
+			return nil
+		}
+
+		var total uploader.PruneTotal
+		if !pruneYes {
+			for _, f := range files {
+				fmt.Fprintf(output.Human(), "  %s (%s, modified %s)\n", f.LocalPath, output.FormatSize(f.Size), f.ModTime.Local().Format(time.DateOnly))
+				total.Add(f)
+			}
+			fmt.Fprintf(output.Human(), "\nWould remove %s. Run with --yes to remove them.\n", total)
+			return nil
+		}
+
+		failed := 0
+		for _, f := range files {
+			// A session resumed since the check is no longer backed up as is
+			if ok, err := uploader.Unchanged(f); err != nil || !ok {
+				fmt.Fprintf(output.Human(), "Skipping %s (changed since it was checked)\n", f.LocalPath)
+				continue
+			}
+			if pruneTrash {
+				err = trash.Move(f.LocalPath)
+			} else {
+				err = os.Remove(f.LocalPath)
+			}
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				failed++
+				continue
+			}
+			total.Add(f)
+		}
+
+		if pruneTrash {
+			fmt.Fprintf(output.Human(), "Moved %d of %d files to the trash (%s); empty it to reclaim the space.\n", total.Files, len(files), output.FormatSize(total.Bytes))
+		} else {
+			fmt.Fprintf(output.Human(), "Deleted %d of %d files, reclaimed %s.\n", total.Files, len(files), output.FormatSize(total.Bytes))
+		}
+		if failed > 0 {
+			return fmt.Errorf("%d files could not be removed", failed)
+		}
+		return nil
+	},
+}
+
+var (
+	diffJSON       bool
+	diffLocalOnly  bool
+	diffRemoteOnly bool
+	diffObjects    bool
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff [project]",
+	Short: "Show which files are uploaded, changed, or missing",
+	Long: `Compares local files with the manifest and prints the state of each file:
+local-only (never uploaded), remote-only (not on this machine), modified
+(changed since the last upload), or in-sync. Files that are local-only or
+modified are the ones the next upload sends.
+
+With --objects, the bucket is listed as well, and files are also reported as
+missing (in the manifest, but the object is gone), unrecorded (an object the
+manifest doesn't know), or size-mismatch (the object's size differs from the
+one recorded at upload).`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if diffLocalOnly && diffRemoteOnly {
+			return fmt.Errorf("--local-only and --remote-only are mutually exclusive")
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		client, err := config.NewS3Client(cmd.Context(), cfg)
+		if err != nil {
+			return fmt.Errorf("creating S3 client: %w", err)
+		}
+
+		project := ""
+		if len(args) == 1 {
+			project = args[0]
+		}
+		u := uploader.New(cfg, client, false, false)
+		diff := u.Diff
+		if diffObjects {
+			diff = u.DiffObjects
+		}
+		states, err := diff(cmd.Context(), project)
+		if err != nil {
+			return err
+		}
+
+		filtered := []uploader.FileState{}
+		counts := make(map[string]int)
+		for _, s := range states {
+			if (diffLocalOnly && s.State != uploader.StateLocalOnly) || (diffRemoteOnly && s.State != uploader.StateRemoteOnly) {
+				continue
+			}
+			filtered = append(filtered, s)
+			counts[s.State]++
+		}
+
+		if diffJSON {
+			data, err := json.MarshalIndent(filtered, "", "  ")
+			if err != nil {
+				return fmt.Errorf("marshaling JSON: %w", err)
+			}
+			fmt.Println(string(data))
+			return nil
+		}
+
+		for _, s := range filtered {
+			fmt.Fprintf(output.Human(), "%-13s %s/%s\n", s.State, s.Project, s.Path)
+		}
+		fmt.Fprintf(output.Human(), "\n%d local-only, %d remote-only, %d modified, %d in sync",
+			counts[uploader.StateLocalOnly], counts[uploader.StateRemoteOnly], counts[uploader.StateModified], counts[uploader.StateInSync])
+		if diffObjects {
+			fmt.Fprintf(output.Human(), "; %d missing, %d unrecorded, %d size-mismatch",
+				counts[uploader.StateMissing], counts[uploader.StateUnrecorded], counts[uploader.StateSizeMismatch])
+		}
+		fmt.Fprintln(output.Human())
+		return nil
+	},
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Inspect the configuration file",
+}
+
+var configSources bool
+
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Validate the config and print the resolved settings",
+	Long: `Loads and validates the config file, then prints every setting after
+defaults are applied (credentials masked) followed by any warnings. Unknown
+keys are reported as warnings, or as errors with --strict.
+
+An invalid config is not printed. Instead every problem found is listed:
+unknown keys with their line numbers (with --strict), malformed bucket names,
+regions, endpoints, and prefixes, and the first other validation error.
+Nothing is sent over the network.
+
+With --sources, each setting is printed on one line with where its value came
+from: the config file, an environment variable, or the default.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		problems := config.Check(configPath, strictConfig)
+		// A missing config is left to loadConfig, which may create one
+		if len(problems) > 0 && !errors.Is(problems[0], config.ErrConfigNotFound) {
+			printConfigProblems(output.Human(), configPath, problems)
+			return &configError{fmt.Errorf("%s is invalid", configPath)}
+		}
+
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		var resolved []byte
+		if configSources {
+			resolved, err = formatSources(cfg)
+		} else {
+			resolved, err = yaml.Marshal(config.Masked(cfg))
+		}
+		if err != nil {
+			return fmt.Errorf("formatting config: %w", err)
+		}
+
+		warnings := config.Warnings(cfg)
+		if !strictConfig {
+			if _, err := config.LoadStrict(configPath); err != nil {
+				warnings = append(warnings, fmt.Sprintf("%v (ignored; use --strict to reject)", errors.Unwrap(err)))
+			}
+		}
+
+		fmt.Fprintf(output.Human(), "Config: %s\n\n%s", configPath, resolved)
+		if len(warnings) > 0 {
+			fmt.Fprintln(output.Human(), "\nWarnings:")
+			for _, w := range warnings {
+				fmt.Fprintf(output.Human(), "  - %s\n", w)
+			}
+		}
+		fmt.Fprintln(output.Human(), "\nConfig is valid.")
+		return nil
+	},
+}
+
+// printConfigProblems lists the problems config.Check found in the config
+// at path.
+func printConfigProblems(w io.Writer, path string, problems []error) {
+	fmt.Fprintf(w, "Config: %s\n\nProblems:\n", path)
+	for _, p := range problems {
+		fmt.Fprintf(w, "  - %v\n", p)
+	}
+}
+
+// formatSources lists each setting of cfg with the source of its value,
+// aligned in columns.
+func formatSources(cfg *types.Config) ([]byte, error) {
+	settings, err := config.Sources(cfg, configPath)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	for _, s := range settings {
+		fmt.Fprintf(tw, "%s\t%s\t(%s)\n", s.Path, s.Value, s.Source)
+	}
+	if err := tw.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var (
+	statusShort bool
+	statusJSON  bool
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show this machine's identity, destination, and last run",
+	Long: `Prints the machine ID recorded in object keys, manifests, and receipts,
+where it came from, and where uploads go. Makes no S3 requests.
+
+With --short or --json, instead compares local files against the manifest
+and prints one line (or object) counting projects, files pending upload, and
+the last upload time. The exit code is 0 when everything is uploaded and 1
+when uploads are pending, for use in shell prompts and cron jobs.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := loadConfig()
+		if err != nil {
+			return err
+		}
+
+		if statusShort || statusJSON {
+			return printSyncSummary(cmd.Context(), cfg)
+		}
+
+		source := "generated"
+		if cfg.Identity.Source == identity.SourceConfig {
+			source = "local.machine_id"
+		}
+		fmt.Fprintf(output.Human(), "Machine ID:  %s (%s)\n", cfg.Identity.ID, source)
+		fmt.Fprintf(output.Human(), "Hostname:    %s\n", cfg.Identity.Hostname)
+		fmt.Fprintf(output.Human(), "State file:  %s\n", cfg.Identity.StatePath)
+		fmt.Fprintf(output.Human(), "Config:      %s\n", configPath)
+		fmt.Fprintf(output.Human(), "Destination: s3://%s/%s\n", cfg.S3.Bucket, config.KeyPrefix(cfg))
+		fmt.Fprintf(output.Human(), "Projects:    %s\n", projectsRootStatus(cfg))
+
+		r, err := runs.Load(runsDir(), "latest")
+		if err != nil {
+			fmt.Fprintln(output.Human(), "Last run:    none recorded")
+			return nil
+		}
+		status := "ok"
+		if r.Error != "" {
+			status = "error"
+		}
+		fmt.Fprintf(output.Human(), "Last run:    %s %s, %d uploaded, %d skipped (%s)\n",
+			r.ID, r.Command, r.Uploaded, r.Skipped, status)
+		return nil
+	},
+}
+
+// printSyncSummary prints the one-line (or JSON) sync summary for status
+// and exits with exitOutOfSync when uploads are pending.
+func printSyncSummary(ctx context.Context, cfg *types.Config) error {
+	client, err := config.NewS3Client(ctx, cfg)
+	if err != nil {
+		return fmt.Errorf("creating S3 client: %w", err)
+	}
+	summary, err := uploader.New(cfg, client, false, false).Summarize(ctx)
+	if err != nil {
+		return err
+	}
+
+	if statusJSON {
+		data, err := json.MarshalIndent(summary, "", "  ")
+		if err != nil {
+			return fmt.Errorf("marshaling JSON: %w", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		fmt.Fprintln(output.Human(), summary)
+	}
+	if summary.PendingFiles > 0 {
+		exitFunc(exitOutOfSync)
+	}
+	return nil
+}
+
+// projectsRootStatus describes the projects root for status, flagging one
+// that looks unmounted.
+func projectsRootStatus(cfg *types.Config) string {
+	root := cfg.Local.ProjectsRoot
+	projects, err := discover.DiscoverLocal(cfg.Local)
+	if err != nil {
+		return fmt.Sprintf("unavailable: %v", err)
+	}
+	if len(projects) == 0 {
+		if last := identity.LastProjects(cfg.Identity.StatePath); last > 0 {
+			return fmt.Sprintf("%s (empty, but the last upload found %d; is the volume mounted?)", root, last)
+		}
+	}
+	return fmt.Sprintf("%s (%d)", root, len(projects))
+}
+
+var runsCmd = &cobra.Command{
+	Use:   "runs",
+	Short: "Inspect receipts of past upload runs",
+	Long: `Each upload run writes a receipt next to the config file recording the
+effective options, an options fingerprint, environment facts, and the outcome.`,
+}
+
+var runsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recorded runs",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := runsDir()
+		ids, err := runs.List(dir)
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			fmt.Fprintln(output.Human(), "No runs recorded.")
+			return nil
+		}
+
+		for _, id := range ids {
+			r, err := runs.Load(dir, id)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+				continue
+			}
+			status := "ok"
+			if r.Error != "" {
+				status = "error"
+			}
+			fmt.Fprintf(output.Human(), "%s  %-7s %s  %d uploaded, %d skipped  %s\n",
+				r.ID, r.Command, r.OptionsFingerprint, r.Uploaded, r.Skipped, status)
+		}
+		return nil
+	},
+}
+
+var runsDiffCmd = &cobra.Command{
+	Use:   "diff <a> <b>",
+	Short: "Show effective options that differ between two runs",
+	Long: `Compares the effective options and environment facts of two runs.
+Runs are identified by ID, a unique ID prefix, or "latest".`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := runsDir()
+		a, err := runs.Load(dir, args[0])
+		if err != nil {
+			return err
+		}
+		b, err := runs.Load(dir, args[1])
+		if err != nil {
+			return err
 		}
 
-		// Merge local and remote projects
-		merged := mergeProjects(localProjects, remoteProjects)
+		fmt.Fprintf(output.Human(), "a: %s (fingerprint %s)\n", a.ID, a.OptionsFingerprint)
+		fmt.Fprintf(output.Human(), "b: %s (fingerprint %s)\n", b.ID, b.OptionsFingerprint)
 
-		if jsonOutput {
-			if err := output.PrintJSON(merged, cfg); err != nil {
-				return fmt.Errorf("printing JSON output: %w", err)
-			}
-		} else {
-			output.PrintProjects(merged)
+		changes := runs.Diff(a, b)
+		if len(changes) == 0 {
+			fmt.Fprintln(output.Human(), "\nNo differences in effective options.")
+			return nil
+		}
+
+		fmt.Fprintln(output.Human())
+		for _, c := range changes {
+			fmt.Fprintf(output.Human(), "  %s: %q → %q\n", c.Key, c.A, c.B)
 		}
 		return nil
 	},
 }
 
-var uploadCmd = &cobra.Command{
-	Use:   "upload",
-	Short: "Upload local JSONL logs to remote storage",
-	Long: `Discovers all .jsonl files in local Claude Code projects and uploads them
-to S3-compatible storage. Safe to run repeatedly from multiple machines.`,
+var (
+	trainDictSize        string
+	trainDictSampleBytes string
+)
+
+var compressionCmd = &cobra.Command{
+	Use:   "compression",
+	Short: "Manage how uploads are compressed",
+}
+
+var compressionTrainDictCmd = &cobra.Command{
+	Use:   "train-dict",
+	Short: "Train a zstd dictionary from local logs and store it in the bucket",
+	Long: `Reads the start of every local log, redacts it as an upload would, and
+trains a zstd dictionary on the result. The dictionary is stored next to the
+manifest as .cclogs-dict, and uploads with compress: zstd use it from then on.
+Small logs compress much better with a dictionary.
+
+Each dictionary is also kept as .cclogs-dict.<id>, and the manifest entry of
+every object compressed with one records its ID, so objects stay readable
+after the dictionary is retrained. Don't delete those objects.`,
+	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
+		size, err := types.ParseByteSize(trainDictSize)
+		if err != nil {
+			return fmt.Errorf("--size: %w", err)
+		}
+		sampleBytes, err := types.ParseByteSize(trainDictSampleBytes)
+		if err != nil {
+			return fmt.Errorf("--sample-bytes: %w", err)
+		}
+
 		cfg, err := loadConfig()
 		if err != nil {
 			return err
 		}
 
 		ctx := cmd.Context()
-
-		// Create S3 client (nil for dry-run)
-		var client *s3.Client
-		if !dryRun {
-			client, err = config.NewS3Client(ctx, cfg)
-			if err != nil {
-				return fmt.Errorf("creating S3 client: %w", err)
-			}
+		src, err := logSource(ctx, cfg, true, false)
+		if err != nil {
+			return err
 		}
-
-		// Create uploader
-		u := uploader.New(cfg, client, noRedact, debug)
-
-		// Discover files
-		files, err := u.DiscoverFiles(ctx)
+		logs, err := src.Logs(ctx, "")
 		if err != nil {
-			return fmt.Errorf("discovering files: %w", err)
+			return err
 		}
 
-		// In dry-run mode, process files with redaction but don't upload
-		if dryRun {
-			_, err = u.DryRunProcess(ctx, files)
+		var samples [][]byte
+		var sampled int64
+		for _, l := range logs {
+			sample, err := dictSample(ctx, src, l.Ref(), int64(sampleBytes))
 			if err != nil {
-				return fmt.Errorf("processing files: %w", err)
+				fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", l.Ref(), err)
+				continue
 			}
-			return nil
+			samples = append(samples, sample)
+			sampled += int64(len(sample))
 		}
 
-		// Perform upload
-		_, err = u.Upload(ctx, files)
+		dict, err := codec.TrainDictionary(samples, int(size))
 		if err != nil {
-			return fmt.Errorf("uploading files: %w", err)
+			return err
 		}
 
-		return nil
-	},
-}
-
-var doctorCmd = &cobra.Command{
-	Use:   "doctor",
-	Short: "Validate configuration and connectivity",
-	Long: `Checks that the configuration is valid, local projects root exists,
-and remote S3 connectivity works.`,
-	RunE: func(cmd *cobra.Command, args []string) error {
-		cfg, err := loadConfig()
+		client, err := config.NewS3Client(ctx, cfg)
 		if err != nil {
+			return fmt.Errorf("creating S3 client: %w", err)
+		}
+		if err := manifest.SaveDict(ctx, client, cfg, dict, cfg.S3.OperationTimeout); err != nil {
 			return err
 		}
 
-		allPassed := doctor.RunChecks(cfg, configPath, false)
-		if !allPassed {
-			exitFunc(1)
+		fmt.Fprintf(output.Human(), "Trained dictionary %d (%s) from %d logs (%s)\n",
+			dict.ID(), output.FormatSize(int64(len(dict))), len(samples), output.FormatSize(sampled))
+		fmt.Fprintf(output.Human(), "Stored as s3://%s/%s\n", cfg.S3.Bucket, manifest.DictKey(cfg))
+		if c, _ := codec.Parse(cfg.Upload.Compress); c != codec.Zstd {
+			fmt.Fprintln(output.Human(), "Set upload.compress to zstd to compress uploads with it.")
 		}
 		return nil
 	},
 }
 
+// dictSample returns up to limit bytes of the log at ref, redacted and cut
+// at the last complete line.
+func dictSample(ctx context.Context, src fetch.Source, ref string, limit int64) ([]byte, error) {
+	body, err := src.Open(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = body.Close() }()
+
+	head, err := io.ReadAll(io.LimitReader(body, limit))
+	if err != nil {
+		return nil, err
+	}
+	if i := bytes.LastIndexByte(head, '\n'); i >= 0 {
+		head = head[:i+1]
+	}
+	return io.ReadAll(redactor.StreamRedact(bytes.NewReader(head)))
+}
+
 func init() {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to get home directory: %v\n", err)
 		homeDir = "~"
 	}
-	defaultConfigPath = filepath.Join(homeDir, ".cclogs", "config.yaml")
+	defaultConfigPath = config.DefaultPath()
+	defaultCclsConfigPath = filepath.Join(homeDir, ".ccls", "config.yaml")
 
 	rootCmd.PersistentFlags().StringVar(&configPath, "config", defaultConfigPath, "path to config file")
+	rootCmd.PersistentFlags().BoolVar(&strictConfig, "strict", false, "reject unknown keys in the config file")
+	rootCmd.PersistentFlags().StringVar(&targetName, "target", "", "use the named destination from the config's targets section (default: the top-level s3 and auth sections)")
+	rootCmd.PersistentFlags().BoolVar(&noManifestCache, "no-cache", false, "don't use or update the local manifest cache")
 
 	listCmd.Flags().BoolVar(&jsonOutput, "json", false, "output in JSON format")
+	listCmd.Flags().BoolVar(&listFiles, "files", false, "with --json, list each remote project's logs and the redaction patterns that matched in them")
+	listCmd.Flags().BoolVarP(&listVerbose, "verbose", "v", false, "show the stored size of each project")
+	listCmd.Flags().IntVar(&listLimit, "limit", 0, "show at most this many projects (0 for all)")
+	listCmd.Flags().IntVar(&listOffset, "offset", 0, "skip this many projects, in name order")
+	listCmd.Flags().StringVar(&listSource, "source", listSourceAuto, "where remote counts come from: manifest, listing, or auto")
+	listCmd.Flags().BoolVar(&listNoPager, "no-pager", false, "don't page long output through $PAGER")
+	listCmd.Flags().BoolVar(&listRedactionPreview, "redaction-preview", false, "show what redaction would find in each local project")
+	listCmd.Flags().IntVar(&listSampleLines, "sample-lines", uploader.DefaultPreviewLines, "with --redaction-preview, lines read from each file (0 for all)")
+	listCmd.Flags().BoolVar(&listByHost, "by-host", false, "with key_layout by_host, show each machine's projects separately")
 	uploadCmd.Flags().BoolVar(&dryRun, "dry-run", false, "process files with redaction but don't upload (shows stats)")
 	uploadCmd.Flags().BoolVar(&noRedact, "no-redact", false, "disable PII/secrets redaction (not recommended)")
 	uploadCmd.Flags().BoolVar(&debug, "debug", false, "show before/after for each redaction match")
+	uploadCmd.Flags().StringVar(&uploadExcludeContent, "exclude-content-pattern", "", "skip files whose first 64KiB match this regular expression")
+	uploadCmd.Flags().StringVar(&uploadSince, "since", "", "only upload files modified since this time (e.g. 30d, 2024-03-10)")
+	uploadCmd.Flags().StringVar(&uploadOrder, "order", uploader.OrderOldest, "upload order: oldest, newest, or name")
+	uploadCmd.Flags().IntVar(&uploadLimit, "limit", 0, "upload at most N files this run (0 = no limit)")
+	uploadCmd.Flags().DurationVar(&uploadEvery, "every", 0, "keep running and upload again this long after each run ends (e.g. 1h)")
+	uploadCmd.Flags().BoolVar(&uploadWaitLock, "wait-lock", false, "wait for another running upload to finish instead of exiting")
+	uploadCmd.Flags().BoolVar(&uploadIgnoreLock, "ignore-lock", false, "upload even if another machine holds the remote lock (upload.remote_lock)")
+	uploadCmd.Flags().BoolVar(&estimateCompress, "estimate-compression", false, "with --dry-run, project stored sizes after compression (automatic when upload.compress is set)")
+	uploadCmd.Flags().BoolVar(&dryRunSummary, "summary", false, "with --dry-run, print per-project totals and estimated durations instead of a line per file")
+	uploadCmd.Flags().BoolVar(&uploadJSON, "json", false, "with --dry-run, print the per-project totals as JSON on stdout")
+	uploadCmd.Flags().IntVar(&uploadThreads, "threads", 0, "files hashed at once during discovery (default: discovery.concurrency)")
+	uploadCmd.Flags().StringVar(&uploadMax, "max-bytes", "", "stop queueing uploads after this much data (e.g. 500MiB)")
+	uploadCmd.Flags().BoolVar(&preflight, "preflight", true, "check config, projects root, and bucket access before uploading")
+	uploadCmd.Flags().BoolVar(&noPreflight, "no-preflight", false, "skip preflight checks")
+	uploadCmd.Flags().BoolVar(&failFast, "fail-fast", false, "stop at the first file that fails to upload")
+	uploadCmd.Flags().BoolVar(&failIfPending, "fail-if-pending", false, "with --dry-run, exit with status 6 if any file would be uploaded")
+	uploadCmd.Flags().BoolVar(&noManifest, "no-manifest", false, "skip the manifest and check each file with a HEAD request instead")
+	uploadCmd.Flags().BoolVar(&uploadAllTargets, "all-targets", false, "upload to every destination in the config, the default one first")
+	uploadCmd.Flags().BoolVar(&noResume, "no-resume", false, "upload files again that an interrupted run finished but did not record in the manifest")
+	uploadCmd.Flags().BoolVar(&datePartition, "date-partition", false, "store new and changed files under <prefix>/YYYY/MM/DD/ (today's UTC date)")
+	uploadCmd.Flags().StringVar(&failSeverity, "fail-on-severity", "", "exit with status 7 if redaction matched patterns of this severity or higher (high, medium, low)")
+	uploadCmd.Flags().BoolVar(&uploadYes, "yes", false, "upload without asking when the bucket has objects but no manifest")
+	uploadCmd.Flags().BoolVar(&uploadJoinArchive, "join-archive", false, "let this machine upload to an archive other machines already share")
+	uploadCmd.Flags().BoolVar(&allowShrink, "allow-shrink", false, "upload files that got smaller since their last upload, overwriting the remote copy")
+
+	verifyCmd.Flags().StringVar(&verifySample, "sample", "", "verify a sample of N objects or a percentage (e.g. 5%), chosen daily")
+	verifyCmd.Flags().StringVar(&verifyMaxBytes, "max-bytes", "", "with --deep, stop sampling before downloading more than this (e.g. 1GiB)")
+	verifyCmd.Flags().StringVar(&verifyProject, "project", "", "only verify objects in this project")
+	verifyCmd.Flags().BoolVar(&verifyDeep, "deep", false, "download objects and re-hash their content")
+
+	catSource.register(catCmd, false)
+	catCmd.Flags().BoolVar(&catRaw, "raw", false, "with --remote, print stored bytes without decompressing")
+
+	statsSource.register(statsCmd, true)
+	statsCmd.Flags().StringVar(&statsBy, "by", stats.BucketDay, "time series bucket (day or week)")
+	statsCmd.Flags().BoolVar(&statsJSON, "json", false, "output the report in JSON format")
+
+	exportSource.register(exportCmd, false)
+	exportCmd.Flags().StringVar(&exportFormat, "format", export.FormatMarkdown, "transcript format (markdown or html)")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "write the transcript to this file instead of stdout")
+	exportCmd.Flags().BoolVar(&exportNoRedact, "no-redact", false, "don't redact the local log (the transcript may contain secrets)")
+	exportCmd.Flags().BoolVar(&exportSplit, "split", false, "write one transcript per session in the log (needs --output)")
+
+	searchSource.register(searchCmd, true)
+	searchCmd.Flags().StringVar(&searchRole, "role", "", "only match lines from this role (user or assistant)")
+	searchCmd.Flags().BoolVarP(&searchIgnoreCase, "ignore-case", "i", false, "match case-insensitively")
+	searchCmd.Flags().BoolVarP(&searchFixed, "fixed-strings", "F", false, "treat the pattern as a literal string")
+	searchCmd.Flags().BoolVar(&searchJSON, "json", false, "output matches in JSON format")
+
+	downloadCmd.Flags().StringVar(&downloadProject, "project", "", "download this project's logs")
+	downloadCmd.Flags().BoolVar(&downloadAll, "all", false, "download the logs of every project")
+	downloadCmd.Flags().BoolVar(&downloadOverwrite, "overwrite", false, "replace existing local files")
+	downloadCmd.Flags().IntVar(&downloadConcurrency, "concurrency", fetch.DefaultConcurrency, "objects downloaded at once")
+
+	doctorCmd.Flags().BoolVar(&doctorCapabilities, "capabilities", false, "probe which optional S3 features the endpoint supports")
+	watchCmd.Flags().DurationVar(&watchSettle, "settle", 30*time.Second, "upload a changed file once it has been quiet this long")
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 5*time.Second, "how often to check the projects root for changes")
+	configValidateCmd.Flags().BoolVar(&configSources, "sources", false, "print each setting with where its value came from")
+	statusCmd.Flags().BoolVar(&statusShort, "short", false, "print a one-line sync summary (exit 1 if uploads are pending)")
+	statusCmd.Flags().BoolVar(&statusJSON, "json", false, "print the sync summary in JSON format (exit 1 if uploads are pending)")
+	diffCmd.Flags().BoolVar(&diffJSON, "json", false, "output in JSON format")
+	diffCmd.Flags().BoolVar(&diffLocalOnly, "local-only", false, "only show files that were never uploaded")
+	diffCmd.Flags().BoolVar(&diffRemoteOnly, "remote-only", false, "only show files missing on this machine")
+	diffCmd.Flags().BoolVar(&diffObjects, "objects", false, "also list the bucket to find missing, unrecorded, and resized objects")
+
+	pruneLocalCmd.Flags().StringVar(&pruneOlderThan, "older-than", "30d", "only prune logs last modified before this time (e.g. 90d, 12w, 2024-03-10)")
+	pruneLocalCmd.Flags().BoolVar(&pruneTrash, "trash", false, "move files to the trash instead of deleting them")
+	pruneLocalCmd.Flags().BoolVar(&pruneYes, "yes", false, "remove the files instead of only listing them")
+
+	manifestShowCmd.Flags().BoolVar(&manifestShowJSON, "json", false, "output the manifest in JSON format")
+	manifestRebuildCmd.Flags().BoolVar(&manifestYes, "yes", false, "save the rebuilt manifest without asking")
+	manifestFsckCmd.Flags().BoolVar(&manifestFsckRemote, "remote", false, "also check that each entry's object exists")
+	manifestRestoreCmd.Flags().StringVar(&manifestRestoreRef, "from", "", "backup to restore (name, key, or timestamp)")
+	manifestRestoreCmd.Flags().BoolVar(&manifestYes, "yes", false, "replace the manifest without asking")
+	manifestFindCmd.Flags().StringSliceVar(&manifestFindTags, "tag", nil, "redaction pattern to look for (repeatable, or comma-separated)")
+	manifestFindCmd.Flags().BoolVar(&manifestFindJSON, "json", false, "output the matching entries in JSON format")
+	manifestFsckCmd.Flags().BoolVar(&manifestFsckRepair, "repair", false, "drop entries whose objects are missing (implies --remote)")
+
+	compressionTrainDictCmd.Flags().StringVar(&trainDictSize, "size", "64KiB", "largest dictionary to train")
+	compressionTrainDictCmd.Flags().StringVar(&trainDictSampleBytes, "sample-bytes", "128KiB", "bytes read from the start of each log")
+
+	migrateCmd.Flags().StringVar(&cclsConfigPath, "from", defaultCclsConfigPath, "path to the ccls config file")
 
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(uploadCmd)
 	rootCmd.AddCommand(doctorCmd)
+	rootCmd.AddCommand(verifyCmd)
+	rootCmd.AddCommand(selftestCmd)
+	rootCmd.AddCommand(migrateCmd)
+	rootCmd.AddCommand(catCmd)
+	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(exportCmd)
+	rootCmd.AddCommand(statsCmd)
+	rootCmd.AddCommand(downloadCmd)
+	rootCmd.AddCommand(statusCmd)
+	rootCmd.AddCommand(watchCmd)
+	rootCmd.AddCommand(diffCmd)
+	rootCmd.AddCommand(pruneLocalCmd)
+
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+
+	manifestCmd.AddCommand(manifestShowCmd)
+	manifestCmd.AddCommand(manifestRebuildCmd)
+	manifestCmd.AddCommand(manifestFsckCmd)
+	manifestCmd.AddCommand(manifestRestoreCmd)
+	manifestCmd.AddCommand(manifestFindCmd)
+	rootCmd.AddCommand(manifestCmd)
+
+	runsCmd.AddCommand(runsListCmd)
+	runsCmd.AddCommand(runsDiffCmd)
+	rootCmd.AddCommand(runsCmd)
+	compressionCmd.AddCommand(compressionTrainDictCmd)
+	rootCmd.AddCommand(compressionCmd)
 }
 
 var exitFunc = os.Exit
 
-func loadConfig() (*types.Config, error) {
-	cfg, err := config.Load(configPath)
+// exitOutOfSync is the exit code of status --short/--json when uploads are
+// pending.
+const exitOutOfSync = 1
+
+// exitConfig is the exit code when the config is missing or invalid.
+const exitConfig = 2
+
+// exitConnectivity is the exit code when S3 could not be reached or refused
+// the credentials.
+const exitConnectivity = 3
+
+// exitPartialFailure is the exit code when an upload finished but some files
+// failed, distinguishing it from runs that failed outright.
+const exitPartialFailure = 4
+
+// exitChecksFailed is the exit code when doctor or selftest checks fail,
+// and when upload preflight fails on the projects root. Preflight failures
+// of the config or the bucket exit with exitConfig or exitConnectivity.
+const exitChecksFailed = 5
+
+// exitPending is the exit code of upload --dry-run --fail-if-pending when
+// some files are not backed up yet.
+const exitPending = 6
+
+// exitSecretsFound is the exit code of upload --fail-on-severity when
+// redaction matched patterns at or above the given severity.
+const exitSecretsFound = 7
+
+// partialExitCode returns the exit code of an upload in which some files
+// failed: exitConnectivity if every file failed because S3 could not be
+// reached or refused the credentials, otherwise exitPartialFailure.
+func partialExitCode(result *uploader.UploadResult) int {
+	if result.Uploaded > 0 {
+		return exitPartialFailure
+	}
+	for _, f := range result.Failures {
+		if exitCode(f.Err) != exitConnectivity {
+			return exitPartialFailure
+		}
+	}
+	return exitConnectivity
+}
+
+// configError marks an error loading or validating the config.
+type configError struct {
+	err error
+}
+
+func (e *configError) Error() string { return e.err.Error() }
+func (e *configError) Unwrap() error { return e.err }
+
+// errPreflightFailed matches errors for failed upload preflight checks.
+var errPreflightFailed = errors.New("preflight failed")
+
+// preflightError is returned when upload preflight checks fail. Stage is
+// the group of the first failing check: "config", "local", or "remote".
+type preflightError struct {
+	Stage   string
+	Message string
+}
+
+func (e *preflightError) Error() string { return errPreflightFailed.Error() + ": " + e.Message }
+func (e *preflightError) Unwrap() error { return errPreflightFailed }
+
+// exitCode returns the exit code for an error a command returned: see the
+// list in the root command's help. Unrecognized errors exit with 1.
+func exitCode(err error) int {
+	var cfgErr *configError
+	var sigErr *s3errors.SignatureMismatchError
+	var netErr net.Error
+	var pfErr *preflightError
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &cfgErr), errors.Is(err, config.ErrConfigNotFound), errors.Is(err, config.ErrBucketNotConfigured):
+		return exitConfig
+	case errors.As(err, &pfErr) && pfErr.Stage == "config":
+		return exitConfig
+	case errors.As(err, &pfErr) && pfErr.Stage == "remote":
+		return exitConnectivity
+	case errors.Is(err, errPreflightFailed):
+		return exitChecksFailed
+	case errors.Is(err, uploader.ErrPartialFailure):
+		return exitPartialFailure
+	case errors.Is(err, s3errors.ErrAccessDenied), s3errors.IsSSOExpired(err), errors.As(s3errors.Classify(err), &sigErr), errors.As(err, &netErr):
+		return exitConnectivity
+	default:
+		return 1
+	}
+}
+
+func loadConfig() (cfg *types.Config, err error) {
+	defer func() {
+		if err != nil {
+			err = &configError{err}
+		}
+	}()
+	// CCLOGS_LANG applies before the config loads, so the welcome message
+	// for a new config is already translated
+	setLanguage(os.Getenv(config.EnvName("lang")))
+
+	load := config.Load
+	if strictConfig {
+		load = config.LoadStrict
+	}
+	cfg, err = load(configPath)
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			isDefaultPath := configPath == defaultConfigPath
+		if errors.Is(err, config.ErrConfigNotFound) {
+			// A path from CCLOGS_CONFIG is one the user chose, like --config
+			isDefaultPath := configPath == defaultConfigPath && os.Getenv(config.PathEnv) == ""
 			if isDefaultPath {
 				if err := config.CreateStarterConfig(configPath); err != nil {
 					return nil, fmt.Errorf("creating starter config: %w", err)
@@ -200,32 +2795,413 @@ func loadConfig() (*types.Config, error) {
 				printWelcomeMessage(configPath)
 				exitFunc(0)
 			}
-			return nil, fmt.Errorf("config file not found: %s", configPath)
+			return nil, fmt.Errorf("%w: %s", config.ErrConfigNotFound, configPath)
+		}
+		if errors.Is(err, config.ErrBucketNotConfigured) {
+			return nil, fmt.Errorf("loading config from %s: %w (set the bucket to upload to, then run 'cclogs doctor')", configPath, err)
 		}
 		return nil, fmt.Errorf("loading config from %s: %w", configPath, err)
 	}
+	redactor.SetEnvKeywords(cfg.Redact.EnvKeywords)
+	if err := redactor.SetDisabledPatterns(cfg.Redact.Disable); err != nil {
+		return nil, fmt.Errorf("redact.disable: %w", err)
+	}
+	if err := redactor.SetAllowlist(cfg.Redact.Allowlist); err != nil {
+		return nil, fmt.Errorf("redact.allowlist: %w", err)
+	}
+	if err := redactor.SetMode(cfg.Redact.Mode, cfg.Redact.MaskChar, cfg.Redact.MaskKeep); err != nil {
+		return nil, fmt.Errorf("redact.mode: %w", err)
+	}
+	redactor.SetCanonicalJSON(cfg.Redact.CanonicalJSON)
+	redactor.SetSpill(int64(cfg.Redact.SpillThreshold))
+	manifest.SetCacheDir(manifestCacheDir())
+	if targetName != "" {
+		if cfg, err = config.Target(cfg, targetName); err != nil {
+			return nil, fmt.Errorf("--target: %w", err)
+		}
+	}
+	useTarget(cfg)
+	setLanguage(cfg.Lang)
+	warnMachineIDChange(cfg)
 	return cfg, nil
 }
 
+// useTarget adapts the manifest and upload settings to the destination cfg
+// points at. Features doctor --capabilities found missing are not used.
+func useTarget(cfg *types.Config) {
+	caps := capabilities(cfg)
+	history := cfg.S3.ManifestHistory
+	if !caps.Supports(selftest.FeatureCopyObject) {
+		history = 0
+	}
+	manifest.SetHistory(history, os.Stderr)
+	uploader.SetChecksums(caps.Supports(selftest.FeatureChecksums))
+}
+
+// setLanguage selects the message language. An unusable translation is
+// reported and English is kept.
+func setLanguage(lang string) {
+	if err := msg.SetLang(lang, messagesDir()); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: %v; using English\n", err)
+	}
+}
+
+// warnMachineIDChange tells the user when the machine ID differs from the
+// previous run's and it is part of object keys, since uploads now go to a
+// different prefix and the old one is no longer updated.
+func warnMachineIDChange(cfg *types.Config) {
+	prev := cfg.Identity.Previous
+	if prev == "" {
+		return
+	}
+	if cfg.S3.KeyLayout != config.KeyLayoutByHost && !config.TemplateUsesHost(cfg.S3.KeyTemplate) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "Warning: machine ID changed from %q to %q; object keys include it, so uploads now go under a new prefix\n", prev, cfg.Identity.ID)
+	fmt.Fprintf(os.Stderr, "  → Objects under the old prefix are kept but no longer updated; set local.machine_id: %s to keep using it\n", prev)
+}
+
 func printWelcomeMessage(configPath string) {
-	fmt.Println("Welcome to cclogs!")
-	fmt.Println()
-	fmt.Printf("A starter configuration file has been created at:\n")
-	fmt.Printf("  %s\n", configPath)
-	fmt.Println()
-	fmt.Println("Please edit this file and configure:")
-	fmt.Println("  1. s3.bucket - Your S3 bucket name")
-	fmt.Println("  2. s3.region - Your AWS region")
-	fmt.Println("  3. auth.profile - Your AWS profile (or use static credentials)")
-	fmt.Println()
-	fmt.Println("For S3-compatible providers (Backblaze B2, MinIO, etc.):")
-	fmt.Println("  - Set s3.endpoint to your provider's endpoint URL")
-	fmt.Println("  - Set s3.force_path_style: true if required")
-	fmt.Println()
-	fmt.Println("After configuration, run:")
-	fmt.Println("  cclogs doctor   # Validate configuration")
-	fmt.Println("  cclogs list     # List local and remote projects")
-	fmt.Println("  cclogs upload   # Upload local JSONL files")
+	fmt.Fprint(output.Human(), msg.Text("welcome", msg.Args{"Path": configPath}))
+}
+
+// messagesDir returns the directory translations are loaded from, next to the config file.
+func messagesDir() string {
+	return filepath.Join(filepath.Dir(configPath), "messages")
+}
+
+// runsDir returns the directory where run receipts are stored, in the state directory.
+func runsDir() string {
+	return filepath.Join(config.StateDir(configPath), "runs")
+}
+
+// resumeDir returns where upload resume files are kept, in the state directory.
+func resumeDir() string {
+	return filepath.Join(config.StateDir(configPath), uploader.ResumeDirName)
+}
+
+// manifestCacheDir returns where manifests are cached, or "" with --no-cache.
+func manifestCacheDir() string {
+	if noManifestCache {
+		return ""
+	}
+	return filepath.Join(config.CacheDir(configPath), manifest.CacheDirName)
+}
+
+// boundUploadMemory keeps the worst-case upload buffers under the memory
+// ceiling, lowering part_concurrency if needed so a small machine isn't
+// driven out of memory by settings tuned for a large one.
+func boundUploadMemory(cfg *types.Config) error {
+	sysMem, _ := uploader.SystemMemory()
+	limit := uploader.MemoryLimit(cfg, sysMem)
+
+	before := uploader.NewMemoryEnvelope(cfg)
+	after, err := uploader.BoundMemory(cfg, limit)
+	if err != nil {
+		return err
+	}
+	if after.PartConcurrency != before.PartConcurrency {
+		fmt.Fprintf(os.Stderr, "Warning: upload memory envelope %s exceeds the limit of %s; lowering part_concurrency to %d (%s)\n",
+			before, types.ByteSize(limit), after.PartConcurrency, types.ByteSize(after.Total()))
+	}
+	if debug {
+		fmt.Fprintf(os.Stderr, "[DEBUG] memory envelope: %s (limit %s)\n", after, types.ByteSize(limit))
+	}
+	return nil
+}
+
+// printOptions prints the effective options of a run to stderr.
+func printOptions(r *runs.Receipt) {
+	fmt.Fprintf(os.Stderr, "[DEBUG] run %s options fingerprint %s\n", r.ID, r.OptionsFingerprint)
+	for _, m := range []map[string]string{r.Options, r.Env} {
+		keys := make([]string, 0, len(m))
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fmt.Fprintf(os.Stderr, "[DEBUG]   %s=%s\n", k, m[k])
+		}
+	}
+}
+
+// saveReceipt completes a run receipt with its outcome and writes it.
+// Failing to write the receipt only warns; it never fails the run.
+func saveReceipt(r *runs.Receipt, result *uploader.UploadResult, err error) {
+	r.FinishedAt = time.Now().UTC()
+	if result != nil {
+		r.Uploaded = result.Uploaded
+		r.Skipped = result.Skipped
+		r.UploadedBytes = result.UploadedBytes
+		r.Failed = len(result.Failures)
+		r.RedactionAnomalies = result.Anomalies
+		r.CompressionEstimate = result.Compression
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	if saveErr := runs.Save(runsDir(), r); saveErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to save run receipt: %v\n", saveErr)
+	}
+}
+
+// startTelemetry exports traces and metrics if telemetry.otlp_endpoint is
+// set, and returns the function that flushes them. A collector that cannot be
+// set up is reported and the run goes on without telemetry.
+func startTelemetry(cfg *types.Config) func() {
+	stop, err := telemetry.Setup(cfg.Telemetry, version, cfg.Local.MachineID, os.Stderr)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: telemetry disabled: %v\n", err)
+		return func() {}
+	}
+	return stop
+}
+
+// notifyRun posts the outcome of an upload to notify.webhook_url, if
+// configured and the run passes notify.on. Failing to deliver it is only a
+// warning: the upload itself is already done.
+func notifyRun(ctx context.Context, cfg *types.Config, result *uploader.UploadResult, err error) {
+	if cfg.Notify.WebhookURL == "" || !notify.ShouldNotify(cfg.Notify.On, result, err) {
+		return
+	}
+	p := notify.NewPayload(cfg.Identity.ID, cfg.S3.Bucket, cfg.S3.Prefix, result, err)
+	if sendErr := notify.Send(ctx, http.DefaultClient, cfg.Notify.WebhookURL, p); sendErr != nil {
+		fmt.Fprintf(os.Stderr, "Warning: sending upload notification: %v\n", sendErr)
+	}
+}
+
+// compressionConfigured reports whether upload.compress selects a codec.
+func compressionConfigured(cfg *types.Config) bool {
+	c, err := codec.Parse(cfg.Upload.Compress)
+	return err == nil && c != codec.None
+}
+
+// recordProjects remembers how many projects this run found so doctor and
+// status can tell an unmounted projects root from a new, empty one. A run that
+// found nothing warns instead of overwriting the count.
+func recordProjects(cfg *types.Config, files []uploader.FileUpload) {
+	if cfg.Identity.StatePath == "" {
+		return
+	}
+	projects := make(map[string]bool)
+	for _, f := range files {
+		projects[f.ProjectDir] = true
+	}
+	if len(projects) == 0 {
+		if last := identity.LastProjects(cfg.Identity.StatePath); last > 0 {
+			fmt.Fprintf(os.Stderr, "Warning: no files found in %s, but the last upload found %d projects; is the volume holding it mounted?\n",
+				cfg.Local.ProjectsRoot, last)
+		}
+		return
+	}
+	if err := identity.RecordProjects(cfg.Identity.StatePath, len(projects)); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not update state file: %v\n", err)
+	}
+}
+
+// recordThroughput remembers the rate of a completed upload in the state
+// file, for dry runs to estimate durations with.
+func recordThroughput(cfg *types.Config, bytes int64, elapsed time.Duration) {
+	if cfg.Identity.StatePath == "" {
+		return
+	}
+	if err := identity.RecordThroughput(cfg.Identity.StatePath, bytes, elapsed); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not update state file: %v\n", err)
+	}
+}
+
+// secretsFound reports whether --fail-on-severity is set and the run
+// redacted matches at or above it, telling the user on stderr.
+func secretsFound(stats *redactor.Stats) bool {
+	if failSeverity == "" || stats == nil {
+		return false
+	}
+	n := stats.CountAtLeast(failSeverity)
+	if n == 0 {
+		return false
+	}
+	fmt.Fprintf(os.Stderr, "%d redaction matches at or above %s severity\n", n, failSeverity)
+	return true
+}
+
+// stdinIsTerminal reports whether the user can answer a prompt. Replaced in
+// tests.
+var stdinIsTerminal = func() bool {
+	info, err := os.Stdin.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// confirmFirstUpload guards against uploading into the wrong bucket: objects
+// under the prefix without a manifest mean cclogs never uploaded there, so the
+// user is asked before logs are mixed in. A failed check only warns.
+func confirmFirstUpload(ctx context.Context, cfg *types.Config, client *s3.Client) error {
+	prefix := config.KeyPrefix(cfg)
+	count, err := uploader.UnmanagedObjects(ctx, client, cfg.S3.Bucket, prefix, manifest.ConfigKey(cfg), cfg.S3.OperationTimeout)
+	if s3errors.IsSSOExpired(err) {
+		// Nothing else would work either
+		return s3errors.SSOError(err, cfg)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not check bucket contents: %v\n", err)
+		return nil
+	}
+	if count == 0 {
+		return nil
+	}
+	return confirmUnmanaged(cfg.S3.Bucket, prefix, count, stdinIsTerminal(), os.Stdin, os.Stderr)
+}
+
+// checkArchive stops a machine from uploading to an archive other machines
+// share until it joins with --join-archive. A manifest that can't be read
+// is left to the upload to report, unless the SSO session has expired.
+func checkArchive(ctx context.Context, cfg *types.Config, client *s3.Client, join bool) error {
+	m, err := manifest.Load(ctx, client, cfg.S3.Bucket, manifest.ConfigKey(cfg), cfg.S3.OperationTimeout)
+	if s3errors.IsSSOExpired(err) {
+		return s3errors.SSOError(err, cfg)
+	}
+	if err != nil {
+		return nil
+	}
+	return joinArchive(cfg, m, archiveLocation(cfg), join, os.Stderr)
+}
+
+// archiveLocation names the manifest of the archive cfg uploads to, as
+// recorded in the state file for joined archives.
+func archiveLocation(cfg *types.Config) string {
+	location := "s3://" + cfg.S3.Bucket + "/" + manifest.ConfigKey(cfg)
+	if cfg.S3.Endpoint != "" {
+		location = cfg.S3.Endpoint + " " + location
+	}
+	return location
+}
+
+// joinArchive decides whether this machine may upload to the archive whose
+// manifest is m. A new archive, or one the machine already uploaded to or
+// joined, needs nothing. Otherwise the archive is described and join must be
+// set; the join is remembered so later runs go ahead without it.
+func joinArchive(cfg *types.Config, m *manifest.Manifest, location string, join bool, out io.Writer) error {
+	if name := cfg.S3.ArchiveName; name != "" && m.Archive.Name != "" && name != m.Archive.Name {
+		return fmt.Errorf("s3.archive_name is %q but %s belongs to the archive %q; check s3.bucket and s3.prefix", name, location, m.Archive.Name)
+	}
+	id := cfg.Local.MachineID
+	machines := m.Machines()
+	if len(machines) == 0 || slices.Contains(machines, id) || identity.Joined(cfg.Identity.StatePath, location) {
+		return nil
+	}
+
+	fmt.Fprintf(out, "This machine (%s) has never uploaded to the archive at %s:\n", id, location)
+	if m.Archive.Name != "" {
+		fmt.Fprintf(out, "  Name:        %s\n", m.Archive.Name)
+	}
+	fmt.Fprintf(out, "  Machines:    %s\n", strings.Join(machines, ", "))
+	fmt.Fprintf(out, "  Projects:    %d (%d files)\n", len(m.CountByProject(config.KeyPrefix(cfg))), len(m.Files))
+	if last := m.LatestUpload(); !last.IsZero() {
+		fmt.Fprintf(out, "  Last upload: %s\n", last.Local().Format(time.RFC3339))
+	}
+	if !join {
+		return fmt.Errorf("machine %s has not joined this archive; run 'cclogs upload --join-archive' once to share it, or change s3.prefix to keep a separate one", id)
+	}
+
+	if err := identity.RecordJoined(cfg.Identity.StatePath, location); err != nil {
+		fmt.Fprintf(out, "Warning: could not remember joining the archive: %v\n", err)
+	}
+	fmt.Fprintf(out, "Joining the archive as %s.\n", id)
+	return nil
+}
+
+// confirmUnmanaged describes the unexpected objects and asks whether to
+// continue. Without a terminal there is nobody to ask, so the upload proceeds
+// as it always has.
+func confirmUnmanaged(bucket, prefix string, count int, interactive bool, in io.Reader, out io.Writer) error {
+	fmt.Fprintf(out, "Bucket %s already has %d objects under prefix %q but no cclogs manifest.\n", bucket, count, prefix)
+	if !interactive {
+		fmt.Fprintf(out, "Warning: not a terminal; uploading without confirmation\n")
+		return nil
+	}
+
+	if !askYesNo(in, out, "Upload to this bucket anyway?") {
+		return fmt.Errorf("upload cancelled; check s3.bucket and s3.prefix, or pass --yes to skip this question")
+	}
+	return nil
+}
+
+// askYesNo prints question to out and reports whether the answer read from
+// in is yes. Anything else, including no answer, means no.
+func askYesNo(in io.Reader, out io.Writer, question string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", question)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// dryRunThroughput returns the rate dry runs estimate durations with, or nil
+// if neither upload.assumed_throughput nor previous runs give one.
+func dryRunThroughput(cfg *types.Config) *uploader.Throughput {
+	var observed float64
+	if cfg.Identity.StatePath != "" {
+		observed = identity.ObservedThroughput(cfg.Identity.StatePath)
+	}
+	return uploader.ChooseThroughput(cfg.Upload.AssumedThroughput, observed)
+}
+
+// printPlan prints the per-project dry-run totals as a table, or with --json
+// as a document on stdout that includes the compression estimate, if any.
+func printPlan(plan uploader.Plan, deferred uploader.Deferred, compression *codec.Estimate) error {
+	if !uploadJSON {
+		fmt.Fprintln(output.Human())
+		plan.Print(output.Human())
+		return nil
+	}
+	data, err := json.MarshalIndent(struct {
+		uploader.Plan
+		DeferredFiles int             `json:"deferredFiles"`
+		DeferredBytes int64           `json:"deferredBytes"`
+		Compression   *codec.Estimate `json:"compression,omitempty"`
+	}{plan, deferred.Files, deferred.Bytes, compression}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling JSON: %w", err)
+	}
+	fmt.Println(string(data))
+	return nil
+}
+
+// printDeferred reports uploads left for a later run by --limit or --max-bytes.
+func printDeferred(d uploader.Deferred) {
+	if d.Files == 0 {
+		return
+	}
+	fmt.Fprintf(output.Human(), "\nLimit reached: %s not queued; run upload again to continue\n", d)
+}
+
+// printErrorGuidance prints troubleshooting advice to stderr when err is a
+// recognized failure.
+func printErrorGuidance(err error, cfg *types.Config) {
+	switch {
+	case s3errors.IsSSOExpired(err):
+		fmt.Fprintln(os.Stderr, s3errors.SSOError(err, cfg))
+		return
+	case errors.Is(err, s3errors.ErrAccessDenied):
+		fmt.Fprintln(os.Stderr, msg.Text("s3.access_denied", msg.Args{"Bucket": config.BucketDisplayName(cfg.S3.Bucket)}))
+		return
+	case errors.Is(err, manifest.ErrCorrupt):
+		fmt.Fprintln(os.Stderr, msg.Text("manifest.corrupt", nil))
+		return
+	}
+
+	var sigErr *s3errors.SignatureMismatchError
+	if !errors.As(s3errors.Classify(err), &sigErr) {
+		return
+	}
+
+	fmt.Fprintln(os.Stderr, msg.Text("s3.signature.rejected", nil))
+	for _, step := range s3errors.Checklist(sigErr, cfg) {
+		fmt.Fprintf(os.Stderr, "  - %s\n", step)
+	}
 }
 
 // mergeProjects combines local and remote projects into a single list.
@@ -248,12 +3224,20 @@ func mergeProjects(local, remote []types.Project) []types.Project {
 			// Project exists locally and remotely
 			existing.RemoteCount = p.RemoteCount
 			existing.RemotePath = p.RemotePath
+			existing.RemoteBytes = p.RemoteBytes
+			existing.RemoteApprox = p.RemoteApprox
+			existing.RemoteLastUpload = p.RemoteLastUpload
+			existing.RemoteFiles = p.RemoteFiles
 		} else {
 			// Remote-only project
 			projectMap[p.Name] = &types.Project{
-				Name:        p.Name,
-				RemotePath:  p.RemotePath,
-				RemoteCount: p.RemoteCount,
+				Name:             p.Name,
+				RemotePath:       p.RemotePath,
+				RemoteCount:      p.RemoteCount,
+				RemoteBytes:      p.RemoteBytes,
+				RemoteApprox:     p.RemoteApprox,
+				RemoteLastUpload: p.RemoteLastUpload,
+				RemoteFiles:      p.RemoteFiles,
 			}
 		}
 	}
@@ -271,13 +3255,112 @@ func mergeProjects(local, remote []types.Project) []types.Project {
 	return merged
 }
 
-// computeManifestKey returns the S3 key for the manifest file.
-func computeManifestKey(prefix string) string {
-	if prefix == "" {
-		return ".manifest.json"
+// Sources of remote counts for list --source.
+const (
+	listSourceManifest = "manifest"
+	listSourceListing  = "listing"
+	listSourceAuto     = "auto"
+)
+
+// discoverRemoteFlat finds the remote projects under the prefix from the
+// manifest or a listing of the bucket, as source selects. In auto mode the
+// manifest is used unless it is empty or its file count disagrees with a
+// count of the stored logs; either way the user is told to rebuild it.
+func discoverRemoteFlat(ctx context.Context, client *s3.Client, cfg *types.Config, source string, files bool) []types.Project {
+	prefix := config.KeyPrefix(cfg)
+	key := manifest.ConfigKey(cfg)
+	recorded := -1 // Files in the manifest; -1 when it was not loaded
+	if source != listSourceListing {
+		m, err := manifest.Load(ctx, client, cfg.S3.Bucket, key, cfg.S3.OperationTimeout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not load manifest: %v\n", err)
+			m = manifest.New()
+		}
+		projects := discover.DiscoverFromManifest(m, prefix)
+		if files {
+			discover.AddManifestFiles(projects, m, prefix)
+		}
+		if source == listSourceManifest {
+			return projects
+		}
+		recorded = output.ArchiveTotals(projects).Files
+		if recorded > 0 {
+			listed, err := discover.CountRemote(ctx, client, cfg.S3.Bucket, prefix, cfg.Local.Extensions, cfg.S3.OperationTimeout)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: could not list the bucket to check the manifest: %v\n", err)
+				return projects
+			}
+			if !discover.CountsDisagree(recorded, listed) {
+				return projects
+			}
+		}
+	}
+
+	projects, err := discover.DiscoverRemote(ctx, client, cfg.S3.Bucket, prefix, cfg.Local.Extensions, cfg.Local.ProjectDepth, cfg.S3.OperationTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not list remote projects: %v\n", err)
+		return nil
+	}
+	listed := output.ArchiveTotals(projects).Files
+	if files {
+		fmt.Fprintln(os.Stderr, "Warning: --files needs the manifest; files are not listed")
+	}
+	switch {
+	case recorded > 0:
+		fmt.Fprintf(os.Stderr, "Warning: manifest %s records %d files but %d are stored; showing the listing. Run \"cclogs manifest rebuild\" to update it.\n", key, recorded, listed)
+	case recorded == 0 && listed > 0:
+		fmt.Fprintf(os.Stderr, "Warning: manifest %s is missing or empty but %d files are stored; showing the listing. Run \"cclogs manifest rebuild\" to recreate it.\n", key, listed)
 	}
-	if !strings.HasSuffix(prefix, "/") {
-		prefix = prefix + "/"
+	return projects
+}
+
+// discoverRemoteByHost loads each machine's manifest under the shared prefix.
+// With breakOut, projects are named "<machine>/<project>"; otherwise counts for
+// the same project are summed across machines.
+func discoverRemoteByHost(ctx context.Context, client *s3.Client, cfg *types.Config, breakOut, files bool) []types.Project {
+	hosts, err := discover.DiscoverHosts(ctx, client, cfg.S3.Bucket, cfg.S3.Prefix, cfg.S3.OperationTimeout)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: could not list machines: %v\n", err)
+		return nil
+	}
+
+	totals := make(map[string]*types.Project)
+	var projects []types.Project
+	for _, host := range hosts {
+		hostPrefix := cfg.S3.Prefix + host + "/"
+		m, err := manifest.Load(ctx, client, cfg.S3.Bucket, manifest.KeyFor(hostPrefix, cfg.S3.ManifestKey), cfg.S3.OperationTimeout)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: could not load manifest for %s: %v\n", host, err)
+			continue
+		}
+
+		hostProjects := discover.DiscoverFromManifest(m, hostPrefix)
+		if files {
+			discover.AddManifestFiles(hostProjects, m, hostPrefix)
+		}
+		for _, p := range hostProjects {
+			if breakOut {
+				p.Name = host + "/" + p.Name
+				projects = append(projects, p)
+				continue
+			}
+			if existing, ok := totals[p.Name]; ok {
+				existing.RemoteCount += p.RemoteCount
+				existing.RemoteBytes += p.RemoteBytes
+				existing.RemoteApprox = existing.RemoteApprox || p.RemoteApprox
+				existing.RemoteFiles = append(existing.RemoteFiles, p.RemoteFiles...)
+				if p.RemoteLastUpload.After(existing.RemoteLastUpload) {
+					existing.RemoteLastUpload = p.RemoteLastUpload
+				}
+				continue
+			}
+			p.RemotePath = cfg.S3.Prefix + "*/" + p.Name + "/"
+			totals[p.Name] = &p
+		}
+	}
+
+	for _, p := range totals {
+		projects = append(projects, *p)
 	}
-	return prefix + ".manifest.json"
+	return projects
 }
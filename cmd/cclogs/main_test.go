@@ -2,10 +2,35 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"reflect"
+	"runtime"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/codec"
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/identity"
+	"github.com/13rac1/cclogs/internal/lock"
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/output"
+	"github.com/13rac1/cclogs/internal/s3errors"
+	"github.com/13rac1/cclogs/internal/selftest"
+	"github.com/13rac1/cclogs/internal/types"
+	"github.com/13rac1/cclogs/internal/uploader"
+	"github.com/aws/smithy-go"
 )
 
 func TestListCommand(t *testing.T) {
@@ -263,6 +288,23 @@ func TestLoadConfigCustomPathNoAutoCreation(t *testing.T) {
 	}
 }
 
+func TestPrintConfigProblems(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := "s3:\n  bucket: logs\n  region: us-west-2\n  end_point: https://minio.local\n  prefix: /logs\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	printConfigProblems(&out, path, config.Check(path, true))
+	want := "Config: " + path + "\n\nProblems:\n" +
+		"  - line 4: unknown key \"s3.end_point\"\n" +
+		"  - s3.prefix must not start with \"/\", got \"/logs/\"\n"
+	if out.String() != want {
+		t.Errorf("output = %q, want %q", out.String(), want)
+	}
+}
+
 func TestPrintWelcomeMessage(t *testing.T) {
 	oldStdout := os.Stdout
 	r, w, _ := os.Pipe()
@@ -297,3 +339,1346 @@ func TestPrintWelcomeMessage(t *testing.T) {
 		}
 	}
 }
+
+func TestUploadPreflightBadBucket(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "projects", "project1")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "session.jsonl"), []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := `local:
+  projects_root: ` + filepath.Join(tmpDir, "projects") + `
+s3:
+  bucket: missing-bucket
+  region: us-east-1
+  endpoint: ` + server.URL + `
+  force_path_style: true
+auth:
+  access_key_id: AKIDEXAMPLE
+  secret_access_key: secret
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldArgs := os.Args
+	oldStdout := os.Stdout
+	defer func() {
+		os.Args = oldArgs
+		os.Stdout = oldStdout
+	}()
+	os.Args = []string{"cclogs", "--config", configPath, "upload", "--preflight"}
+
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	var out bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(&out, r)
+		close(done)
+	}()
+
+	var errBuf bytes.Buffer
+	rootCmd.SetOut(&errBuf)
+	rootCmd.SetErr(&errBuf)
+	err := rootCmd.Execute()
+
+	_ = w.Close()
+	<-done
+	os.Stdout = oldStdout
+
+	if err == nil || !strings.Contains(err.Error(), "preflight failed") {
+		t.Fatalf("upload error = %v, want preflight failure", err)
+	}
+	if !strings.Contains(out.String(), "Failed to connect to S3 bucket") {
+		t.Errorf("expected doctor messaging, got: %s", out.String())
+	}
+
+	// Discovery would fetch the manifest; only the bucket check may have run
+	mu.Lock()
+	defer mu.Unlock()
+	for _, req := range requests {
+		if !strings.HasPrefix(req, http.MethodHead) {
+			t.Errorf("unexpected request before preflight passed: %s", req)
+		}
+	}
+
+	receipts, err := os.ReadDir(filepath.Join(tmpDir, "runs"))
+	if err != nil || len(receipts) != 1 {
+		t.Fatalf("expected one run receipt, got %v (%v)", receipts, err)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "runs", receipts[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"remote.bucket"`) {
+		t.Errorf("receipt missing preflight results: %s", data)
+	}
+}
+
+func TestUploadReceiptOnClientError(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "projects"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// A profile missing from the shared config makes the client fail
+	empty := filepath.Join(tmpDir, "aws-config")
+	if err := os.WriteFile(empty, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("AWS_CONFIG_FILE", empty)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", empty)
+
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := `local:
+  projects_root: ` + filepath.Join(tmpDir, "projects") + `
+s3:
+  bucket: test-bucket
+  region: us-east-1
+auth:
+  profile: missing-profile
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	code, out := runCLI(t, "--config", configPath, "upload", "--no-preflight")
+	noPreflight = false
+	if code == 0 {
+		t.Fatalf("upload succeeded without a client:\n%s", out)
+	}
+
+	receipts, err := os.ReadDir(filepath.Join(tmpDir, "runs"))
+	if err != nil || len(receipts) != 1 {
+		t.Fatalf("expected one run receipt, got %v (%v)", receipts, err)
+	}
+	data, err := os.ReadFile(filepath.Join(tmpDir, "runs", receipts[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "creating S3 client") {
+		t.Errorf("receipt missing the client error: %s", data)
+	}
+}
+
+func TestUploadLockHeld(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the pid-based lock does not exclude its own process")
+	}
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "projects"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := `local:
+  projects_root: ` + filepath.Join(tmpDir, "projects") + `
+s3:
+  bucket: test-bucket
+  region: us-east-1
+  endpoint: ` + server.URL + `
+  force_path_style: true
+auth:
+  access_key_id: AKIDEXAMPLE
+  secret_access_key: secret
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	held, err := lock.Acquire(filepath.Join(tmpDir, lock.File))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = held.Release() }()
+
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+		noPreflight = false
+	}()
+	os.Args = []string{"cclogs", "--config", configPath, "upload", "--no-preflight"}
+
+	var errBuf bytes.Buffer
+	rootCmd.SetOut(&errBuf)
+	rootCmd.SetErr(&errBuf)
+	err = rootCmd.Execute()
+
+	want := fmt.Sprintf("another upload is running (pid %d); use --wait-lock", os.Getpid())
+	if err == nil || !strings.Contains(err.Error(), want) {
+		t.Fatalf("upload error = %v, want %q", err, want)
+	}
+	if n := requests.Load(); n != 0 {
+		t.Errorf("%d S3 requests made while the lock was held, want 0", n)
+	}
+}
+
+func TestUploadDryRunFailIfPending(t *testing.T) {
+	mtime := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	backedUp := `{"version":1,"files":{"claude-code/project1/session.jsonl":{"mtime":"2025-03-01T12:00:00Z","size":3}}}`
+
+	tests := []struct {
+		name     string
+		manifest string // Empty serves NoSuchKey
+		wantExit int    // -1 if exitFunc must not be called
+		wantOut  string
+	}{
+		{"file pending", "", exitPending, "1 files pending upload"},
+		{"all backed up", backedUp, -1, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/.manifest.json"):
+					if tt.manifest == "" {
+						w.Header().Set("Content-Type", "application/xml")
+						w.WriteHeader(http.StatusNotFound)
+						_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code></Error>`)
+						return
+					}
+					_, _ = io.WriteString(w, tt.manifest)
+				case r.Method == http.MethodHead:
+					w.WriteHeader(http.StatusOK)
+				default:
+					t.Errorf("unexpected request in dry run: %s %s", r.Method, r.URL.Path)
+					w.WriteHeader(http.StatusForbidden)
+				}
+			}))
+			defer server.Close()
+
+			tmpDir := t.TempDir()
+			session := filepath.Join(tmpDir, "projects", "project1", "session.jsonl")
+			if err := os.MkdirAll(filepath.Dir(session), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(session, []byte("{}\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Chtimes(session, mtime, mtime); err != nil {
+				t.Fatal(err)
+			}
+
+			cfgPath := filepath.Join(tmpDir, "config.yaml")
+			configContent := `local:
+  projects_root: ` + filepath.Join(tmpDir, "projects") + `
+s3:
+  bucket: test-bucket
+  region: us-east-1
+  endpoint: ` + server.URL + `
+  force_path_style: true
+auth:
+  access_key_id: AKIDEXAMPLE
+  secret_access_key: secret
+`
+			if err := os.WriteFile(cfgPath, []byte(configContent), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			oldArgs, oldStdout, oldStderr, oldExit := os.Args, os.Stdout, os.Stderr, exitFunc
+			defer func() {
+				os.Args, os.Stdout, os.Stderr, exitFunc = oldArgs, oldStdout, oldStderr, oldExit
+				dryRun, failIfPending = false, false
+			}()
+			os.Args = []string{"cclogs", "--config", cfgPath, "upload", "--dry-run", "--fail-if-pending"}
+
+			exitCode := -1
+			exitFunc = func(code int) { exitCode = code }
+
+			r, w, _ := os.Pipe()
+			os.Stdout, os.Stderr = w, w
+			var out bytes.Buffer
+			done := make(chan struct{})
+			go func() {
+				_, _ = io.Copy(&out, r)
+				close(done)
+			}()
+
+			err := rootCmd.Execute()
+
+			_ = w.Close()
+			<-done
+			os.Stdout, os.Stderr = oldStdout, oldStderr
+
+			if err != nil {
+				t.Fatalf("upload error = %v\n%s", err, out.String())
+			}
+			if exitCode != tt.wantExit {
+				t.Errorf("exit code = %d, want %d\n%s", exitCode, tt.wantExit, out.String())
+			}
+			if tt.wantOut != "" && !strings.Contains(out.String(), tt.wantOut) {
+				t.Errorf("output missing %q:\n%s", tt.wantOut, out.String())
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied"}
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{"nil", nil, 0},
+		{"generic", errors.New("boom"), 1},
+		{"config not found", fmt.Errorf("%w: /tmp/config.yaml", config.ErrConfigNotFound), exitConfig},
+		{"config invalid", &configError{errors.New("validating config: s3.region is required")}, exitConfig},
+		{"bucket not configured", fmt.Errorf("validating config: %w", config.ErrBucketNotConfigured), exitConfig},
+		{"access denied", fmt.Errorf("uploading files: %w", s3errors.Wrap(denied)), exitConnectivity},
+		{"signature mismatch", &smithy.GenericAPIError{Code: "SignatureDoesNotMatch"}, exitConnectivity},
+		{"network", fmt.Errorf("downloading manifest: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), exitConnectivity},
+		{"sso expired", &s3errors.SSOExpiredError{Profile: "work", Err: errors.New("cached SSO token is expired, or not present, and cannot be refreshed")}, exitConnectivity},
+		{"partial failure", fmt.Errorf("target: %w", uploader.ErrPartialFailure), exitPartialFailure},
+		{"preflight config", &preflightError{Stage: "config", Message: "s3.region is required"}, exitConfig},
+		{"preflight local", &preflightError{Stage: "local", Message: "projects root not found"}, exitChecksFailed},
+		{"preflight remote", fmt.Errorf("upload: %w", &preflightError{Stage: "remote", Message: "bucket unreachable"}), exitConnectivity},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := exitCode(tt.err); got != tt.want {
+				t.Errorf("exitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+// runCLI runs cclogs with args and returns the status it would exit with:
+// the first code passed to exitFunc, or else that of the returned error.
+func runCLI(t *testing.T, args ...string) (int, string) {
+	t.Helper()
+	oldArgs, oldStdout, oldStderr, oldExit := os.Args, os.Stdout, os.Stderr, exitFunc
+	defer func() {
+		os.Args, os.Stdout, os.Stderr, exitFunc = oldArgs, oldStdout, oldStderr, oldExit
+	}()
+	os.Args = append([]string{"cclogs"}, args...)
+
+	code := -1
+	exitFunc = func(c int) {
+		if code == -1 {
+			code = c
+		}
+	}
+
+	r, w, _ := os.Pipe()
+	os.Stdout, os.Stderr = w, w
+	var out bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(&out, r)
+		close(done)
+	}()
+
+	err := rootCmd.Execute()
+
+	_ = w.Close()
+	<-done
+	if code == -1 {
+		code = exitCode(err)
+	}
+	return code, out.String()
+}
+
+func TestExitCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/denied-bucket"):
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+		case strings.HasSuffix(r.URL.Path, "/.manifest.json") && r.Method == http.MethodGet:
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code></Error>`)
+		case r.Method == http.MethodPut && !strings.HasSuffix(r.URL.Path, "/session.jsonl"):
+			w.Header().Set("ETag", `"1"`)
+		case r.URL.Query().Has("list-type"):
+			_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult></ListBucketResult>`)
+		default:
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusForbidden)
+			_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+		}
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	session := filepath.Join(tmpDir, "projects", "project1", "session.jsonl")
+	if err := os.MkdirAll(filepath.Dir(session), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{session, filepath.Join(filepath.Dir(session), "other.jsonl")} {
+		if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeConfig := func(name, s3 string) string {
+		path := filepath.Join(tmpDir, name, "config.yaml")
+		content := "local:\n  projects_root: " + filepath.Join(tmpDir, "projects") + "\ns3:\n" + s3 +
+			"auth:\n  access_key_id: AKIDEXAMPLE\n  secret_access_key: secret\n"
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	remote := writeConfig("remote", "  bucket: test-bucket\n  region: us-east-1\n  endpoint: "+server.URL+"\n  force_path_style: true\n")
+	denied := writeConfig("denied", "  bucket: denied-bucket\n  region: us-east-1\n  endpoint: "+server.URL+"\n  force_path_style: true\n")
+	invalid := writeConfig("invalid", "  bucket: test-bucket\n  region: us east\n")
+	defer func() { uploadYes, noPreflight = false, false }()
+
+	tests := []struct {
+		name string
+		args []string
+		want int
+	}{
+		{"missing config", []string{"--config", filepath.Join(tmpDir, "missing.yaml"), "status"}, exitConfig},
+		{"invalid config", []string{"--config", invalid, "status"}, exitConfig},
+		{"config validate problems", []string{"--config", invalid, "config", "validate"}, exitConfig},
+		{"doctor failure", []string{"--config", remote, "doctor"}, exitChecksFailed},
+		{"preflight failure", []string{"--config", remote, "upload", "--yes"}, exitConnectivity},
+		{"access denied", []string{"--config", denied, "upload", "--yes", "--no-preflight"}, exitConnectivity},
+		{"partial upload failure", []string{"--config", remote, "upload", "--yes", "--no-preflight"}, exitPartialFailure},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, out := runCLI(t, tt.args...)
+			if code != tt.want {
+				t.Errorf("exit code = %d, want %d\n%s", code, tt.want, out)
+			}
+		})
+	}
+}
+
+func TestPruneLocal(t *testing.T) {
+	mtime := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	manifestJSON := `{"version":1,"files":{"claude-code/project1/uploaded.jsonl":{"mtime":"2025-03-01T12:00:00Z","size":3}}}`
+
+	tests := []struct {
+		name        string
+		args        []string
+		wantRemoved bool
+		wantOut     string
+	}{
+		{"listing only", nil, false, "Would remove 1 file (3 B). Run with --yes"},
+		{"delete", []string{"--yes"}, true, "Deleted 1 of 1 files, reclaimed 3 B."},
+		{"absolute time", []string{"--older-than", "2025-03-01T13:00:00Z"}, false, "Would remove 1 file (3 B)."},
+		{"absolute time before", []string{"--older-than", "2025-03-01T11:00:00Z"}, false, "No uploaded logs older than 2025-03-01T11:00:00Z"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/.manifest.json") {
+					_, _ = io.WriteString(w, manifestJSON)
+					return
+				}
+				t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+				w.WriteHeader(http.StatusForbidden)
+			}))
+			defer server.Close()
+
+			tmpDir := t.TempDir()
+			uploaded := filepath.Join(tmpDir, "projects", "project1", "uploaded.jsonl")
+			pending := filepath.Join(tmpDir, "projects", "project1", "pending.jsonl")
+			if err := os.MkdirAll(filepath.Dir(uploaded), 0755); err != nil {
+				t.Fatal(err)
+			}
+			for _, path := range []string{uploaded, pending} {
+				if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+				if err := os.Chtimes(path, mtime, mtime); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			cfgPath := filepath.Join(tmpDir, "config.yaml")
+			configContent := `local:
+  projects_root: ` + filepath.Join(tmpDir, "projects") + `
+s3:
+  bucket: test-bucket
+  region: us-east-1
+  endpoint: ` + server.URL + `
+  force_path_style: true
+auth:
+  access_key_id: AKIDEXAMPLE
+  secret_access_key: secret
+`
+			if err := os.WriteFile(cfgPath, []byte(configContent), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			oldArgs, oldStdout := os.Args, os.Stdout
+			defer func() {
+				os.Args, os.Stdout = oldArgs, oldStdout
+				pruneYes = false
+			}()
+			os.Args = append([]string{"cclogs", "--config", cfgPath, "prune-local", "--older-than", "7d"}, tt.args...)
+
+			r, w, _ := os.Pipe()
+			os.Stdout = w
+			var out bytes.Buffer
+			done := make(chan struct{})
+			go func() {
+				_, _ = io.Copy(&out, r)
+				close(done)
+			}()
+
+			err := rootCmd.Execute()
+
+			_ = w.Close()
+			<-done
+			os.Stdout = oldStdout
+
+			if err != nil {
+				t.Fatalf("prune-local error = %v\n%s", err, out.String())
+			}
+			if !strings.Contains(out.String(), tt.wantOut) {
+				t.Errorf("output missing %q:\n%s", tt.wantOut, out.String())
+			}
+			if _, err := os.Stat(uploaded); os.IsNotExist(err) != tt.wantRemoved {
+				t.Errorf("uploaded file removed = %v, want %v", os.IsNotExist(err), tt.wantRemoved)
+			}
+			if _, err := os.Stat(pending); err != nil {
+				t.Errorf("pending file was touched: %v", err)
+			}
+		})
+	}
+}
+
+func TestUploadFailOnSeverity(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		severity string
+		wantExit int // -1 if exitFunc must not be called
+	}{
+		{"high secret", `{"text":"key AKIAJ7Q2XG5N4WLD8KPR"}`, "high", exitSecretsFound},
+		{"only low matches", `{"text":"mail bob@company.com"}`, "high", -1},
+		{"low threshold", `{"text":"mail bob@company.com"}`, "low", exitSecretsFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			session := filepath.Join(tmpDir, "projects", "project1", "session.jsonl")
+			if err := os.MkdirAll(filepath.Dir(session), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(session, []byte(tt.content+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfgPath := filepath.Join(tmpDir, "config.yaml")
+			configContent := `local:
+  projects_root: ` + filepath.Join(tmpDir, "projects") + `
+s3:
+  bucket: test-bucket
+  region: us-east-1
+`
+			if err := os.WriteFile(cfgPath, []byte(configContent), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			oldArgs, oldStdout, oldStderr, oldExit := os.Args, os.Stdout, os.Stderr, exitFunc
+			defer func() {
+				os.Args, os.Stdout, os.Stderr, exitFunc = oldArgs, oldStdout, oldStderr, oldExit
+				dryRun, noPreflight, failSeverity = false, false, ""
+			}()
+			os.Args = []string{"cclogs", "--config", cfgPath, "upload", "--dry-run", "--no-preflight", "--fail-on-severity", tt.severity}
+
+			exitCode := -1
+			exitFunc = func(code int) { exitCode = code }
+
+			r, w, _ := os.Pipe()
+			os.Stdout, os.Stderr = w, w
+			var out bytes.Buffer
+			done := make(chan struct{})
+			go func() {
+				_, _ = io.Copy(&out, r)
+				close(done)
+			}()
+
+			err := rootCmd.Execute()
+
+			_ = w.Close()
+			<-done
+			os.Stdout, os.Stderr = oldStdout, oldStderr
+
+			if err != nil {
+				t.Fatalf("upload error = %v\n%s", err, out.String())
+			}
+			if exitCode != tt.wantExit {
+				t.Errorf("exit code = %d, want %d\n%s", exitCode, tt.wantExit, out.String())
+			}
+			if tt.wantExit == exitSecretsFound && !strings.Contains(out.String(), "at or above "+tt.severity+" severity") {
+				t.Errorf("output missing severity message:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestConfirmUnmanaged(t *testing.T) {
+	tests := []struct {
+		name        string
+		interactive bool
+		answer      string
+		wantErr     bool
+		wantPrompt  bool
+	}{
+		{name: "not a terminal proceeds", interactive: false, answer: "n\n"},
+		{name: "confirmed", interactive: true, answer: "y\n", wantPrompt: true},
+		{name: "declined", interactive: true, answer: "n\n", wantErr: true, wantPrompt: true},
+		{name: "no answer", interactive: true, answer: "", wantErr: true, wantPrompt: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := confirmUnmanaged("logs", "claude-code/", 12, tt.interactive, strings.NewReader(tt.answer), &out)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("confirmUnmanaged() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !strings.Contains(out.String(), `Bucket logs already has 12 objects under prefix "claude-code/"`) {
+				t.Errorf("output missing bucket details:\n%s", out.String())
+			}
+			if got := strings.Contains(out.String(), "[y/N]"); got != tt.wantPrompt {
+				t.Errorf("prompted = %v, want %v\n%s", got, tt.wantPrompt, out.String())
+			}
+		})
+	}
+}
+
+func TestJoinArchive(t *testing.T) {
+	uploaded := time.Date(2025, 3, 8, 12, 0, 0, 0, time.UTC)
+	shared := manifest.New()
+	shared.Archive = manifest.Archive{Name: "team-logs", Machines: []string{"laptop-a1b2c3"}}
+	shared.Files["claude-code/api/a.jsonl"] = manifest.FileEntry{Size: 3, Machine: "desktop-d4e5f6", UploadedAt: uploaded}
+	shared.Files["claude-code/web/b.jsonl"] = manifest.FileEntry{Size: 5, Machine: "laptop-a1b2c3", UploadedAt: uploaded}
+	const location = "s3://logs/claude-code/.manifest.json"
+
+	newConfig := func(machine string) *types.Config {
+		return &types.Config{
+			Local:    types.LocalConfig{MachineID: machine},
+			S3:       types.S3Config{Bucket: "logs", Prefix: "claude-code/"},
+			Identity: types.Identity{StatePath: filepath.Join(t.TempDir(), "state.json")},
+		}
+	}
+
+	t.Run("new archive", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := joinArchive(newConfig("server-0a0b0c"), manifest.New(), location, false, &out); err != nil || out.Len() != 0 {
+			t.Errorf("joinArchive() = %v, output %q; want nil and no notice", err, out.String())
+		}
+	})
+
+	t.Run("machine already in the archive", func(t *testing.T) {
+		var out bytes.Buffer
+		if err := joinArchive(newConfig("desktop-d4e5f6"), shared, location, false, &out); err != nil || out.Len() != 0 {
+			t.Errorf("joinArchive() = %v, output %q; want nil and no notice", err, out.String())
+		}
+	})
+
+	t.Run("first join", func(t *testing.T) {
+		cfg := newConfig("server-0a0b0c")
+		var out bytes.Buffer
+		err := joinArchive(cfg, shared, location, false, &out)
+		if err == nil || !strings.Contains(err.Error(), "--join-archive") {
+			t.Fatalf("joinArchive() without --join-archive = %v, want an error naming the flag", err)
+		}
+		for _, want := range []string{
+			"This machine (server-0a0b0c) has never uploaded to the archive at " + location,
+			"Name:        team-logs",
+			"Machines:    desktop-d4e5f6, laptop-a1b2c3",
+			"Projects:    2 (2 files)",
+			"Last upload: " + uploaded.Local().Format(time.RFC3339),
+		} {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("notice missing %q:\n%s", want, out.String())
+			}
+		}
+
+		out.Reset()
+		if err := joinArchive(cfg, shared, location, true, &out); err != nil {
+			t.Fatalf("joinArchive() with --join-archive = %v", err)
+		}
+		if !strings.Contains(out.String(), "Joining the archive as server-0a0b0c") {
+			t.Errorf("output does not confirm the join:\n%s", out.String())
+		}
+
+		// Repeat runs go ahead without the flag or the notice
+		out.Reset()
+		if err := joinArchive(cfg, shared, location, false, &out); err != nil || out.Len() != 0 {
+			t.Errorf("joinArchive() after joining = %v, output %q; want nil and no notice", err, out.String())
+		}
+		// The join belongs to this archive only
+		if err := joinArchive(cfg, shared, "s3://other/claude-code/.manifest.json", false, &out); err == nil {
+			t.Error("joining one archive let the machine into another")
+		}
+	})
+
+	t.Run("archive name mismatch", func(t *testing.T) {
+		cfg := newConfig("desktop-d4e5f6")
+		cfg.S3.ArchiveName = "personal"
+		err := joinArchive(cfg, shared, location, true, &bytes.Buffer{})
+		if err == nil || !strings.Contains(err.Error(), `belongs to the archive "team-logs"`) {
+			t.Errorf("joinArchive() = %v, want an archive name mismatch", err)
+		}
+	})
+}
+
+// TestJSONOutputIsPure runs each command that has --json and checks stdout
+// holds exactly one JSON document, with any other text on stderr.
+func TestJSONOutputIsPure(t *testing.T) {
+	mtime := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	manifestJSON := `{"version":1,"files":{` +
+		`"claude-code/project1/session.jsonl":{"mtime":"2025-03-01T12:00:00Z","size":3},` +
+		`"claude-code/project2/remote.jsonl":{"mtime":"2025-03-01T12:00:00Z","size":9,"redactions":{"EMAIL":2}}}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/.manifest.json"):
+			_, _ = io.WriteString(w, manifestJSON)
+		case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult></ListBucketResult>`)
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code></Error>`)
+		}
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	for name, content := range map[string]string{
+		"project1/session.jsonl": "{}\n",
+		"project1/new.jsonl":     `{"type":"user","message":{"role":"user","content":"needle"}}` + "\n",
+	} {
+		path := filepath.Join(tmpDir, "projects", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfgPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := `local:
+  projects_root: ` + filepath.Join(tmpDir, "projects") + `
+s3:
+  bucket: test-bucket
+  region: us-east-1
+  endpoint: ` + server.URL + `
+  force_path_style: true
+auth:
+  access_key_id: AKIDEXAMPLE
+  secret_access_key: secret
+`
+	if err := os.WriteFile(cfgPath, []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// An unknown language adds a warning, which must not reach stdout
+	t.Setenv("CCLOGS_LANG", "zz")
+
+	tests := [][]string{
+		{"list", "--json"},
+		{"list", "--redaction-preview", "--json"},
+		{"search", "needle", "--json"},
+		{"status", "--json"},
+		{"diff", "--json"},
+		{"stats", "--json"},
+		{"manifest", "show", "--json"},
+		{"manifest", "find", "--tag", "EMAIL", "--json"},
+		{"upload", "--dry-run", "--json"},
+	}
+
+	for _, args := range tests {
+		t.Run(strings.Join(args, " "), func(t *testing.T) {
+			oldArgs, oldStdout, oldStderr, oldExit := os.Args, os.Stdout, os.Stderr, exitFunc
+			defer func() {
+				os.Args, os.Stdout, os.Stderr, exitFunc = oldArgs, oldStdout, oldStderr, oldExit
+				jsonOutput, searchJSON, statusJSON, diffJSON, statsJSON, manifestShowJSON = false, false, false, false, false, false
+				listRedactionPreview = false
+				uploadJSON, dryRun, dryRunSummary = false, false, false
+				manifestFindJSON, manifestFindTags = false, nil
+				output.SetMachine(false)
+			}()
+			os.Args = append([]string{"cclogs", "--config", cfgPath}, args...)
+			exitFunc = func(int) {}
+
+			capture := func() (*os.File, *bytes.Buffer, chan struct{}) {
+				r, w, _ := os.Pipe()
+				var buf bytes.Buffer
+				done := make(chan struct{})
+				go func() {
+					_, _ = io.Copy(&buf, r)
+					close(done)
+				}()
+				return w, &buf, done
+			}
+			stdoutW, stdout, stdoutDone := capture()
+			stderrW, stderr, stderrDone := capture()
+			os.Stdout, os.Stderr = stdoutW, stderrW
+
+			err := rootCmd.Execute()
+
+			_ = stdoutW.Close()
+			_ = stderrW.Close()
+			<-stdoutDone
+			<-stderrDone
+			os.Stdout, os.Stderr = oldStdout, oldStderr
+
+			if err != nil {
+				t.Fatalf("%v failed: %v\nstderr:\n%s", args, err, stderr)
+			}
+			if !json.Valid(stdout.Bytes()) {
+				t.Errorf("stdout is not a single JSON document:\n%s", stdout)
+			}
+			if !strings.Contains(stderr.String(), "Warning: no translation") {
+				t.Errorf("stderr missing language warning:\n%s", stderr)
+			}
+		})
+	}
+}
+
+func TestListJSONFiles(t *testing.T) {
+	manifestJSON := `{"version":1,"files":{` +
+		`"claude-code/project1/session.jsonl":{"mtime":"2025-03-01T12:00:00Z","size":3,"redactions":{"EMAIL":1}},` +
+		`"claude-code/project2/remote.jsonl":{"mtime":"2025-03-01T12:00:00Z","size":9,"redactions":{"AWS_KEY":2}}}}`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/.manifest.json"):
+			_, _ = io.WriteString(w, manifestJSON)
+		case r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2":
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult>`+
+				`<Contents><Key>claude-code/project1/session.jsonl</Key><Size>3</Size></Contents>`+
+				`<Contents><Key>claude-code/project2/remote.jsonl</Key><Size>9</Size></Contents>`+
+				`</ListBucketResult>`)
+		default:
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code></Error>`)
+		}
+	}))
+	defer server.Close()
+
+	tmpDir := t.TempDir()
+	path := filepath.Join(tmpDir, "projects", "project1", "session.jsonl")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfgPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := `local:
+  projects_root: ` + filepath.Join(tmpDir, "projects") + `
+s3:
+  bucket: test-bucket
+  region: us-east-1
+  endpoint: ` + server.URL + `
+  force_path_style: true
+auth:
+  access_key_id: AKIDEXAMPLE
+  secret_access_key: secret
+`
+	if err := os.WriteFile(cfgPath, []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldArgs, oldStdout := os.Args, os.Stdout
+	defer func() {
+		os.Args, os.Stdout = oldArgs, oldStdout
+		jsonOutput, listFiles = false, false
+		output.SetMachine(false)
+	}()
+	os.Args = []string{"cclogs", "--config", cfgPath, "list", "--json", "--files"}
+
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+	var out bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		_, _ = io.Copy(&out, r)
+		close(done)
+	}()
+
+	err := rootCmd.Execute()
+
+	_ = w.Close()
+	<-done
+	os.Stdout = oldStdout
+
+	if err != nil {
+		t.Fatalf("list error = %v", err)
+	}
+	var result output.JSONOutput
+	if err := json.Unmarshal(out.Bytes(), &result); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, out.String())
+	}
+
+	// project1 exists locally and remotely, project2 only remotely; both
+	// pass through mergeProjects
+	want := map[string][]output.RemoteFile{
+		"project1": {{Key: "claude-code/project1/session.jsonl", Size: 3, Tags: []string{"EMAIL"}}},
+		"project2": {{Key: "claude-code/project2/remote.jsonl", Size: 9, Tags: []string{"AWS_KEY"}}},
+	}
+	got := make(map[string][]output.RemoteFile)
+	for _, p := range result.RemoteProjects {
+		got[p.Name] = p.Files
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("remote files = %+v, want %+v", got, want)
+	}
+}
+
+func TestPrintWelcomeMessageMachineMode(t *testing.T) {
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	stdoutR, stdoutW, _ := os.Pipe()
+	stderrR, stderrW, _ := os.Pipe()
+	os.Stdout, os.Stderr = stdoutW, stderrW
+	output.SetMachine(true)
+
+	printWelcomeMessage("/test/path/config.yaml")
+
+	output.SetMachine(false)
+	_ = stdoutW.Close()
+	_ = stderrW.Close()
+	os.Stdout, os.Stderr = oldStdout, oldStderr
+
+	stdout, _ := io.ReadAll(stdoutR)
+	stderr, _ := io.ReadAll(stderrR)
+	if len(stdout) != 0 {
+		t.Errorf("stdout = %q, want nothing in machine mode", stdout)
+	}
+	if !strings.Contains(string(stderr), "Welcome to cclogs!") {
+		t.Errorf("stderr missing welcome message: %q", stderr)
+	}
+}
+
+func TestNotifyRunFailureIsWarning(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	cfg := &types.Config{Notify: types.NotifyConfig{WebhookURL: server.URL, On: "changes"}}
+
+	oldStderr := os.Stderr
+	r, w, _ := os.Pipe()
+	os.Stderr = w
+
+	notifyRun(context.Background(), cfg, &uploader.UploadResult{Skipped: 3}, nil) // Filtered out
+	notifyRun(context.Background(), cfg, &uploader.UploadResult{Uploaded: 1}, nil)
+
+	_ = w.Close()
+	os.Stderr = oldStderr
+	stderr, _ := io.ReadAll(r)
+
+	if calls != 1 {
+		t.Errorf("webhook called %d times, want 1", calls)
+	}
+	if !strings.Contains(string(stderr), "Warning: sending upload notification: webhook returned 500") {
+		t.Errorf("stderr = %q, want webhook warning", stderr)
+	}
+}
+
+func TestRemoteLockSkippedWithoutConditionalWrites(t *testing.T) {
+	cfg := &types.Config{
+		S3:       types.S3Config{Bucket: "logs", Endpoint: "https://minio.local"},
+		Identity: types.Identity{StatePath: filepath.Join(t.TempDir(), identity.StateFile)},
+	}
+	err := identity.RecordCapabilities(cfg.Identity.StatePath, identity.Capabilities{
+		Endpoint: cfg.S3.Endpoint,
+		Bucket:   cfg.S3.Bucket,
+		Features: map[string]bool{selftest.FeatureConditionalWrite: false},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// No client: the lock must not be attempted at all
+	rl, err := acquireRemoteLock(context.Background(), cfg, nil, false, false)
+	if rl != nil || err != nil {
+		t.Errorf("acquireRemoteLock() = %v, %v; want skipped", rl, err)
+	}
+}
+
+func TestDiscoverRemoteFlatSource(t *testing.T) {
+	manifestJSON := `{"version":1,"files":{` +
+		`"claude-code/project1/a.jsonl":{"mtime":"2025-03-01T12:00:00Z","size":3},` +
+		`"claude-code/project1/b.jsonl":{"mtime":"2025-03-01T12:00:00Z","size":3}}}`
+	listing := `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult>` +
+		`<Contents><Key>claude-code/project1/a.jsonl</Key><Size>3</Size></Contents>` +
+		`<Contents><Key>claude-code/project1/b.jsonl</Key><Size>3</Size></Contents>` +
+		`<Contents><Key>claude-code/project2/c.jsonl</Key><Size>5</Size></Contents>` +
+		`</ListBucketResult>`
+	prefixes := `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult>` +
+		`<CommonPrefixes><Prefix>claude-code/project1/</Prefix></CommonPrefixes>` +
+		`<CommonPrefixes><Prefix>claude-code/project2/</Prefix></CommonPrefixes>` +
+		`</ListBucketResult>`
+
+	tests := []struct {
+		name        string
+		source      string
+		manifest    string // Empty for a missing manifest
+		listed      string // Keys the listing serves, filtered by prefix
+		want        map[string]int
+		wantWarning string
+	}{
+		{"manifest trusted", listSourceManifest, "", listing, map[string]int{}, ""},
+		{"auto agrees", listSourceAuto, manifestJSON, `claude-code/project1/`, map[string]int{"project1": 2}, ""},
+		{"auto missing manifest", listSourceAuto, "", listing, map[string]int{"project1": 2, "project2": 1}, "is missing or empty but 3 files are stored"},
+		{"auto stale manifest", listSourceAuto, manifestJSON, listing, map[string]int{"project1": 2, "project2": 1}, "records 2 files but 3 are stored"},
+		{"listing", listSourceListing, manifestJSON, listing, map[string]int{"project1": 2, "project2": 1}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/xml")
+				switch {
+				case r.URL.Query().Get("list-type") == "2" && r.URL.Query().Get("delimiter") == "/":
+					_, _ = io.WriteString(w, prefixes)
+				case r.URL.Query().Get("list-type") == "2":
+					body := listing
+					if prefix := r.URL.Query().Get("prefix"); tt.listed != listing || prefix != "claude-code/" {
+						body = filterListing(listing, prefix, tt.listed)
+					}
+					_, _ = io.WriteString(w, body)
+				case strings.HasSuffix(r.URL.Path, "/.manifest.json") && tt.manifest != "":
+					_, _ = io.WriteString(w, tt.manifest)
+				default:
+					w.WriteHeader(http.StatusNotFound)
+					_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code></Error>`)
+				}
+			}))
+			defer server.Close()
+
+			cfg := &types.Config{
+				S3: types.S3Config{
+					Bucket: "test-bucket", Region: "us-east-1", Prefix: "claude-code/",
+					Endpoint: server.URL, ForcePathStyle: true, KeyLayout: "flat",
+				},
+				Auth: types.AuthConfig{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"},
+			}
+			client, err := config.NewS3Client(context.Background(), cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			manifest.SetCacheDir("")
+
+			oldStderr := os.Stderr
+			r, w, _ := os.Pipe()
+			os.Stderr = w
+			projects := discoverRemoteFlat(context.Background(), client, cfg, tt.source, false)
+			_ = w.Close()
+			os.Stderr = oldStderr
+			stderr, _ := io.ReadAll(r)
+
+			got := make(map[string]int)
+			for _, p := range projects {
+				got[p.Name] = p.RemoteCount
+			}
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("projects = %v, want %v", got, tt.want)
+			}
+			if tt.wantWarning == "" && len(stderr) > 0 {
+				t.Errorf("unexpected warning: %s", stderr)
+			}
+			if !strings.Contains(string(stderr), tt.wantWarning) {
+				t.Errorf("stderr = %q, want %q", stderr, tt.wantWarning)
+			}
+		})
+	}
+}
+
+// filterListing keeps the Contents of listing under prefix and, unless
+// within is the full listing, under within too.
+func filterListing(listing, prefix, within string) string {
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?><ListBucketResult>`)
+	for _, part := range strings.Split(listing, "<Contents>")[1:] {
+		key := part[len("<Key>"):strings.Index(part, "</Key>")]
+		if strings.HasPrefix(key, prefix) && (strings.HasPrefix(within, "<?xml") || strings.HasPrefix(key, within)) {
+			b.WriteString("<Contents>" + strings.TrimSuffix(part, "</ListBucketResult>"))
+		}
+	}
+	b.WriteString(`</ListBucketResult>`)
+	return b.String()
+}
+
+func TestUploadAllTargetsOnce(t *testing.T) {
+	t.Cleanup(func() { manifest.SetHistory(0, nil) })
+	cfg := &types.Config{
+		S3: types.S3Config{Bucket: "work-logs", Prefix: "claude-code/"},
+		Targets: map[string]types.Target{
+			"b2":    {S3: types.S3Config{Bucket: "b2-logs", Prefix: "claude-code/"}},
+			"minio": {S3: types.S3Config{Bucket: "minio-logs", Prefix: "claude-code/"}},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		results  map[string]int // Exit code per target; -1 returns an error
+		want     []string       // Buckets run, in order
+		wantCode int
+		wantErr  string
+	}{
+		{
+			name: "all targets in order",
+			want: []string{"work-logs", "b2-logs", "minio-logs"},
+		},
+		{
+			name:    "a failure does not stop later targets",
+			results: map[string]int{"b2": -1, "minio": exitPartialFailure},
+			want:    []string{"work-logs", "b2-logs", "minio-logs"},
+			wantErr: "uploading to target b2: boom",
+		},
+		{
+			name:     "first exit code is returned",
+			results:  map[string]int{"default": exitSecretsFound, "minio": exitPartialFailure},
+			want:     []string{"work-logs", "b2-logs", "minio-logs"},
+			wantCode: exitSecretsFound,
+		},
+		{
+			name:     "interrupt stops the remaining targets",
+			results:  map[string]int{"b2": 130},
+			want:     []string{"work-logs", "b2-logs"},
+			wantCode: 130,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			code, err := uploadAllTargetsOnce(context.Background(), cfg, func(c *types.Config) (int, error) {
+				got = append(got, c.S3.Bucket)
+				if tt.results[c.TargetName] == -1 {
+					return 0, fmt.Errorf("boom")
+				}
+				return tt.results[c.TargetName], nil
+			})
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("ran %v, want %v", got, tt.want)
+			}
+			if code != tt.wantCode {
+				t.Errorf("code = %d, want %d", code, tt.wantCode)
+			}
+			if (err == nil) != (tt.wantErr == "") || (err != nil && err.Error() != tt.wantErr) {
+				t.Errorf("err = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFindTagged(t *testing.T) {
+	m := manifest.New()
+	m.Files["claude-code/app/a.jsonl"] = manifest.FileEntry{Redactions: map[string]int64{"AWS_SECRET": 1, "EMAIL": 3}}
+	m.Files["claude-code/app/b.jsonl"] = manifest.FileEntry{Redactions: map[string]int64{"EMAIL": 1}}
+	m.Files["claude-code/web/c.jsonl"] = manifest.FileEntry{Project: "web", Redactions: map[string]int64{"AWS_SECRET": 2}}
+
+	got := findTagged(m, "claude-code/", []string{"AWS_SECRET"})
+	want := []taggedFile{
+		{Key: "claude-code/app/a.jsonl", Project: "app", Tags: []string{"AWS_SECRET", "EMAIL"}},
+		{Key: "claude-code/web/c.jsonl", Project: "web", Tags: []string{"AWS_SECRET"}},
+	}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("findTagged() = %+v, want %+v", got, want)
+	}
+
+	// No match is an empty JSON list, not null
+	data, err := json.Marshal(findTagged(m, "claude-code/", []string{"JWT"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "[]" {
+		t.Errorf("no matches marshal to %s, want []", data)
+	}
+}
+
+func TestExportSplit(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "projects", "app")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Session "b" was appended to the file of session "a" after a crash
+	content := `{"type":"user","sessionId":"a","message":{"role":"user","content":"first question"}}
+{"type":"user","sessionId":"b","message":{"role":"user","content":"second question"}}
+`
+	if err := os.WriteFile(filepath.Join(projectDir, "s.jsonl"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := `local:
+  projects_root: ` + filepath.Join(tmpDir, "projects") + `
+s3:
+  bucket: test-bucket
+  region: us-east-1
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldArgs, oldStderr := os.Args, os.Stderr
+	defer func() {
+		os.Args, os.Stderr = oldArgs, oldStderr
+		exportOutput, exportSplit = "", false
+	}()
+	os.Stderr, _ = os.Open(os.DevNull)
+	out := filepath.Join(tmpDir, "s.md")
+	os.Args = []string{"cclogs", "--config", configPath, "export", "app/s.jsonl", "--split", "-o", out}
+
+	if err := rootCmd.Execute(); err != nil {
+		t.Fatalf("export --split: %v", err)
+	}
+
+	for _, tt := range []struct{ name, want, other string }{
+		{"s-1.md", "first question", "second question"},
+		{"s-2.md", "second question", "first question"},
+	} {
+		data, err := os.ReadFile(filepath.Join(tmpDir, tt.name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(data), tt.want) || strings.Contains(string(data), tt.other) {
+			t.Errorf("%s should hold only %q:\n%s", tt.name, tt.want, data)
+		}
+	}
+	if _, err := os.Stat(out); !os.IsNotExist(err) {
+		t.Errorf("%s written alongside the split transcripts", out)
+	}
+}
+
+func TestSourceFlags(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "projects", "app")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := `{"type":"user","sessionId":"a","message":{"role":"user","content":"needle"}}` + "\n"
+	if err := os.WriteFile(filepath.Join(projectDir, "s.jsonl"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(tmpDir, "config.yaml")
+	configContent := `local:
+  projects_root: ` + filepath.Join(tmpDir, "projects") + `
+s3:
+  bucket: test-bucket
+  region: us-east-1
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Every log-reading command takes the same --local/--remote pair
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"cat", "app/s.jsonl"}, "needle"},
+		{[]string{"export", "app/s.jsonl"}, "needle"},
+		{[]string{"search", "needle", "--project", "app"}, "app/s.jsonl:1:"},
+		{[]string{"stats", "--project", "app"}, "1 files"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.args[0], func(t *testing.T) {
+			cmd, _, err := rootCmd.Find(tt.args[:1])
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() {
+				for _, name := range []string{"local", "remote", "project"} {
+					if f := cmd.Flags().Lookup(name); f != nil {
+						_ = f.Value.Set(f.DefValue)
+						f.Changed = false
+					}
+				}
+			}()
+
+			args := append([]string{"--config", configPath}, tt.args...)
+			code, out := runCLI(t, append(args, "--local")...)
+			if code != 0 || !strings.Contains(out, tt.want) {
+				t.Errorf("%v --local: exit %d, want output containing %q:\n%s", tt.args, code, tt.want, out)
+			}
+
+			oldArgs := os.Args
+			defer func() { os.Args = oldArgs }()
+			os.Args = append([]string{"cclogs"}, append(args, "--local", "--remote")...)
+			if err := rootCmd.Execute(); err == nil || !strings.Contains(err.Error(), "[local remote]") {
+				t.Errorf("%v --local --remote: error = %v, want a conflict error", tt.args, err)
+			}
+		})
+	}
+}
+
+func TestPrintPlanJSON(t *testing.T) {
+	oldStdout := os.Stdout
+	defer func() {
+		os.Stdout = oldStdout
+		uploadJSON = false
+	}()
+	uploadJSON = true
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Stdout = w
+
+	plan := uploader.NewPlan([]uploader.FileUpload{{ProjectDir: "app", Size: 1000}}, nil)
+	estimate := &codec.Estimate{Codec: codec.Gzip, Files: 1, InputBytes: 1000, StoredBytes: 150}
+	err = printPlan(plan, uploader.Deferred{}, estimate)
+	_ = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Total       uploader.ProjectPlan `json:"total"`
+		Compression *codec.Estimate      `json:"compression"`
+	}
+	if err := json.NewDecoder(r).Decode(&got); err != nil {
+		t.Fatalf("decoding plan: %v", err)
+	}
+	if got.Total.Uploads != 1 || got.Compression == nil || got.Compression.StoredBytes != 150 {
+		t.Errorf("plan = %+v, compression = %+v; want 1 upload stored as 150 bytes", got.Total, got.Compression)
+	}
+}
@@ -0,0 +1,228 @@
+// Package codec provides the compression codecs used for uploaded objects.
+// The codec chosen for each file is recorded in the manifest so later runs
+// know the object key suffix and how to decompress the content.
+package codec
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec identifies a compression format.
+type Codec string
+
+const (
+	None Codec = "none"
+	Gzip Codec = "gzip"
+	Zstd Codec = "zstd"
+)
+
+// zstdMaxLevel is the highest zstd level. Levels map onto the encoder's
+// speed settings the way the zstd command line tool's do.
+const zstdMaxLevel = 22
+
+// entropyThreshold is the Shannon entropy (bits per byte) above which content
+// is treated as already compressed. JSONL text is typically below 6.
+const entropyThreshold = 7.5
+
+// SampleSize is the number of leading bytes inspected by HighEntropy.
+const SampleSize = 64 * 1024
+
+// Parse validates a codec name from configuration. An empty name means None.
+func Parse(name string) (Codec, error) {
+	switch Codec(name) {
+	case "", None:
+		return None, nil
+	case Gzip, Zstd:
+		return Codec(name), nil
+	default:
+		return "", fmt.Errorf("unknown codec %q (use gzip, zstd, or none)", name)
+	}
+}
+
+// FromKey returns the codec an object key's extension names, and the key
+// without that extension. Keys without one are None.
+func FromKey(key string) (Codec, string) {
+	for _, c := range []Codec{Gzip, Zstd} {
+		if base, ok := strings.CutSuffix(key, c.Extension()); ok {
+			return c, base
+		}
+	}
+	return None, key
+}
+
+// FromContentEncoding returns the codec an object's Content-Encoding names,
+// or None for any other encoding.
+func FromContentEncoding(enc string) Codec {
+	switch c := Codec(enc); c {
+	case Gzip, Zstd:
+		return c
+	default:
+		return None
+	}
+}
+
+// Extension returns the object key suffix for c.
+func (c Codec) Extension() string {
+	switch c {
+	case Gzip:
+		return ".gz"
+	case Zstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+// ContentEncoding returns the HTTP Content-Encoding for objects compressed
+// with c, or "" when the content is stored as-is.
+func (c Codec) ContentEncoding() string {
+	switch c {
+	case Gzip, Zstd:
+		return string(c)
+	default:
+		return ""
+	}
+}
+
+// CheckLevel validates a compression level for c. Zero selects the codec's
+// default level.
+func CheckLevel(c Codec, level int) error {
+	if level == 0 {
+		return nil
+	}
+	switch c {
+	case Gzip:
+		if level < gzip.BestSpeed || level > gzip.BestCompression {
+			return fmt.Errorf("gzip level must be between %d and %d, got %d", gzip.BestSpeed, gzip.BestCompression, level)
+		}
+		return nil
+	case Zstd:
+		if level < 1 || level > zstdMaxLevel {
+			return fmt.Errorf("zstd level must be between 1 and %d, got %d", zstdMaxLevel, level)
+		}
+		return nil
+	case "", None:
+		return fmt.Errorf("uncompressed uploads have no level")
+	default:
+		return fmt.Errorf("levels are not supported for %q", c)
+	}
+}
+
+// Compress returns a reader yielding r compressed with c at level (zero for
+// the default), using dict if c is Zstd and dict is not nil. Compression runs
+// in a goroutine; errors from r or the encoder are returned by Read. Closing
+// the reader stops the goroutine if the consumer gives up early.
+func Compress(c Codec, level int, dict Dictionary, r io.Reader) (io.ReadCloser, error) {
+	switch c {
+	case "", None:
+		return io.NopCloser(r), nil
+	case Gzip, Zstd:
+		pr, pw := io.Pipe()
+		zw, err := NewWriter(c, level, dict, pw)
+		if err != nil {
+			return nil, err
+		}
+		go func() {
+			if _, err := io.Copy(zw, r); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			pw.CloseWithError(zw.Close())
+		}()
+		return pr, nil
+	default:
+		return nil, fmt.Errorf("unsupported codec %q", c)
+	}
+}
+
+// NewWriter returns a writer that compresses what is written to it with c at
+// level (zero for the default), using dict if c is Zstd and dict is not nil,
+// and writes the result to w. Close flushes the encoder but does not close w.
+func NewWriter(c Codec, level int, dict Dictionary, w io.Writer) (io.WriteCloser, error) {
+	switch c {
+	case "", None:
+		return nopWriteCloser{w}, nil
+	case Gzip:
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		zw, err := gzip.NewWriterLevel(w, level)
+		if err != nil {
+			return nil, fmt.Errorf("gzip level %d: %w", level, err)
+		}
+		return zw, nil
+	case Zstd:
+		opts := []zstd.EOption{zstd.WithEncoderConcurrency(1)}
+		if level != 0 {
+			opts = append(opts, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(level)))
+		}
+		if dict != nil {
+			opts = append(opts, zstd.WithEncoderDict(dict))
+		}
+		zw, err := zstd.NewWriter(w, opts...)
+		if err != nil {
+			return nil, fmt.Errorf("zstd level %d: %w", level, err)
+		}
+		return zw, nil
+	default:
+		return nil, fmt.Errorf("unsupported codec %q", c)
+	}
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// Decompress returns a reader yielding the decoded content of r. A zstd
+// stream compressed with a dictionary needs dict to be that dictionary;
+// otherwise a *DictionaryError is returned before anything is read.
+func Decompress(c Codec, dict Dictionary, r io.Reader) (io.ReadCloser, error) {
+	switch c {
+	case "", None:
+		return io.NopCloser(r), nil
+	case Gzip:
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("opening gzip stream: %w", err)
+		}
+		return zr, nil
+	case Zstd:
+		return newZstdReader(r, dict)
+	default:
+		return nil, fmt.Errorf("unsupported codec %q", c)
+	}
+}
+
+// HighEntropy reports whether sample looks already compressed or encrypted,
+// in which case compressing it again would waste CPU for no gain.
+func HighEntropy(sample []byte) bool {
+	if len(sample) == 0 {
+		return false
+	}
+	return entropy(sample) > entropyThreshold
+}
+
+// entropy returns the Shannon entropy of data in bits per byte.
+func entropy(data []byte) float64 {
+	var counts [256]int
+	for _, b := range data {
+		counts[b]++
+	}
+
+	n := float64(len(data))
+	var h float64
+	for _, c := range counts {
+		if c == 0 {
+			continue
+		}
+		p := float64(c) / n
+		h -= p * math.Log2(p)
+	}
+	return h
+}
@@ -0,0 +1,173 @@
+package codec
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    Codec
+		wantErr bool
+	}{
+		{name: "", want: None},
+		{name: "none", want: None},
+		{name: "gzip", want: Gzip},
+		{name: "zstd", want: Zstd},
+		{name: "bzip2", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Parse(tt.name)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Parse(%q) error = %v, wantErr %v", tt.name, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("Parse(%q) = %q, want %q", tt.name, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckLevel(t *testing.T) {
+	tests := []struct {
+		codec   Codec
+		level   int
+		wantErr bool
+	}{
+		{None, 0, false},
+		{Gzip, 0, false},
+		{Gzip, 1, false},
+		{Gzip, 9, false},
+		{Gzip, 10, true},
+		{Gzip, -1, true},
+		{None, 6, true},
+		{Zstd, 1, false},
+		{Zstd, 22, false},
+		{Zstd, 23, true},
+	}
+	for _, tt := range tests {
+		if err := CheckLevel(tt.codec, tt.level); (err != nil) != tt.wantErr {
+			t.Errorf("CheckLevel(%q, %d) error = %v, wantErr %v", tt.codec, tt.level, err, tt.wantErr)
+		}
+	}
+}
+
+func TestCompressLevels(t *testing.T) {
+	data := []byte(strings.Repeat(`{"type":"assistant","message":{"content":"some text"}}`+"\n", 2000))
+
+	sizes := make(map[int]int)
+	for _, level := range []int{1, 9} {
+		compressed, err := Compress(Gzip, level, nil, bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("Compress(level %d) failed: %v", level, err)
+		}
+		encoded, err := io.ReadAll(compressed)
+		_ = compressed.Close()
+		if err != nil {
+			t.Fatalf("reading compressed: %v", err)
+		}
+		sizes[level] = len(encoded)
+
+		rc, err := Decompress(Gzip, nil, bytes.NewReader(encoded))
+		if err != nil {
+			t.Fatalf("Decompress failed: %v", err)
+		}
+		decoded, err := io.ReadAll(rc)
+		_ = rc.Close()
+		if err != nil || !bytes.Equal(decoded, data) {
+			t.Fatalf("level %d round trip mismatch (err %v)", level, err)
+		}
+	}
+	if sizes[9] > sizes[1] {
+		t.Errorf("level 9 size %d larger than level 1 size %d", sizes[9], sizes[1])
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	data := []byte(strings.Repeat(`{"type":"user","message":"hello"}`+"\n", 1000))
+
+	for _, c := range []Codec{None, Gzip, Zstd} {
+		t.Run(string(c), func(t *testing.T) {
+			compressed, err := Compress(c, 0, nil, bytes.NewReader(data))
+			if err != nil {
+				t.Fatalf("Compress failed: %v", err)
+			}
+			defer compressed.Close()
+			encoded, err := io.ReadAll(compressed)
+			if err != nil {
+				t.Fatalf("reading compressed: %v", err)
+			}
+			if c != None && len(encoded) >= len(data) {
+				t.Errorf("compressed size %d not smaller than %d", len(encoded), len(data))
+			}
+
+			rc, err := Decompress(c, nil, bytes.NewReader(encoded))
+			if err != nil {
+				t.Fatalf("Decompress failed: %v", err)
+			}
+			defer rc.Close()
+			decoded, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("reading decompressed: %v", err)
+			}
+			if !bytes.Equal(decoded, data) {
+				t.Error("round trip altered content")
+			}
+		})
+	}
+}
+
+func TestFromKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want Codec
+		base string
+	}{
+		{"p/a.jsonl", None, "p/a.jsonl"},
+		{"p/a.jsonl.gz", Gzip, "p/a.jsonl"},
+		{"p/a.jsonl.zst", Zstd, "p/a.jsonl"},
+	}
+	for _, tt := range tests {
+		if c, base := FromKey(tt.key); c != tt.want || base != tt.base {
+			t.Errorf("FromKey(%q) = %q, %q, want %q, %q", tt.key, c, base, tt.want, tt.base)
+		}
+	}
+}
+
+func TestHighEntropy(t *testing.T) {
+	random := make([]byte, SampleSize)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+
+	var gz bytes.Buffer
+	compressed, _ := Compress(Gzip, 0, nil, bytes.NewReader(random))
+	if _, err := io.Copy(&gz, compressed); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		sample []byte
+		want   bool
+	}{
+		{name: "empty", sample: nil, want: false},
+		{name: "jsonl text", sample: []byte(strings.Repeat(`{"role":"assistant","content":"ok"}`+"\n", 500)), want: false},
+		{name: "random bytes", sample: random, want: true},
+		{name: "gzip output", sample: gz.Bytes(), want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := HighEntropy(tt.sample); got != tt.want {
+				t.Errorf("HighEntropy() = %v, want %v (entropy %.2f)", got, tt.want, entropy(tt.sample))
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+package codec
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/klauspost/compress/dict"
+	"github.com/klauspost/compress/zstd"
+)
+
+// DefaultDictSize is the size TrainDictionary aims for when none is given.
+// Transcripts share most of their structure in the first few KiB of JSON
+// keys and tool names, so larger dictionaries gain little.
+const DefaultDictSize = 64 * 1024
+
+// Dictionary is a trained zstd dictionary in the format "zstd --train"
+// writes. Each zstd frame compressed with it records its ID, which is also
+// kept in the manifest entry of the object. A nil Dictionary means none.
+type Dictionary []byte
+
+// ID returns the dictionary's ID, or 0 for none.
+func (d Dictionary) ID() uint32 {
+	if len(d) == 0 {
+		return 0
+	}
+	info, err := zstd.InspectDictionary(d)
+	if err != nil {
+		return 0
+	}
+	return info.ID()
+}
+
+// ParseDictionary checks that data is a zstd dictionary.
+func ParseDictionary(data []byte) (Dictionary, error) {
+	info, err := zstd.InspectDictionary(data)
+	if err != nil {
+		return nil, fmt.Errorf("not a zstd dictionary: %w", err)
+	}
+	if info.ID() == 0 {
+		return nil, errors.New("not a zstd dictionary: it has no ID")
+	}
+	return Dictionary(data), nil
+}
+
+// TrainDictionary builds a dictionary of up to size bytes (zero for
+// DefaultDictSize) from samples of the content it will compress. It is given
+// a random ID.
+func TrainDictionary(samples [][]byte, size int) (Dictionary, error) {
+	if size <= 0 {
+		size = DefaultDictSize
+	}
+	var nonEmpty [][]byte
+	for _, s := range samples {
+		if len(s) > 0 {
+			nonEmpty = append(nonEmpty, s)
+		}
+	}
+	if len(nonEmpty) == 0 {
+		return nil, errors.New("no sample content to train a dictionary on")
+	}
+	d, err := dict.BuildZstdDict(nonEmpty, dict.Options{MaxDictSize: size, HashBytes: 6})
+	if err != nil {
+		return nil, fmt.Errorf("training dictionary: %w", err)
+	}
+	return Dictionary(d), nil
+}
+
+// ErrWrongDictionary matches errors for a zstd object that needs a
+// dictionary other than the one it was read with.
+var ErrWrongDictionary = errors.New("wrong zstd dictionary")
+
+// DictionaryError reports an object compressed with a dictionary that was
+// not given to Decompress.
+type DictionaryError struct {
+	Want uint32 // Dictionary the object was compressed with
+	Have uint32 // Dictionary given to Decompress (0 for none)
+}
+
+// Error implements error.
+func (e *DictionaryError) Error() string {
+	if e.Have == 0 {
+		return fmt.Sprintf("object was compressed with zstd dictionary %d, but no dictionary was given", e.Want)
+	}
+	return fmt.Sprintf("object was compressed with zstd dictionary %d, not dictionary %d", e.Want, e.Have)
+}
+
+// Unwrap returns ErrWrongDictionary.
+func (e *DictionaryError) Unwrap() error {
+	return ErrWrongDictionary
+}
+
+// newZstdReader opens a zstd stream, checking the dictionary its frame
+// header asks for against dict first so a mismatch fails before any content
+// is decoded.
+func newZstdReader(r io.Reader, dict Dictionary) (io.ReadCloser, error) {
+	br := bufio.NewReader(r)
+	// A short peek just leaves the check to the decoder
+	if head, _ := br.Peek(zstd.HeaderMaxSize); len(head) > 0 {
+		var h zstd.Header
+		if h.Decode(head) == nil && h.DictionaryID != 0 && h.DictionaryID != dict.ID() {
+			return nil, &DictionaryError{Want: h.DictionaryID, Have: dict.ID()}
+		}
+	}
+
+	opts := []zstd.DOption{zstd.WithDecoderConcurrency(1)}
+	if dict != nil {
+		opts = append(opts, zstd.WithDecoderDicts(dict))
+	}
+	zr, err := zstd.NewReader(br, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("opening zstd stream: %w", err)
+	}
+	return zr.IOReadCloser(), nil
+}
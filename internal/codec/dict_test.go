@@ -0,0 +1,133 @@
+package codec
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+// trainTestDict trains a dictionary on transcript-like lines, offsetting
+// their content by seed so two dictionaries differ.
+func trainTestDict(t *testing.T, seed int) Dictionary {
+	t.Helper()
+	var samples [][]byte
+	for i := range 50 {
+		var b strings.Builder
+		for j := range 10 {
+			fmt.Fprintf(&b, `{"type":"assistant","sessionId":"s%d","message":{"role":"assistant","content":[{"type":"text","text":"step %d of %d"}]}}`+"\n", seed+i, j, seed)
+		}
+		samples = append(samples, []byte(b.String()))
+	}
+	dict, err := TrainDictionary(samples, 4096)
+	if err != nil {
+		t.Fatalf("TrainDictionary failed: %v", err)
+	}
+	if dict.ID() == 0 {
+		t.Fatal("trained dictionary has no ID")
+	}
+	return dict
+}
+
+func compressAll(t *testing.T, c Codec, dict Dictionary, data []byte) []byte {
+	t.Helper()
+	compressed, err := Compress(c, 0, dict, bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("Compress failed: %v", err)
+	}
+	defer compressed.Close()
+	encoded, err := io.ReadAll(compressed)
+	if err != nil {
+		t.Fatalf("reading compressed: %v", err)
+	}
+	return encoded
+}
+
+func TestDictionaryRoundTrip(t *testing.T) {
+	dict := trainTestDict(t, 1)
+	data := []byte(`{"type":"assistant","sessionId":"s7","message":{"role":"assistant","content":[{"type":"text","text":"step 3 of 1"}]}}` + "\n")
+
+	plain := compressAll(t, Zstd, nil, data)
+	withDict := compressAll(t, Zstd, dict, data)
+	if len(withDict) >= len(plain) {
+		t.Errorf("size with dictionary = %d, want less than %d without", len(withDict), len(plain))
+	}
+
+	for _, tt := range []struct {
+		name    string
+		encoded []byte
+		dict    Dictionary
+	}{
+		{"without dictionary", plain, nil},
+		{"with dictionary", withDict, dict},
+		// A dictionary on read doesn't matter for objects compressed without one
+		{"plain object read with dictionary", plain, dict},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			rc, err := Decompress(Zstd, tt.dict, bytes.NewReader(tt.encoded))
+			if err != nil {
+				t.Fatalf("Decompress failed: %v", err)
+			}
+			defer rc.Close()
+			decoded, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatalf("reading decompressed: %v", err)
+			}
+			if !bytes.Equal(decoded, data) {
+				t.Error("round trip altered content")
+			}
+		})
+	}
+}
+
+func TestDictionaryMismatch(t *testing.T) {
+	dict := trainTestDict(t, 1)
+	other := trainTestDict(t, 2)
+	if dict.ID() == other.ID() {
+		t.Fatal("trained dictionaries share an ID")
+	}
+	encoded := compressAll(t, Zstd, dict, []byte(strings.Repeat(`{"type":"user"}`+"\n", 10)))
+
+	for _, tt := range []struct {
+		name string
+		dict Dictionary
+		want string
+	}{
+		{"no dictionary", nil, "no dictionary was given"},
+		{"other dictionary", other, fmt.Sprintf("not dictionary %d", other.ID())},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Decompress(Zstd, tt.dict, bytes.NewReader(encoded))
+			if !errors.Is(err, ErrWrongDictionary) {
+				t.Fatalf("Decompress error = %v, want ErrWrongDictionary", err)
+			}
+			var de *DictionaryError
+			if !errors.As(err, &de) || de.Want != dict.ID() || de.Have != tt.dict.ID() {
+				t.Errorf("error = %#v, want Want %d and Have %d", de, dict.ID(), tt.dict.ID())
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error %q does not mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseDictionary(t *testing.T) {
+	dict := trainTestDict(t, 1)
+	parsed, err := ParseDictionary(dict)
+	if err != nil {
+		t.Fatalf("ParseDictionary failed: %v", err)
+	}
+	if parsed.ID() != dict.ID() {
+		t.Errorf("ID = %d, want %d", parsed.ID(), dict.ID())
+	}
+
+	if _, err := ParseDictionary([]byte("not a dictionary")); err == nil {
+		t.Error("ParseDictionary accepted garbage")
+	}
+	if _, err := TrainDictionary([][]byte{nil, {}}, 0); err == nil {
+		t.Error("TrainDictionary accepted empty samples")
+	}
+}
@@ -0,0 +1,92 @@
+package codec
+
+import (
+	"io"
+	"time"
+)
+
+// Estimate projects the effect of compressing content without storing the
+// result: bytes in, bytes that would be stored, and the CPU time spent
+// compressing.
+type Estimate struct {
+	Codec       Codec         `json:"codec"`
+	Level       int           `json:"level,omitempty"` // Zero for the codec's default
+	Files       int           `json:"files"`
+	InputBytes  int64         `json:"input_bytes"`  // Content before compression (after redaction)
+	StoredBytes int64         `json:"stored_bytes"` // Projected object size
+	CPUTime     time.Duration `json:"cpu_time_ns"`  // Time spent in the encoder
+}
+
+// Add adds the totals of other to e.
+func (e *Estimate) Add(other Estimate) {
+	e.Files += other.Files
+	e.InputBytes += other.InputBytes
+	e.StoredBytes += other.StoredBytes
+	e.CPUTime += other.CPUTime
+}
+
+// Ratio returns stored bytes as a share of input bytes (1 for no input).
+func (e Estimate) Ratio() float64 {
+	if e.InputBytes == 0 {
+		return 1
+	}
+	return float64(e.StoredBytes) / float64(e.InputBytes)
+}
+
+// Saved returns the bytes compression would save (negative if it grows the
+// content).
+func (e Estimate) Saved() int64 {
+	return e.InputBytes - e.StoredBytes
+}
+
+// Counter is a writer that compresses its input, discards the output, and
+// counts the bytes on both sides.
+type Counter struct {
+	est Estimate
+	enc io.WriteCloser
+	out countWriter
+}
+
+// NewCounter returns a Counter for one file compressed with c at level, and
+// dict for zstd. With None, the stored size is the input size.
+func NewCounter(c Codec, level int, dict Dictionary) (*Counter, error) {
+	counter := &Counter{est: Estimate{Codec: c, Level: level, Files: 1}}
+	enc, err := NewWriter(c, level, dict, &counter.out)
+	if err != nil {
+		return nil, err
+	}
+	counter.enc = enc
+	return counter, nil
+}
+
+// Write implements io.Writer.
+func (c *Counter) Write(p []byte) (int, error) {
+	start := time.Now()
+	n, err := c.enc.Write(p)
+	c.est.CPUTime += time.Since(start)
+	c.est.InputBytes += int64(n)
+	return n, err
+}
+
+// Close flushes the encoder so the stored size is final.
+func (c *Counter) Close() error {
+	start := time.Now()
+	err := c.enc.Close()
+	c.est.CPUTime += time.Since(start)
+	return err
+}
+
+// Estimate returns the totals so far; call it after Close.
+func (c *Counter) Estimate() Estimate {
+	est := c.est
+	est.StoredBytes = c.out.n
+	return est
+}
+
+// countWriter discards its input, counting it.
+type countWriter struct{ n int64 }
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	w.n += int64(len(p))
+	return len(p), nil
+}
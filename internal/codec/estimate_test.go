@@ -0,0 +1,90 @@
+package codec
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	compressible := []byte(strings.Repeat(`{"type":"assistant","message":{"content":"some text"}}`+"\n", 2000))
+	incompressible := make([]byte, 256*1024)
+	if _, err := rand.Read(incompressible); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		codec    Codec
+		data     []byte
+		minRatio float64
+		maxRatio float64
+	}{
+		{"compressible gzip", Gzip, compressible, 0, 0.05},
+		// Random data grows slightly from gzip framing
+		{"incompressible gzip", Gzip, incompressible, 0.99, 1.01},
+		{"none", None, compressible, 1, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			counter, err := NewCounter(tt.codec, 0, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := io.Copy(counter, bytes.NewReader(tt.data)); err != nil {
+				t.Fatal(err)
+			}
+			if err := counter.Close(); err != nil {
+				t.Fatal(err)
+			}
+			est := counter.Estimate()
+
+			if est.InputBytes != int64(len(tt.data)) {
+				t.Errorf("InputBytes = %d, want %d", est.InputBytes, len(tt.data))
+			}
+			if r := est.Ratio(); r < tt.minRatio || r > tt.maxRatio {
+				t.Errorf("Ratio() = %.4f, want between %.2f and %.2f", r, tt.minRatio, tt.maxRatio)
+			}
+			if est.Saved() != est.InputBytes-est.StoredBytes {
+				t.Errorf("Saved() = %d, want %d", est.Saved(), est.InputBytes-est.StoredBytes)
+			}
+
+			// The projected size is exactly what Compress would store
+			if tt.codec == Gzip {
+				stored, err := Compress(Gzip, 0, nil, bytes.NewReader(tt.data))
+				if err != nil {
+					t.Fatal(err)
+				}
+				encoded, err := io.ReadAll(stored)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if est.StoredBytes != int64(len(encoded)) {
+					t.Errorf("StoredBytes = %d, Compress stored %d", est.StoredBytes, len(encoded))
+				}
+			}
+		})
+	}
+}
+
+func TestEstimateAdd(t *testing.T) {
+	var total Estimate
+	total.Add(Estimate{Files: 1, InputBytes: 1000, StoredBytes: 100})
+	total.Add(Estimate{Files: 1, InputBytes: 1000, StoredBytes: 1000})
+
+	if total.Files != 2 || total.InputBytes != 2000 || total.StoredBytes != 1100 {
+		t.Errorf("total = %+v, want 2 files, 2000 in, 1100 stored", total)
+	}
+	if r := total.Ratio(); r != 0.55 {
+		t.Errorf("Ratio() = %v, want 0.55", r)
+	}
+	if s := total.Saved(); s != 900 {
+		t.Errorf("Saved() = %d, want 900", s)
+	}
+	if r := (Estimate{}).Ratio(); r != 1 {
+		t.Errorf("Ratio() without input = %v, want 1", r)
+	}
+}
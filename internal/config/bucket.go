@@ -0,0 +1,114 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+)
+
+// placeholderBucket is the bucket name in the starter config. It is left to
+// doctor to report, with a clearer message than a naming-rule failure.
+const placeholderBucket = "YOUR-BUCKET-NAME"
+
+// bucketNamePattern matches S3 general purpose bucket naming rules:
+// 3-63 lowercase letters, digits, dots, and hyphens, starting and ending
+// with a letter or digit.
+var bucketNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9.-]{1,61}[a-z0-9]$`)
+
+// AccessPoint describes an S3 access point ARN used in place of a bucket name.
+type AccessPoint struct {
+	Partition   string // e.g. "aws", "aws-cn"
+	Region      string // Empty for multi-region access points
+	Account     string
+	Name        string // Access point name, or the alias for multi-region access points
+	MultiRegion bool
+}
+
+// ParseAccessPointARN parses an access point ARN of the form
+// arn:<partition>:s3:<region>:<account>:accesspoint/<name>. The second
+// result is false if s is not an access point ARN.
+func ParseAccessPointARN(s string) (AccessPoint, bool) {
+	parts := strings.SplitN(s, ":", 6)
+	if len(parts) != 6 || parts[0] != "arn" || parts[2] != "s3" {
+		return AccessPoint{}, false
+	}
+
+	resource := parts[5]
+	var name string
+	switch {
+	case strings.HasPrefix(resource, "accesspoint/"):
+		name = strings.TrimPrefix(resource, "accesspoint/")
+	case strings.HasPrefix(resource, "accesspoint:"):
+		name = strings.TrimPrefix(resource, "accesspoint:")
+	default:
+		return AccessPoint{}, false
+	}
+	if name == "" || strings.ContainsAny(name, "/:") {
+		return AccessPoint{}, false
+	}
+
+	return AccessPoint{
+		Partition:   parts[1],
+		Region:      parts[3],
+		Account:     parts[4],
+		Name:        name,
+		MultiRegion: parts[3] == "",
+	}, true
+}
+
+// IsARN reports whether bucket looks like an ARN rather than a bucket name.
+func IsARN(bucket string) bool {
+	return strings.HasPrefix(bucket, "arn:")
+}
+
+// BucketDisplayName returns a short name for bucket suitable for output:
+// the access point name for ARNs, or the bucket name unchanged.
+func BucketDisplayName(bucket string) string {
+	ap, ok := ParseAccessPointARN(bucket)
+	if !ok {
+		return bucket
+	}
+	if ap.MultiRegion {
+		return ap.Name + " (multi-region access point)"
+	}
+	return ap.Name + " (access point, " + ap.Region + ")"
+}
+
+// validateBucket checks s3.bucket. Access point ARNs bypass the naming rules
+// and are passed to the SDK as-is; plain names must follow S3 naming rules
+// unless a custom endpoint (with its own rules) is configured.
+func validateBucket(bucket, endpoint string, forcePathStyle bool) error {
+	if IsARN(bucket) {
+		ap, ok := ParseAccessPointARN(bucket)
+		if !ok {
+			return fmt.Errorf("unsupported ARN %q (only S3 access point ARNs are supported)", bucket)
+		}
+		if endpoint != "" {
+			return fmt.Errorf("access point ARNs cannot be combined with s3.endpoint")
+		}
+		if forcePathStyle {
+			return fmt.Errorf("access point ARNs do not support s3.force_path_style")
+		}
+		if ap.MultiRegion && ap.Partition != "aws" {
+			return fmt.Errorf("multi-region access points are not supported in partition %q", ap.Partition)
+		}
+		return nil
+	}
+
+	if bucket == placeholderBucket || endpoint != "" {
+		return nil
+	}
+
+	if !bucketNamePattern.MatchString(bucket) {
+		return fmt.Errorf("invalid bucket name %q: use 3-63 lowercase letters, digits, dots, and hyphens, starting and ending with a letter or digit", bucket)
+	}
+	if strings.Contains(bucket, "..") {
+		return fmt.Errorf("invalid bucket name %q: must not contain consecutive dots", bucket)
+	}
+	if net.ParseIP(bucket) != nil {
+		return fmt.Errorf("invalid bucket name %q: must not be formatted as an IP address", bucket)
+	}
+
+	return nil
+}
@@ -0,0 +1,121 @@
+package config
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseAccessPointARN(t *testing.T) {
+	tests := []struct {
+		name   string
+		arn    string
+		want   AccessPoint
+		wantOK bool
+	}{
+		{
+			name:   "access point",
+			arn:    "arn:aws:s3:us-west-2:123456789012:accesspoint/logs-ap",
+			want:   AccessPoint{Partition: "aws", Region: "us-west-2", Account: "123456789012", Name: "logs-ap"},
+			wantOK: true,
+		},
+		{
+			name:   "colon resource separator",
+			arn:    "arn:aws-cn:s3:cn-north-1:123456789012:accesspoint:logs-ap",
+			want:   AccessPoint{Partition: "aws-cn", Region: "cn-north-1", Account: "123456789012", Name: "logs-ap"},
+			wantOK: true,
+		},
+		{
+			name:   "multi-region access point",
+			arn:    "arn:aws:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap",
+			want:   AccessPoint{Partition: "aws", Account: "123456789012", Name: "mfzwi23gnjvgw.mrap", MultiRegion: true},
+			wantOK: true,
+		},
+		{name: "plain bucket", arn: "my-bucket"},
+		{name: "bucket ARN", arn: "arn:aws:s3:::my-bucket"},
+		{name: "outposts ARN", arn: "arn:aws:s3-outposts:us-west-2:123456789012:outpost/op-1/accesspoint/ap"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseAccessPointARN(tt.arn)
+			if ok != tt.wantOK {
+				t.Fatalf("ParseAccessPointARN() ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got != tt.want {
+				t.Errorf("ParseAccessPointARN() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateBucket(t *testing.T) {
+	tests := []struct {
+		name           string
+		bucket         string
+		endpoint       string
+		forcePathStyle bool
+		errMsg         string
+	}{
+		{name: "valid name", bucket: "my-logs.2025"},
+		{name: "placeholder left for doctor", bucket: placeholderBucket},
+		{name: "uppercase", bucket: "MyBucket", errMsg: "invalid bucket name"},
+		{name: "too short", bucket: "ab", errMsg: "invalid bucket name"},
+		{name: "consecutive dots", bucket: "my..bucket", errMsg: "consecutive dots"},
+		{name: "ip address", bucket: "192.168.1.1", errMsg: "IP address"},
+		{name: "custom endpoint relaxes rules", bucket: "MyBucket", endpoint: "https://minio.local"},
+		{name: "access point bypasses naming rules", bucket: "arn:aws:s3:us-west-2:123456789012:accesspoint/Logs_AP"},
+		{name: "multi-region access point", bucket: "arn:aws:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap"},
+		{name: "bucket ARN", bucket: "arn:aws:s3:::my-bucket", errMsg: "only S3 access point ARNs"},
+		{
+			name:     "access point with endpoint",
+			bucket:   "arn:aws:s3:us-west-2:123456789012:accesspoint/ap",
+			endpoint: "https://minio.local",
+			errMsg:   "cannot be combined with s3.endpoint",
+		},
+		{
+			name:           "access point with path style",
+			bucket:         "arn:aws:s3:us-west-2:123456789012:accesspoint/ap",
+			forcePathStyle: true,
+			errMsg:         "force_path_style",
+		},
+		{
+			name:   "multi-region access point outside aws partition",
+			bucket: "arn:aws-cn:s3::123456789012:accesspoint/x.mrap",
+			errMsg: "multi-region access points are not supported",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateBucket(tt.bucket, tt.endpoint, tt.forcePathStyle)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("error = %v, want containing %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestBucketDisplayName(t *testing.T) {
+	tests := []struct {
+		bucket string
+		want   string
+	}{
+		{bucket: "my-bucket", want: "my-bucket"},
+		{bucket: "arn:aws:s3:us-west-2:123456789012:accesspoint/logs-ap", want: "logs-ap (access point, us-west-2)"},
+		{bucket: "arn:aws:s3::123456789012:accesspoint/mfzwi23gnjvgw.mrap", want: "mfzwi23gnjvgw.mrap (multi-region access point)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.bucket, func(t *testing.T) {
+			if got := BucketDisplayName(tt.bucket); got != tt.want {
+				t.Errorf("BucketDisplayName() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
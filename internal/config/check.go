@@ -0,0 +1,76 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"os"
+	"slices"
+
+	"github.com/13rac1/cclogs/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Check loads the config at path like Load, or LoadStrict if strict is set,
+// but instead of stopping at the first problem it returns every unknown key
+// and malformed s3 value, followed by the first other validation error. A
+// valid config has none. Unlike Load, Check never writes the state file.
+func Check(path string, strict bool) []error {
+	expandedPath, err := expandTilde(path)
+	if err != nil {
+		return []error{fmt.Errorf("expanding config path: %w", err)}
+	}
+	data, err := os.ReadFile(expandedPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return []error{fmt.Errorf("%w: %w", ErrConfigNotFound, err)}
+	}
+	if err != nil {
+		return []error{fmt.Errorf("reading config file %s: %w", expandedPath, err)}
+	}
+
+	var problems []error
+	var cfg types.Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(strict)
+	if err := dec.Decode(&cfg); err != nil && !errors.Is(err, io.EOF) {
+		var typeErr *yaml.TypeError
+		if !errors.As(err, &typeErr) {
+			return []error{fmt.Errorf("parsing config YAML: %w", err)}
+		}
+		// The rest of the document still decodes, so keep checking it
+		for _, e := range typeErr.Errors {
+			problems = append(problems, describeYAMLError(&yaml.TypeError{Errors: []string{e}}))
+		}
+	}
+
+	if err := applyEnv(&cfg, os.LookupEnv); err != nil {
+		return append(problems, fmt.Errorf("applying environment overrides: %w", err))
+	}
+	if err := applyDefaults(&cfg); err != nil {
+		return append(problems, fmt.Errorf("applying defaults: %w", err))
+	}
+
+	problems = append(problems, s3ValueErrors(&cfg.S3)...)
+	for _, name := range slices.Sorted(maps.Keys(cfg.Targets)) {
+		c := cfg
+		c.S3, c.Auth, c.Targets = cfg.Targets[name].S3, cfg.Targets[name].Auth, nil
+		if applyDefaults(&c) != nil {
+			continue
+		}
+		for _, err := range s3ValueErrors(&c.S3) {
+			problems = append(problems, fmt.Errorf("targets.%s: %w", name, err))
+		}
+	}
+
+	err = validate(&cfg)
+	if err == nil {
+		err = resolveTargets(&cfg)
+	}
+	if err != nil && !slices.ContainsFunc(problems, func(p error) bool { return p.Error() == err.Error() }) {
+		problems = append(problems, err)
+	}
+	return problems
+}
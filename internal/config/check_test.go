@@ -0,0 +1,88 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		strict  bool
+		want    []string
+	}{
+		{
+			name:    "valid",
+			content: "s3:\n  bucket: logs\n  region: us-west-2\n",
+		},
+		{
+			name: "every malformed value",
+			content: `s3:
+  bucket: Logs_Bucket
+  region: us west
+  prefix: /claude-code
+  end_point: https://minio.local
+`,
+			strict: true,
+			want: []string{
+				`line 5: unknown key "s3.end_point"`,
+				`s3.bucket: invalid bucket name "Logs_Bucket"`,
+				`s3.region: invalid region "us west"`,
+				`s3.prefix must not start with "/", got "/claude-code/"`,
+			},
+		},
+		{
+			name:    "unknown keys ignored without strict",
+			content: "s3:\n  bucket: logs\n  region: us-west-2\n  end_point: https://minio.local\n",
+		},
+		{
+			name: "other validation error after value problems",
+			content: `s3:
+  region: us-west-2
+  endpoint: minio.local:9000
+targets:
+  personal:
+    s3:
+      bucket: personal
+      region: us-west-004
+      endpoint: ftp://b2
+`,
+			want: []string{
+				`s3.endpoint: "minio.local:9000" is not an http or https URL`,
+				`targets.personal: s3.endpoint: "ftp://b2" is not an http or https URL`,
+				"s3.bucket is required",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			problems := Check(path, tt.strict)
+			if len(problems) != len(tt.want) {
+				t.Fatalf("Check() = %v, want %d problems", problems, len(tt.want))
+			}
+			for i, want := range tt.want {
+				if got := problems[i].Error(); !strings.HasPrefix(got, want) {
+					t.Errorf("problem %d = %q, want prefix %q", i, got, want)
+				}
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(path), "state.json")); !os.IsNotExist(err) {
+				t.Errorf("Check wrote the state file: %v", err)
+			}
+		})
+	}
+
+	problems := Check(filepath.Join(t.TempDir(), "missing.yaml"), false)
+	if len(problems) != 1 || !errors.Is(problems[0], ErrConfigNotFound) {
+		t.Errorf("Check(missing) = %v, want ErrConfigNotFound", problems)
+	}
+}
@@ -1,11 +1,16 @@
 package config
 
 import (
+	"errors"
+	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/13rac1/cclogs/internal/identity"
 	"github.com/13rac1/cclogs/internal/types"
 )
 
@@ -45,8 +50,759 @@ s3:
 				if cfg.Local.ProjectsRoot != expectedRoot {
 					t.Errorf("projects_root = %q, want %q", cfg.Local.ProjectsRoot, expectedRoot)
 				}
+				if cfg.S3.OperationTimeout != 60*time.Second {
+					t.Errorf("operation_timeout = %v, want %v", cfg.S3.OperationTimeout, 60*time.Second)
+				}
+				if cfg.S3.ContentType != "application/x-ndjson" {
+					t.Errorf("content_type = %q, want application/x-ndjson", cfg.S3.ContentType)
+				}
+				if !slices.Equal(cfg.Local.Extensions, []string{".jsonl"}) {
+					t.Errorf("extensions = %q, want [.jsonl]", cfg.Local.Extensions)
+				}
+				if cfg.S3.ManifestKey != ".manifest.json" {
+					t.Errorf("manifest_key = %q, want .manifest.json", cfg.S3.ManifestKey)
+				}
+				if cfg.S3.ManifestHistory != 5 {
+					t.Errorf("manifest_history = %d, want 5", cfg.S3.ManifestHistory)
+				}
+			},
+		},
+		{
+			name: "custom operation timeout",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  operation_timeout: 2m30s
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *types.Config) {
+				if cfg.S3.OperationTimeout != 150*time.Second {
+					t.Errorf("operation_timeout = %v, want %v", cfg.S3.OperationTimeout, 150*time.Second)
+				}
+			},
+		},
+		{
+			name: "negative operation timeout",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  operation_timeout: -1s
+`,
+			wantErr: true,
+			errMsg:  "s3.operation_timeout must not be negative",
+		},
+		{
+			name: "content headers",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  content_type: "application/jsonl; charset=utf-8"
+  cache_control: "private, max-age=86400"
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *types.Config) {
+				if cfg.S3.ContentType != "application/jsonl; charset=utf-8" {
+					t.Errorf("content_type = %q", cfg.S3.ContentType)
+				}
+				if cfg.S3.CacheControl != "private, max-age=86400" {
+					t.Errorf("cache_control = %q", cfg.S3.CacheControl)
+				}
+			},
+		},
+		{
+			name: "custom env keywords",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+redact:
+  env_keywords: ["TOKEN", "DSN"]
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *types.Config) {
+				if strings.Join(cfg.Redact.EnvKeywords, ",") != "TOKEN,DSN" {
+					t.Errorf("env_keywords = %v", cfg.Redact.EnvKeywords)
+				}
+			},
+		},
+		{
+			name: "invalid env keyword",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+redact:
+  env_keywords: ["API KEY"]
+`,
+			wantErr: true,
+			errMsg:  "redact.env_keywords",
+		},
+		{
+			name: "unknown disabled pattern",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+redact:
+  disable: ["IP", "ADDRESS"]
+`,
+			wantErr: true,
+			errMsg:  `redact.disable: unknown pattern "ADDRESS"`,
+		},
+		{
+			name: "invalid allowlist regex",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+redact:
+  allowlist: ["build@ci.internal", "/(unclosed/"]
+`,
+			wantErr: true,
+			errMsg:  "redact.allowlist: entry /(unclosed/",
+		},
+		{
+			name: "gzip level out of range",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+upload:
+  compress: gzip
+  compress_level: 12
+`,
+			wantErr: true,
+			errMsg:  "upload.compress_level: gzip level must be between 1 and 9",
+		},
+		{
+			name: "negative discovery concurrency",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+discovery:
+  concurrency: -1
+`,
+			wantErr: true,
+			errMsg:  "discovery.concurrency must not be negative",
+		},
+		{
+			name: "project depth too deep",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+local:
+  project_depth: 4
+`,
+			wantErr: true,
+			errMsg:  "local.project_depth must be between 1 and 3, got 4",
+		},
+		{
+			name: "project depth with marker",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+local:
+  project_depth: 2
+  project_marker: .claude-project
+`,
+			wantErr: true,
+			errMsg:  "local.project_depth and local.project_marker cannot be combined",
+		},
+		{
+			name: "OTLP endpoint without scheme",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+telemetry:
+  otlp_endpoint: localhost:4318
+`,
+			wantErr: true,
+			errMsg:  "telemetry.otlp_endpoint must be an http or https URL",
+		},
+		{
+			name: "webhook URL without scheme",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+notify:
+  webhook_url: hooks.example.com/abc
+`,
+			wantErr: true,
+			errMsg:  "notify.webhook_url must be an http or https URL",
+		},
+		{
+			name: "unknown notify filter",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+notify:
+  webhook_url: https://hooks.example.com/abc
+  on: sometimes
+`,
+			wantErr: true,
+			errMsg:  "notify.on must be always, changes, or failure",
+		},
+		{
+			name: "invalid sse-c key",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  sse_c_key: "c2hvcnQ="
+`,
+			wantErr: true,
+			errMsg:  "s3.sse_c_key: must be a base64-encoded 32-byte key or a file holding one",
+		},
+		{
+			name: "sse-c key over plain http",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  endpoint: http://minio.local:9000
+  force_path_style: true
+  sse_c_key: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+`,
+			wantErr: true,
+			errMsg:  "s3.sse_c_key: requires an https endpoint",
+		},
+		{
+			name: "unknown redaction mode",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+redact:
+  mode: hash
+`,
+			wantErr: true,
+			errMsg:  "redact.mode must be placeholder or mask",
+		},
+		{
+			name: "multi-character mask char",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+redact:
+  mode: mask
+  mask_char: "**"
+`,
+			wantErr: true,
+			errMsg:  "redact.mask_char must be a single character",
+		},
+		{
+			name: "local extensions normalized",
+			content: `
+local:
+  extensions: [".JSONL", "ndjson", " *.log"]
+s3:
+  bucket: test-bucket
+  region: us-west-2
+`,
+			validate: func(t *testing.T, cfg *types.Config) {
+				want := []string{".jsonl", ".ndjson", ".log"}
+				if !slices.Equal(cfg.Local.Extensions, want) {
+					t.Errorf("extensions = %q, want %q", cfg.Local.Extensions, want)
+				}
+			},
+		},
+		{
+			name: "invalid local extension",
+			content: `
+local:
+  extensions: [".jsonl", "."]
+s3:
+  bucket: test-bucket
+  region: us-west-2
+`,
+			wantErr: true,
+			errMsg:  `local.extensions: invalid extension "."`,
+		},
+		{
+			name: "custom manifest key",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  manifest_key: " /cclogs-manifest-v2.json"
+`,
+			validate: func(t *testing.T, cfg *types.Config) {
+				if cfg.S3.ManifestKey != "cclogs-manifest-v2.json" {
+					t.Errorf("manifest_key = %q, want cclogs-manifest-v2.json", cfg.S3.ManifestKey)
+				}
+			},
+		},
+		{
+			name: "manifest key with a path",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  manifest_key: "meta/manifest.json"
+`,
+			wantErr: true,
+			errMsg:  `s3.manifest_key: must be a file name under the prefix, got "meta/manifest.json"`,
+		},
+		{
+			name: "manifest key with a log extension",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  manifest_key: "manifest.jsonl"
+`,
+			wantErr: true,
+			errMsg:  "s3.manifest_key: \"manifest.jsonl\" has a session log extension",
+		},
+		{
+			name: "invalid content type",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  content_type: "not a media type"
+`,
+			wantErr: true,
+			errMsg:  "s3.content_type: invalid media type",
+		},
+		{
+			name: "multi-line cache control",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  cache_control: "no-cache\r\nX-Injected: 1"
+`,
+			wantErr: true,
+			errMsg:  "s3.cache_control must be a single line",
+		},
+		{
+			name: "object lock",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  object_lock:
+    mode: governance
+    retain_days: 365
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *types.Config) {
+				if cfg.S3.ObjectLock.Mode != ObjectLockGovernance || cfg.S3.ObjectLock.RetainDays != 365 {
+					t.Errorf("object_lock = %+v", cfg.S3.ObjectLock)
+				}
+			},
+		},
+		{
+			name: "object lock unknown mode",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  object_lock:
+    mode: legal_hold
+    retain_days: 30
+`,
+			wantErr: true,
+			errMsg:  "s3.object_lock: mode must be GOVERNANCE or COMPLIANCE",
+		},
+		{
+			name: "object lock without retention period",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  object_lock:
+    mode: COMPLIANCE
+`,
+			wantErr: true,
+			errMsg:  "s3.object_lock: retain_days must be at least 1",
+		},
+		{
+			name: "object lock retention without mode",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  object_lock:
+    retain_days: 30
+`,
+			wantErr: true,
+			errMsg:  "s3.object_lock: retain_days needs a mode",
+		},
+		{
+			name: "assume role",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+auth:
+  profile: work
+  role_arn: arn:aws:iam::123456789012:role/cclogs-uploader
+  external_id: team-logs
+  role_session_name: alice@laptop
+`,
+			validate: func(t *testing.T, cfg *types.Config) {
+				if cfg.Auth.RoleARN != "arn:aws:iam::123456789012:role/cclogs-uploader" || cfg.Auth.ExternalID != "team-logs" {
+					t.Errorf("auth = %+v", cfg.Auth)
+				}
+			},
+		},
+		{
+			name: "role arn of a user",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+auth:
+  role_arn: arn:aws:iam::123456789012:user/alice
+`,
+			wantErr: true,
+			errMsg:  "auth.role_arn must be an IAM role ARN",
+		},
+		{
+			name: "external id without role",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+auth:
+  external_id: team-logs
+`,
+			wantErr: true,
+			errMsg:  "auth.external_id and auth.role_session_name need auth.role_arn",
+		},
+		{
+			name: "role session name with spaces",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+auth:
+  role_arn: arn:aws:iam::123456789012:role/cclogs
+  role_session_name: my laptop
+`,
+			wantErr: true,
+			errMsg:  "auth.role_session_name must be 2-64",
+		},
+		{
+			name: "by_host layout with machine id",
+			content: `
+local:
+  machine_id: "Work Laptop"
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  key_layout: by_host
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *types.Config) {
+				if cfg.Local.MachineID != "Work-Laptop" {
+					t.Errorf("machine_id = %q, want %q", cfg.Local.MachineID, "Work-Laptop")
+				}
+				if got := KeyPrefix(cfg); got != "claude-code/Work-Laptop/" {
+					t.Errorf("KeyPrefix() = %q, want %q", got, "claude-code/Work-Laptop/")
+				}
+			},
+		},
+		{
+			name: "by_host layout defaults to generated machine id",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  key_layout: by_host
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *types.Config) {
+				if cfg.Identity.Source != identity.SourceState || cfg.Local.MachineID != cfg.Identity.ID {
+					t.Errorf("machine_id = %q (identity %+v), want generated ID", cfg.Local.MachineID, cfg.Identity)
+				}
+				if got, want := KeyPrefix(cfg), "claude-code/"+cfg.Identity.ID+"/"; got != want {
+					t.Errorf("KeyPrefix() = %q, want %q", got, want)
+				}
+			},
+		},
+		{
+			name: "flat layout ignores machine id",
+			content: `
+local:
+  machine_id: laptop
+s3:
+  bucket: test-bucket
+  region: us-west-2
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *types.Config) {
+				if cfg.S3.KeyLayout != "flat" {
+					t.Errorf("key_layout = %q, want %q", cfg.S3.KeyLayout, "flat")
+				}
+				if got := KeyPrefix(cfg); got != "claude-code/" {
+					t.Errorf("KeyPrefix() = %q, want %q", got, "claude-code/")
+				}
+			},
+		},
+		{
+			name: "missing CA bundle",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  ca_bundle: /nonexistent/ca.pem
+`,
+			wantErr: true,
+			errMsg:  "s3.ca_bundle",
+		},
+		{
+			name: "access point ARN as bucket",
+			content: `
+s3:
+  bucket: "arn:aws:s3:us-west-2:123456789012:accesspoint/logs-ap"
+  region: us-west-2
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *types.Config) {
+				if !IsARN(cfg.S3.Bucket) {
+					t.Errorf("bucket = %q, want ARN passed through", cfg.S3.Bucket)
+				}
+			},
+		},
+		{
+			name: "invalid bucket name",
+			content: `
+s3:
+  bucket: My_Bucket
+  region: us-west-2
+`,
+			wantErr: true,
+			errMsg:  "s3.bucket: invalid bucket name",
+		},
+		{
+			name: "invalid key layout",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  key_layout: nested
+`,
+			wantErr: true,
+			errMsg:  "s3.key_layout must be",
+		},
+		{
+			name: "custom key template",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  key_template: "claude/{year}/{month}/{project}/{filename}"
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *types.Config) {
+				if cfg.S3.KeyTemplate != "claude/{year}/{month}/{project}/{filename}" {
+					t.Errorf("key_template = %q", cfg.S3.KeyTemplate)
+				}
+			},
+		},
+		{
+			name: "key template defaults to layout",
+			content: `
+local:
+  machine_id: laptop
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  key_layout: by_host
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *types.Config) {
+				if cfg.S3.KeyTemplate != ByHostKeyTemplate {
+					t.Errorf("key_template = %q, want %q", cfg.S3.KeyTemplate, ByHostKeyTemplate)
+				}
+			},
+		},
+		{
+			name: "key template without uniqueness component",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  key_template: "{prefix}{year}/{month}/{project}"
+`,
+			wantErr: true,
+			errMsg:  "s3.key_template: must include {path} or {filename}",
+		},
+		{
+			name: "by_host key template without host",
+			content: `
+local:
+  machine_id: laptop
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  key_layout: by_host
+  key_template: "{prefix}{project}/{path}"
+`,
+			wantErr: true,
+			errMsg:  "must include {host}",
+		},
+		{
+			name: "spool defaults follow part size",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+upload:
+  part_size: 16MiB
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *types.Config) {
+				if cfg.Upload.Spool != "auto" {
+					t.Errorf("spool = %q, want %q", cfg.Upload.Spool, "auto")
+				}
+				if cfg.Upload.SpoolThreshold != 16*1024*1024 {
+					t.Errorf("spool_threshold = %s, want 16MiB", cfg.Upload.SpoolThreshold)
+				}
+				if cfg.Upload.SpoolMemory != 8*1024*1024 {
+					t.Errorf("spool_memory = %s, want 8MiB", cfg.Upload.SpoolMemory)
+				}
+			},
+		},
+		{
+			name: "invalid spool mode",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+upload:
+  spool: tape
+`,
+			wantErr: true,
+			errMsg:  "upload.spool must be auto, memory, or disk",
+		},
+		{
+			name: "gzip compression",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+upload:
+  compress: gzip
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *types.Config) {
+				if cfg.Upload.Compress != "gzip" {
+					t.Errorf("compress = %q, want %q", cfg.Upload.Compress, "gzip")
+				}
+			},
+		},
+		{
+			name: "unsupported compression",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+upload:
+  compress: bzip2
+`,
+			wantErr: true,
+			errMsg:  `upload.compress: unknown codec "bzip2"`,
+		},
+		{
+			name: "zstd level out of range",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+upload:
+  compress: zstd
+  compress_level: 23
+`,
+			wantErr: true,
+			errMsg:  "zstd level must be between 1 and 22",
+		},
+		{
+			name: "upload tuning with unit suffix",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+upload:
+  part_size: 16MiB
+  part_concurrency: 8
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *types.Config) {
+				if cfg.Upload.PartSize != 16*1024*1024 {
+					t.Errorf("part_size = %d, want %d", cfg.Upload.PartSize, 16*1024*1024)
+				}
+				if cfg.Upload.PartConcurrency != 8 {
+					t.Errorf("part_concurrency = %d, want 8", cfg.Upload.PartConcurrency)
+				}
+			},
+		},
+		{
+			name: "upload defaults",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *types.Config) {
+				if cfg.Upload.PartSize != 5*1024*1024 {
+					t.Errorf("part_size = %d, want %d", cfg.Upload.PartSize, 5*1024*1024)
+				}
+				if cfg.Upload.PartConcurrency != 5 {
+					t.Errorf("part_concurrency = %d, want 5", cfg.Upload.PartConcurrency)
+				}
 			},
 		},
+		{
+			name: "part size below S3 minimum",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+upload:
+  part_size: 1MiB
+`,
+			wantErr: true,
+			errMsg:  "upload.part_size must be between 5MiB and 5GiB",
+		},
+		{
+			name: "part concurrency too high",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+upload:
+  part_concurrency: 1000
+`,
+			wantErr: true,
+			errMsg:  "upload.part_concurrency must be between 1 and 64",
+		},
+		{
+			name: "invalid part size",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+upload:
+  part_size: lots
+`,
+			wantErr: true,
+			errMsg:  "invalid size",
+		},
 		{
 			name: "custom prefix without trailing slash",
 			content: `
@@ -157,6 +913,48 @@ s3:
 			wantErr: true,
 			errMsg:  "s3.region is required",
 		},
+		{
+			name: "malformed region",
+			content: `
+s3:
+  bucket: test-bucket
+  region: US West 2
+`,
+			wantErr: true,
+			errMsg:  `s3.region: invalid region "US West 2"`,
+		},
+		{
+			name: "availability zone as region",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2a
+`,
+			wantErr: true,
+			errMsg:  `s3.region: "us-west-2a" is an availability zone, not a region; use "us-west-2"`,
+		},
+		{
+			name: "endpoint without scheme",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-004
+  endpoint: s3.us-west-004.backblazeb2.com
+`,
+			wantErr: true,
+			errMsg:  `s3.endpoint: "s3.us-west-004.backblazeb2.com" is not an http or https URL`,
+		},
+		{
+			name: "prefix with leading slash",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  prefix: /claude-code
+`,
+			wantErr: true,
+			errMsg:  `s3.prefix must not start with "/"`,
+		},
 		{
 			name:    "invalid YAML",
 			content: `invalid: yaml: content:`,
@@ -215,8 +1013,32 @@ func TestLoadNonexistentFile(t *testing.T) {
 	if err == nil {
 		t.Error("Load() error = nil, want error for nonexistent file")
 	}
-	if !strings.Contains(err.Error(), "reading config file") {
-		t.Errorf("Load() error = %q, want error containing 'reading config file'", err.Error())
+	if !errors.Is(err, ErrConfigNotFound) || !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Load() error = %v, want ErrConfigNotFound and fs.ErrNotExist", err)
+	}
+	if !strings.Contains(err.Error(), "/nonexistent/config.yaml") {
+		t.Errorf("Load() error = %q, want the path", err.Error())
+	}
+}
+
+func TestLoadBucketNotConfigured(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "top level", content: "s3:\n  region: us-west-2\n"},
+		{name: "target", content: "s3:\n  bucket: logs\n  region: us-west-2\ntargets:\n  personal:\n    s3:\n      region: us-west-2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); !errors.Is(err, ErrBucketNotConfigured) {
+				t.Errorf("Load() error = %v, want ErrBucketNotConfigured", err)
+			}
+		})
 	}
 }
 
@@ -356,3 +1178,151 @@ func TestCreateStarterConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadStrict(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		errMsg  string // Empty if both modes succeed
+	}{
+		{
+			name: "known keys only",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+`,
+		},
+		{
+			name: "misspelled section key",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  regionn: us-east-1
+`,
+			errMsg: `line 5: unknown key "s3.regionn"`,
+		},
+		{
+			name: "unknown top-level key",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+uplaod:
+  compress: gzip
+`,
+			errMsg: `line 5: unknown key "uplaod"`,
+		},
+		{
+			name: "misspelled nested key",
+			content: `
+s3:
+  bucket: test-bucket
+  region: us-west-2
+  object_lock:
+    retain_dayz: 30
+`,
+			errMsg: `line 6: unknown key "s3.object_lock.retain_dayz"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			// Lenient loading keeps ignoring unknown keys
+			if _, err := Load(path); err != nil {
+				t.Errorf("Load() error = %v, want nil", err)
+			}
+
+			_, err := LoadStrict(path)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("LoadStrict() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("LoadStrict() error = %v, want containing %q", err, tt.errMsg)
+			}
+		})
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	root := t.TempDir()
+
+	tests := []struct {
+		name string
+		cfg  types.Config
+		want []string // Substrings, one per expected warning
+	}{
+		{
+			name: "clean config",
+			cfg:  types.Config{Local: types.LocalConfig{ProjectsRoot: root}},
+		},
+		{
+			name: "missing projects root",
+			cfg:  types.Config{Local: types.LocalConfig{ProjectsRoot: filepath.Join(root, "missing")}},
+			want: []string{"local.projects_root"},
+		},
+		{
+			name: "insecure and half-set credentials",
+			cfg: types.Config{
+				Local: types.LocalConfig{ProjectsRoot: root},
+				S3:    types.S3Config{InsecureSkipVerify: true},
+				Auth:  types.AuthConfig{AccessKeyID: "AKID", Profile: "work"},
+			},
+			want: []string{"insecure_skip_verify", "set together", "auth.profile is ignored"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Warnings(&tt.cfg)
+			if len(got) != len(tt.want) {
+				t.Fatalf("Warnings() = %q, want %d warnings", got, len(tt.want))
+			}
+			for i, w := range tt.want {
+				if !strings.Contains(got[i], w) {
+					t.Errorf("warning %d = %q, want containing %q", i, got[i], w)
+				}
+			}
+		})
+	}
+}
+
+func TestMasked(t *testing.T) {
+	cfg := &types.Config{
+		S3:        types.S3Config{Bucket: "b", SSECKey: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="},
+		Auth:      types.AuthConfig{AccessKeyID: "AKIAEXAMPLE", SecretAccessKey: "secret"},
+		Notify:    types.NotifyConfig{WebhookURL: "https://hooks.slack.com/services/T000/B000/XXXXSECRET"},
+		Telemetry: types.TelemetryConfig{Headers: map[string]string{"Authorization": "Bearer token"}},
+	}
+	masked := Masked(cfg)
+	if got := masked.Telemetry.Headers["Authorization"]; got != "****" {
+		t.Errorf("telemetry.headers Authorization = %q, want ****", got)
+	}
+	if cfg.Telemetry.Headers["Authorization"] != "Bearer token" {
+		t.Error("Masked modified the original telemetry headers")
+	}
+	if got, want := masked.Notify.WebhookURL, "https://hooks.slack.com/****"; got != want {
+		t.Errorf("notify.webhook_url = %q, want %q", got, want)
+	}
+	for name, got := range map[string]string{
+		"auth.access_key_id":     masked.Auth.AccessKeyID,
+		"auth.secret_access_key": masked.Auth.SecretAccessKey,
+		"s3.sse_c_key":           masked.S3.SSECKey,
+	} {
+		if got != "****" {
+			t.Errorf("%s = %q, want ****", name, got)
+		}
+	}
+	if cfg.S3.SSECKey == "****" {
+		t.Error("Masked modified the original config")
+	}
+}
@@ -0,0 +1,314 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/types"
+	"gopkg.in/yaml.v3"
+)
+
+// Field describes one config setting. Fields is the single list of settings
+// that env overrides, unknown-key errors, and config validate --sources work
+// from; tests check it against types.Config, the starter template, and
+// docs/CONFIGURATION.md.
+type Field struct {
+	Path        string // Dotted YAML path, e.g. "upload.part_size"
+	Type        string // string, bool, int, number, duration, size, list, or map
+	Default     string // Default as documented; empty if none
+	Env         string // Environment variable that overrides the file, if any
+	Description string
+
+	index []int // Field index within types.Config, for reflect.Value.FieldByIndex
+}
+
+// fieldDoc is the hand-written part of a Field.
+type fieldDoc struct {
+	path        string
+	def         string
+	env         string
+	description string
+}
+
+// fieldDocs documents every setting of types.Config.
+var fieldDocs = []fieldDoc{
+	{"local.projects_root", defaultProjectsRoot, "", "Directory containing Claude Code project folders"},
+	{"local.machine_id", "generated", "", "Name of this machine in object keys, manifests, and receipts"},
+	{"local.extensions", ".jsonl", "", "File extensions treated as session logs"},
+	{"local.project_depth", "1", "", "Depth below projects_root at which directories are projects"},
+	{"local.project_marker", "", "", "File whose presence makes a directory a project"},
+
+	{"s3.bucket", "", "", "Bucket name or access point ARN (required)"},
+	{"s3.prefix", defaultS3Prefix, "", "Prefix for all uploaded objects"},
+	{"s3.region", "", "", "Bucket region (required)"},
+	{"s3.endpoint", "", "", "Endpoint of an S3-compatible provider"},
+	{"s3.force_path_style", "false", "", "Use path-style addressing"},
+	{"s3.ca_bundle", "", "", "PEM file of extra CA certificates to trust"},
+	{"s3.insecure_skip_verify", "false", "", "Disable TLS certificate verification (development only)"},
+	{"s3.sse_c_key", "", "", "Customer-provided encryption key (SSE-C), base64 or a key file"},
+	{"s3.key_layout", KeyLayoutFlat, "", "Object key layout: flat or by_host"},
+	{"s3.key_template", "follows key_layout", "", "Template object keys are built from"},
+	{"s3.manifest_key", defaultManifestKey, "", "Name of the manifest under the key prefix"},
+	{"s3.archive_name", "", "", "Name recorded in the manifest for the archive machines share"},
+	{"s3.manifest_history", "5", "", "Manifest backups kept before each save (negative disables)"},
+	{"s3.operation_timeout", "60s", "", "Timeout for each S3 API call"},
+	{"s3.content_type", DefaultContentType, "", "Content-Type of uploaded logs"},
+	{"s3.cache_control", "", "", "Cache-Control header of uploaded logs"},
+	{"s3.object_lock.mode", "", "", "Object Lock retention mode of uploaded logs: GOVERNANCE or COMPLIANCE"},
+	{"s3.object_lock.retain_days", "", "", "Days each uploaded log is retained under Object Lock"},
+
+	{"auth.profile", "", "", "Profile in ~/.aws/credentials or ~/.aws/config, including SSO"},
+	{"auth.access_key_id", "", "", "Static access key ID"},
+	{"auth.secret_access_key", "", "", "Static secret access key"},
+	{"auth.session_token", "", "", "Session token for temporary credentials"},
+	{"auth.role_arn", "", "", "IAM role to assume before accessing S3"},
+	{"auth.external_id", "", "", "External ID required by the role's trust policy"},
+	{"auth.role_session_name", defaultRoleSessionName, "", "Session name of the assumed role"},
+
+	{"upload.part_size", "5MiB", "", "Multipart part size (min 5MiB)"},
+	{"upload.part_concurrency", "5", "", "Parts uploaded in parallel per file"},
+	{"upload.compress", "none", "", "Codec for uploaded objects: gzip or none"},
+	{"upload.compress_level", "codec default", "", "Codec level (gzip 1-9)"},
+	{"upload.upload_empty", "false", "", "Upload zero-byte files instead of skipping them"},
+	{"upload.spool", defaultSpool, "", "Buffering for single-PUT files: auto, memory, or disk"},
+	{"upload.spool_threshold", "part_size", "", "Files below this size are spooled"},
+	{"upload.spool_memory", "8MiB", "", "In auto mode, spool in memory up to this size, then disk"},
+	{"upload.memory_limit", "a quarter of system memory", "", "Ceiling for upload buffers"},
+	{"upload.remote_lock", "false", "", "Hold an advisory lock object in the bucket while uploading"},
+	{"upload.assumed_throughput", "observed rate", "", "Upload rate per second dry runs estimate durations with"},
+
+	{"redact.max_match_share", "0.2", "", "Warn when a pattern matches more than this share of lines"},
+	{"redact.env_keywords", "KEY, TOKEN, SECRET, ...", "", "Env var name suffixes redacted in KEY=VALUE lines"},
+	{"redact.disable", "", "", "Pattern tags that are not redacted"},
+	{"redact.allowlist", "well-known example values", "", "Values never redacted; /regex/ entries match whole values"},
+	{"redact.mode", "placeholder", "", "Replacement style: placeholder or mask"},
+	{"redact.mask_char", "*", "", "Character that replaces masked characters"},
+	{"redact.mask_keep", "", "", "Characters left as they are in mask mode"},
+	{"redact.canonical_json", "false", "", "Write redacted JSON lines in RFC 8785 canonical form"},
+	{"redact.spill_threshold", "0 (off)", "", "Encode longer JSON lines straight into the output"},
+
+	{"discovery.concurrency", "number of CPUs", "", "Files hashed at once"},
+
+	{"notify.webhook_url", "", "", "Webhook that receives a summary of each upload"},
+	{"notify.on", "always", "", "Runs that are sent: always, changes, or failure"},
+
+	{"telemetry.otlp_endpoint", "", "", "OTLP/HTTP collector for traces and metrics"},
+	{"telemetry.headers", "", "", "Headers sent with each export request"},
+
+	{"targets", "", "", "Extra named destinations, each with its own s3 and auth sections"},
+
+	{"lang", "English", "CCLOGS_LANG", "Message language, e.g. de"},
+}
+
+// Fields returns every config setting in the order types.Config declares
+// them. It panics if fieldDocs and types.Config disagree, which the package
+// tests catch.
+func Fields() []Field {
+	docs := make(map[string]fieldDoc, len(fieldDocs))
+	for _, d := range fieldDocs {
+		docs[d.path] = d
+	}
+
+	leaves := structFields()
+	fields := make([]Field, 0, len(leaves))
+	for _, f := range leaves {
+		d, ok := docs[f.Path]
+		if !ok {
+			panic(fmt.Sprintf("config: %s has no fieldDocs entry", f.Path))
+		}
+		f.Default, f.Env, f.Description = d.def, d.env, d.description
+		fields = append(fields, f)
+	}
+	if len(fields) != len(docs) {
+		panic("config: fieldDocs has entries for settings types.Config lacks")
+	}
+	return fields
+}
+
+// EnvName returns the environment variable overriding the setting at path,
+// or "" if there is none.
+func EnvName(path string) string {
+	for _, d := range fieldDocs {
+		if d.path == path {
+			return d.env
+		}
+	}
+	return ""
+}
+
+// structFields walks types.Config by YAML tag, returning its settings with
+// Path, Type, and index set.
+func structFields() []Field {
+	var fields []Field
+	var walk func(t reflect.Type, prefix string, index []int)
+	walk = func(t reflect.Type, prefix string, index []int) {
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+			if name == "" || name == "-" {
+				continue
+			}
+			idx := append(slices.Clone(index), i)
+			if sf.Type.Kind() == reflect.Struct {
+				walk(sf.Type, prefix+name+".", idx)
+				continue
+			}
+			fields = append(fields, Field{Path: prefix + name, Type: typeName(sf.Type), index: idx})
+		}
+	}
+	walk(reflect.TypeFor[types.Config](), "", nil)
+	return fields
+}
+
+// typeName names a setting's type for display.
+func typeName(t reflect.Type) string {
+	switch t {
+	case reflect.TypeFor[types.ByteSize]():
+		return "size"
+	case reflect.TypeFor[time.Duration]():
+		return "duration"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int64:
+		return "int"
+	case reflect.Float64:
+		return "number"
+	case reflect.Slice:
+		return "list"
+	case reflect.Map:
+		return "map"
+	default:
+		return "string"
+	}
+}
+
+// sectionNames maps config section types, as yaml.v3 names them in errors,
+// to their dotted YAML paths, e.g. types.ObjectLockConfig to s3.object_lock.
+func sectionNames() map[string]string {
+	names := make(map[string]string)
+	var walk func(t reflect.Type, prefix string)
+	walk = func(t reflect.Type, prefix string) {
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			name, _, _ := strings.Cut(sf.Tag.Get("yaml"), ",")
+			if sf.Type.Kind() == reflect.Struct && name != "" && name != "-" {
+				names[sf.Type.String()] = prefix + name
+				walk(sf.Type, prefix+name+".")
+			}
+		}
+	}
+	walk(reflect.TypeFor[types.Config](), "")
+	return names
+}
+
+// applyEnv sets each setting that has an environment variable and that
+// lookup finds. Values are parsed as YAML, as they would be in the file.
+func applyEnv(cfg *types.Config, lookup func(string) (string, bool)) error {
+	v := reflect.ValueOf(cfg).Elem()
+	for _, f := range Fields() {
+		if f.Env == "" {
+			continue
+		}
+		s, ok := lookup(f.Env)
+		if !ok || s == "" {
+			continue
+		}
+		if err := yaml.Unmarshal([]byte(s), v.FieldByIndex(f.index).Addr().Interface()); err != nil {
+			return fmt.Errorf("%s: %w", f.Env, err)
+		}
+	}
+	return nil
+}
+
+// Setting is a resolved setting and where its value came from.
+type Setting struct {
+	Field
+	Value  string
+	Source string // "file", "default", or "env <NAME>"
+}
+
+// Sources returns every setting of cfg, loaded from the config file at path,
+// with the source of its value. Credentials are masked.
+func Sources(cfg *types.Config, path string) ([]Setting, error) {
+	expandedPath, err := expandTilde(path)
+	if err != nil {
+		return nil, fmt.Errorf("expanding config path: %w", err)
+	}
+	data, err := os.ReadFile(expandedPath)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file %s: %w", expandedPath, err)
+	}
+	var doc map[string]any
+	if err := yaml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("parsing config YAML: %w", err)
+	}
+
+	v := reflect.ValueOf(Masked(cfg)).Elem()
+	var settings []Setting
+	for _, f := range Fields() {
+		s := Setting{Field: f, Value: formatValue(v.FieldByIndex(f.index)), Source: "default"}
+		if f.Env != "" && os.Getenv(f.Env) != "" {
+			s.Source = "env " + f.Env
+		} else if inDoc(doc, f.Path) {
+			s.Source = "file"
+		}
+		settings = append(settings, s)
+	}
+	return settings, nil
+}
+
+// inDoc reports whether the dotted path is set in a decoded YAML document.
+func inDoc(doc map[string]any, path string) bool {
+	section, key, nested := strings.Cut(path, ".")
+	if !nested {
+		_, ok := doc[path]
+		return ok
+	}
+	m, ok := doc[section].(map[string]any)
+	if !ok {
+		return false
+	}
+	return inDoc(m, key)
+}
+
+// formatValue formats a setting's value on one line.
+func formatValue(v reflect.Value) string {
+	switch v.Kind() {
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			items[i] = fmt.Sprint(v.Index(i).Interface())
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case reflect.Map:
+		// Sections, such as targets, are listed by name
+		if v.Type().Elem().Kind() == reflect.Struct {
+			names := make([]string, 0, v.Len())
+			for _, k := range v.MapKeys() {
+				names = append(names, k.String())
+			}
+			slices.Sort(names)
+			return "[" + strings.Join(names, ", ") + "]"
+		}
+		items := make([]string, 0, v.Len())
+		for _, k := range v.MapKeys() {
+			items = append(items, fmt.Sprintf("%v: %v", k.Interface(), v.MapIndex(k).Interface()))
+		}
+		slices.Sort(items)
+		return "{" + strings.Join(items, ", ") + "}"
+	case reflect.String:
+		if v.String() == "" {
+			return `""`
+		}
+	}
+	return fmt.Sprint(v.Interface())
+}
@@ -0,0 +1,180 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestFieldsMatchConfig fails when a setting is added to types.Config
+// without a fieldDocs entry, or an entry outlives its setting.
+func TestFieldsMatchConfig(t *testing.T) {
+	var structPaths, docPaths []string
+	for _, f := range structFields() {
+		structPaths = append(structPaths, f.Path)
+	}
+	for _, d := range fieldDocs {
+		if slices.Contains(docPaths, d.path) {
+			t.Errorf("fieldDocs lists %s twice", d.path)
+		}
+		docPaths = append(docPaths, d.path)
+		if d.description == "" {
+			t.Errorf("fieldDocs entry %s has no description", d.path)
+		}
+	}
+
+	for _, p := range structPaths {
+		if !slices.Contains(docPaths, p) {
+			t.Errorf("types.Config setting %s has no fieldDocs entry", p)
+		}
+	}
+	for _, p := range docPaths {
+		if !slices.Contains(structPaths, p) {
+			t.Errorf("fieldDocs entry %s is not a types.Config setting", p)
+		}
+	}
+}
+
+// templateKeyPattern matches a key in the starter template, commented out
+// or not, with its indentation and any value after the colon.
+var templateKeyPattern = regexp.MustCompile(`^(#?)( *)(?:# ?)? *([a-z][a-z0-9_]*):(.*)$`)
+
+// TestStarterTemplateCoversFields checks that every setting appears in the
+// starter config, under its section.
+func TestStarterTemplateCoversFields(t *testing.T) {
+	type parent struct {
+		col  int
+		path string
+	}
+	var keys []string
+	var parents []parent
+	for line := range strings.SplitSeq(starterConfigTemplate, "\n") {
+		m := templateKeyPattern.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		col := strings.Index(line, m[3]+":")
+		// Top-level keys start the line, or follow a single "# "
+		if m[2] == "" || (m[1] == "#" && m[2] == " " && !strings.HasPrefix(line, "#  ")) {
+			parents = []parent{{col, m[3]}}
+			keys = append(keys, m[3])
+			continue
+		}
+		// A key without a value opens a nested section, e.g. object_lock
+		for len(parents) > 1 && parents[len(parents)-1].col >= col {
+			parents = parents[:len(parents)-1]
+		}
+		path := m[3]
+		if len(parents) > 0 {
+			path = parents[len(parents)-1].path + "." + m[3]
+		}
+		keys = append(keys, path)
+		if strings.TrimSpace(m[4]) == "" {
+			parents = append(parents, parent{col, path})
+		}
+	}
+
+	for _, f := range Fields() {
+		if !slices.Contains(keys, f.Path) {
+			t.Errorf("starter config template does not mention %s", f.Path)
+		}
+	}
+}
+
+// TestConfigurationDocsCoverFields checks that docs/CONFIGURATION.md has a
+// section for every setting and none for settings that do not exist.
+func TestConfigurationDocsCoverFields(t *testing.T) {
+	data, err := os.ReadFile(filepath.Join("..", "..", "docs", "CONFIGURATION.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	headings := regexp.MustCompile("(?m)^#### `([^`]+)`$").FindAllStringSubmatch(string(data), -1)
+	documented := make([]string, len(headings))
+	for i, h := range headings {
+		documented[i] = h[1]
+	}
+
+	var paths []string
+	for _, f := range Fields() {
+		paths = append(paths, f.Path)
+		if !slices.Contains(documented, f.Path) {
+			t.Errorf("docs/CONFIGURATION.md has no section for %s", f.Path)
+		}
+	}
+	for _, d := range documented {
+		if !slices.Contains(paths, d) {
+			t.Errorf("docs/CONFIGURATION.md documents unknown setting %s", d)
+		}
+	}
+}
+
+func TestFieldTypes(t *testing.T) {
+	want := map[string]string{
+		"s3.bucket":              "string",
+		"s3.force_path_style":    "bool",
+		"s3.manifest_history":    "int",
+		"s3.operation_timeout":   "duration",
+		"upload.part_size":       "size",
+		"redact.max_match_share": "number",
+		"local.extensions":       "list",
+		"telemetry.headers":      "map",
+	}
+	for _, f := range Fields() {
+		if w, ok := want[f.Path]; ok && f.Type != w {
+			t.Errorf("%s type = %q, want %q", f.Path, f.Type, w)
+		}
+	}
+}
+
+func TestSectionNames(t *testing.T) {
+	names := sectionNames()
+	for typ, want := range map[string]string{
+		"types.S3Config":         "s3",
+		"types.DiscoveryConfig":  "discovery",
+		"types.ObjectLockConfig": "s3.object_lock",
+	} {
+		if names[typ] != want {
+			t.Errorf("sectionNames()[%q] = %q, want %q", typ, names[typ], want)
+		}
+	}
+}
+
+func TestEnvOverride(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	content := "s3:\n  bucket: test-bucket\n  region: us-west-2\nlang: fr\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("CCLOGS_LANG", "de")
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.Lang != "de" {
+		t.Errorf("Lang = %q, want de from CCLOGS_LANG", cfg.Lang)
+	}
+
+	settings, err := Sources(cfg, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"lang":             "env CCLOGS_LANG",
+		"s3.bucket":        "file",
+		"s3.prefix":        "default",
+		"upload.part_size": "default",
+	}
+	for _, s := range settings {
+		if w, ok := want[s.Path]; ok && s.Source != w {
+			t.Errorf("%s source = %q, want %q", s.Path, s.Source, w)
+		}
+		if s.Path == "s3.prefix" && s.Value != defaultS3Prefix {
+			t.Errorf("s3.prefix value = %q, want %q", s.Value, defaultS3Prefix)
+		}
+	}
+}
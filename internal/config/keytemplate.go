@@ -0,0 +1,230 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+const (
+	// DefaultKeyTemplate reproduces the flat layout: <prefix>/<project>/<path>.
+	DefaultKeyTemplate = "{prefix}{project}/{path}"
+	// ByHostKeyTemplate reproduces the by_host layout: <prefix>/<machine>/<project>/<path>.
+	ByHostKeyTemplate = "{prefix}{host}/{project}/{path}"
+)
+
+// keyPlaceholders lists the placeholders accepted in s3.key_template.
+var keyPlaceholders = map[string]bool{
+	"prefix":   true, // s3.prefix, with trailing slash
+	"host":     true, // local.machine_id (defaults to hostname)
+	"project":  true, // project directory name
+	"path":     true, // file path relative to the project directory
+	"filename": true, // file base name
+	"year":     true, // file mtime (UTC), 4 digits
+	"month":    true, // file mtime (UTC), 2 digits
+	"day":      true, // file mtime (UTC), 2 digits
+}
+
+var placeholderPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+
+// KeyFields holds the values substituted into a key template.
+type KeyFields struct {
+	Prefix  string
+	Host    string
+	Project string
+	Path    string // Relative path within the project; may use backslashes
+	ModTime time.Time
+}
+
+// RenderKey expands tmpl with the given fields. Backslashes in values are
+// converted to forward slashes so Windows paths produce valid S3 keys.
+func RenderKey(tmpl string, f KeyFields) string {
+	prefix := f.Prefix
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	relPath := strings.ReplaceAll(f.Path, "\\", "/")
+	mtime := f.ModTime.UTC()
+
+	values := map[string]string{
+		"prefix":   prefix,
+		"host":     f.Host,
+		"project":  f.Project,
+		"path":     relPath,
+		"filename": path.Base(relPath),
+		"year":     fmt.Sprintf("%04d", mtime.Year()),
+		"month":    fmt.Sprintf("%02d", int(mtime.Month())),
+		"day":      fmt.Sprintf("%02d", mtime.Day()),
+	}
+
+	key := placeholderPattern.ReplaceAllStringFunc(tmpl, func(m string) string {
+		return values[m[1:len(m)-1]]
+	})
+	return strings.ReplaceAll(key, "\\", "/")
+}
+
+// DatedKey inserts day's UTC date as YYYY/MM/DD/ after prefix in key, for
+// --date-partition. Keys outside prefix get the date in front.
+func DatedKey(prefix, key string, day time.Time) string {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	rest, ok := strings.CutPrefix(key, prefix)
+	if !ok {
+		prefix, rest = "", key
+	}
+	return prefix + day.UTC().Format("2006/01/02") + "/" + rest
+}
+
+// datePartitionPattern matches the YYYY/MM/DD/ segments DatedKey inserts.
+var datePartitionPattern = regexp.MustCompile(`(?:^|/)([0-9]{4}/[0-9]{2}/[0-9]{2}/)`)
+
+// UndatedKey reverses DatedKey: it removes the first YYYY/MM/DD/ segments
+// after prefix in key. With the by_host layout they follow the machine
+// segment. ok is false when key has no date partition.
+func UndatedKey(prefix, key string) (undated string, ok bool) {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	rest, found := strings.CutPrefix(key, prefix)
+	if !found {
+		prefix, rest = "", key
+	}
+	m := datePartitionPattern.FindStringSubmatchIndex(rest)
+	if m == nil {
+		return key, false
+	}
+	return prefix + rest[:m[2]] + rest[m[3]:], true
+}
+
+// placeholderPatterns are the regular expressions ParseKey matches each
+// placeholder's value with.
+var placeholderPatterns = map[string]string{
+	"host":     `[^/]+`,
+	"project":  `[^/]+`,
+	"path":     `.+`,
+	"filename": `[^/]+`,
+	"year":     `[0-9]{4}`,
+	"month":    `[0-9]{2}`,
+	"day":      `[0-9]{2}`,
+}
+
+// ParseKey reverses RenderKey: it matches key against tmpl, with {prefix}
+// standing for prefix and {project} for projectDepth path segments (at least
+// one), and returns the fields the key encodes. Path is the {path} value, or
+// the {filename} value when the template has no {path}. ModTime is left zero.
+// ok is false when key does not match tmpl, or a placeholder used twice has
+// two different values.
+//
+// Keys stored with --date-partition are parsed without their YYYY/MM/DD/
+// segments, unless only the dated key matches tmpl.
+func ParseKey(tmpl, prefix, key string, projectDepth int) (f KeyFields, ok bool) {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	if undated, dated := UndatedKey(prefix, key); dated {
+		if f, ok := parseKey(tmpl, prefix, undated, projectDepth); ok {
+			return f, true
+		}
+	}
+	return parseKey(tmpl, prefix, key, projectDepth)
+}
+
+// parseKey implements ParseKey for a key taken as it is.
+func parseKey(tmpl, prefix, key string, projectDepth int) (f KeyFields, ok bool) {
+
+	var pattern strings.Builder
+	var names []string
+	pattern.WriteString("^")
+	last := 0
+	for _, m := range placeholderPattern.FindAllStringSubmatchIndex(tmpl, -1) {
+		pattern.WriteString(regexp.QuoteMeta(tmpl[last:m[0]]))
+		name := tmpl[m[2]:m[3]]
+		switch name {
+		case "prefix":
+			pattern.WriteString(regexp.QuoteMeta(prefix))
+		case "project":
+			pattern.WriteString(fmt.Sprintf(`([^/]+(?:/[^/]+){%d})`, max(projectDepth, 1)-1))
+			names = append(names, name)
+		default:
+			pattern.WriteString("(" + placeholderPatterns[name] + ")")
+			names = append(names, name)
+		}
+		last = m[1]
+	}
+	pattern.WriteString(regexp.QuoteMeta(tmpl[last:]) + "$")
+
+	re, err := regexp.Compile(pattern.String())
+	if err != nil {
+		return KeyFields{}, false
+	}
+	match := re.FindStringSubmatch(key)
+	if match == nil {
+		return KeyFields{}, false
+	}
+
+	values := make(map[string]string)
+	for i, name := range names {
+		if v, seen := values[name]; seen && v != match[i+1] {
+			return KeyFields{}, false
+		}
+		values[name] = match[i+1]
+	}
+
+	f = KeyFields{Prefix: prefix, Host: values["host"], Project: values["project"], Path: values["path"]}
+	if f.Path == "" {
+		f.Path = values["filename"]
+	}
+	return f, true
+}
+
+// KeyTemplate returns the effective key template for cfg, falling back to the
+// default for the configured layout when s3.key_template is unset.
+func KeyTemplate(cfg *types.Config) string {
+	if cfg.S3.KeyTemplate != "" {
+		return cfg.S3.KeyTemplate
+	}
+	if cfg.S3.KeyLayout == KeyLayoutByHost {
+		return ByHostKeyTemplate
+	}
+	return DefaultKeyTemplate
+}
+
+// TemplateUsesHost reports whether tmpl contains the {host} placeholder.
+func TemplateUsesHost(tmpl string) bool {
+	return strings.Contains(tmpl, "{host}")
+}
+
+// validateKeyTemplate checks that tmpl only uses known placeholders and
+// includes enough components to keep keys unique. Files are unique per
+// project by path (or session filename), and per machine when requireHost is set.
+func validateKeyTemplate(tmpl string, requireHost bool) error {
+	if strings.HasPrefix(tmpl, "/") {
+		return fmt.Errorf("must not start with \"/\"")
+	}
+
+	used := make(map[string]bool)
+	for _, m := range placeholderPattern.FindAllStringSubmatch(tmpl, -1) {
+		if !keyPlaceholders[m[1]] {
+			return fmt.Errorf("unknown placeholder {%s}", m[1])
+		}
+		used[m[1]] = true
+	}
+
+	if !used["project"] {
+		return fmt.Errorf("must include {project}")
+	}
+	if !used["path"] && !used["filename"] {
+		return fmt.Errorf("must include {path} or {filename}")
+	}
+	if requireHost && !used["host"] {
+		return fmt.Errorf("must include {host} for by_host layout")
+	}
+
+	return nil
+}
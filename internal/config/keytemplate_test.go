@@ -0,0 +1,192 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderKey(t *testing.T) {
+	fields := KeyFields{
+		Prefix:  "claude-code/",
+		Host:    "laptop",
+		Project: "my-app",
+		Path:    "sub\\session.jsonl",
+		ModTime: time.Date(2025, 3, 7, 23, 30, 0, 0, time.FixedZone("PST", -8*3600)),
+	}
+
+	tests := []struct {
+		name string
+		tmpl string
+		want string
+	}{
+		{name: "default", tmpl: DefaultKeyTemplate, want: "claude-code/my-app/sub/session.jsonl"},
+		{name: "by host", tmpl: ByHostKeyTemplate, want: "claude-code/laptop/my-app/sub/session.jsonl"},
+		{
+			name: "date partitioned uses UTC",
+			tmpl: "claude/{year}/{month}/{day}/{project}/{filename}",
+			want: "claude/2025/03/08/my-app/session.jsonl",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RenderKey(tt.tmpl, fields); got != tt.want {
+				t.Errorf("RenderKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDatedKey(t *testing.T) {
+	// 23:30 PST is already the next day in UTC
+	day1 := time.Date(2025, 3, 7, 23, 30, 0, 0, time.FixedZone("PST", -8*3600))
+	day2 := day1.Add(24 * time.Hour)
+
+	tests := []struct {
+		name   string
+		prefix string
+		key    string
+		day    time.Time
+		want   string
+	}{
+		{name: "flat", prefix: "claude-code", key: "claude-code/my-app/session.jsonl", day: day1, want: "claude-code/2025/03/08/my-app/session.jsonl"},
+		{name: "next day", prefix: "claude-code/", key: "claude-code/my-app/session.jsonl", day: day2, want: "claude-code/2025/03/09/my-app/session.jsonl"},
+		{name: "by host prefix", prefix: "claude-code/laptop/", key: "claude-code/laptop/my-app/s.jsonl.gz", day: day1, want: "claude-code/laptop/2025/03/08/my-app/s.jsonl.gz"},
+		{name: "no prefix", key: "my-app/session.jsonl", day: day1, want: "2025/03/08/my-app/session.jsonl"},
+		{name: "key outside prefix", prefix: "claude-code/", key: "logs/my-app/session.jsonl", day: day1, want: "2025/03/08/logs/my-app/session.jsonl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DatedKey(tt.prefix, tt.key, tt.day); got != tt.want {
+				t.Errorf("DatedKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUndatedKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		prefix string
+		key    string
+		want   string
+		wantOK bool
+	}{
+		{name: "flat", prefix: "claude-code", key: "claude-code/2025/03/08/my-app/session.jsonl", want: "claude-code/my-app/session.jsonl", wantOK: true},
+		{name: "by host", prefix: "claude-code/", key: "claude-code/laptop/2025/03/08/my-app/s.jsonl.gz", want: "claude-code/laptop/my-app/s.jsonl.gz", wantOK: true},
+		{name: "no prefix", key: "2025/03/08/my-app/session.jsonl", want: "my-app/session.jsonl", wantOK: true},
+		{name: "undated", prefix: "claude-code/", key: "claude-code/my-app/session.jsonl", want: "claude-code/my-app/session.jsonl"},
+		{name: "year-like project", prefix: "claude-code/", key: "claude-code/2025/notes/session.jsonl", want: "claude-code/2025/notes/session.jsonl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := UndatedKey(tt.prefix, tt.key)
+			if got != tt.want || ok != tt.wantOK {
+				t.Errorf("UndatedKey() = %q, %v, want %q, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	tests := []struct {
+		name   string
+		tmpl   string
+		key    string
+		depth  int
+		want   KeyFields
+		wantOK bool
+	}{
+		{
+			name:   "default",
+			tmpl:   DefaultKeyTemplate,
+			key:    "claude-code/my-app/sub/session.jsonl",
+			want:   KeyFields{Prefix: "claude-code/", Project: "my-app", Path: "sub/session.jsonl"},
+			wantOK: true,
+		},
+		{
+			name:   "by host",
+			tmpl:   ByHostKeyTemplate,
+			key:    "claude-code/laptop/my-app/session.jsonl",
+			want:   KeyFields{Prefix: "claude-code/", Host: "laptop", Project: "my-app", Path: "session.jsonl"},
+			wantOK: true,
+		},
+		{
+			name:   "date partitioned",
+			tmpl:   "{prefix}{year}/{month}/{day}/{project}/{filename}",
+			key:    "claude-code/2025/03/08/my-app/session.jsonl",
+			want:   KeyFields{Prefix: "claude-code/", Project: "my-app", Path: "session.jsonl"},
+			wantOK: true,
+		},
+		{
+			name:   "project depth 2",
+			tmpl:   DefaultKeyTemplate,
+			key:    "claude-code/acme/my-app/sub/session.jsonl",
+			depth:  2,
+			want:   KeyFields{Prefix: "claude-code/", Project: "acme/my-app", Path: "sub/session.jsonl"},
+			wantOK: true,
+		},
+		{
+			name:   "--date-partition",
+			tmpl:   DefaultKeyTemplate,
+			key:    "claude-code/2025/03/08/my-app/sub/session.jsonl",
+			want:   KeyFields{Prefix: "claude-code/", Project: "my-app", Path: "sub/session.jsonl"},
+			wantOK: true,
+		},
+		{
+			name:   "--date-partition by host",
+			tmpl:   ByHostKeyTemplate,
+			key:    "claude-code/laptop/2025/03/08/my-app/session.jsonl",
+			want:   KeyFields{Prefix: "claude-code/", Host: "laptop", Project: "my-app", Path: "session.jsonl"},
+			wantOK: true,
+		},
+		{name: "other prefix", tmpl: DefaultKeyTemplate, key: "other/my-app/session.jsonl"},
+		{name: "no project directory", tmpl: DefaultKeyTemplate, key: "claude-code/session.jsonl"},
+		{name: "repeated placeholder differs", tmpl: "{prefix}{project}/{project}-{filename}", key: "claude-code/a/b-s.jsonl"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := ParseKey(tt.tmpl, "claude-code", tt.key, tt.depth)
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("ParseKey() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestValidateKeyTemplate(t *testing.T) {
+	tests := []struct {
+		name        string
+		tmpl        string
+		requireHost bool
+		errMsg      string
+	}{
+		{name: "default", tmpl: DefaultKeyTemplate},
+		{name: "filename instead of path", tmpl: "logs/{year}/{project}/{filename}"},
+		{name: "by host", tmpl: ByHostKeyTemplate, requireHost: true},
+		{name: "missing project", tmpl: "{prefix}{path}", errMsg: "must include {project}"},
+		{name: "missing file component", tmpl: "{prefix}{project}/{day}", errMsg: "must include {path} or {filename}"},
+		{name: "missing host", tmpl: DefaultKeyTemplate, requireHost: true, errMsg: "must include {host}"},
+		{name: "unknown placeholder", tmpl: "{prefix}{project}/{session}", errMsg: "unknown placeholder {session}"},
+		{name: "leading slash", tmpl: "/{project}/{path}", errMsg: "must not start with"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateKeyTemplate(tt.tmpl, tt.requireHost)
+			if tt.errMsg == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("error = %v, want containing %q", err, tt.errMsg)
+			}
+		})
+	}
+}
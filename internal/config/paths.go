@@ -0,0 +1,117 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/13rac1/cclogs/internal/identity"
+)
+
+// PathEnv is the environment variable naming the config file to use when
+// --config is not given.
+const PathEnv = "CCLOGS_CONFIG"
+
+// appName names the cclogs directories under the XDG base directories.
+const appName = "cclogs"
+
+// DefaultPath returns the config file used without --config: $CCLOGS_CONFIG
+// if set, otherwise the first that exists of $XDG_CONFIG_HOME/cclogs,
+// ~/.config/cclogs, and the legacy ~/.cclogs, each holding config.yaml. If
+// none exists, it returns the XDG location, where the starter config is
+// created.
+func DefaultPath() string {
+	if path := os.Getenv(PathEnv); path != "" {
+		return path
+	}
+	candidates := configPaths()
+	for _, path := range candidates {
+		if _, err := os.Stat(path); err == nil {
+			return path
+		}
+	}
+	return candidates[0]
+}
+
+// configPaths returns the locations DefaultPath searches, in order: the
+// XDG ones, then the legacy one.
+func configPaths() []string {
+	home, _ := os.UserHomeDir()
+	var paths []string
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		paths = append(paths, filepath.Join(dir, appName, "config.yaml"))
+	}
+	return append(paths,
+		filepath.Join(home, ".config", appName, "config.yaml"),
+		filepath.Join(legacyDir(), "config.yaml"))
+}
+
+// legacyDir returns ~/.cclogs, where earlier versions kept everything.
+func legacyDir() string {
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, ".cclogs")
+}
+
+// isXDGPath reports whether the config file at path is in one of the XDG
+// locations DefaultPath searches.
+func isXDGPath(path string) bool {
+	candidates := configPaths()
+	path = filepath.Clean(path)
+	return slices.Contains(candidates[:len(candidates)-1], path)
+}
+
+// StateDir returns where cclogs keeps the state file, run receipts, resume
+// files, and the upload lock for the config file at path. For a config in an
+// XDG location that is $XDG_STATE_HOME/cclogs (default ~/.local/state/cclogs);
+// for any other config, including the legacy ~/.cclogs one, it is the config's
+// own directory, as it always was.
+//
+// A config moved from ~/.cclogs to an XDG location keeps using the state left
+// in ~/.cclogs until the XDG state directory exists, so the machine ID it
+// generated does not change.
+func StateDir(path string) string {
+	path = expandPath(path)
+	if !isXDGPath(path) {
+		return filepath.Dir(path)
+	}
+	dir := xdgDir("XDG_STATE_HOME", filepath.Join(".local", "state"))
+	if _, err := os.Stat(dir); errors.Is(err, os.ErrNotExist) {
+		if _, err := os.Stat(filepath.Join(legacyDir(), identity.StateFile)); err == nil {
+			return legacyDir()
+		}
+	}
+	return dir
+}
+
+// CacheDir returns the directory holding cached data, such as manifests,
+// for the config file at path: $XDG_CACHE_HOME/cclogs (default
+// ~/.cache/cclogs) for a config in an XDG location, otherwise the config's
+// own directory.
+func CacheDir(path string) string {
+	path = expandPath(path)
+	if !isXDGPath(path) {
+		return filepath.Dir(path)
+	}
+	return xdgDir("XDG_CACHE_HOME", ".cache")
+}
+
+// xdgDir returns the cclogs directory under the XDG base directory named by
+// env, or under fallback in the home directory if env is unset or relative,
+// as the XDG spec requires.
+func xdgDir(env, fallback string) string {
+	if dir := os.Getenv(env); filepath.IsAbs(dir) {
+		return filepath.Join(dir, appName)
+	}
+	home, _ := os.UserHomeDir()
+	return filepath.Join(home, fallback, appName)
+}
+
+// expandPath is expandTilde for callers that have no use for its error: a
+// path that can't be expanded is returned as is.
+func expandPath(path string) string {
+	if expanded, err := expandTilde(path); err == nil {
+		return expanded
+	}
+	return path
+}
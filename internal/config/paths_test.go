@@ -0,0 +1,134 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/identity"
+)
+
+// setHome points the home directory and XDG base directories at a temp dir
+// and returns it.
+func setHome(t *testing.T) string {
+	t.Helper()
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	for _, env := range []string{PathEnv, "XDG_CONFIG_HOME", "XDG_STATE_HOME", "XDG_CACHE_HOME"} {
+		t.Setenv(env, "")
+	}
+	return home
+}
+
+func writeFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDefaultPath(t *testing.T) {
+	home := setHome(t)
+	xdgHome := filepath.Join(home, "xdg")
+	legacy := filepath.Join(home, ".cclogs", "config.yaml")
+	dotConfig := filepath.Join(home, ".config", "cclogs", "config.yaml")
+	xdg := filepath.Join(xdgHome, "cclogs", "config.yaml")
+
+	if got := DefaultPath(); got != dotConfig {
+		t.Errorf("DefaultPath() with no config = %q, want the XDG default %q", got, dotConfig)
+	}
+
+	writeFile(t, legacy)
+	if got := DefaultPath(); got != legacy {
+		t.Errorf("DefaultPath() with only the legacy config = %q, want %q", got, legacy)
+	}
+
+	writeFile(t, dotConfig)
+	if got := DefaultPath(); got != dotConfig {
+		t.Errorf("DefaultPath() = %q, want ~/.config over the legacy config", got)
+	}
+
+	t.Setenv("XDG_CONFIG_HOME", xdgHome)
+	if got := DefaultPath(); got != dotConfig {
+		t.Errorf("DefaultPath() = %q, want %q while $XDG_CONFIG_HOME has no config", got, dotConfig)
+	}
+	writeFile(t, xdg)
+	if got := DefaultPath(); got != xdg {
+		t.Errorf("DefaultPath() = %q, want $XDG_CONFIG_HOME first", got)
+	}
+
+	t.Setenv(PathEnv, "/etc/cclogs.yaml")
+	if got := DefaultPath(); got != "/etc/cclogs.yaml" {
+		t.Errorf("DefaultPath() = %q, want $%s", got, PathEnv)
+	}
+}
+
+func TestStateAndCacheDirs(t *testing.T) {
+	home := setHome(t)
+	xdg := filepath.Join(home, ".config", "cclogs", "config.yaml")
+	legacyDir := filepath.Join(home, ".cclogs")
+
+	tests := []struct {
+		name      string
+		path      string
+		wantState string
+		wantCache string
+	}{
+		{"legacy", filepath.Join(legacyDir, "config.yaml"), legacyDir, legacyDir},
+		{"custom", "/srv/cclogs/config.yaml", "/srv/cclogs", "/srv/cclogs"},
+		{"xdg", xdg, filepath.Join(home, ".local", "state", "cclogs"), filepath.Join(home, ".cache", "cclogs")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StateDir(tt.path); got != tt.wantState {
+				t.Errorf("StateDir() = %q, want %q", got, tt.wantState)
+			}
+			if got := CacheDir(tt.path); got != tt.wantCache {
+				t.Errorf("CacheDir() = %q, want %q", got, tt.wantCache)
+			}
+		})
+	}
+
+	t.Setenv("XDG_STATE_HOME", filepath.Join(home, "state"))
+	t.Setenv("XDG_CACHE_HOME", "relative/cache") // Ignored, as the spec requires
+	if got, want := StateDir(xdg), filepath.Join(home, "state", "cclogs"); got != want {
+		t.Errorf("StateDir() = %q, want %q", got, want)
+	}
+	if got, want := CacheDir(xdg), filepath.Join(home, ".cache", "cclogs"); got != want {
+		t.Errorf("CacheDir() = %q, want %q", got, want)
+	}
+
+	// A config moved from ~/.cclogs keeps the state, and machine ID, left there
+	writeFile(t, filepath.Join(legacyDir, identity.StateFile))
+	if got := StateDir(xdg); got != legacyDir {
+		t.Errorf("StateDir() with legacy state = %q, want %q", got, legacyDir)
+	}
+	if err := os.MkdirAll(filepath.Join(home, "state", "cclogs"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := StateDir(xdg), filepath.Join(home, "state", "cclogs"); got != want {
+		t.Errorf("StateDir() once the XDG state directory exists = %q, want %q", got, want)
+	}
+}
+
+func TestLoad_XDGState(t *testing.T) {
+	home := setHome(t)
+	path := filepath.Join(home, ".config", "cclogs", "config.yaml")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("s3:\n  bucket: logs\n  region: us-west-2\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := filepath.Join(home, ".local", "state", "cclogs", identity.StateFile); cfg.Identity.StatePath != want {
+		t.Errorf("StatePath = %q, want %q", cfg.Identity.StatePath, want)
+	}
+}
@@ -0,0 +1,117 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+// MaxProjectDepth is the deepest local.project_depth accepted, and how far
+// below the projects root local.project_marker is looked for.
+const MaxProjectDepth = 3
+
+// ProjectDir is a directory holding the logs of one project.
+type ProjectDir struct {
+	Name string // Slash-separated path below the projects root, e.g. "client/app"
+	Path string // Filesystem path
+}
+
+// ProjectDirs returns the project directories under local.ProjectsRoot, sorted
+// by name. By default each immediate child directory is a project. With
+// local.ProjectDepth n, the directories n levels down are. With
+// local.ProjectMarker, a directory holding a file of that name is, and its
+// subdirectories are searched no further.
+func ProjectDirs(local types.LocalConfig) ([]ProjectDir, error) {
+	root := local.ProjectsRoot
+	info, err := os.Stat(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("projects root does not exist: %s", root)
+		}
+		return nil, fmt.Errorf("accessing projects root %s: %w", root, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("projects root is not a directory: %s", root)
+	}
+
+	depth := max(local.ProjectDepth, 1)
+	if local.ProjectMarker != "" {
+		depth = MaxProjectDepth
+	}
+
+	var dirs []ProjectDir
+	var walk func(dir, name string, level int) error
+	walk = func(dir, name string, level int) error {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if level == 0 {
+				return fmt.Errorf("reading projects root %s: %w", root, err)
+			}
+			fmt.Fprintf(os.Stderr, "Warning: skipping %s: %v\n", dir, err)
+			return nil
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			d := ProjectDir{Name: path.Join(name, e.Name()), Path: filepath.Join(dir, e.Name())}
+			switch {
+			case local.ProjectMarker != "" && hasFile(d.Path, local.ProjectMarker):
+				dirs = append(dirs, d)
+			case local.ProjectMarker == "" && level+1 == depth:
+				dirs = append(dirs, d)
+			case level+1 < depth:
+				if err := walk(d.Path, d.Name, level+1); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+	if err := walk(root, "", 0); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(dirs, func(i, j int) bool { return dirs[i].Name < dirs[j].Name })
+	return dirs, nil
+}
+
+// hasFile reports whether dir contains a regular file named name.
+func hasFile(dir, name string) bool {
+	info, err := os.Stat(filepath.Join(dir, name))
+	return err == nil && !info.IsDir()
+}
+
+// SplitRef splits a "project/path" reference into its project and the path
+// below it. The project has local.ProjectDepth segments; with
+// local.ProjectMarker it is the shortest leading run of segments isProject
+// accepts, or the first segment if none is.
+func SplitRef(local types.LocalConfig, ref string, isProject func(string) bool) (project, rel string, ok bool) {
+	segments := strings.Split(strings.Trim(ref, "/"), "/")
+
+	n := max(local.ProjectDepth, 1)
+	if local.ProjectMarker != "" {
+		n = 1
+		for i := 1; i <= MaxProjectDepth && i < len(segments); i++ {
+			if isProject != nil && isProject(strings.Join(segments[:i], "/")) {
+				n = i
+				break
+			}
+		}
+	}
+
+	if len(segments) <= n {
+		return "", "", false
+	}
+	project, rel = strings.Join(segments[:n], "/"), strings.Join(segments[n:], "/")
+	if slices.Contains(segments[:n], "") || rel == "" {
+		return "", "", false
+	}
+	return project, rel, true
+}
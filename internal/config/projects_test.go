@@ -0,0 +1,106 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+func TestProjectDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"app/s.jsonl",
+		"client/web/s.jsonl",
+		"client/api/.claude-project",
+		"client/api/nested/.claude-project",
+		"deep/a/b/.claude-project",
+		"loose.jsonl",
+	} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		local types.LocalConfig
+		want  []string
+	}{
+		{
+			name:  "default",
+			local: types.LocalConfig{},
+			want:  []string{"app", "client", "deep"},
+		},
+		{
+			name:  "depth 2",
+			local: types.LocalConfig{ProjectDepth: 2},
+			want:  []string{"client/api", "client/web", "deep/a"},
+		},
+		{
+			name:  "marker",
+			local: types.LocalConfig{ProjectMarker: ".claude-project"},
+			want:  []string{"client/api", "deep/a/b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.local.ProjectsRoot = root
+			dirs, err := ProjectDirs(tt.local)
+			if err != nil {
+				t.Fatalf("ProjectDirs() error = %v", err)
+			}
+			var got []string
+			for _, d := range dirs {
+				got = append(got, d.Name)
+				if want := filepath.Join(root, filepath.FromSlash(d.Name)); d.Path != want {
+					t.Errorf("%s: Path = %q, want %q", d.Name, d.Path, want)
+				}
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("ProjectDirs() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := ProjectDirs(types.LocalConfig{ProjectsRoot: filepath.Join(root, "missing")}); err == nil {
+		t.Error("ProjectDirs() of a missing root succeeded")
+	}
+}
+
+func TestSplitRef(t *testing.T) {
+	isProject := func(p string) bool { return p == "client/api" }
+
+	tests := []struct {
+		name        string
+		local       types.LocalConfig
+		ref         string
+		wantProject string
+		wantRel     string
+		wantOK      bool
+	}{
+		{name: "default", ref: "app/s.jsonl", wantProject: "app", wantRel: "s.jsonl", wantOK: true},
+		{name: "default nested", ref: "app/sub/s.jsonl", wantProject: "app", wantRel: "sub/s.jsonl", wantOK: true},
+		{name: "depth 2", local: types.LocalConfig{ProjectDepth: 2}, ref: "client/app/s.jsonl", wantProject: "client/app", wantRel: "s.jsonl", wantOK: true},
+		{name: "depth 2 too short", local: types.LocalConfig{ProjectDepth: 2}, ref: "client/s.jsonl"},
+		{name: "marker", local: types.LocalConfig{ProjectMarker: ".claude-project"}, ref: "client/api/s.jsonl", wantProject: "client/api", wantRel: "s.jsonl", wantOK: true},
+		{name: "marker unknown project", local: types.LocalConfig{ProjectMarker: ".claude-project"}, ref: "app/s.jsonl", wantProject: "app", wantRel: "s.jsonl", wantOK: true},
+		{name: "no path", ref: "app"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project, rel, ok := SplitRef(tt.local, tt.ref, isProject)
+			if project != tt.wantProject || rel != tt.wantRel || ok != tt.wantOK {
+				t.Errorf("SplitRef(%q) = %q, %q, %v, want %q, %q, %v", tt.ref, project, rel, ok, tt.wantProject, tt.wantRel, tt.wantOK)
+			}
+		})
+	}
+}
@@ -2,17 +2,31 @@ package config
 
 import (
 	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"net/http"
+	"os"
+	"time"
 
 	"github.com/13rac1/cclogs/internal/types"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/credentials/stscreds"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
 )
 
+// defaultRoleSessionName names assumed-role sessions when
+// auth.role_session_name is not set, so CloudTrail shows who made them.
+const defaultRoleSessionName = "cclogs"
+
 // NewS3Client creates an S3 client from the provided configuration.
 // Authentication priority: static credentials > AWS profile > default credential chain.
+// With auth.role_arn, the credentials found that way are used to assume the
+// role, and S3 is accessed as the role.
 func NewS3Client(ctx context.Context, cfg *types.Config) (*s3.Client, error) {
 	var opts []func(*config.LoadOptions) error
 
@@ -36,20 +50,109 @@ func NewS3Client(ctx context.Context, cfg *types.Config) (*s3.Client, error) {
 		opts = append(opts, config.WithSharedConfigProfile(cfg.Auth.Profile))
 	}
 
+	httpClient, err := newHTTPClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if httpClient != nil {
+		opts = append(opts, config.WithHTTPClient(httpClient))
+	}
+
 	awsCfg, err := config.LoadDefaultConfig(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("load AWS config: %w", err)
 	}
+	if cfg.Auth.RoleARN != "" {
+		awsCfg.Credentials = assumeRoleCredentials(sts.NewFromConfig(awsCfg), cfg.Auth)
+	}
 
 	// Create S3 client with optional customizations
-	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+	optFns := []func(*s3.Options){func(o *s3.Options) {
 		if cfg.S3.Endpoint != "" {
 			o.BaseEndpoint = aws.String(cfg.S3.Endpoint)
 		}
 		if cfg.S3.ForcePathStyle {
 			o.UsePathStyle = true
 		}
-	})
+	}}
+	if cfg.S3.SSECKey != "" {
+		key, err := LoadSSECKey(cfg.S3.SSECKey)
+		if err != nil {
+			return nil, fmt.Errorf("s3.sse_c_key: %w", err)
+		}
+		optFns = append(optFns, withSSEC(key))
+	}
+	client := s3.NewFromConfig(awsCfg, optFns...)
 
 	return client, nil
 }
+
+// assumeRoleCredentials returns credentials for the role auth.RoleARN, assumed
+// through client with auth's external ID and session name. They are cached
+// and renewed before they expire, so long watch runs keep working.
+func assumeRoleCredentials(client stscreds.AssumeRoleAPIClient, auth types.AuthConfig) aws.CredentialsProvider {
+	return aws.NewCredentialsCache(stscreds.NewAssumeRoleProvider(client, auth.RoleARN, func(o *stscreds.AssumeRoleOptions) {
+		o.RoleSessionName = auth.RoleSessionName
+		if o.RoleSessionName == "" {
+			o.RoleSessionName = defaultRoleSessionName
+		}
+		if auth.ExternalID != "" {
+			o.ExternalID = aws.String(auth.ExternalID)
+		}
+	}))
+}
+
+// WithOperationTimeout derives a context that bounds a single S3 API call.
+// A non-positive timeout disables the deadline but still returns a cancel func.
+func WithOperationTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// newHTTPClient builds an HTTP client with custom TLS settings when a CA bundle
+// or insecure mode is configured. Returns nil to use the SDK default otherwise.
+func newHTTPClient(cfg *types.Config) (*awshttp.BuildableClient, error) {
+	if cfg.S3.CABundle == "" && !cfg.S3.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsCfg := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if cfg.S3.CABundle != "" {
+		pool, err := loadCABundle(cfg.S3.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("load CA bundle: %w", err)
+		}
+		tlsCfg.RootCAs = pool
+	}
+
+	if cfg.S3.InsecureSkipVerify {
+		tlsCfg.InsecureSkipVerify = true // #nosec G402 -- explicit opt-in for development endpoints
+	}
+
+	return awshttp.NewBuildableClient().WithTransportOptions(func(tr *http.Transport) {
+		tr.TLSClientConfig = tlsCfg
+	}), nil
+}
+
+// loadCABundle reads a PEM file and returns the system cert pool extended with
+// its certificates, so public endpoints keep working alongside the private CA.
+func loadCABundle(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,202 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+var testSSECKey = []byte("0123456789abcdef0123456789abcdef")
+
+func TestLoadSSECKey(t *testing.T) {
+	dir := t.TempDir()
+	encoded := base64.StdEncoding.EncodeToString(testSSECKey)
+	files := map[string]string{
+		"base64.key": encoded + "\n",
+		"raw.key":    string(testSSECKey),
+		"short.key":  "c2hvcnQ=",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		value   string
+		wantErr string
+	}{
+		{name: "inline base64", value: encoded},
+		{name: "file with base64", value: filepath.Join(dir, "base64.key")},
+		{name: "file with raw bytes", value: filepath.Join(dir, "raw.key")},
+		{name: "inline wrong length", value: "c2hvcnQ=", wantErr: "base64-encoded 32-byte key"},
+		{name: "file wrong length", value: filepath.Join(dir, "short.key"), wantErr: "does not hold"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := LoadSSECKey(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadSSECKey() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadSSECKey() error = %v", err)
+			}
+			if !bytes.Equal(key, testSSECKey) {
+				t.Errorf("LoadSSECKey() = %q, want %q", key, testSSECKey)
+			}
+		})
+	}
+}
+
+// sseCServer is a fake S3 endpoint recording the SSE-C headers of each
+// request, keyed by operation.
+type sseCServer struct {
+	mu      sync.Mutex
+	headers map[string]http.Header
+}
+
+func (s *sseCServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, _ = io.Copy(io.Discard, r.Body)
+	op := r.Method
+	switch q := r.URL.Query(); {
+	case q.Has("uploads"):
+		op = "CreateMultipartUpload"
+		_, _ = io.WriteString(w, `<InitiateMultipartUploadResult><Bucket>b</Bucket><Key>k</Key><UploadId>u1</UploadId></InitiateMultipartUploadResult>`)
+	case q.Has("partNumber"):
+		op = "UploadPart"
+		w.Header().Set("ETag", `"p1"`)
+	case q.Has("uploadId"):
+		op = "CompleteMultipartUpload"
+		_, _ = io.WriteString(w, `<CompleteMultipartUploadResult><ETag>"e"</ETag></CompleteMultipartUploadResult>`)
+	case strings.HasSuffix(r.URL.Path, "/plain"):
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	case r.Method == http.MethodGet:
+		_, _ = io.WriteString(w, "data")
+	}
+
+	s.mu.Lock()
+	s.headers[op] = r.Header.Clone()
+	s.mu.Unlock()
+}
+
+func TestSSECHeaders(t *testing.T) {
+	fake := &sseCServer{headers: make(map[string]http.Header)}
+	srv := httptest.NewServer(fake)
+	defer srv.Close()
+
+	cfg := &types.Config{
+		S3: types.S3Config{
+			Bucket:         "b",
+			Region:         "us-east-1",
+			Endpoint:       srv.URL,
+			ForcePathStyle: true,
+			SSECKey:        base64.StdEncoding.EncodeToString(testSSECKey),
+		},
+		Auth: types.AuthConfig{AccessKeyID: "AKID", SecretAccessKey: "SECRET"},
+	}
+	client, err := NewS3Client(context.Background(), cfg)
+	if err != nil {
+		t.Fatalf("NewS3Client() error = %v", err)
+	}
+
+	ctx := context.Background()
+	put := &s3.PutObjectInput{Bucket: aws.String("b"), Key: aws.String("k"), Body: strings.NewReader("data")}
+	if _, err := client.PutObject(ctx, put); err != nil {
+		t.Fatalf("PutObject: %v", err)
+	}
+	if put.SSECustomerKey != nil {
+		t.Error("PutObject input was modified to carry the key")
+	}
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{Bucket: aws.String("b"), Key: aws.String("k")})
+	if err != nil {
+		t.Fatalf("GetObject: %v", err)
+	}
+	_ = out.Body.Close()
+	if _, err := client.HeadObject(ctx, &s3.HeadObjectInput{Bucket: aws.String("b"), Key: aws.String("k")}); err != nil {
+		t.Fatalf("HeadObject: %v", err)
+	}
+	if _, err := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{Bucket: aws.String("b"), Key: aws.String("k")}); err != nil {
+		t.Fatalf("CreateMultipartUpload: %v", err)
+	}
+	if _, err := client.UploadPart(ctx, &s3.UploadPartInput{Bucket: aws.String("b"), Key: aws.String("k"), UploadId: aws.String("u1"), PartNumber: aws.Int32(1), Body: strings.NewReader("part")}); err != nil {
+		t.Fatalf("UploadPart: %v", err)
+	}
+	if _, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+		Bucket: aws.String("b"), Key: aws.String("k"), UploadId: aws.String("u1"),
+		MultipartUpload: &s3types.CompletedMultipartUpload{Parts: []s3types.CompletedPart{{ETag: aws.String(`"p1"`), PartNumber: aws.Int32(1)}}},
+	}); err != nil {
+		t.Fatalf("CompleteMultipartUpload: %v", err)
+	}
+	if _, err := client.DeleteObject(ctx, &s3.DeleteObjectInput{Bucket: aws.String("b"), Key: aws.String("k")}); err != nil {
+		t.Fatalf("DeleteObject: %v", err)
+	}
+
+	sum := md5.Sum(testSSECKey)
+	want := map[string]string{
+		"X-Amz-Server-Side-Encryption-Customer-Algorithm": "AES256",
+		"X-Amz-Server-Side-Encryption-Customer-Key":       base64.StdEncoding.EncodeToString(testSSECKey),
+		"X-Amz-Server-Side-Encryption-Customer-Key-Md5":   base64.StdEncoding.EncodeToString(sum[:]),
+	}
+	fake.mu.Lock()
+	defer fake.mu.Unlock()
+	for _, op := range []string{http.MethodPut, http.MethodGet, http.MethodHead, "CreateMultipartUpload", "UploadPart", "CompleteMultipartUpload"} {
+		h, ok := fake.headers[op]
+		if !ok {
+			t.Errorf("%s: no request recorded", op)
+			continue
+		}
+		for name, value := range want {
+			if got := h.Get(name); got != value {
+				t.Errorf("%s: %s = %q, want %q", op, name, got, value)
+			}
+		}
+	}
+	if h := fake.headers[http.MethodDelete]; h.Get("X-Amz-Server-Side-Encryption-Customer-Key") != "" {
+		t.Error("DeleteObject sent the key, which it does not need")
+	}
+}
+
+func TestSSECRejectedRead(t *testing.T) {
+	srv := httptest.NewServer(&sseCServer{headers: make(map[string]http.Header)})
+	defer srv.Close()
+
+	cfg := &types.Config{
+		S3: types.S3Config{
+			Region: "us-east-1", Endpoint: srv.URL, ForcePathStyle: true,
+			SSECKey: base64.StdEncoding.EncodeToString(testSSECKey),
+		},
+		Auth: types.AuthConfig{AccessKeyID: "AKID", SecretAccessKey: "SECRET"},
+	}
+	client, err := NewS3Client(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.HeadObject(context.Background(), &s3.HeadObjectInput{Bucket: aws.String("b"), Key: aws.String("plain")})
+	if err == nil || !strings.Contains(err.Error(), "not be encrypted with s3.sse_c_key") {
+		t.Fatalf("HeadObject error = %v, want a hint about the key", err)
+	}
+	if strings.Contains(err.Error(), cfg.S3.SSECKey) {
+		t.Errorf("error includes the key: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+// DefaultTarget names the destination configured by the top-level s3 and
+// auth sections.
+const DefaultTarget = "default"
+
+// targetNamePattern matches a valid name under targets.
+var targetNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]*$`)
+
+// resolveTargets applies defaults to each entry of cfg.Targets and validates
+// it. A target is a complete s3 and auth section: fields it leaves unset take
+// their defaults, not the values of the top-level sections.
+func resolveTargets(cfg *types.Config) error {
+	for _, name := range slices.Sorted(maps.Keys(cfg.Targets)) {
+		if name == DefaultTarget || !targetNamePattern.MatchString(name) {
+			return fmt.Errorf("targets: invalid name %q (letters, digits, '-' and '_'; %q is reserved)", name, DefaultTarget)
+		}
+		t := cfg.Targets[name]
+		c := *cfg
+		c.S3, c.Auth, c.Targets = t.S3, t.Auth, nil
+		if err := applyDefaults(&c); err != nil {
+			return fmt.Errorf("targets.%s: %w", name, err)
+		}
+		if err := validate(&c); err != nil {
+			return fmt.Errorf("targets.%s: %w", name, err)
+		}
+		cfg.Targets[name] = types.Target{S3: c.S3, Auth: c.Auth}
+	}
+
+	// Targets sharing a manifest would overwrite each other's upload history
+	seen := make(map[string]string)
+	for _, t := range Targets(cfg) {
+		manifest := t.S3.Endpoint + "|" + t.S3.Bucket + "|" + t.S3.Prefix + t.S3.ManifestKey
+		if other, ok := seen[manifest]; ok {
+			return fmt.Errorf("targets: %s and %s share the manifest s3://%s/%s%s; give them different prefixes",
+				other, t.TargetName, t.S3.Bucket, t.S3.Prefix, t.S3.ManifestKey)
+		}
+		seen[manifest] = t.TargetName
+	}
+	return nil
+}
+
+// TargetNames returns the names of the destinations cfg configures:
+// DefaultTarget followed by the entries of targets in name order.
+func TargetNames(cfg *types.Config) []string {
+	return append([]string{DefaultTarget}, slices.Sorted(maps.Keys(cfg.Targets))...)
+}
+
+// Target returns a copy of cfg whose S3 and Auth sections are those of the
+// named target. DefaultTarget selects the top-level sections.
+func Target(cfg *types.Config, name string) (*types.Config, error) {
+	c := *cfg
+	c.TargetName = name
+	if name == DefaultTarget {
+		return &c, nil
+	}
+	t, ok := cfg.Targets[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown target %q (configured: %s)", name, strings.Join(TargetNames(cfg), ", "))
+	}
+	c.S3, c.Auth = t.S3, t.Auth
+	return &c, nil
+}
+
+// Targets returns a copy of cfg for each configured destination, in
+// TargetNames order.
+func Targets(cfg *types.Config) []*types.Config {
+	var targets []*types.Config
+	for _, name := range TargetNames(cfg) {
+		t, _ := Target(cfg, name)
+		targets = append(targets, t)
+	}
+	return targets
+}
@@ -0,0 +1,156 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadTargets(t *testing.T) {
+	tests := []struct {
+		name    string
+		targets string
+		errMsg  string
+	}{
+		{
+			name: "valid",
+			targets: `
+  personal:
+    s3:
+      bucket: personal-logs
+      region: us-west-004
+      endpoint: https://s3.us-west-004.backblazeb2.com
+    auth:
+      profile: b2
+`,
+		},
+		{
+			name: "target missing region",
+			targets: `
+  personal:
+    s3:
+      bucket: personal-logs
+`,
+			errMsg: "targets.personal: s3.region is required",
+		},
+		{
+			name: "reserved name",
+			targets: `
+  default:
+    s3:
+      bucket: personal-logs
+      region: us-west-2
+`,
+			errMsg: `targets: invalid name "default"`,
+		},
+		{
+			name: "shared manifest",
+			targets: `
+  copy:
+    s3:
+      bucket: work-logs
+      region: us-east-1
+`,
+			errMsg: "targets: default and copy share the manifest s3://work-logs/claude-code/.manifest.json",
+		},
+		{
+			name: "same bucket, different prefix",
+			targets: `
+  copy:
+    s3:
+      bucket: work-logs
+      region: us-east-1
+      prefix: mirror
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			content := "s3:\n  bucket: work-logs\n  region: us-east-1\ntargets:" + tt.targets
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg, err := LoadStrict(path)
+			if tt.errMsg != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.errMsg) {
+					t.Fatalf("LoadStrict() error = %v, want one containing %q", err, tt.errMsg)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadStrict() error = %v", err)
+			}
+			for _, target := range cfg.Targets {
+				// Defaults apply to each target on its own
+				if target.S3.ManifestKey != defaultManifestKey || !strings.HasSuffix(target.S3.Prefix, "/") {
+					t.Errorf("target defaults not applied: %+v", target.S3)
+				}
+			}
+		})
+	}
+}
+
+func TestTarget(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `s3:
+  bucket: work-logs
+  region: us-east-1
+auth:
+  profile: work
+targets:
+  personal:
+    s3:
+      bucket: personal-logs
+      region: us-west-004
+    auth:
+      access_key_id: AKIA0000000000000000
+      secret_access_key: secret
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := TargetNames(cfg), []string{DefaultTarget, "personal"}; !slices.Equal(got, want) {
+		t.Errorf("TargetNames() = %v, want %v", got, want)
+	}
+
+	personal, err := Target(cfg, "personal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if personal.S3.Bucket != "personal-logs" || personal.Auth.Profile != "" || personal.TargetName != "personal" {
+		t.Errorf("Target(personal) = %+v, %+v, %q", personal.S3, personal.Auth, personal.TargetName)
+	}
+	if personal.S3.Prefix != defaultS3Prefix {
+		t.Errorf("personal prefix = %q, want the default %q", personal.S3.Prefix, defaultS3Prefix)
+	}
+
+	def, err := Target(cfg, DefaultTarget)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if def.S3.Bucket != "work-logs" || def.Auth.Profile != "work" {
+		t.Errorf("Target(default) = %+v, %+v", def.S3, def.Auth)
+	}
+
+	if _, err := Target(cfg, "missing"); err == nil || !strings.Contains(err.Error(), "configured: default, personal") {
+		t.Errorf("Target(missing) error = %v", err)
+	}
+
+	masked := Masked(cfg)
+	if got := masked.Targets["personal"].Auth.SecretAccessKey; got != "****" {
+		t.Errorf("masked target secret = %q", got)
+	}
+	if cfg.Targets["personal"].Auth.SecretAccessKey != "secret" {
+		t.Error("Masked modified the original config")
+	}
+}
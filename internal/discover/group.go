@@ -0,0 +1,49 @@
+package discover
+
+import (
+	"context"
+	"sync"
+)
+
+// group runs functions concurrently with a bounded number in flight. The first
+// error cancels the shared context and is returned by Wait, mirroring
+// golang.org/x/sync/errgroup with SetLimit.
+type group struct {
+	wg      sync.WaitGroup
+	sem     chan struct{}
+	cancel  context.CancelFunc
+	errOnce sync.Once
+	err     error
+}
+
+// newGroup returns a group running at most limit functions at once and a
+// context that is cancelled when any function fails or Wait returns.
+func newGroup(ctx context.Context, limit int) (*group, context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	return &group{sem: make(chan struct{}, limit), cancel: cancel}, ctx
+}
+
+// Go runs f in a new goroutine once a slot is free.
+func (g *group) Go(f func() error) {
+	g.sem <- struct{}{}
+	g.wg.Add(1)
+	go func() {
+		defer func() {
+			<-g.sem
+			g.wg.Done()
+		}()
+		if err := f(); err != nil {
+			g.errOnce.Do(func() {
+				g.err = err
+				g.cancel()
+			})
+		}
+	}()
+}
+
+// Wait blocks until all functions return and reports the first error.
+func (g *group) Wait() error {
+	g.wg.Wait()
+	g.cancel()
+	return g.err
+}
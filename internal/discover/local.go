@@ -8,73 +8,47 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
-	"sort"
-	"strings"
 
+	"github.com/13rac1/cclogs/internal/config"
 	"github.com/13rac1/cclogs/internal/types"
 )
 
-// DiscoverLocal discovers all local Claude Code projects and counts their .jsonl files.
-// It scans the projectsRoot directory, treating each immediate child directory as a project,
-// and recursively counts .jsonl files within each project.
+// DiscoverLocal discovers all local Claude Code projects and counts their log files.
+// It scans local.ProjectsRoot for project directories (see config.ProjectDirs),
+// and recursively counts files with one of local.Extensions (default .jsonl)
+// within each project.
 //
-// Returns an error if projectsRoot doesn't exist, is not a directory, or is not readable.
+// Returns an error if the projects root doesn't exist, is not a directory, or is not readable.
 // Individual project read errors are logged but don't fail the entire operation.
-func DiscoverLocal(projectsRoot string) ([]types.Project, error) {
-	// Verify projects root exists and is a directory
-	info, err := os.Stat(projectsRoot)
+func DiscoverLocal(local types.LocalConfig) ([]types.Project, error) {
+	dirs, err := config.ProjectDirs(local)
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("projects root does not exist: %s", projectsRoot)
-		}
-		return nil, fmt.Errorf("accessing projects root %s: %w", projectsRoot, err)
-	}
-
-	if !info.IsDir() {
-		return nil, fmt.Errorf("projects root is not a directory: %s", projectsRoot)
-	}
-
-	// Read immediate children of projects root
-	entries, err := os.ReadDir(projectsRoot)
-	if err != nil {
-		return nil, fmt.Errorf("reading projects root %s: %w", projectsRoot, err)
+		return nil, err
 	}
 
 	var projects []types.Project
 
 	// Process each directory as a project
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
-
-		projectName := entry.Name()
-		projectPath := filepath.Join(projectsRoot, projectName)
-
-		count, err := countJSONLFiles(projectPath)
+	for _, d := range dirs {
+		count, err := countJSONLFiles(d.Path, local.Extensions)
 		if err != nil {
 			// Log warning but continue with other projects
-			fmt.Fprintf(os.Stderr, "Warning: failed to count JSONL files in project %s: %v\n", projectName, err)
+			fmt.Fprintf(os.Stderr, "Warning: failed to count JSONL files in project %s: %v\n", d.Name, err)
 			continue
 		}
 
 		projects = append(projects, types.Project{
-			Name:       projectName,
-			LocalPath:  projectPath,
+			Name:       d.Name,
+			LocalPath:  d.Path,
 			LocalCount: count,
 		})
 	}
 
-	// Sort by name for deterministic output
-	sort.Slice(projects, func(i, j int) bool {
-		return projects[i].Name < projects[j].Name
-	})
-
 	return projects, nil
 }
 
-// countJSONLFiles recursively counts .jsonl files in the given directory.
-func countJSONLFiles(root string) (int, error) {
+// countJSONLFiles recursively counts files with one of exts in the given directory.
+func countJSONLFiles(root string, exts []string) (int, error) {
 	count := 0
 
 	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
@@ -86,7 +60,7 @@ func countJSONLFiles(root string) (int, error) {
 			return nil
 		}
 
-		if strings.HasSuffix(strings.ToLower(d.Name()), ".jsonl") {
+		if config.HasLogExtension(d.Name(), exts) {
 			count++
 		}
 
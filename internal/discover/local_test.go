@@ -195,7 +195,7 @@ func TestDiscoverLocal(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			projectsRoot := tt.setupFunc(t)
 
-			projects, err := DiscoverLocal(projectsRoot)
+			projects, err := DiscoverLocal(types.LocalConfig{ProjectsRoot: projectsRoot})
 
 			if tt.wantErr {
 				if err == nil {
@@ -262,3 +262,39 @@ func indexOf(s, substr string) int {
 	}
 	return -1
 }
+
+func TestCountJSONLFilesExtensions(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"a.jsonl", "B.JSONL", "c.ndjson", "d.log", "e.Log", "notes.txt", "f.jsonl.bak", "sub/g.ndjson",
+	} {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		createFile(t, path)
+	}
+
+	tests := []struct {
+		name string
+		exts []string
+		want int
+	}{
+		{"default", nil, 2},
+		{"jsonl only", []string{".jsonl"}, 2},
+		{"ndjson and log", []string{".ndjson", ".log"}, 4},
+		{"all three", []string{".jsonl", ".ndjson", ".log"}, 6},
+		{"upper case config", []string{".LOG"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := countJSONLFiles(root, tt.exts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("countJSONLFiles(%v) = %d, want %d", tt.exts, got, tt.want)
+			}
+		})
+	}
+}
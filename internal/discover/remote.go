@@ -6,46 +6,84 @@ import (
 	"path"
 	"sort"
 	"strings"
+	"time"
 
+	"github.com/13rac1/cclogs/internal/codec"
+	"github.com/13rac1/cclogs/internal/config"
 	"github.com/13rac1/cclogs/internal/manifest"
 	"github.com/13rac1/cclogs/internal/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 )
 
+// remoteCountWorkers bounds concurrent per-prefix listings in DiscoverRemote.
+const remoteCountWorkers = 8
+
 // DiscoverRemote discovers projects in S3 by listing prefixes.
-// Each immediate child prefix under bucket/prefix/ is treated as a project.
-// For each project, counts .jsonl files (case-insensitive).
-func DiscoverRemote(ctx context.Context, client *s3.Client, bucket, prefix string) ([]types.Project, error) {
+// Each immediate child prefix under bucket/prefix/ is listed, several at a
+// time, and the files in it with one of exts (case-insensitive, default
+// .jsonl) are counted towards the project their key names: projectDepth
+// segments below prefix, after any YYYY/MM/DD/ date partition
+// (--date-partition).
+// Each list request is bounded by timeout (non-positive disables the deadline).
+func DiscoverRemote(ctx context.Context, client s3.ListObjectsV2APIClient, bucket, prefix string, exts []string, projectDepth int, timeout time.Duration) ([]types.Project, error) {
 	// Ensure prefix ends with / for consistent prefix matching
 	if prefix != "" && !strings.HasSuffix(prefix, "/") {
 		prefix = prefix + "/"
 	}
 
-	// Discover project directories
-	projectPrefixes, err := listProjectPrefixes(ctx, client, bucket, prefix)
+	// Discover top-level directories: projects, their parents, or date partitions
+	childPrefixes, err := listProjectPrefixes(ctx, client, bucket, prefix, timeout)
 	if err != nil {
 		return nil, fmt.Errorf("list project prefixes: %w", err)
 	}
 
-	var projects []types.Project
-
-	// Count JSONL files in each project
-	for _, projectPrefix := range projectPrefixes {
-		projectName := extractProjectName(projectPrefix, prefix)
-		if projectName == "" {
-			continue
-		}
+	// Count files under each prefix concurrently; each worker writes only
+	// its own slot so results merge in prefix order
+	counts := make([]map[string]*types.Project, len(childPrefixes))
+	g, gctx := newGroup(ctx, remoteCountWorkers)
+	for i, childPrefix := range childPrefixes {
+		g.Go(func() error {
+			byProject := make(map[string]*types.Project)
+			err := listLogObjects(gctx, client, bucket, childPrefix, exts, timeout, func(key string, size int64) {
+				name := manifest.ProjectOf(key, prefix, projectDepth)
+				if name == "" {
+					return
+				}
+				p := byProject[name]
+				if p == nil {
+					p = &types.Project{Name: name, RemotePath: prefix + name + "/"}
+					byProject[name] = p
+				}
+				p.RemoteCount++
+				p.RemoteBytes += size
+			})
+			if err != nil {
+				return fmt.Errorf("count JSONL files in %s: %w", extractProjectName(childPrefix, prefix), err)
+			}
+			counts[i] = byProject
+			return nil
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
 
-		count, err := countRemoteJSONLFiles(ctx, client, bucket, projectPrefix)
-		if err != nil {
-			return nil, fmt.Errorf("count JSONL files in %s: %w", projectName, err)
+	// A project's files may be spread over several date partitions
+	merged := make(map[string]*types.Project)
+	var projects []types.Project
+	for _, byProject := range counts {
+		for name, p := range byProject {
+			if m := merged[name]; m != nil {
+				m.RemoteCount += p.RemoteCount
+				m.RemoteBytes += p.RemoteBytes
+				continue
+			}
+			merged[name] = p
 		}
-
-		projects = append(projects, types.Project{
-			Name:        projectName,
-			RemotePath:  projectPrefix,
-			RemoteCount: count,
-		})
+	}
+	for _, p := range merged {
+		projects = append(projects, *p)
 	}
 
 	// Sort by name for deterministic output
@@ -56,6 +94,29 @@ func DiscoverRemote(ctx context.Context, client *s3.Client, bucket, prefix strin
 	return projects, nil
 }
 
+// DiscoverHosts returns the machine segments under prefix for the by_host key layout.
+// Each immediate child prefix of bucket/prefix/ is treated as a machine.
+func DiscoverHosts(ctx context.Context, client s3.ListObjectsV2APIClient, bucket, prefix string, timeout time.Duration) ([]string, error) {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+
+	hostPrefixes, err := listProjectPrefixes(ctx, client, bucket, prefix, timeout)
+	if err != nil {
+		return nil, fmt.Errorf("list host prefixes: %w", err)
+	}
+
+	var hosts []string
+	for _, hp := range hostPrefixes {
+		if name := extractProjectName(hp, prefix); name != "" {
+			hosts = append(hosts, name)
+		}
+	}
+
+	sort.Strings(hosts)
+	return hosts, nil
+}
+
 // DiscoverFromManifest builds a project list from manifest entries.
 // This is more efficient than DiscoverRemote as it requires only one S3 GET.
 func DiscoverFromManifest(m *manifest.Manifest, prefix string) []types.Project {
@@ -64,14 +125,17 @@ func DiscoverFromManifest(m *manifest.Manifest, prefix string) []types.Project {
 		prefix = prefix + "/"
 	}
 
-	counts := m.CountByProject(prefix)
+	usage := m.UsageByProject(prefix)
 
 	var projects []types.Project
-	for name, count := range counts {
+	for name, u := range usage {
 		projects = append(projects, types.Project{
-			Name:        name,
-			RemotePath:  prefix + name + "/",
-			RemoteCount: count,
+			Name:             name,
+			RemotePath:       prefix + name + "/",
+			RemoteCount:      u.Files,
+			RemoteBytes:      u.Bytes,
+			RemoteApprox:     u.Approximate,
+			RemoteLastUpload: u.LastUpload,
 		})
 	}
 
@@ -82,9 +146,29 @@ func DiscoverFromManifest(m *manifest.Manifest, prefix string) []types.Project {
 	return projects
 }
 
-// listProjectPrefixes returns all immediate child prefixes under bucket/prefix/.
+// AddManifestFiles sets the RemoteFiles of each project from the entries of m
+// under prefix, in key order.
+func AddManifestFiles(projects []types.Project, m *manifest.Manifest, prefix string) {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+
+	byProject := make(map[string][]types.RemoteFile)
+	for key, entry := range m.Files {
+		project := m.Project(key, prefix)
+		byProject[project] = append(byProject[project], types.RemoteFile{Key: key, Size: entry.Size, Tags: entry.Tags()})
+	}
+	for i := range projects {
+		files := byProject[projects[i].Name]
+		sort.Slice(files, func(a, b int) bool { return files[a].Key < files[b].Key })
+		projects[i].RemoteFiles = files
+	}
+}
+
+// listProjectPrefixes returns all immediate child prefixes under bucket/prefix/,
+// except the manifest's backups.
 // Uses pagination to handle large buckets.
-func listProjectPrefixes(ctx context.Context, client *s3.Client, bucket, prefix string) ([]string, error) {
+func listProjectPrefixes(ctx context.Context, client s3.ListObjectsV2APIClient, bucket, prefix string, timeout time.Duration) ([]string, error) {
 	var prefixes []string
 
 	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
@@ -94,13 +178,15 @@ func listProjectPrefixes(ctx context.Context, client *s3.Client, bucket, prefix
 	})
 
 	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+		opCtx, cancel := config.WithOperationTimeout(ctx, timeout)
+		page, err := paginator.NextPage(opCtx)
+		cancel()
 		if err != nil {
 			return nil, fmt.Errorf("list objects: %w", err)
 		}
 
 		for _, cp := range page.CommonPrefixes {
-			if cp.Prefix != nil {
+			if cp.Prefix != nil && path.Base(*cp.Prefix) != manifest.HistoryDir {
 				prefixes = append(prefixes, *cp.Prefix)
 			}
 		}
@@ -109,30 +195,71 @@ func listProjectPrefixes(ctx context.Context, client *s3.Client, bucket, prefix
 	return prefixes, nil
 }
 
-// countRemoteJSONLFiles counts .jsonl files (case-insensitive) under the given prefix.
-// Uses pagination to handle projects with many files.
-func countRemoteJSONLFiles(ctx context.Context, client *s3.Client, bucket, prefix string) (int, error) {
+// CountRemote counts the files with one of exts under prefix, including any
+// nested under project prefixes, with a single listing.
+func CountRemote(ctx context.Context, client s3.ListObjectsV2APIClient, bucket, prefix string, exts []string, timeout time.Duration) (int, error) {
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix = prefix + "/"
+	}
+	count, _, err := countRemoteJSONLFiles(ctx, client, bucket, prefix, exts, timeout)
+	return count, err
+}
+
+// CountsDisagree reports whether a manifest recording manifestFiles files
+// is out of step with the listedFiles a listing found: they differ by more
+// than a tenth of the larger count. Small archives disagree on any
+// difference.
+func CountsDisagree(manifestFiles, listedFiles int) bool {
+	diff := manifestFiles - listedFiles
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff > max(manifestFiles, listedFiles)/10
+}
+
+// countRemoteJSONLFiles counts files with one of exts (case-insensitive) under the given prefix,
+// compressed or not, and totals their size.
+func countRemoteJSONLFiles(ctx context.Context, client s3.ListObjectsV2APIClient, bucket, prefix string, exts []string, timeout time.Duration) (int, int64, error) {
 	count := 0
+	var size int64
+	err := listLogObjects(ctx, client, bucket, prefix, exts, timeout, func(key string, n int64) {
+		count++
+		size += n
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	return count, size, nil
+}
 
+// listLogObjects calls fn with the key and size of every object under prefix
+// that is a file with one of exts (case-insensitive), compressed or not.
+// Uses pagination to handle projects with many files.
+func listLogObjects(ctx context.Context, client s3.ListObjectsV2APIClient, bucket, prefix string, exts []string, timeout time.Duration, fn func(key string, size int64)) error {
 	paginator := s3.NewListObjectsV2Paginator(client, &s3.ListObjectsV2Input{
 		Bucket: &bucket,
 		Prefix: &prefix,
 	})
 
 	for paginator.HasMorePages() {
-		page, err := paginator.NextPage(ctx)
+		opCtx, cancel := config.WithOperationTimeout(ctx, timeout)
+		page, err := paginator.NextPage(opCtx)
+		cancel()
 		if err != nil {
-			return 0, fmt.Errorf("list objects: %w", err)
+			return fmt.Errorf("list objects: %w", err)
 		}
 
 		for _, obj := range page.Contents {
-			if obj.Key != nil && strings.HasSuffix(strings.ToLower(*obj.Key), ".jsonl") {
-				count++
+			if obj.Key == nil {
+				continue
+			}
+			// Compressed objects carry the codec's suffix after the log extension
+			if _, base := codec.FromKey(*obj.Key); config.HasLogExtension(base, exts) {
+				fn(*obj.Key, aws.ToInt64(obj.Size))
 			}
 		}
 	}
-
-	return count, nil
+	return nil
 }
 
 // extractProjectName extracts the project name from an S3 prefix.
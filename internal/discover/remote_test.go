@@ -1,10 +1,22 @@
 package discover
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
 func TestExtractProjectName(t *testing.T) {
@@ -192,3 +204,282 @@ func TestDiscoverFromManifest(t *testing.T) {
 		})
 	}
 }
+
+// listingS3Client serves ListObjectsV2 from an in-memory key set, paging two
+// keys at a time so pagination runs within each project.
+type listingS3Client struct {
+	keys    []string
+	delay   time.Duration
+	failFor string // Prefix whose listing fails
+
+	mu       sync.Mutex
+	inFlight int
+	maxSeen  int
+}
+
+func (c *listingS3Client) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	c.mu.Lock()
+	c.inFlight++
+	c.maxSeen = max(c.maxSeen, c.inFlight)
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		c.inFlight--
+		c.mu.Unlock()
+	}()
+
+	prefix := aws.ToString(params.Prefix)
+	if c.failFor != "" && prefix == c.failFor {
+		return nil, errors.New("access denied")
+	}
+	time.Sleep(c.delay)
+
+	out := &s3.ListObjectsV2Output{}
+	if aws.ToString(params.Delimiter) == "/" {
+		seen := make(map[string]bool)
+		for _, k := range c.keys {
+			rest, ok := strings.CutPrefix(k, prefix)
+			if !ok {
+				continue
+			}
+			if dir, _, found := strings.Cut(rest, "/"); found && !seen[dir] {
+				seen[dir] = true
+				out.CommonPrefixes = append(out.CommonPrefixes, s3types.CommonPrefix{Prefix: aws.String(prefix + dir + "/")})
+			}
+		}
+		return out, nil
+	}
+
+	var matching []string
+	for _, k := range c.keys {
+		if strings.HasPrefix(k, prefix) {
+			matching = append(matching, k)
+		}
+	}
+	start := 0
+	if tok := aws.ToString(params.ContinuationToken); tok != "" {
+		start, _ = strconv.Atoi(tok)
+	}
+	end := min(start+2, len(matching))
+	for _, k := range matching[start:end] {
+		out.Contents = append(out.Contents, s3types.Object{Key: aws.String(k), Size: aws.Int64(int64(len(k)))})
+	}
+	if end < len(matching) {
+		out.IsTruncated = aws.Bool(true)
+		out.NextContinuationToken = aws.String(strconv.Itoa(end))
+	}
+	return out, nil
+}
+
+func TestDiscoverRemoteConcurrent(t *testing.T) {
+	var keys []string
+	want := make(map[string]int)
+	for i := range 20 {
+		project := fmt.Sprintf("project-%02d", i)
+		n := i%5 + 1
+		for j := range n {
+			keys = append(keys, fmt.Sprintf("claude-code/%s/s%d.jsonl", project, j))
+		}
+		keys = append(keys, "claude-code/"+project+"/notes.txt")
+		want[project] = n
+	}
+	// Manifest backups are not a project
+	keys = append(keys, "claude-code/.manifest-history/20250601T120000.000Z.manifest.json")
+
+	client := &listingS3Client{keys: keys, delay: time.Millisecond}
+	projects, err := DiscoverRemote(context.Background(), client, "bucket", "claude-code/", nil, 1, 0)
+	if err != nil {
+		t.Fatalf("DiscoverRemote failed: %v", err)
+	}
+
+	if len(projects) != len(want) {
+		t.Fatalf("got %d projects, want %d", len(projects), len(want))
+	}
+	for i, p := range projects {
+		if i > 0 && projects[i-1].Name >= p.Name {
+			t.Errorf("projects not sorted: %q before %q", projects[i-1].Name, p.Name)
+		}
+		if p.RemoteCount != want[p.Name] {
+			t.Errorf("%s: RemoteCount = %d, want %d", p.Name, p.RemoteCount, want[p.Name])
+		}
+		if p.RemoteBytes == 0 {
+			t.Errorf("%s: RemoteBytes not set", p.Name)
+		}
+		if p.RemotePath != "claude-code/"+p.Name+"/" {
+			t.Errorf("%s: RemotePath = %q", p.Name, p.RemotePath)
+		}
+	}
+
+	if client.maxSeen < 2 {
+		t.Errorf("max concurrent listings = %d, want concurrency", client.maxSeen)
+	}
+	if client.maxSeen > remoteCountWorkers {
+		t.Errorf("max concurrent listings = %d, exceeds limit %d", client.maxSeen, remoteCountWorkers)
+	}
+}
+
+func TestDiscoverRemoteDatePartitions(t *testing.T) {
+	client := &listingS3Client{keys: []string{
+		"claude-code/app/s1.jsonl",
+		"claude-code/2025/03/01/app/s2.jsonl",
+		"claude-code/2025/03/02/app/s1.jsonl",
+		"claude-code/2025/03/02/web/s1.jsonl.gz",
+		"claude-code/2024/notes/s1.jsonl", // A project that only looks like a year
+	}}
+
+	projects, err := DiscoverRemote(context.Background(), client, "bucket", "claude-code/", nil, 1, 0)
+	if err != nil {
+		t.Fatalf("DiscoverRemote failed: %v", err)
+	}
+
+	got := make(map[string]int)
+	for _, p := range projects {
+		got[p.Name] = p.RemoteCount
+		if p.RemotePath != "claude-code/"+p.Name+"/" {
+			t.Errorf("%s: RemotePath = %q", p.Name, p.RemotePath)
+		}
+	}
+	want := map[string]int{"2024": 1, "app": 3, "web": 1}
+	if !maps.Equal(got, want) {
+		t.Errorf("projects = %v, want %v", got, want)
+	}
+}
+
+func TestDiscoverRemoteProjectDepth(t *testing.T) {
+	client := &listingS3Client{keys: []string{
+		"claude-code/acme/app/s1.jsonl",
+		"claude-code/acme/app/sub/s2.jsonl",
+		"claude-code/acme/web/s1.jsonl.gz",
+		"claude-code/2025/03/01/acme/app/s3.jsonl",
+		"claude-code/acme/loose.jsonl", // Not deep enough to name a project
+	}}
+
+	projects, err := DiscoverRemote(context.Background(), client, "bucket", "claude-code/", nil, 2, 0)
+	if err != nil {
+		t.Fatalf("DiscoverRemote failed: %v", err)
+	}
+
+	got := make(map[string]int)
+	for _, p := range projects {
+		got[p.Name] = p.RemoteCount
+	}
+	want := map[string]int{"acme/app": 3, "acme/web": 1}
+	if !maps.Equal(got, want) {
+		t.Errorf("projects = %v, want %v", got, want)
+	}
+}
+
+func TestCountRemoteJSONLFilesExtensions(t *testing.T) {
+	client := &listingS3Client{keys: []string{
+		"claude-code/p/a.jsonl",
+		"claude-code/p/b.NDJSON",
+		"claude-code/p/c.log",
+		"claude-code/p/notes.txt",
+		"claude-code/p/d.jsonl.gz",
+		"claude-code/p/e.log.zst",
+		"claude-code/p/notes.txt.gz",
+	}}
+
+	tests := []struct {
+		exts []string
+		want int
+	}{
+		{nil, 2},
+		{[]string{".jsonl", ".ndjson"}, 3},
+		{[]string{".jsonl", ".ndjson", ".log"}, 5},
+	}
+	for _, tt := range tests {
+		got, _, err := countRemoteJSONLFiles(context.Background(), client, "bucket", "claude-code/p/", tt.exts, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("countRemoteJSONLFiles(%v) = %d, want %d", tt.exts, got, tt.want)
+		}
+	}
+}
+
+func TestDiscoverRemotePropagatesError(t *testing.T) {
+	client := &listingS3Client{
+		keys: []string{
+			"claude-code/a/s.jsonl",
+			"claude-code/b/s.jsonl",
+			"claude-code/c/s.jsonl",
+		},
+		failFor: "claude-code/b/",
+	}
+
+	_, err := DiscoverRemote(context.Background(), client, "bucket", "claude-code/", nil, 1, 0)
+	if err == nil {
+		t.Fatal("expected error, got nil")
+	}
+	if !strings.Contains(err.Error(), "count JSONL files in b") {
+		t.Errorf("error = %v, want failing project named", err)
+	}
+}
+
+func TestCountRemote(t *testing.T) {
+	client := &listingS3Client{keys: []string{
+		"claude-code/a/s1.jsonl",
+		"claude-code/a/sub/s2.jsonl",
+		"claude-code/b/s1.jsonl",
+		"claude-code/b/notes.txt",
+		"claude-code/.manifest.json",
+		"other/c/s1.jsonl",
+	}}
+	got, err := CountRemote(context.Background(), client, "bucket", "claude-code", nil, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != 3 {
+		t.Errorf("CountRemote() = %d, want 3", got)
+	}
+}
+
+func TestCountsDisagree(t *testing.T) {
+	tests := []struct {
+		manifest, listed int
+		want             bool
+	}{
+		{0, 0, false},
+		{5, 5, false},
+		{5, 6, true},
+		{0, 3, true},
+		{100, 108, false},
+		{100, 115, true},
+		{1000, 40, true},
+	}
+	for _, tt := range tests {
+		if got := CountsDisagree(tt.manifest, tt.listed); got != tt.want {
+			t.Errorf("CountsDisagree(%d, %d) = %v, want %v", tt.manifest, tt.listed, got, tt.want)
+		}
+	}
+}
+
+func TestAddManifestFiles(t *testing.T) {
+	m := manifest.New()
+	m.Files["claude-code/app/b.jsonl"] = manifest.FileEntry{Size: 20, Redactions: map[string]int64{"EMAIL": 1}}
+	m.Files["claude-code/app/a.jsonl"] = manifest.FileEntry{Size: 10}
+	m.Files["claude-code/web/c.jsonl"] = manifest.FileEntry{Size: 30, Redactions: map[string]int64{"JWT": 2, "AWS_KEY": 1}}
+
+	projects := DiscoverFromManifest(m, "claude-code/")
+	AddManifestFiles(projects, m, "claude-code/")
+
+	want := map[string][]types.RemoteFile{
+		"app": {
+			{Key: "claude-code/app/a.jsonl", Size: 10, Tags: []string{}},
+			{Key: "claude-code/app/b.jsonl", Size: 20, Tags: []string{"EMAIL"}},
+		},
+		"web": {
+			{Key: "claude-code/web/c.jsonl", Size: 30, Tags: []string{"AWS_KEY", "JWT"}},
+		},
+	}
+	if len(projects) != len(want) {
+		t.Fatalf("projects = %+v, want %d", projects, len(want))
+	}
+	for _, p := range projects {
+		if !reflect.DeepEqual(p.RemoteFiles, want[p.Name]) {
+			t.Errorf("%s files = %+v, want %+v", p.Name, p.RemoteFiles, want[p.Name])
+		}
+	}
+}
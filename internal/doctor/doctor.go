@@ -1,6 +1,9 @@
 // Package doctor provides configuration and connectivity validation for cclogs.
 // It checks that the config is valid, local projects directory exists,
 // and S3 connectivity works with the configured credentials.
+//
+// Checks return structured Results so they can be printed by the doctor
+// command or reused as an upload preflight.
 package doctor
 
 import (
@@ -8,168 +11,293 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"sort"
+	"strings"
+	"time"
 
 	"github.com/13rac1/cclogs/internal/config"
 	"github.com/13rac1/cclogs/internal/discover"
+	"github.com/13rac1/cclogs/internal/identity"
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/msg"
+	"github.com/13rac1/cclogs/internal/output"
+	"github.com/13rac1/cclogs/internal/s3errors"
 	"github.com/13rac1/cclogs/internal/types"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
 )
 
 const (
-	colorGreen = "\033[32m"
-	colorRed   = "\033[31m"
-	colorReset = "\033[0m"
+	colorGreen  = "\033[32m"
+	colorRed    = "\033[31m"
+	colorYellow = "\033[33m"
+	colorReset  = "\033[0m"
 )
 
-func checkmark() string {
-	return colorGreen + "✓" + colorReset
+// preflightTimeout bounds the remote calls made by Preflight.
+const preflightTimeout = 10 * time.Second
+
+// Status is the outcome of a single check.
+type Status string
+
+const (
+	Pass Status = "pass"
+	Warn Status = "warn"
+	Fail Status = "fail"
+)
+
+// Result is the outcome of one check: a summary line plus detail lines
+// (remediation hints, error dumps) printed beneath it.
+type Result struct {
+	Name    string   `json:"name"`           // Stable identifier, e.g. "remote.bucket"
+	Code    string   `json:"code,omitempty"` // Message ID of the outcome, e.g. "doctor.bucket.missing"
+	Status  Status   `json:"status"`
+	Message string   `json:"message"`
+	Details []string `json:"details,omitempty"`
+}
+
+func pass(name string, m msg.Message) Result {
+	return Result{Name: name, Code: m.ID, Status: Pass, Message: m.String()}
+}
+
+func warn(name string, m msg.Message, details ...string) Result {
+	return Result{Name: name, Code: m.ID, Status: Warn, Message: m.String(), Details: details}
+}
+
+func fail(name string, m msg.Message, details ...string) Result {
+	return Result{Name: name, Code: m.ID, Status: Fail, Message: m.String(), Details: details}
+}
+
+// hint renders a catalog message as a remediation detail line.
+func hint(id string, args msg.Args) string {
+	return "→ " + msg.Text(id, args)
+}
+
+// errorDetail renders err as a detail line.
+func errorDetail(err error) string {
+	return hint("doctor.error", msg.Args{"Err": err})
+}
+
+// Passed reports whether no result failed. Warnings do not fail a check run.
+func Passed(results []Result) bool {
+	for _, r := range results {
+		if r.Status == Fail {
+			return false
+		}
+	}
+	return true
+}
+
+// Failures returns the failed results.
+func Failures(results []Result) []Result {
+	var failed []Result
+	for _, r := range results {
+		if r.Status == Fail {
+			failed = append(failed, r)
+		}
+	}
+	return failed
 }
 
-func crossmark() string {
-	return colorRed + "✗" + colorReset
+// PrintResults prints each result with its status mark and details.
+func PrintResults(results []Result) {
+	for _, r := range results {
+		fmt.Fprintf(output.Human(), "  %s %s\n", mark(r.Status), r.Message)
+		for _, d := range r.Details {
+			fmt.Fprintf(output.Human(), "    %s\n", d)
+		}
+	}
 }
 
-// dumpAWSError logs detailed information about AWS API errors.
-func dumpAWSError(err error) {
-	fmt.Printf("  → Error details:\n")
-	fmt.Printf("    Type: %T\n", err)
+func mark(s Status) string {
+	switch s {
+	case Pass:
+		return colorGreen + "✓" + colorReset
+	case Warn:
+		return colorYellow + "!" + colorReset
+	default:
+		return colorRed + "✗" + colorReset
+	}
+}
+
+// awsErrorDetails describes an AWS API error for display.
+func awsErrorDetails(err error) []string {
+	details := []string{
+		"→ Error details:",
+		fmt.Sprintf("  Type: %T", err),
+	}
 
 	var apiErr smithy.APIError
 	if errors.As(err, &apiErr) {
-		fmt.Printf("    API Code: %s\n", apiErr.ErrorCode())
-		fmt.Printf("    API Message: %s\n", apiErr.ErrorMessage())
-		fmt.Printf("    API Fault: %v\n", apiErr.ErrorFault())
+		details = append(details,
+			fmt.Sprintf("  API Code: %s", apiErr.ErrorCode()),
+			fmt.Sprintf("  API Message: %s", apiErr.ErrorMessage()),
+			fmt.Sprintf("  API Fault: %v", apiErr.ErrorFault()))
 	}
 
 	var respErr *awshttp.ResponseError
 	if errors.As(err, &respErr) {
-		fmt.Printf("    HTTP Status: %d\n", respErr.HTTPStatusCode())
-		fmt.Printf("    Request ID: %s\n", respErr.ServiceRequestID())
+		details = append(details,
+			fmt.Sprintf("  HTTP Status: %d", respErr.HTTPStatusCode()),
+			fmt.Sprintf("  Request ID: %s", respErr.ServiceRequestID()))
 		if respErr.Response != nil && respErr.Response.Header != nil {
-			fmt.Printf("    Response Headers:\n")
+			details = append(details, "  Response Headers:")
 			for k, v := range respErr.Response.Header {
-				fmt.Printf("      %s: %v\n", k, v)
+				details = append(details, fmt.Sprintf("    %s: %v", k, v))
 			}
 		}
 	}
+
+	return details
 }
 
 // checkRemoteConnectivity verifies S3 bucket access using HeadBucket.
-func checkRemoteConnectivity(ctx context.Context, client *s3.Client, bucket, region string) bool {
-	_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{
-		Bucket: aws.String(bucket),
+func checkRemoteConnectivity(ctx context.Context, client *s3.Client, cfg *types.Config) Result {
+	headCtx, cancel := config.WithOperationTimeout(ctx, cfg.S3.OperationTimeout)
+	defer cancel()
+
+	_, err := client.HeadBucket(headCtx, &s3.HeadBucketInput{
+		Bucket: aws.String(cfg.S3.Bucket),
 	})
+	if err == nil {
+		return pass("remote.bucket", msg.New("doctor.remote.bucket.ok", msg.Args{"Bucket": config.BucketDisplayName(cfg.S3.Bucket), "Region": cfg.S3.Region}))
+	}
 
-	if err != nil {
-		fmt.Printf("  %s Failed to connect to S3 bucket\n", crossmark())
-		fmt.Printf("    → Error: %v\n", err)
-		dumpAWSError(err)
-		fmt.Printf("    → Check your AWS credentials and bucket permissions\n")
-		return false
+	var ssoErr *s3errors.SSOExpiredError
+	if errors.As(s3errors.SSOError(err, cfg), &ssoErr) {
+		return fail("remote.bucket", msg.New("doctor.remote.bucket.sso_expired", nil), "→ "+ssoErr.Error())
 	}
 
-	return true
+	details := append([]string{errorDetail(err)}, awsErrorDetails(err)...)
+
+	var sigErr *s3errors.SignatureMismatchError
+	if errors.As(classifyForbidden(ctx, client, cfg, err), &sigErr) {
+		details = append(details, hint("doctor.remote.bucket.signature", nil))
+		for _, step := range s3errors.Checklist(sigErr, cfg) {
+			details = append(details, "  - "+step)
+		}
+		return fail("remote.bucket", msg.New("doctor.remote.bucket.failed", nil), details...)
+	}
+
+	details = append(details, hint("doctor.remote.bucket.check_credentials", nil))
+	return fail("remote.bucket", msg.New("doctor.remote.bucket.failed", nil), details...)
 }
 
-// RunChecks performs all doctor checks and returns whether all passed.
-// Remote connectivity checks can be skipped by setting skipRemote to true.
-func RunChecks(cfg *types.Config, configPath string, skipRemote bool) bool {
-	fmt.Println("cclogs doctor - Configuration and connectivity check")
-	fmt.Println()
+// classifyForbidden classifies a HeadBucket error. HEAD responses carry no
+// error body, so a 403 is retried as ListObjectsV2 to learn the error code.
+func classifyForbidden(ctx context.Context, client *s3.Client, cfg *types.Config, err error) error {
+	if classified := s3errors.Classify(err); classified != nil {
+		return classified
+	}
 
-	allPassed := true
+	var respErr *awshttp.ResponseError
+	if !errors.As(err, &respErr) || respErr.HTTPStatusCode() != 403 {
+		return nil
+	}
 
-	// Configuration checks
-	fmt.Println("Configuration:")
-	fmt.Printf("  %s Config file loaded: %s\n", checkmark(), configPath)
+	ctx, cancel := config.WithOperationTimeout(ctx, cfg.S3.OperationTimeout)
+	defer cancel()
+
+	_, listErr := client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+		Bucket:  aws.String(cfg.S3.Bucket),
+		MaxKeys: aws.Int32(1),
+	})
+	return s3errors.Classify(listErr)
+}
+
+// ConfigChecks validates the loaded configuration.
+func ConfigChecks(cfg *types.Config, configPath string) []Result {
+	results := []Result{pass("config.file", msg.New("doctor.config.file.ok", msg.Args{"Path": configPath}))}
 
 	if cfg.S3.Bucket == "" || cfg.S3.Bucket == "YOUR-BUCKET-NAME" {
-		fmt.Printf("  %s S3 bucket not configured (still set to placeholder)\n", crossmark())
-		fmt.Printf("    → Edit %s and set s3.bucket\n", configPath)
-		allPassed = false
+		results = append(results, fail("config.bucket", msg.New("doctor.config.bucket.missing", nil),
+			hint("doctor.config.edit", msg.Args{"Path": configPath, "Setting": "s3.bucket"})))
 	} else {
-		fmt.Printf("  %s S3 bucket configured: %s\n", checkmark(), cfg.S3.Bucket)
+		results = append(results, pass("config.bucket", msg.New("doctor.config.bucket.ok", msg.Args{"Bucket": config.BucketDisplayName(cfg.S3.Bucket)})))
 	}
 
 	if cfg.S3.Region == "" {
-		fmt.Printf("  %s S3 region not configured\n", crossmark())
-		fmt.Printf("    → Edit %s and set s3.region\n", configPath)
-		allPassed = false
+		results = append(results, fail("config.region", msg.New("doctor.config.region.missing", nil),
+			hint("doctor.config.edit", msg.Args{"Path": configPath, "Setting": "s3.region"})))
 	} else {
-		fmt.Printf("  %s S3 region configured: %s\n", checkmark(), cfg.S3.Region)
+		results = append(results, pass("config.region", msg.New("doctor.config.region.ok", msg.Args{"Region": cfg.S3.Region})))
 	}
 
-	if cfg.S3.Prefix == "" {
-		fmt.Printf("  %s S3 prefix configured: (empty)\n", checkmark())
-	} else {
-		fmt.Printf("  %s S3 prefix configured: %s\n", checkmark(), cfg.S3.Prefix)
+	switch {
+	case cfg.S3.Prefix == "":
+		results = append(results, pass("config.prefix", msg.New("doctor.config.prefix.empty", nil)))
+	case !strings.HasSuffix(cfg.S3.Prefix, "/"):
+		results = append(results, warn("config.prefix", msg.New("doctor.config.prefix.no_slash", msg.Args{"Prefix": cfg.S3.Prefix}),
+			hint("doctor.config.prefix.siblings", msg.Args{"Prefix": cfg.S3.Prefix}),
+			hint("doctor.config.edit", msg.Args{"Path": configPath, "Setting": "s3.prefix: " + cfg.S3.Prefix + "/"})))
+	default:
+		results = append(results, pass("config.prefix", msg.New("doctor.config.prefix.ok", msg.Args{"Prefix": cfg.S3.Prefix})))
+	}
+
+	if cfg.S3.CABundle != "" {
+		results = append(results, pass("config.ca_bundle", msg.New("doctor.config.ca_bundle.ok", msg.Args{"Path": cfg.S3.CABundle})))
+	}
+
+	if cfg.S3.InsecureSkipVerify {
+		results = append(results, warn("config.tls", msg.New("doctor.config.tls.insecure", nil),
+			hint("doctor.config.tls.hint", nil)))
 	}
 
-	fmt.Println()
+	return results
+}
 
-	// Local filesystem checks
-	fmt.Println("Local filesystem:")
+// projectsRootChecks verifies the projects root is a readable directory.
+// The returned entries are nil if any check failed.
+func projectsRootChecks(cfg *types.Config) ([]Result, []os.DirEntry) {
+	root := cfg.Local.ProjectsRoot
+	unreadable := []Result{
+		fail("local.readable", msg.New("doctor.local.readable.failed", nil)),
+		fail("local.projects", msg.New("doctor.local.projects.none", nil)),
+	}
 
-	info, err := os.Stat(cfg.Local.ProjectsRoot)
+	info, err := os.Stat(root)
 	if err != nil {
 		if os.IsNotExist(err) {
-			fmt.Printf("  %s Projects root does not exist: %s\n", crossmark(), cfg.Local.ProjectsRoot)
-			fmt.Printf("    → Create the directory or update local.projects_root in config\n")
-			fmt.Printf("  %s Cannot read projects root\n", crossmark())
-			fmt.Printf("  %s No projects found\n", crossmark())
-			allPassed = false
-			fmt.Println()
-			printSummary(allPassed)
-			return allPassed
-		}
-		fmt.Printf("  %s Cannot access projects root: %s\n", crossmark(), cfg.Local.ProjectsRoot)
-		fmt.Printf("    → Error: %v\n", err)
-		fmt.Printf("  %s Cannot read projects root\n", crossmark())
-		fmt.Printf("  %s No projects found\n", crossmark())
-		allPassed = false
-		fmt.Println()
-		printSummary(allPassed)
-		return allPassed
+			h := hint("doctor.local.root.create", nil)
+			if last := identity.LastProjects(cfg.Identity.StatePath); last > 0 {
+				h = hint("doctor.local.root.unmounted", msg.Args{"Projects": last})
+			}
+			return append([]Result{fail("local.projects_root", msg.New("doctor.local.root.missing", msg.Args{"Path": root}), h)}, unreadable...), nil
+		}
+		return append([]Result{fail("local.projects_root", msg.New("doctor.local.root.inaccessible", msg.Args{"Path": root}),
+			errorDetail(err))}, unreadable...), nil
 	}
 
 	if !info.IsDir() {
-		fmt.Printf("  %s Projects root is not a directory: %s\n", crossmark(), cfg.Local.ProjectsRoot)
-		fmt.Printf("    → Ensure local.projects_root points to a directory\n")
-		fmt.Printf("  %s Cannot read projects root\n", crossmark())
-		fmt.Printf("  %s No projects found\n", crossmark())
-		allPassed = false
-		fmt.Println()
-		printSummary(allPassed)
-		return allPassed
+		return append([]Result{fail("local.projects_root", msg.New("doctor.local.root.not_dir", msg.Args{"Path": root}),
+			hint("doctor.local.root.not_dir_hint", nil))}, unreadable...), nil
 	}
 
-	fmt.Printf("  %s Projects root exists: %s\n", checkmark(), cfg.Local.ProjectsRoot)
+	results := []Result{pass("local.projects_root", msg.New("doctor.local.root.ok", msg.Args{"Path": root}))}
 
-	// Check if projects root is readable
-	entries, err := os.ReadDir(cfg.Local.ProjectsRoot)
+	entries, err := os.ReadDir(root)
 	if err != nil {
-		fmt.Printf("  %s Projects root is not readable\n", crossmark())
-		fmt.Printf("    → Error: %v\n", err)
-		fmt.Printf("  %s No projects found\n", crossmark())
-		allPassed = false
-		fmt.Println()
-		printSummary(allPassed)
-		return allPassed
+		return append(results,
+			fail("local.readable", msg.New("doctor.local.readable.not_readable", nil), errorDetail(err)),
+			fail("local.projects", msg.New("doctor.local.projects.none", nil))), nil
 	}
 
-	fmt.Printf("  %s Projects root is readable\n", checkmark())
+	return append(results, pass("local.readable", msg.New("doctor.local.readable.ok", nil))), entries
+}
 
-	// Count projects with JSONL files
-	projects, err := discover.DiscoverLocal(cfg.Local.ProjectsRoot)
+// LocalChecks verifies the projects root and counts local projects.
+func LocalChecks(cfg *types.Config) []Result {
+	results, entries := projectsRootChecks(cfg)
+	if !Passed(results) {
+		return results
+	}
+
+	projects, err := discover.DiscoverLocal(cfg.Local)
 	if err != nil {
-		fmt.Printf("  %s Failed to discover projects: %v\n", crossmark(), err)
-		allPassed = false
-		fmt.Println()
-		printSummary(allPassed)
-		return allPassed
+		return append(results, fail("local.projects", msg.New("doctor.local.projects.failed", msg.Args{"Err": err})))
 	}
 
 	totalJSONL := 0
@@ -178,67 +306,386 @@ func RunChecks(cfg *types.Config, configPath string, skipRemote bool) bool {
 	}
 
 	if len(projects) == 0 {
-		// Check if there are any directories at all
-		hasDirectories := false
-		for _, entry := range entries {
-			if entry.IsDir() {
-				hasDirectories = true
-				break
-			}
+		if last := identity.LastProjects(cfg.Identity.StatePath); last > 0 && len(entries) == 0 {
+			return append(results, warn("local.projects", msg.New("doctor.local.projects.vanished", msg.Args{"Projects": last}),
+				hint("doctor.local.projects.check_mount", nil)))
 		}
+		if countDirectories(entries) > 0 {
+			return append(results, pass("local.projects", msg.New("doctor.local.projects.empty", msg.Args{"Projects": countDirectories(entries)})))
+		}
+		return append(results, pass("local.projects", msg.New("doctor.local.projects.no_dirs", nil)))
+	}
 
-		if hasDirectories {
-			fmt.Printf("  %s Found %d local projects with 0 JSONL files\n", checkmark(), countDirectories(entries))
-		} else {
-			fmt.Printf("  %s No projects found (no directories in projects root)\n", checkmark())
+	return append(results, pass("local.projects", msg.New("doctor.local.projects.ok", msg.Args{"Projects": len(projects), "Files": totalJSONL})))
+}
+
+// CacheChecks reports the age of the cached manifest.
+func CacheChecks(cfg *types.Config) []Result {
+	return cacheResults(cfg, time.Now())
+}
+
+// cacheResults reports the cached copy of the configured manifest as of now.
+func cacheResults(cfg *types.Config, now time.Time) []Result {
+	key := manifest.ConfigKey(cfg)
+	path := manifest.CachePath(cfg.S3.Bucket, key)
+	if path == "" {
+		return []Result{pass("local.manifest_cache", msg.New("doctor.local.cache.disabled", nil))}
+	}
+
+	updated, ok, err := manifest.CachedAt(cfg.S3.Bucket, key)
+	switch {
+	case err != nil:
+		return []Result{warn("local.manifest_cache", msg.New("doctor.local.cache.unusable", msg.Args{"Err": err}),
+			hint("doctor.local.cache.clear", msg.Args{"Path": path}))}
+	case !ok:
+		return []Result{pass("local.manifest_cache", msg.New("doctor.local.cache.none", nil))}
+	default:
+		age := max(now.Sub(updated), 0).Round(time.Second)
+		return []Result{pass("local.manifest_cache", msg.New("doctor.local.cache.ok", msg.Args{"Age": age, "Path": path}))}
+	}
+}
+
+// RemoteChecks initializes an S3 client and verifies bucket access.
+func RemoteChecks(ctx context.Context, cfg *types.Config) []Result {
+	client, err := config.NewS3Client(ctx, cfg)
+	if err != nil {
+		return []Result{fail("remote.client", msg.New("doctor.remote.client.failed", nil),
+			errorDetail(err),
+			hint("doctor.remote.client.configure", nil))}
+	}
+
+	return []Result{
+		pass("remote.client", msg.New("doctor.remote.client.ok", nil)),
+		checkRemoteConnectivity(ctx, client, cfg),
+	}
+}
+
+// CollisionChecks compares the remote manifest with local projects and warns
+// when a project has more files remotely than locally: a sign that another
+// machine uploads a same-named project into this key space, where the two can
+// overwrite each other's logs. The check is advisory and never fails.
+func CollisionChecks(ctx context.Context, cfg *types.Config) []Result {
+	client, err := config.NewS3Client(ctx, cfg)
+	if err != nil {
+		return []Result{warn("remote.collisions", msg.New("doctor.remote.collisions.skipped", msg.Args{"Err": err}))}
+	}
+
+	m, err := manifest.Load(ctx, client, cfg.S3.Bucket, manifest.ConfigKey(cfg), cfg.S3.OperationTimeout)
+	if err != nil {
+		return []Result{warn("remote.collisions", msg.New("doctor.remote.collisions.skipped", msg.Args{"Err": err}))}
+	}
+
+	local, err := discover.DiscoverLocal(cfg.Local)
+	if err != nil {
+		return []Result{warn("remote.collisions", msg.New("doctor.remote.collisions.skipped", msg.Args{"Err": err}))}
+	}
+
+	return collisionResults(cfg, m, local)
+}
+
+// collisionResults warns for each local project the manifest records more
+// files for than exist locally. Files this machine uploaded are only counted
+// against local files still there, so logs pruned after their upload don't
+// look like another machine's.
+func collisionResults(cfg *types.Config, m *manifest.Manifest, local []types.Project) []Result {
+	prefix := config.KeyPrefix(cfg)
+	remote, own := make(map[string]int), make(map[string]int)
+	for key, entry := range m.Files {
+		project := m.Project(key, prefix)
+		if project == "" {
+			continue
 		}
-	} else {
-		fileWord := "files"
-		if totalJSONL == 1 {
-			fileWord = "file"
+		remote[project]++
+		if entry.Machine != "" && entry.Machine == cfg.Local.MachineID {
+			own[project]++
 		}
-		projectWord := "projects"
-		if len(projects) == 1 {
-			projectWord = "project"
+	}
+
+	sorted := append([]types.Project(nil), local...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	h := hint("doctor.remote.collisions.separate", nil)
+	if cfg.S3.KeyLayout == config.KeyLayoutByHost {
+		h = hint("doctor.remote.collisions.machine_id", msg.Args{"MachineID": cfg.Local.MachineID})
+	}
+
+	var results []Result
+	for _, p := range sorted {
+		n := remote[p.Name]
+		if n-own[p.Name] <= max(p.LocalCount-own[p.Name], 0) {
+			continue
 		}
-		fmt.Printf("  %s Found %d local %s with %d JSONL %s\n", checkmark(), len(projects), projectWord, totalJSONL, fileWord)
+		results = append(results, warn("remote.collisions",
+			msg.New("doctor.remote.collisions.found", msg.Args{"Project": p.Name, "Remote": n, "Local": p.LocalCount}),
+			hint("doctor.remote.collisions.overwrite", nil), h))
+	}
+
+	if len(results) == 0 {
+		return []Result{pass("remote.collisions", msg.New("doctor.remote.collisions.ok", nil))}
 	}
+	return results
+}
 
-	fmt.Println()
+// ManifestChecks downloads the manifest and checks it without touching the
+// objects it lists: a manifest that cannot be decoded fails, since every
+// upload would stop on it, while bad entries only warn.
+func ManifestChecks(ctx context.Context, cfg *types.Config) []Result {
+	client, err := config.NewS3Client(ctx, cfg)
+	if err != nil {
+		return []Result{warn("remote.manifest", msg.New("doctor.remote.manifest.skipped", msg.Args{"Err": err}))}
+	}
 
-	// Remote connectivity checks (skip if requested)
-	if !skipRemote {
-		fmt.Println("Remote connectivity:")
+	data, err := manifest.Fetch(ctx, client, cfg.S3.Bucket, manifest.ConfigKey(cfg), cfg.S3.OperationTimeout)
+	if err != nil {
+		return []Result{warn("remote.manifest", msg.New("doctor.remote.manifest.skipped", msg.Args{"Err": err}))}
+	}
+	if data == nil {
+		return []Result{pass("remote.manifest", msg.New("doctor.remote.manifest.none", nil))}
+	}
+	return manifestResults(manifest.Fsck(data, config.KeyPrefix(cfg), time.Now()))
+}
+
+// manifestResults turns an fsck report into one result, with a detail line
+// per problem kind.
+func manifestResults(report manifest.FsckReport) []Result {
+	if len(report.Problems) == 0 {
+		return []Result{pass("remote.manifest", msg.New("doctor.remote.manifest.ok", msg.Args{"Entries": len(report.Manifest.Files)}))}
+	}
+
+	groups := report.ByKind()
+	var details []string
+	for _, kind := range manifest.ProblemKinds {
+		g := groups[kind]
+		if len(g) == 0 {
+			continue
+		}
+		if g[0].Key == "" {
+			details = append(details, fmt.Sprintf("→ %s: %s", kind, g[0].Detail))
+			continue
+		}
+		details = append(details, fmt.Sprintf("→ %s: %d entries (e.g. %s)", kind, len(g), g[0].Key))
+	}
+
+	if report.Fatal() {
+		details = append(details, hint("doctor.remote.manifest.rebuild", nil))
+		return []Result{fail("remote.manifest", msg.New("doctor.remote.manifest.unreadable", nil), details...)}
+	}
+	details = append(details, hint("doctor.remote.manifest.fsck", nil))
+	return []Result{warn("remote.manifest",
+		msg.New("doctor.remote.manifest.problems", msg.Args{"Problems": len(report.Problems)}),
+		details...)}
+}
+
+// staleUploadAge is how old an incomplete multipart upload must be before it
+// is reported; younger ones may belong to an upload still in progress.
+const staleUploadAge = 24 * time.Hour
+
+// MultipartChecks warns about incomplete multipart uploads under this
+// machine's prefix. An upload killed before it could abort leaves its parts
+// behind, and S3 bills for them until they are aborted. The check is
+// advisory and never fails.
+func MultipartChecks(ctx context.Context, cfg *types.Config) []Result {
+	client, err := config.NewS3Client(ctx, cfg)
+	if err != nil {
+		return []Result{warn("remote.multipart", msg.New("doctor.remote.multipart.skipped", msg.Args{"Err": err}))}
+	}
 
-		ctx := context.Background()
-		client, err := config.NewS3Client(ctx, cfg)
+	var uploads []s3types.MultipartUpload
+	input := &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(cfg.S3.Bucket),
+		Prefix: aws.String(config.KeyPrefix(cfg)),
+	}
+	for {
+		listCtx, cancel := config.WithOperationTimeout(ctx, cfg.S3.OperationTimeout)
+		out, err := client.ListMultipartUploads(listCtx, input)
+		cancel()
 		if err != nil {
-			fmt.Printf("  %s Failed to initialize S3 client\n", crossmark())
-			fmt.Printf("    → Error: %v\n", err)
-			fmt.Printf("    → Configure auth.profile or auth.access_key_id in config\n")
-			allPassed = false
-		} else {
-			fmt.Printf("  %s S3 client initialized\n", checkmark())
-
-			if checkRemoteConnectivity(ctx, client, cfg.S3.Bucket, cfg.S3.Region) {
-				fmt.Printf("  %s Connected to bucket: %s (%s)\n", checkmark(), cfg.S3.Bucket, cfg.S3.Region)
+			return []Result{warn("remote.multipart", msg.New("doctor.remote.multipart.skipped", msg.Args{"Err": err}))}
+		}
+		uploads = append(uploads, out.Uploads...)
+		if !aws.ToBool(out.IsTruncated) {
+			break
+		}
+		input.KeyMarker = out.NextKeyMarker
+		input.UploadIdMarker = out.NextUploadIdMarker
+	}
+
+	return staleUploadResults(cfg, uploads, time.Now())
+}
+
+// staleUploadResults reports the uploads started more than staleUploadAge
+// before now.
+func staleUploadResults(cfg *types.Config, uploads []s3types.MultipartUpload, now time.Time) []Result {
+	var stale []string
+	for _, up := range uploads {
+		started := aws.ToTime(up.Initiated)
+		if now.Sub(started) < staleUploadAge {
+			continue
+		}
+		stale = append(stale, fmt.Sprintf("→ %s (started %s)", aws.ToString(up.Key), started.UTC().Format(time.RFC3339)))
+	}
+	if len(stale) == 0 {
+		return []Result{pass("remote.multipart", msg.New("doctor.remote.multipart.ok", nil))}
+	}
+
+	details := append(stale,
+		hint("doctor.remote.multipart.abort", msg.Args{"Bucket": cfg.S3.Bucket}),
+		hint("doctor.remote.multipart.lifecycle", nil))
+	return []Result{warn("remote.multipart",
+		msg.New("doctor.remote.multipart.stale", msg.Args{"Uploads": len(stale)}),
+		details...)}
+}
+
+// ObjectLockChecks reports the bucket's Object Lock configuration: whether
+// the retention s3.object_lock asks for can be applied, and whether a bucket
+// default retention would also lock the manifest, which cclogs rewrites on
+// every upload.
+func ObjectLockChecks(ctx context.Context, cfg *types.Config) []Result {
+	client, err := config.NewS3Client(ctx, cfg)
+	if err != nil {
+		return []Result{warn("remote.object_lock", msg.New("doctor.remote.object_lock.skipped", msg.Args{"Err": err}))}
+	}
+
+	lockCtx, cancel := config.WithOperationTimeout(ctx, cfg.S3.OperationTimeout)
+	out, err := client.GetObjectLockConfiguration(lockCtx, &s3.GetObjectLockConfigurationInput{Bucket: aws.String(cfg.S3.Bucket)})
+	cancel()
+	return objectLockResults(cfg, out, err)
+}
+
+// objectLockResults turns a GetObjectLockConfiguration response into results.
+// Providers without Object Lock answer with an error; that only matters when
+// s3.object_lock needs it.
+func objectLockResults(cfg *types.Config, out *s3.GetObjectLockConfigurationOutput, err error) []Result {
+	lock := cfg.S3.ObjectLock
+	var lockCfg *s3types.ObjectLockConfiguration
+	if err == nil && out != nil {
+		lockCfg = out.ObjectLockConfiguration
+	}
+
+	var apiErr smithy.APIError
+	notFound := errors.As(err, &apiErr) && apiErr.ErrorCode() == "ObjectLockConfigurationNotFoundError"
+	switch {
+	case err != nil && !notFound && lock.Mode != "":
+		return []Result{warn("remote.object_lock", msg.New("doctor.remote.object_lock.skipped", msg.Args{"Err": err}))}
+	case err != nil && !notFound:
+		return []Result{pass("remote.object_lock", msg.New("doctor.remote.object_lock.off", nil))}
+	case lockCfg == nil || lockCfg.ObjectLockEnabled != s3types.ObjectLockEnabledEnabled:
+		if lock.Mode != "" {
+			return []Result{fail("remote.object_lock",
+				msg.New("doctor.remote.object_lock.missing", msg.Args{"Bucket": cfg.S3.Bucket}),
+				hint("doctor.remote.object_lock.enable", nil))}
+		}
+		return []Result{pass("remote.object_lock", msg.New("doctor.remote.object_lock.off", nil))}
+	}
+
+	var results []Result
+	if lock.Mode != "" {
+		results = append(results, pass("remote.object_lock",
+			msg.New("doctor.remote.object_lock.ok", msg.Args{"Mode": lock.Mode, "Days": lock.RetainDays})))
+	} else {
+		results = append(results, pass("remote.object_lock", msg.New("doctor.remote.object_lock.unused", nil)))
+	}
+
+	if lockCfg.Rule != nil && lockCfg.Rule.DefaultRetention != nil {
+		d := lockCfg.Rule.DefaultRetention
+		period := fmt.Sprintf("%d days", aws.ToInt32(d.Days))
+		if d.Years != nil {
+			period = fmt.Sprintf("%d years", aws.ToInt32(d.Years))
+		}
+		results = append(results, warn("remote.object_lock",
+			msg.New("doctor.remote.object_lock.default", msg.Args{"Mode": string(d.Mode), "Period": period}),
+			hint("doctor.remote.object_lock.manifest", nil)))
+	}
+	return results
+}
+
+// Preflight runs the cheap subset of checks before an upload: config sanity,
+// the projects root, client initialization, and HeadBucket (unless
+// skipRemote). It stops at the first failing stage, and remote calls are
+// bounded by a short timeout.
+func Preflight(ctx context.Context, cfg *types.Config, configPath string, skipRemote bool) []Result {
+	results := ConfigChecks(cfg, configPath)
+	if !Passed(results) {
+		return results
+	}
+
+	local, _ := projectsRootChecks(cfg)
+	results = append(results, local...)
+	if !Passed(results) || skipRemote {
+		return results
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, preflightTimeout)
+	defer cancel()
+	return append(results, RemoteChecks(ctx, cfg)...)
+}
+
+// RunChecks performs all doctor checks and returns whether all passed.
+// Remote connectivity checks can be skipped by setting skipRemote to true.
+func RunChecks(cfg *types.Config, configPath string, skipRemote bool) bool {
+	fmt.Fprintln(output.Human(), msg.Text("doctor.title", nil))
+	fmt.Fprintln(output.Human())
+
+	// Configuration checks
+	fmt.Fprintln(output.Human(), msg.Text("doctor.section.config", nil))
+	configResults := ConfigChecks(cfg, configPath)
+	PrintResults(configResults)
+	allPassed := Passed(configResults)
+	fmt.Fprintln(output.Human())
+
+	// Local filesystem checks; remote checks are pointless without projects
+	fmt.Fprintln(output.Human(), msg.Text("doctor.section.local", nil))
+	localResults := LocalChecks(cfg)
+	if Passed(localResults) {
+		localResults = append(localResults, CacheChecks(cfg)...)
+	}
+	PrintResults(localResults)
+	fmt.Fprintln(output.Human())
+	if !Passed(localResults) {
+		printSummary(false)
+		return false
+	}
+
+	// Remote connectivity checks (skip if requested)
+	if !skipRemote {
+		targets := remoteTargets(cfg)
+		for _, t := range targets {
+			if len(targets) > 1 {
+				fmt.Fprintln(output.Human(), msg.Text("doctor.section.remote_target", msg.Args{"Target": t.TargetName, "Bucket": t.S3.Bucket}))
 			} else {
-				allPassed = false
+				fmt.Fprintln(output.Human(), msg.Text("doctor.section.remote", nil))
 			}
+			remoteResults := RemoteChecks(context.Background(), t)
+			if Passed(remoteResults) {
+				remoteResults = append(remoteResults, ManifestChecks(context.Background(), t)...)
+				remoteResults = append(remoteResults, CollisionChecks(context.Background(), t)...)
+				remoteResults = append(remoteResults, MultipartChecks(context.Background(), t)...)
+				remoteResults = append(remoteResults, ObjectLockChecks(context.Background(), t)...)
+			}
+			PrintResults(remoteResults)
+			allPassed = allPassed && Passed(remoteResults)
+			fmt.Fprintln(output.Human())
 		}
-
-		fmt.Println()
 	}
 
 	printSummary(allPassed)
 	return allPassed
 }
 
+// remoteTargets returns the destinations whose remote side is checked: the
+// one selected with --target, or else every configured target.
+func remoteTargets(cfg *types.Config) []*types.Config {
+	if cfg.TargetName != "" {
+		return []*types.Config{cfg}
+	}
+	return config.Targets(cfg)
+}
+
 func printSummary(allPassed bool) {
 	if allPassed {
-		fmt.Println("All checks passed! Ready to use cclogs.")
+		fmt.Fprintln(output.Human(), msg.Text("doctor.summary.passed", nil))
 	} else {
-		fmt.Println("Some checks failed. Please fix the issues above.")
+		fmt.Fprintln(output.Human(), msg.Text("doctor.summary.failed", nil))
 	}
 }
 
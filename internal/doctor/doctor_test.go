@@ -1,11 +1,26 @@
 package doctor
 
 import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/discover"
+	"github.com/13rac1/cclogs/internal/identity"
+	"github.com/13rac1/cclogs/internal/manifest"
 	"github.com/13rac1/cclogs/internal/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
 func TestRunChecks(t *testing.T) {
@@ -372,3 +387,460 @@ func TestCountDirectories(t *testing.T) {
 		})
 	}
 }
+
+func TestPreflight(t *testing.T) {
+	var headRequests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			headRequests.Add(1)
+		}
+		if strings.HasPrefix(r.URL.Path, "/good-bucket") {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	projectsRoot := t.TempDir()
+
+	tests := []struct {
+		name         string
+		bucket       string
+		projectsRoot string
+		skipRemote   bool
+		wantFailed   string // Name of the first failing check, empty if all pass
+		wantHeads    int32
+	}{
+		{name: "placeholder bucket fails before touching the network", bucket: "YOUR-BUCKET-NAME", projectsRoot: projectsRoot, wantFailed: "config.bucket"},
+		{name: "missing projects root", bucket: "good-bucket", projectsRoot: filepath.Join(projectsRoot, "missing"), wantFailed: "local.projects_root"},
+		{name: "bad bucket", bucket: "bad-bucket", projectsRoot: projectsRoot, wantFailed: "remote.bucket", wantHeads: 1},
+		{name: "good bucket", bucket: "good-bucket", projectsRoot: projectsRoot, wantHeads: 1},
+		{name: "remote skipped", bucket: "bad-bucket", projectsRoot: projectsRoot, skipRemote: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headRequests.Store(0)
+			cfg := &types.Config{
+				Local: types.LocalConfig{ProjectsRoot: tt.projectsRoot},
+				S3: types.S3Config{
+					Bucket:         tt.bucket,
+					Region:         "us-east-1",
+					Endpoint:       server.URL,
+					ForcePathStyle: true,
+				},
+				Auth: types.AuthConfig{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"},
+			}
+
+			results := Preflight(context.Background(), cfg, "config.yaml", tt.skipRemote)
+
+			failures := Failures(results)
+			gotFailed := ""
+			if len(failures) > 0 {
+				gotFailed = failures[0].Name
+			}
+			if gotFailed != tt.wantFailed {
+				t.Errorf("first failure = %q, want %q (results: %+v)", gotFailed, tt.wantFailed, results)
+			}
+			if Passed(results) != (tt.wantFailed == "") {
+				t.Errorf("Passed() = %v, want %v", Passed(results), tt.wantFailed == "")
+			}
+			if got := headRequests.Load(); got != tt.wantHeads {
+				t.Errorf("HEAD requests = %d, want %d", got, tt.wantHeads)
+			}
+		})
+	}
+}
+
+func TestCollisionResults(t *testing.T) {
+	root := t.TempDir()
+	for project, n := range map[string]int{"app": 2, "lib": 1} {
+		dir := filepath.Join(root, project)
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		for i := range n {
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("s%d.jsonl", i)), []byte("{}\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	local, err := discover.DiscoverLocal(types.LocalConfig{ProjectsRoot: root})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// manifestWith records n files under prefix for each project, and own
+	// more uploaded by this machine
+	manifestWith := func(prefix string, counts, own map[string]int) *manifest.Manifest {
+		m := manifest.New()
+		for project, n := range counts {
+			for i := range n {
+				m.Files[fmt.Sprintf("%s%s/s%d.jsonl", prefix, project, i)] = manifest.FileEntry{}
+			}
+		}
+		for project, n := range own {
+			for i := range n {
+				m.Files[fmt.Sprintf("%s%s/own%d.jsonl", prefix, project, i)] = manifest.FileEntry{Machine: "laptop"}
+			}
+		}
+		return m
+	}
+
+	tests := []struct {
+		name      string
+		remote    map[string]int
+		own       map[string]int // Files uploaded by this machine
+		layout    string
+		wantWarns []string // Projects expected in warnings, in order
+		wantHint  string
+	}{
+		{
+			name:   "remote matches local",
+			remote: map[string]int{"app": 2, "lib": 1},
+		},
+		{
+			name:   "local ahead of remote",
+			remote: map[string]int{"app": 1},
+		},
+		{
+			name:   "remote-only projects are ignored",
+			remote: map[string]int{"app": 2, "elsewhere": 7},
+		},
+		{
+			name:      "overlapping project with extra remote files",
+			remote:    map[string]int{"app": 5, "lib": 1},
+			wantWarns: []string{"app"},
+			wantHint:  "key_layout: by_host",
+		},
+		{
+			name: "own uploads pruned locally",
+			own:  map[string]int{"app": 6, "lib": 3},
+		},
+		{
+			name:      "another machine's files next to own uploads",
+			remote:    map[string]int{"app": 1},
+			own:       map[string]int{"app": 2},
+			wantWarns: []string{"app"},
+			wantHint:  "key_layout: by_host",
+		},
+		{
+			name:      "by_host layout points at machine_id",
+			remote:    map[string]int{"app": 3, "lib": 2},
+			layout:    config.KeyLayoutByHost,
+			wantWarns: []string{"app", "lib"},
+			wantHint:  "local.machine_id",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &types.Config{
+				Local: types.LocalConfig{MachineID: "laptop"},
+				S3:    types.S3Config{Prefix: "claude-code/", KeyLayout: tt.layout},
+			}
+
+			results := collisionResults(cfg, manifestWith(config.KeyPrefix(cfg), tt.remote, tt.own), local)
+
+			if !Passed(results) {
+				t.Fatalf("collision check failed instead of warning: %+v", results)
+			}
+			if len(tt.wantWarns) == 0 {
+				if len(results) != 1 || results[0].Status != Pass {
+					t.Errorf("results = %+v, want a single pass", results)
+				}
+				return
+			}
+			if len(results) != len(tt.wantWarns) {
+				t.Fatalf("results = %+v, want %d warnings", results, len(tt.wantWarns))
+			}
+			for i, project := range tt.wantWarns {
+				r := results[i]
+				if r.Status != Warn || !strings.Contains(r.Message, "Project "+project+" ") {
+					t.Errorf("result %d = %+v, want warning for %s", i, r, project)
+				}
+				if !strings.Contains(strings.Join(r.Details, "\n"), tt.wantHint) {
+					t.Errorf("details = %q, want hint containing %q", r.Details, tt.wantHint)
+				}
+			}
+		})
+	}
+}
+
+func TestConfigChecksPrefixWithoutSlash(t *testing.T) {
+	cfg := &types.Config{S3: types.S3Config{Bucket: "b", Region: "us-east-1", Prefix: "logs"}}
+
+	for _, r := range ConfigChecks(cfg, "config.yaml") {
+		if r.Name != "config.prefix" {
+			continue
+		}
+		if r.Status != Warn || !strings.Contains(strings.Join(r.Details, "\n"), "logs-old/") {
+			t.Errorf("config.prefix = %+v, want sibling-prefix warning", r)
+		}
+		if r.Code != "doctor.config.prefix.no_slash" {
+			t.Errorf("config.prefix code = %q, want doctor.config.prefix.no_slash", r.Code)
+		}
+		return
+	}
+	t.Error("no config.prefix result")
+}
+
+func TestStaleUploadResults(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	cfg := &types.Config{S3: types.S3Config{Bucket: "test-bucket"}}
+	upload := func(key string, age time.Duration) s3types.MultipartUpload {
+		return s3types.MultipartUpload{Key: aws.String(key), Initiated: aws.Time(now.Add(-age))}
+	}
+
+	tests := []struct {
+		name       string
+		uploads    []s3types.MultipartUpload
+		wantStatus Status
+		wantKeys   []string
+	}{
+		{
+			name:       "none",
+			wantStatus: Pass,
+		},
+		{
+			name:       "recent upload may still be running",
+			uploads:    []s3types.MultipartUpload{upload("claude-code/p/a.jsonl", time.Hour)},
+			wantStatus: Pass,
+		},
+		{
+			name: "stale uploads reported",
+			uploads: []s3types.MultipartUpload{
+				upload("claude-code/p/a.jsonl", 48*time.Hour),
+				upload("claude-code/p/b.jsonl", time.Hour),
+				upload("claude-code/p/c.jsonl", 25*time.Hour),
+			},
+			wantStatus: Warn,
+			wantKeys:   []string{"claude-code/p/a.jsonl", "claude-code/p/c.jsonl"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := staleUploadResults(cfg, tt.uploads, now)
+			if len(results) != 1 || results[0].Status != tt.wantStatus {
+				t.Fatalf("results = %+v, want one %v result", results, tt.wantStatus)
+			}
+			details := strings.Join(results[0].Details, "\n")
+			for _, key := range tt.wantKeys {
+				if !strings.Contains(details, key) {
+					t.Errorf("details missing %s:\n%s", key, details)
+				}
+			}
+			if strings.Contains(details, "b.jsonl") {
+				t.Errorf("recent upload reported:\n%s", details)
+			}
+		})
+	}
+}
+
+func TestObjectLockResults(t *testing.T) {
+	enabled := func(rule *s3types.ObjectLockRule) *s3.GetObjectLockConfigurationOutput {
+		return &s3.GetObjectLockConfigurationOutput{ObjectLockConfiguration: &s3types.ObjectLockConfiguration{
+			ObjectLockEnabled: s3types.ObjectLockEnabledEnabled,
+			Rule:              rule,
+		}}
+	}
+	notFound := &smithy.GenericAPIError{Code: "ObjectLockConfigurationNotFoundError"}
+	lock := types.ObjectLockConfig{Mode: "GOVERNANCE", RetainDays: 30}
+
+	tests := []struct {
+		name  string
+		lock  types.ObjectLockConfig
+		out   *s3.GetObjectLockConfigurationOutput
+		err   error
+		codes []string
+	}{
+		{"not enabled, not used", types.ObjectLockConfig{}, nil, notFound, []string{"doctor.remote.object_lock.off"}},
+		{"not enabled, but configured", lock, nil, notFound, []string{"doctor.remote.object_lock.missing"}},
+		{"unsupported provider", types.ObjectLockConfig{}, nil, &smithy.GenericAPIError{Code: "NotImplemented"}, []string{"doctor.remote.object_lock.off"}},
+		{"check failed while configured", lock, nil, &smithy.GenericAPIError{Code: "AccessDenied"}, []string{"doctor.remote.object_lock.skipped"}},
+		{"enabled, not used", types.ObjectLockConfig{}, enabled(nil), nil, []string{"doctor.remote.object_lock.unused"}},
+		{"enabled and configured", lock, enabled(nil), nil, []string{"doctor.remote.object_lock.ok"}},
+		{
+			"bucket default locks the manifest",
+			lock,
+			enabled(&s3types.ObjectLockRule{DefaultRetention: &s3types.DefaultRetention{Mode: s3types.ObjectLockRetentionModeCompliance, Days: aws.Int32(7)}}),
+			nil,
+			[]string{"doctor.remote.object_lock.ok", "doctor.remote.object_lock.default"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &types.Config{S3: types.S3Config{Bucket: "test-bucket", ObjectLock: tt.lock}}
+			results := objectLockResults(cfg, tt.out, tt.err)
+			var codes []string
+			for _, r := range results {
+				codes = append(codes, r.Code)
+			}
+			if strings.Join(codes, ",") != strings.Join(tt.codes, ",") {
+				t.Errorf("codes = %v, want %v", codes, tt.codes)
+			}
+		})
+	}
+}
+
+func TestManifestResults(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		data       string
+		wantStatus Status
+		wantCode   string
+		wantDetail string
+	}{
+		{
+			name:       "consistent",
+			data:       `{"version":2,"files":{"claude-code/p/a.jsonl":{"mtime":"2025-05-01T00:00:00Z","size":10}}}`,
+			wantStatus: Pass,
+			wantCode:   "doctor.remote.manifest.ok",
+		},
+		{
+			name:       "truncated",
+			data:       `{"version":2,"files":{"claude-code/p/a.jsonl":{"mti`,
+			wantStatus: Fail,
+			wantCode:   "doctor.remote.manifest.unreadable",
+			wantDetail: "truncated",
+		},
+		{
+			name:       "bad entries",
+			data:       `{"version":2,"files":{"other/p/a.jsonl":{"size":-1}}}`,
+			wantStatus: Warn,
+			wantCode:   "doctor.remote.manifest.problems",
+			wantDetail: "outside prefix: 1 entries",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results := manifestResults(manifest.Fsck([]byte(tt.data), "claude-code/", now))
+			if len(results) != 1 || results[0].Status != tt.wantStatus || results[0].Code != tt.wantCode {
+				t.Fatalf("results = %+v, want one %v %s result", results, tt.wantStatus, tt.wantCode)
+			}
+			if details := strings.Join(results[0].Details, "\n"); !strings.Contains(details, tt.wantDetail) {
+				t.Errorf("details missing %q:\n%s", tt.wantDetail, details)
+			}
+		})
+	}
+}
+
+func TestCacheResults(t *testing.T) {
+	cfg := &types.Config{S3: types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/", ManifestKey: manifest.DefaultName}}
+	key := manifest.ConfigKey(cfg)
+
+	manifest.SetCacheDir("")
+	if r := cacheResults(cfg, time.Now()); r[0].Code != "doctor.local.cache.disabled" {
+		t.Errorf("disabled cache: %+v", r)
+	}
+
+	manifest.SetCacheDir(t.TempDir())
+	defer manifest.SetCacheDir("")
+	if r := cacheResults(cfg, time.Now()); r[0].Code != "doctor.local.cache.none" {
+		t.Errorf("empty cache: %+v", r)
+	}
+
+	path := manifest.CachePath(cfg.S3.Bucket, key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(`{"etag":"\"e1\"","manifest":{"version":1,"files":{}}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	updated := time.Now().Add(-90 * time.Minute)
+	if err := os.Chtimes(path, updated, updated); err != nil {
+		t.Fatal(err)
+	}
+	r := cacheResults(cfg, updated.Add(90*time.Minute))
+	if r[0].Status != Pass || !strings.Contains(r[0].Message, "1h30m0s ago") {
+		t.Errorf("cached manifest: %+v", r)
+	}
+
+	if err := os.WriteFile(path, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if r := cacheResults(cfg, time.Now()); r[0].Status != Warn || !strings.Contains(strings.Join(r[0].Details, ""), path) {
+		t.Errorf("corrupt cache: %+v", r)
+	}
+}
+
+func TestLocalChecks_UnmountedRoot(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	root := filepath.Join(dir, "projects")
+	if err := os.Mkdir(root, 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		last       int // Projects recorded by the last upload
+		root       string
+		wantStatus Status
+		wantName   string
+		wantText   string
+	}{
+		{name: "new empty root", root: root, wantStatus: Pass, wantName: "local.projects"},
+		{name: "empty mountpoint", last: 12, root: root, wantStatus: Warn, wantName: "local.projects", wantText: "last upload found 12 projects"},
+		{name: "root gone", last: 12, root: filepath.Join(dir, "missing"), wantStatus: Fail, wantName: "local.projects_root", wantText: "is the volume holding it mounted?"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_ = os.Remove(statePath)
+			if tt.last > 0 {
+				if err := identity.RecordProjects(statePath, tt.last); err != nil {
+					t.Fatal(err)
+				}
+			}
+			cfg := &types.Config{
+				Local:    types.LocalConfig{ProjectsRoot: tt.root},
+				Identity: types.Identity{StatePath: statePath},
+			}
+
+			var got *Result
+			for _, r := range LocalChecks(cfg) {
+				if r.Name == tt.wantName {
+					got = &r
+					break
+				}
+			}
+			if got == nil {
+				t.Fatalf("no %s result", tt.wantName)
+			}
+			if got.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s (%+v)", got.Status, tt.wantStatus, got)
+			}
+			text := got.Message + " " + strings.Join(got.Details, " ")
+			if !strings.Contains(text, tt.wantText) {
+				t.Errorf("result %q missing %q", text, tt.wantText)
+			}
+		})
+	}
+}
+
+func TestRemoteTargets(t *testing.T) {
+	cfg := &types.Config{
+		S3:      types.S3Config{Bucket: "work-logs"},
+		Targets: map[string]types.Target{"personal": {S3: types.S3Config{Bucket: "personal-logs"}}},
+	}
+
+	targets := remoteTargets(cfg)
+	if len(targets) != 2 || targets[0].TargetName != config.DefaultTarget || targets[1].S3.Bucket != "personal-logs" {
+		t.Fatalf("remoteTargets() = %+v, want default and personal", targets)
+	}
+
+	selected, err := config.Target(cfg, "personal")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if targets := remoteTargets(selected); len(targets) != 1 || targets[0].S3.Bucket != "personal-logs" {
+		t.Errorf("remoteTargets(--target personal) = %+v, want only personal", targets)
+	}
+}
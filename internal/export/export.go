@@ -0,0 +1,253 @@
+// Package export renders Claude Code session logs as readable conversation
+// transcripts.
+package export
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Output formats.
+const (
+	FormatMarkdown = "markdown"
+	FormatHTML     = "html"
+)
+
+// Block kinds.
+const (
+	KindText       = "text"
+	KindThinking   = "thinking"
+	KindToolUse    = "tool_use"
+	KindToolResult = "tool_result"
+)
+
+// Entry is one user or assistant message.
+type Entry struct {
+	Role   string    // "user" or "assistant"
+	Time   time.Time // Zero if the record has no timestamp
+	Blocks []Block
+}
+
+// Block is one content block of a message.
+type Block struct {
+	Kind    string // KindText, KindThinking, KindToolUse, KindToolResult, or the raw type (e.g. "image")
+	Text    string // Text, thinking, tool input (indented JSON), or tool output
+	Name    string // Tool name for KindToolUse
+	IsError bool   // Tool result reported an error
+}
+
+// ToolResultsOnly reports whether the entry only carries tool output, which
+// Claude Code records as a user message. Renderers show it under the tool
+// call instead of as a new turn.
+func (e Entry) ToolResultsOnly() bool {
+	for _, b := range e.Blocks {
+		if b.Kind != KindToolResult {
+			return false
+		}
+	}
+	return len(e.Blocks) > 0
+}
+
+// Stats counts what Parse read.
+type Stats struct {
+	Messages int // User and assistant messages rendered
+	Skipped  int // Lines that could not be parsed
+}
+
+// record holds the fields of a log line that a transcript shows.
+type record struct {
+	Type      string    `json:"type"`
+	Timestamp time.Time `json:"timestamp"`
+	Message   *struct {
+		Role    string          `json:"role"`
+		Content json.RawMessage `json:"content"`
+	} `json:"message"`
+}
+
+// block is a content block as stored in the log.
+type block struct {
+	Type     string          `json:"type"`
+	Text     string          `json:"text"`
+	Thinking string          `json:"thinking"`
+	Name     string          `json:"name"`
+	Input    json.RawMessage `json:"input"`
+	Content  json.RawMessage `json:"content"`
+	IsError  bool            `json:"is_error"`
+}
+
+// ParseFormat validates an output format name ("md" is accepted for
+// markdown).
+func ParseFormat(s string) (string, error) {
+	switch strings.ToLower(s) {
+	case "", FormatMarkdown, "md":
+		return FormatMarkdown, nil
+	case FormatHTML:
+		return FormatHTML, nil
+	default:
+		return "", fmt.Errorf("unknown format %q (want %s or %s)", s, FormatMarkdown, FormatHTML)
+	}
+}
+
+// Parse reads a JSONL session log and returns its user and assistant
+// messages in order. Other record types (summaries, snapshots) are left
+// out; lines that aren't JSON or whose message can't be decoded are skipped
+// and counted.
+func Parse(r io.Reader) ([]Entry, Stats, error) {
+	scanner := bufio.NewScanner(r)
+	// Increase buffer for large lines (10MB max)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	var entries []Entry
+	var stats Stats
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var rec record
+		if err := json.Unmarshal(line, &rec); err != nil {
+			stats.Skipped++
+			continue
+		}
+		if rec.Type != "user" && rec.Type != "assistant" {
+			continue
+		}
+		if rec.Message == nil {
+			stats.Skipped++
+			continue
+		}
+
+		blocks, err := parseContent(rec.Message.Content)
+		if err != nil {
+			stats.Skipped++
+			continue
+		}
+		if len(blocks) == 0 {
+			continue
+		}
+
+		role := rec.Message.Role
+		if role == "" {
+			role = rec.Type
+		}
+		entries = append(entries, Entry{Role: role, Time: rec.Timestamp, Blocks: blocks})
+		stats.Messages++
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, stats, fmt.Errorf("reading log: %w", err)
+	}
+	return entries, stats, nil
+}
+
+// parseContent decodes message content, which is either a plain string or a
+// list of content blocks. Empty text blocks are dropped.
+func parseContent(raw json.RawMessage) ([]Block, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		if strings.TrimSpace(s) == "" {
+			return nil, nil
+		}
+		return []Block{{Kind: KindText, Text: s}}, nil
+	}
+
+	var raws []block
+	if err := json.Unmarshal(raw, &raws); err != nil {
+		return nil, err
+	}
+
+	var blocks []Block
+	for _, b := range raws {
+		switch b.Type {
+		case KindText:
+			if strings.TrimSpace(b.Text) != "" {
+				blocks = append(blocks, Block{Kind: KindText, Text: b.Text})
+			}
+		case KindThinking:
+			if strings.TrimSpace(b.Thinking) != "" {
+				blocks = append(blocks, Block{Kind: KindThinking, Text: b.Thinking})
+			}
+		case KindToolUse:
+			blocks = append(blocks, Block{Kind: KindToolUse, Name: b.Name, Text: indentJSON(b.Input)})
+		case KindToolResult:
+			text, err := resultText(b.Content)
+			if err != nil {
+				return nil, err
+			}
+			blocks = append(blocks, Block{Kind: KindToolResult, Text: text, IsError: b.IsError})
+		default:
+			blocks = append(blocks, Block{Kind: b.Type})
+		}
+	}
+	return blocks, nil
+}
+
+// resultText returns the text of tool output, which is either a string or a
+// list of blocks. Non-text blocks are shown by type.
+func resultText(raw json.RawMessage) (string, error) {
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err == nil {
+		return s, nil
+	}
+
+	var raws []block
+	if err := json.Unmarshal(raw, &raws); err != nil {
+		return "", err
+	}
+	parts := make([]string, 0, len(raws))
+	for _, b := range raws {
+		if b.Type == KindText {
+			parts = append(parts, b.Text)
+		} else {
+			parts = append(parts, "["+b.Type+"]")
+		}
+	}
+	return strings.Join(parts, "\n"), nil
+}
+
+// indentJSON pretty-prints tool input, returning it unchanged if it isn't
+// valid JSON.
+func indentJSON(raw json.RawMessage) string {
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, raw, "", "  "); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
+
+// Render writes entries as a transcript titled title in format.
+func Render(w io.Writer, format, title string, entries []Entry) error {
+	switch format {
+	case FormatMarkdown:
+		return renderMarkdown(w, title, entries)
+	case FormatHTML:
+		return renderHTML(w, title, entries)
+	default:
+		return fmt.Errorf("unknown format %q", format)
+	}
+}
+
+// heading returns the title of an entry, e.g. "User · 2025-01-02 15:04 UTC".
+func heading(e Entry) string {
+	role := e.Role
+	if role != "" {
+		role = strings.ToUpper(role[:1]) + role[1:]
+	}
+	if e.Time.IsZero() {
+		return role
+	}
+	return role + " · " + e.Time.UTC().Format("2006-01-02 15:04 UTC")
+}
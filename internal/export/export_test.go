@@ -0,0 +1,120 @@
+package export
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+const session = `{"type":"summary","summary":"Fix tests"}
+{"type":"user","timestamp":"2025-01-02T15:04:05Z","message":{"role":"user","content":"run the <tests>"}}
+{"type":"assistant","message":{"role":"assistant","content":[{"type":"thinking","thinking":"use go test"},{"type":"text","text":"Running them."},{"type":"tool_use","name":"Bash","input":{"command":"go test ./..."}}]}}
+not json
+{"type":"user","message":{"role":"user","content":[{"type":"tool_result","content":[{"type":"text","text":"FAIL ` + "```" + `x` + "```" + `"}],"is_error":true}]}}
+{"type":"assistant","message":{"role":"assistant","content":42}}
+{"type":"assistant","message":{"role":"assistant","content":[{"type":"image"}]}}
+`
+
+func TestParse(t *testing.T) {
+	entries, stats, err := Parse(strings.NewReader(session))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+
+	if stats != (Stats{Messages: 4, Skipped: 2}) {
+		t.Errorf("stats = %+v, want 4 messages, 2 skipped", stats)
+	}
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries, want 4", len(entries))
+	}
+
+	if got := heading(entries[0]); got != "User · 2025-01-02 15:04 UTC" {
+		t.Errorf("heading = %q", got)
+	}
+	if got := entries[1].Blocks; len(got) != 3 || got[0].Kind != KindThinking || got[2].Name != "Bash" ||
+		got[2].Text != "{\n  \"command\": \"go test ./...\"\n}" {
+		t.Errorf("assistant blocks = %+v", got)
+	}
+	if !entries[2].ToolResultsOnly() || !entries[2].Blocks[0].IsError {
+		t.Errorf("tool result entry = %+v", entries[2])
+	}
+	if entries[3].Blocks[0].Kind != "image" {
+		t.Errorf("image block = %+v", entries[3].Blocks[0])
+	}
+}
+
+func TestRender(t *testing.T) {
+	entries, _, err := Parse(strings.NewReader(session))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		format string
+		want   []string
+		absent []string
+	}{
+		{
+			format: FormatMarkdown,
+			want: []string{
+				"# app/s.jsonl\n",
+				"## User · 2025-01-02 15:04 UTC\n\nrun the <tests>\n",
+				"**Tool: Bash**\n\n```json\n{\n  \"command\": \"go test ./...\"\n}\n```\n",
+				"**Error:**\n\n````\nFAIL ```x```\n````\n",
+				"_[image]_",
+			},
+			absent: []string{"## User\n"}, // Tool output has no heading of its own
+		},
+		{
+			format: FormatHTML,
+			want: []string{
+				"<title>app/s.jsonl</title>",
+				"run the &lt;tests&gt;",
+				"<strong>Tool: Bash</strong>",
+				`<div class="error"><strong>Error:</strong>`,
+				`<p class="note">[image]</p>`,
+			},
+			absent: []string{"<tests>"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := Render(&buf, tt.format, "app/s.jsonl", entries); err != nil {
+				t.Fatalf("Render() error = %v", err)
+			}
+			out := buf.String()
+			for _, s := range tt.want {
+				if !strings.Contains(out, s) {
+					t.Errorf("output missing %q:\n%s", s, out)
+				}
+			}
+			for _, s := range tt.absent {
+				if strings.Contains(out, s) {
+					t.Errorf("output contains %q:\n%s", s, out)
+				}
+			}
+		})
+	}
+}
+
+func TestParseFormat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "", want: FormatMarkdown},
+		{in: "md", want: FormatMarkdown},
+		{in: "HTML", want: FormatHTML},
+		{in: "pdf", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseFormat(tt.in)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseFormat(%q) = %q, %v", tt.in, got, err)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package export
+
+import (
+	"bufio"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+)
+
+// renderMarkdown writes a transcript with one section per turn. Tool calls,
+// tool output, and thinking are fenced so their content is shown verbatim.
+func renderMarkdown(w io.Writer, title string, entries []Entry) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# %s\n", title)
+
+	for _, e := range entries {
+		if !e.ToolResultsOnly() {
+			fmt.Fprintf(bw, "\n## %s\n", heading(e))
+		}
+		for _, b := range e.Blocks {
+			fmt.Fprintln(bw)
+			switch b.Kind {
+			case KindText:
+				fmt.Fprintln(bw, strings.TrimRight(b.Text, "\n"))
+			case KindThinking:
+				fmt.Fprintf(bw, "<details><summary>Thinking</summary>\n\n%s\n</details>\n", fenced("", b.Text))
+			case KindToolUse:
+				fmt.Fprintf(bw, "**Tool: %s**\n\n%s\n", b.Name, fenced("json", b.Text))
+			case KindToolResult:
+				label := "Result"
+				if b.IsError {
+					label = "Error"
+				}
+				fmt.Fprintf(bw, "**%s:**\n\n%s\n", label, fenced("", b.Text))
+			default:
+				fmt.Fprintf(bw, "_[%s]_\n", b.Kind)
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// fenced wraps s in a code fence longer than any run of backticks inside it.
+func fenced(lang, s string) string {
+	longest, run := 0, 0
+	for _, r := range s {
+		if r == '`' {
+			run++
+			longest = max(longest, run)
+		} else {
+			run = 0
+		}
+	}
+	fence := strings.Repeat("`", max(3, longest+1))
+	return fence + lang + "\n" + strings.TrimRight(s, "\n") + "\n" + fence
+}
+
+var htmlTemplate = template.Must(template.New("transcript").Funcs(template.FuncMap{
+	"heading": heading,
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 52rem; margin: 2rem auto; padding: 0 1rem; line-height: 1.5; }
+section { border-left: 4px solid #ccc; padding-left: 1rem; margin: 1.5rem 0; }
+section.user { border-color: #3b82f6; }
+section.assistant { border-color: #10b981; }
+section.result { border-color: #ddd; margin-top: -1rem; }
+h2 { font-size: 1rem; margin: 0 0 .5rem; }
+.text { white-space: pre-wrap; }
+pre { background: #f5f5f5; padding: .5rem; overflow-x: auto; white-space: pre-wrap; }
+.error pre { background: #fee2e2; }
+.note { color: #777; font-style: italic; }
+</style>
+</head>
+<body>
+<h1>{{.Title}}</h1>
+{{- range .Entries}}
+{{- if .ToolResultsOnly}}
+<section class="result">
+{{- else}}
+<section class="{{.Role}}">
+<h2>{{heading .}}</h2>
+{{- end}}
+{{- range .Blocks}}
+{{- if eq .Kind "text"}}
+<div class="text">{{.Text}}</div>
+{{- else if eq .Kind "thinking"}}
+<details><summary>Thinking</summary><pre>{{.Text}}</pre></details>
+{{- else if eq .Kind "tool_use"}}
+<div class="tool"><strong>Tool: {{.Name}}</strong><pre>{{.Text}}</pre></div>
+{{- else if eq .Kind "tool_result"}}
+<div class="{{if .IsError}}error{{else}}output{{end}}"><strong>{{if .IsError}}Error{{else}}Result{{end}}:</strong><pre>{{.Text}}</pre></div>
+{{- else}}
+<p class="note">[{{.Kind}}]</p>
+{{- end}}
+{{- end}}
+</section>
+{{- end}}
+</body>
+</html>
+`))
+
+// renderHTML writes a standalone HTML page. All log content is escaped.
+func renderHTML(w io.Writer, title string, entries []Entry) error {
+	return htmlTemplate.Execute(w, struct {
+		Title   string
+		Entries []Entry
+	}{title, entries})
+}
@@ -0,0 +1,329 @@
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/codec"
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/output"
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+// DefaultConcurrency is the number of objects downloaded at once when
+// DownloadOptions.Concurrency is unset.
+const DefaultConcurrency = 4
+
+// Target is a stored log to restore and where it goes below the destination.
+type Target struct {
+	Object
+	Project string
+	Path    string    // Slash-separated path relative to the project directory
+	Size    int64     // Size of the restored file: the object's, if stored uncompressed (0 if unknown)
+	SHA256  string    // Hex SHA-256 of the restored file, if stored uncompressed ("" if unknown)
+	ModTime time.Time // Source modification time to restore (zero if unknown)
+
+	// The original log, before redaction, which a restore must not replace
+	SourceSize   int64  // 0 if unknown
+	SourceSHA256 string // "" if unknown
+}
+
+// Targets returns the manifest's logs for project, or for every project if
+// project is empty, sorted by key.
+func Targets(cfg *types.Config, m *manifest.Manifest, project string) []Target {
+	prefix := config.KeyPrefix(cfg)
+	var targets []Target
+	for key, entry := range m.Files {
+		p := m.Project(key, prefix)
+		if p == "" || (project != "" && p != project) {
+			continue
+		}
+		c := codec.Codec(entry.Codec)
+		t := Target{
+			Object:       entryObject(cfg, key, entry),
+			Project:      p,
+			Path:         relPath(strings.TrimSuffix(key, c.Extension()), prefix, p),
+			ModTime:      entry.Mtime,
+			SourceSize:   entry.Size,
+			SourceSHA256: entry.SourceSHA256,
+		}
+		// The stored object is the restored file unless it was compressed
+		if c.ContentEncoding() == "" {
+			t.Size, t.SHA256 = entry.UploadedSize, entry.SHA256
+		}
+		targets = append(targets, t)
+	}
+	sortTargets(targets)
+	return targets
+}
+
+// ListedTargets builds targets from a bucket listing (key to object size) for
+// when no manifest is available. Projects and paths are read with the key
+// template under local.project_depth, and compressed objects are recognized
+// by extension.
+func ListedTargets(cfg *types.Config, objects map[string]int64, project string) []Target {
+	manifestKey := manifest.ConfigKey(cfg)
+	var targets []Target
+	for key, size := range objects {
+		c, base := codec.FromKey(key)
+		if key == manifestKey || !config.HasLogExtension(base, cfg.Local.Extensions) {
+			continue
+		}
+
+		fields, ok := config.ParseKey(config.KeyTemplate(cfg), cfg.S3.Prefix, base, cfg.Local.ProjectDepth)
+		p, rel := fields.Project, fields.Path
+		if !ok || (project != "" && p != project) {
+			continue
+		}
+
+		t := Target{Object: Object{Key: key, Codec: c}, Project: p, Path: rel}
+		if c == codec.None {
+			t.Size = size
+		}
+		targets = append(targets, t)
+	}
+	sortTargets(targets)
+	return targets
+}
+
+func sortTargets(targets []Target) {
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Key < targets[j].Key })
+}
+
+// relPath returns the path of key below its project segment. Templates that
+// put other segments before the project (dates, host) are handled by
+// searching for the project segment; keys without one keep their base name.
+func relPath(key, prefix, project string) string {
+	rel := strings.TrimPrefix(key, prefix)
+	if after, ok := strings.CutPrefix(rel, project+"/"); ok {
+		return after
+	}
+	if i := strings.Index(rel, "/"+project+"/"); i >= 0 {
+		return rel[i+len(project)+2:]
+	}
+	return path.Base(rel)
+}
+
+// DownloadOptions controls Download.
+type DownloadOptions struct {
+	Dest        string    // Directory that receives <project>/<path>
+	Overwrite   bool      // Replace existing files even if they look complete
+	Concurrency int       // Objects downloaded at once (default DefaultConcurrency)
+	Out         io.Writer // Progress and summary (nil discards)
+}
+
+// DownloadResult contains summary statistics from a download.
+type DownloadResult struct {
+	Downloaded      int
+	Skipped         int
+	DownloadedBytes int64 // Bytes written locally, after decompression
+	Failures        []DownloadFailure
+}
+
+// DownloadFailure records an object that could not be restored.
+type DownloadFailure struct {
+	Key  string
+	Path string // Local destination
+	Err  error
+}
+
+// Download restores targets below opts.Dest, decompressing as needed. A file
+// that already exists with the expected size is skipped, as is one that is
+// larger locally (it has likely grown since the upload), unless
+// opts.Overwrite is set. Files are written to a temp file and renamed into
+// place, so an interrupted download never leaves a partial log behind.
+func Download(ctx context.Context, client S3Client, bucket string, targets []Target, opts DownloadOptions) (*DownloadResult, error) {
+	out := opts.Out
+	if out == nil {
+		out = io.Discard
+	}
+	workers := opts.Concurrency
+	if workers <= 0 {
+		workers = DefaultConcurrency
+	}
+
+	result := &DownloadResult{}
+	var mu sync.Mutex // Guards result and keeps progress lines whole
+	done := 0
+	report := func(line string, record func()) {
+		mu.Lock()
+		defer mu.Unlock()
+		done++
+		fmt.Fprintf(out, "[%d/%d] %s\n", done, len(targets), line)
+		record()
+	}
+
+	jobs := make(chan Target)
+	var wg sync.WaitGroup
+	for range min(workers, max(len(targets), 1)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range jobs {
+				local, err := localPath(opts.Dest, t)
+				if err == nil {
+					if reason := skipReason(local, t, opts.Overwrite); reason != "" {
+						report(fmt.Sprintf("Skipping %s (%s)", local, reason), func() { result.Skipped++ })
+						continue
+					}
+					var n int64
+					if n, err = downloadOne(ctx, client, bucket, t, local); err == nil {
+						report(fmt.Sprintf("Downloaded %s (%s)", local, output.FormatSize(n)), func() {
+							result.Downloaded++
+							result.DownloadedBytes += n
+						})
+						continue
+					}
+				}
+				report(fmt.Sprintf("Failed %s: %v", t.Key, err), func() {
+					result.Failures = append(result.Failures, DownloadFailure{Key: t.Key, Path: local, Err: err})
+				})
+			}
+		}()
+	}
+
+	var interrupted error
+	for _, t := range targets {
+		if err := ctx.Err(); err != nil {
+			interrupted = err
+			break
+		}
+		jobs <- t
+	}
+	close(jobs)
+	wg.Wait()
+
+	printDownloadSummary(out, result, len(targets), interrupted)
+
+	if interrupted != nil {
+		return result, fmt.Errorf("download interrupted: %w", interrupted)
+	}
+	if len(result.Failures) > 0 {
+		f := result.Failures[0]
+		return result, fmt.Errorf("%d of %d downloads failed (first: %s: %v)", len(result.Failures), len(targets), f.Key, f.Err)
+	}
+	return result, nil
+}
+
+// localPath returns where t is restored, refusing keys that would escape dest.
+func localPath(dest string, t Target) (string, error) {
+	if strings.Contains(t.Project, "/") || !filepath.IsLocal(t.Project) || !filepath.IsLocal(filepath.FromSlash(t.Path)) {
+		return "", fmt.Errorf("refusing unsafe destination path %q", t.Project+"/"+t.Path)
+	}
+	return filepath.Join(dest, t.Project, filepath.FromSlash(t.Path)), nil
+}
+
+// skipReason returns why the existing file at local should be kept, or ""
+// if it should be downloaded: it already holds the stored copy or the
+// original log, or it is larger than both and has likely grown since.
+func skipReason(local string, t Target, overwrite bool) string {
+	if overwrite {
+		return ""
+	}
+	info, err := os.Stat(local)
+	if err != nil {
+		return ""
+	}
+	switch size := info.Size(); {
+	case t.Size > 0 && size == t.Size && hasSHA256(local, t.SHA256):
+		return "exists"
+	case t.SourceSize > 0 && size == t.SourceSize && hasSHA256(local, t.SourceSHA256):
+		return "original exists"
+	case max(t.Size, t.SourceSize) > 0 && size > max(t.Size, t.SourceSize):
+		return "larger locally; use --overwrite to replace"
+	default:
+		return ""
+	}
+}
+
+// hasSHA256 reports whether the file at path has the hex SHA-256 sum, or
+// sum is empty (unknown).
+func hasSHA256(path, sum string) bool {
+	if sum == "" {
+		return true
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer func() { _ = f.Close() }()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return false
+	}
+	return hex.EncodeToString(h.Sum(nil)) == sum
+}
+
+// downloadOne streams t into local through a temp file in the same
+// directory and returns the bytes written.
+func downloadOne(ctx context.Context, client S3Client, bucket string, t Target, local string) (int64, error) {
+	if err := os.MkdirAll(filepath.Dir(local), 0o755); err != nil {
+		return 0, fmt.Errorf("creating directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(local), "."+filepath.Base(local)+".*")
+	if err != nil {
+		return 0, fmt.Errorf("creating temp file: %w", err)
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+
+	cw := &countingWriter{w: tmp}
+	err = Cat(ctx, client, bucket, t.Object, false, cw)
+	if closeErr := tmp.Close(); err == nil && closeErr != nil {
+		err = fmt.Errorf("writing %s: %w", local, closeErr)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if !t.ModTime.IsZero() {
+		if err := os.Chtimes(tmp.Name(), t.ModTime, t.ModTime); err != nil {
+			return 0, fmt.Errorf("setting modification time: %w", err)
+		}
+	}
+	if err := os.Rename(tmp.Name(), local); err != nil {
+		return 0, fmt.Errorf("moving into place: %w", err)
+	}
+	return cw.n, nil
+}
+
+// countingWriter counts bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// printDownloadSummary prints totals in the same shape as the upload summary.
+func printDownloadSummary(out io.Writer, result *DownloadResult, total int, interrupted error) {
+	remaining := total - result.Downloaded - result.Skipped - len(result.Failures)
+	if interrupted != nil {
+		fmt.Fprintf(out, "\nDownload interrupted: %d of %d downloaded (%s), %d skipped, %d failed, %d not processed\n",
+			result.Downloaded, total, output.FormatSize(result.DownloadedBytes), result.Skipped, len(result.Failures), remaining)
+	} else {
+		fmt.Fprintf(out, "\nDownload complete: %d downloaded (%s), %d skipped, %d failed\n",
+			result.Downloaded, output.FormatSize(result.DownloadedBytes), result.Skipped, len(result.Failures))
+	}
+
+	if len(result.Failures) > 0 {
+		fmt.Fprintf(out, "\nFailed downloads:\n")
+		for _, f := range result.Failures {
+			fmt.Fprintf(out, "  %s: %v\n", f.Key, f.Err)
+		}
+	}
+}
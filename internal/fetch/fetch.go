@@ -0,0 +1,114 @@
+// Package fetch resolves stored logs to S3 keys and streams their content back.
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/13rac1/cclogs/internal/codec"
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/types"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Client defines the minimal S3 client interface needed to read objects.
+type S3Client interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// Object identifies a stored log and how its content is encoded.
+type Object struct {
+	Key   string
+	Codec codec.Codec
+	Dict  string // Key of the zstd dictionary the object was compressed with ("" for none)
+}
+
+// entryObject returns the object the manifest entry recorded under key
+// describes.
+func entryObject(cfg *types.Config, key string, entry manifest.FileEntry) Object {
+	obj := Object{Key: entry.ObjectKey(key), Codec: codec.Codec(entry.Codec)}
+	if entry.DictID != 0 {
+		obj.Dict = manifest.DictIDKey(cfg, entry.DictID)
+	}
+	return obj
+}
+
+// Resolve maps a project-relative path ("project/file.jsonl") or a full S3
+// key to its object. The manifest is searched first since key templates and
+// compression can change the key; a ref under the configured prefix is then
+// taken as a key, and anything else is computed with the configured template.
+func Resolve(cfg *types.Config, m *manifest.Manifest, ref string) (Object, error) {
+	if entry, ok := m.Files[ref]; ok {
+		return entryObject(cfg, ref, entry), nil
+	}
+
+	prefix := config.KeyPrefix(cfg)
+	isProject := func(p string) bool {
+		for key := range m.Files {
+			if m.Project(key, prefix) == p {
+				return true
+			}
+		}
+		return false
+	}
+	project, relPath, ok := config.SplitRef(cfg.Local, ref, isProject)
+	if !ok {
+		return Object{}, fmt.Errorf("expected <project>/<file>, got %q", ref)
+	}
+
+	var matches []string
+	for key, entry := range m.Files {
+		if m.Project(key, prefix) != project {
+			continue
+		}
+		base := strings.TrimSuffix(key, codec.Codec(entry.Codec).Extension())
+		if base == relPath || strings.HasSuffix(base, "/"+relPath) {
+			matches = append(matches, key)
+		}
+	}
+
+	switch len(matches) {
+	case 1:
+		return entryObject(cfg, matches[0], m.Files[matches[0]]), nil
+	case 0:
+		if prefix != "" && strings.HasPrefix(ref, prefix) {
+			return keyObject(ref), nil
+		}
+		key := config.RenderKey(config.KeyTemplate(cfg), config.KeyFields{
+			Prefix:  cfg.S3.Prefix,
+			Host:    cfg.Local.MachineID,
+			Project: project,
+			Path:    relPath,
+		})
+		return Object{Key: key, Codec: codec.None}, nil
+	default:
+		sort.Strings(matches)
+		return Object{}, fmt.Errorf("%q matches several objects; pass one of these keys instead:\n  %s", ref, strings.Join(matches, "\n  "))
+	}
+}
+
+// keyObject returns the object for a literal key, recognizing compressed
+// objects by their extension.
+func keyObject(key string) Object {
+	c, _ := codec.FromKey(key)
+	return Object{Key: key, Codec: c}
+}
+
+// Cat streams an object to w. Unless raw is set, content is decompressed when
+// the object has Content-Encoding gzip or was stored with a codec.
+func Cat(ctx context.Context, client S3Client, bucket string, obj Object, raw bool, w io.Writer) error {
+	body, err := openObject(ctx, client, bucket, obj, raw)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+
+	if _, err := io.Copy(w, body); err != nil {
+		return fmt.Errorf("reading %s: %w", obj.Key, err)
+	}
+	return nil
+}
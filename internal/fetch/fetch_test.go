@@ -0,0 +1,194 @@
+package fetch
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/codec"
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// mockS3Client serves a single object body with an optional content encoding.
+type mockS3Client struct {
+	objects  map[string][]byte
+	encoding string
+}
+
+func (m *mockS3Client) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	data, ok := m.objects[*params.Key]
+	if !ok {
+		return nil, &s3types.NoSuchKey{}
+	}
+	out := &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}
+	if m.encoding != "" {
+		out.ContentEncoding = aws.String(m.encoding)
+	}
+	return out, nil
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		t.Fatal(err)
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestResolve(t *testing.T) {
+	cfg := &types.Config{S3: types.S3Config{Prefix: "claude-code/"}}
+
+	m := manifest.New()
+	m.Files["claude-code/app/session.jsonl.gz"] = manifest.FileEntry{Project: "app", Codec: "gzip"}
+	m.Files["claude/2025/01/web/s1.jsonl"] = manifest.FileEntry{Project: "web"}
+	m.Files["claude/2025/02/web/s1.jsonl"] = manifest.FileEntry{Project: "web"}
+
+	tests := []struct {
+		name    string
+		ref     string
+		want    Object
+		wantErr string
+	}{
+		{name: "compressed entry", ref: "app/session.jsonl", want: Object{Key: "claude-code/app/session.jsonl.gz", Codec: codec.Gzip}},
+		{name: "not in manifest", ref: "other/sub/x.jsonl", want: Object{Key: "claude-code/other/sub/x.jsonl", Codec: codec.None}},
+		{name: "full key in manifest", ref: "claude/2025/02/web/s1.jsonl", want: Object{Key: "claude/2025/02/web/s1.jsonl"}},
+		{name: "full key under prefix", ref: "claude-code/old/s2.jsonl.gz", want: Object{Key: "claude-code/old/s2.jsonl.gz", Codec: codec.Gzip}},
+		{name: "ambiguous", ref: "web/s1.jsonl", wantErr: "  claude/2025/01/web/s1.jsonl\n  claude/2025/02/web/s1.jsonl"},
+		{name: "missing file", ref: "app", wantErr: "expected <project>/<file>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Resolve(cfg, m, tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Resolve() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Resolve() failed: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Resolve() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCat(t *testing.T) {
+	content := []byte(`{"type":"user"}` + "\n")
+	compressed := gzipBytes(t, content)
+
+	tests := []struct {
+		name     string
+		stored   []byte
+		encoding string
+		obj      Object
+		raw      bool
+		want     []byte
+	}{
+		{name: "plain", stored: content, obj: Object{Key: "k"}, want: content},
+		{name: "content-encoding gzip", stored: compressed, encoding: "gzip", obj: Object{Key: "k"}, want: content},
+		{name: "manifest codec gzip", stored: compressed, obj: Object{Key: "k", Codec: codec.Gzip}, want: content},
+		{name: "raw keeps bytes", stored: compressed, encoding: "gzip", obj: Object{Key: "k"}, raw: true, want: compressed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &mockS3Client{objects: map[string][]byte{"k": tt.stored}, encoding: tt.encoding}
+
+			var out bytes.Buffer
+			if err := Cat(context.Background(), client, "bucket", tt.obj, tt.raw, &out); err != nil {
+				t.Fatalf("Cat failed: %v", err)
+			}
+			if !bytes.Equal(out.Bytes(), tt.want) {
+				t.Errorf("output = %q, want %q", out.Bytes(), tt.want)
+			}
+		})
+	}
+}
+
+func TestCatMissing(t *testing.T) {
+	client := &mockS3Client{objects: map[string][]byte{}}
+	err := Cat(context.Background(), client, "bucket", Object{Key: "missing"}, false, io.Discard)
+	if err == nil {
+		t.Fatal("expected error for missing object, got nil")
+	}
+}
+
+func TestCatDictionary(t *testing.T) {
+	line := `{"type":"user","message":{"role":"user","content":"hello"}}` + "\n"
+	var samples [][]byte
+	for i := range 50 {
+		samples = append(samples, []byte(strings.Repeat(line, i+1)))
+	}
+	dict, err := codec.TrainDictionary(samples, 4096)
+	if err != nil {
+		t.Fatalf("TrainDictionary failed: %v", err)
+	}
+	compressed, err := codec.Compress(codec.Zstd, 0, dict, strings.NewReader(line))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored, err := io.ReadAll(compressed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &types.Config{S3: types.S3Config{Prefix: "claude-code/"}}
+	m := manifest.New()
+	m.Files["claude-code/app/s.jsonl.zst"] = manifest.FileEntry{Project: "app", Codec: "zstd", DictID: dict.ID()}
+	obj, err := Resolve(cfg, m, "app/s.jsonl")
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	if want := manifest.DictIDKey(cfg, dict.ID()); obj.Dict != want {
+		t.Fatalf("Object.Dict = %q, want %q", obj.Dict, want)
+	}
+
+	tests := []struct {
+		name    string
+		objects map[string][]byte
+		obj     Object
+		wantErr string
+	}{
+		{name: "with dictionary", objects: map[string][]byte{obj.Key: stored, obj.Dict: dict}, obj: obj},
+		{name: "dictionary missing", objects: map[string][]byte{obj.Key: stored}, obj: obj, wantErr: "restore it from a backup"},
+		{name: "entry without dict_id", objects: map[string][]byte{obj.Key: stored}, obj: Object{Key: obj.Key, Codec: codec.Zstd}, wantErr: "records which one it needs (dict_id)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dictCache.Clear()
+			client := &mockS3Client{objects: tt.objects}
+
+			var out bytes.Buffer
+			err := Cat(context.Background(), client, "bucket", tt.obj, false, &out)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Cat() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Cat failed: %v", err)
+			}
+			if out.String() != line {
+				t.Errorf("output = %q, want %q", out.String(), line)
+			}
+		})
+	}
+}
@@ -0,0 +1,239 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/codec"
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/s3errors"
+	"github.com/13rac1/cclogs/internal/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// Log is a session log as the archive-reading commands see it, wherever it
+// is stored.
+type Log struct {
+	Project string
+	Path    string    // Slash-separated path relative to the project directory
+	Size    int64     // Size of the local source file
+	ModTime time.Time // Modification time of the local source file
+}
+
+// Ref returns the "project/path" reference that Source.Open accepts.
+func (l Log) Ref() string {
+	return l.Project + "/" + l.Path
+}
+
+// Source reads session logs either from the local projects root or from the
+// remote archive. Commands that read logs go through a Source so both modes
+// share filters and output.
+type Source interface {
+	// Logs returns the logs of project (all projects if empty), sorted by
+	// project and path.
+	Logs(ctx context.Context, project string) ([]Log, error)
+	// Open returns the plain content of the log at ref ("project/path").
+	Open(ctx context.Context, ref string) (io.ReadCloser, error)
+}
+
+// LocalSource reads logs from the projects root. Content is returned as it
+// is on disk, without redaction.
+type LocalSource struct {
+	Root          string
+	Extensions    []string // Log file extensions (default .jsonl)
+	ProjectDepth  int      // local.project_depth
+	ProjectMarker string   // local.project_marker
+}
+
+// local returns the projects root layout s reads.
+func (s LocalSource) local() types.LocalConfig {
+	return types.LocalConfig{
+		ProjectsRoot:  s.Root,
+		Extensions:    s.Extensions,
+		ProjectDepth:  s.ProjectDepth,
+		ProjectMarker: s.ProjectMarker,
+	}
+}
+
+// Logs implements Source.
+func (s LocalSource) Logs(ctx context.Context, project string) ([]Log, error) {
+	dirs, err := config.ProjectDirs(s.local())
+	if err != nil {
+		return nil, err
+	}
+
+	var logs []Log
+	for _, e := range dirs {
+		if project != "" && e.Name != project {
+			continue
+		}
+		dir := e.Path
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() || !config.HasLogExtension(d.Name(), s.Extensions) {
+				return nil
+			}
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			rel, err := filepath.Rel(dir, path)
+			if err != nil {
+				return err
+			}
+			logs = append(logs, Log{
+				Project: e.Name,
+				Path:    filepath.ToSlash(rel),
+				Size:    info.Size(),
+				ModTime: info.ModTime().UTC(),
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("walking project %s: %w", e.Name, err)
+		}
+	}
+	sortLogs(logs)
+	return logs, nil
+}
+
+// Open implements Source.
+func (s LocalSource) Open(ctx context.Context, ref string) (io.ReadCloser, error) {
+	isProject := func(p string) bool {
+		info, err := os.Stat(filepath.Join(s.Root, filepath.FromSlash(p), s.ProjectMarker))
+		return err == nil && !info.IsDir()
+	}
+	project, relPath, ok := config.SplitRef(s.local(), ref, isProject)
+	if !ok {
+		return nil, fmt.Errorf("expected <project>/<file>, got %q", ref)
+	}
+	path := filepath.Join(filepath.FromSlash(project), filepath.FromSlash(relPath))
+	if !filepath.IsLocal(path) {
+		return nil, fmt.Errorf("%q is outside the projects root", ref)
+	}
+	f, err := os.Open(filepath.Join(s.Root, path))
+	if err != nil {
+		return nil, fmt.Errorf("opening %s: %w", ref, err)
+	}
+	return f, nil
+}
+
+// RemoteSource reads logs from the archive, using the manifest to find them.
+type RemoteSource struct {
+	Config   *types.Config
+	Client   S3Client
+	Manifest *manifest.Manifest
+	Raw      bool // Return stored bytes without decompressing
+}
+
+// Logs implements Source.
+func (s RemoteSource) Logs(ctx context.Context, project string) ([]Log, error) {
+	targets := Targets(s.Config, s.Manifest, project)
+	var logs []Log
+	for _, t := range targets {
+		logs = append(logs, Log{Project: t.Project, Path: t.Path, Size: t.SourceSize, ModTime: t.ModTime})
+	}
+	sortLogs(logs)
+	return logs, nil
+}
+
+// Open implements Source.
+func (s RemoteSource) Open(ctx context.Context, ref string) (io.ReadCloser, error) {
+	obj, err := Resolve(s.Config, s.Manifest, ref)
+	if err != nil {
+		return nil, err
+	}
+	return openObject(ctx, s.Client, s.Config.S3.Bucket, obj, s.Raw)
+}
+
+func sortLogs(logs []Log) {
+	sort.Slice(logs, func(i, j int) bool {
+		if logs[i].Project != logs[j].Project {
+			return logs[i].Project < logs[j].Project
+		}
+		return logs[i].Path < logs[j].Path
+	})
+}
+
+// openObject returns the content of obj. Unless raw is set, content is
+// decompressed when the object has Content-Encoding gzip or was stored with
+// a codec.
+func openObject(ctx context.Context, client S3Client, bucket string, obj Object, raw bool) (io.ReadCloser, error) {
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(obj.Key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("getting %s: %w", obj.Key, s3errors.Wrap(err))
+	}
+	if raw {
+		return output.Body, nil
+	}
+
+	c := obj.Codec
+	if enc := codec.FromContentEncoding(aws.ToString(output.ContentEncoding)); enc != codec.None {
+		c = enc
+	}
+	var dict codec.Dictionary
+	if c == codec.Zstd && obj.Dict != "" {
+		if dict, err = loadDict(ctx, client, bucket, obj.Dict); err != nil {
+			_ = output.Body.Close()
+			return nil, fmt.Errorf("decompressing %s: %w", obj.Key, err)
+		}
+	}
+	rc, err := codec.Decompress(c, dict, output.Body)
+	if err != nil {
+		_ = output.Body.Close()
+		if errors.Is(err, codec.ErrWrongDictionary) {
+			err = fmt.Errorf("%w; cclogs compression train-dict keeps every dictionary next to the manifest as %s.<id>, and the object's manifest entry records which one it needs (dict_id)", err, manifest.DictName)
+		}
+		return nil, fmt.Errorf("decompressing %s: %w", obj.Key, err)
+	}
+	return &stackedCloser{ReadCloser: rc, inner: output.Body}, nil
+}
+
+// dictCache holds the dictionaries loadDict has read, by bucket and key.
+// A dictionary's key names its ID, so it never changes.
+var dictCache sync.Map
+
+// loadDict returns the zstd dictionary at key, reading it only once.
+func loadDict(ctx context.Context, client S3Client, bucket, key string) (codec.Dictionary, error) {
+	if dict, ok := dictCache.Load(bucket + "/" + key); ok {
+		return dict.(codec.Dictionary), nil
+	}
+	dict, err := manifest.LoadDict(ctx, client, bucket, key, 0)
+	if err != nil {
+		return nil, err
+	}
+	if dict == nil {
+		return nil, fmt.Errorf("zstd dictionary %s not found; restore it from a backup to read objects compressed with it", key)
+	}
+	dictCache.Store(bucket+"/"+key, dict)
+	return dict, nil
+}
+
+// stackedCloser closes a decompressor and the body beneath it.
+type stackedCloser struct {
+	io.ReadCloser
+	inner io.Closer
+}
+
+func (c *stackedCloser) Close() error {
+	err := c.ReadCloser.Close()
+	if innerErr := c.inner.Close(); err == nil {
+		err = innerErr
+	}
+	return err
+}
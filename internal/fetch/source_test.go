@@ -0,0 +1,96 @@
+package fetch
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/codec"
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+// TestSources_Parity stores the same logs locally and in the bucket and
+// checks that both sources list and read them identically.
+func TestSources_Parity(t *testing.T) {
+	mtime := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	logs := map[string]string{
+		"app/session.jsonl":   "{\"n\":1}\n{\"n\":2}\n",
+		"app/sub/agent.jsonl": "{\"agent\":true}\n",
+		"web/s1.jsonl":        "{}\n",
+	}
+
+	root := t.TempDir()
+	cfg := &types.Config{S3: types.S3Config{Bucket: "bucket", Prefix: "claude-code/"}}
+	client := &mockS3Client{objects: map[string][]byte{}}
+	m := manifest.New()
+	for ref, content := range logs {
+		path := filepath.Join(root, filepath.FromSlash(ref))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+
+		project, _, _ := strings.Cut(ref, "/")
+		key := "claude-code/" + ref + codec.Gzip.Extension()
+		client.objects[key] = gzipBytes(t, []byte(content))
+		m.Files[key] = manifest.FileEntry{Project: project, Codec: string(codec.Gzip), Size: int64(len(content)), Mtime: mtime}
+	}
+
+	sources := map[string]Source{
+		"local":  LocalSource{Root: root},
+		"remote": RemoteSource{Config: cfg, Client: client, Manifest: m},
+	}
+	ctx := context.Background()
+
+	for _, project := range []string{"", "app", "missing"} {
+		got := map[string][]Log{}
+		for name, src := range sources {
+			l, err := src.Logs(ctx, project)
+			if err != nil {
+				t.Fatalf("%s Logs(%q) error = %v", name, project, err)
+			}
+			got[name] = l
+		}
+		if !reflect.DeepEqual(got["local"], got["remote"]) {
+			t.Errorf("Logs(%q) differ:\nlocal  %+v\nremote %+v", project, got["local"], got["remote"])
+		}
+	}
+
+	for ref, want := range logs {
+		for name, src := range sources {
+			body, err := src.Open(ctx, ref)
+			if err != nil {
+				t.Fatalf("%s Open(%q) error = %v", name, ref, err)
+			}
+			data, err := io.ReadAll(body)
+			_ = body.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(data) != want {
+				t.Errorf("%s Open(%q) = %q, want %q", name, ref, data, want)
+			}
+		}
+	}
+}
+
+func TestLocalSource_OpenRejectsEscape(t *testing.T) {
+	src := LocalSource{Root: t.TempDir()}
+	for _, ref := range []string{"app/../../etc/passwd", "session.jsonl", "app/"} {
+		if body, err := src.Open(context.Background(), ref); err == nil {
+			_ = body.Close()
+			t.Errorf("Open(%q) error = nil, want error", ref)
+		}
+	}
+}
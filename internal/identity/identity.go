@@ -0,0 +1,268 @@
+// Package identity resolves a stable identifier for this machine. The
+// configured local.machine_id wins; otherwise an ID is chosen once and
+// persisted in a state file next to the config. It starts out as the
+// hostname, which earlier versions used, so existing by_host prefixes carry
+// on; after that the hostname is recorded for display only, since laptops
+// rename themselves and containers are random.
+package identity
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+// StateFile is the name of the state file created in the config directory.
+const StateFile = "state.json"
+
+// Sources of a resolved machine ID.
+const (
+	SourceConfig = "config" // local.machine_id
+	SourceState  = "state"  // Chosen once and persisted in StateFile
+)
+
+// state is the persisted part of the identity.
+type state struct {
+	MachineID string `json:"machine_id"`         // Chosen once, never changed
+	LastID    string `json:"last_id,omitempty"`  // Effective ID at the last resolution
+	Projects  int    `json:"projects,omitempty"` // Project directories found by the last upload
+
+	// Bytes uploaded and seconds spent uploading them, with older runs
+	// weighing less (see RecordThroughput)
+	UploadedBytes float64 `json:"uploaded_bytes,omitempty"`
+	UploadSeconds float64 `json:"upload_seconds,omitempty"`
+
+	// Optional S3 features the endpoint supported when last probed
+	Capabilities *Capabilities `json:"capabilities,omitempty"`
+
+	// Manifests of shared archives this machine joined (see RecordJoined)
+	Joined []string `json:"joined,omitempty"`
+}
+
+// Resolve returns the machine identity for the config directory dir. label is
+// the configured local.machine_id (empty if unset). The state file is created
+// on first use. Previous is set when the effective ID differs from the one
+// the last resolution returned.
+func Resolve(label, dir string) (types.Identity, error) {
+	hostname, _ := os.Hostname()
+	path := filepath.Join(dir, StateFile)
+
+	st, err := load(path)
+	if err != nil {
+		return types.Identity{}, err
+	}
+	saved := st
+
+	if st.MachineID == "" {
+		// Earlier versions used the hostname as the ID; starting from it keeps
+		// their by_host prefix. A random ID stands in for a missing hostname.
+		st.MachineID = Sanitize(hostname)
+		if st.MachineID == "" {
+			id, err := generate()
+			if err != nil {
+				return types.Identity{}, err
+			}
+			st.MachineID = id
+		}
+	}
+
+	ident := types.Identity{ID: st.MachineID, Source: SourceState, Hostname: hostname, StatePath: path}
+	if id := Sanitize(label); id != "" {
+		ident.ID = id
+		ident.Source = SourceConfig
+	}
+	if st.LastID != "" && st.LastID != ident.ID {
+		ident.Previous = st.LastID
+	}
+	st.LastID = ident.ID
+
+	if st.MachineID != saved.MachineID || st.LastID != saved.LastID {
+		if err := save(path, st); err != nil {
+			return types.Identity{}, err
+		}
+	}
+	return ident, nil
+}
+
+// RecordProjects remembers how many project directories an upload found, so
+// a projects root that later turns up empty can be recognized as unmounted.
+func RecordProjects(statePath string, n int) error {
+	st, err := load(statePath)
+	if err != nil {
+		return err
+	}
+	if st.Projects == n {
+		return nil
+	}
+	st.Projects = n
+	return save(statePath, st)
+}
+
+// LastProjects returns the count saved by RecordProjects, or 0 if unknown.
+func LastProjects(statePath string) int {
+	st, err := load(statePath)
+	if err != nil {
+		return 0
+	}
+	return st.Projects
+}
+
+// RecordJoined remembers that this machine joined the archive whose
+// manifest is at location, so it is not asked again.
+func RecordJoined(statePath, location string) error {
+	st, err := load(statePath)
+	if err != nil {
+		return err
+	}
+	if slices.Contains(st.Joined, location) {
+		return nil
+	}
+	st.Joined = append(st.Joined, location)
+	return save(statePath, st)
+}
+
+// Joined reports whether RecordJoined recorded the archive at location.
+func Joined(statePath, location string) bool {
+	st, err := load(statePath)
+	if err != nil {
+		return false
+	}
+	return slices.Contains(st.Joined, location)
+}
+
+// throughputDecay is the weight kept by earlier uploads each time
+// RecordThroughput adds one, so the rate follows a changed connection.
+const throughputDecay = 0.5
+
+// RecordThroughput adds an upload of bytes that took elapsed to the rate
+// ObservedThroughput reports.
+func RecordThroughput(statePath string, bytes int64, elapsed time.Duration) error {
+	if bytes <= 0 || elapsed <= 0 {
+		return nil
+	}
+	st, err := load(statePath)
+	if err != nil {
+		return err
+	}
+	st.UploadedBytes = st.UploadedBytes*throughputDecay + float64(bytes)
+	st.UploadSeconds = st.UploadSeconds*throughputDecay + elapsed.Seconds()
+	return save(statePath, st)
+}
+
+// ObservedThroughput returns the upload rate in bytes per second recorded by
+// RecordThroughput, or 0 if unknown.
+func ObservedThroughput(statePath string) float64 {
+	st, err := load(statePath)
+	if err != nil || st.UploadSeconds <= 0 {
+		return 0
+	}
+	return st.UploadedBytes / st.UploadSeconds
+}
+
+// Capabilities records which optional S3 features an endpoint and bucket
+// supported when doctor --capabilities probed them.
+type Capabilities struct {
+	Endpoint  string          `json:"endpoint"` // s3.endpoint; empty for AWS
+	Bucket    string          `json:"bucket"`
+	CheckedAt time.Time       `json:"checked_at"`
+	Features  map[string]bool `json:"features"`
+}
+
+// Supports reports whether feature may be used. Features that were not
+// probed, or not probed for this endpoint, are assumed to work.
+func (c *Capabilities) Supports(feature string) bool {
+	if c == nil {
+		return true
+	}
+	supported, ok := c.Features[feature]
+	return supported || !ok
+}
+
+// RecordCapabilities saves the result of a capability probe, replacing any
+// earlier one.
+func RecordCapabilities(statePath string, c Capabilities) error {
+	st, err := load(statePath)
+	if err != nil {
+		return err
+	}
+	st.Capabilities = &c
+	return save(statePath, st)
+}
+
+// LoadCapabilities returns the recorded capabilities of endpoint and bucket,
+// or nil if they were never probed or a different bucket was.
+func LoadCapabilities(statePath, endpoint, bucket string) *Capabilities {
+	st, err := load(statePath)
+	if err != nil || st.Capabilities == nil {
+		return nil
+	}
+	if c := st.Capabilities; c.Endpoint == endpoint && c.Bucket == bucket {
+		return c
+	}
+	return nil
+}
+
+// Sanitize makes s safe to use as a single S3 key path segment.
+func Sanitize(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, strings.TrimSpace(s))
+}
+
+// generate returns a new random machine ID.
+func generate() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generating machine ID: %w", err)
+	}
+	return "m-" + hex.EncodeToString(b), nil
+}
+
+func load(path string) (state, error) {
+	var st state
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return st, nil
+	}
+	if err != nil {
+		return st, fmt.Errorf("reading state file: %w", err)
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("parsing state file %s: %w", path, err)
+	}
+	return st, nil
+}
+
+func save(path string, st state) error {
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling state: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("creating state directory: %w", err)
+	}
+	// Write then rename so a crash never leaves a truncated state file
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("writing state file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("writing state file: %w", err)
+	}
+	return nil
+}
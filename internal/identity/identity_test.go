@@ -0,0 +1,214 @@
+package identity
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+)
+
+func TestResolve_SeedsAndPersists(t *testing.T) {
+	dir := t.TempDir()
+
+	first, err := Resolve("", dir)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	hostname, _ := os.Hostname()
+	// Earlier versions used the hostname, so existing prefixes carry on
+	if want := Sanitize(hostname); want != "" && first.ID != want {
+		t.Errorf("ID = %q, want sanitized hostname %q", first.ID, want)
+	}
+	if first.Source != SourceState {
+		t.Errorf("Source = %q, want %q", first.Source, SourceState)
+	}
+	if first.StatePath != filepath.Join(dir, StateFile) {
+		t.Errorf("StatePath = %q, want %q", first.StatePath, filepath.Join(dir, StateFile))
+	}
+	if first.Hostname != hostname {
+		t.Errorf("Hostname = %q, want %q", first.Hostname, hostname)
+	}
+	if first.Previous != "" {
+		t.Errorf("Previous = %q on first use, want empty", first.Previous)
+	}
+
+	second, err := Resolve("", dir)
+	if err != nil {
+		t.Fatalf("second Resolve() error = %v", err)
+	}
+	if second.ID != first.ID {
+		t.Errorf("ID changed between runs: %q then %q", first.ID, second.ID)
+	}
+	if second.Previous != "" {
+		t.Errorf("Previous = %q on unchanged ID, want empty", second.Previous)
+	}
+
+}
+
+func TestResolve_KeepsPersistedID(t *testing.T) {
+	dir := t.TempDir()
+	// Chosen under an earlier hostname
+	if err := os.WriteFile(filepath.Join(dir, StateFile), []byte(`{"machine_id":"old-laptop","last_id":"old-laptop"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := Resolve("", dir)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+	if got.ID != "old-laptop" || got.Source != SourceState || got.Previous != "" {
+		t.Errorf("Resolve() = {ID:%q Source:%q Previous:%q}, want the persisted ID unchanged", got.ID, got.Source, got.Previous)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	id, err := generate()
+	if err != nil {
+		t.Fatalf("generate() error = %v", err)
+	}
+	if !regexp.MustCompile(`^m-[0-9a-f]{12}$`).MatchString(id) {
+		t.Errorf("ID = %q, want m- followed by 12 hex digits", id)
+	}
+}
+
+func TestResolve_LabelPrecedence(t *testing.T) {
+	dir := t.TempDir()
+	generated, err := Resolve("", dir)
+	if err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	steps := []struct {
+		label        string
+		wantID       string
+		wantSource   string
+		wantPrevious string
+	}{
+		{"Work Laptop", "Work-Laptop", SourceConfig, generated.ID},
+		{"Work Laptop", "Work-Laptop", SourceConfig, ""},
+		{"desk", "desk", SourceConfig, "Work-Laptop"},
+		// Removing the label falls back to the generated ID, which survived
+		{"", generated.ID, SourceState, "desk"},
+	}
+
+	for i, s := range steps {
+		got, err := Resolve(s.label, dir)
+		if err != nil {
+			t.Fatalf("step %d: Resolve(%q) error = %v", i, s.label, err)
+		}
+		if got.ID != s.wantID || got.Source != s.wantSource || got.Previous != s.wantPrevious {
+			t.Errorf("step %d: Resolve(%q) = {ID:%q Source:%q Previous:%q}, want {%q %q %q}",
+				i, s.label, got.ID, got.Source, got.Previous, s.wantID, s.wantSource, s.wantPrevious)
+		}
+	}
+}
+
+func TestResolve_CorruptState(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, StateFile), []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Resolve("", dir); err == nil {
+		t.Error("Resolve() error = nil, want parse error")
+	}
+}
+
+func TestSanitize(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"laptop", "laptop"},
+		{" Work Laptop ", "Work-Laptop"},
+		{"host.local", "host.local"},
+		{"a/b:c", "a-b-c"},
+	}
+	for _, tt := range tests {
+		if got := Sanitize(tt.in); got != tt.want {
+			t.Errorf("Sanitize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRecordThroughput(t *testing.T) {
+	path := filepath.Join(t.TempDir(), StateFile)
+	if got := ObservedThroughput(path); got != 0 {
+		t.Fatalf("ObservedThroughput() with no state = %v, want 0", got)
+	}
+
+	steps := []struct {
+		bytes   int64
+		elapsed time.Duration
+		want    float64
+	}{
+		{bytes: 1000, elapsed: 10 * time.Second, want: 100},
+		{bytes: 0, elapsed: time.Second, want: 100}, // Nothing uploaded: not recorded
+		// Earlier runs weigh half: (500+3000) / (5+10)
+		{bytes: 3000, elapsed: 10 * time.Second, want: 3500.0 / 15},
+	}
+	for i, s := range steps {
+		if err := RecordThroughput(path, s.bytes, s.elapsed); err != nil {
+			t.Fatalf("step %d: RecordThroughput() error = %v", i, err)
+		}
+		if got := ObservedThroughput(path); got != s.want {
+			t.Errorf("step %d: ObservedThroughput() = %v, want %v", i, got, s.want)
+		}
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	path := filepath.Join(t.TempDir(), StateFile)
+	if c := LoadCapabilities(path, "", "bucket"); c != nil || !c.Supports("tagging") {
+		t.Fatalf("LoadCapabilities() with no state = %v, want nil supporting everything", c)
+	}
+
+	err := RecordCapabilities(path, Capabilities{
+		Endpoint: "https://minio.local",
+		Bucket:   "bucket",
+		Features: map[string]bool{"tagging": false, "copy_object": true},
+	})
+	if err != nil {
+		t.Fatalf("RecordCapabilities() error = %v", err)
+	}
+	// Resolving the identity keeps the probe result
+	if _, err := Resolve("", filepath.Dir(path)); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	c := LoadCapabilities(path, "https://minio.local", "bucket")
+	if c == nil {
+		t.Fatal("LoadCapabilities() = nil after RecordCapabilities")
+	}
+	if c.Supports("tagging") || !c.Supports("copy_object") || !c.Supports("never_probed") {
+		t.Errorf("Supports() wrong for %v", c.Features)
+	}
+	if c := LoadCapabilities(path, "", "bucket"); c != nil {
+		t.Errorf("LoadCapabilities() for another endpoint = %v, want nil", c)
+	}
+}
+
+func TestJoined(t *testing.T) {
+	path := filepath.Join(t.TempDir(), StateFile)
+	const location = "s3://bucket/claude-code/.manifest.json"
+	if Joined(path, location) {
+		t.Fatal("Joined() = true with no state")
+	}
+
+	for range 2 {
+		if err := RecordJoined(path, location); err != nil {
+			t.Fatalf("RecordJoined() error = %v", err)
+		}
+	}
+	// Resolving the identity keeps the joined archives
+	if _, err := Resolve("", filepath.Dir(path)); err != nil {
+		t.Fatalf("Resolve() error = %v", err)
+	}
+
+	if !Joined(path, location) {
+		t.Error("Joined() = false after RecordJoined")
+	}
+	if Joined(path, "s3://other/claude-code/.manifest.json") {
+		t.Error("Joined() = true for another archive")
+	}
+}
@@ -0,0 +1,107 @@
+// Package lock keeps upload runs on one machine from overlapping. Every run
+// that writes to the bucket holds an exclusive lock on a file next to the
+// config, which records the holder's pid for the message shown to a second
+// run. The lock is released by the operating system when its holder exits,
+// so a file left behind by a crashed run is simply taken over.
+//
+// The optional remote lock does the same across machines sharing a prefix,
+// using an object next to the manifest that expires unless its holder keeps
+// refreshing it.
+package lock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// File is the name of the lock file created in the config directory.
+const File = "lock"
+
+// pollInterval is how often Wait retries a held lock.
+const pollInterval = 500 * time.Millisecond
+
+// HeldError is returned by Acquire when another process holds the lock.
+type HeldError struct {
+	PID int // Holder's pid, or 0 if the lock file did not record one
+}
+
+func (e *HeldError) Error() string {
+	if e.PID == 0 {
+		return "another upload is running"
+	}
+	return fmt.Sprintf("another upload is running (pid %d)", e.PID)
+}
+
+// Lock is a held lock. Release it when the run ends.
+type Lock struct {
+	f *os.File
+}
+
+// Acquire takes the lock at path without waiting. It returns a *HeldError if
+// another process holds it.
+func Acquire(path string) (*Lock, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("opening lock file: %w", err)
+	}
+
+	held, err := tryLock(f)
+	if err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("locking %s: %w", path, err)
+	}
+	if held {
+		pid := readPID(f)
+		_ = f.Close()
+		return nil, &HeldError{PID: pid}
+	}
+
+	// Replace the pid a previous (possibly crashed) holder left behind
+	if err := f.Truncate(0); err == nil {
+		_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &Lock{f: f}, nil
+}
+
+// Wait takes the lock at path, retrying while another process holds it until
+// ctx is done.
+func Wait(ctx context.Context, path string) (*Lock, error) {
+	for {
+		l, err := Acquire(path)
+		var held *HeldError
+		if !errors.As(err, &held) {
+			return l, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Release gives up the lock. The file is kept: removing it could let a
+// waiting process lock a file that a third process then recreates.
+func (l *Lock) Release() error {
+	if err := unlock(l.f); err != nil {
+		_ = l.f.Close()
+		return fmt.Errorf("unlocking: %w", err)
+	}
+	return l.f.Close()
+}
+
+// readPID returns the pid recorded in f, or 0.
+func readPID(f *os.File) int {
+	buf := make([]byte, 32)
+	n, _ := f.ReadAt(buf, 0)
+	pid, err := strconv.Atoi(strings.TrimSpace(string(buf[:n])))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,26 @@
+//go:build !unix
+
+package lock
+
+import "os"
+
+// tryLock reports held if f records the pid of a live process. Without flock
+// two runs starting at the same instant can both succeed, but a lock left by
+// a dead process is still detected as stale.
+func tryLock(f *os.File) (held bool, err error) {
+	pid := readPID(f)
+	if pid == 0 || pid == os.Getpid() {
+		return false, nil
+	}
+	// On Windows FindProcess fails for pids that are not running
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false, nil
+	}
+	_ = p.Release()
+	return true, nil
+}
+
+func unlock(f *os.File) error {
+	return f.Truncate(0)
+}
@@ -0,0 +1,92 @@
+//go:build unix
+
+package lock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquire(t *testing.T) {
+	path := filepath.Join(t.TempDir(), File)
+
+	first, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire() error = %v", err)
+	}
+
+	_, err = Acquire(path)
+	var held *HeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("second Acquire() error = %v, want *HeldError", err)
+	}
+	if held.PID != os.Getpid() {
+		t.Errorf("HeldError.PID = %d, want %d", held.PID, os.Getpid())
+	}
+
+	if err := first.Release(); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	again, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire() after Release error = %v", err)
+	}
+	_ = again.Release()
+}
+
+func TestAcquireStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), File)
+	// A crashed run leaves its pid behind without holding the lock
+	if err := os.WriteFile(path, []byte("999999999\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Acquire() over stale lock error = %v", err)
+	}
+	defer func() { _ = l.Release() }()
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = f.Close() }()
+	if got := readPID(f); got != os.Getpid() {
+		t.Errorf("lock file pid = %d, want %d", got, os.Getpid())
+	}
+}
+
+func TestWait(t *testing.T) {
+	path := filepath.Join(t.TempDir(), File)
+	first, err := Acquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := Wait(ctx, path); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Wait() on held lock error = %v, want context.DeadlineExceeded", err)
+	}
+
+	time.AfterFunc(100*time.Millisecond, func() { _ = first.Release() })
+	l, err := Wait(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Wait() error = %v", err)
+	}
+	_ = l.Release()
+}
+
+func TestHeldError(t *testing.T) {
+	if got := (&HeldError{PID: 42}).Error(); got != "another upload is running (pid 42)" {
+		t.Errorf("Error() = %q", got)
+	}
+	if got := (&HeldError{}).Error(); got != "another upload is running" {
+		t.Errorf("Error() = %q", got)
+	}
+}
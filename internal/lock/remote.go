@@ -0,0 +1,279 @@
+package lock
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// RemoteFile is the name of the advisory lock object, stored next to the
+// manifest.
+const RemoteFile = ".cclogs-lock"
+
+// DefaultTTL is how long a remote lock stays valid without a refresh. The
+// holder refreshes it every third of that.
+const DefaultTTL = 5 * time.Minute
+
+// remotePollInterval is how often WaitRemote retries a held remote lock.
+var remotePollInterval = 10 * time.Second
+
+// RemoteClient is the part of the S3 API the remote lock uses.
+type RemoteClient interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+// Holder identifies the run holding a remote lock.
+type Holder struct {
+	Machine  string    `json:"machine"`
+	Hostname string    `json:"hostname,omitempty"`
+	PID      int       `json:"pid"`
+	Acquired time.Time `json:"acquired"`
+	Expires  time.Time `json:"expires"`
+}
+
+// RemoteHeldError is returned by AcquireRemote when another run holds an
+// unexpired remote lock.
+type RemoteHeldError struct {
+	Holder Holder
+}
+
+func (e *RemoteHeldError) Error() string {
+	h := e.Holder
+	who := h.Machine
+	if h.Hostname != "" && h.Hostname != h.Machine {
+		who += " (" + h.Hostname + ")"
+	}
+	return fmt.Sprintf("another upload is running on %s, pid %d, since %s (lock expires %s)",
+		who, h.PID, h.Acquired.Format(time.RFC3339), h.Expires.Format(time.RFC3339))
+}
+
+// Remote is a held remote lock. It is refreshed in the background until
+// Release.
+type Remote struct {
+	client RemoteClient
+	bucket string
+	key    string
+	ttl    time.Duration
+	holder Holder
+
+	stop context.CancelFunc
+	done chan struct{}
+
+	mu   sync.Mutex
+	etag string
+	lost error // Set when a refresh found the lock taken over
+}
+
+// AcquireRemote creates the lock object at key without waiting. A lock whose
+// holder let it expire is broken. It returns a *RemoteHeldError if another
+// run holds the lock.
+func AcquireRemote(ctx context.Context, client RemoteClient, bucket, key string, holder Holder, ttl time.Duration) (*Remote, error) {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	holder.Acquired = time.Now().UTC()
+	r := &Remote{client: client, bucket: bucket, key: key, ttl: ttl, holder: holder}
+
+	var current Holder
+	for range 3 {
+		etag, err := r.put(ctx, &s3.PutObjectInput{IfNoneMatch: aws.String("*")})
+		if err == nil {
+			r.start(etag)
+			return r, nil
+		}
+		if !isPreconditionFailed(err) {
+			return nil, fmt.Errorf("creating remote lock: %w", err)
+		}
+
+		var currentETag string
+		current, currentETag, err = readRemote(ctx, client, bucket, key)
+		if isNotFound(err) {
+			continue // Released in between
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading remote lock: %w", err)
+		}
+		if time.Now().Before(current.Expires) {
+			return nil, &RemoteHeldError{Holder: current}
+		}
+
+		// Stale: replace it, unless another run broke it first
+		etag, err = r.put(ctx, &s3.PutObjectInput{IfMatch: aws.String(currentETag)})
+		if err == nil {
+			r.start(etag)
+			return r, nil
+		}
+		if !isPreconditionFailed(err) {
+			return nil, fmt.Errorf("breaking stale remote lock: %w", err)
+		}
+	}
+	return nil, &RemoteHeldError{Holder: current}
+}
+
+// WaitRemote is AcquireRemote, retrying while another run holds the lock
+// until ctx is done.
+func WaitRemote(ctx context.Context, client RemoteClient, bucket, key string, holder Holder, ttl time.Duration) (*Remote, error) {
+	for {
+		r, err := AcquireRemote(ctx, client, bucket, key, holder, ttl)
+		var held *RemoteHeldError
+		if !errors.As(err, &held) {
+			return r, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(remotePollInterval):
+		}
+	}
+}
+
+// Release stops refreshing the lock and deletes it if it is still ours. It
+// reports an error if the lock was taken over while held.
+func (r *Remote) Release(ctx context.Context) error {
+	r.stop()
+	<-r.done
+
+	r.mu.Lock()
+	etag, lost := r.etag, r.lost
+	r.mu.Unlock()
+	if lost != nil {
+		return lost
+	}
+
+	// Only delete the object we wrote: conditional deletes are not
+	// supported everywhere, so compare ETags first
+	_, current, err := readRemote(ctx, r.client, r.bucket, r.key)
+	if isNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading remote lock: %w", err)
+	}
+	if current != etag {
+		return errors.New("remote lock was taken over by another run")
+	}
+	if _, err := r.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(r.bucket),
+		Key:    aws.String(r.key),
+	}); err != nil {
+		return fmt.Errorf("deleting remote lock: %w", err)
+	}
+	return nil
+}
+
+// start records the lock's ETag and begins refreshing it.
+func (r *Remote) start(etag string) {
+	r.etag = etag
+	ctx, cancel := context.WithCancel(context.Background())
+	r.stop = cancel
+	r.done = make(chan struct{})
+	go r.refreshLoop(ctx)
+}
+
+// refreshLoop extends the lock's expiry until ctx is cancelled or the lock
+// is found taken over. Transient failures are retried at the next tick;
+// the lock only expires if they persist for the whole TTL.
+func (r *Remote) refreshLoop(ctx context.Context) {
+	defer close(r.done)
+	ticker := time.NewTicker(r.ttl / 3)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		r.mu.Lock()
+		etag := r.etag
+		r.mu.Unlock()
+
+		putCtx, cancel := context.WithTimeout(ctx, r.ttl/3)
+		next, err := r.put(putCtx, &s3.PutObjectInput{IfMatch: aws.String(etag)})
+		cancel()
+		if ctx.Err() != nil {
+			return
+		}
+
+		r.mu.Lock()
+		if err == nil {
+			r.etag = next
+		} else if isPreconditionFailed(err) {
+			r.lost = errors.New("remote lock was taken over by another run")
+		}
+		lost := r.lost != nil
+		r.mu.Unlock()
+		if lost {
+			return
+		}
+	}
+}
+
+// put writes the holder with a fresh expiry using the conditions in input
+// and returns the new object's ETag.
+func (r *Remote) put(ctx context.Context, input *s3.PutObjectInput) (string, error) {
+	r.holder.Expires = time.Now().UTC().Add(r.ttl)
+
+	data, err := json.Marshal(r.holder)
+	if err != nil {
+		return "", fmt.Errorf("marshaling remote lock: %w", err)
+	}
+
+	input.Bucket = aws.String(r.bucket)
+	input.Key = aws.String(r.key)
+	input.Body = bytes.NewReader(data)
+	input.ContentType = aws.String("application/json")
+	out, err := r.client.PutObject(ctx, input)
+	if err != nil {
+		return "", err
+	}
+	return aws.ToString(out.ETag), nil
+}
+
+// readRemote returns the holder recorded in the lock object and its ETag. An
+// unreadable lock yields a zero Holder, which counts as expired.
+func readRemote(ctx context.Context, client RemoteClient, bucket, key string) (Holder, string, error) {
+	out, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return Holder{}, "", err
+	}
+	defer func() { _ = out.Body.Close() }()
+
+	var h Holder
+	_ = json.NewDecoder(out.Body).Decode(&h)
+	return h, aws.ToString(out.ETag), nil
+}
+
+// isPreconditionFailed reports whether a conditional write lost to another
+// writer: 412 when the condition failed, 409 when S3 saw concurrent
+// conditional writes.
+func isPreconditionFailed(err error) bool {
+	var respErr *awshttp.ResponseError
+	if !errors.As(err, &respErr) {
+		return false
+	}
+	code := respErr.HTTPStatusCode()
+	return code == http.StatusPreconditionFailed || code == http.StatusConflict
+}
+
+func isNotFound(err error) bool {
+	var nsk *s3types.NoSuchKey
+	var nf *s3types.NotFound
+	return errors.As(err, &nsk) || errors.As(err, &nf)
+}
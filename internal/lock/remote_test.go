@@ -0,0 +1,288 @@
+package lock
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+const testKey = "claude-code/" + RemoteFile
+
+// condS3 is a minimal in-memory S3 server for path-style GET, PUT, and
+// DELETE that honors If-None-Match and If-Match on PUT.
+type condS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	etags   map[string]string
+	n       int
+}
+
+func newCondS3(t *testing.T) (*s3.Client, *condS3) {
+	t.Helper()
+	f := &condS3{objects: make(map[string][]byte), etags: make(map[string]string)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(srv.URL),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+	})
+	return client, f
+}
+
+// store writes key unconditionally, as another machine would.
+func (f *condS3) store(key string, data []byte) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.n++
+	f.objects[key] = data
+	f.etags[key] = fmt.Sprintf(`"%d"`, f.n)
+}
+
+// holder returns the holder recorded at key.
+func (f *condS3) holder(t *testing.T, key string) (Holder, bool) {
+	t.Helper()
+	f.mu.Lock()
+	data, ok := f.objects[key]
+	f.mu.Unlock()
+	var h Holder
+	if ok {
+		if err := json.Unmarshal(data, &h); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return h, ok
+}
+
+func (f *condS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	key := parts[1]
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, exists := f.objects[key]
+
+	switch r.Method {
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if (r.Header.Get("If-None-Match") == "*" && exists) ||
+			(r.Header.Get("If-Match") != "" && r.Header.Get("If-Match") != f.etags[key]) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			_, _ = io.WriteString(w, `<Error><Code>PreconditionFailed</Code></Error>`)
+			return
+		}
+		f.n++
+		f.objects[key] = body
+		f.etags[key] = fmt.Sprintf(`"%d"`, f.n)
+		w.Header().Set("ETag", f.etags[key])
+		w.WriteHeader(http.StatusOK)
+	case http.MethodGet:
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = io.WriteString(w, `<Error><Code>NoSuchKey</Code></Error>`)
+			return
+		}
+		w.Header().Set("ETag", f.etags[key])
+		_, _ = w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, key)
+		delete(f.etags, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestAcquireRemote(t *testing.T) {
+	client, f := newCondS3(t)
+	ctx := context.Background()
+
+	a, err := AcquireRemote(ctx, client, "bucket", testKey, Holder{Machine: "a", PID: 1}, time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireRemote() error = %v", err)
+	}
+
+	_, err = AcquireRemote(ctx, client, "bucket", testKey, Holder{Machine: "b", PID: 2}, time.Minute)
+	var held *RemoteHeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("second AcquireRemote() error = %v, want *RemoteHeldError", err)
+	}
+	if held.Holder.Machine != "a" || held.Holder.PID != 1 {
+		t.Errorf("holder = %+v, want machine a pid 1", held.Holder)
+	}
+
+	if err := a.Release(ctx); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, ok := f.holder(t, testKey); ok {
+		t.Error("lock object still exists after Release")
+	}
+
+	b, err := AcquireRemote(ctx, client, "bucket", testKey, Holder{Machine: "b", PID: 2}, time.Minute)
+	if err != nil {
+		t.Fatalf("AcquireRemote() after Release error = %v", err)
+	}
+	_ = b.Release(ctx)
+}
+
+func TestAcquireRemoteBreaksExpired(t *testing.T) {
+	tests := []struct {
+		name  string
+		stale []byte
+	}{
+		{"expired", mustJSON(t, Holder{Machine: "a", PID: 1, Expires: time.Now().Add(-time.Second)})},
+		{"unreadable", []byte("not json")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, f := newCondS3(t)
+			f.store(testKey, tt.stale)
+
+			r, err := AcquireRemote(context.Background(), client, "bucket", testKey, Holder{Machine: "b", PID: 2}, time.Minute)
+			if err != nil {
+				t.Fatalf("AcquireRemote() over stale lock error = %v", err)
+			}
+			defer func() { _ = r.Release(context.Background()) }()
+
+			if h, _ := f.holder(t, testKey); h.Machine != "b" {
+				t.Errorf("lock holder = %q, want b", h.Machine)
+			}
+		})
+	}
+}
+
+func TestRemoteRefresh(t *testing.T) {
+	client, f := newCondS3(t)
+	ctx := context.Background()
+
+	const ttl = 150 * time.Millisecond
+	r, err := AcquireRemote(ctx, client, "bucket", testKey, Holder{Machine: "a", PID: 1}, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, _ := f.holder(t, testKey)
+
+	// Without refreshes the lock would have expired by now
+	time.Sleep(2 * ttl)
+	refreshed, _ := f.holder(t, testKey)
+	if !refreshed.Expires.After(first.Expires) {
+		t.Errorf("expiry not extended: %v, was %v", refreshed.Expires, first.Expires)
+	}
+	if !refreshed.Acquired.Equal(first.Acquired) {
+		t.Errorf("acquired changed on refresh: %v, was %v", refreshed.Acquired, first.Acquired)
+	}
+	var held *RemoteHeldError
+	if _, err := AcquireRemote(ctx, client, "bucket", testKey, Holder{Machine: "b"}, ttl); !errors.As(err, &held) {
+		t.Errorf("AcquireRemote() on refreshed lock error = %v, want *RemoteHeldError", err)
+	}
+
+	if err := r.Release(ctx); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+}
+
+func TestRemoteTakenOver(t *testing.T) {
+	client, f := newCondS3(t)
+	ctx := context.Background()
+
+	const ttl = 60 * time.Millisecond
+	r, err := AcquireRemote(ctx, client, "bucket", testKey, Holder{Machine: "a", PID: 1}, ttl)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Another machine broke the lock, e.g. after a long network outage here
+	f.store(testKey, mustJSON(t, Holder{Machine: "b", PID: 2, Expires: time.Now().Add(time.Hour)}))
+	time.Sleep(2 * ttl)
+
+	if err := r.Release(ctx); err == nil || !strings.Contains(err.Error(), "taken over") {
+		t.Errorf("Release() error = %v, want taken over", err)
+	}
+	if h, _ := f.holder(t, testKey); h.Machine != "b" {
+		t.Errorf("Release() removed the other run's lock: holder = %q", h.Machine)
+	}
+}
+
+func TestWaitRemote(t *testing.T) {
+	old := remotePollInterval
+	remotePollInterval = 10 * time.Millisecond
+	defer func() { remotePollInterval = old }()
+
+	client, _ := newCondS3(t)
+	first, err := AcquireRemote(context.Background(), client, "bucket", testKey, Holder{Machine: "a"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := WaitRemote(ctx, client, "bucket", testKey, Holder{Machine: "b"}, time.Minute); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("WaitRemote() on held lock error = %v, want context.DeadlineExceeded", err)
+	}
+
+	time.AfterFunc(50*time.Millisecond, func() { _ = first.Release(context.Background()) })
+	r, err := WaitRemote(context.Background(), client, "bucket", testKey, Holder{Machine: "b"}, time.Minute)
+	if err != nil {
+		t.Fatalf("WaitRemote() error = %v", err)
+	}
+	_ = r.Release(context.Background())
+}
+
+func TestRemoteReleaseAfterInterrupt(t *testing.T) {
+	client, f := newCondS3(t)
+
+	// The run's context is cancelled by Ctrl+C; release uses a fresh one
+	ctx, cancel := context.WithCancel(context.Background())
+	r, err := AcquireRemote(ctx, client, "bucket", testKey, Holder{Machine: "a"}, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+
+	if err := r.Release(context.Background()); err != nil {
+		t.Fatalf("Release() error = %v", err)
+	}
+	if _, ok := f.holder(t, testKey); ok {
+		t.Error("lock object still exists after Release")
+	}
+}
+
+func TestRemoteHeldError(t *testing.T) {
+	at := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+	err := &RemoteHeldError{Holder: Holder{Machine: "m-1", Hostname: "laptop", PID: 42, Acquired: at, Expires: at.Add(DefaultTTL)}}
+	want := "another upload is running on m-1 (laptop), pid 42, since 2025-06-01T09:00:00Z (lock expires 2025-06-01T09:05:00Z)"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func mustJSON(t *testing.T, v any) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
@@ -0,0 +1,58 @@
+package manifest
+
+import (
+	"slices"
+	"time"
+)
+
+// Archive identifies the archive a manifest belongs to, so a machine that
+// starts uploading to a prefix other machines already share does so on
+// purpose rather than by accident.
+type Archive struct {
+	Name     string   `json:"name,omitempty"`     // s3.archive_name of the machine that named it
+	Machines []string `json:"machines,omitempty"` // Machine IDs that joined, sorted
+}
+
+// Machines returns the IDs of the machines that joined the archive or
+// uploaded one of its entries, sorted. Manifests written before the archive
+// block only have the latter.
+func (m *Manifest) Machines() []string {
+	machines := slices.Clone(m.Archive.Machines)
+	for _, entry := range m.Files {
+		if entry.Machine != "" && !slices.Contains(machines, entry.Machine) {
+			machines = append(machines, entry.Machine)
+		}
+	}
+	slices.Sort(machines)
+	return machines
+}
+
+// HasMachine reports whether the machine id joined the archive or uploaded
+// one of its entries.
+func (m *Manifest) HasMachine(id string) bool {
+	return slices.Contains(m.Machines(), id)
+}
+
+// Join records the machine id as part of the archive, and gives the archive
+// name if it has none yet.
+func (m *Manifest) Join(id, name string) {
+	if id != "" && !slices.Contains(m.Archive.Machines, id) {
+		m.Archive.Machines = append(m.Archive.Machines, id)
+		slices.Sort(m.Archive.Machines)
+	}
+	if m.Archive.Name == "" {
+		m.Archive.Name = name
+	}
+}
+
+// LatestUpload returns when an entry was last uploaded: the manifest's
+// LastUpload, or for manifests without one the latest entry upload time.
+func (m *Manifest) LatestUpload() time.Time {
+	latest := m.LastUpload
+	for _, entry := range m.Files {
+		if entry.UploadedAt.After(latest) {
+			latest = entry.UploadedAt
+		}
+	}
+	return latest
+}
@@ -0,0 +1,46 @@
+package manifest
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestArchive(t *testing.T) {
+	m := New()
+	if got := m.Machines(); len(got) != 0 || m.HasMachine("laptop-a1b2c3") {
+		t.Fatalf("Machines() of a new manifest = %v, want none", got)
+	}
+
+	// Entries from before the archive block still count their machines
+	early := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	m.Files["claude-code/api/a.jsonl"] = FileEntry{Machine: "desktop-d4e5f6", UploadedAt: early}
+	m.Files["claude-code/api/b.jsonl"] = FileEntry{}
+
+	m.Join("laptop-a1b2c3", "team-logs")
+	m.Join("laptop-a1b2c3", "renamed")
+	m.Join("desktop-d4e5f6", "")
+	if m.Archive.Name != "team-logs" {
+		t.Errorf("Archive.Name = %q, want the first name kept", m.Archive.Name)
+	}
+	if want := []string{"desktop-d4e5f6", "laptop-a1b2c3"}; !slices.Equal(m.Archive.Machines, want) {
+		t.Errorf("Archive.Machines = %v, want %v", m.Archive.Machines, want)
+	}
+
+	m.Archive.Machines = []string{"laptop-a1b2c3"}
+	if want := []string{"desktop-d4e5f6", "laptop-a1b2c3"}; !slices.Equal(m.Machines(), want) {
+		t.Errorf("Machines() = %v, want %v", m.Machines(), want)
+	}
+	if !m.HasMachine("desktop-d4e5f6") || m.HasMachine("server-0a0b0c") {
+		t.Error("HasMachine() wrong")
+	}
+
+	if got := m.LatestUpload(); !got.Equal(early) {
+		t.Errorf("LatestUpload() = %v, want the entry time %v", got, early)
+	}
+	later := early.Add(time.Hour)
+	m.LastUpload = later
+	if got := m.LatestUpload(); !got.Equal(later) {
+		t.Errorf("LatestUpload() = %v, want LastUpload %v", got, later)
+	}
+}
@@ -0,0 +1,129 @@
+package manifest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CacheDirName is the directory under the cache directory holding cached
+// manifests.
+const CacheDirName = "cache"
+
+var (
+	cacheMu  sync.Mutex
+	cacheDir string
+)
+
+// SetCacheDir makes Load and Save keep a copy of each manifest under dir, so
+// Load can ask S3 for the manifest only if it changed. An empty dir disables
+// the cache.
+func SetCacheDir(dir string) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	cacheDir = dir
+}
+
+// cacheFile is a cached manifest with the ETag of the object it was read
+// from or written as.
+type cacheFile struct {
+	ETag     string          `json:"etag"`
+	Manifest json.RawMessage `json:"manifest"`
+}
+
+// CachePath returns the file the manifest at bucket/key is cached in, or ""
+// if the cache is disabled. Cached manifests mirror the bucket layout, e.g.
+// cache/<bucket>/<prefix>/.manifest.json.
+func CachePath(bucket, key string) string {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	if cacheDir == "" {
+		return ""
+	}
+	return filepath.Join(cacheDir, bucket, filepath.FromSlash(key))
+}
+
+// CachedAt returns when the cached copy of the manifest at bucket/key was
+// last written. ok is false if there is none; err is set if it exists but
+// cannot be used.
+func CachedAt(bucket, key string) (t time.Time, ok bool, err error) {
+	path := CachePath(bucket, key)
+	if path == "" {
+		return time.Time{}, false, nil
+	}
+	info, err := os.Stat(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if _, _, err := readCache(path); err != nil {
+		return info.ModTime(), true, err
+	}
+	return info.ModTime(), true, nil
+}
+
+// readCache returns the manifest cached at path and its ETag.
+func readCache(path string) (*Manifest, string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", err
+	}
+	var c cacheFile
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, "", fmt.Errorf("parsing cached manifest: %w", err)
+	}
+	if c.ETag == "" {
+		return nil, "", errors.New("cached manifest has no ETag")
+	}
+	m, err := parse(c.Manifest)
+	if err != nil {
+		return nil, "", err
+	}
+	return m, c.ETag, nil
+}
+
+// writeCache replaces the cached manifest at path with data, the manifest as
+// stored under etag. The file is renamed into place, so readers see the old
+// copy or the new one. If it cannot be written, any old copy is removed
+// rather than left to be paired with a newer ETag.
+func writeCache(path, etag string, data []byte) {
+	if path == "" {
+		return
+	}
+	if etag == "" {
+		_ = os.Remove(path)
+		return
+	}
+	if err := writeFileAtomic(path, cacheFile{ETag: etag, Manifest: data}); err != nil {
+		_ = os.Remove(path)
+	}
+}
+
+func writeFileAtomic(path string, c cacheFile) error {
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".manifest-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() { _ = os.Remove(tmp.Name()) }()
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,142 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// etagStore holds one object and answers conditional GETs like S3.
+type etagStore struct {
+	data        []byte
+	etag        string
+	version     int
+	downloads   int
+	notModified int
+}
+
+func (s *etagStore) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	if s.data == nil {
+		return nil, &s3types.NoSuchKey{}
+	}
+	if aws.ToString(params.IfNoneMatch) == s.etag {
+		s.notModified++
+		return nil, &awshttp.ResponseError{ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: http.StatusNotModified}},
+			Err:      fmt.Errorf("not modified"),
+		}}
+	}
+	s.downloads++
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(s.data)), ETag: aws.String(s.etag)}, nil
+}
+
+func (s *etagStore) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	s.version++
+	s.data, s.etag = data, fmt.Sprintf(`"v%d"`, s.version)
+	return &s3.PutObjectOutput{ETag: aws.String(s.etag)}, nil
+}
+
+func TestLoadCache(t *testing.T) {
+	dir := t.TempDir()
+	SetCacheDir(dir)
+	defer SetCacheDir("")
+
+	ctx := context.Background()
+	store := &etagStore{}
+	const key = "claude-code/.manifest.json"
+
+	// No manifest yet: nothing to cache
+	if _, err := Load(ctx, store, "bucket", key, 0); err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if _, ok, _ := CachedAt("bucket", key); ok {
+		t.Error("missing manifest was cached")
+	}
+
+	m := New()
+	m.Files["claude-code/app/a.jsonl"] = FileEntry{Size: 10}
+	if err := Save(ctx, store, "bucket", key, m, 0); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if path := CachePath("bucket", key); path != filepath.Join(dir, "bucket", "claude-code", ".manifest.json") {
+		t.Errorf("CachePath() = %q", path)
+	}
+
+	// Saved copy is current: no download
+	got, err := Load(ctx, store, "bucket", key, 0)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if store.downloads != 0 || store.notModified != 1 || got.Files["claude-code/app/a.jsonl"].Size != 10 {
+		t.Errorf("Load() after Save: %d downloads, %d not modified, files %v", store.downloads, store.notModified, got.Files)
+	}
+
+	// Another machine changed it: downloaded and cached again
+	other := New()
+	other.Files["claude-code/app/b.jsonl"] = FileEntry{Size: 20}
+	data, err := json.Marshal(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.data, store.etag = data, `"remote"`
+	for range 2 {
+		if got, err = Load(ctx, store, "bucket", key, 0); err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+	}
+	if store.downloads != 1 || store.notModified != 2 || len(got.Files) != 1 || got.Files["claude-code/app/b.jsonl"].Size != 20 {
+		t.Errorf("Load() after remote change: %d downloads, %d not modified, files %v", store.downloads, store.notModified, got.Files)
+	}
+
+	// A corrupt cache is ignored
+	if err := os.WriteFile(CachePath("bucket", key), []byte("{"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := CachedAt("bucket", key); err == nil {
+		t.Error("CachedAt() of a corrupt cache succeeded")
+	}
+	if got, err = Load(ctx, store, "bucket", key, 0); err != nil || len(got.Files) != 1 || store.downloads != 2 {
+		t.Errorf("Load() with corrupt cache = %v, %v after %d downloads", got, err, store.downloads)
+	}
+
+	// Deleted remotely: the cache goes too
+	store.data = nil
+	if got, err = Load(ctx, store, "bucket", key, 0); err != nil || len(got.Files) != 0 {
+		t.Errorf("Load() of deleted manifest = %v, %v", got, err)
+	}
+	if _, ok, _ := CachedAt("bucket", key); ok {
+		t.Error("cache kept after the manifest was deleted")
+	}
+}
+
+func TestLoadCacheDisabled(t *testing.T) {
+	SetCacheDir("")
+	store := &etagStore{}
+	if err := Save(context.Background(), store, "bucket", "m.json", New(), 0); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, err := Load(context.Background(), store, "bucket", "m.json", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if store.downloads != 2 || store.notModified != 0 {
+		t.Errorf("%d downloads, %d not modified, want 2 unconditional downloads", store.downloads, store.notModified)
+	}
+}
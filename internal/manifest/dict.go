@@ -0,0 +1,91 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/codec"
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/s3errors"
+	"github.com/13rac1/cclogs/internal/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// DictName is the name, next to the manifest, of the zstd dictionary uploads
+// compress with (cclogs compression train-dict).
+const DictName = ".cclogs-dict"
+
+// DictKey returns the S3 key of the dictionary uploads use.
+func DictKey(cfg *types.Config) string {
+	return KeyFor(config.KeyPrefix(cfg), DictName)
+}
+
+// DictIDKey returns the S3 key the dictionary with id is kept under. Every
+// dictionary is kept there for good, so objects compressed with one stay
+// readable after it is replaced.
+func DictIDKey(cfg *types.Config, id uint32) string {
+	return DictKey(cfg) + "." + strconv.FormatUint(uint64(id), 10)
+}
+
+// DictClient is the subset of the S3 API LoadDict needs.
+type DictClient interface {
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+}
+
+// LoadDict downloads the dictionary at key. It returns nil without an error
+// if there is none. The download is bounded by timeout (non-positive
+// disables the deadline).
+func LoadDict(ctx context.Context, client DictClient, bucket, key string, timeout time.Duration) (codec.Dictionary, error) {
+	ctx, cancel := config.WithOperationTimeout(ctx, timeout)
+	defer cancel()
+
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var nsk *s3types.NoSuchKey
+		var nf *s3types.NotFound
+		if errors.As(err, &nsk) || errors.As(err, &nf) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("downloading dictionary %s: %w", key, s3errors.Wrap(err))
+	}
+	defer func() { _ = output.Body.Close() }()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("downloading dictionary %s: %w", key, err)
+	}
+	dict, err := codec.ParseDictionary(data)
+	if err != nil {
+		return nil, fmt.Errorf("dictionary %s: %w", key, err)
+	}
+	return dict, nil
+}
+
+// SaveDict uploads dict under its ID, then as the dictionary uploads use.
+// The uploads are bounded by timeout (non-positive disables the deadline).
+func SaveDict(ctx context.Context, client S3Client, cfg *types.Config, dict codec.Dictionary, timeout time.Duration) error {
+	for _, key := range []string{DictIDKey(cfg, dict.ID()), DictKey(cfg)} {
+		putCtx, cancel := config.WithOperationTimeout(ctx, timeout)
+		_, err := client.PutObject(putCtx, &s3.PutObjectInput{
+			Bucket:      aws.String(cfg.S3.Bucket),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(dict),
+			ContentType: aws.String("application/octet-stream"),
+		})
+		cancel()
+		if err != nil {
+			return fmt.Errorf("uploading dictionary %s: %w", key, s3errors.Wrap(err))
+		}
+	}
+	return nil
+}
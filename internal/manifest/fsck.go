@@ -0,0 +1,216 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Kinds of problems Fsck reports, in the order they are listed.
+const (
+	ProblemUnreadable    = "unreadable"
+	ProblemVersion       = "unsupported version"
+	ProblemOutsidePrefix = "outside prefix"
+	ProblemFutureMtime   = "mtime in the future"
+	ProblemNegativeSize  = "negative size"
+	ProblemMissing       = "missing object"
+)
+
+// ProblemKinds lists every problem kind in report order.
+var ProblemKinds = []string{ProblemUnreadable, ProblemVersion, ProblemOutsidePrefix, ProblemFutureMtime, ProblemNegativeSize, ProblemMissing}
+
+// futureSlack tolerates clock skew between the uploading machines.
+const futureSlack = 24 * time.Hour
+
+// Problem is one inconsistency found by Fsck.
+type Problem struct {
+	Kind   string `json:"kind"`
+	Key    string `json:"key,omitempty"` // Entry the problem concerns; empty for the manifest itself
+	Detail string `json:"detail"`
+}
+
+// FsckReport is the result of checking a manifest.
+type FsckReport struct {
+	Manifest *Manifest // Decoded manifest; nil when it could not be read
+	Problems []Problem
+}
+
+// Fatal reports whether the manifest itself is unusable, as opposed to
+// holding bad entries.
+func (r FsckReport) Fatal() bool {
+	return r.Manifest == nil
+}
+
+// ByKind groups the problems by kind, each group sorted by key.
+func (r FsckReport) ByKind() map[string][]Problem {
+	groups := make(map[string][]Problem)
+	for _, p := range r.Problems {
+		groups[p.Kind] = append(groups[p.Kind], p)
+	}
+	for _, g := range groups {
+		sort.Slice(g, func(i, j int) bool { return g[i].Key < g[j].Key })
+	}
+	return groups
+}
+
+// Fetch downloads the manifest document at key as stored, bypassing the
+// local cache. A missing manifest returns nil data and no error.
+func Fetch(ctx context.Context, client S3Client, bucket, key string, timeout time.Duration) ([]byte, error) {
+	ctx, cancel := config.WithOperationTimeout(ctx, timeout)
+	defer cancel()
+
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		var nsk *s3types.NoSuchKey
+		var nf *s3types.NotFound
+		if errors.As(err, &nsk) || errors.As(err, &nf) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("downloading manifest: %w", err)
+	}
+	defer func() { _ = output.Body.Close() }()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("downloading manifest: %w", err)
+	}
+	return data, nil
+}
+
+// Fsck decodes data and checks every entry: keys under prefix, mtimes no
+// later than now (with a day's slack for clock skew), and non-negative sizes.
+// A document that is not valid JSON or has an unsupported version is
+// reported as such, with no entries checked.
+func Fsck(data []byte, prefix string, now time.Time) FsckReport {
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return FsckReport{Problems: []Problem{{Kind: ProblemUnreadable, Detail: describeJSONError(data, err)}}}
+	}
+	if m.Version < 1 || m.Version > Version {
+		return FsckReport{Problems: []Problem{{Kind: ProblemVersion, Detail: fmt.Sprintf("version %d, this cclogs reads 1 to %d", m.Version, Version)}}}
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]FileEntry)
+	}
+
+	report := FsckReport{Manifest: &m}
+	for key, entry := range m.Files {
+		if prefix != "" && !strings.HasPrefix(key, prefix) {
+			report.Problems = append(report.Problems, Problem{Kind: ProblemOutsidePrefix, Key: key, Detail: "not under " + prefix})
+		}
+		if entry.Mtime.After(now.Add(futureSlack)) {
+			report.Problems = append(report.Problems, Problem{Kind: ProblemFutureMtime, Key: key, Detail: entry.Mtime.UTC().Format(time.RFC3339)})
+		}
+		if entry.Size < 0 || entry.UploadedSize < 0 {
+			report.Problems = append(report.Problems, Problem{Kind: ProblemNegativeSize, Key: key, Detail: fmt.Sprintf("size %d, uploaded_size %d", entry.Size, entry.UploadedSize)})
+		}
+	}
+	return report
+}
+
+// HeadClient is the subset of the S3 API CheckObjects needs.
+type HeadClient interface {
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+}
+
+// headConcurrency bounds the HeadObject requests CheckObjects has in flight.
+const headConcurrency = 8
+
+// CheckObjects sends a HeadObject request for the object of each entry in m
+// and returns a ProblemMissing for each one that does not exist. Other
+// errors abort the check.
+func CheckObjects(ctx context.Context, client HeadClient, bucket string, m *Manifest, timeout time.Duration) ([]Problem, error) {
+	keys := make(chan string)
+	var (
+		mu       sync.Mutex
+		problems []Problem
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	for range headConcurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for key := range keys {
+				object := m.Files[key].ObjectKey(key)
+				headCtx, cancel := config.WithOperationTimeout(ctx, timeout)
+				_, err := client.HeadObject(headCtx, &s3.HeadObjectInput{Bucket: aws.String(bucket), Key: aws.String(object)})
+				cancel()
+
+				mu.Lock()
+				var nsk *s3types.NoSuchKey
+				var nf *s3types.NotFound
+				switch {
+				case err == nil:
+				case errors.As(err, &nsk) || errors.As(err, &nf):
+					problems = append(problems, Problem{Kind: ProblemMissing, Key: key, Detail: "no object at " + object})
+				case firstErr == nil:
+					firstErr = fmt.Errorf("head object %s: %w", object, err)
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+
+	for key := range m.Files {
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed || ctx.Err() != nil {
+			break
+		}
+		keys <- key
+	}
+	close(keys)
+	wg.Wait()
+
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return problems, firstErr
+}
+
+// describeJSONError explains a decoding error of data, locating it.
+func describeJSONError(data []byte, err error) string {
+	if where := jsonErrorLocation(data, err); where != "" {
+		return where + ": " + err.Error()
+	}
+	return err.Error()
+}
+
+// jsonErrorLocation says where in data a decoding error occurred: the line
+// and column of a syntax error, or that the document is empty or cut short.
+// It returns "" for other errors.
+func jsonErrorLocation(data []byte, err error) string {
+	trimmed := bytes.TrimRight(data, " \t\r\n")
+	if len(trimmed) == 0 {
+		return "manifest is empty"
+	}
+	var syntaxErr *json.SyntaxError
+	if !errors.As(err, &syntaxErr) {
+		return ""
+	}
+	if syntaxErr.Offset >= int64(len(trimmed)) {
+		return fmt.Sprintf("manifest is truncated after %d bytes", len(data))
+	}
+	// Offset counts the offending byte, so it ends the prefix before it
+	before := data[:syntaxErr.Offset-1]
+	line := 1 + bytes.Count(before, []byte("\n"))
+	col := len(before) - bytes.LastIndexByte(before, '\n')
+	return fmt.Sprintf("line %d, column %d", line, col)
+}
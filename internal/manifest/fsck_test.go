@@ -0,0 +1,144 @@
+package manifest
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+func TestFsck(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		data       string
+		wantFatal  bool
+		wantKinds  []string
+		wantDetail string
+	}{
+		{
+			name: "consistent",
+			data: `{"version":2,"files":{"claude-code/p/a.jsonl":{"mtime":"2025-06-02T00:00:00Z","size":10}}}`,
+		},
+		{
+			name:       "empty",
+			data:       "\n",
+			wantFatal:  true,
+			wantKinds:  []string{ProblemUnreadable},
+			wantDetail: "manifest is empty",
+		},
+		{
+			name:       "truncated",
+			data:       `{"version":2,"files":{"claude-code/p/a.jsonl":{"size":1`,
+			wantFatal:  true,
+			wantKinds:  []string{ProblemUnreadable},
+			wantDetail: "truncated",
+		},
+		{
+			name:       "syntax error located",
+			data:       "{\n  \"version\": 2,\n  \"files\": {,}\n}",
+			wantFatal:  true,
+			wantKinds:  []string{ProblemUnreadable},
+			wantDetail: "line 3, column 13",
+		},
+		{
+			name:       "unsupported version",
+			data:       `{"version":9,"files":{}}`,
+			wantFatal:  true,
+			wantKinds:  []string{ProblemVersion},
+			wantDetail: "version 9",
+		},
+		{
+			name: "bad entries",
+			data: `{"version":1,"files":{
+				"other/p/a.jsonl":{"size":1},
+				"claude-code/p/b.jsonl":{"mtime":"2030-01-01T00:00:00Z","size":1},
+				"claude-code/p/c.jsonl":{"size":1,"uploaded_size":-5}}}`,
+			wantKinds: []string{ProblemOutsidePrefix, ProblemFutureMtime, ProblemNegativeSize},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := Fsck([]byte(tt.data), "claude-code/", now)
+			if report.Fatal() != tt.wantFatal {
+				t.Errorf("Fatal() = %v, want %v", report.Fatal(), tt.wantFatal)
+			}
+			var kinds []string
+			for _, p := range report.Problems {
+				kinds = append(kinds, p.Kind)
+				if tt.wantDetail != "" && !strings.Contains(p.Detail, tt.wantDetail) {
+					t.Errorf("Detail = %q, want %q", p.Detail, tt.wantDetail)
+				}
+			}
+			slices.Sort(kinds)
+			want := slices.Clone(tt.wantKinds)
+			slices.Sort(want)
+			if !slices.Equal(kinds, want) {
+				t.Errorf("problem kinds = %v, want %v", kinds, want)
+			}
+		})
+	}
+}
+
+// headClient answers HeadObject for the keys in objects and fails for fail.
+type headClient struct {
+	mu      sync.Mutex
+	objects map[string]bool
+	fail    string
+}
+
+func (c *headClient) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	key := aws.ToString(params.Key)
+	switch {
+	case key == c.fail:
+		return nil, errors.New("access denied")
+	case c.objects[key]:
+		return &s3.HeadObjectOutput{}, nil
+	}
+	return nil, &s3types.NotFound{}
+}
+
+func TestCheckObjects(t *testing.T) {
+	m := &Manifest{Version: Version, Files: map[string]FileEntry{
+		"claude-code/p/a.jsonl": {Size: 1},
+		"claude-code/p/b.jsonl": {Size: 1},
+		"claude-code/p/c.jsonl": {Size: 1, Object: "claude-code/2025/06/01/p/c.jsonl"},
+		"claude-code/p/d.jsonl": {Size: 1, Object: "claude-code/2025/06/02/p/d.jsonl"},
+	}}
+	client := &headClient{objects: map[string]bool{
+		"claude-code/p/a.jsonl":            true,
+		"claude-code/2025/06/01/p/c.jsonl": true,
+	}}
+
+	problems, err := CheckObjects(context.Background(), client, "bucket", m, 0)
+	if err != nil {
+		t.Fatalf("CheckObjects() error = %v", err)
+	}
+	var missing []string
+	for _, p := range problems {
+		if p.Kind != ProblemMissing {
+			t.Errorf("Kind = %q, want %q", p.Kind, ProblemMissing)
+		}
+		missing = append(missing, p.Key)
+	}
+	slices.Sort(missing)
+	if want := []string{"claude-code/p/b.jsonl", "claude-code/p/d.jsonl"}; !slices.Equal(missing, want) {
+		t.Errorf("missing = %v, want %v", missing, want)
+	}
+
+	client.fail = "claude-code/p/a.jsonl"
+	if _, err := CheckObjects(context.Background(), client, "bucket", m, 0); err == nil || !strings.Contains(err.Error(), "access denied") {
+		t.Errorf("CheckObjects() error = %v, want access denied", err)
+	}
+}
@@ -0,0 +1,214 @@
+package manifest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/s3errors"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// HistoryDir is the directory, next to the manifest, that holds the copies
+// Save makes of the manifest before overwriting it.
+const HistoryDir = ".manifest-history"
+
+// historyTimeFormat names backups so they sort in the order they were made.
+const historyTimeFormat = "20060102T150405.000Z"
+
+// HistoryClient is the subset of the S3 API backups need. Save only makes
+// backups with a client that implements it.
+type HistoryClient interface {
+	CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error)
+}
+
+var (
+	historyMu   sync.Mutex
+	historyKeep int
+	historyWarn io.Writer = io.Discard
+)
+
+// SetHistory makes Save copy the manifest it is about to overwrite into
+// HistoryDir, keeping the keep most recent copies. keep of 0 or less disables
+// backups. A backup that fails does not stop the save; it is reported to warn.
+func SetHistory(keep int, warn io.Writer) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	historyKeep = keep
+	if warn == nil {
+		warn = io.Discard
+	}
+	historyWarn = warn
+}
+
+// historySettings returns the values set by SetHistory.
+func historySettings() (int, io.Writer) {
+	historyMu.Lock()
+	defer historyMu.Unlock()
+	return historyKeep, historyWarn
+}
+
+// HistoryPrefix returns the key prefix of the backups of the manifest at key.
+func HistoryPrefix(key string) string {
+	dir := path.Dir(key)
+	if dir == "." {
+		return HistoryDir + "/"
+	}
+	return dir + "/" + HistoryDir + "/"
+}
+
+// historyKey returns the key of a backup of the manifest at key made at t:
+// the time followed by the manifest's name, so backups keep its extension.
+func historyKey(key string, t time.Time) string {
+	return HistoryPrefix(key) + t.UTC().Format(historyTimeFormat) + "." + strings.TrimLeft(path.Base(key), ".")
+}
+
+// Backup is one stored copy of the manifest.
+type Backup struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Backups lists the backups of the manifest at key, newest first.
+func Backups(ctx context.Context, client HistoryClient, bucket, key string, timeout time.Duration) ([]Backup, error) {
+	var backups []Backup
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(HistoryPrefix(key)),
+	}
+	for {
+		listCtx, cancel := config.WithOperationTimeout(ctx, timeout)
+		out, err := client.ListObjectsV2(listCtx, input)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("listing manifest backups: %w", err)
+		}
+		for _, obj := range out.Contents {
+			backups = append(backups, Backup{
+				Key:          aws.ToString(obj.Key),
+				Size:         aws.ToInt64(obj.Size),
+				LastModified: aws.ToTime(obj.LastModified),
+			})
+		}
+		if !aws.ToBool(out.IsTruncated) {
+			break
+		}
+		input.ContinuationToken = out.NextContinuationToken
+	}
+
+	sort.Slice(backups, func(i, j int) bool { return backups[i].Key > backups[j].Key })
+	return backups, nil
+}
+
+// FindBackup returns the backup ref names: its full key, its name within
+// HistoryDir, or a prefix of that name such as the timestamp. A prefix must
+// match exactly one backup.
+func FindBackup(backups []Backup, ref string) (Backup, error) {
+	var matches []Backup
+	for _, b := range backups {
+		name := path.Base(b.Key)
+		if b.Key == ref || name == ref {
+			return b, nil
+		}
+		if strings.HasPrefix(name, ref) {
+			matches = append(matches, b)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return Backup{}, fmt.Errorf("no manifest backup matches %q", ref)
+	case 1:
+		return matches[0], nil
+	}
+	return Backup{}, fmt.Errorf("%q matches %d manifest backups; give more of the name", ref, len(matches))
+}
+
+// LockedError lists objects that could not be deleted because Object Lock
+// protects them, e.g. manifest backups under a bucket default retention.
+type LockedError struct {
+	Keys []string
+}
+
+// Error implements error.
+func (e *LockedError) Error() string {
+	return fmt.Sprintf("%d objects are protected by Object Lock and cannot be deleted yet: %s",
+		len(e.Keys), strings.Join(e.Keys, ", "))
+}
+
+// backup copies the manifest at key into HistoryDir, then deletes all but
+// the keep newest backups. A manifest that does not exist yet needs no
+// backup. Backups Object Lock protects are skipped and returned in a
+// *LockedError once the others are deleted.
+func backup(ctx context.Context, client HistoryClient, bucket, key string, keep int, now time.Time, timeout time.Duration) error {
+	copyCtx, cancel := config.WithOperationTimeout(ctx, timeout)
+	_, err := client.CopyObject(copyCtx, &s3.CopyObjectInput{
+		Bucket:     aws.String(bucket),
+		Key:        aws.String(historyKey(key, now)),
+		CopySource: aws.String((&url.URL{Path: bucket + "/" + key}).EscapedPath()),
+	})
+	cancel()
+	if err != nil {
+		var nsk *types.NoSuchKey
+		var nf *types.NotFound
+		if errors.As(err, &nsk) || errors.As(err, &nf) {
+			return nil
+		}
+		return fmt.Errorf("copying manifest: %w", err)
+	}
+
+	backups, err := Backups(ctx, client, bucket, key, timeout)
+	if err != nil {
+		return err
+	}
+	var locked []string
+	for _, b := range backups[min(keep, len(backups)):] {
+		deleteCtx, cancel := config.WithOperationTimeout(ctx, timeout)
+		_, err := client.DeleteObject(deleteCtx, &s3.DeleteObjectInput{Bucket: aws.String(bucket), Key: aws.String(b.Key)})
+		cancel()
+		if s3errors.IsObjectLocked(err) {
+			locked = append(locked, b.Key)
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("pruning manifest backup %s: %w", b.Key, err)
+		}
+	}
+	if len(locked) > 0 {
+		return &LockedError{Keys: locked}
+	}
+	return nil
+}
+
+// Restore replaces the manifest at key with the backup at backupKey, after
+// checking that the backup is a manifest this version can read. The manifest
+// being replaced is backed up first, like any other save.
+func Restore(ctx context.Context, client S3Client, bucket, key, backupKey string, timeout time.Duration) (*Manifest, error) {
+	data, err := Fetch(ctx, client, bucket, backupKey, timeout)
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("no backup at %s", backupKey)
+	}
+	m, err := parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("backup %s: %w", backupKey, err)
+	}
+	if err := Save(ctx, client, bucket, key, m, timeout); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
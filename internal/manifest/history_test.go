@@ -0,0 +1,237 @@
+package manifest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+)
+
+// storeClient keeps objects in memory and implements HistoryClient. Deleting
+// a key in locked fails as S3 does for objects under Object Lock retention.
+type storeClient struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	locked  map[string]bool
+}
+
+func (c *storeClient) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	data, ok := c.objects[aws.ToString(params.Key)]
+	if !ok {
+		return nil, &s3types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (c *storeClient) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.objects[aws.ToString(params.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (c *storeClient) CopyObject(ctx context.Context, params *s3.CopyObjectInput, optFns ...func(*s3.Options)) (*s3.CopyObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, src, _ := strings.Cut(aws.ToString(params.CopySource), "/")
+	data, ok := c.objects[src]
+	if !ok {
+		return nil, &s3types.NoSuchKey{}
+	}
+	c.objects[aws.ToString(params.Key)] = data
+	return &s3.CopyObjectOutput{}, nil
+}
+
+func (c *storeClient) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := &s3.ListObjectsV2Output{}
+	for k, data := range c.objects {
+		if strings.HasPrefix(k, aws.ToString(params.Prefix)) {
+			out.Contents = append(out.Contents, s3types.Object{Key: aws.String(k), Size: aws.Int64(int64(len(data)))})
+		}
+	}
+	sort.Slice(out.Contents, func(i, j int) bool { return *out.Contents[i].Key < *out.Contents[j].Key })
+	return out, nil
+}
+
+func (c *storeClient) DeleteObject(ctx context.Context, params *s3.DeleteObjectInput, optFns ...func(*s3.Options)) (*s3.DeleteObjectOutput, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.locked[aws.ToString(params.Key)] {
+		return nil, &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied because object protected by object lock."}
+	}
+	delete(c.objects, aws.ToString(params.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+func TestHistoryPrefix(t *testing.T) {
+	if got := HistoryPrefix("claude-code/.manifest.json"); got != "claude-code/.manifest-history/" {
+		t.Errorf("HistoryPrefix() = %q", got)
+	}
+	if got := HistoryPrefix(".manifest.json"); got != ".manifest-history/" {
+		t.Errorf("HistoryPrefix() = %q", got)
+	}
+	at := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	if got := historyKey("claude-code/.manifest.json", at); got != "claude-code/.manifest-history/20250601T120000.000Z.manifest.json" {
+		t.Errorf("historyKey() = %q", got)
+	}
+}
+
+func TestBackupPrunes(t *testing.T) {
+	ctx := context.Background()
+	key := "claude-code/.manifest.json"
+	client := &storeClient{objects: map[string][]byte{}}
+
+	// No manifest yet: nothing to back up
+	if err := backup(ctx, client, "bucket", key, 2, time.Now(), 0); err != nil {
+		t.Fatalf("backup() error = %v", err)
+	}
+	if len(client.objects) != 0 {
+		t.Fatalf("objects = %d, want none", len(client.objects))
+	}
+
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	for i := range 4 {
+		client.objects[key] = []byte{byte('a' + i)}
+		if err := backup(ctx, client, "bucket", key, 2, start.Add(time.Duration(i)*time.Minute), 0); err != nil {
+			t.Fatalf("backup() error = %v", err)
+		}
+	}
+
+	backups, err := Backups(ctx, client, "bucket", key, 0)
+	if err != nil {
+		t.Fatalf("Backups() error = %v", err)
+	}
+	var names []string
+	for _, b := range backups {
+		names = append(names, strings.TrimPrefix(b.Key, HistoryPrefix(key)))
+	}
+	want := []string{"20250601T120300.000Z.manifest.json", "20250601T120200.000Z.manifest.json"}
+	if !slices.Equal(names, want) {
+		t.Errorf("backups = %v, want %v", names, want)
+	}
+	if got := string(client.objects[backups[0].Key]); got != "d" {
+		t.Errorf("newest backup = %q, want d", got)
+	}
+}
+
+func TestBackupSkipsLocked(t *testing.T) {
+	ctx := context.Background()
+	key := "claude-code/.manifest.json"
+	start := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	oldest := historyKey(key, start)
+	client := &storeClient{objects: map[string][]byte{}, locked: map[string]bool{oldest: true}}
+
+	for i := range 4 {
+		client.objects[key] = []byte{byte('a' + i)}
+		err := backup(ctx, client, "bucket", key, 1, start.Add(time.Duration(i)*time.Minute), 0)
+		if i < 2 {
+			continue
+		}
+		var locked *LockedError
+		if !errors.As(err, &locked) || !slices.Equal(locked.Keys, []string{oldest}) {
+			t.Fatalf("backup() error = %v, want LockedError for %s", err, oldest)
+		}
+	}
+
+	backups, err := Backups(ctx, client, "bucket", key, 0)
+	if err != nil {
+		t.Fatalf("Backups() error = %v", err)
+	}
+	// The locked backup stays; the unlocked ones beyond keep are still pruned
+	if len(backups) != 2 || backups[0].Key != historyKey(key, start.Add(3*time.Minute)) || backups[1].Key != oldest {
+		t.Errorf("backups = %v, want the newest and the locked oldest", backups)
+	}
+}
+
+func TestFindBackup(t *testing.T) {
+	backups := []Backup{
+		{Key: "p/.manifest-history/20250602T080000.000Z.manifest.json"},
+		{Key: "p/.manifest-history/20250601T130000.000Z.manifest.json"},
+		{Key: "p/.manifest-history/20250601T120000.000Z.manifest.json"},
+	}
+
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr string
+	}{
+		{ref: "p/.manifest-history/20250601T120000.000Z.manifest.json", want: backups[2].Key},
+		{ref: "20250601T130000.000Z.manifest.json", want: backups[1].Key},
+		{ref: "20250602", want: backups[0].Key},
+		{ref: "20250601", wantErr: "matches 2"},
+		{ref: "2024", wantErr: "no manifest backup"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := FindBackup(backups, tt.ref)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("FindBackup() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindBackup() error = %v", err)
+			}
+			if got.Key != tt.want {
+				t.Errorf("FindBackup() = %q, want %q", got.Key, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveBacksUpAndRestore(t *testing.T) {
+	SetHistory(5, io.Discard)
+	defer SetHistory(0, nil)
+
+	ctx := context.Background()
+	key := "claude-code/.manifest.json"
+	good := `{"version":2,"files":{"claude-code/p/a.jsonl":{"size":10}}}`
+	client := &storeClient{objects: map[string][]byte{key: []byte(good)}}
+
+	// A bad save replaces the manifest, but the good one is kept
+	if err := Save(ctx, client, "bucket", key, &Manifest{Files: map[string]FileEntry{}}, 0); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	backups, err := Backups(ctx, client, "bucket", key, 0)
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("Backups() = %v, %v; want one backup", backups, err)
+	}
+
+	m, err := Restore(ctx, client, "bucket", key, backups[0].Key, 0)
+	if err != nil {
+		t.Fatalf("Restore() error = %v", err)
+	}
+	if _, ok := m.Files["claude-code/p/a.jsonl"]; !ok || len(m.Files) != 1 {
+		t.Errorf("restored files = %v", m.Files)
+	}
+	restored, err := Load(ctx, client, "bucket", key, 0)
+	if err != nil || len(restored.Files) != 1 {
+		t.Errorf("Load() after restore = %v, %v", restored, err)
+	}
+
+	// A backup that is not a manifest is refused
+	client.objects[HistoryPrefix(key)+"bad.manifest.json"] = []byte("{")
+	if _, err := Restore(ctx, client, "bucket", key, HistoryPrefix(key)+"bad.manifest.json", 0); err == nil {
+		t.Error("Restore() of a truncated backup succeeded")
+	}
+}
@@ -4,43 +4,249 @@
 package manifest
 
 import (
+	"slices"
 	"strings"
 	"time"
+
+	"github.com/13rac1/cclogs/internal/codec"
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/types"
 )
 
 // Manifest tracks uploaded file metadata to enable efficient deduplication.
 // It records source file modification times, not uploaded content size.
 type Manifest struct {
-	Version int                  `json:"version"`
-	Files   map[string]FileEntry `json:"files"`
+	Version    int                  `json:"version"`
+	LastUpload time.Time            `json:"last_upload,omitzero"` // When an upload last saved the manifest (UTC)
+	Archive    Archive              `json:"archive,omitzero"`     // Name and machines of the archive
+	Files      map[string]FileEntry `json:"files"`
 }
 
 // FileEntry records metadata about an uploaded file.
 type FileEntry struct {
-	Mtime time.Time `json:"mtime"` // Source file modification time (UTC)
-	Size  int64     `json:"size"`  // Source file size (for reference only)
+	Mtime        time.Time        `json:"mtime"`                   // Source file modification time (UTC)
+	Size         int64            `json:"size"`                    // Source file size (for reference only)
+	UploadedSize int64            `json:"uploaded_size,omitempty"` // Size of the uploaded (redacted) object
+	SHA256       string           `json:"sha256,omitempty"`        // Hex SHA-256 of the uploaded object content
+	SourceSHA256 string           `json:"source_sha256,omitempty"` // Hex SHA-256 of the local file content (before redaction)
+	Project      string           `json:"project,omitempty"`       // Project name (keys from s3.key_template may not encode it)
+	Codec        string           `json:"codec,omitempty"`         // Compression codec of the object ("gzip", "zstd", "none"; empty for older entries)
+	DictID       uint32           `json:"dict_id,omitempty"`       // ID of the zstd dictionary the object was compressed with (0 for none)
+	Lines        int64            `json:"lines,omitempty"`         // Lines scanned by the redactor
+	Redactions   map[string]int64 `json:"redactions,omitempty"`    // Redaction matches per pattern
+	Machine      string           `json:"machine,omitempty"`       // Machine ID of the uploader (local.machine_id)
+	VerifiedAt   time.Time        `json:"verified_at,omitzero"`    // When verify --deep last matched the object's hash (UTC)
+	Object       string           `json:"object,omitempty"`        // Key of the object when it differs from the manifest key (--date-partition)
+	ETag         string           `json:"etag,omitempty"`          // ETag S3 returned for the upload (version 2)
+	Host         string           `json:"host,omitempty"`          // Hostname of the uploading machine (version 2)
+	UploadedAt   time.Time        `json:"uploaded_at,omitzero"`    // When the object was uploaded (UTC, version 2)
+}
+
+// ObjectKey returns the key of the object recorded under key: the entry's
+// Object, or key itself.
+func (e FileEntry) ObjectKey(key string) string {
+	if e.Object != "" {
+		return e.Object
+	}
+	return key
+}
+
+// Tags returns the redaction patterns that matched in the file, sorted. It is
+// empty for files uploaded without redaction.
+func (e FileEntry) Tags() []string {
+	tags := make([]string, 0, len(e.Redactions))
+	for tag, n := range e.Redactions {
+		if n > 0 {
+			tags = append(tags, tag)
+		}
+	}
+	slices.Sort(tags)
+	return tags
+}
+
+// Version is the manifest format written by Save. Version 2 added the
+// etag, host, and uploaded_at entry fields; version 1 manifests load with
+// them unset.
+const Version = 2
+
+// DefaultName is the manifest's file name when s3.manifest_key is unset.
+const DefaultName = ".manifest.json"
+
+// KeyFor returns the S3 key of the manifest named name stored under prefix.
+// An empty name means DefaultName.
+func KeyFor(prefix, name string) string {
+	if name == "" {
+		name = DefaultName
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	return prefix + name
 }
 
-// New creates an empty manifest with version 1.
+// ConfigKey returns the S3 key of this machine's manifest: s3.manifest_key
+// under the key prefix.
+func ConfigKey(cfg *types.Config) string {
+	return KeyFor(config.KeyPrefix(cfg), cfg.S3.ManifestKey)
+}
+
+// New creates an empty manifest of the current Version.
 func New() *Manifest {
 	return &Manifest{
-		Version: 1,
+		Version: Version,
 		Files:   make(map[string]FileEntry),
 	}
 }
 
 // CountByProject groups manifest entries by project and returns counts.
-// Project is extracted from S3 key: prefix/project/file.jsonl → project
+// See Project for how each entry's project is determined.
 func (m *Manifest) CountByProject(prefix string) map[string]int {
 	counts := make(map[string]int)
 	for key := range m.Files {
-		// Strip prefix, extract first path component as project
-		rel := strings.TrimPrefix(key, prefix)
-		rel = strings.TrimPrefix(rel, "/")
-		parts := strings.SplitN(rel, "/", 2)
-		if len(parts) > 0 && parts[0] != "" {
-			counts[parts[0]]++
+		if project := m.Project(key, prefix); project != "" {
+			counts[project]++
 		}
 	}
 	return counts
 }
+
+// Usage totals the stored size of archived objects.
+type Usage struct {
+	Files       int       `json:"files"`
+	Bytes       int64     `json:"bytes"`
+	Approximate bool      `json:"approximate,omitempty"` // Some entries lacked uploaded_size; their source size was counted
+	LastUpload  time.Time `json:"last_upload,omitzero"`  // Latest uploaded_at; zero if no entry recorded one
+}
+
+// Add adds the totals of o to u, keeping the later LastUpload.
+func (u *Usage) Add(o Usage) {
+	u.Files += o.Files
+	u.Bytes += o.Bytes
+	u.Approximate = u.Approximate || o.Approximate
+	if o.LastUpload.After(u.LastUpload) {
+		u.LastUpload = o.LastUpload
+	}
+}
+
+// UsageByProject totals the stored size of entries by project. Entries
+// written before uploaded_size was recorded count their source size, and
+// mark the project's total approximate. LastUpload is the project's latest
+// uploaded_at.
+func (m *Manifest) UsageByProject(prefix string) map[string]Usage {
+	usage := make(map[string]Usage)
+	for key, entry := range m.Files {
+		project := m.Project(key, prefix)
+		if project == "" {
+			continue
+		}
+		u := usage[project]
+		u.Files++
+		if entry.UploadedSize > 0 || entry.Size == 0 {
+			u.Bytes += entry.UploadedSize
+		} else {
+			u.Bytes += entry.Size
+			u.Approximate = true
+		}
+		if entry.UploadedAt.After(u.LastUpload) {
+			u.LastUpload = entry.UploadedAt
+		}
+		usage[project] = u
+	}
+	return usage
+}
+
+// ProjectSummary rolls up the entries of one project.
+type ProjectSummary struct {
+	Project     string    `json:"project"`
+	SourceBytes int64     `json:"source_bytes"` // Total size of the local files
+	Newest      time.Time `json:"newest"`       // Latest source mtime
+	Machines    []string  `json:"machines,omitempty"`
+	Usage
+}
+
+// Summarize rolls up the entries under prefix by project, sorted by name.
+// Stored bytes are counted as in UsageByProject.
+func (m *Manifest) Summarize(prefix string) []ProjectSummary {
+	usage := m.UsageByProject(prefix)
+	byProject := make(map[string]*ProjectSummary, len(usage))
+	for key, entry := range m.Files {
+		project := m.Project(key, prefix)
+		if project == "" {
+			continue
+		}
+		s := byProject[project]
+		if s == nil {
+			s = &ProjectSummary{Project: project, Usage: usage[project]}
+			byProject[project] = s
+		}
+		s.SourceBytes += entry.Size
+		if entry.Mtime.After(s.Newest) {
+			s.Newest = entry.Mtime
+		}
+		if entry.Machine != "" && !slices.Contains(s.Machines, entry.Machine) {
+			s.Machines = append(s.Machines, entry.Machine)
+		}
+	}
+
+	summaries := make([]ProjectSummary, 0, len(byProject))
+	for _, s := range byProject {
+		slices.Sort(s.Machines)
+		summaries = append(summaries, *s)
+	}
+	slices.SortFunc(summaries, func(a, b ProjectSummary) int { return strings.Compare(a.Project, b.Project) })
+	return summaries
+}
+
+// VerifiedSince counts the entries that belong to a project under prefix, and
+// how many of them verify --deep matched at or after since.
+func (m *Manifest) VerifiedSince(prefix string, since time.Time) (verified, total int) {
+	for key, entry := range m.Files {
+		if m.Project(key, prefix) == "" {
+			continue
+		}
+		total++
+		if !entry.VerifiedAt.IsZero() && !entry.VerifiedAt.Before(since) {
+			verified++
+		}
+	}
+	return verified, total
+}
+
+// FindTags returns the keys of the entries in which any of the redaction
+// patterns tags matched, sorted. Tags are compared case-insensitively.
+func (m *Manifest) FindTags(tags []string) []string {
+	var keys []string
+	for key, entry := range m.Files {
+		for tag, n := range entry.Redactions {
+			if n > 0 && slices.ContainsFunc(tags, func(t string) bool { return strings.EqualFold(t, tag) }) {
+				keys = append(keys, key)
+				break
+			}
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// Project returns the project of the entry at key. Entries record their project
+// explicitly; older entries fall back to parsing the key: prefix/project/file.jsonl → project.
+// They were written before local.project_depth, so the project is one segment.
+func (m *Manifest) Project(key, prefix string) string {
+	if entry, ok := m.Files[key]; ok && entry.Project != "" {
+		return entry.Project
+	}
+	return ProjectOf(key, prefix, 1)
+}
+
+// ProjectOf extracts the project name, projectDepth segments long, from an
+// S3 key under prefix laid out as prefix/project/path, with or without a
+// compression suffix or date partition. Returns an empty string if the key
+// has no project component.
+func ProjectOf(key, prefix string, projectDepth int) string {
+	_, name := codec.FromKey(key)
+	fields, ok := config.ParseKey(config.DefaultKeyTemplate, prefix, name, projectDepth)
+	if !ok {
+		return ""
+	}
+	return fields.Project
+}
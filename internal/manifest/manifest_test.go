@@ -6,18 +6,24 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
+	"reflect"
+	"slices"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/13rac1/cclogs/internal/s3errors"
+	"github.com/13rac1/cclogs/internal/types"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
 func TestNew(t *testing.T) {
 	m := New()
 
-	if m.Version != 1 {
-		t.Errorf("Version = %d, want 1", m.Version)
+	if m.Version != Version {
+		t.Errorf("Version = %d, want %d", m.Version, Version)
 	}
 
 	if m.Files == nil {
@@ -31,11 +37,13 @@ func TestNew(t *testing.T) {
 
 func TestManifestJSONRoundtrip(t *testing.T) {
 	original := &Manifest{
-		Version: 1,
+		Version:    Version,
+		LastUpload: time.Date(2025, 1, 3, 9, 0, 0, 0, time.UTC),
 		Files: map[string]FileEntry{
 			"project-a/session.jsonl": {
-				Mtime: time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
-				Size:  12345,
+				Mtime:      time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC),
+				Size:       12345,
+				UploadedAt: time.Date(2025, 1, 3, 9, 0, 0, 0, time.UTC),
 			},
 			"project-b/logs/2025-01.jsonl": {
 				Mtime: time.Date(2025, 1, 2, 8, 30, 0, 0, time.UTC),
@@ -57,6 +65,9 @@ func TestManifestJSONRoundtrip(t *testing.T) {
 	if parsed.Version != original.Version {
 		t.Errorf("Version = %d, want %d", parsed.Version, original.Version)
 	}
+	if !parsed.LastUpload.Equal(original.LastUpload) {
+		t.Errorf("LastUpload = %v, want %v", parsed.LastUpload, original.LastUpload)
+	}
 
 	if len(parsed.Files) != len(original.Files) {
 		t.Errorf("Files count = %d, want %d", len(parsed.Files), len(original.Files))
@@ -74,6 +85,9 @@ func TestManifestJSONRoundtrip(t *testing.T) {
 		if got.Size != want.Size {
 			t.Errorf("File %s: Size = %d, want %d", key, got.Size, want.Size)
 		}
+		if !got.UploadedAt.Equal(want.UploadedAt) {
+			t.Errorf("File %s: UploadedAt = %v, want %v", key, got.UploadedAt, want.UploadedAt)
+		}
 	}
 }
 
@@ -125,16 +139,16 @@ func (m *mockS3Client) PutObject(ctx context.Context, params *s3.PutObjectInput,
 
 func TestLoad_ManifestDoesNotExist(t *testing.T) {
 	mock := &mockS3Client{
-		getObjectErr: &types.NoSuchKey{},
+		getObjectErr: &s3types.NoSuchKey{},
 	}
 
-	m, err := Load(context.Background(), mock, "bucket", "key")
+	m, err := Load(context.Background(), mock, "bucket", "key", 0)
 	if err != nil {
 		t.Fatalf("Load failed for missing manifest: %v", err)
 	}
 
-	if m.Version != 1 {
-		t.Errorf("Version = %d, want 1", m.Version)
+	if m.Version != Version {
+		t.Errorf("Version = %d, want %d", m.Version, Version)
 	}
 
 	if len(m.Files) != 0 {
@@ -144,16 +158,16 @@ func TestLoad_ManifestDoesNotExist(t *testing.T) {
 
 func TestLoad_ManifestDoesNotExist_NotFound(t *testing.T) {
 	mock := &mockS3Client{
-		getObjectErr: &types.NotFound{},
+		getObjectErr: &s3types.NotFound{},
 	}
 
-	m, err := Load(context.Background(), mock, "bucket", "key")
+	m, err := Load(context.Background(), mock, "bucket", "key", 0)
 	if err != nil {
 		t.Fatalf("Load failed for missing manifest (NotFound): %v", err)
 	}
 
-	if m.Version != 1 {
-		t.Errorf("Version = %d, want 1", m.Version)
+	if m.Version != Version {
+		t.Errorf("Version = %d, want %d", m.Version, Version)
 	}
 
 	if len(m.Files) != 0 {
@@ -178,13 +192,13 @@ func TestLoad_Success(t *testing.T) {
 		},
 	}
 
-	m, err := Load(context.Background(), mock, "bucket", "key")
+	m, err := Load(context.Background(), mock, "bucket", "key", 0)
 	if err != nil {
 		t.Fatalf("Load failed: %v", err)
 	}
 
-	if m.Version != 1 {
-		t.Errorf("Version = %d, want 1", m.Version)
+	if m.Version != Version {
+		t.Errorf("Version = %d, want %d", m.Version, Version)
 	}
 
 	if len(m.Files) != 1 {
@@ -213,10 +227,49 @@ func TestLoad_CorruptJSON(t *testing.T) {
 		},
 	}
 
-	_, err := Load(context.Background(), mock, "bucket", "key")
+	_, err := Load(context.Background(), mock, "bucket", "key", 0)
 	if err == nil {
 		t.Fatal("Expected error for corrupt JSON, got nil")
 	}
+	if !errors.Is(err, ErrCorrupt) {
+		t.Errorf("Load() error = %v, want ErrCorrupt", err)
+	}
+}
+
+func TestParse_Corrupt(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		wantCorrupt bool
+	}{
+		{name: "truncated", data: `{"version": 2, "files": {`, wantCorrupt: true},
+		{name: "wrong type", data: `{"version": "two"}`, wantCorrupt: true},
+		{name: "no version", data: `{"files": {}}`, wantCorrupt: true},
+		{name: "newer version", data: `{"version": 999, "files": {}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parse([]byte(tt.data))
+			if err == nil {
+				t.Fatal("parse() error = nil")
+			}
+			if errors.Is(err, ErrCorrupt) != tt.wantCorrupt {
+				t.Errorf("parse() error = %v, ErrCorrupt match = %v, want %v", err, !tt.wantCorrupt, tt.wantCorrupt)
+			}
+		})
+	}
+}
+
+func TestLoadSave_AccessDenied(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied"}
+	mock := &mockS3Client{getObjectErr: denied, putObjectErr: denied}
+
+	if _, err := Load(context.Background(), mock, "bucket", "key", 0); !errors.Is(err, s3errors.ErrAccessDenied) {
+		t.Errorf("Load() error = %v, want ErrAccessDenied", err)
+	}
+	if err := Save(context.Background(), mock, "bucket", "key", New(), 0); !errors.Is(err, s3errors.ErrAccessDenied) {
+		t.Errorf("Save() error = %v, want ErrAccessDenied", err)
+	}
 }
 
 func TestLoad_UnsupportedVersion(t *testing.T) {
@@ -231,18 +284,107 @@ func TestLoad_UnsupportedVersion(t *testing.T) {
 		},
 	}
 
-	_, err := Load(context.Background(), mock, "bucket", "key")
+	_, err := Load(context.Background(), mock, "bucket", "key", 0)
 	if err == nil {
 		t.Fatal("Expected error for unsupported version, got nil")
 	}
 }
 
+func TestLoadVersions(t *testing.T) {
+	uploaded := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name    string
+		json    string
+		want    FileEntry
+		wantErr string
+	}{
+		{
+			name: "v1 migrates with new fields unset",
+			json: `{"version":1,"files":{"a.jsonl":{"mtime":"2025-01-01T00:00:00Z","size":10,"uploaded_size":8}}}`,
+			want: FileEntry{Mtime: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Size: 10, UploadedSize: 8},
+		},
+		{
+			name: "v2 fields read",
+			json: `{"version":2,"files":{"a.jsonl":{"mtime":"2025-01-01T00:00:00Z","size":10,` +
+				`"etag":"\"abc\"","host":"laptop","uploaded_at":"2025-03-01T12:00:00Z"}}}`,
+			want: FileEntry{Mtime: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), Size: 10, ETag: `"abc"`, Host: "laptop", UploadedAt: uploaded},
+		},
+		{
+			name:    "newer version rejected",
+			json:    `{"version":3,"files":{}}`,
+			wantErr: "unsupported manifest version: 3",
+		},
+		{
+			name:    "missing version rejected",
+			json:    `{"files":{}}`,
+			wantErr: "invalid manifest version: 0",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mock := &mockS3Client{getObjectResp: &s3.GetObjectOutput{Body: io.NopCloser(strings.NewReader(tt.json))}}
+			m, err := Load(context.Background(), mock, "bucket", "key", 0)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("Load() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Load() error = %v", err)
+			}
+			if m.Version != Version {
+				t.Errorf("Version = %d, want %d", m.Version, Version)
+			}
+			if got := m.Files["a.jsonl"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("entry = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSaveRoundTripV2(t *testing.T) {
+	store := &etagStore{data: []byte(`{"version":1,"files":{"old.jsonl":{"mtime":"2025-01-01T00:00:00Z","size":5}}}`), etag: `"v0"`}
+	ctx := context.Background()
+
+	m, err := Load(ctx, store, "bucket", "key", 0)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	entry := FileEntry{
+		Mtime:        time.Date(2025, 3, 1, 11, 0, 0, 0, time.UTC),
+		Size:         100,
+		UploadedSize: 40,
+		SHA256:       "aa",
+		SourceSHA256: "bb",
+		ETag:         `"e1"`,
+		Host:         "laptop",
+		UploadedAt:   time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+	m.Files["new.jsonl"] = entry
+	if err := Save(ctx, store, "bucket", "key", m, 0); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+	if !strings.Contains(string(store.data), `"version": 2`) {
+		t.Errorf("saved manifest is not version 2:\n%s", store.data)
+	}
+
+	reloaded, err := Load(ctx, store, "bucket", "key", 0)
+	if err != nil {
+		t.Fatalf("Load() error = %v", err)
+	}
+	if !reflect.DeepEqual(reloaded.Files, m.Files) {
+		t.Errorf("round trip = %+v, want %+v", reloaded.Files, m.Files)
+	}
+}
+
 func TestLoad_NetworkError(t *testing.T) {
 	mock := &mockS3Client{
 		getObjectErr: errors.New("network timeout"),
 	}
 
-	_, err := Load(context.Background(), mock, "bucket", "key")
+	_, err := Load(context.Background(), mock, "bucket", "key", 0)
 	if err == nil {
 		t.Fatal("Expected error for network failure, got nil")
 	}
@@ -260,7 +402,7 @@ func TestLoad_NilFilesMap(t *testing.T) {
 		},
 	}
 
-	m, err := Load(context.Background(), mock, "bucket", "key")
+	m, err := Load(context.Background(), mock, "bucket", "key", 0)
 	if err != nil {
 		t.Fatalf("Load failed: %v", err)
 	}
@@ -289,7 +431,7 @@ func TestSave_Success(t *testing.T) {
 		putObjectResp: &s3.PutObjectOutput{},
 	}
 
-	err := Save(context.Background(), mock, "bucket", "key", m)
+	err := Save(context.Background(), mock, "bucket", "key", m, 0)
 	if err != nil {
 		t.Fatalf("Save failed: %v", err)
 	}
@@ -302,7 +444,7 @@ func TestSave_NetworkError(t *testing.T) {
 		putObjectErr: errors.New("network timeout"),
 	}
 
-	err := Save(context.Background(), mock, "bucket", "key", m)
+	err := Save(context.Background(), mock, "bucket", "key", m, 0)
 	if err == nil {
 		t.Fatal("Expected error for network failure, got nil")
 	}
@@ -358,6 +500,16 @@ func TestCountByProject(t *testing.T) {
 			prefix: "",
 			want:   map[string]int{"project-a": 1, "project-b": 1},
 		},
+		{
+			name: "explicit project from templated keys",
+			files: map[string]FileEntry{
+				"claude/2025/01/project-a/s1.jsonl": {Project: "project-a"},
+				"claude/2025/02/project-a/s2.jsonl": {Project: "project-a"},
+				"claude/2025/02/project-b/s3.jsonl": {Project: "project-b"},
+			},
+			prefix: "claude/",
+			want:   map[string]int{"project-a": 2, "project-b": 1},
+		},
 	}
 
 	for _, tt := range tests {
@@ -386,3 +538,226 @@ func TestCountByProject(t *testing.T) {
 		})
 	}
 }
+
+func TestProjectOf(t *testing.T) {
+	tests := []struct {
+		key   string
+		depth int
+		want  string
+	}{
+		{"claude-code/app/s.jsonl", 1, "app"},
+		{"claude-code/app/sub/s.jsonl.gz", 1, "app"},
+		{"claude-code/2025/03/01/app/s.jsonl", 1, "app"},
+		{"claude-code/acme/app/s.jsonl", 2, "acme/app"},
+		{"claude-code/acme/s.jsonl", 2, ""},
+		{"claude-code/s.jsonl", 1, ""},
+		{"other/app/s.jsonl", 1, ""},
+	}
+
+	for _, tt := range tests {
+		if got := ProjectOf(tt.key, "claude-code/", tt.depth); got != tt.want {
+			t.Errorf("ProjectOf(%q, depth %d) = %q, want %q", tt.key, tt.depth, got, tt.want)
+		}
+	}
+}
+
+func TestUsageByProject(t *testing.T) {
+	tests := []struct {
+		name       string
+		files      map[string]FileEntry
+		want       map[string]Usage
+		wantApprox bool
+	}{
+		{
+			name: "stored sizes",
+			files: map[string]FileEntry{
+				"claude-code/a/s1.jsonl": {Size: 100, UploadedSize: 40},
+				"claude-code/a/s2.jsonl": {Size: 200, UploadedSize: 60},
+			},
+			want: map[string]Usage{"a": {Files: 2, Bytes: 100}},
+		},
+		{
+			name: "mixed old and new entries",
+			files: map[string]FileEntry{
+				"claude-code/a/new.jsonl": {Size: 100, UploadedSize: 40},
+				"claude-code/a/old.jsonl": {Size: 200},
+				"claude-code/b/new.jsonl": {Size: 10, UploadedSize: 5},
+			},
+			want: map[string]Usage{
+				"a": {Files: 2, Bytes: 240, Approximate: true},
+				"b": {Files: 1, Bytes: 5},
+			},
+			wantApprox: true,
+		},
+		{
+			name: "empty source is exact",
+			files: map[string]FileEntry{
+				"claude-code/a/empty.jsonl": {},
+			},
+			want: map[string]Usage{"a": {Files: 1}},
+		},
+		{
+			name: "latest upload time",
+			files: map[string]FileEntry{
+				"claude-code/a/s1.jsonl": {UploadedAt: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+				"claude-code/a/s2.jsonl": {UploadedAt: time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)},
+				"claude-code/a/s3.jsonl": {},
+			},
+			want: map[string]Usage{"a": {Files: 3, LastUpload: time.Date(2025, 3, 2, 0, 0, 0, 0, time.UTC)}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := &Manifest{Version: 1, Files: tt.files}
+			got := m.UsageByProject("claude-code/")
+			if len(got) != len(tt.want) {
+				t.Fatalf("UsageByProject() = %+v, want %+v", got, tt.want)
+			}
+			var total Usage
+			for project, want := range tt.want {
+				if got[project] != want {
+					t.Errorf("UsageByProject()[%q] = %+v, want %+v", project, got[project], want)
+				}
+				total.Add(got[project])
+			}
+			if total.Approximate != tt.wantApprox {
+				t.Errorf("total.Approximate = %v, want %v", total.Approximate, tt.wantApprox)
+			}
+		})
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	older := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	newer := older.Add(48 * time.Hour)
+	m := &Manifest{Version: 1, Files: map[string]FileEntry{
+		"claude-code/b/s1.jsonl": {Size: 100, UploadedSize: 40, Mtime: older, Machine: "laptop"},
+		"claude-code/b/s2.jsonl": {Size: 200, UploadedSize: 60, Mtime: newer, Machine: "desktop"},
+		"claude-code/b/s3.jsonl": {Size: 50, UploadedSize: 50, Mtime: older, Machine: "laptop"},
+		"claude-code/a/s1.jsonl": {Size: 10, Mtime: older},
+	}}
+
+	got := m.Summarize("claude-code/")
+	want := []ProjectSummary{
+		{Project: "a", SourceBytes: 10, Newest: older, Usage: Usage{Files: 1, Bytes: 10, Approximate: true}},
+		{Project: "b", SourceBytes: 350, Newest: newer, Machines: []string{"desktop", "laptop"}, Usage: Usage{Files: 3, Bytes: 150}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Summarize() = %+v, want %+v", got, want)
+	}
+}
+
+// blockingS3Client blocks every call until its context is done.
+type blockingS3Client struct{}
+
+func (b *blockingS3Client) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (b *blockingS3Client) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestLoad_Timeout(t *testing.T) {
+	_, err := Load(context.Background(), &blockingS3Client{}, "bucket", "key", 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Load() error = %v, want wrapped context.DeadlineExceeded", err)
+	}
+}
+
+func TestSave_Timeout(t *testing.T) {
+	err := Save(context.Background(), &blockingS3Client{}, "bucket", "key", New(), 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Save() error = %v, want wrapped context.DeadlineExceeded", err)
+	}
+}
+
+func TestKeyFor(t *testing.T) {
+	tests := []struct {
+		prefix string
+		name   string
+		want   string
+	}{
+		{"", "", ".manifest.json"},
+		{"claude-code/", "", "claude-code/.manifest.json"},
+		{"claude-code", "", "claude-code/.manifest.json"},
+		{"claude-code/laptop/", "", "claude-code/laptop/.manifest.json"},
+		{"", "manifest-v2.json", "manifest-v2.json"},
+		{"claude-code/", "manifest-v2.json", "claude-code/manifest-v2.json"},
+		{"claude-code", "manifest-v2.json", "claude-code/manifest-v2.json"},
+	}
+
+	for _, tt := range tests {
+		if got := KeyFor(tt.prefix, tt.name); got != tt.want {
+			t.Errorf("KeyFor(%q, %q) = %q, want %q", tt.prefix, tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestConfigKey(t *testing.T) {
+	tests := []struct {
+		name string
+		s3   types.S3Config
+		id   string
+		want string
+	}{
+		{"default", types.S3Config{Prefix: "claude-code/"}, "", "claude-code/.manifest.json"},
+		{"custom with slash", types.S3Config{Prefix: "claude-code/", ManifestKey: "cclogs.json"}, "", "claude-code/cclogs.json"},
+		{"custom without slash", types.S3Config{Prefix: "claude-code", ManifestKey: "cclogs.json"}, "", "claude-code/cclogs.json"},
+		{"custom by host", types.S3Config{Prefix: "logs", KeyLayout: "by_host", ManifestKey: "cclogs.json"}, "laptop", "logs/laptop/cclogs.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &types.Config{S3: tt.s3, Local: types.LocalConfig{MachineID: tt.id}}
+			if got := ConfigKey(cfg); got != tt.want {
+				t.Errorf("ConfigKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTags(t *testing.T) {
+	m := New()
+	m.Files["p/aws.jsonl"] = FileEntry{Redactions: map[string]int64{"AWS_SECRET": 2, "EMAIL": 1}}
+	m.Files["p/email.jsonl"] = FileEntry{Redactions: map[string]int64{"EMAIL": 4, "AWS_SECRET": 0}}
+	m.Files["p/clean.jsonl"] = FileEntry{Lines: 10}
+
+	// Tag sets survive a save and load
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := parse(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for key, want := range map[string][]string{
+		"p/aws.jsonl":   {"AWS_SECRET", "EMAIL"},
+		"p/email.jsonl": {"EMAIL"},
+		"p/clean.jsonl": {},
+	} {
+		if got := parsed.Files[key].Tags(); !slices.Equal(got, want) {
+			t.Errorf("Tags(%s) = %v, want %v", key, got, want)
+		}
+	}
+
+	tests := []struct {
+		tags []string
+		want []string
+	}{
+		{[]string{"AWS_SECRET"}, []string{"p/aws.jsonl"}},
+		{[]string{"email"}, []string{"p/aws.jsonl", "p/email.jsonl"}},
+		{[]string{"JWT", "AWS_SECRET"}, []string{"p/aws.jsonl"}},
+		{[]string{"JWT"}, nil},
+	}
+	for _, tt := range tests {
+		if got := parsed.FindTags(tt.tags); !slices.Equal(got, tt.want) {
+			t.Errorf("FindTags(%v) = %v, want %v", tt.tags, got, tt.want)
+		}
+	}
+}
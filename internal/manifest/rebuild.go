@@ -0,0 +1,252 @@
+package manifest
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/codec"
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// sourceSizeMetadata is the object metadata key the uploader records the
+// local file size in.
+const sourceSizeMetadata = "source-size"
+
+// RebuildClient defines the S3 operations Rebuild needs.
+type RebuildClient interface {
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+}
+
+// LocalFile describes the local file an object key was rendered from.
+type LocalFile struct {
+	Mtime   time.Time
+	Size    int64
+	Project string
+}
+
+// RebuildOptions describes where the archived objects are and what is known
+// about them besides the listing.
+type RebuildOptions struct {
+	Prefix     string   // s3.prefix, as substituted for {prefix} in Template
+	ListPrefix string   // Prefix to list (config.KeyPrefix)
+	Template   string   // Effective s3.key_template
+	Extensions []string // Session log extensions (local.extensions)
+
+	// ProjectDepth is how many key segments {project} spans
+	// (local.project_depth). Projects of local files are taken as they are.
+	ProjectDepth int
+
+	// Previous is the current manifest, if any. Its entries are kept for
+	// objects whose stored size still matches.
+	Previous *Manifest
+
+	// Local maps object keys to the local files they would be uploaded from.
+	Local map[string]LocalFile
+
+	Timeout time.Duration // Per-request timeout (non-positive disables it)
+}
+
+// RebuildStats counts what Rebuild did.
+type RebuildStats struct {
+	Objects int // Objects recorded in the rebuilt manifest
+	Kept    int // Entries carried over from the previous manifest
+	Added   int // Objects the previous manifest did not track
+	Removed int // Previous entries whose object is gone
+	Ignored int // Objects that are not session logs under the key template
+	Older   int // Dated objects superseded by a newer copy of the same file
+}
+
+// Rebuild reconstructs a manifest from a listing of opts.ListPrefix. Every
+// object whose key, less a compression suffix, is a session log matching the
+// key template gets an entry; its project (and machine, for templates with
+// {host}) is read from the key.
+//
+// Objects stored with --date-partition are recorded under their undated key,
+// with Object set to the dated one. When a file was uploaded on several days,
+// the most recently written object wins.
+//
+// Objects unchanged since the previous manifest keep their entry. Others are
+// read with HeadObject for the source size and SHA-256 checksum the uploader
+// records. The object's LastModified stands in for the source mtime unless the
+// matching local file has the recorded size and has not changed since the
+// object was written, in which case its mtime is used so the next upload
+// skips it.
+func Rebuild(ctx context.Context, client RebuildClient, bucket string, opts RebuildOptions) (*Manifest, RebuildStats, error) {
+	m := New()
+	var stats RebuildStats
+	if opts.Previous != nil {
+		m.LastUpload = opts.Previous.LastUpload
+		m.Archive = opts.Previous.Archive
+	}
+
+	written := make(map[string]time.Time) // LastModified of each entry's object
+	kept := make(map[string]bool)         // Whether each entry is the previous one
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(opts.ListPrefix),
+	}
+	for {
+		opCtx, cancel := config.WithOperationTimeout(ctx, opts.Timeout)
+		output, err := client.ListObjectsV2(opCtx, input)
+		cancel()
+		if err != nil {
+			return nil, stats, fmt.Errorf("list objects with prefix %s: %w", opts.ListPrefix, err)
+		}
+
+		for _, obj := range output.Contents {
+			key := aws.ToString(obj.Key)
+			size := aws.ToInt64(obj.Size)
+
+			c, entryKey, fields, ok := parseObjectKey(key, opts)
+			if !ok {
+				stats.Ignored++
+				continue
+			}
+			modified := aws.ToTime(obj.LastModified).UTC()
+			if last, seen := written[entryKey]; seen {
+				stats.Older++
+				if !modified.After(last) {
+					continue
+				}
+			}
+			written[entryKey] = modified
+
+			object := ""
+			if entryKey != key {
+				object = key
+			}
+			if prev, ok := previousEntry(opts.Previous, entryKey, object, size); ok {
+				m.Files[entryKey] = prev
+				kept[entryKey] = true
+				continue
+			}
+
+			entry := FileEntry{
+				Mtime:        modified,
+				Size:         size,
+				UploadedSize: size,
+				Project:      fields.Project,
+				Codec:        string(c),
+				Machine:      fields.Host,
+				ETag:         aws.ToString(obj.ETag),
+				UploadedAt:   modified,
+				Object:       object,
+			}
+			sourceSize, err := headEntry(ctx, client, bucket, key, &entry, opts.Timeout)
+			if err != nil {
+				return nil, stats, err
+			}
+
+			lf, ok := opts.Local[entryKey]
+			if ok && lf.Project != "" {
+				entry.Project = lf.Project
+			}
+			if ok && !lf.Mtime.After(entry.Mtime) && (sourceSize < 0 || sourceSize == lf.Size) {
+				entry.Mtime = lf.Mtime
+				entry.Size = lf.Size
+			}
+
+			m.Files[entryKey] = entry
+			kept[entryKey] = false
+		}
+
+		if !aws.ToBool(output.IsTruncated) {
+			break
+		}
+		input.ContinuationToken = output.NextContinuationToken
+	}
+
+	stats.Objects = len(m.Files)
+	for _, k := range kept {
+		if k {
+			stats.Kept++
+		} else {
+			stats.Added++
+		}
+	}
+	if opts.Previous != nil {
+		for key := range opts.Previous.Files {
+			if _, ok := m.Files[key]; !ok && strings.HasPrefix(key, opts.ListPrefix) {
+				stats.Removed++
+			}
+		}
+	}
+	return m, stats, nil
+}
+
+// parseObjectKey returns the codec, manifest key, and template fields of a
+// session log object key. The manifest key is the object key without a date
+// partition. ok is false for other objects, such as the manifest.
+func parseObjectKey(key string, opts RebuildOptions) (codec.Codec, string, config.KeyFields, bool) {
+	c, name := codec.FromKey(key)
+	if !config.HasLogExtension(name, opts.Extensions) {
+		return "", "", config.KeyFields{}, false
+	}
+	if undated, dated := config.UndatedKey(opts.ListPrefix, key); dated {
+		_, undatedName := codec.FromKey(undated)
+		if fields, ok := config.ParseKey(opts.Template, opts.Prefix, undatedName, opts.ProjectDepth); ok {
+			return c, undated, fields, true
+		}
+	}
+	fields, ok := config.ParseKey(opts.Template, opts.Prefix, name, opts.ProjectDepth)
+	return c, key, fields, ok
+}
+
+// previousEntry returns the previous manifest's entry for key if it records
+// object (empty for key itself) and the object still has the stored size it
+// records.
+func previousEntry(prev *Manifest, key, object string, size int64) (FileEntry, bool) {
+	if prev == nil {
+		return FileEntry{}, false
+	}
+	entry, ok := prev.Files[key]
+	if !ok || entry.Object != object {
+		return FileEntry{}, false
+	}
+	stored := entry.UploadedSize
+	if stored == 0 {
+		stored = entry.Size // Written before uploaded_size was recorded
+	}
+	return entry, stored == size
+}
+
+// headEntry fills in entry from the object's metadata: the SHA-256 of the
+// stored content when S3 kept a full-object checksum, and the source size.
+// It returns the source size, or -1 if the object does not record it.
+func headEntry(ctx context.Context, client RebuildClient, bucket, key string, entry *FileEntry, timeout time.Duration) (int64, error) {
+	ctx, cancel := config.WithOperationTimeout(ctx, timeout)
+	defer cancel()
+
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket:       aws.String(bucket),
+		Key:          aws.String(key),
+		ChecksumMode: types.ChecksumModeEnabled,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("head object %s: %w", key, err)
+	}
+
+	// Multipart checksums ("<hash>-<parts>") are not the hash of the content
+	if sum := aws.ToString(head.ChecksumSHA256); sum != "" && head.ChecksumType != types.ChecksumTypeComposite {
+		if raw, err := base64.StdEncoding.DecodeString(sum); err == nil && len(raw) == 32 {
+			entry.SHA256 = hex.EncodeToString(raw)
+		}
+	}
+
+	if v, ok := head.Metadata[sourceSizeMetadata]; ok {
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			entry.Size = n
+			return n, nil
+		}
+	}
+	return -1, nil
+}
@@ -0,0 +1,187 @@
+package manifest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// listingClient lists objects one per page and answers HeadObject from heads.
+type listingClient struct {
+	objects []s3types.Object
+	heads   map[string]*s3.HeadObjectOutput
+	headed  []string
+}
+
+func (c *listingClient) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	i := 0
+	if params.ContinuationToken != nil {
+		i = int(aws.ToString(params.ContinuationToken)[0] - '0')
+	}
+	out := &s3.ListObjectsV2Output{}
+	if i < len(c.objects) {
+		out.Contents = c.objects[i : i+1]
+	}
+	if i+1 < len(c.objects) {
+		out.IsTruncated = aws.Bool(true)
+		out.NextContinuationToken = aws.String(string(rune('0' + i + 1)))
+	}
+	return out, nil
+}
+
+func (c *listingClient) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	key := aws.ToString(params.Key)
+	c.headed = append(c.headed, key)
+	if params.ChecksumMode != s3types.ChecksumModeEnabled {
+		return nil, errors.New("checksum mode not enabled")
+	}
+	if head, ok := c.heads[key]; ok {
+		return head, nil
+	}
+	return &s3.HeadObjectOutput{}, nil
+}
+
+func TestRebuild(t *testing.T) {
+	uploaded := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	before := uploaded.Add(-time.Hour)
+	sum := sha256.Sum256([]byte("redacted"))
+
+	client := &listingClient{
+		objects: []s3types.Object{
+			{Key: aws.String("claude-code/.manifest.json"), Size: aws.Int64(10), LastModified: &uploaded},
+			{Key: aws.String("claude-code/app/kept.jsonl"), Size: aws.Int64(40), LastModified: &uploaded},
+			{Key: aws.String("claude-code/app/local.jsonl.gz"), Size: aws.Int64(30), LastModified: &uploaded},
+			{Key: aws.String("claude-code/app/changed.jsonl"), Size: aws.Int64(8), LastModified: &uploaded},
+			{Key: aws.String("claude-code/lib/sub/remote.jsonl"), Size: aws.Int64(8), LastModified: &uploaded},
+			{Key: aws.String("claude-code/loose.jsonl"), Size: aws.Int64(5), LastModified: &uploaded},
+		},
+		heads: map[string]*s3.HeadObjectOutput{
+			"claude-code/app/local.jsonl.gz": {Metadata: map[string]string{"source-size": "100"}},
+			"claude-code/app/changed.jsonl":  {Metadata: map[string]string{"source-size": "9"}},
+			"claude-code/lib/sub/remote.jsonl": {
+				ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+				ChecksumType:   s3types.ChecksumTypeFullObject,
+			},
+		},
+	}
+	previous := &Manifest{Version: 1, LastUpload: uploaded, Files: map[string]FileEntry{
+		"claude-code/app/kept.jsonl": {Size: 50, UploadedSize: 40, SHA256: "abc", Project: "app"},
+		"claude-code/app/gone.jsonl": {Size: 50, UploadedSize: 40},
+	}}
+
+	m, stats, err := Rebuild(context.Background(), client, "bucket", RebuildOptions{
+		Prefix:     "claude-code/",
+		ListPrefix: "claude-code/",
+		Template:   config.DefaultKeyTemplate,
+		Previous:   previous,
+		Local: map[string]LocalFile{
+			"claude-code/app/local.jsonl.gz": {Mtime: before, Size: 100},
+			"claude-code/app/changed.jsonl":  {Mtime: uploaded.Add(time.Hour), Size: 20},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+
+	want := RebuildStats{Objects: 4, Kept: 1, Added: 3, Removed: 1, Ignored: 2}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+	if !m.LastUpload.Equal(uploaded) {
+		t.Errorf("LastUpload = %v, want the previous manifest's", m.LastUpload)
+	}
+	if len(client.headed) != 3 {
+		t.Errorf("HeadObject called for %v, want only the 3 added objects", client.headed)
+	}
+
+	if got := m.Files["claude-code/app/kept.jsonl"]; got.SHA256 != "abc" {
+		t.Errorf("kept entry = %+v, want the previous entry", got)
+	}
+	if got := m.Files["claude-code/app/local.jsonl.gz"]; !got.Mtime.Equal(before) || got.Size != 100 ||
+		got.UploadedSize != 30 || got.Codec != "gzip" || got.Project != "app" {
+		t.Errorf("local entry = %+v, want local mtime, source size, gzip", got)
+	}
+	if got := m.Files["claude-code/app/changed.jsonl"]; !got.Mtime.Equal(uploaded) || got.Size != 9 {
+		t.Errorf("changed entry = %+v, want LastModified and recorded source size", got)
+	}
+	if got := m.Files["claude-code/lib/sub/remote.jsonl"]; got.SHA256 != hex.EncodeToString(sum[:]) ||
+		got.Project != "lib" || got.Codec != "none" {
+		t.Errorf("remote entry = %+v, want checksum and project lib", got)
+	}
+}
+
+func TestRebuildDatePartition(t *testing.T) {
+	day1 := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	client := &listingClient{objects: []s3types.Object{
+		{Key: aws.String("claude-code/2025/03/01/app/s.jsonl"), Size: aws.Int64(5), LastModified: &day1},
+		{Key: aws.String("claude-code/2025/03/02/app/s.jsonl"), Size: aws.Int64(8), LastModified: &day2},
+		{Key: aws.String("claude-code/2025/03/01/web/t.jsonl.gz"), Size: aws.Int64(6), LastModified: &day1},
+		{Key: aws.String("claude-code/app/u.jsonl"), Size: aws.Int64(4), LastModified: &day1},
+	}}
+	previous := &Manifest{Version: 1, Files: map[string]FileEntry{
+		"claude-code/web/t.jsonl.gz": {Size: 20, UploadedSize: 6, Codec: "gzip", Object: "claude-code/2025/03/01/web/t.jsonl.gz", SHA256: "abc"},
+	}}
+
+	m, stats, err := Rebuild(context.Background(), client, "bucket", RebuildOptions{
+		Prefix:     "claude-code/",
+		ListPrefix: "claude-code/",
+		Template:   config.DefaultKeyTemplate,
+		Previous:   previous,
+		Local: map[string]LocalFile{
+			"claude-code/app/s.jsonl": {Mtime: day1, Size: 8},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+
+	want := RebuildStats{Objects: 3, Kept: 1, Added: 2, Older: 1}
+	if stats != want {
+		t.Errorf("stats = %+v, want %+v", stats, want)
+	}
+	for key := range m.Files {
+		if m.Project(key, "claude-code/") == "2025" {
+			t.Errorf("entry %s is in a year project", key)
+		}
+	}
+
+	if got := m.Files["claude-code/app/s.jsonl"]; got.Object != "claude-code/2025/03/02/app/s.jsonl" ||
+		got.UploadedSize != 8 || got.Project != "app" || !got.Mtime.Equal(day1) {
+		t.Errorf("dated entry = %+v, want the newest partition's object with the local mtime", got)
+	}
+	if got := m.Files["claude-code/web/t.jsonl.gz"]; got.SHA256 != "abc" || got.Object != "claude-code/2025/03/01/web/t.jsonl.gz" {
+		t.Errorf("compressed dated entry = %+v, want the previous entry", got)
+	}
+	if got := m.Files["claude-code/app/u.jsonl"]; got.Object != "" || got.Project != "app" {
+		t.Errorf("undated entry = %+v, want no separate object", got)
+	}
+}
+
+func TestRebuildByHost(t *testing.T) {
+	modified := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	client := &listingClient{objects: []s3types.Object{
+		{Key: aws.String("claude-code/laptop/app/s.jsonl"), Size: aws.Int64(8), LastModified: &modified},
+	}}
+
+	m, _, err := Rebuild(context.Background(), client, "bucket", RebuildOptions{
+		Prefix:     "claude-code/",
+		ListPrefix: "claude-code/laptop/",
+		Template:   config.ByHostKeyTemplate,
+	})
+	if err != nil {
+		t.Fatalf("Rebuild() error = %v", err)
+	}
+	if got := m.Files["claude-code/laptop/app/s.jsonl"]; got.Project != "app" || got.Machine != "laptop" {
+		t.Errorf("entry = %+v, want project app on laptop", got)
+	}
+}
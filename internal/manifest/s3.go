@@ -6,10 +6,20 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
 
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/s3errors"
+	"github.com/13rac1/cclogs/internal/telemetry"
 	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 // S3Client defines the minimal S3 client interface needed for manifest operations.
@@ -21,30 +31,106 @@ type S3Client interface {
 // Load downloads and parses the manifest from S3.
 // Returns an empty manifest if the file doesn't exist (first run).
 // Returns an error for other failures (network, permissions, corrupt JSON).
-func Load(ctx context.Context, client S3Client, bucket, key string) (*Manifest, error) {
-	output, err := client.GetObject(ctx, &s3.GetObjectInput{
+// The download is bounded by timeout (non-positive disables the deadline).
+//
+// With a cache directory set, the request is conditional on the ETag of the
+// cached copy, which is used as is when S3 reports it unchanged.
+func Load(ctx context.Context, client S3Client, bucket, key string, timeout time.Duration) (m *Manifest, err error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "manifest.load", trace.WithAttributes(attribute.String("manifest.key", key)))
+	defer func() {
+		if m != nil {
+			span.SetAttributes(attribute.Int("manifest.files", len(m.Files)))
+		}
+		telemetry.EndSpan(span, err)
+	}()
+
+	ctx, cancel := config.WithOperationTimeout(ctx, timeout)
+	defer cancel()
+
+	input := &s3.GetObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
-	})
+	}
+	cachePath := CachePath(bucket, key)
+	var cached *Manifest
+	if cachePath != "" {
+		if m, etag, err := readCache(cachePath); err == nil {
+			cached = m
+			input.IfNoneMatch = aws.String(etag)
+		}
+	}
+
+	output, err := client.GetObject(ctx, input)
 
 	if err != nil {
+		if cached != nil && isNotModified(err) {
+			span.SetAttributes(attribute.Bool("manifest.cached", true))
+			return cached, nil
+		}
 		var nsk *types.NoSuchKey
 		var nf *types.NotFound
 		if errors.As(err, &nsk) || errors.As(err, &nf) {
+			if cachePath != "" {
+				_ = os.Remove(cachePath)
+			}
 			return New(), nil
 		}
-		return nil, fmt.Errorf("downloading manifest: %w", err)
+		return nil, fmt.Errorf("downloading manifest: %w", s3errors.Wrap(err))
 	}
 	defer func() { _ = output.Body.Close() }()
 
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return nil, fmt.Errorf("downloading manifest: %w", err)
+	}
+	m, err = parse(data)
+	if err != nil {
+		return nil, err
+	}
+
+	writeCache(cachePath, aws.ToString(output.ETag), data)
+	return m, nil
+}
+
+// ErrCorrupt matches errors for a manifest that is not valid JSON or has no
+// valid version. A manifest written by a newer cclogs is not corrupt.
+var ErrCorrupt = errors.New("manifest is corrupt")
+
+// corruptError marks a parse error as ErrCorrupt without changing its
+// message.
+type corruptError struct {
+	err error
+}
+
+// Error implements error.
+func (e *corruptError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns ErrCorrupt and the underlying error.
+func (e *corruptError) Unwrap() []error {
+	return []error{ErrCorrupt, e.err}
+}
+
+// parse decodes a manifest document.
+func parse(data []byte) (*Manifest, error) {
 	var m Manifest
-	if err := json.NewDecoder(output.Body).Decode(&m); err != nil {
-		return nil, fmt.Errorf("parsing manifest JSON: %w", err)
+	if err := json.Unmarshal(data, &m); err != nil {
+		if where := jsonErrorLocation(data, err); where != "" {
+			return nil, &corruptError{fmt.Errorf("parsing manifest JSON (%s): %w", where, err)}
+		}
+		return nil, &corruptError{fmt.Errorf("parsing manifest JSON: %w", err)}
 	}
 
-	if m.Version != 1 {
+	switch {
+	case m.Version > Version:
 		return nil, fmt.Errorf("unsupported manifest version: %d", m.Version)
+	case m.Version < 1:
+		return nil, &corruptError{fmt.Errorf("invalid manifest version: %d", m.Version)}
 	}
+	// Later versions only add optional fields, so older manifests migrate
+	// by relabeling; the next Save writes the current version
+	m.Version = Version
 
 	if m.Files == nil {
 		m.Files = make(map[string]FileEntry)
@@ -53,14 +139,44 @@ func Load(ctx context.Context, client S3Client, bucket, key string) (*Manifest,
 	return &m, nil
 }
 
-// Save uploads the manifest to S3 as JSON.
-func Save(ctx context.Context, client S3Client, bucket, key string, m *Manifest) error {
+// isNotModified reports whether a conditional GET found the object unchanged.
+func isNotModified(err error) bool {
+	var respErr *awshttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusNotModified
+}
+
+// Save uploads the manifest to S3 as JSON, then replaces the cached copy, if
+// caching is enabled.
+// The upload is bounded by timeout (non-positive disables the deadline).
+func Save(ctx context.Context, client S3Client, bucket, key string, m *Manifest, timeout time.Duration) (err error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "manifest.save", trace.WithAttributes(
+		attribute.String("manifest.key", key),
+		attribute.Int("manifest.files", len(m.Files)),
+	))
+	defer func() { telemetry.EndSpan(span, err) }()
+
+	m.Version = Version
 	data, err := json.MarshalIndent(m, "", "  ")
 	if err != nil {
 		return fmt.Errorf("marshaling manifest: %w", err)
 	}
+	span.SetAttributes(attribute.Int("manifest.bytes", len(data)))
+
+	if keep, warn := historySettings(); keep > 0 {
+		if hc, ok := client.(HistoryClient); ok {
+			var locked *LockedError
+			if err := backup(ctx, hc, bucket, key, keep, time.Now(), timeout); errors.As(err, &locked) {
+				fmt.Fprintf(warn, "Warning: old manifest backups kept: %v\n", err)
+			} else if err != nil {
+				fmt.Fprintf(warn, "Warning: manifest backup failed: %v\n", err)
+			}
+		}
+	}
+
+	ctx, cancel := config.WithOperationTimeout(ctx, timeout)
+	defer cancel()
 
-	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+	output, err := client.PutObject(ctx, &s3.PutObjectInput{
 		Bucket:      aws.String(bucket),
 		Key:         aws.String(key),
 		Body:        bytes.NewReader(data),
@@ -68,8 +184,9 @@ func Save(ctx context.Context, client S3Client, bucket, key string, m *Manifest)
 	})
 
 	if err != nil {
-		return fmt.Errorf("uploading manifest: %w", err)
+		return fmt.Errorf("uploading manifest: %w", s3errors.Wrap(err))
 	}
 
+	writeCache(CachePath(bucket, key), aws.ToString(output.ETag), data)
 	return nil
 }
@@ -0,0 +1,124 @@
+// Package migrate moves users from the earlier ccls tool to cclogs.
+// It copies the old configuration to the new location and bootstraps a manifest
+// from the existing bucket contents so previously uploaded files are not re-uploaded.
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// S3Client defines the minimal S3 client interface needed for manifest bootstrap.
+type S3Client interface {
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+}
+
+// LocalFile describes a local file that may already exist remotely.
+type LocalFile struct {
+	Mtime   time.Time
+	Size    int64
+	Project string // Project directory the file was discovered in
+}
+
+// MigrateConfig copies the ccls config at oldPath to newPath.
+// Returns true if the config was copied, false if there was nothing to do
+// (no old config, or a config already exists at newPath).
+func MigrateConfig(oldPath, newPath string) (bool, error) {
+	data, err := os.ReadFile(oldPath)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
+		}
+		return false, fmt.Errorf("reading ccls config %s: %w", oldPath, err)
+	}
+
+	if _, err := os.Stat(newPath); err == nil {
+		return false, nil
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return false, fmt.Errorf("checking config %s: %w", newPath, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(newPath), 0755); err != nil {
+		return false, fmt.Errorf("creating config directory: %w", err)
+	}
+
+	// ccls and cclogs share the same config schema; only the location changed
+	if err := os.WriteFile(newPath, data, 0600); err != nil {
+		return false, fmt.Errorf("writing config %s: %w", newPath, err)
+	}
+
+	return true, nil
+}
+
+// BootstrapManifest adds an entry to m for every remote log object under prefix,
+// one whose key ends in one of exts (see config.HasLogExtension), that the
+// manifest does not already track. Existing entries are left untouched,
+// so running it twice is a no-op.
+//
+// ccls compared by size and never recorded source mtimes. When a remote object
+// corresponds to a local file that has not changed since the object was written,
+// the local mtime is recorded so the next upload skips it. Otherwise the object's
+// LastModified is used, and the file will be re-uploaded if it differs locally.
+// Entries for local files record the file's project.
+//
+// Returns the number of entries added.
+func BootstrapManifest(ctx context.Context, client S3Client, bucket, prefix string, exts []string, m *manifest.Manifest, local map[string]LocalFile, timeout time.Duration) (int, error) {
+	added := 0
+
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(bucket),
+		Prefix: aws.String(prefix),
+	}
+
+	for {
+		opCtx, cancel := config.WithOperationTimeout(ctx, timeout)
+		output, err := client.ListObjectsV2(opCtx, input)
+		cancel()
+		if err != nil {
+			return added, fmt.Errorf("list objects with prefix %s: %w", prefix, err)
+		}
+
+		for _, obj := range output.Contents {
+			key := aws.ToString(obj.Key)
+			if !config.HasLogExtension(key, exts) {
+				continue
+			}
+			if _, exists := m.Files[key]; exists {
+				continue
+			}
+
+			entry := manifest.FileEntry{
+				Mtime:        aws.ToTime(obj.LastModified).UTC(),
+				Size:         aws.ToInt64(obj.Size),
+				UploadedSize: aws.ToInt64(obj.Size),
+			}
+
+			if lf, ok := local[key]; ok {
+				entry.Project = lf.Project
+				if !lf.Mtime.After(entry.Mtime) {
+					entry.Mtime = lf.Mtime
+					entry.Size = lf.Size
+				}
+			}
+
+			m.Files[key] = entry
+			added++
+		}
+
+		if !aws.ToBool(output.IsTruncated) {
+			break
+		}
+		input.ContinuationToken = output.NextContinuationToken
+	}
+
+	return added, nil
+}
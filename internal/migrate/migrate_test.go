@@ -0,0 +1,160 @@
+package migrate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// mockS3Client returns a fixed listing split across pages.
+type mockS3Client struct {
+	pages []*s3.ListObjectsV2Output
+	calls int
+}
+
+func (m *mockS3Client) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	page := m.pages[m.calls]
+	m.calls++
+	return page, nil
+}
+
+func object(key string, size int64, modified time.Time) types.Object {
+	return types.Object{Key: &key, Size: &size, LastModified: &modified}
+}
+
+func TestMigrateConfig(t *testing.T) {
+	tmpDir := t.TempDir()
+	oldPath := filepath.Join(tmpDir, ".ccls", "config.yaml")
+	newPath := filepath.Join(tmpDir, ".cclogs", "config.yaml")
+
+	// No old config: nothing to do
+	copied, err := MigrateConfig(oldPath, newPath)
+	if err != nil {
+		t.Fatalf("MigrateConfig failed: %v", err)
+	}
+	if copied {
+		t.Error("copied = true with no ccls config, want false")
+	}
+
+	content := "s3:\n  bucket: old-bucket\n  region: us-east-1\n"
+	if err := os.MkdirAll(filepath.Dir(oldPath), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(oldPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	copied, err = MigrateConfig(oldPath, newPath)
+	if err != nil {
+		t.Fatalf("MigrateConfig failed: %v", err)
+	}
+	if !copied {
+		t.Error("copied = false, want true")
+	}
+
+	got, err := os.ReadFile(newPath)
+	if err != nil {
+		t.Fatalf("reading migrated config: %v", err)
+	}
+	if string(got) != content {
+		t.Errorf("migrated config = %q, want %q", got, content)
+	}
+
+	// Second run must not overwrite the now-existing config
+	if err := os.WriteFile(newPath, []byte("edited"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	copied, err = MigrateConfig(oldPath, newPath)
+	if err != nil {
+		t.Fatalf("MigrateConfig failed: %v", err)
+	}
+	if copied {
+		t.Error("copied = true on second run, want false")
+	}
+	got, _ = os.ReadFile(newPath)
+	if string(got) != "edited" {
+		t.Errorf("existing config was overwritten: %q", got)
+	}
+}
+
+func TestBootstrapManifest(t *testing.T) {
+	uploadedAt := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	localMtime := time.Date(2025, 2, 28, 9, 0, 0, 0, time.UTC)
+	changedMtime := time.Date(2025, 3, 2, 9, 0, 0, 0, time.UTC)
+
+	truncated := true
+	token := "page2"
+	client := &mockS3Client{pages: []*s3.ListObjectsV2Output{
+		{
+			Contents: []types.Object{
+				object("claude-code/p/unchanged.jsonl", 90, uploadedAt),
+				object("claude-code/p/changed.jsonl", 50, uploadedAt),
+			},
+			IsTruncated:           &truncated,
+			NextContinuationToken: &token,
+		},
+		{
+			Contents: []types.Object{
+				object("claude-code/p/remote-only.JSONL", 10, uploadedAt),
+				object("claude-code/p/notes.txt", 5, uploadedAt),
+				object("claude-code/p/agent.log", 7, uploadedAt),
+				object("claude-code/p/tracked.jsonl", 20, uploadedAt),
+			},
+		},
+	}}
+
+	m := manifest.New()
+	tracked := manifest.FileEntry{Mtime: localMtime, Size: 1}
+	m.Files["claude-code/p/tracked.jsonl"] = tracked
+
+	local := map[string]LocalFile{
+		"claude-code/p/unchanged.jsonl": {Mtime: localMtime, Size: 100, Project: "p"},
+		"claude-code/p/changed.jsonl":   {Mtime: changedMtime, Size: 60, Project: "p"},
+	}
+
+	exts := []string{".jsonl", ".log"}
+	added, err := BootstrapManifest(context.Background(), client, "bucket", "claude-code/", exts, m, local, 0)
+	if err != nil {
+		t.Fatalf("BootstrapManifest failed: %v", err)
+	}
+
+	if added != 4 {
+		t.Errorf("added = %d, want 4", added)
+	}
+
+	if got := m.Files["claude-code/p/unchanged.jsonl"]; !got.Mtime.Equal(localMtime) || got.Size != 100 || got.Project != "p" {
+		t.Errorf("unchanged entry = %+v, want local mtime %v, size 100 and project p", got, localMtime)
+	}
+	if got := m.Files["claude-code/p/changed.jsonl"]; !got.Mtime.Equal(uploadedAt) || got.Project != "p" {
+		t.Errorf("changed entry = %+v, want object LastModified %v and project p", got, uploadedAt)
+	}
+	if _, ok := m.Files["claude-code/p/agent.log"]; !ok {
+		t.Error("entry for configured extension .log missing")
+	}
+	if _, ok := m.Files["claude-code/p/remote-only.JSONL"]; !ok {
+		t.Error("remote-only entry missing")
+	}
+	if _, ok := m.Files["claude-code/p/notes.txt"]; ok {
+		t.Error("object without a log extension should not be added")
+	}
+	if got := m.Files["claude-code/p/tracked.jsonl"]; !reflect.DeepEqual(got, tracked) {
+		t.Errorf("existing entry modified: %+v", got)
+	}
+
+	// Second run adds nothing
+	client.calls = 0
+	added, err = BootstrapManifest(context.Background(), client, "bucket", "claude-code/", exts, m, local, 0)
+	if err != nil {
+		t.Fatalf("BootstrapManifest failed: %v", err)
+	}
+	if added != 0 {
+		t.Errorf("second run added = %d, want 0", added)
+	}
+}
@@ -0,0 +1,192 @@
+// Package msg is a small message catalog for user-facing text. Messages are
+// Go text/template strings keyed by a stable ID; the English catalog is
+// embedded, and translations are YAML files with the same keys, loaded from
+// a directory at startup. IDs double as machine-readable codes in JSON
+// output, so automation does not depend on the display language.
+package msg
+
+import (
+	"bytes"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultLang is the language of the embedded catalog.
+const DefaultLang = "en"
+
+//go:embed catalog/en.yaml
+var defaultCatalog []byte
+
+// Args are the named values a message template refers to, e.g. {{.Bucket}}.
+type Args map[string]any
+
+// Message is a catalog message with its arguments, rendered on demand.
+type Message struct {
+	ID   string
+	Args Args
+}
+
+// New returns the message id with args.
+func New(id string, args Args) Message {
+	return Message{ID: id, Args: args}
+}
+
+// String renders the message in the current language.
+func (m Message) String() string {
+	return Text(m.ID, m.Args)
+}
+
+var funcs = template.FuncMap{
+	// plural picks one or other by count: {{plural .N "file" "files"}}
+	"plural": func(n int, one, other string) string {
+		if n == 1 {
+			return one
+		}
+		return other
+	},
+}
+
+var (
+	mu       sync.RWMutex
+	english  = mustParse(DefaultLang, defaultCatalog)
+	active   map[string]*template.Template
+	language = DefaultLang
+)
+
+// Text renders message id with args in the current language. A translation
+// that fails to render falls back to English; an unknown ID renders as
+// itself so a missing message is visible rather than silently blank.
+func Text(id string, args Args) string {
+	mu.RLock()
+	tmpl := active[id]
+	mu.RUnlock()
+
+	if tmpl != nil {
+		if s, err := execute(tmpl, args); err == nil {
+			return s
+		}
+	}
+	if tmpl := english[id]; tmpl != nil {
+		if s, err := execute(tmpl, args); err == nil {
+			return s
+		}
+	}
+	return id
+}
+
+// Lang returns the current language.
+func Lang() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return language
+}
+
+// SetLang switches to lang, loading its translation from dir. Messages the
+// translation lacks stay English. Locale suffixes are tried from most to
+// least specific: "pt_BR.UTF-8" loads pt_BR.yaml, else pt.yaml. An empty
+// lang or "en" restores English.
+func SetLang(lang, dir string) error {
+	lang = strings.TrimSpace(lang)
+	if i := strings.IndexAny(lang, ".@"); i >= 0 {
+		lang = lang[:i]
+	}
+	if lang == "" || lang == DefaultLang || lang == "C" || lang == "POSIX" {
+		setActive(DefaultLang, nil)
+		return nil
+	}
+
+	candidates := []string{lang}
+	if base, _, ok := strings.Cut(lang, "_"); ok {
+		candidates = append(candidates, base)
+	}
+	for _, name := range candidates {
+		data, err := os.ReadFile(filepath.Join(dir, name+".yaml"))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("reading %s translation: %w", name, err)
+		}
+		tmpls, err := parseCatalog(name, data)
+		if err != nil {
+			return err
+		}
+		if unknown := unknownIDs(tmpls); len(unknown) > 0 {
+			return fmt.Errorf("%s translation: unknown message IDs: %s", name, strings.Join(unknown, ", "))
+		}
+		setActive(name, tmpls)
+		return nil
+	}
+	return fmt.Errorf("no translation for %q in %s", lang, dir)
+}
+
+// IDs returns every message ID in the English catalog, sorted.
+func IDs() []string {
+	ids := make([]string, 0, len(english))
+	for id := range english {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func setActive(lang string, tmpls map[string]*template.Template) {
+	mu.Lock()
+	defer mu.Unlock()
+	language = lang
+	active = tmpls
+}
+
+func unknownIDs(tmpls map[string]*template.Template) []string {
+	var unknown []string
+	for id := range tmpls {
+		if english[id] == nil {
+			unknown = append(unknown, id)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+func execute(tmpl *template.Template, args Args) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, args); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// parseCatalog compiles a catalog file. Templates fail on missing arguments
+// instead of printing "<no value>".
+func parseCatalog(lang string, data []byte) (map[string]*template.Template, error) {
+	var raw map[string]string
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parsing %s catalog: %w", lang, err)
+	}
+	tmpls := make(map[string]*template.Template, len(raw))
+	for id, text := range raw {
+		tmpl, err := template.New(id).Funcs(funcs).Option("missingkey=error").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("parsing %s message %s: %w", lang, id, err)
+		}
+		tmpls[id] = tmpl
+	}
+	return tmpls, nil
+}
+
+func mustParse(lang string, data []byte) map[string]*template.Template {
+	tmpls, err := parseCatalog(lang, data)
+	if err != nil {
+		panic(err)
+	}
+	return tmpls
+}
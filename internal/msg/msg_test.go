@@ -0,0 +1,272 @@
+package msg
+
+import (
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"text/template/parse"
+)
+
+// reference is a message used in the source: its ID and the argument names
+// passed with it.
+type reference struct {
+	pos  string
+	id   string
+	args []string
+}
+
+// references finds every msg.New and msg.Text call, and doctor's hint
+// helper, in the module's non-test sources.
+func references(t *testing.T) []reference {
+	t.Helper()
+	var refs []reference
+	fset := token.NewFileSet()
+	err := filepath.WalkDir(filepath.Join("..", ".."), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(path, ".go") || strings.HasSuffix(path, "_test.go") {
+			return nil
+		}
+		file, err := parser.ParseFile(fset, path, nil, 0)
+		if err != nil {
+			return err
+		}
+		ast.Inspect(file, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || !isMessageCall(call.Fun, file.Name.Name) || len(call.Args) != 2 {
+				return true
+			}
+			pos := fset.Position(call.Pos()).String()
+			if forwards(call) {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				t.Errorf("%s: message ID is not a string literal", pos)
+				return true
+			}
+			id, _ := strconv.Unquote(lit.Value)
+			refs = append(refs, reference{pos: pos, id: id, args: argNames(t, pos, call.Args[1])})
+			return true
+		})
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return refs
+}
+
+func isMessageCall(fun ast.Expr, pkg string) bool {
+	switch f := fun.(type) {
+	case *ast.SelectorExpr:
+		x, ok := f.X.(*ast.Ident)
+		return ok && x.Name == "msg" && (f.Sel.Name == "New" || f.Sel.Name == "Text")
+	case *ast.Ident:
+		return pkg == "doctor" && f.Name == "hint"
+	}
+	return false
+}
+
+// forwards reports whether call passes on its caller's ID and arguments, as
+// Message.String and doctor's hint do.
+func forwards(call *ast.CallExpr) bool {
+	id, ok := call.Args[0].(*ast.Ident)
+	if !ok {
+		return false
+	}
+	args, ok := call.Args[1].(*ast.Ident)
+	return ok && id.Name != "nil" && args.Name != "nil"
+}
+
+// argNames returns the keys of an Args literal, or none for nil.
+func argNames(t *testing.T, pos string, expr ast.Expr) []string {
+	if ident, ok := expr.(*ast.Ident); ok && ident.Name == "nil" {
+		return nil
+	}
+	lit, ok := expr.(*ast.CompositeLit)
+	if !ok {
+		t.Errorf("%s: message arguments are not an Args literal", pos)
+		return nil
+	}
+	var names []string
+	for _, elt := range lit.Elts {
+		kv, ok := elt.(*ast.KeyValueExpr)
+		if !ok {
+			continue
+		}
+		if key, ok := kv.Key.(*ast.BasicLit); ok {
+			name, _ := strconv.Unquote(key.Value)
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// fields returns the argument names a template refers to.
+func fields(node parse.Node, into map[string]bool) {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return
+		}
+		for _, c := range n.Nodes {
+			fields(c, into)
+		}
+	case *parse.ActionNode:
+		fields(n.Pipe, into)
+	case *parse.PipeNode:
+		for _, c := range n.Cmds {
+			fields(c, into)
+		}
+	case *parse.CommandNode:
+		for _, a := range n.Args {
+			fields(a, into)
+		}
+	case *parse.FieldNode:
+		into[n.Ident[0]] = true
+	case *parse.IfNode:
+		fields(n.Pipe, into)
+		fields(n.List, into)
+		fields(n.ElseList, into)
+	case *parse.RangeNode:
+		fields(n.Pipe, into)
+		fields(n.List, into)
+		fields(n.ElseList, into)
+	}
+}
+
+func templateFields(id string) []string {
+	set := make(map[string]bool)
+	fields(english[id].Tree.Root, set)
+	var names []string
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestReferencedMessages(t *testing.T) {
+	refs := references(t)
+	if len(refs) == 0 {
+		t.Fatal("no message references found")
+	}
+
+	used := make(map[string]bool)
+	for _, r := range refs {
+		used[r.id] = true
+		if english[r.id] == nil {
+			t.Errorf("%s: message %q is not in the default catalog", r.pos, r.id)
+			continue
+		}
+		if want := templateFields(r.id); strings.Join(r.args, ",") != strings.Join(want, ",") {
+			t.Errorf("%s: message %q passed arguments %v, template uses %v", r.pos, r.id, r.args, want)
+		}
+	}
+
+	for _, id := range IDs() {
+		if !used[id] {
+			t.Errorf("message %q is in the catalog but never used", id)
+		}
+	}
+}
+
+func TestSetLang(t *testing.T) {
+	dir := t.TempDir()
+	de := "doctor.config.file.ok: \"Konfiguration geladen: {{.Path}}\"\n" +
+		"doctor.remote.bucket.ok: \"Verbunden mit {{.Bucket}} ({{.Regionn}})\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "de.yaml"), []byte(de), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = SetLang("", dir) }()
+
+	if err := SetLang("de_DE.UTF-8", dir); err != nil {
+		t.Fatalf("SetLang() error = %v", err)
+	}
+	if got := Lang(); got != "de" {
+		t.Errorf("Lang() = %q, want de", got)
+	}
+
+	tests := []struct {
+		id   string
+		args Args
+		want string
+	}{
+		{"doctor.config.file.ok", Args{"Path": "/c.yaml"}, "Konfiguration geladen: /c.yaml"},
+		// Untranslated messages stay English
+		{"doctor.config.region.ok", Args{"Region": "eu-west-1"}, "S3 region configured: eu-west-1"},
+		// A translation referring to an unknown argument falls back to English
+		{"doctor.remote.bucket.ok", Args{"Bucket": "b", "Region": "r"}, "Connected to bucket: b (r)"},
+		{"no.such.message", nil, "no.such.message"},
+	}
+	for _, tt := range tests {
+		if got := Text(tt.id, tt.args); got != tt.want {
+			t.Errorf("Text(%q) = %q, want %q", tt.id, got, tt.want)
+		}
+	}
+
+	if err := SetLang("en", dir); err != nil {
+		t.Fatal(err)
+	}
+	if got := Text("doctor.config.file.ok", Args{"Path": "/c.yaml"}); got != "Config file loaded: /c.yaml" {
+		t.Errorf("Text() after SetLang(en) = %q", got)
+	}
+}
+
+func TestSetLangErrors(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"xx.yaml": "no.such.message: \"hi\"\n",
+		"yy.yaml": "welcome: \"{{.Path\"\n",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	defer func() { _ = SetLang("", dir) }()
+
+	tests := []struct {
+		lang string
+		want string
+	}{
+		{"fr", `no translation for "fr"`},
+		{"xx", "unknown message IDs: no.such.message"},
+		{"yy", "parsing yy message welcome"},
+	}
+	for _, tt := range tests {
+		err := SetLang(tt.lang, dir)
+		if err == nil || !strings.Contains(err.Error(), tt.want) {
+			t.Errorf("SetLang(%q) error = %v, want %q", tt.lang, err, tt.want)
+		}
+		if Lang() != DefaultLang {
+			t.Errorf("SetLang(%q) switched to %q despite the error", tt.lang, Lang())
+		}
+	}
+}
+
+func TestPlural(t *testing.T) {
+	tests := []struct {
+		projects, files int
+		want            string
+	}{
+		{1, 1, "Found 1 local project with 1 JSONL file"},
+		{2, 5, "Found 2 local projects with 5 JSONL files"},
+	}
+	for _, tt := range tests {
+		got := Text("doctor.local.projects.ok", Args{"Projects": tt.projects, "Files": tt.files})
+		if got != tt.want {
+			t.Errorf("Text() = %q, want %q", got, tt.want)
+		}
+	}
+}
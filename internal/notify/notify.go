@@ -0,0 +1,154 @@
+// Package notify posts a summary of each upload run to a webhook, so backups
+// can be watched from Slack or any endpoint that accepts JSON. Delivery is
+// best effort: callers report a failed notification without failing the run.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/output"
+	"github.com/13rac1/cclogs/internal/uploader"
+)
+
+// Values accepted by notify.on.
+const (
+	OnAlways  = "always"  // Every run
+	OnChanges = "changes" // Runs that uploaded files or failed
+	OnFailure = "failure" // Runs that failed, fully or partially
+)
+
+// Timeout bounds a webhook request, so a slow endpoint cannot hold up a run.
+const Timeout = 10 * time.Second
+
+// Run status values in Payload.Status.
+const (
+	StatusOK      = "ok"
+	StatusPartial = "partial" // Some files failed, the rest were uploaded
+	StatusFailed  = "failed"
+)
+
+// Payload is the JSON document posted for a run. Text is a one-line summary
+// for Slack-style incoming webhooks; the other fields are for everything else.
+type Payload struct {
+	Text          string           `json:"text"`
+	Status        string           `json:"status"`
+	Machine       string           `json:"machine"`
+	Bucket        string           `json:"bucket"`
+	Prefix        string           `json:"prefix"`
+	Uploaded      int              `json:"uploaded"`
+	Skipped       int              `json:"skipped"`
+	Failed        int              `json:"failed"`
+	UploadedBytes int64            `json:"uploaded_bytes"`
+	Redactions    int64            `json:"redactions"`
+	ByPattern     map[string]int64 `json:"redactions_by_pattern,omitempty"`
+	Failures      []Failure        `json:"failures,omitempty"`
+	Error         string           `json:"error,omitempty"`
+}
+
+// Failure is a file that failed to upload.
+type Failure struct {
+	Path  string `json:"path"`
+	Key   string `json:"key"`
+	Error string `json:"error"`
+}
+
+// ShouldNotify reports whether a run with this outcome is sent under the
+// notify.on filter on.
+func ShouldNotify(on string, result *uploader.UploadResult, err error) bool {
+	switch on {
+	case OnFailure:
+		return err != nil
+	case OnChanges:
+		return err != nil || (result != nil && result.Uploaded > 0)
+	default:
+		return true
+	}
+}
+
+// NewPayload summarizes an upload run. result may be nil when the run failed
+// before uploading anything.
+func NewPayload(machine, bucket, prefix string, result *uploader.UploadResult, err error) Payload {
+	p := Payload{
+		Status:  StatusOK,
+		Machine: machine,
+		Bucket:  bucket,
+		Prefix:  prefix,
+	}
+	if result != nil {
+		p.Uploaded = result.Uploaded
+		p.Skipped = result.Skipped
+		p.Failed = len(result.Failures)
+		p.UploadedBytes = result.UploadedBytes
+		if s := result.RedactionStats; s != nil {
+			p.Redactions = s.TotalMatches
+			if len(s.ByPattern) > 0 {
+				p.ByPattern = s.ByPattern
+			}
+		}
+		for _, f := range result.Failures {
+			p.Failures = append(p.Failures, Failure{Path: f.Path, Key: f.Key, Error: f.Err.Error()})
+		}
+	}
+	if err != nil {
+		p.Status = StatusFailed
+		if errors.Is(err, uploader.ErrPartialFailure) {
+			p.Status = StatusPartial
+		}
+		p.Error = err.Error()
+	}
+
+	where := fmt.Sprintf("s3://%s/%s", bucket, prefix)
+	switch p.Status {
+	case StatusOK:
+		p.Text = fmt.Sprintf("cclogs on %s: uploaded %d files (%s) to %s, %d skipped, %d redactions",
+			machine, p.Uploaded, output.FormatSize(p.UploadedBytes), where, p.Skipped, p.Redactions)
+	case StatusPartial:
+		p.Text = fmt.Sprintf("cclogs on %s: uploaded %d files (%s) to %s, %d failed",
+			machine, p.Uploaded, output.FormatSize(p.UploadedBytes), where, p.Failed)
+	default:
+		p.Text = fmt.Sprintf("cclogs on %s: upload to %s failed: %s", machine, where, p.Error)
+	}
+	return p
+}
+
+// Send posts p to webhookURL as JSON. Any response other than 2xx is an error.
+func Send(ctx context.Context, client *http.Client, webhookURL string, p Payload) error {
+	body, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("encoding payload: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhookURL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		// The URL is a credential for most webhooks; keep it out of the message
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("posting to webhook: %w", err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+	return nil
+}
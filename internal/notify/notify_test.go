@@ -0,0 +1,120 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/redactor"
+	"github.com/13rac1/cclogs/internal/uploader"
+)
+
+func TestSend(t *testing.T) {
+	var got map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("request = %s with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decoding payload: %v", err)
+		}
+	}))
+	defer srv.Close()
+
+	stats := redactor.NewStats()
+	stats.TotalMatches = 3
+	stats.ByPattern["EMAIL"] = 3
+	result := &uploader.UploadResult{
+		Uploaded:       2,
+		Skipped:        5,
+		UploadedBytes:  2048,
+		RedactionStats: stats,
+		Failures:       []uploader.FileFailure{{Key: "p/app/a.jsonl", Path: "/logs/app/a.jsonl", Err: errors.New("timeout")}},
+	}
+	err := fmt.Errorf("uploading: %w", uploader.ErrPartialFailure)
+
+	p := NewPayload("laptop", "my-bucket", "claude-code/", result, err)
+	if err := Send(context.Background(), srv.Client(), srv.URL, p); err != nil {
+		t.Fatalf("Send() error = %v", err)
+	}
+
+	want := map[string]any{
+		"status":                "partial",
+		"machine":               "laptop",
+		"bucket":                "my-bucket",
+		"uploaded":              float64(2),
+		"skipped":               float64(5),
+		"failed":                float64(1),
+		"uploaded_bytes":        float64(2048),
+		"redactions":            float64(3),
+		"redactions_by_pattern": map[string]any{"EMAIL": float64(3)},
+		"error":                 "uploading: some files failed to upload",
+	}
+	for k, v := range want {
+		if fmt.Sprint(got[k]) != fmt.Sprint(v) {
+			t.Errorf("payload[%q] = %v, want %v", k, got[k], v)
+		}
+	}
+	failures, _ := got["failures"].([]any)
+	if len(failures) != 1 || failures[0].(map[string]any)["error"] != "timeout" {
+		t.Errorf("payload failures = %v", got["failures"])
+	}
+	if text, _ := got["text"].(string); !strings.Contains(text, "uploaded 2 files (2.0 KB)") {
+		t.Errorf("payload text = %q", text)
+	}
+}
+
+func TestSendErrors(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no", http.StatusForbidden)
+	}))
+	defer srv.Close()
+
+	closed := httptest.NewServer(http.NotFoundHandler())
+	closedURL := closed.URL + "/services/secret-token"
+	closed.Close()
+
+	p := NewPayload("laptop", "my-bucket", "claude-code/", &uploader.UploadResult{}, nil)
+
+	if err := Send(context.Background(), srv.Client(), srv.URL, p); err == nil || !strings.Contains(err.Error(), "403") {
+		t.Errorf("Send() to rejecting server error = %v, want 403", err)
+	}
+	err := Send(context.Background(), http.DefaultClient, closedURL, p)
+	if err == nil {
+		t.Fatal("Send() to closed server succeeded")
+	}
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("error leaks the webhook URL: %v", err)
+	}
+}
+
+func TestShouldNotify(t *testing.T) {
+	changed := &uploader.UploadResult{Uploaded: 1}
+	unchanged := &uploader.UploadResult{Skipped: 4}
+	failed := errors.New("boom")
+
+	tests := []struct {
+		on     string
+		result *uploader.UploadResult
+		err    error
+		want   bool
+	}{
+		{on: OnAlways, result: unchanged, want: true},
+		{on: OnChanges, result: unchanged, want: false},
+		{on: OnChanges, result: changed, want: true},
+		{on: OnChanges, result: unchanged, err: failed, want: true},
+		{on: OnFailure, result: changed, want: false},
+		{on: OnFailure, err: failed, want: true},
+	}
+
+	for _, tt := range tests {
+		if got := ShouldNotify(tt.on, tt.result, tt.err); got != tt.want {
+			t.Errorf("ShouldNotify(%q, %+v, %v) = %v, want %v", tt.on, tt.result, tt.err, got, tt.want)
+		}
+	}
+}
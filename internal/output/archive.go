@@ -0,0 +1,66 @@
+package output
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+// Archive totals the remote side of a project listing.
+type Archive struct {
+	Projects    int   `json:"projects"`
+	Files       int   `json:"files"`
+	Bytes       int64 `json:"bytes"`
+	Approximate bool  `json:"approximate,omitempty"` // Some manifest entries lack stored sizes
+}
+
+// ArchiveTotals sums the remote counts and sizes of projects.
+func ArchiveTotals(projects []types.Project) Archive {
+	var a Archive
+	for _, p := range projects {
+		if p.RemoteCount == 0 {
+			continue
+		}
+		a.Projects++
+		a.Files += p.RemoteCount
+		a.Bytes += p.RemoteBytes
+		a.Approximate = a.Approximate || p.RemoteApprox
+	}
+	return a
+}
+
+// String formats the totals as a sentence for the table footer.
+func (a Archive) String() string {
+	projects := "projects"
+	if a.Projects == 1 {
+		projects = "project"
+	}
+	files := "files"
+	if a.Files == 1 {
+		files = "file"
+	}
+	s := fmt.Sprintf("Archive: %s across %s %s in %d %s",
+		formatArchiveSize(a.Bytes, a.Approximate), formatThousands(a.Files), files, a.Projects, projects)
+	if a.Approximate {
+		s += " (approximate: older manifest entries lack stored sizes)"
+	}
+	return s
+}
+
+// formatArchiveSize formats a stored size, prefixed with "~" if approximate.
+func formatArchiveSize(bytes int64, approx bool) string {
+	if approx {
+		return "~" + FormatSize(bytes)
+	}
+	return FormatSize(bytes)
+}
+
+// formatThousands formats n with comma thousands separators.
+func formatThousands(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0 && s[i-1] != '-'; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
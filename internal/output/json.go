@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/13rac1/cclogs/internal/config"
 	"github.com/13rac1/cclogs/internal/types"
 )
 
@@ -14,13 +15,16 @@ type JSONOutput struct {
 	Config         ConfigInfo      `json:"config"`
 	LocalProjects  []LocalProject  `json:"localProjects"`
 	RemoteProjects []RemoteProject `json:"remoteProjects"`
+	Archive        Archive         `json:"archive"`
+	Page           *Page           `json:"page,omitempty"` // Set when --limit trimmed the projects
 }
 
 // ConfigInfo holds configuration details for JSON output.
 type ConfigInfo struct {
-	Bucket   string `json:"bucket"`
-	Prefix   string `json:"prefix"`
-	Endpoint string `json:"endpoint,omitempty"`
+	Bucket    string `json:"bucket"`
+	BucketARN string `json:"bucketArn,omitempty"` // Set when bucket is an access point ARN
+	Prefix    string `json:"prefix"`
+	Endpoint  string `json:"endpoint,omitempty"`
 }
 
 // LocalProject represents a local project in JSON output.
@@ -32,18 +36,45 @@ type LocalProject struct {
 
 // RemoteProject represents a remote project in JSON output.
 type RemoteProject struct {
-	Name       string `json:"name"`
-	Prefix     string `json:"prefix"`
-	JSONLCount int    `json:"jsonlCount"`
+	Name        string       `json:"name"`
+	Prefix      string       `json:"prefix"`
+	JSONLCount  int          `json:"jsonlCount"`
+	Bytes       int64        `json:"bytes"`
+	Approximate bool         `json:"approximate,omitempty"`
+	LastUpload  string       `json:"lastUpload,omitempty"` // RFC3339; unset when the manifest predates upload times
+	Files       []RemoteFile `json:"files,omitempty"`      // With list --files
+}
+
+// RemoteFile represents an uploaded log in JSON output.
+type RemoteFile struct {
+	Key  string   `json:"key"`
+	Size int64    `json:"size"`
+	Tags []string `json:"tags"` // Redaction patterns that matched
 }
 
 // PrintJSON formats and prints projects as JSON to stdout.
 func PrintJSON(projects []types.Project, cfg *types.Config) error {
+	return printJSON(projects, projects, nil, cfg)
+}
+
+// PrintJSONPage is PrintJSON listing only limit projects from offset (see
+// Paginate). The document's page field records the window, so a consumer can
+// tell a trimmed listing from a complete one; the archive totals still cover
+// every project.
+func PrintJSONPage(projects []types.Project, cfg *types.Config, offset, limit int) error {
+	shown, page := Paginate(projects, offset, limit)
+	return printJSON(projects, shown, &page, cfg)
+}
+
+// printJSON prints the shown projects with the archive totals of all of them.
+func printJSON(all, shown []types.Project, page *Page, cfg *types.Config) error {
 	output := JSONOutput{
 		GeneratedAt:    time.Now().UTC().Format(time.RFC3339),
 		Config:         buildConfigInfo(cfg),
-		LocalProjects:  buildLocalProjects(projects),
-		RemoteProjects: buildRemoteProjects(projects),
+		LocalProjects:  buildLocalProjects(shown),
+		RemoteProjects: buildRemoteProjects(shown),
+		Archive:        ArchiveTotals(all),
+		Page:           page,
 	}
 
 	data, err := json.MarshalIndent(output, "", "  ")
@@ -56,12 +87,18 @@ func PrintJSON(projects []types.Project, cfg *types.Config) error {
 }
 
 // buildConfigInfo extracts config information for JSON output.
+// Access point ARNs are shown by name, with the full ARN alongside.
 func buildConfigInfo(cfg *types.Config) ConfigInfo {
-	return ConfigInfo{
+	info := ConfigInfo{
 		Bucket:   cfg.S3.Bucket,
 		Prefix:   cfg.S3.Prefix,
 		Endpoint: cfg.S3.Endpoint,
 	}
+	if ap, ok := config.ParseAccessPointARN(cfg.S3.Bucket); ok {
+		info.Bucket = ap.Name
+		info.BucketARN = cfg.S3.Bucket
+	}
+	return info
 }
 
 // buildLocalProjects extracts local projects from the merged project list.
@@ -87,11 +124,24 @@ func buildRemoteProjects(projects []types.Project) []RemoteProject {
 
 	for _, p := range projects {
 		if p.RemoteCount > 0 {
-			remote = append(remote, RemoteProject{
-				Name:       p.Name,
-				Prefix:     p.RemotePath,
-				JSONLCount: p.RemoteCount,
-			})
+			rp := RemoteProject{
+				Name:        p.Name,
+				Prefix:      p.RemotePath,
+				JSONLCount:  p.RemoteCount,
+				Bytes:       p.RemoteBytes,
+				Approximate: p.RemoteApprox,
+			}
+			if !p.RemoteLastUpload.IsZero() {
+				rp.LastUpload = p.RemoteLastUpload.UTC().Format(time.RFC3339)
+			}
+			for _, f := range p.RemoteFiles {
+				tags := f.Tags
+				if tags == nil {
+					tags = []string{}
+				}
+				rp.Files = append(rp.Files, RemoteFile{Key: f.Key, Size: f.Size, Tags: tags})
+			}
+			remote = append(remote, rp)
 		}
 	}
 
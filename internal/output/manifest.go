@@ -0,0 +1,71 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/olekukonko/tablewriter"
+)
+
+// PrintManifestProjects prints one row per project of a manifest, followed by
+// the totals.
+func PrintManifestProjects(projects []manifest.ProjectSummary) {
+	if len(projects) == 0 {
+		fmt.Fprintln(Human(), "No entries.")
+		return
+	}
+
+	table := tablewriter.NewWriter(Human())
+	table.Header("Project", "Files", "Source", "Stored", "Newest", "Machines")
+
+	var total manifest.Usage
+	var source int64
+	for _, p := range projects {
+		table.Append(p.Project, formatThousands(p.Files), FormatSize(p.SourceBytes),
+			formatArchiveSize(p.Bytes, p.Approximate), formatDate(p.Newest), strings.Join(p.Machines, ", "))
+		total.Add(p.Usage)
+		source += p.SourceBytes
+	}
+	table.Render()
+
+	fmt.Fprintf(Human(), "Total: %s files in %d projects, %s source, %s stored\n",
+		formatThousands(total.Files), len(projects), FormatSize(source), formatArchiveSize(total.Bytes, total.Approximate))
+}
+
+// PrintManifestFiles prints the entries of project, by key.
+func PrintManifestFiles(m *manifest.Manifest, prefix, project string) {
+	var keys []string
+	for key := range m.Files {
+		if m.Project(key, prefix) == project {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	table := tablewriter.NewWriter(Human())
+	table.Header("Key", "Source", "Stored", "Modified", "Codec", "Verified")
+	for _, key := range keys {
+		e := m.Files[key]
+		stored := "-"
+		if e.UploadedSize > 0 {
+			stored = FormatSize(e.UploadedSize)
+		}
+		codec := e.Codec
+		if codec == "" {
+			codec = "-"
+		}
+		table.Append(strings.TrimPrefix(key, prefix), FormatSize(e.Size), stored, formatDate(e.Mtime), codec, formatDate(e.VerifiedAt))
+	}
+	table.Render()
+}
+
+// formatDate formats t as a local date and time, or "-" if unset.
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format("2006-01-02 15:04")
+}
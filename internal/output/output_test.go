@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"io"
 	"os"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
 	"time"
@@ -252,7 +254,7 @@ func TestPrintProjects(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			output := captureStdout(func() {
-				PrintProjects(tt.projects)
+				PrintProjects(tt.projects, false)
 			})
 
 			for _, want := range tt.contains {
@@ -285,6 +287,29 @@ func TestFormatCount(t *testing.T) {
 	}
 }
 
+func TestFormatAge(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		t    time.Time
+		want string
+	}{
+		{time.Time{}, "-"},
+		{now.Add(-30 * time.Second), "just now"},
+		{now.Add(-5 * time.Minute), "5m ago"},
+		{now.Add(-2 * time.Hour), "2h ago"},
+		{now.AddDate(0, 0, -3), "3d ago"},
+		{now.AddDate(0, 0, -185), "6mo ago"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := formatAge(tt.t, now); got != tt.want {
+				t.Errorf("formatAge(%v) = %q, want %q", tt.t, got, tt.want)
+			}
+		})
+	}
+}
+
 func TestDetermineStatus(t *testing.T) {
 	tests := []struct {
 		name        string
@@ -352,11 +377,12 @@ func TestPrintJSON(t *testing.T) {
 			name: "projects with local and remote",
 			projects: []types.Project{
 				{
-					Name:        "test-project",
-					LocalPath:   "/path/to/test-project",
-					LocalCount:  5,
-					RemotePath:  "claude-code/test-project/",
-					RemoteCount: 5,
+					Name:             "test-project",
+					LocalPath:        "/path/to/test-project",
+					LocalCount:       5,
+					RemotePath:       "claude-code/test-project/",
+					RemoteCount:      5,
+					RemoteLastUpload: time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC),
 				},
 			},
 			cfg: &types.Config{
@@ -417,6 +443,9 @@ func TestPrintJSON(t *testing.T) {
 				if remote.JSONLCount != 5 {
 					t.Errorf("remote.jsonlCount = %d, want %d", remote.JSONLCount, 5)
 				}
+				if remote.LastUpload != "2025-03-01T12:00:00Z" {
+					t.Errorf("remote.lastUpload = %q, want %q", remote.LastUpload, "2025-03-01T12:00:00Z")
+				}
 			},
 		},
 		{
@@ -663,6 +692,32 @@ func TestPrintJSON_IndentedOutput(t *testing.T) {
 	}
 }
 
+func TestBuildConfigInfo_AccessPoint(t *testing.T) {
+	const arn = "arn:aws:s3:us-west-2:123456789012:accesspoint/logs-ap"
+
+	tests := []struct {
+		name    string
+		bucket  string
+		wantBkt string
+		wantARN string
+	}{
+		{name: "bucket name", bucket: "test-bucket", wantBkt: "test-bucket"},
+		{name: "access point ARN", bucket: arn, wantBkt: "logs-ap", wantARN: arn},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := buildConfigInfo(&types.Config{S3: types.S3Config{Bucket: tt.bucket}})
+			if info.Bucket != tt.wantBkt {
+				t.Errorf("Bucket = %q, want %q", info.Bucket, tt.wantBkt)
+			}
+			if info.BucketARN != tt.wantARN {
+				t.Errorf("BucketARN = %q, want %q", info.BucketARN, tt.wantARN)
+			}
+		})
+	}
+}
+
 // captureStdout captures os.Stdout output from the given function.
 func captureStdout(f func()) string {
 	old := os.Stdout
@@ -678,3 +733,234 @@ func captureStdout(f func()) string {
 	io.Copy(&buf, r)
 	return buf.String()
 }
+
+func TestArchiveTotals(t *testing.T) {
+	tests := []struct {
+		name     string
+		projects []types.Project
+		want     string
+	}{
+		{
+			name: "exact",
+			projects: []types.Project{
+				{Name: "a", RemoteCount: 12000, RemoteBytes: 3 << 30},
+				{Name: "b", RemoteCount: 304, RemoteBytes: 1 << 29},
+				{Name: "local", LocalCount: 4},
+			},
+			want: "Archive: 3.5 GB across 12,304 files in 2 projects",
+		},
+		{
+			name: "mixed old and new entries",
+			projects: []types.Project{
+				{Name: "a", RemoteCount: 1, RemoteBytes: 2048},
+				{Name: "b", RemoteCount: 2, RemoteBytes: 1024, RemoteApprox: true},
+			},
+			want: "Archive: ~3.0 KB across 3 files in 2 projects (approximate: older manifest entries lack stored sizes)",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ArchiveTotals(tt.projects).String(); got != tt.want {
+				t.Errorf("ArchiveTotals() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintProjectsVerbose(t *testing.T) {
+	projects := []types.Project{
+		{Name: "project-a", LocalCount: 2, RemoteCount: 2, RemoteBytes: 5 << 20, RemoteLastUpload: time.Now().Add(-2 * time.Hour)},
+		{Name: "project-b", LocalCount: 1},
+	}
+	output := captureStdout(func() {
+		PrintProjects(projects, true)
+	})
+	for _, want := range []string{"SIZE", "5.0 MB", "LAST UPLOAD", "2h ago", "Archive: 5.0 MB across 2 files in 1 project"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("output missing expected string %q\nGot:\n%s", want, output)
+		}
+	}
+}
+
+func TestFormatThousands(t *testing.T) {
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "0"},
+		{999, "999"},
+		{1000, "1,000"},
+		{12304, "12,304"},
+		{1234567, "1,234,567"},
+	}
+	for _, tt := range tests {
+		if got := formatThousands(tt.n); got != tt.want {
+			t.Errorf("formatThousands(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	items := []int{0, 1, 2, 3, 4, 5, 6}
+
+	tests := []struct {
+		name          string
+		offset, limit int
+		want          []int
+		wantPage      Page
+		wantString    string
+	}{
+		{"no limit", 0, 0, items, Page{Total: 7, Shown: 7}, "Showing 1-7 of 7"},
+		{"first page", 0, 3, []int{0, 1, 2}, Page{Limit: 3, Total: 7, Shown: 3, Truncated: true}, "Showing 1-3 of 7; next page: --offset 3"},
+		{"middle page", 3, 3, []int{3, 4, 5}, Page{Offset: 3, Limit: 3, Total: 7, Shown: 3, Truncated: true}, "Showing 4-6 of 7; next page: --offset 6"},
+		{"last page", 6, 3, []int{6}, Page{Offset: 6, Limit: 3, Total: 7, Shown: 1, Truncated: true}, "Showing 7-7 of 7"},
+		{"offset only", 5, 0, []int{5, 6}, Page{Offset: 5, Total: 7, Shown: 2, Truncated: true}, "Showing 6-7 of 7"},
+		{"past the end", 9, 3, []int{}, Page{Offset: 9, Limit: 3, Total: 7, Truncated: true}, "Nothing at offset 9 of 7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, page := Paginate(items, tt.offset, tt.limit)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("Paginate() = %v, want %v", got, tt.want)
+			}
+			if page != tt.wantPage {
+				t.Errorf("page = %+v, want %+v", page, tt.wantPage)
+			}
+			if page.String() != tt.wantString {
+				t.Errorf("String() = %q, want %q", page.String(), tt.wantString)
+			}
+		})
+	}
+
+	// Consecutive pages cover every item once
+	var seen []int
+	for offset := 0; offset < len(items); offset += 2 {
+		got, _ := Paginate(items, offset, 2)
+		seen = append(seen, got...)
+	}
+	if !slices.Equal(seen, items) {
+		t.Errorf("pages covered %v, want %v", seen, items)
+	}
+}
+
+func TestPrintJSONPage(t *testing.T) {
+	projects := []types.Project{
+		{Name: "a", LocalPath: "/a", LocalCount: 1},
+		{Name: "b", LocalPath: "/b", LocalCount: 2, RemoteCount: 2, RemoteBytes: 10},
+		{Name: "c", RemoteCount: 3, RemoteBytes: 30},
+	}
+	cfg := &types.Config{S3: types.S3Config{Bucket: "test-bucket", Prefix: "prefix/"}}
+
+	tests := []struct {
+		name          string
+		offset, limit int
+		wantLocal     []string
+		wantRemote    []string
+		wantTruncated bool
+	}{
+		{name: "trimmed", offset: 1, limit: 1, wantLocal: []string{"b"}, wantRemote: []string{"b"}, wantTruncated: true},
+		{name: "limit covers all", limit: 10, wantLocal: []string{"a", "b"}, wantRemote: []string{"b", "c"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := captureStdout(func() {
+				if err := PrintJSONPage(projects, cfg, tt.offset, tt.limit); err != nil {
+					t.Fatalf("PrintJSONPage() error = %v", err)
+				}
+			})
+			var result JSONOutput
+			if err := json.Unmarshal([]byte(out), &result); err != nil {
+				t.Fatalf("invalid JSON: %v", err)
+			}
+			var local, remote []string
+			for _, p := range result.LocalProjects {
+				local = append(local, p.Name)
+			}
+			for _, p := range result.RemoteProjects {
+				remote = append(remote, p.Name)
+			}
+			if !slices.Equal(local, tt.wantLocal) || !slices.Equal(remote, tt.wantRemote) {
+				t.Errorf("projects = %v / %v, want %v / %v", local, remote, tt.wantLocal, tt.wantRemote)
+			}
+			if result.Page == nil || result.Page.Truncated != tt.wantTruncated || result.Page.Total != 3 {
+				t.Errorf("page = %+v, want truncated %v of 3", result.Page, tt.wantTruncated)
+			}
+			// Totals cover every project, not just the page
+			if result.Archive.Files != 5 {
+				t.Errorf("archive files = %d, want 5", result.Archive.Files)
+			}
+		})
+	}
+
+	// Unpaged output has no page field
+	out := captureStdout(func() { _ = PrintJSON(projects, cfg) })
+	if strings.Contains(out, `"page"`) {
+		t.Errorf("PrintJSON() output has a page field:\n%s", out)
+	}
+}
+
+func TestPrintProjectsPage(t *testing.T) {
+	projects := []types.Project{
+		{Name: "a", LocalCount: 1}, {Name: "b", LocalCount: 1}, {Name: "c", LocalCount: 1},
+	}
+	var out bytes.Buffer
+	PrintProjectsPage(&out, projects, false, 0, 2)
+	if strings.Contains(out.String(), "│ c ") || !strings.Contains(out.String(), "Showing 1-2 of 3; next page: --offset 2") {
+		t.Errorf("PrintProjectsPage() output:\n%s", out.String())
+	}
+}
+
+func TestPager(t *testing.T) {
+	if got := pagerCommand(""); !slices.Equal(got, []string{"less", "-R"}) {
+		t.Errorf("pagerCommand(\"\") = %v", got)
+	}
+	if got := pagerCommand("more -s"); !slices.Equal(got, []string{"more", "-s"}) {
+		t.Errorf("pagerCommand() = %v", got)
+	}
+
+	text := []byte("1\n2\n3\n")
+	if needsPager(text, 4) {
+		t.Error("3 lines paged on a 4-row terminal")
+	}
+	if !needsPager(text, 3) {
+		t.Error("3 lines not paged on a 3-row terminal")
+	}
+}
+
+func TestPrintJSONFiles(t *testing.T) {
+	projects := []types.Project{
+		{Name: "app", RemoteCount: 2, RemoteFiles: []types.RemoteFile{
+			{Key: "p/app/a.jsonl", Size: 10},
+			{Key: "p/app/b.jsonl", Size: 20, Tags: []string{"AWS_KEY", "EMAIL"}},
+		}},
+		{Name: "web", RemoteCount: 1},
+	}
+	cfg := &types.Config{S3: types.S3Config{Bucket: "test-bucket", Prefix: "p/"}}
+
+	out := captureStdout(func() {
+		if err := PrintJSON(projects, cfg); err != nil {
+			t.Fatalf("PrintJSON() error = %v", err)
+		}
+	})
+	var result JSONOutput
+	if err := json.Unmarshal([]byte(out), &result); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+
+	want := []RemoteFile{
+		{Key: "p/app/a.jsonl", Size: 10, Tags: []string{}},
+		{Key: "p/app/b.jsonl", Size: 20, Tags: []string{"AWS_KEY", "EMAIL"}},
+	}
+	if got := result.RemoteProjects[0].Files; !reflect.DeepEqual(got, want) {
+		t.Errorf("app files = %+v, want %+v", got, want)
+	}
+	// A file without matches has an empty list, not a missing one
+	if !strings.Contains(out, `"tags": []`) {
+		t.Errorf("output has no empty tags list:\n%s", out)
+	}
+	// Without --files there is no files field
+	if result.RemoteProjects[1].Files != nil || strings.Count(out, `"files": [`) != 1 {
+		t.Errorf("web project lists files:\n%s", out)
+	}
+}
@@ -0,0 +1,104 @@
+package output
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// Page describes the window of a listing that was shown: up to Limit items
+// starting at Offset, out of Total. A zero Limit means no limit.
+type Page struct {
+	Offset    int  `json:"offset"`
+	Limit     int  `json:"limit,omitempty"`
+	Total     int  `json:"total"`
+	Shown     int  `json:"shown"`
+	Truncated bool `json:"truncated"` // Items before or after the window were left out
+}
+
+// Paginate returns the items in the window of limit items starting at
+// offset. Listings are sorted before paging, so consecutive offsets never
+// repeat or skip an item.
+func Paginate[T any](items []T, offset, limit int) ([]T, Page) {
+	page := Page{Offset: offset, Limit: limit, Total: len(items)}
+	start := min(max(offset, 0), len(items))
+	end := len(items)
+	if limit > 0 {
+		end = min(start+limit, len(items))
+	}
+	page.Shown = end - start
+	page.Truncated = page.Shown < page.Total
+	return items[start:end], page
+}
+
+// String describes the window for a table footer, e.g. "Showing 51-100 of
+// 312; next page: --offset 100".
+func (p Page) String() string {
+	if p.Shown == 0 {
+		return fmt.Sprintf("Nothing at offset %d of %d", p.Offset, p.Total)
+	}
+	s := fmt.Sprintf("Showing %d-%d of %d", p.Offset+1, p.Offset+p.Shown, p.Total)
+	if next := p.Offset + p.Shown; next < p.Total {
+		s += fmt.Sprintf("; next page: --offset %d", next)
+	}
+	return s
+}
+
+// defaultPager is run when $PAGER is unset or empty.
+const defaultPager = "less -R"
+
+// Paged writes what render produces to Human, through $PAGER when enabled,
+// stdout is a terminal, and the text is taller than it. If the pager cannot
+// be started the text is written directly.
+func Paged(enabled bool, render func(io.Writer)) {
+	if !enabled || Machine() || !isTerminal(os.Stdout) {
+		render(Human())
+		return
+	}
+	height, ok := terminalHeight(os.Stdout)
+	if !ok {
+		render(os.Stdout)
+		return
+	}
+
+	var buf bytes.Buffer
+	render(&buf)
+	if !needsPager(buf.Bytes(), height) {
+		_, _ = os.Stdout.Write(buf.Bytes())
+		return
+	}
+
+	args := pagerCommand(os.Getenv("PAGER"))
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(buf.Bytes())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		_, _ = os.Stdout.Write(buf.Bytes())
+		return
+	}
+	_ = cmd.Wait()
+}
+
+// needsPager reports whether text has more lines than fit on a terminal of
+// height rows, leaving one for the prompt.
+func needsPager(text []byte, height int) bool {
+	return height > 0 && bytes.Count(text, []byte("\n")) >= height
+}
+
+// pagerCommand splits $PAGER into a command and its arguments.
+func pagerCommand(env string) []string {
+	if args := strings.Fields(env); len(args) > 0 {
+		return args
+	}
+	return strings.Fields(defaultPager)
+}
+
+// isTerminal reports whether f is a character device such as a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,32 @@
+package output
+
+import (
+	"io"
+	"os"
+	"sync/atomic"
+)
+
+// machine is set while a command writes a machine-readable document or
+// stream (--json) to stdout.
+var machine atomic.Bool
+
+// SetMachine turns machine output mode on or off. While it is on, Human
+// returns stderr so stdout carries only the structured output.
+func SetMachine(on bool) {
+	machine.Store(on)
+}
+
+// Machine reports whether machine output mode is on.
+func Machine() bool {
+	return machine.Load()
+}
+
+// Human returns where human-readable text (progress, summaries, tables,
+// hints) goes: stdout, or stderr in machine output mode. It is resolved on
+// each call so redirected os.Stdout and os.Stderr are honored.
+func Human() io.Writer {
+	if machine.Load() {
+		return os.Stderr
+	}
+	return os.Stdout
+}
@@ -4,8 +4,9 @@ package output
 
 import (
 	"fmt"
-	"os"
+	"io"
 	"strconv"
+	"time"
 
 	"github.com/13rac1/cclogs/internal/types"
 	"github.com/olekukonko/tablewriter"
@@ -14,12 +15,12 @@ import (
 // PrintLocalProjects formats and prints local projects as an ASCII table.
 func PrintLocalProjects(projects []types.Project) {
 	if len(projects) == 0 {
-		fmt.Println("No local projects found.")
+		fmt.Fprintln(Human(), "No local projects found.")
 		return
 	}
 
-	fmt.Println("Local Projects")
-	table := tablewriter.NewWriter(os.Stdout)
+	fmt.Fprintln(Human(), "Local Projects")
+	table := tablewriter.NewWriter(Human())
 	table.Header("Project", "JSONL Files")
 
 	for _, p := range projects {
@@ -29,26 +30,77 @@ func PrintLocalProjects(projects []types.Project) {
 	table.Render()
 }
 
-// PrintProjects formats and prints projects with local and remote counts.
-func PrintProjects(projects []types.Project) {
+// PrintProjects formats and prints projects with local and remote counts,
+// followed by the archive totals. verbose adds each project's stored size.
+// Last Upload shows how long ago the project's newest object was uploaded.
+func PrintProjects(projects []types.Project, verbose bool) {
+	PrintProjectsPage(Human(), projects, verbose, 0, 0)
+}
+
+// PrintProjectsPage is PrintProjects writing to w and showing only limit
+// projects from offset (see Paginate), with a footer naming the next page.
+// The archive totals still cover every project.
+func PrintProjectsPage(w io.Writer, projects []types.Project, verbose bool, offset, limit int) {
 	if len(projects) == 0 {
-		fmt.Println("No projects found.")
+		fmt.Fprintln(w, "No projects found.")
 		return
 	}
 
-	fmt.Println("Projects")
-	table := tablewriter.NewWriter(os.Stdout)
-	table.Header("Project", "Local", "Remote", "Status")
+	shown, page := Paginate(projects, offset, limit)
+	fmt.Fprintln(w, "Projects")
+	table := tablewriter.NewWriter(w)
+	if verbose {
+		table.Header("Project", "Local", "Remote", "Size", "Last Upload", "Status")
+	} else {
+		table.Header("Project", "Local", "Remote", "Last Upload", "Status")
+	}
 
-	for _, p := range projects {
+	now := time.Now()
+	for _, p := range shown {
 		local := formatCount(p.LocalCount)
 		remote := formatCount(p.RemoteCount)
+		last := formatAge(p.RemoteLastUpload, now)
 		status := determineStatus(p.LocalCount, p.RemoteCount)
 
-		table.Append(p.Name, local, remote, status)
+		if verbose {
+			size := "-"
+			if p.RemoteCount > 0 {
+				size = formatArchiveSize(p.RemoteBytes, p.RemoteApprox)
+			}
+			table.Append(p.Name, local, remote, size, last, status)
+		} else {
+			table.Append(p.Name, local, remote, last, status)
+		}
 	}
 
 	table.Render()
+
+	if page.Truncated {
+		fmt.Fprintln(w, page)
+	}
+	if archive := ArchiveTotals(projects); archive.Files > 0 {
+		fmt.Fprintln(w, archive)
+	}
+}
+
+// FormatSize formats a byte count as a human-readable size, e.g. "1.5 MB".
+func FormatSize(bytes int64) string {
+	const (
+		KB = 1024
+		MB = KB * 1024
+		GB = MB * 1024
+	)
+
+	switch {
+	case bytes >= GB:
+		return fmt.Sprintf("%.1f GB", float64(bytes)/GB)
+	case bytes >= MB:
+		return fmt.Sprintf("%.1f MB", float64(bytes)/MB)
+	case bytes >= KB:
+		return fmt.Sprintf("%.1f KB", float64(bytes)/KB)
+	default:
+		return fmt.Sprintf("%d B", bytes)
+	}
 }
 
 // formatCount formats a count for display, using "-" for zero values.
@@ -59,6 +111,27 @@ func formatCount(count int) string {
 	return strconv.Itoa(count)
 }
 
+// formatAge formats the time since t coarsely, e.g. "2h ago", using "-"
+// for a zero time.
+func formatAge(t, now time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm ago", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh ago", int(d/time.Hour))
+	case d < 60*24*time.Hour:
+		return fmt.Sprintf("%dd ago", int(d/(24*time.Hour)))
+	default:
+		return fmt.Sprintf("%dmo ago", int(d/(30*24*time.Hour)))
+	}
+}
+
 // determineStatus determines the sync status based on local and remote counts.
 func determineStatus(localCount, remoteCount int) string {
 	hasLocal := localCount > 0
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin)
+
+package output
+
+import "os"
+
+// terminalHeight is not known on this platform, so output is never paged.
+func terminalHeight(f *os.File) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,19 @@
+//go:build linux || darwin
+
+package output
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// terminalHeight returns the number of rows of the terminal f is attached to.
+func terminalHeight(f *os.File) (int, bool) {
+	var ws struct{ Row, Col, X, Y uint16 }
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 || ws.Row == 0 {
+		return 0, false
+	}
+	return int(ws.Row), true
+}
@@ -0,0 +1,71 @@
+package redactor
+
+import (
+	"fmt"
+	"sort"
+)
+
+// DefaultMaxMatchShare is the share of processed lines a single pattern may
+// match before it is flagged as a likely false-positive storm.
+const DefaultMaxMatchShare = 0.2
+
+// densityJump is the factor by which a pattern's per-line match rate must
+// exceed its historical rate to be flagged.
+const densityJump = 10
+
+// minAnomalyMatches keeps tiny inputs (one line, one match) from being flagged.
+const minAnomalyMatches = 10
+
+// Anomaly flags a pattern whose match rate suggests a broken regex rather
+// than real secrets.
+type Anomaly struct {
+	Pattern string  `json:"pattern"`
+	Matches int64   `json:"matches"`
+	Share   float64 `json:"share"`           // Matches per processed line in this run
+	Usual   float64 `json:"usual,omitempty"` // Matches per line in history, when the jump heuristic fired
+	Reason  string  `json:"reason"`          // "share" or "jump"
+}
+
+// String formats the anomaly for the post-run summary.
+func (a Anomaly) String() string {
+	if a.Reason == "jump" {
+		return fmt.Sprintf("pattern %s matched %.1f%% of lines, %.0f× its usual rate — possible false-positive storm",
+			a.Pattern, a.Share*100, a.Share/a.Usual)
+	}
+	return fmt.Sprintf("pattern %s matched %.0f%% of lines — possible false-positive storm", a.Pattern, a.Share*100)
+}
+
+// DetectAnomalies flags patterns in current that matched more than maxShare
+// of processed lines, or whose per-line rate is an order of magnitude above
+// the rate in history. history may be nil when no earlier counts are known.
+func DetectAnomalies(current, history *Stats, maxShare float64) []Anomaly {
+	if current == nil || current.LinesProcessed == 0 {
+		return nil
+	}
+
+	var anomalies []Anomaly
+	for pattern, count := range current.ByPattern {
+		if count < minAnomalyMatches {
+			continue
+		}
+		share := float64(count) / float64(current.LinesProcessed)
+
+		if maxShare > 0 && share > maxShare {
+			anomalies = append(anomalies, Anomaly{Pattern: pattern, Matches: count, Share: share, Reason: "share"})
+			continue
+		}
+
+		if history == nil || history.LinesProcessed == 0 || history.ByPattern[pattern] == 0 {
+			continue
+		}
+		usual := float64(history.ByPattern[pattern]) / float64(history.LinesProcessed)
+		if share >= usual*densityJump {
+			anomalies = append(anomalies, Anomaly{Pattern: pattern, Matches: count, Share: share, Usual: usual, Reason: "jump"})
+		}
+	}
+
+	sort.Slice(anomalies, func(i, j int) bool {
+		return anomalies[i].Pattern < anomalies[j].Pattern
+	})
+	return anomalies
+}
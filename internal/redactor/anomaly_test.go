@@ -0,0 +1,112 @@
+package redactor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDetectAnomalies(t *testing.T) {
+	stats := func(lines int64, byPattern map[string]int64) *Stats {
+		s := NewStats()
+		s.LinesProcessed = lines
+		for p, c := range byPattern {
+			s.ByPattern[p] = c
+			s.TotalMatches += c
+		}
+		return s
+	}
+
+	tests := []struct {
+		name     string
+		current  *Stats
+		history  *Stats
+		maxShare float64
+		want     []string // "pattern:reason"
+	}{
+		{
+			name:     "normal rates",
+			current:  stats(1000, map[string]int64{"EMAIL": 30, "ENV_SECRET": 5}),
+			maxShare: DefaultMaxMatchShare,
+		},
+		{
+			name:     "share above threshold",
+			current:  stats(1000, map[string]int64{"ENV_SECRET": 480, "EMAIL": 30}),
+			maxShare: DefaultMaxMatchShare,
+			want:     []string{"ENV_SECRET:share"},
+		},
+		{
+			name:     "share exactly at threshold",
+			current:  stats(1000, map[string]int64{"ENV_SECRET": 200}),
+			maxShare: DefaultMaxMatchShare,
+		},
+		{
+			name:     "configured threshold",
+			current:  stats(1000, map[string]int64{"EMAIL": 60}),
+			maxShare: 0.05,
+			want:     []string{"EMAIL:share"},
+		},
+		{
+			name:     "too few matches to judge",
+			current:  stats(3, map[string]int64{"EMAIL": 3}),
+			maxShare: DefaultMaxMatchShare,
+		},
+		{
+			name:     "order of magnitude jump over history",
+			current:  stats(1000, map[string]int64{"ENV_SECRET": 100, "EMAIL": 30}),
+			history:  stats(100000, map[string]int64{"ENV_SECRET": 500, "EMAIL": 3000}),
+			maxShare: DefaultMaxMatchShare,
+			want:     []string{"ENV_SECRET:jump"},
+		},
+		{
+			name:     "increase below jump factor",
+			current:  stats(1000, map[string]int64{"ENV_SECRET": 40}),
+			history:  stats(100000, map[string]int64{"ENV_SECRET": 500}),
+			maxShare: DefaultMaxMatchShare,
+		},
+		{
+			name:     "pattern new to history",
+			current:  stats(1000, map[string]int64{"JWT": 50}),
+			history:  stats(100000, map[string]int64{"EMAIL": 500}),
+			maxShare: DefaultMaxMatchShare,
+		},
+		{
+			name:     "no lines processed",
+			current:  stats(0, nil),
+			maxShare: DefaultMaxMatchShare,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, a := range DetectAnomalies(tt.current, tt.history, tt.maxShare) {
+				got = append(got, a.Pattern+":"+a.Reason)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("anomalies = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAnomaly_String(t *testing.T) {
+	tests := []struct {
+		anomaly Anomaly
+		want    string
+	}{
+		{
+			anomaly: Anomaly{Pattern: "ENV_SECRET", Matches: 480, Share: 0.48, Reason: "share"},
+			want:    "pattern ENV_SECRET matched 48% of lines — possible false-positive storm",
+		},
+		{
+			anomaly: Anomaly{Pattern: "ENV_SECRET", Matches: 100, Share: 0.1, Usual: 0.005, Reason: "jump"},
+			want:    "pattern ENV_SECRET matched 10.0% of lines, 20× its usual rate — possible false-positive storm",
+		},
+	}
+
+	for _, tt := range tests {
+		if got := tt.anomaly.String(); got != tt.want {
+			t.Errorf("String() = %q, want %q", got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package redactor
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"unicode/utf16"
+)
+
+var canonicalJSON bool
+
+// SetCanonicalJSON makes redacted JSON lines be written in the canonical
+// form of RFC 8785: object keys sorted by UTF-16 code units, numbers in
+// their shortest form, and only the escapes JSON requires. Two machines
+// redacting the same file then produce identical bytes. It must be called
+// before any redaction starts.
+func SetCanonicalJSON(on bool) {
+	canonicalJSON = on
+}
+
+// encodeLine encodes a redacted JSON value as a single line.
+func encodeLine(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if canonicalJSON {
+		if err := writeCanonical(&buf, v); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	// Use encoder with HTML escaping disabled to preserve <TAG-xxx> format
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	// Remove trailing newline added by Encode
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// jsonWriter is what encoded lines are written to: a bytes.Buffer, or a
+// bufio.Writer over the output of a spilled line.
+type jsonWriter interface {
+	io.Writer
+	io.ByteWriter
+	io.StringWriter
+	WriteRune(r rune) (int, error)
+}
+
+// writeCanonical writes v, a value decoded by encoding/json, in canonical
+// form.
+func writeCanonical(buf jsonWriter, v any) error {
+	switch val := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		buf.WriteString(strconv.FormatBool(val))
+	case float64:
+		if val == 0 {
+			buf.WriteString("0") // Including -0
+			return nil
+		}
+		// encoding/json formats floats as ECMAScript does, which RFC 8785 follows
+		b, err := json.Marshal(val)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+	case string:
+		writeCanonicalString(buf, val)
+	case []any:
+		buf.WriteByte('[')
+		for i, elem := range val {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeCanonical(buf, elem); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]any:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return slices.Compare(utf16.Encode([]rune(keys[i])), utf16.Encode([]rune(keys[j]))) < 0
+		})
+
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			writeCanonicalString(buf, k)
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, val[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("cannot encode %T as canonical JSON", v)
+	}
+	return nil
+}
+
+// writeCanonicalString writes s as a JSON string, escaping only quotes,
+// backslashes, and control characters.
+func writeCanonicalString(buf jsonWriter, s string) {
+	buf.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			buf.WriteString(`\"`)
+		case '\\':
+			buf.WriteString(`\\`)
+		case '\b':
+			buf.WriteString(`\b`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\t':
+			buf.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(buf, `\u%04x`, r)
+			} else {
+				buf.WriteRune(r)
+			}
+		}
+	}
+	buf.WriteByte('"')
+}
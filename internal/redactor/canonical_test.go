@@ -0,0 +1,99 @@
+package redactor
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	SetCanonicalJSON(true)
+	defer SetCanonicalJSON(false)
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{
+			name:  "keys sorted at every level",
+			input: `{"z":1,"a":{"y":[{"b":2,"a":1}],"x":null},"m":true}`,
+			want:  `{"a":{"x":null,"y":[{"a":1,"b":2}]},"m":true,"z":1}`,
+		},
+		{
+			name:  "keys sorted by UTF-16 code units",
+			input: `{"דּ":1,"😀":2,"a":3}`,
+			want:  `{"a":3,"😀":2,"דּ":1}`,
+		},
+		{
+			name:  "numbers in shortest form",
+			input: `{"a":1.50,"b":-0,"c":1e21,"d":0.000001,"e":1E-7,"f":100}`,
+			want:  `{"a":1.5,"b":0,"c":1e+21,"d":0.000001,"e":1e-7,"f":100}`,
+		},
+		{
+			name:  "only required escapes",
+			input: `{"s":"<a> &   \"q\" \\ \t \u001f é"}`,
+			want:  "{\"s\":\"<a> &   \\\"q\\\" \\\\ \\t \\u001f é\"}",
+		},
+		{
+			name:  "placeholders kept",
+			input: `{"user":"alice@company.com"}`,
+			want:  `{"user":"<EMAIL-22c9943ba6fd>"}`,
+		},
+		{
+			name:  "non-JSON line unaffected",
+			input: `not json {"b":1,"a":2}`,
+			want:  `not json {"b":1,"a":2}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := redactLine([]byte(tt.input))
+			if err != nil {
+				t.Fatalf("redactLine() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("redactLine() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanonicalJSONOnlyReorders(t *testing.T) {
+	lines := []string{
+		`{"type":"user","message":{"role":"user","content":[{"type":"text","text":"hi"}]},"n":3.25,"ok":false}`,
+		`{"ok":false,"n":3.25,"message":{"content":[{"text":"hi","type":"text"}],"role":"user"},"type":"user"}`,
+	}
+
+	var outputs []string
+	for _, line := range lines {
+		SetCanonicalJSON(false)
+		plain, err := redactLine([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		SetCanonicalJSON(true)
+		canonical, err := redactLine([]byte(line))
+		SetCanonicalJSON(false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var want, got any
+		if err := json.Unmarshal(plain, &want); err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(canonical, &got); err != nil {
+			t.Fatalf("canonical output %s is not JSON: %v", canonical, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("canonical output %s differs from %s in more than order", canonical, plain)
+		}
+		outputs = append(outputs, string(canonical))
+	}
+
+	if outputs[0] != outputs[1] {
+		t.Errorf("same record in different key order encoded differently:\n%s\n%s", outputs[0], outputs[1])
+	}
+}
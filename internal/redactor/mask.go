@@ -0,0 +1,57 @@
+package redactor
+
+import (
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Redaction modes.
+const (
+	ModePlaceholder = "placeholder" // <TAG-hash> placeholders (default)
+	ModeMask        = "mask"        // Each character replaced by the mask char
+)
+
+// DefaultMaskChar replaces matched characters in mask mode.
+const DefaultMaskChar = "*"
+
+var (
+	mode     = ModePlaceholder
+	maskChar = '*'
+	maskKeep = ""
+)
+
+// SetMode selects how matches are replaced. In mask mode every character of
+// a match becomes maskChar, except characters in keep (e.g. "@." to keep the
+// shape of emails), so replacements are as long as the original. An empty
+// mode restores placeholders. It must be called before any redaction starts.
+func SetMode(m, char, keep string) error {
+	switch m {
+	case "", ModePlaceholder:
+		mode = ModePlaceholder
+		return nil
+	case ModeMask:
+	default:
+		return fmt.Errorf("unknown mode %q (known: %s, %s)", m, ModePlaceholder, ModeMask)
+	}
+
+	if char == "" {
+		char = DefaultMaskChar
+	}
+	r, size := utf8.DecodeRuneInString(char)
+	if size != len(char) || r == utf8.RuneError {
+		return fmt.Errorf("mask char must be a single character, got %q", char)
+	}
+	mode, maskChar, maskKeep = ModeMask, r, keep
+	return nil
+}
+
+// mask replaces each character of s not in maskKeep with maskChar.
+func mask(s string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(maskKeep, r) {
+			return r
+		}
+		return maskChar
+	}, s)
+}
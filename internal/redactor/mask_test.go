@@ -0,0 +1,86 @@
+package redactor
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestMaskMode(t *testing.T) {
+	t.Cleanup(func() { _ = SetMode("", "", "") })
+
+	tests := []struct {
+		name  string
+		char  string
+		keep  string
+		input string
+		want  string
+	}{
+		{"email", "", "", "mail john.doe@company.com now", "mail ******************** now"},
+		{"email keeps separators", "", "@.", "mail john.doe@company.com now", "mail ****.***@*******.*** now"},
+		{"custom mask char", "#", "@.", "a@b.io", "#@#.##"},
+		{"ip keeps dots", "", ".", "host 192.168.1.10 up", "host ***.***.*.** up"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetMode(ModeMask, tt.char, tt.keep); err != nil {
+				t.Fatalf("SetMode() error = %v", err)
+			}
+			got := Redact(tt.input)
+			if got != tt.want {
+				t.Errorf("Redact(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+			if utf8.RuneCountInString(got) != utf8.RuneCountInString(tt.input) {
+				t.Errorf("masked length %d, want %d", utf8.RuneCountInString(got), utf8.RuneCountInString(tt.input))
+			}
+		})
+	}
+}
+
+func TestMaskModeStats(t *testing.T) {
+	t.Cleanup(func() { _ = SetMode("", "", "") })
+	if err := SetMode(ModeMask, "", "@."); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := NewStats()
+	got := redactWithStats("contact alice@company.org", stats, nil)
+	if want := "contact *****@*******.***"; got != want {
+		t.Errorf("redactWithStats() = %q, want %q", got, want)
+	}
+	if stats.ByPattern["EMAIL"] != 1 {
+		t.Errorf("EMAIL matches = %d, want 1", stats.ByPattern["EMAIL"])
+	}
+}
+
+func TestSetMode(t *testing.T) {
+	t.Cleanup(func() { _ = SetMode("", "", "") })
+
+	tests := []struct {
+		mode, char string
+		wantErr    string
+	}{
+		{ModePlaceholder, "", ""},
+		{ModeMask, "", ""},
+		{ModeMask, "x", ""},
+		{ModeMask, "ab", "single character"},
+		{"hash", "", "unknown mode"},
+	}
+	for _, tt := range tests {
+		err := SetMode(tt.mode, tt.char, "")
+		if tt.wantErr == "" && err != nil {
+			t.Errorf("SetMode(%q, %q) error = %v", tt.mode, tt.char, err)
+		}
+		if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("SetMode(%q, %q) error = %v, want %q", tt.mode, tt.char, err, tt.wantErr)
+		}
+	}
+
+	if err := SetMode(ModePlaceholder, "", ""); err != nil {
+		t.Fatal(err)
+	}
+	if got := Redact("a@b.io"); !strings.HasPrefix(got, "<EMAIL-") {
+		t.Errorf("Redact() after placeholder mode = %q, want placeholder", got)
+	}
+}
@@ -12,7 +12,6 @@ package redactor
 
 import (
 	"bufio"
-	"bytes"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
@@ -49,6 +48,8 @@ var patterns = []pattern{
 	{"SLACK", regexp.MustCompile(`(?i)\bxox[baprs]-[A-Za-z0-9-]{10,}\b`)},
 	{"NPM", regexp.MustCompile(`(?i)\bnpm_[A-Za-z0-9]{36}\b`)},
 	{"GCP_API", regexp.MustCompile(`\bAIza[0-9A-Za-z_-]{35}\b`)},
+	{"GOOGLE_OAUTH_SECRET", regexp.MustCompile(`\bGOCSPX-[A-Za-z0-9_-]{28}`)},
+	{"GOOGLE_REFRESH_TOKEN", regexp.MustCompile(`\b1//[A-Za-z0-9_-]{30,}`)},
 	{"SENDGRID", regexp.MustCompile(`\bSG\.[A-Za-z0-9_-]{20,}\.[A-Za-z0-9_-]{40,}\b`)},
 	{"TWILIO_SID", regexp.MustCompile(`(?i)\b(AC|SK)[a-z0-9]{32}\b`)},
 	{"DIGITALOCEAN", regexp.MustCompile(`(?i)\bdop_v1_[a-f0-9]{64}\b`)},
@@ -83,6 +84,8 @@ var patterns = []pattern{
 
 	// PII patterns
 	{"EMAIL", regexp.MustCompile(`\b[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}\b`)},
+	{"IBAN", regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]{4}){2,7}(?: ?[A-Z0-9]{1,3})?\b`)},
+	{"UK_NINO", regexp.MustCompile(`\b[A-CEGHJ-PR-TW-Z][A-CEGHJ-NPR-TW-Z] ?\d{2} ?\d{2} ?\d{2} ?[A-D]\b`)},
 	{"SSN", regexp.MustCompile(`\b\d{3}[-.\s]?\d{2}[-.\s]?\d{4}\b`)},
 	{"CC", regexp.MustCompile(`\b\d{4}[-\s]\d{4}[-\s]\d{4}[-\s]\d{4}\b`)},
 	{"IP", regexp.MustCompile(`\b(?:(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\.){3}(?:25[0-5]|2[0-4][0-9]|[01]?[0-9][0-9]?)\b`)},
@@ -90,21 +93,85 @@ var patterns = []pattern{
 	{"PHONE_INTL", regexp.MustCompile(`\+[1-9]\d{0,2}[-\s]+\d+(?:[-\s]+\d+)+`)},
 
 	// Generic secret patterns (last, as catch-all)
+	{"ENV_VAR", envVarPattern(DefaultEnvKeywords)},
 	{"ENV_SECRET", regexp.MustCompile(`(?i)\b(password|secret|api_key)\s*[=:]\s*["']?[^\s"']{8,}`)},
 	{"HEX_SECRET", regexp.MustCompile(`(?i)\b(key|secret)\s*[=:]\s*["']?[a-f0-9]{32,}`)},
 	// BASE64_SECRET pattern removed: too broad, matched file paths
 	// preDecodeAndRedact handles actual base64-encoded secrets
 }
 
+// DefaultEnvKeywords are the env var name suffixes whose KEY=VALUE lines are
+// redacted by ENV_VAR. Credentials in *_URL values are caught by URL_CREDS.
+var DefaultEnvKeywords = []string{"KEY", "TOKEN", "SECRET", "PASSWORD", "PASSWD", "PASS", "PWD", "CREDENTIALS", "AUTH"}
+
+// envValueMin is the shortest value ENV_VAR redacts; shorter values are
+// usually settings like FEATURE_AUTH=false rather than secrets.
+const envValueMin = 8
+
+// envVarPattern matches upper-case KEY=VALUE assignments, as found in pasted
+// .env files, whose last name segment is one of keywords. Quoted values may
+// contain spaces. The value may not start with "<" so already-redacted
+// placeholders are left alone.
+func envVarPattern(keywords []string) *regexp.Regexp {
+	quoted := make([]string, len(keywords))
+	for i, k := range keywords {
+		quoted[i] = regexp.QuoteMeta(strings.ToUpper(k))
+	}
+	n := envValueMin - 1
+	return regexp.MustCompile(fmt.Sprintf(`\b(?:[A-Z0-9]+_)*(?:%s)[ \t]*=[ \t]*(?:"[^"<\n][^"\n]{%d,}"|'[^'<\n][^'\n]{%d,}'|[^\s"'<][^\s"']{%d,})`,
+		strings.Join(quoted, "|"), n, n, n))
+}
+
+// SetEnvKeywords replaces the env var name suffixes redacted by ENV_VAR.
+// An empty list restores DefaultEnvKeywords. It must be called before any
+// redaction starts.
+func SetEnvKeywords(keywords []string) {
+	if len(keywords) == 0 {
+		keywords = DefaultEnvKeywords
+	}
+	for i := range patterns {
+		if patterns[i].tag == "ENV_VAR" {
+			patterns[i].re = envVarPattern(keywords)
+		}
+	}
+}
+
 // skipValues contains values that should not be redacted even if they match a pattern.
 var skipValues = map[string]bool{
 	"127.0.0.1": true, // localhost - nothing to hide
 }
 
+// PatternFingerprint returns a short hash identifying the enabled pattern set.
+// It changes whenever a pattern is added, removed, reordered, or edited, and
+// whenever the allowlist changes.
+func PatternFingerprint() string {
+	h := sha256.New()
+	for _, p := range patterns {
+		if disabled[p.tag] {
+			continue
+		}
+		fmt.Fprintf(h, "%s=%s\n", p.tag, p.re.String())
+	}
+	if disabled["BASE64_SECRET"] {
+		h.Write([]byte("-BASE64_SECRET\n"))
+	}
+	for _, e := range allowEntries {
+		fmt.Fprintf(h, "+allow=%s\n", e)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)[:6])
+}
+
+// base64Pattern finds potential base64 (40+ chars to reduce false positives).
+var base64Pattern = regexp.MustCompile(`[A-Za-z0-9+/]{40,}={0,2}`)
+
 // placeholder generates a deterministic placeholder for a redacted value.
 // Format: <TAG-XXXXXXXXXXXX> where X is the first 6 bytes (48 bits) of SHA-256 hash.
 // Note: 12 bytes (96 bits) recommended if rainbow table attacks are a concern.
+// In mask mode the value is masked instead (see SetMode).
 func placeholder(tag, original string) string {
+	if mode == ModeMask {
+		return mask(original)
+	}
 	hash := sha256.Sum256([]byte(original))
 	return fmt.Sprintf("<%s-%x>", tag, hash[:6])
 }
@@ -112,10 +179,10 @@ func placeholder(tag, original string) string {
 // preDecodeAndRedact attempts to detect and decode common encodings,
 // then recursively redacts the decoded content to catch encoded secrets.
 func preDecodeAndRedact(s string) string {
-	// Pattern for potential base64 (40+ chars to reduce false positives)
-	base64Pattern := regexp.MustCompile(`[A-Za-z0-9+/]{40,}={0,2}`)
-
 	s = base64Pattern.ReplaceAllStringFunc(s, func(m string) string {
+		if disabled["BASE64_SECRET"] {
+			return m
+		}
 		// Attempt base64 decode
 		if decoded, err := base64.StdEncoding.DecodeString(m); err == nil {
 			decodedStr := string(decoded)
@@ -163,15 +230,7 @@ func Redact(s string) string {
 		s = preDecodeAndRedact(s)
 	}
 
-	for _, p := range patterns {
-		s = p.re.ReplaceAllStringFunc(s, func(m string) string {
-			if skipValues[m] {
-				return m
-			}
-			return placeholder(p.tag, m)
-		})
-	}
-	return s
+	return applyPatterns(s, placeholder)
 }
 
 // RedactJSON recursively redacts all string values in parsed JSON.
@@ -208,21 +267,7 @@ func redactLine(line []byte) ([]byte, error) {
 		return []byte(Redact(string(line))), nil
 	}
 
-	redacted := RedactJSON(data)
-
-	// Use encoder with HTML escaping disabled to preserve <TAG-xxx> format
-	var buf bytes.Buffer
-	enc := json.NewEncoder(&buf)
-	enc.SetEscapeHTML(false)
-	if err := enc.Encode(redacted); err != nil {
-		return nil, err
-	}
-	// Remove trailing newline added by Encode
-	result := buf.Bytes()
-	if len(result) > 0 && result[len(result)-1] == '\n' {
-		result = result[:len(result)-1]
-	}
-	return result, nil
+	return encodeLine(RedactJSON(data))
 }
 
 // StreamRedact returns an io.Reader that redacts each JSONL line from r.
@@ -247,6 +292,19 @@ func streamRedact(r io.Reader, w io.Writer) error {
 
 	for scanner.Scan() {
 		line := scanner.Bytes()
+		if spills(line) {
+			_, ok, err := spillLine(line, w, RedactJSON)
+			if err != nil {
+				return fmt.Errorf("redacting line: %w", err)
+			}
+			if ok {
+				if _, err := w.Write([]byte("\n")); err != nil {
+					return fmt.Errorf("writing newline: %w", err)
+				}
+				continue
+			}
+		}
+
 		redacted, err := redactLine(line)
 		if err != nil {
 			return fmt.Errorf("redacting line: %w", err)
@@ -274,29 +332,23 @@ func redactWithStats(s string, stats *Stats, debugW io.Writer) string {
 		s = preDecodeAndRedactWithStats(s, stats, debugW)
 	}
 
-	for _, p := range patterns {
-		tag := p.tag // capture for closure
-		s = p.re.ReplaceAllStringFunc(s, func(m string) string {
-			if skipValues[m] {
-				return m
-			}
-			stats.TotalMatches++
-			stats.ByPattern[tag]++
-			redacted := placeholder(tag, m)
-			if debugW != nil {
-				fmt.Fprintf(debugW, "[DEBUG] %s: %q → %q\n", tag, m, redacted)
-			}
-			return redacted
-		})
-	}
-	return s
+	return applyPatterns(s, func(tag, m string) string {
+		stats.TotalMatches++
+		stats.ByPattern[tag]++
+		redacted := placeholder(tag, m)
+		if debugW != nil {
+			fmt.Fprintf(debugW, "[DEBUG] %s: %q → %q\n", tag, m, redacted)
+		}
+		return redacted
+	})
 }
 
 // preDecodeAndRedactWithStats is like preDecodeAndRedact but tracks stats.
 func preDecodeAndRedactWithStats(s string, stats *Stats, debugW io.Writer) string {
-	base64Pattern := regexp.MustCompile(`[A-Za-z0-9+/]{40,}={0,2}`)
-
 	s = base64Pattern.ReplaceAllStringFunc(s, func(m string) string {
+		if disabled["BASE64_SECRET"] {
+			return m
+		}
 		if decoded, err := base64.StdEncoding.DecodeString(m); err == nil {
 			decodedStr := string(decoded)
 			redacted := redactWithStats(decodedStr, stats, debugW)
@@ -368,19 +420,7 @@ func redactLineWithStats(line []byte, stats *Stats, debugW io.Writer) ([]byte, e
 		return []byte(redactWithStats(string(line), stats, debugW)), nil
 	}
 
-	redacted := RedactJSONWithStats(data, stats, debugW)
-
-	var buf bytes.Buffer
-	enc := json.NewEncoder(&buf)
-	enc.SetEscapeHTML(false)
-	if err := enc.Encode(redacted); err != nil {
-		return nil, err
-	}
-	result := buf.Bytes()
-	if len(result) > 0 && result[len(result)-1] == '\n' {
-		result = result[:len(result)-1]
-	}
-	return result, nil
+	return encodeLine(RedactJSONWithStats(data, stats, debugW))
 }
 
 // StreamRedactWithStats returns an io.Reader that redacts content and a channel
@@ -397,7 +437,7 @@ func StreamRedactWithStatsDebug(r io.Reader, debugW io.Writer) (io.Reader, <-cha
 
 	go func() {
 		stats := NewStats()
-		err := streamRedactWithStats(r, pw, stats, debugW)
+		err := streamRedactWithStats(r, pw, stats, debugW, redactLineWithStats, true)
 		statsCh <- stats
 		close(statsCh)
 		pw.CloseWithError(err)
@@ -406,17 +446,60 @@ func StreamRedactWithStatsDebug(r io.Reader, debugW io.Writer) (io.Reader, <-cha
 	return pr, statsCh
 }
 
-// streamRedactWithStats performs redaction while tracking statistics.
-func streamRedactWithStats(r io.Reader, w io.Writer, stats *Stats, debugW io.Writer) error {
+// StreamRedactTextWithStatsDebug is like StreamRedactWithStatsDebug for logs
+// that are not JSON lines: each line is redacted as a raw string without
+// attempting to parse it.
+func StreamRedactTextWithStatsDebug(r io.Reader, debugW io.Writer) (io.Reader, <-chan *Stats) {
+	pr, pw := io.Pipe()
+	statsCh := make(chan *Stats, 1)
+
+	go func() {
+		stats := NewStats()
+		err := streamRedactWithStats(r, pw, stats, debugW, redactTextLineWithStats, false)
+		statsCh <- stats
+		close(statsCh)
+		pw.CloseWithError(err)
+	}()
+
+	return pr, statsCh
+}
+
+// redactTextLineWithStats redacts a line of a non-JSON log as a raw string.
+func redactTextLineWithStats(line []byte, stats *Stats, debugW io.Writer) ([]byte, error) {
+	if len(line) == 0 {
+		return line, nil
+	}
+	return []byte(redactWithStats(string(line), stats, debugW)), nil
+}
+
+// streamRedactWithStats redacts r line by line with redactLine while
+// tracking statistics. With isJSON, oversized lines are spilled (see SetSpill).
+func streamRedactWithStats(r io.Reader, w io.Writer, stats *Stats, debugW io.Writer,
+	redactLine func([]byte, *Stats, io.Writer) ([]byte, error), isJSON bool) error {
 	scanner := bufio.NewScanner(r)
 	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+	redactJSON := func(v any) any { return RedactJSONWithStats(v, stats, debugW) }
 
 	for scanner.Scan() {
 		line := scanner.Bytes()
 		stats.LinesProcessed++
 		stats.OriginalBytes += int64(len(line)) + 1 // +1 for newline
 
-		redacted, err := redactLineWithStats(line, stats, debugW)
+		if isJSON && spills(line) {
+			n, ok, err := spillLine(line, w, redactJSON)
+			if err != nil {
+				return fmt.Errorf("redacting line: %w", err)
+			}
+			if ok {
+				stats.RedactedBytes += n + 1
+				if _, err := w.Write([]byte("\n")); err != nil {
+					return fmt.Errorf("writing newline: %w", err)
+				}
+				continue
+			}
+		}
+
+		redacted, err := redactLine(line, stats, debugW)
 		if err != nil {
 			return fmt.Errorf("redacting line: %w", err)
 		}
@@ -0,0 +1,123 @@
+package redactor
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// requiredLiterals lists, per tag, substrings at least one of which occurs in
+// every match of the pattern, in lower case. A pattern is only run on text
+// that contains one of them, so clean text costs a few substring searches
+// instead of a regex scan per pattern. Patterns without an entry, such as the
+// digit-only ones, always run.
+//
+// Combining the patterns into one alternation was measured first and gave no
+// speedup: Go's regexp has no DFA, so the cost of a scan grows with the size
+// of the combined program and matches that of separate scans.
+var requiredLiterals = map[string][]string{
+	"PRIVKEY":              {"-----begin "},
+	"OPENSSH_KEY":          {"-----begin openssh private key-----"},
+	"PUTTY_KEY":            {"putty-user-key-file-"},
+	"GITHUB":               {"ghp_", "gho_", "ghu_", "ghs_", "ghr_"},
+	"GITLAB":               {"glpat-"},
+	"ANTHROPIC":            {"sk-ant-"},
+	"STRIPE":               {"sk_live_", "sk_test_"},
+	"OPENAI":               {"sk-"},
+	"SLACK":                {"xoxb-", "xoxa-", "xoxp-", "xoxr-", "xoxs-"},
+	"NPM":                  {"npm_"},
+	"GCP_API":              {"aiza"},
+	"GOOGLE_OAUTH_SECRET":  {"gocspx-"},
+	"GOOGLE_REFRESH_TOKEN": {"1//"},
+	"SENDGRID":             {"sg."},
+	"TWILIO_SID":           {"ac", "sk"},
+	"DIGITALOCEAN":         {"dop_v1_"},
+	"DOCKER_PAT":           {"dckr_pat_"},
+	"CLOUDFLARE":           {"v1.0-"},
+	"AWS_KEY":              {"akia"},
+	"AWS_SECRET":           {"secret_access_key"},
+	"AZURE_KEY":            {"=="},
+	"MONGO_URL":            {"mongodb://", "mongodb+srv://"},
+	"REDIS_URL":            {"redis://", "rediss://"},
+	"ETH_KEY":              {"key"},
+	"JWT":                  {".ey"},
+	"BEARER":               {"bearer"},
+	"AUTH_TOKEN":           {"auth", "token"},
+	"BASIC_AUTH":           {"basic"},
+	"URL_CREDS":            {"@"},
+	"SSH_URL":              {".git"},
+	"EMAIL":                {"@"},
+	"PHONE_INTL":           {"+"},
+	"ENV_VAR":              {"="},
+	"ENV_SECRET":           {"password", "secret", "api_key"},
+	"HEX_SECRET":           {"key", "secret"},
+}
+
+// screening turns the required-literal screen on. Tests and benchmarks turn
+// it off to compare against running every pattern.
+var screening = true
+
+// applyPatterns runs the enabled patterns over s in order, replacing each
+// match m of the pattern tagged tag with replace(tag, m).
+func applyPatterns(s string, replace func(tag, m string) string) string {
+	folded := foldLower(s)
+	for _, p := range patterns {
+		if disabled[p.tag] {
+			continue
+		}
+		if screening && !containsAny(folded, requiredLiterals[p.tag]) {
+			continue
+		}
+		next := p.re.ReplaceAllStringFunc(s, func(m string) string {
+			span := redactSpan(p.tag, m)
+			if span == "" {
+				return m
+			}
+			return replace(p.tag, span) + m[len(span):]
+		})
+		if next != s {
+			s = next
+			folded = foldLower(s)
+		}
+	}
+	return s
+}
+
+// containsAny reports whether s contains one of literals. No literals means
+// the pattern cannot be screened, so it reports true.
+func containsAny(s string, literals []string) bool {
+	if len(literals) == 0 {
+		return true
+	}
+	for _, lit := range literals {
+		if strings.Contains(s, lit) {
+			return true
+		}
+	}
+	return false
+}
+
+// foldLower lower-cases s for matching against requiredLiterals. Non-ASCII
+// letters that case-insensitive patterns treat as ASCII, such as the Kelvin
+// sign for k, are folded to that letter so they cannot slip past the screen.
+func foldLower(s string) string {
+	for i := 0; i < len(s); i++ {
+		if s[i] >= utf8.RuneSelf {
+			return strings.Map(foldASCII, s)
+		}
+	}
+	return strings.ToLower(s)
+}
+
+// foldASCII maps r to the lower-case ASCII letter in its case-folding orbit,
+// or to its lower case if the orbit has none.
+func foldASCII(r rune) rune {
+	for f := r; ; {
+		if f < utf8.RuneSelf {
+			return unicode.ToLower(f)
+		}
+		if f = unicode.SimpleFold(f); f == r {
+			return unicode.ToLower(r)
+		}
+	}
+}
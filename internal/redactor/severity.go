@@ -0,0 +1,115 @@
+package redactor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Severity levels of redaction patterns, from most to least serious.
+const (
+	SeverityHigh   = "high"   // Credentials that grant access (keys, tokens)
+	SeverityMedium = "medium" // Likely secrets without a known format, and identity numbers
+	SeverityLow    = "low"    // Personal contact and network details
+)
+
+// severityRank orders severities for threshold comparisons.
+var severityRank = map[string]int{SeverityLow: 1, SeverityMedium: 2, SeverityHigh: 3}
+
+// severities classifies each pattern tag. Tags missing here are medium.
+var severities = map[string]string{
+	"PRIVKEY":              SeverityHigh,
+	"OPENSSH_KEY":          SeverityHigh,
+	"PUTTY_KEY":            SeverityHigh,
+	"GITHUB":               SeverityHigh,
+	"GITLAB":               SeverityHigh,
+	"ANTHROPIC":            SeverityHigh,
+	"STRIPE":               SeverityHigh,
+	"OPENAI":               SeverityHigh,
+	"SLACK":                SeverityHigh,
+	"NPM":                  SeverityHigh,
+	"GCP_API":              SeverityHigh,
+	"GOOGLE_OAUTH_SECRET":  SeverityHigh,
+	"GOOGLE_REFRESH_TOKEN": SeverityHigh,
+	"SENDGRID":             SeverityHigh,
+	"DIGITALOCEAN":         SeverityHigh,
+	"DOCKER_PAT":           SeverityHigh,
+	"CLOUDFLARE":           SeverityHigh,
+	"AWS_KEY":              SeverityHigh,
+	"AWS_SECRET":           SeverityHigh,
+	"AZURE_KEY":            SeverityHigh,
+	"MONGO_URL":            SeverityHigh,
+	"REDIS_URL":            SeverityHigh,
+	"ETH_KEY":              SeverityHigh,
+	"JWT":                  SeverityHigh,
+	"BEARER":               SeverityHigh,
+	"BASIC_AUTH":           SeverityHigh,
+	"URL_CREDS":            SeverityHigh,
+	"BASE64_SECRET":        SeverityHigh,
+	"EMAIL":                SeverityLow,
+	"IP":                   SeverityLow,
+	"PHONE_US":             SeverityLow,
+	"PHONE_INTL":           SeverityLow,
+	"SSH_URL":              SeverityLow,
+}
+
+// Severity returns the severity of a pattern tag.
+func Severity(tag string) string {
+	if s, ok := severities[tag]; ok {
+		return s
+	}
+	return SeverityMedium
+}
+
+// ParseSeverity validates a severity name such as a --fail-on-severity value.
+func ParseSeverity(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if _, ok := severityRank[s]; !ok {
+		return "", fmt.Errorf("unknown severity %q (want high, medium, or low)", s)
+	}
+	return s, nil
+}
+
+// CountAtLeast returns the matches of patterns at or above severity min.
+func (s *Stats) CountAtLeast(min string) int64 {
+	var n int64
+	for tag, count := range s.ByPattern {
+		if severityRank[Severity(tag)] >= severityRank[min] {
+			n += count
+		}
+	}
+	return n
+}
+
+// Tags returns the tags of all redaction patterns, sorted.
+func Tags() []string {
+	tags := []string{"BASE64_SECRET"}
+	for _, p := range patterns {
+		tags = append(tags, p.tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// disabled holds the tags turned off with SetDisabledPatterns.
+var disabled = map[string]bool{}
+
+// SetDisabledPatterns turns off the patterns with the given tags, re-enabling
+// all others. It must be called before any redaction starts.
+func SetDisabledPatterns(tags []string) error {
+	known := make(map[string]bool)
+	for _, t := range Tags() {
+		known[t] = true
+	}
+
+	next := make(map[string]bool, len(tags))
+	for _, t := range tags {
+		t = strings.ToUpper(strings.TrimSpace(t))
+		if !known[t] {
+			return fmt.Errorf("unknown pattern %q", t)
+		}
+		next[t] = true
+	}
+	disabled = next
+	return nil
+}
@@ -0,0 +1,69 @@
+package redactor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCountAtLeast(t *testing.T) {
+	s := &Stats{ByPattern: map[string]int64{
+		"PRIVKEY":    1,
+		"AWS_KEY":    2,
+		"ENV_SECRET": 4,
+		"IP":         8,
+	}}
+
+	tests := []struct {
+		min  string
+		want int64
+	}{
+		{SeverityHigh, 3},
+		{SeverityMedium, 7},
+		{SeverityLow, 15},
+	}
+
+	for _, tt := range tests {
+		if got := s.CountAtLeast(tt.min); got != tt.want {
+			t.Errorf("CountAtLeast(%s) = %d, want %d", tt.min, got, tt.want)
+		}
+	}
+}
+
+func TestParseSeverity(t *testing.T) {
+	if got, err := ParseSeverity(" High "); err != nil || got != SeverityHigh {
+		t.Errorf("ParseSeverity(High) = %q, %v; want high", got, err)
+	}
+	if _, err := ParseSeverity("critical"); err == nil {
+		t.Error("ParseSeverity(critical) error = nil, want error")
+	}
+}
+
+func TestSeverity_EveryPatternClassified(t *testing.T) {
+	for _, tag := range Tags() {
+		if _, ok := severityRank[Severity(tag)]; !ok {
+			t.Errorf("Severity(%s) = %q, not a known severity", tag, Severity(tag))
+		}
+	}
+}
+
+func TestSetDisabledPatterns(t *testing.T) {
+	t.Cleanup(func() { _ = SetDisabledPatterns(nil) })
+
+	input := "mail bob@company.com from 10.1.2.3"
+	before := PatternFingerprint()
+
+	if err := SetDisabledPatterns([]string{"ip"}); err != nil {
+		t.Fatal(err)
+	}
+	got := Redact(input)
+	if !strings.Contains(got, "10.1.2.3") || strings.Contains(got, "bob@company.com") {
+		t.Errorf("Redact() = %q, want IP kept and email redacted", got)
+	}
+	if PatternFingerprint() == before {
+		t.Error("PatternFingerprint() unchanged after disabling a pattern")
+	}
+
+	if err := SetDisabledPatterns([]string{"NOPE"}); err == nil {
+		t.Error("SetDisabledPatterns(NOPE) error = nil, want error")
+	}
+}
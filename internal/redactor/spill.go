@@ -0,0 +1,117 @@
+package redactor
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+)
+
+// spillThreshold is the length above which JSON lines are encoded straight
+// into the output (0 disables).
+var spillThreshold int64
+
+// SetSpill makes JSON lines longer than threshold bytes be encoded straight
+// into the output, one object member or array element at a time, instead of
+// being encoded into memory whole. A threshold of 0 disables it. It must be
+// called before any redaction starts.
+func SetSpill(threshold int64) {
+	spillThreshold = threshold
+}
+
+// spills reports whether line is encoded straight into the output.
+func spills(line []byte) bool {
+	return spillThreshold > 0 && int64(len(line)) > spillThreshold
+}
+
+// spillLine redacts a JSON line with redact and encodes it into w through a
+// small buffer, returning the bytes written. ok is false, and nothing is
+// written, if line is not valid JSON.
+func spillLine(line []byte, w io.Writer, redact func(any) any) (n int64, ok bool, err error) {
+	var data any
+	if err := json.Unmarshal(line, &data); err != nil {
+		return 0, false, nil
+	}
+	data = redact(data)
+
+	cw := &countingWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	if canonicalJSON {
+		err = writeCanonical(bw, data)
+	} else {
+		err = writeStreaming(bw, data)
+	}
+	if err == nil {
+		err = bw.Flush()
+	}
+	if err != nil {
+		return cw.n, true, fmt.Errorf("writing redacted line: %w", err)
+	}
+	return cw.n, true, nil
+}
+
+// countingWriter counts the bytes written through it.
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// writeStreaming writes v as encodeLine would without canonical_json, but
+// one object member or array element at a time, so only the largest scalar
+// is ever buffered.
+func writeStreaming(w jsonWriter, v any) error {
+	switch val := v.(type) {
+	case []any:
+		w.WriteByte('[')
+		for i, elem := range val {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := writeStreaming(w, elem); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+	case map[string]any:
+		// encoding/json sorts keys by byte order
+		w.WriteByte('{')
+		for i, k := range slices.Sorted(maps.Keys(val)) {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			if err := writeScalar(w, k); err != nil {
+				return err
+			}
+			w.WriteByte(':')
+			if err := writeStreaming(w, val[k]); err != nil {
+				return err
+			}
+		}
+		w.WriteByte('}')
+	default:
+		return writeScalar(w, v)
+	}
+	return nil
+}
+
+// writeScalar encodes a JSON scalar with HTML escaping disabled, preserving
+// the <TAG-xxx> placeholder format.
+func writeScalar(w jsonWriter, v any) error {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return err
+	}
+	_, err := w.Write(bytes.TrimSuffix(buf.Bytes(), []byte("\n")))
+	return err
+}
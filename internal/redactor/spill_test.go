@@ -0,0 +1,81 @@
+package redactor
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestStreamRedactSpill(t *testing.T) {
+	big := `{"z":"` + strings.Repeat("x", 4096) + `","a":["user@company.com",{"ip":"10.1.2.3","n":1.5}],"html":"<b>&</b>"}`
+	input := big + "\n" + `{"small":"alice@company.com"}` + "\n" + "not json " + strings.Repeat("y", 4096) + "\n"
+
+	for _, canonical := range []bool{false, true} {
+		SetCanonicalJSON(canonical)
+		want, err := io.ReadAll(StreamRedact(strings.NewReader(input)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		SetSpill(1024)
+		got, err := io.ReadAll(StreamRedact(strings.NewReader(input)))
+		if err != nil {
+			t.Fatalf("StreamRedact() with spill error = %v", err)
+		}
+		statsGot, statsCh := StreamRedactWithStats(strings.NewReader(input))
+		withStats, err := io.ReadAll(statsGot)
+		if err != nil {
+			t.Fatalf("StreamRedactWithStats() with spill error = %v", err)
+		}
+		stats := <-statsCh
+		SetSpill(0)
+		SetCanonicalJSON(false)
+
+		if string(got) != string(want) || string(withStats) != string(want) {
+			t.Errorf("canonical=%v: spilled output differs\ngot:  %.200s\nwant: %.200s", canonical, got, want)
+		}
+		if stats.RedactedBytes != int64(len(want)) {
+			t.Errorf("canonical=%v: RedactedBytes = %d, want %d", canonical, stats.RedactedBytes, len(want))
+		}
+		if stats.ByPattern["EMAIL"] != 2 || stats.ByPattern["IP"] != 1 {
+			t.Errorf("canonical=%v: ByPattern = %v", canonical, stats.ByPattern)
+		}
+	}
+}
+
+// chunkWriter records the largest single write it receives.
+type chunkWriter struct {
+	bytes.Buffer
+	largest int
+}
+
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	c.largest = max(c.largest, len(p))
+	return c.Buffer.Write(p)
+}
+
+func TestSpillLineWritesInChunks(t *testing.T) {
+	var elems []string
+	for range 2000 {
+		elems = append(elems, `"user@company.com"`)
+	}
+	line := []byte(`{"a":[` + strings.Join(elems, ",") + `]}`)
+
+	for _, canonical := range []bool{false, true} {
+		SetCanonicalJSON(canonical)
+		var w chunkWriter
+		n, ok, err := spillLine(line, &w, RedactJSON)
+		SetCanonicalJSON(false)
+		if err != nil || !ok {
+			t.Fatalf("canonical=%v: spillLine() = %v, %v", canonical, ok, err)
+		}
+		if n != int64(w.Len()) || n < 20000 {
+			t.Errorf("canonical=%v: spillLine() wrote %d bytes, counted %d", canonical, w.Len(), n)
+		}
+		// The line is copied through a bufio.Writer, never encoded whole
+		if w.largest > 4096 {
+			t.Errorf("canonical=%v: largest write = %d bytes, want at most 4096", canonical, w.largest)
+		}
+	}
+}
@@ -77,14 +77,18 @@ func (s *Stats) String() string {
 	return sb.String()
 }
 
-// PatternSummary returns a sorted list of pattern counts for display.
+// PatternSummary returns a sorted list of pattern counts for display,
+// grouped by severity from high to low.
 func (s *Stats) PatternSummary() []PatternCount {
 	counts := make([]PatternCount, 0, len(s.ByPattern))
 	for pattern, count := range s.ByPattern {
-		counts = append(counts, PatternCount{Pattern: pattern, Count: count})
+		counts = append(counts, PatternCount{Pattern: pattern, Count: count, Severity: Severity(pattern)})
 	}
-	// Sort by count descending, then by pattern name
+	// Sort by severity, then by count descending, then by pattern name
 	sort.Slice(counts, func(i, j int) bool {
+		if ri, rj := severityRank[counts[i].Severity], severityRank[counts[j].Severity]; ri != rj {
+			return ri > rj
+		}
 		if counts[i].Count != counts[j].Count {
 			return counts[i].Count > counts[j].Count
 		}
@@ -95,6 +99,7 @@ func (s *Stats) PatternSummary() []PatternCount {
 
 // PatternCount represents a pattern and its match count.
 type PatternCount struct {
-	Pattern string
-	Count   int64
+	Pattern  string
+	Count    int64
+	Severity string
 }
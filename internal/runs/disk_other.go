@@ -0,0 +1,8 @@
+//go:build !linux && !darwin
+
+package runs
+
+// freeDisk is not implemented on this platform.
+func freeDisk(path string) (int64, bool) {
+	return 0, false
+}
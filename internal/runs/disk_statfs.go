@@ -0,0 +1,15 @@
+//go:build linux || darwin
+
+package runs
+
+import "syscall"
+
+// freeDisk returns the bytes available to unprivileged users on the
+// filesystem containing path.
+func freeDisk(path string) (int64, bool) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return 0, false
+	}
+	return int64(uint64(st.Bavail) * uint64(st.Bsize)), true
+}
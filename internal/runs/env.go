@@ -0,0 +1,39 @@
+package runs
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+// environment captures facts about the host that can explain behavior
+// differences between runs.
+func environment(cfg *types.Config) map[string]string {
+	env := map[string]string{
+		"goos":   runtime.GOOS,
+		"goarch": runtime.GOARCH,
+		"tty":    strconv.FormatBool(isTerminal(os.Stdout)),
+	}
+	if cfg.Identity.Source != "" {
+		env["machine_source"] = cfg.Identity.Source
+	}
+	if cfg.Identity.Hostname != "" {
+		env["hostname"] = cfg.Identity.Hostname
+	}
+	if free, ok := freeDisk(cfg.Local.ProjectsRoot); ok {
+		// Round down to MiB so the value stays readable
+		env["free_disk"] = types.ByteSize(free &^ (1<<20 - 1)).String()
+	}
+	return env
+}
+
+// isTerminal reports whether f is a character device such as a terminal.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
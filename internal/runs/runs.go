@@ -0,0 +1,259 @@
+// Package runs records a receipt for each upload run: the effective options,
+// environment facts, and outcome. Receipts are stored as JSON files so two
+// runs can be compared when investigating why they behaved differently.
+package runs
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/codec"
+	"github.com/13rac1/cclogs/internal/doctor"
+	"github.com/13rac1/cclogs/internal/redactor"
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+// Receipt describes a single run.
+type Receipt struct {
+	ID                  string             `json:"id"`
+	Command             string             `json:"command"`
+	StartedAt           time.Time          `json:"started_at"`
+	FinishedAt          time.Time          `json:"finished_at"`
+	Options             map[string]string  `json:"options"`             // Effective options (secrets masked)
+	OptionsFingerprint  string             `json:"options_fingerprint"` // Hash of Options
+	Env                 map[string]string  `json:"env"`                 // Environment facts (not fingerprinted)
+	Uploaded            int                `json:"uploaded"`
+	Skipped             int                `json:"skipped"`
+	UploadedBytes       int64              `json:"uploaded_bytes"`
+	Failed              int                `json:"failed,omitempty"`
+	Error               string             `json:"error,omitempty"`
+	RedactionAnomalies  []redactor.Anomaly `json:"redaction_anomalies,omitempty"`
+	Preflight           []doctor.Result    `json:"preflight,omitempty"`
+	CompressionEstimate *codec.Estimate    `json:"compression_estimate,omitempty"` // Dry runs only
+}
+
+// Change is one option or environment value that differs between two receipts.
+type Change struct {
+	Key string
+	A   string // Empty if absent from the first receipt
+	B   string // Empty if absent from the second receipt
+}
+
+// NewReceipt starts a receipt for command with the effective options derived
+// from cfg and flags.
+func NewReceipt(command string, cfg *types.Config, flags map[string]string, now time.Time) *Receipt {
+	opts := EffectiveOptions(cfg, flags)
+	return &Receipt{
+		ID:                 now.UTC().Format("20060102T150405.000Z"),
+		Command:            command,
+		StartedAt:          now.UTC(),
+		Options:            opts,
+		OptionsFingerprint: Fingerprint(opts),
+		Env:                environment(cfg),
+	}
+}
+
+// EffectiveOptions flattens the resolved config and flag overrides into
+// key/value pairs. Credentials are masked.
+func EffectiveOptions(cfg *types.Config, flags map[string]string) map[string]string {
+	opts := map[string]string{
+		"local.projects_root":        cfg.Local.ProjectsRoot,
+		"local.machine_id":           cfg.Local.MachineID,
+		"local.extensions":           strings.Join(cfg.Local.Extensions, ","),
+		"local.project_depth":        strconv.Itoa(cfg.Local.ProjectDepth),
+		"local.project_marker":       cfg.Local.ProjectMarker,
+		"s3.bucket":                  cfg.S3.Bucket,
+		"s3.prefix":                  cfg.S3.Prefix,
+		"s3.region":                  cfg.S3.Region,
+		"s3.endpoint":                cfg.S3.Endpoint,
+		"s3.force_path_style":        strconv.FormatBool(cfg.S3.ForcePathStyle),
+		"s3.ca_bundle":               cfg.S3.CABundle,
+		"s3.insecure_skip_verify":    strconv.FormatBool(cfg.S3.InsecureSkipVerify),
+		"s3.sse_c_key":               hide(cfg.S3.SSECKey),
+		"s3.key_layout":              cfg.S3.KeyLayout,
+		"s3.key_template":            cfg.S3.KeyTemplate,
+		"s3.manifest_key":            cfg.S3.ManifestKey,
+		"s3.manifest_history":        strconv.Itoa(cfg.S3.ManifestHistory),
+		"s3.operation_timeout":       cfg.S3.OperationTimeout.String(),
+		"s3.object_lock.mode":        cfg.S3.ObjectLock.Mode,
+		"s3.object_lock.retain_days": strconv.Itoa(cfg.S3.ObjectLock.RetainDays),
+		"upload.part_size":           cfg.Upload.PartSize.String(),
+		"upload.part_concurrency":    strconv.Itoa(cfg.Upload.PartConcurrency),
+		"upload.memory_limit":        cfg.Upload.MemoryLimit.String(),
+		"upload.compress":            cfg.Upload.Compress,
+		"upload.compress_level":      strconv.Itoa(cfg.Upload.CompressLevel),
+		"upload.remote_lock":         strconv.FormatBool(cfg.Upload.RemoteLock),
+		"upload.assumed_throughput":  cfg.Upload.AssumedThroughput.String(),
+		"discovery.concurrency":      strconv.Itoa(cfg.Discovery.Concurrency),
+		"redact.max_match_share":     strconv.FormatFloat(cfg.Redact.MaxMatchShare, 'g', -1, 64),
+		"redact.env_keywords":        strings.Join(cfg.Redact.EnvKeywords, ","),
+		"redact.disable":             strings.Join(cfg.Redact.Disable, ","),
+		"redact.allowlist":           strings.Join(cfg.Redact.Allowlist, ","),
+		"redact.mode":                cfg.Redact.Mode,
+		"redact.mask_char":           cfg.Redact.MaskChar,
+		"redact.mask_keep":           cfg.Redact.MaskKeep,
+		"redact.canonical_json":      strconv.FormatBool(cfg.Redact.CanonicalJSON),
+		"auth.profile":               cfg.Auth.Profile,
+		"auth.access_key_id":         mask(cfg.Auth.AccessKeyID),
+		"auth.secret_access_key":     hide(cfg.Auth.SecretAccessKey),
+		"auth.session_token":         hide(cfg.Auth.SessionToken),
+		"notify.webhook_url":         hide(cfg.Notify.WebhookURL),
+		"notify.on":                  cfg.Notify.On,
+		"telemetry.otlp_endpoint":    cfg.Telemetry.OTLPEndpoint,
+		"redact.patterns":            redactor.PatternFingerprint(),
+	}
+	for k, v := range flags {
+		opts["flag."+k] = v
+	}
+	return opts
+}
+
+// mask hides an access key ID, keeping only enough to tell two values apart.
+func mask(s string) string {
+	if s == "" {
+		return ""
+	}
+	if len(s) <= 8 {
+		return "****"
+	}
+	return s[:4] + "****"
+}
+
+// hide replaces s entirely, for secrets of which no part may be shown.
+func hide(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "****"
+}
+
+// Fingerprint returns a short hash of opts that is independent of map order.
+func Fingerprint(opts map[string]string) string {
+	h := sha256.New()
+	for _, k := range sortedKeys(opts) {
+		fmt.Fprintf(h, "%s=%s\n", k, opts[k])
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)[:6])
+}
+
+// Diff returns the options and environment facts that differ between a and b,
+// sorted by key. Environment keys are prefixed with "env.".
+func Diff(a, b *Receipt) []Change {
+	var changes []Change
+	changes = append(changes, diffMaps(a.Options, b.Options, "")...)
+	changes = append(changes, diffMaps(a.Env, b.Env, "env.")...)
+	return changes
+}
+
+func diffMaps(a, b map[string]string, prefix string) []Change {
+	keys := make(map[string]bool)
+	for k := range a {
+		keys[k] = true
+	}
+	for k := range b {
+		keys[k] = true
+	}
+
+	var changes []Change
+	for k := range keys {
+		if a[k] != b[k] {
+			changes = append(changes, Change{Key: prefix + k, A: a[k], B: b[k]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Save writes r to dir as <id>.json, creating dir if needed.
+func Save(dir string, r *Receipt) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("creating runs directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshaling receipt: %w", err)
+	}
+
+	path := filepath.Join(dir, r.ID+".json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("writing receipt: %w", err)
+	}
+	return nil
+}
+
+// List returns the IDs of stored receipts, oldest first.
+func List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("reading runs directory: %w", err)
+	}
+
+	var ids []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), ".json") {
+			ids = append(ids, strings.TrimSuffix(e.Name(), ".json"))
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// Load reads the receipt whose ID is id or starts with id. "latest" selects
+// the most recent receipt.
+func Load(dir, id string) (*Receipt, error) {
+	ids, err := List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var match string
+	switch {
+	case id == "latest" && len(ids) > 0:
+		match = ids[len(ids)-1]
+	default:
+		for _, candidate := range ids {
+			if !strings.HasPrefix(candidate, id) {
+				continue
+			}
+			if match != "" {
+				return nil, fmt.Errorf("run ID %q is ambiguous", id)
+			}
+			match = candidate
+		}
+	}
+	if match == "" {
+		return nil, fmt.Errorf("run %q not found", id)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, match+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("reading receipt: %w", err)
+	}
+
+	var r Receipt
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("parsing receipt %s: %w", match, err)
+	}
+	return &r, nil
+}
@@ -0,0 +1,126 @@
+package runs
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+func testConfig() *types.Config {
+	return &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: "/tmp"},
+		S3:    types.S3Config{Bucket: "bucket", Prefix: "claude-code/", Region: "us-west-2", KeyLayout: "flat", SSECKey: "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="},
+		Auth: types.AuthConfig{
+			AccessKeyID:     "AKIAEXAMPLEKEY",
+			SecretAccessKey: "super-secret-value",
+			SessionToken:    "FwoGZXIvYXdzEXAMPLETOKEN",
+		},
+		Upload: types.UploadConfig{PartSize: 5 << 20, PartConcurrency: 5, Compress: "none"},
+		Notify: types.NotifyConfig{WebhookURL: "https://hooks.slack.com/services/T000/B000/XXXXSECRET"},
+	}
+}
+
+func TestEffectiveOptionsMasksCredentials(t *testing.T) {
+	opts := EffectiveOptions(testConfig(), nil)
+
+	for _, key := range []string{"auth.secret_access_key", "auth.session_token", "s3.sse_c_key", "notify.webhook_url"} {
+		if opts[key] != "****" {
+			t.Errorf("%s = %q, want ****", key, opts[key])
+		}
+	}
+	// Only the access key ID keeps a prefix, to tell keys apart
+	if got := opts["auth.access_key_id"]; got != "AKIA****" {
+		t.Errorf("auth.access_key_id = %q, want AKIA****", got)
+	}
+	if opts["redact.patterns"] == "" {
+		t.Error("redact.patterns fingerprint missing")
+	}
+}
+
+func TestReceiptsUnderDifferingFlags(t *testing.T) {
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	cfg := testConfig()
+
+	a := NewReceipt("upload", cfg, map[string]string{"dry_run": "false", "no_redact": "false"}, now)
+	b := NewReceipt("upload", cfg, map[string]string{"dry_run": "false", "no_redact": "false"}, now.Add(time.Minute))
+	if a.OptionsFingerprint != b.OptionsFingerprint {
+		t.Errorf("identical options produced fingerprints %s and %s", a.OptionsFingerprint, b.OptionsFingerprint)
+	}
+
+	cfg2 := testConfig()
+	cfg2.Upload.PartConcurrency = 8
+	c := NewReceipt("upload", cfg2, map[string]string{"dry_run": "false", "no_redact": "true"}, now.Add(2*time.Minute))
+	if a.OptionsFingerprint == c.OptionsFingerprint {
+		t.Error("differing options produced the same fingerprint")
+	}
+
+	// Pin environment facts so only option differences are reported
+	c.Env = a.Env
+
+	changes := Diff(a, c)
+	got := make(map[string]Change)
+	for _, ch := range changes {
+		got[ch.Key] = ch
+	}
+	if len(got) != 2 {
+		t.Fatalf("Diff() = %+v, want 2 changes", changes)
+	}
+	if ch := got["flag.no_redact"]; ch.A != "false" || ch.B != "true" {
+		t.Errorf("flag.no_redact change = %+v", ch)
+	}
+	if ch := got["upload.part_concurrency"]; ch.A != "5" || ch.B != "8" {
+		t.Errorf("upload.part_concurrency change = %+v", ch)
+	}
+}
+
+func TestDiffEnvironment(t *testing.T) {
+	a := &Receipt{Env: map[string]string{"goos": "linux", "tty": "true"}}
+	b := &Receipt{Env: map[string]string{"goos": "linux", "tty": "false"}}
+
+	changes := Diff(a, b)
+	if len(changes) != 1 || changes[0].Key != "env.tty" {
+		t.Errorf("Diff() = %+v, want single env.tty change", changes)
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	first := NewReceipt("upload", testConfig(), nil, now)
+	second := NewReceipt("upload", testConfig(), nil, now.Add(time.Hour))
+	second.Uploaded = 3
+	for _, r := range []*Receipt{first, second} {
+		if err := Save(dir, r); err != nil {
+			t.Fatalf("Save failed: %v", err)
+		}
+	}
+
+	ids, err := List(dir)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(ids) != 2 || ids[0] != first.ID {
+		t.Fatalf("List() = %v, want [%s %s]", ids, first.ID, second.ID)
+	}
+
+	latest, err := Load(dir, "latest")
+	if err != nil {
+		t.Fatalf("Load(latest) failed: %v", err)
+	}
+	if latest.ID != second.ID || latest.Uploaded != 3 {
+		t.Errorf("Load(latest) = %s (%d uploaded), want %s (3 uploaded)", latest.ID, latest.Uploaded, second.ID)
+	}
+
+	if _, err := Load(dir, "20250601T1"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("Load(ambiguous prefix) error = %v, want ambiguous", err)
+	}
+	if _, err := Load(dir, "20250601T13"); err != nil {
+		t.Errorf("Load(unique prefix) failed: %v", err)
+	}
+	if _, err := Load(dir, "nope"); err == nil {
+		t.Error("Load(unknown) succeeded, want error")
+	}
+}
@@ -0,0 +1,230 @@
+// Package s3errors classifies S3 API errors into typed errors that carry the
+// context needed to explain them, and produces targeted troubleshooting advice.
+package s3errors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/msg"
+	"github.com/13rac1/cclogs/internal/types"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/smithy-go"
+)
+
+// ErrAccessDenied matches errors, as returned by Wrap, for S3 requests the
+// credentials are not allowed to make.
+var ErrAccessDenied = errors.New("access denied")
+
+// maxClockSkew is the skew S3 tolerates before rejecting signed requests.
+const maxClockSkew = 5 * time.Minute
+
+// SignatureMismatchError indicates S3 rejected a request signature. Common causes
+// are a wrong secret key, a skewed system clock, or endpoint/region quirks.
+type SignatureMismatchError struct {
+	RequestID  string    // S3 request ID for support
+	ServerTime time.Time // Date header from the response (zero if absent)
+	LocalTime  time.Time // Local clock when the error was classified
+	Err        error     // Underlying SDK error
+}
+
+// Error implements error.
+func (e *SignatureMismatchError) Error() string {
+	return fmt.Sprintf("signature does not match: %v", e.Err)
+}
+
+// Unwrap returns the underlying SDK error.
+func (e *SignatureMismatchError) Unwrap() error {
+	return e.Err
+}
+
+// ClockSkew returns local time minus server time. The second result is false
+// when the response carried no usable Date header.
+func (e *SignatureMismatchError) ClockSkew() (time.Duration, bool) {
+	if e.ServerTime.IsZero() {
+		return 0, false
+	}
+	return e.LocalTime.Sub(e.ServerTime), true
+}
+
+// Classify inspects an S3 error and returns a typed error when it recognizes
+// the failure, or nil otherwise.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) || apiErr.ErrorCode() != "SignatureDoesNotMatch" {
+		return nil
+	}
+
+	sigErr := &SignatureMismatchError{
+		LocalTime: time.Now().UTC(),
+		Err:       err,
+	}
+
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		sigErr.RequestID = respErr.ServiceRequestID()
+		if respErr.Response != nil && respErr.Response.Header != nil {
+			if date, err := http.ParseTime(respErr.Response.Header.Get("Date")); err == nil {
+				sigErr.ServerTime = date.UTC()
+			}
+		}
+	}
+
+	return sigErr
+}
+
+// accessDeniedError marks an S3 error as ErrAccessDenied without changing
+// its message.
+type accessDeniedError struct {
+	err error
+}
+
+// Error implements error.
+func (e *accessDeniedError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns ErrAccessDenied and the underlying SDK error.
+func (e *accessDeniedError) Unwrap() []error {
+	return []error{ErrAccessDenied, e.err}
+}
+
+// Wrap returns err so that errors.Is matches ErrAccessDenied when S3 refused
+// the request for lack of permission: an AccessDenied error code, or a bare
+// 403, as HEAD responses have no body to carry a code. Object Lock refusals,
+// signature mismatches, and other errors are returned unchanged.
+func Wrap(err error) error {
+	if err == nil || errors.Is(err, ErrAccessDenied) || IsObjectLocked(err) {
+		return err
+	}
+	var code string
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		code = apiErr.ErrorCode()
+	}
+	var respErr *awshttp.ResponseError
+	forbidden := errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusForbidden
+	if code == "AccessDenied" || forbidden && (code == "" || code == "Forbidden") {
+		return &accessDeniedError{err: err}
+	}
+	return err
+}
+
+// IsObjectLocked reports whether err is S3 refusing to delete or overwrite an
+// object because an Object Lock retention period or legal hold protects it.
+// AWS answers with AccessDenied naming Object Lock; MinIO calls the object
+// WORM protected.
+func IsObjectLocked(err error) bool {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.ErrorCode() == "ObjectLocked" {
+		return true
+	}
+	m := strings.ToLower(apiErr.ErrorMessage())
+	return strings.Contains(m, "object lock") || strings.Contains(m, "worm protected")
+}
+
+// SSOExpiredError reports that no request could be signed because the IAM
+// Identity Center (SSO) session the credentials come from has expired. Its
+// message says how to sign in again.
+type SSOExpiredError struct {
+	Profile string // AWS profile to sign in with; empty for the default
+	Err     error  // Underlying SDK error
+}
+
+// Error implements error.
+func (e *SSOExpiredError) Error() string {
+	return msg.Text("s3.sso_expired", msg.Args{"Profile": e.Profile})
+}
+
+// Unwrap returns the underlying SDK error.
+func (e *SSOExpiredError) Unwrap() error {
+	return e.Err
+}
+
+// IsSSOExpired reports whether err is the SDK failing to get credentials
+// from an SSO profile because its session has expired, or its cached token
+// is missing and can't be refreshed. An *SSOExpiredError also matches.
+func IsSSOExpired(err error) bool {
+	if err == nil {
+		return false
+	}
+	var ssoErr *SSOExpiredError
+	var tokenErr *ssocreds.InvalidTokenError
+	if errors.As(err, &ssoErr) || errors.As(err, &tokenErr) {
+		return true
+	}
+	// Profiles with an sso_session refresh their token through a provider
+	// that reports failures as plain errors
+	m := err.Error()
+	return strings.Contains(m, "cached SSO token is expired") || strings.Contains(m, "refresh cached SSO token failed")
+}
+
+// SSOError returns err as an *SSOExpiredError naming the profile cfg uses if
+// IsSSOExpired(err), and err unchanged otherwise, including when it already
+// is one.
+func SSOError(err error, cfg *types.Config) error {
+	var ssoErr *SSOExpiredError
+	if !IsSSOExpired(err) || errors.As(err, &ssoErr) {
+		return err
+	}
+	profile := cfg.Auth.Profile
+	if profile == "" {
+		profile = os.Getenv("AWS_PROFILE")
+	}
+	return &SSOExpiredError{Profile: profile, Err: err}
+}
+
+// Checklist returns troubleshooting steps for a signature mismatch,
+// tailored to the configured endpoint and the observed clock skew.
+func Checklist(e *SignatureMismatchError, cfg *types.Config) []string {
+	var steps []string
+
+	if cfg.Auth.AccessKeyID != "" {
+		steps = append(steps, msg.Text("s3.signature.static_keys", nil))
+	} else {
+		steps = append(steps, msg.Text("s3.signature.profile_keys", nil))
+	}
+
+	if skew, ok := e.ClockSkew(); ok {
+		abs := skew
+		if abs < 0 {
+			abs = -abs
+		}
+		if abs > maxClockSkew {
+			steps = append(steps, msg.Text("s3.signature.clock_skewed", msg.Args{
+				"Skew":   skew.Round(time.Second),
+				"Local":  e.LocalTime.Format(time.RFC3339),
+				"Server": e.ServerTime.Format(time.RFC3339),
+			}))
+		} else {
+			steps = append(steps, msg.Text("s3.signature.clock_ok", msg.Args{"Skew": abs.Round(time.Second)}))
+		}
+	} else {
+		steps = append(steps, msg.Text("s3.signature.clock_unknown", nil))
+	}
+
+	if cfg.S3.Endpoint != "" {
+		if !cfg.S3.ForcePathStyle {
+			steps = append(steps, msg.Text("s3.signature.path_style", nil))
+		}
+		steps = append(steps, msg.Text("s3.signature.region", msg.Args{"Region": cfg.S3.Region}))
+	}
+
+	if e.RequestID != "" {
+		steps = append(steps, msg.Text("s3.signature.request_id", msg.Args{"RequestID": e.RequestID}))
+	}
+
+	return steps
+}
@@ -0,0 +1,272 @@
+package s3errors
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/types"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/credentials/ssocreds"
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// responseError builds an SDK-shaped error as returned by an S3 operation.
+func responseError(code, requestID, date string) error {
+	header := http.Header{}
+	if date != "" {
+		header.Set("Date", date)
+	}
+	return &smithy.OperationError{
+		ServiceID:     "S3",
+		OperationName: "PutObject",
+		Err: &awshttp.ResponseError{
+			ResponseError: &smithyhttp.ResponseError{
+				Response: &smithyhttp.Response{Response: &http.Response{StatusCode: 403, Header: header}},
+				Err:      &smithy.GenericAPIError{Code: code, Message: "test"},
+			},
+			RequestID: requestID,
+		},
+	}
+}
+
+func TestClassify(t *testing.T) {
+	serverTime := time.Now().UTC().Add(-10 * time.Minute).Truncate(time.Second)
+
+	tests := []struct {
+		name      string
+		err       error
+		wantSig   bool
+		wantReqID string
+		wantDate  bool
+	}{
+		{name: "nil", err: nil},
+		{name: "plain error", err: errors.New("boom")},
+		{name: "other API error", err: responseError("AccessDenied", "req-1", "")},
+		{
+			name:      "signature mismatch",
+			err:       responseError("SignatureDoesNotMatch", "req-2", serverTime.Format(http.TimeFormat)),
+			wantSig:   true,
+			wantReqID: "req-2",
+			wantDate:  true,
+		},
+		{
+			name:      "wrapped signature mismatch without date",
+			err:       fmt.Errorf("uploading: %w", responseError("SignatureDoesNotMatch", "req-3", "")),
+			wantSig:   true,
+			wantReqID: "req-3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Classify(tt.err)
+
+			var sigErr *SignatureMismatchError
+			if errors.As(got, &sigErr) != tt.wantSig {
+				t.Fatalf("Classify() = %v, want signature mismatch %v", got, tt.wantSig)
+			}
+			if !tt.wantSig {
+				return
+			}
+
+			if sigErr.RequestID != tt.wantReqID {
+				t.Errorf("RequestID = %q, want %q", sigErr.RequestID, tt.wantReqID)
+			}
+			if !errors.Is(got, tt.err) {
+				t.Error("classified error does not unwrap to original")
+			}
+			if _, ok := sigErr.ClockSkew(); ok != tt.wantDate {
+				t.Errorf("ClockSkew() ok = %v, want %v", ok, tt.wantDate)
+			}
+		})
+	}
+}
+
+func TestClockSkew(t *testing.T) {
+	local := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		server time.Time
+		want   time.Duration
+		wantOK bool
+	}{
+		{name: "no server time", server: time.Time{}, wantOK: false},
+		{name: "local ahead", server: local.Add(-7 * time.Minute), want: 7 * time.Minute, wantOK: true},
+		{name: "local behind", server: local.Add(90 * time.Second), want: -90 * time.Second, wantOK: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := &SignatureMismatchError{LocalTime: local, ServerTime: tt.server}
+			got, ok := e.ClockSkew()
+			if ok != tt.wantOK || got != tt.want {
+				t.Errorf("ClockSkew() = %v, %v; want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestChecklist(t *testing.T) {
+	local := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		err     *SignatureMismatchError
+		cfg     types.Config
+		want    []string
+		notWant []string
+	}{
+		{
+			name: "aws with skewed clock",
+			err:  &SignatureMismatchError{RequestID: "req-9", LocalTime: local, ServerTime: local.Add(-10 * time.Minute)},
+			cfg:  types.Config{S3: types.S3Config{Region: "us-west-2"}},
+			want: []string{
+				"AWS profile",
+				"System clock is off by 10m0s",
+				"Request ID for support: req-9",
+			},
+			notWant: []string{"Custom endpoint"},
+		},
+		{
+			name: "custom endpoint with correct clock",
+			err:  &SignatureMismatchError{LocalTime: local, ServerTime: local.Add(-2 * time.Second)},
+			cfg: types.Config{
+				S3:   types.S3Config{Region: "auto", Endpoint: "https://example.com"},
+				Auth: types.AuthConfig{AccessKeyID: "AKID"},
+			},
+			want: []string{
+				"auth.secret_access_key",
+				"within 2s of server time",
+				"s3.force_path_style: true",
+				`s3.region "auto"`,
+			},
+			notWant: []string{"Request ID"},
+		},
+		{
+			name:    "path style already enabled and no date",
+			err:     &SignatureMismatchError{LocalTime: local},
+			cfg:     types.Config{S3: types.S3Config{Endpoint: "https://example.com", ForcePathStyle: true}},
+			want:    []string{"Check the system clock", "s3.region"},
+			notWant: []string{"force_path_style"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := strings.Join(Checklist(tt.err, &tt.cfg), "\n")
+			for _, want := range tt.want {
+				if !strings.Contains(out, want) {
+					t.Errorf("checklist missing %q\nGot:\n%s", want, out)
+				}
+			}
+			for _, nw := range tt.notWant {
+				if strings.Contains(out, nw) {
+					t.Errorf("checklist unexpectedly contains %q\nGot:\n%s", nw, out)
+				}
+			}
+		})
+	}
+}
+
+func TestIsObjectLocked(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"aws retention", &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied because object protected by object lock."}, true},
+		{"minio worm", &smithy.GenericAPIError{Code: "InvalidRequest", Message: "Object is WORM protected and cannot be overwritten"}, true},
+		{"locked code", fmt.Errorf("deleting: %w", &smithy.GenericAPIError{Code: "ObjectLocked"}), true},
+		{"plain access denied", &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied"}, false},
+		{"not an API error", errors.New("object lock"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsObjectLocked(tt.err); got != tt.want {
+				t.Errorf("IsObjectLocked() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWrap(t *testing.T) {
+	bare403 := &awshttp.ResponseError{
+		ResponseError: &smithyhttp.ResponseError{
+			Response: &smithyhttp.Response{Response: &http.Response{StatusCode: 403, Header: http.Header{}}},
+			Err:      errors.New("forbidden"),
+		},
+	}
+	tests := []struct {
+		name       string
+		err        error
+		wantDenied bool
+	}{
+		{name: "nil", err: nil},
+		{name: "plain error", err: errors.New("boom")},
+		{name: "access denied", err: responseError("AccessDenied", "req-1", ""), wantDenied: true},
+		{name: "wrapped access denied", err: fmt.Errorf("uploading: %w", responseError("AccessDenied", "req-2", "")), wantDenied: true},
+		{name: "bare 403", err: bare403, wantDenied: true},
+		{name: "signature mismatch", err: responseError("SignatureDoesNotMatch", "req-3", "")},
+		{name: "object locked", err: &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied because object protected by object lock."}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Wrap(tt.err)
+			if errors.Is(got, ErrAccessDenied) != tt.wantDenied {
+				t.Errorf("errors.Is(Wrap(), ErrAccessDenied) = %v, want %v", !tt.wantDenied, tt.wantDenied)
+			}
+			if tt.err == nil {
+				if got != nil {
+					t.Errorf("Wrap(nil) = %v", got)
+				}
+				return
+			}
+			if got.Error() != tt.err.Error() || !errors.Is(got, tt.err) {
+				t.Errorf("Wrap() = %v, want the original message and error kept", got)
+			}
+		})
+	}
+}
+
+func TestSSOError(t *testing.T) {
+	cfg := &types.Config{Auth: types.AuthConfig{Profile: "work"}}
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"plain error", errors.New("boom"), false},
+		{"expired token", fmt.Errorf("operation error S3: HeadBucket, failed to retrieve credentials: %w", &ssocreds.InvalidTokenError{}), true},
+		{"refresh failed", errors.New("refresh cached SSO token failed, unable to refresh SSO token, InvalidGrantException"), true},
+		{"cannot refresh", errors.New("cached SSO token is expired, or not present, and cannot be refreshed"), true},
+		{"access denied", responseError("AccessDenied", "req-1", ""), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := IsSSOExpired(tt.err); got != tt.want {
+				t.Errorf("IsSSOExpired() = %v, want %v", got, tt.want)
+			}
+			got := SSOError(tt.err, cfg)
+			var ssoErr *SSOExpiredError
+			if errors.As(got, &ssoErr) != tt.want {
+				t.Fatalf("SSOError() = %v, want an SSOExpiredError: %v", got, tt.want)
+			}
+			if !tt.want {
+				if got != tt.err {
+					t.Errorf("SSOError() = %v, want the error unchanged", got)
+				}
+				return
+			}
+			if !strings.Contains(got.Error(), "aws sso login --profile work") || !errors.Is(got, tt.err) {
+				t.Errorf("SSOError() = %v, want the login command and the original error kept", got)
+			}
+		})
+	}
+}
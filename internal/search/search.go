@@ -0,0 +1,194 @@
+// Package search finds lines matching a pattern in session logs, read from
+// any fetch.Source.
+package search
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/13rac1/cclogs/internal/fetch"
+)
+
+// Roles accepted by Options.Role.
+const (
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
+)
+
+// DefaultContext is how many bytes of a matching line are kept on each side
+// of the match.
+const DefaultContext = 60
+
+// Options controls a search.
+type Options struct {
+	Pattern *regexp.Regexp
+	Project string // Only search this project (all if empty)
+	Role    string // Only match records of this role (any if empty)
+	Context int    // Bytes kept around the match (DefaultContext if 0)
+}
+
+// Match is one matching line.
+type Match struct {
+	Project string `json:"project"`
+	Path    string `json:"path"`
+	Line    int    `json:"line"`
+	Role    string `json:"role,omitempty"`
+	Text    string `json:"text"` // The match with trimmed context
+}
+
+// Failure is a log that could not be searched.
+type Failure struct {
+	Ref string
+	Err error
+}
+
+// Result summarizes a search.
+type Result struct {
+	Files    int // Logs searched
+	Matches  int
+	Failures []Failure
+}
+
+// record holds the fields of a log line used for role filtering.
+type record struct {
+	Type    string `json:"type"`
+	Message struct {
+		Role string `json:"role"`
+	} `json:"message"`
+}
+
+// Run searches the logs of src, calling fn for each match in project and
+// path order. A log that cannot be read is recorded as a failure and the
+// search continues; an error from fn stops it.
+func Run(ctx context.Context, src fetch.Source, opts Options, fn func(Match) error) (*Result, error) {
+	if opts.Context <= 0 {
+		opts.Context = DefaultContext
+	}
+
+	logs, err := src.Logs(ctx, opts.Project)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Result{}
+	for _, l := range logs {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		n, err := searchLog(ctx, src, l, opts, fn)
+		result.Matches += n
+		if err != nil {
+			var cbErr callbackError
+			if errors.As(err, &cbErr) {
+				return result, cbErr.err
+			}
+			result.Failures = append(result.Failures, Failure{Ref: l.Ref(), Err: err})
+			continue
+		}
+		result.Files++
+	}
+	return result, nil
+}
+
+// callbackError marks an error returned by the caller's match function.
+type callbackError struct{ err error }
+
+func (e callbackError) Error() string { return e.err.Error() }
+
+func searchLog(ctx context.Context, src fetch.Source, l fetch.Log, opts Options, fn func(Match) error) (int, error) {
+	body, err := src.Open(ctx, l.Ref())
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = body.Close() }()
+
+	scanner := bufio.NewScanner(body)
+	// Increase buffer for large lines (10MB max)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	matches := 0
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		line := scanner.Text()
+		loc := opts.Pattern.FindStringIndex(line)
+		if loc == nil {
+			continue
+		}
+
+		role := lineRole(line)
+		if opts.Role != "" && role != opts.Role {
+			continue
+		}
+
+		matches++
+		m := Match{Project: l.Project, Path: l.Path, Line: lineNum, Role: role, Text: Snippet(line, loc, opts.Context)}
+		if err := fn(m); err != nil {
+			return matches, callbackError{err}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return matches, fmt.Errorf("reading %s line %d: %w", l.Ref(), lineNum+1, err)
+	}
+	return matches, nil
+}
+
+// lineRole returns the role of a JSON log record: "user" or "assistant" for
+// messages, "" for anything else.
+func lineRole(line string) string {
+	var rec record
+	if err := json.Unmarshal([]byte(line), &rec); err != nil {
+		return ""
+	}
+	for _, role := range []string{rec.Type, rec.Message.Role} {
+		if role == RoleUser || role == RoleAssistant {
+			return role
+		}
+	}
+	return ""
+}
+
+// Snippet returns the match at loc in line with up to width bytes of context
+// on each side, marking trimmed ends with "…". Cuts never split a UTF-8
+// character, and the match itself is never trimmed.
+func Snippet(line string, loc []int, width int) string {
+	start := max(loc[0]-width, 0)
+	for start > 0 && !utf8.RuneStart(line[start]) {
+		start++
+	}
+	end := min(loc[1]+width, len(line))
+	for end < len(line) && !utf8.RuneStart(line[end]) {
+		end--
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	b.WriteString(line[start:end])
+	if end < len(line) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// Compile builds the search pattern. A fixed pattern matches literally.
+func Compile(pattern string, fixed, ignoreCase bool) (*regexp.Regexp, error) {
+	if fixed {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid pattern: %w", err)
+	}
+	return re, nil
+}
@@ -0,0 +1,140 @@
+package search
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/fetch"
+)
+
+func writeLog(t *testing.T, root, name, content string) {
+	t.Helper()
+	path := filepath.Join(root, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRun(t *testing.T) {
+	root := t.TempDir()
+	writeLog(t, root, "app/a.jsonl",
+		`{"type":"user","message":{"role":"user","content":"Plan the database migration"}}`+"\n"+
+			`{"type":"assistant","message":{"role":"assistant","content":"The migration needs two steps"}}`+"\n"+
+			`{"type":"summary","summary":"Migration planning"}`+"\n")
+	writeLog(t, root, "web/b.jsonl", `{"type":"user","message":{"content":"no match here"}}`+"\n")
+	writeLog(t, root, "web/notes.txt", "migration\n")
+	src := fetch.LocalSource{Root: root}
+
+	tests := []struct {
+		name       string
+		pattern    string
+		fixed      bool
+		ignoreCase bool
+		project    string
+		role       string
+		want       []string
+	}{
+		{name: "regexp", pattern: `migrat\w+`, want: []string{"app/a.jsonl:1:user", "app/a.jsonl:2:assistant"}},
+		{name: "ignore case", pattern: "migration", ignoreCase: true, want: []string{"app/a.jsonl:1:user", "app/a.jsonl:2:assistant", "app/a.jsonl:3:"}},
+		{name: "role", pattern: "migration", role: RoleAssistant, want: []string{"app/a.jsonl:2:assistant"}},
+		{name: "fixed", pattern: "two steps", fixed: true, want: []string{"app/a.jsonl:2:assistant"}},
+		{name: "fixed metacharacters", pattern: "migrat.*", fixed: true},
+		{name: "project", pattern: "match", project: "web", want: []string{"web/b.jsonl:1:user"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			re, err := Compile(tt.pattern, tt.fixed, tt.ignoreCase)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			result, err := Run(context.Background(), src, Options{Pattern: re, Project: tt.project, Role: tt.role}, func(m Match) error {
+				got = append(got, m.Project+"/"+m.Path+":"+strconv.Itoa(m.Line)+":"+m.Role)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("matches = %v, want %v", got, tt.want)
+			}
+			if result.Matches != len(tt.want) {
+				t.Errorf("Result.Matches = %d, want %d", result.Matches, len(tt.want))
+			}
+		})
+	}
+}
+
+// failingSource lists two logs and fails to open the first.
+type failingSource struct{ fetch.LocalSource }
+
+func (s failingSource) Logs(ctx context.Context, project string) ([]fetch.Log, error) {
+	return []fetch.Log{{Project: "app", Path: "gone.jsonl"}, {Project: "app", Path: "a.jsonl"}}, nil
+}
+
+func (s failingSource) Open(ctx context.Context, ref string) (io.ReadCloser, error) {
+	if ref == "app/gone.jsonl" {
+		return nil, errors.New("NoSuchKey")
+	}
+	return s.LocalSource.Open(ctx, ref)
+}
+
+func TestRunFailures(t *testing.T) {
+	root := t.TempDir()
+	writeLog(t, root, "app/a.jsonl", "needle\n")
+	src := failingSource{fetch.LocalSource{Root: root}}
+	re, _ := Compile("needle", false, false)
+
+	result, err := Run(context.Background(), src, Options{Pattern: re}, func(Match) error { return nil })
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if result.Files != 1 || result.Matches != 1 || len(result.Failures) != 1 || result.Failures[0].Ref != "app/gone.jsonl" {
+		t.Errorf("Run() = %+v, want 1 file, 1 match, and app/gone.jsonl failed", result)
+	}
+
+	// An error from the callback stops the search
+	stop := errors.New("stop")
+	if _, err := Run(context.Background(), src, Options{Pattern: re}, func(Match) error { return stop }); !errors.Is(err, stop) {
+		t.Errorf("Run() with failing callback error = %v, want %v", err, stop)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	tests := []struct {
+		line  string
+		match string
+		width int
+		want  string
+	}{
+		{"short needle line", "needle", 60, "short needle line"},
+		{"0123456789needle0123456789", "needle", 3, "…789needle012…"},
+		{"needle at start", "needle", 2, "needle a…"},
+		// Cuts move inward rather than split a multi-byte character
+		{"ééééneedleéééé", "needle", 3, "…éneedleé…"},
+	}
+	for _, tt := range tests {
+		i := strings.Index(tt.line, tt.match)
+		if got := Snippet(tt.line, []int{i, i + len(tt.match)}, tt.width); got != tt.want {
+			t.Errorf("Snippet(%q, %d) = %q, want %q", tt.line, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestCompileInvalid(t *testing.T) {
+	if _, err := Compile("(unclosed", false, false); err == nil {
+		t.Error("Compile() accepted an invalid pattern")
+	}
+	if _, err := Compile("(unclosed", true, false); err != nil {
+		t.Errorf("Compile() with fixed = %v, want literal match", err)
+	}
+}
@@ -0,0 +1,261 @@
+package selftest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/doctor"
+	"github.com/13rac1/cclogs/internal/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// Optional S3 features some S3-compatible providers lack, as probed by
+// ProbeCapabilities.
+const (
+	FeatureConditionalWrite = "conditional_write" // If-None-Match and If-Match on PutObject
+	FeatureChecksums        = "checksums"         // x-amz-checksum-sha256 on PutObject
+	FeatureTagging          = "tagging"           // PutObjectTagging
+	FeatureCopyObject       = "copy_object"       // CopyObject
+	FeatureDeleteObjects    = "delete_objects"    // Batched DeleteObjects
+)
+
+// Features lists the probed features in the order they are probed.
+var Features = []string{FeatureConditionalWrite, FeatureChecksums, FeatureTagging, FeatureCopyObject, FeatureDeleteObjects}
+
+// fallbacks describes how cclogs works around a feature it found missing.
+var fallbacks = map[string]string{
+	FeatureConditionalWrite: "upload.remote_lock is skipped with a warning",
+	FeatureChecksums:        "uploads are sent without a SHA-256 checksum header",
+	FeatureCopyObject:       "manifest backups (s3.manifest_history) are skipped",
+}
+
+// errForbidden marks a probe the credentials were not allowed to make, which
+// says nothing about the endpoint.
+var errForbidden = errors.New("access denied")
+
+// probe holds the keys a capability probe writes, all under the selftest
+// prefix.
+type probe struct {
+	cfg    *types.Config
+	client *s3.Client
+	dir    string // Key prefix of the probe objects
+	etag   string // ETag of the base object
+}
+
+func (p *probe) key(name string) string { return p.dir + name }
+
+// ProbeCapabilities exercises each optional feature with tiny objects under
+// <prefix>/.selftest/capabilities/ and reports which the endpoint supports.
+// The map holds only features that were conclusively probed; one that was
+// denied by permissions is left out. Credentials that cannot write at all
+// skip the probe and return a nil map. Probe objects are always deleted.
+func ProbeCapabilities(ctx context.Context, cfg *types.Config, client *s3.Client) (map[string]bool, []doctor.Result) {
+	p := &probe{cfg: cfg, client: client, dir: Prefix(cfg) + "capabilities/"}
+	defer p.cleanup(ctx)
+
+	etag, err := p.put(ctx, &s3.PutObjectInput{Key: aws.String(p.key("base"))})
+	if errors.Is(err, errForbidden) {
+		return nil, []doctor.Result{{
+			Name:    "capabilities",
+			Status:  doctor.Warn,
+			Message: "Capability probe skipped: credentials cannot write to " + p.dir,
+			Details: []string{"→ Read-only credentials only read and list; nothing was recorded"},
+		}}
+	}
+	if err != nil {
+		return nil, []doctor.Result{{
+			Name:    "capabilities",
+			Status:  doctor.Fail,
+			Message: "Capability probe failed: writing probe object",
+			Details: []string{"→ Error: " + err.Error()},
+		}}
+	}
+	p.etag = etag
+
+	checks := map[string]func(context.Context) error{
+		FeatureConditionalWrite: p.conditionalWrite,
+		FeatureChecksums:        p.checksums,
+		FeatureTagging:          p.tagging,
+		FeatureCopyObject:       p.copyObject,
+		FeatureDeleteObjects:    p.deleteObjects,
+	}
+	features := make(map[string]bool)
+	var results []doctor.Result
+	for _, feature := range Features {
+		err := checks[feature](ctx)
+		name := "capabilities." + feature
+		switch {
+		case err == nil:
+			features[feature] = true
+			results = append(results, doctor.Result{Name: name, Status: doctor.Pass, Message: feature + ": supported"})
+		case errors.Is(err, errForbidden):
+			results = append(results, doctor.Result{
+				Name:    name,
+				Status:  doctor.Warn,
+				Message: feature + ": not probed, permission denied",
+			})
+		default:
+			features[feature] = false
+			r := doctor.Result{Name: name, Status: doctor.Warn, Message: feature + ": unsupported",
+				Details: []string{"→ " + err.Error()}}
+			if fb, ok := fallbacks[feature]; ok {
+				r.Details = append(r.Details, "→ Fallback: "+fb)
+			}
+			results = append(results, r)
+		}
+	}
+	return features, results
+}
+
+// conditionalWrite checks that a create-only write of an existing object and
+// a write with a stale ETag are refused, and one with the current ETag is
+// not, as the remote lock relies on.
+func (p *probe) conditionalWrite(ctx context.Context) error {
+	_, err := p.put(ctx, &s3.PutObjectInput{Key: aws.String(p.key("base")), IfNoneMatch: aws.String("*")})
+	if err == nil {
+		return errors.New("If-None-Match: * did not stop overwriting an existing object")
+	}
+	if !isStatus(err, http.StatusPreconditionFailed, http.StatusConflict) {
+		return unsupported(err)
+	}
+
+	_, err = p.put(ctx, &s3.PutObjectInput{Key: aws.String(p.key("base")), IfMatch: aws.String(`"stale"`)})
+	if err == nil {
+		return errors.New("If-Match with a stale ETag did not stop the write")
+	}
+	if !isStatus(err, http.StatusPreconditionFailed, http.StatusConflict) {
+		return unsupported(err)
+	}
+
+	etag, err := p.put(ctx, &s3.PutObjectInput{Key: aws.String(p.key("base")), IfMatch: aws.String(p.etag)})
+	if err != nil {
+		return unsupported(err)
+	}
+	p.etag = etag
+	return nil
+}
+
+func (p *probe) checksums(ctx context.Context) error {
+	body := []byte("cclogs checksum probe\n")
+	sum := sha256.Sum256(body)
+	_, err := p.put(ctx, &s3.PutObjectInput{
+		Key:            aws.String(p.key("checksum")),
+		Body:           bytes.NewReader(body),
+		ChecksumSHA256: aws.String(base64.StdEncoding.EncodeToString(sum[:])),
+	})
+	return unsupported(err)
+}
+
+func (p *probe) tagging(ctx context.Context) error {
+	opCtx, cancel := config.WithOperationTimeout(ctx, p.cfg.S3.OperationTimeout)
+	defer cancel()
+	_, err := p.client.PutObjectTagging(opCtx, &s3.PutObjectTaggingInput{
+		Bucket:  aws.String(p.cfg.S3.Bucket),
+		Key:     aws.String(p.key("base")),
+		Tagging: &s3types.Tagging{TagSet: []s3types.Tag{{Key: aws.String("cclogs"), Value: aws.String("probe")}}},
+	})
+	return unsupported(err)
+}
+
+func (p *probe) copyObject(ctx context.Context) error {
+	opCtx, cancel := config.WithOperationTimeout(ctx, p.cfg.S3.OperationTimeout)
+	defer cancel()
+	_, err := p.client.CopyObject(opCtx, &s3.CopyObjectInput{
+		Bucket:     aws.String(p.cfg.S3.Bucket),
+		Key:        aws.String(p.key("copy")),
+		CopySource: aws.String((&url.URL{Path: p.cfg.S3.Bucket + "/" + p.key("base")}).EscapedPath()),
+	})
+	return unsupported(err)
+}
+
+func (p *probe) deleteObjects(ctx context.Context) error {
+	// A key of its own, so the objects other probes need are left alone
+	if _, err := p.put(ctx, &s3.PutObjectInput{Key: aws.String(p.key("batch"))}); err != nil {
+		return unsupported(err)
+	}
+	opCtx, cancel := config.WithOperationTimeout(ctx, p.cfg.S3.OperationTimeout)
+	defer cancel()
+	out, err := p.client.DeleteObjects(opCtx, &s3.DeleteObjectsInput{
+		Bucket: aws.String(p.cfg.S3.Bucket),
+		Delete: &s3types.Delete{Objects: []s3types.ObjectIdentifier{{Key: aws.String(p.key("batch"))}}},
+	})
+	if err != nil {
+		return unsupported(err)
+	}
+	if len(out.Errors) > 0 {
+		e := out.Errors[0]
+		if aws.ToString(e.Code) == "AccessDenied" {
+			return errForbidden
+		}
+		return fmt.Errorf("deleting %s: %s", aws.ToString(e.Key), aws.ToString(e.Message))
+	}
+	return nil
+}
+
+// put writes a probe object, empty unless the input has a body, and returns
+// its ETag.
+func (p *probe) put(ctx context.Context, input *s3.PutObjectInput) (string, error) {
+	opCtx, cancel := config.WithOperationTimeout(ctx, p.cfg.S3.OperationTimeout)
+	defer cancel()
+	input.Bucket = aws.String(p.cfg.S3.Bucket)
+	if input.Body == nil {
+		input.Body = strings.NewReader("")
+	}
+	out, err := p.client.PutObject(opCtx, input)
+	if err != nil {
+		return "", unsupported(err)
+	}
+	return aws.ToString(out.ETag), nil
+}
+
+// cleanup deletes the probe objects one at a time, so it works where
+// DeleteObjects does not. It runs detached from cancellation like the
+// selftest cleanup.
+func (p *probe) cleanup(ctx context.Context) {
+	ctx = context.WithoutCancel(ctx)
+	for _, name := range []string{"base", "checksum", "copy", "batch"} {
+		opCtx, cancel := config.WithOperationTimeout(ctx, p.cfg.S3.OperationTimeout)
+		_, _ = p.client.DeleteObject(opCtx, &s3.DeleteObjectInput{
+			Bucket: aws.String(p.cfg.S3.Bucket),
+			Key:    aws.String(p.key(name)),
+		})
+		cancel()
+	}
+}
+
+// unsupported returns err, or errForbidden if it was a 403, which is a
+// permission problem rather than a missing feature.
+func unsupported(err error) error {
+	if err == nil || errors.Is(err, errForbidden) {
+		return err
+	}
+	if isStatus(err, http.StatusForbidden) {
+		return fmt.Errorf("%w: %v", errForbidden, err)
+	}
+	return err
+}
+
+// isStatus reports whether err is an HTTP response with one of codes.
+func isStatus(err error, codes ...int) bool {
+	var respErr *awshttp.ResponseError
+	if !errors.As(err, &respErr) {
+		return false
+	}
+	for _, code := range codes {
+		if respErr.HTTPStatusCode() == code {
+			return true
+		}
+	}
+	return false
+}
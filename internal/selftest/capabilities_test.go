@@ -0,0 +1,109 @@
+package selftest
+
+import (
+	"context"
+	"maps"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/doctor"
+	"github.com/13rac1/cclogs/internal/identity"
+)
+
+func TestProbeCapabilities(t *testing.T) {
+	tests := []struct {
+		name        string
+		unsupported []string
+		want        map[string]bool
+	}{
+		{
+			name: "everything supported",
+			want: map[string]bool{
+				FeatureConditionalWrite: true, FeatureChecksums: true, FeatureTagging: true,
+				FeatureCopyObject: true, FeatureDeleteObjects: true,
+			},
+		},
+		{
+			name:        "no tagging or conditional writes",
+			unsupported: []string{FeatureTagging, FeatureConditionalWrite},
+			want: map[string]bool{
+				FeatureConditionalWrite: false, FeatureChecksums: true, FeatureTagging: false,
+				FeatureCopyObject: true, FeatureDeleteObjects: true,
+			},
+		},
+		{
+			name:        "no copies, batch deletes, or checksums",
+			unsupported: []string{FeatureCopyObject, FeatureDeleteObjects, FeatureChecksums},
+			want: map[string]bool{
+				FeatureConditionalWrite: true, FeatureChecksums: false, FeatureTagging: true,
+				FeatureCopyObject: false, FeatureDeleteObjects: false,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, fake := newFakeS3(t)
+			for _, f := range tt.unsupported {
+				fake.unsupported[f] = true
+			}
+			fake.objects["claude-code/.manifest.json"] = []byte(`{"version":2,"files":{}}`)
+
+			features, results := ProbeCapabilities(context.Background(), testConfig(), client)
+			if !maps.Equal(features, tt.want) {
+				t.Errorf("features = %v, want %v", features, tt.want)
+			}
+			if !doctor.Passed(results) {
+				t.Errorf("results failed: %+v", results)
+			}
+			for _, r := range results {
+				feature := strings.TrimPrefix(r.Name, "capabilities.")
+				if wantPass := tt.want[feature]; (r.Status == doctor.Pass) != wantPass {
+					t.Errorf("%s: status %s, want supported %v", r.Name, r.Status, wantPass)
+				}
+			}
+
+			for key := range fake.objects {
+				if strings.HasPrefix(key, "claude-code/.selftest/") {
+					t.Errorf("probe object left behind: %s", key)
+				}
+			}
+			for _, w := range fake.writes {
+				if !strings.Contains(w, " claude-code/.selftest/capabilities/") {
+					t.Errorf("write outside the probe prefix: %s", w)
+				}
+			}
+
+			// What runtime code sees once the result is recorded
+			path := filepath.Join(t.TempDir(), identity.StateFile)
+			if err := identity.RecordCapabilities(path, identity.Capabilities{Bucket: "test-bucket", Features: features}); err != nil {
+				t.Fatal(err)
+			}
+			caps := identity.LoadCapabilities(path, "", "test-bucket")
+			for feature, supported := range tt.want {
+				if caps.Supports(feature) != supported {
+					t.Errorf("Supports(%s) = %v, want %v", feature, !supported, supported)
+				}
+			}
+		})
+	}
+}
+
+func TestProbeCapabilities_ReadOnly(t *testing.T) {
+	client, fake := newFakeS3(t)
+	fake.denyWrites = true
+
+	features, results := ProbeCapabilities(context.Background(), testConfig(), client)
+	if features != nil {
+		t.Errorf("features = %v, want nil for read-only credentials", features)
+	}
+	if len(results) != 1 || results[0].Status != doctor.Warn {
+		t.Fatalf("results = %+v, want one skipped warning", results)
+	}
+	for _, w := range fake.writes {
+		if strings.HasPrefix(w, "PUT ") {
+			t.Errorf("read-only probe wrote %s", w)
+		}
+	}
+}
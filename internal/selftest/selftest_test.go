@@ -0,0 +1,352 @@
+package selftest
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/doctor"
+	"github.com/13rac1/cclogs/internal/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeS3 is a path-style in-memory S3 server. fail, if set, picks requests
+// to answer with a 500 so individual stages can be broken. Features named in
+// unsupported behave like a provider lacking them: conditional headers are
+// ignored, checksum headers are rejected, and the other operations answer
+// 501 Not Implemented. denyWrites answers every write with 403, like
+// read-only credentials.
+type fakeS3 struct {
+	mu          sync.Mutex
+	objects     map[string][]byte
+	writes      []string // "METHOD key" for every PUT and DELETE
+	fail        func(method, key string) bool
+	unsupported map[string]bool
+	denyWrites  bool
+}
+
+func newFakeS3(t *testing.T) (*s3.Client, *fakeS3) {
+	t.Helper()
+
+	f := &fakeS3{objects: make(map[string][]byte), unsupported: make(map[string]bool)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(srv.URL),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+		// Injected failures should fail fast rather than back off
+		RetryMaxAttempts: 1,
+	})
+	return client, f
+}
+
+func etagOf(data []byte) string {
+	return fmt.Sprintf(`"%x"`, md5.Sum(data))
+}
+
+func writeError(w http.ResponseWriter, status int, code string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>%s</Code><Message>%s</Message></Error>`, code, code)
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	_, key, ok := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	// Only batch deletes are addressed to the bucket itself
+	if !ok && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.fail != nil && f.fail(r.Method, key) {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if f.denyWrites && r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusForbidden, "AccessDenied")
+		return
+	}
+
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && query.Has("delete"):
+		if f.unsupported[FeatureDeleteObjects] {
+			writeError(w, http.StatusNotImplemented, "NotImplemented")
+			return
+		}
+		var req struct {
+			Objects []struct{ Key string } `xml:"Object"`
+		}
+		if err := xml.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "MalformedXML")
+			return
+		}
+		var out strings.Builder
+		out.WriteString(`<?xml version="1.0" encoding="UTF-8"?><DeleteResult>`)
+		for _, o := range req.Objects {
+			delete(f.objects, o.Key)
+			f.writes = append(f.writes, "DELETE "+o.Key)
+			fmt.Fprintf(&out, "<Deleted><Key>%s</Key></Deleted>", o.Key)
+		}
+		out.WriteString("</DeleteResult>")
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = io.WriteString(w, out.String())
+	case r.Method == http.MethodPut && query.Has("tagging"):
+		if f.unsupported[FeatureTagging] {
+			writeError(w, http.StatusNotImplemented, "NotImplemented")
+			return
+		}
+		if _, ok := f.objects[key]; !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodPut && r.Header.Get("X-Amz-Copy-Source") != "":
+		if f.unsupported[FeatureCopyObject] {
+			writeError(w, http.StatusNotImplemented, "NotImplemented")
+			return
+		}
+		src, _ := url.PathUnescape(r.Header.Get("X-Amz-Copy-Source"))
+		_, srcKey, _ := strings.Cut(strings.TrimPrefix(src, "/"), "/")
+		data, ok := f.objects[srcKey]
+		if !ok {
+			writeError(w, http.StatusNotFound, "NoSuchKey")
+			return
+		}
+		f.objects[key] = data
+		f.writes = append(f.writes, "PUT "+key)
+		w.Header().Set("Content-Type", "application/xml")
+		_, _ = fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?><CopyObjectResult><ETag>%s</ETag></CopyObjectResult>`, etagOf(data))
+	case r.Method == http.MethodPut:
+		current, exists := f.objects[key]
+		if !f.unsupported[FeatureConditionalWrite] {
+			if r.Header.Get("If-None-Match") == "*" && exists {
+				writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+				return
+			}
+			if m := r.Header.Get("If-Match"); m != "" && (!exists || m != etagOf(current)) {
+				writeError(w, http.StatusPreconditionFailed, "PreconditionFailed")
+				return
+			}
+		}
+		if f.unsupported[FeatureChecksums] && r.Header.Get("X-Amz-Checksum-Sha256") != "" {
+			writeError(w, http.StatusBadRequest, "InvalidArgument")
+			return
+		}
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		f.objects[key] = data
+		f.writes = append(f.writes, "PUT "+key)
+		w.Header().Set("ETag", etagOf(data))
+		w.WriteHeader(http.StatusOK)
+	case r.Method == http.MethodDelete:
+		delete(f.objects, key)
+		f.writes = append(f.writes, "DELETE "+key)
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodGet, r.Method == http.MethodHead:
+		data, ok := f.objects[key]
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			}
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func testConfig() *types.Config {
+	return &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: "/nonexistent/real-projects"},
+		S3:    types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+	}
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name       string
+		compress   string
+		fail       func(method, key string) bool
+		wantFailed string // Name of the failing stage, empty if all pass
+	}{
+		{
+			name: "all stages pass",
+		},
+		{
+			name:     "all stages pass with gzip",
+			compress: "gzip",
+		},
+		{
+			name: "upload fails",
+			fail: func(method, key string) bool {
+				return method == http.MethodPut && strings.HasSuffix(key, ".jsonl")
+			},
+			wantFailed: "selftest.upload",
+		},
+		{
+			name: "manifest save fails",
+			fail: func(method, key string) bool {
+				return method == http.MethodPut && strings.HasSuffix(key, ".manifest.json")
+			},
+			wantFailed: "selftest.manifest",
+		},
+		{
+			name: "verify fails after upload",
+			fail: func(method, key string) bool {
+				return method == http.MethodHead
+			},
+			wantFailed: "selftest.verify",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, fake := newFakeS3(t)
+			fake.fail = tt.fail
+
+			// Stand-ins for real data that must survive untouched
+			realManifest := []byte(`{"version":1,"files":{}}`)
+			fake.objects["claude-code/.manifest.json"] = realManifest
+			fake.objects["claude-code/real-project/session.jsonl"] = []byte("{}\n")
+
+			cfg := testConfig()
+			cfg.Upload.Compress = tt.compress
+			tmp := t.TempDir()
+			results := Run(context.Background(), cfg, client, Options{TempDir: tmp})
+
+			var failed []string
+			for _, r := range results {
+				if r.Status == doctor.Fail {
+					failed = append(failed, r.Name)
+				}
+			}
+			var want []string
+			if tt.wantFailed != "" {
+				want = []string{tt.wantFailed}
+			}
+			if strings.Join(failed, ",") != strings.Join(want, ",") {
+				t.Fatalf("failed stages = %v, want %v; results: %+v", failed, want, results)
+			}
+
+			last := results[len(results)-1]
+			if last.Name != "selftest.cleanup" || last.Status != doctor.Pass {
+				t.Errorf("last result = %+v, want passing cleanup", last)
+			}
+
+			// Cleanup removed everything under the scoped prefix...
+			for key := range fake.objects {
+				if strings.HasPrefix(key, "claude-code/.selftest/") {
+					t.Errorf("selftest object left behind: %s", key)
+				}
+			}
+			entries, err := os.ReadDir(tmp)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != 0 {
+				t.Errorf("local files left behind: %v", entries)
+			}
+
+			// ...and never wrote outside it
+			for _, w := range fake.writes {
+				if !strings.Contains(w, " claude-code/.selftest/") {
+					t.Errorf("write outside selftest prefix: %s", w)
+				}
+			}
+			if got := string(fake.objects["claude-code/.manifest.json"]); got != string(realManifest) {
+				t.Errorf("real manifest changed: %s", got)
+			}
+			if _, ok := fake.objects["claude-code/real-project/session.jsonl"]; !ok {
+				t.Error("real object deleted")
+			}
+			if cfg.Local.ProjectsRoot != "/nonexistent/real-projects" || cfg.S3.Prefix != "claude-code/" {
+				t.Errorf("caller config modified: %+v", cfg)
+			}
+		})
+	}
+}
+
+func TestRun_SetupFails(t *testing.T) {
+	client, fake := newFakeS3(t)
+
+	results := Run(context.Background(), testConfig(), client, Options{TempDir: "/nonexistent/tmp"})
+
+	if len(results) != 2 || results[0].Name != "selftest.setup" || results[0].Status != doctor.Fail {
+		t.Fatalf("results = %+v, want failed setup then cleanup", results)
+	}
+	if results[1].Status != doctor.Pass {
+		t.Errorf("cleanup = %+v, want pass", results[1])
+	}
+	// Only the scoped manifest key is known before discovery
+	for _, w := range fake.writes {
+		if w != "DELETE claude-code/.selftest/.manifest.json" {
+			t.Errorf("unexpected write: %s", w)
+		}
+	}
+}
+
+func TestScopedConfig(t *testing.T) {
+	cfg := &types.Config{
+		Local: types.LocalConfig{
+			MachineID:     "laptop",
+			ProjectDepth:  2,
+			ProjectMarker: ".git",
+			Extensions:    []string{".log"},
+		},
+		S3: types.S3Config{
+			Prefix:      "logs",
+			KeyLayout:   config.KeyLayoutByHost,
+			KeyTemplate: "{prefix}{year}/{project}/{path}",
+			ObjectLock:  types.ObjectLockConfig{Mode: "COMPLIANCE", RetainDays: 30},
+		},
+	}
+
+	scoped := scopedConfig(cfg)
+	if scoped.S3.ObjectLock != (types.ObjectLockConfig{}) {
+		t.Errorf("scoped object lock = %+v, want none", scoped.S3.ObjectLock)
+	}
+	if scoped.Local.ProjectDepth != 1 || scoped.Local.ProjectMarker != "" || !reflect.DeepEqual(scoped.Local.Extensions, config.DefaultExtensions) {
+		t.Errorf("scoped local = %+v, want the default project layout", scoped.Local)
+	}
+	if got, want := config.KeyPrefix(scoped), "logs/laptop/.selftest/"; got != want {
+		t.Errorf("scoped prefix = %q, want %q", got, want)
+	}
+	if config.KeyTemplate(scoped) != config.DefaultKeyTemplate {
+		t.Errorf("scoped template = %q, want default", config.KeyTemplate(scoped))
+	}
+	if cfg.S3.Prefix != "logs" || cfg.S3.KeyTemplate == "" {
+		t.Errorf("original config modified: %+v", cfg.S3)
+	}
+}
@@ -0,0 +1,93 @@
+package stats
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/olekukonko/tablewriter"
+)
+
+// PrintReport writes the report as tables: projects by month, models, and
+// the time series, followed by the busiest periods and overall totals.
+func PrintReport(w io.Writer, r *Report) {
+	if r.Total.Messages == 0 {
+		fmt.Fprintf(w, "No token usage found in %d files.\n", r.Files)
+		return
+	}
+
+	columns := []string{"Responses", "Input", "Output", "Cache write", "Cache read", "Total"}
+
+	fmt.Fprintln(w, "Projects")
+	table := tablewriter.NewWriter(w)
+	table.Header(append([]string{"Project", "Month"}, columns...))
+	for _, p := range r.Projects {
+		for i, m := range p.Months {
+			name := ""
+			if i == 0 {
+				name = p.Name
+			}
+			table.Append(append([]string{name, m.Name}, totalsRow(m.Totals)...))
+		}
+		if len(p.Months) > 1 {
+			table.Append(append([]string{"", "all"}, totalsRow(p.Totals)...))
+		}
+	}
+	table.Render()
+
+	fmt.Fprintln(w, "\nModels")
+	table = tablewriter.NewWriter(w)
+	table.Header(append([]string{"Model"}, columns...))
+	for _, m := range r.Models {
+		table.Append(append([]string{m.Name}, totalsRow(m.Totals)...))
+	}
+	table.Render()
+
+	if len(r.Series) > 0 {
+		period := "Day"
+		if r.Bucket == BucketWeek {
+			period = "Week of"
+		}
+		fmt.Fprintf(w, "\nBy %s\n", r.Bucket)
+		table = tablewriter.NewWriter(w)
+		table.Header(append([]string{period}, columns...))
+		for _, s := range r.Series {
+			table.Append(append([]string{s.Name}, totalsRow(s.Totals)...))
+		}
+		table.Render()
+
+		busiest := make([]string, len(r.Busiest))
+		for i, b := range r.Busiest {
+			busiest[i] = fmt.Sprintf("%s (%s tokens)", b.Name, formatCount(b.Tokens()))
+		}
+		fmt.Fprintf(w, "\nBusiest %ss: %s\n", r.Bucket, strings.Join(busiest, ", "))
+	}
+
+	fmt.Fprintf(w, "\n%s responses, %s tokens in %d files (%d sessions)", formatCount(int64(r.Total.Messages)), formatCount(r.Total.Tokens()), r.Files, r.Sessions)
+	if !r.First.IsZero() {
+		fmt.Fprintf(w, ", %s to %s", r.First.Local().Format(time.DateOnly), r.Last.Local().Format(time.DateOnly))
+	}
+	fmt.Fprintln(w)
+}
+
+func totalsRow(t Totals) []string {
+	return []string{
+		formatCount(int64(t.Messages)),
+		formatCount(t.InputTokens),
+		formatCount(t.OutputTokens),
+		formatCount(t.CacheCreation),
+		formatCount(t.CacheRead),
+		formatCount(t.Tokens()),
+	}
+}
+
+// formatCount formats n with comma thousands separators.
+func formatCount(n int64) string {
+	s := strconv.FormatInt(n, 10)
+	for i := len(s) - 3; i > 0 && s[i-1] != '-'; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
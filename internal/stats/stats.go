@@ -0,0 +1,387 @@
+// Package stats aggregates token usage recorded in session logs by project,
+// model, and time period, reading logs from any fetch.Source.
+package stats
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/fetch"
+	"github.com/13rac1/cclogs/internal/transcript"
+)
+
+// Time series buckets accepted by Options.Bucket.
+const (
+	BucketDay  = "day"
+	BucketWeek = "week"
+)
+
+// busiestCount is how many periods Report.Busiest lists.
+const busiestCount = 3
+
+// Options controls which logs are read and how usage is grouped.
+type Options struct {
+	Project  string         // Only read this project (all if empty)
+	Bucket   string         // BucketDay (default) or BucketWeek
+	Location *time.Location // Time zone for months and periods (time.Local if nil)
+}
+
+// Totals sums the usage of assistant responses.
+type Totals struct {
+	Messages      int   `json:"messages"` // Responses with usage
+	InputTokens   int64 `json:"input_tokens"`
+	OutputTokens  int64 `json:"output_tokens"`
+	CacheCreation int64 `json:"cache_creation_input_tokens"`
+	CacheRead     int64 `json:"cache_read_input_tokens"`
+}
+
+// Tokens returns all tokens counted, cached ones included.
+func (t Totals) Tokens() int64 {
+	return t.InputTokens + t.OutputTokens + t.CacheCreation + t.CacheRead
+}
+
+func (t *Totals) add(other Totals) {
+	t.Messages += other.Messages
+	t.InputTokens += other.InputTokens
+	t.OutputTokens += other.OutputTokens
+	t.CacheCreation += other.CacheCreation
+	t.CacheRead += other.CacheRead
+}
+
+// Group is the usage of one project, month, model, or period.
+type Group struct {
+	Name string `json:"name"`
+	Totals
+}
+
+// Project is the usage of one project, in total and by month ("2006-01").
+type Project struct {
+	Group
+	Sessions int     `json:"sessions"` // Logical sessions in the project's logs
+	Months   []Group `json:"months"`
+}
+
+// Failure is a log that could not be read.
+type Failure struct {
+	Ref string
+	Err error
+}
+
+// Report is the usage found in a set of logs.
+type Report struct {
+	Bucket   string    `json:"bucket"`
+	Files    int       `json:"files"`    // Logs read
+	Sessions int       `json:"sessions"` // Logical sessions in the logs read
+	Skipped  int       `json:"skipped"`  // Lines that were not JSON
+	First    time.Time `json:"first,omitzero"`
+	Last     time.Time `json:"last,omitzero"`
+	Total    Totals    `json:"total"`
+	Projects []Project `json:"projects"`
+	Models   []Group   `json:"models"`
+	Series   []Group   `json:"series"`  // Periods in time order, named by their first day
+	Busiest  []Group   `json:"busiest"` // Periods with the most tokens, most first
+	Failures []Failure `json:"-"`
+}
+
+// Run reads the logs of src and aggregates their usage. A log that cannot be
+// read is recorded as a failure and the others are still counted.
+func Run(ctx context.Context, src fetch.Source, opts Options) (*Report, error) {
+	switch opts.Bucket {
+	case "":
+		opts.Bucket = BucketDay
+	case BucketDay, BucketWeek:
+	default:
+		return nil, fmt.Errorf("unknown bucket %q (want %s or %s)", opts.Bucket, BucketDay, BucketWeek)
+	}
+	if opts.Location == nil {
+		opts.Location = time.Local
+	}
+
+	logs, err := src.Logs(ctx, opts.Project)
+	if err != nil {
+		return nil, err
+	}
+
+	a := newAggregator(opts)
+	for _, l := range logs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if err := a.readLog(ctx, src, l); err != nil {
+			a.report.Failures = append(a.report.Failures, Failure{Ref: l.Ref(), Err: err})
+			continue
+		}
+		a.report.Files++
+	}
+	return a.finish(), nil
+}
+
+// aggregator accumulates usage while logs are read.
+type aggregator struct {
+	opts     Options
+	report   Report
+	seen     map[string]bool // Responses already counted
+	projects map[string]map[string]*Totals
+	models   map[string]*Totals
+	series   map[string]*Totals
+	sessions map[string]int // Logical sessions by project
+}
+
+func newAggregator(opts Options) *aggregator {
+	return &aggregator{
+		opts:     opts,
+		report:   Report{Bucket: opts.Bucket},
+		seen:     make(map[string]bool),
+		projects: make(map[string]map[string]*Totals),
+		models:   make(map[string]*Totals),
+		series:   make(map[string]*Totals),
+		sessions: make(map[string]int),
+	}
+}
+
+func (a *aggregator) readLog(ctx context.Context, src fetch.Source, l fetch.Log) error {
+	body, err := src.Open(ctx, l.Ref())
+	if err != nil {
+		return err
+	}
+	defer func() { _ = body.Close() }()
+
+	scanner := bufio.NewScanner(body)
+	// Increase buffer for large lines (10MB max)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	// A file Claude Code appended to after a crash can hold several sessions
+	var sessions transcript.Splitter
+	lineNum := 0
+	for scanner.Scan() {
+		lineNum++
+		sessions.Add(scanner.Bytes())
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		u, ok, err := parseUsage(line)
+		if err != nil {
+			a.report.Skipped++
+			continue
+		}
+		if ok {
+			a.add(l.Project, u)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading %s line %d: %w", l.Ref(), lineNum+1, err)
+	}
+	n := len(sessions.Sessions())
+	a.report.Sessions += n
+	a.sessions[l.Project] += n
+	return nil
+}
+
+// add counts one response, once even if its usage is repeated on several
+// lines.
+func (a *aggregator) add(project string, u usage) {
+	if u.ID != "" {
+		if a.seen[u.ID] {
+			return
+		}
+		a.seen[u.ID] = true
+	}
+
+	a.report.Total.add(u.Totals)
+
+	months := a.projects[project]
+	if months == nil {
+		months = make(map[string]*Totals)
+		a.projects[project] = months
+	}
+	month := "unknown"
+	if !u.Time.IsZero() {
+		t := u.Time.In(a.opts.Location)
+		month = t.Format("2006-01")
+		addTo(a.series, a.period(t), u.Totals)
+		if a.report.First.IsZero() || u.Time.Before(a.report.First) {
+			a.report.First = u.Time
+		}
+		if u.Time.After(a.report.Last) {
+			a.report.Last = u.Time
+		}
+	}
+	addTo(months, month, u.Totals)
+
+	model := u.Model
+	if model == "" {
+		model = "unknown"
+	}
+	addTo(a.models, model, u.Totals)
+}
+
+// period returns the name of the series bucket holding t: its day, or the
+// Monday starting its week.
+func (a *aggregator) period(t time.Time) string {
+	if a.opts.Bucket == BucketWeek {
+		offset := (int(t.Weekday()) + 6) % 7 // Days since Monday
+		t = t.AddDate(0, 0, -offset)
+	}
+	return t.Format(time.DateOnly)
+}
+
+func addTo(m map[string]*Totals, key string, t Totals) {
+	if m[key] == nil {
+		m[key] = &Totals{}
+	}
+	m[key].add(t)
+}
+
+// finish sorts the groups into the report: projects and models by tokens,
+// most first; the series in time order.
+func (a *aggregator) finish() *Report {
+	r := &a.report
+
+	r.Projects = []Project{}
+	for name, months := range a.projects {
+		p := Project{Group: Group{Name: name}, Sessions: a.sessions[name], Months: sortedByName(months)}
+		for _, m := range p.Months {
+			p.add(m.Totals)
+		}
+		r.Projects = append(r.Projects, p)
+	}
+	sort.Slice(r.Projects, func(i, j int) bool {
+		return byTokens(r.Projects[i].Group, r.Projects[j].Group)
+	})
+
+	r.Models = sortedByName(a.models)
+	sort.SliceStable(r.Models, func(i, j int) bool { return byTokens(r.Models[i], r.Models[j]) })
+
+	r.Series = sortedByName(a.series)
+
+	r.Busiest = append([]Group{}, r.Series...)
+	sort.SliceStable(r.Busiest, func(i, j int) bool { return byTokens(r.Busiest[i], r.Busiest[j]) })
+	if len(r.Busiest) > busiestCount {
+		r.Busiest = r.Busiest[:busiestCount]
+	}
+	return r
+}
+
+func sortedByName(m map[string]*Totals) []Group {
+	groups := make([]Group, 0, len(m))
+	for name, t := range m {
+		groups = append(groups, Group{Name: name, Totals: *t})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
+
+// byTokens orders groups by total tokens, most first, then by name.
+func byTokens(a, b Group) bool {
+	if a.Tokens() != b.Tokens() {
+		return a.Tokens() > b.Tokens()
+	}
+	return a.Name < b.Name
+}
+
+// usage is what one log line says about a response.
+type usage struct {
+	ID    string // Response identity for de-duplication ("" if unknown)
+	Model string
+	Time  time.Time
+	Totals
+}
+
+// record holds the fields of a log line that carry usage. Claude Code has
+// kept usage under message, but older logs put model and usage at the top
+// level, and numbers or timestamps may be encoded differently.
+type record struct {
+	RequestID string    `json:"requestId"`
+	Timestamp flexTime  `json:"timestamp"`
+	Model     string    `json:"model"`
+	Usage     *rawUsage `json:"usage"`
+	Message   *struct {
+		ID    string    `json:"id"`
+		Model string    `json:"model"`
+		Usage *rawUsage `json:"usage"`
+	} `json:"message"`
+}
+
+type rawUsage struct {
+	InputTokens   flexInt `json:"input_tokens"`
+	OutputTokens  flexInt `json:"output_tokens"`
+	CacheCreation flexInt `json:"cache_creation_input_tokens"`
+	CacheRead     flexInt `json:"cache_read_input_tokens"`
+}
+
+// parseUsage returns the usage on a log line. ok is false for lines without
+// usage; an error means the line is not a JSON object.
+func parseUsage(line []byte) (u usage, ok bool, err error) {
+	var rec record
+	if err := json.Unmarshal(line, &rec); err != nil {
+		return usage{}, false, err
+	}
+
+	raw, model := rec.Usage, rec.Model
+	if rec.Message != nil {
+		if rec.Message.Usage != nil {
+			raw = rec.Message.Usage
+		}
+		if rec.Message.Model != "" {
+			model = rec.Message.Model
+		}
+		if rec.Message.ID != "" {
+			u.ID = rec.Message.ID + ":" + rec.RequestID
+		}
+	}
+	if raw == nil {
+		return usage{}, false, nil
+	}
+
+	u.Model = model
+	u.Time = time.Time(rec.Timestamp)
+	u.Totals = Totals{
+		Messages:      1,
+		InputTokens:   int64(raw.InputTokens),
+		OutputTokens:  int64(raw.OutputTokens),
+		CacheCreation: int64(raw.CacheCreation),
+		CacheRead:     int64(raw.CacheRead),
+	}
+	return u, true, nil
+}
+
+// flexInt decodes a count written as a number or a numeric string. Anything
+// else counts as zero rather than failing the line.
+type flexInt int64
+
+func (n *flexInt) UnmarshalJSON(data []byte) error {
+	s := string(bytes.Trim(data, `"`))
+	if f, err := strconv.ParseFloat(s, 64); err == nil {
+		*n = flexInt(f)
+	}
+	return nil
+}
+
+// flexTime decodes an RFC 3339 timestamp or Unix time in seconds or
+// milliseconds. Anything else leaves the time zero.
+type flexTime time.Time
+
+func (t *flexTime) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		if parsed, err := time.Parse(time.RFC3339Nano, s); err == nil {
+			*t = flexTime(parsed)
+		}
+		return nil
+	}
+	if f, err := strconv.ParseFloat(string(data), 64); err == nil {
+		if f > 1e12 { // Milliseconds
+			*t = flexTime(time.UnixMilli(int64(f)))
+		} else {
+			*t = flexTime(time.Unix(int64(f), 0))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,180 @@
+package stats
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/fetch"
+)
+
+var testdata = fetch.LocalSource{Root: "testdata/projects"}
+
+func names(groups []Group) []string {
+	var out []string
+	for _, g := range groups {
+		out = append(out, g.Name)
+	}
+	return out
+}
+
+func tokens(groups []Group) []int64 {
+	var out []int64
+	for _, g := range groups {
+		out = append(out, g.Tokens())
+	}
+	return out
+}
+
+func TestRun(t *testing.T) {
+	r, err := Run(context.Background(), testdata, Options{Location: time.UTC})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	if r.Files != 3 || r.Skipped != 2 || len(r.Failures) != 0 {
+		t.Errorf("files = %d, skipped = %d, failures = %v; want 3, 2, none", r.Files, r.Skipped, r.Failures)
+	}
+	// msg_1 is logged twice but counted once
+	want := Totals{Messages: 6, InputTokens: 172, OutputTokens: 530, CacheCreation: 1000, CacheRead: 2000}
+	if r.Total != want {
+		t.Errorf("Total = %+v, want %+v", r.Total, want)
+	}
+	if !r.First.Equal(time.Date(2025, 3, 3, 9, 0, 1, 0, time.UTC)) || !r.Last.Equal(time.Date(2025, 4, 1, 8, 0, 0, 0, time.UTC)) {
+		t.Errorf("First, Last = %v, %v", r.First, r.Last)
+	}
+
+	if len(r.Projects) != 2 || r.Projects[0].Name != "app" || r.Projects[0].Tokens() != 3698 {
+		t.Fatalf("Projects = %+v, want app (3698 tokens) first", r.Projects)
+	}
+	if got := names(r.Projects[0].Months); !reflect.DeepEqual(got, []string{"2025-03", "2025-04"}) {
+		t.Errorf("app months = %v", got)
+	}
+	if got := tokens(r.Projects[0].Months); !reflect.DeepEqual(got, []int64{3683, 15}) {
+		t.Errorf("app month tokens = %v", got)
+	}
+	if got := names(r.Projects[1].Months); !reflect.DeepEqual(got, []string{"unknown"}) {
+		t.Errorf("lib months = %v, want unknown (no usable timestamp)", got)
+	}
+
+	if got := names(r.Models); !reflect.DeepEqual(got, []string{"claude-opus-4", "claude-sonnet-4", "claude-3-5-sonnet", "unknown"}) {
+		t.Errorf("Models = %v", got)
+	}
+	if got := tokens(r.Models); !reflect.DeepEqual(got, []int64{2550, 1135, 13, 4}) {
+		t.Errorf("model tokens = %v", got)
+	}
+
+	if got := names(r.Series); !reflect.DeepEqual(got, []string{"2025-03-03", "2025-03-05", "2025-04-01"}) {
+		t.Errorf("Series = %v", got)
+	}
+	if got := names(r.Busiest); !reflect.DeepEqual(got, []string{"2025-03-05", "2025-03-03", "2025-04-01"}) {
+		t.Errorf("Busiest = %v", got)
+	}
+}
+
+func TestRunWeekly(t *testing.T) {
+	r, err := Run(context.Background(), testdata, Options{Bucket: BucketWeek, Location: time.UTC})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	// Weeks are named by their Monday
+	if got := names(r.Series); !reflect.DeepEqual(got, []string{"2025-03-03", "2025-03-31"}) {
+		t.Errorf("Series = %v", got)
+	}
+	if got := tokens(r.Series); !reflect.DeepEqual(got, []int64{3683, 15}) {
+		t.Errorf("series tokens = %v", got)
+	}
+}
+
+func TestRunOptions(t *testing.T) {
+	r, err := Run(context.Background(), testdata, Options{Project: "lib", Location: time.UTC})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if r.Files != 1 || r.Total.Tokens() != 4 {
+		t.Errorf("lib: files = %d, tokens = %d; want 1 and 4", r.Files, r.Total.Tokens())
+	}
+
+	if _, err := Run(context.Background(), testdata, Options{Bucket: "month"}); err == nil {
+		t.Error("Run() with unknown bucket succeeded")
+	}
+}
+
+func TestRunSessions(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	// Session "b" was appended to the file of session "a" after a crash
+	content := `{"type":"user","sessionId":"a","message":{"role":"user","content":"hi"}}
+{"type":"assistant","sessionId":"a","message":{"id":"m1","usage":{"input_tokens":10,"output_tokens":5}}}
+{"type":"user","sessionId":"b","message":{"role":"user","content":"again"}}
+{"type":"assistant","sessionId":"b","message":{"id":"m2","usage":{"input_tokens":7,"output_tokens":3}}}
+`
+	if err := os.WriteFile(filepath.Join(root, "app", "s.jsonl"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Run(context.Background(), fetch.LocalSource{Root: root}, Options{Location: time.UTC})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if r.Files != 1 || r.Sessions != 2 || len(r.Projects) != 1 || r.Projects[0].Sessions != 2 {
+		t.Errorf("files = %d, sessions = %d, projects = %+v; want 1 file with 2 sessions", r.Files, r.Sessions, r.Projects)
+	}
+}
+
+// failingSource lists a log that can't be opened alongside the fixtures.
+type failingSource struct{ fetch.LocalSource }
+
+func (s failingSource) Logs(ctx context.Context, project string) ([]fetch.Log, error) {
+	logs, err := s.LocalSource.Logs(ctx, project)
+	return append(logs, fetch.Log{Project: "app", Path: "gone.jsonl"}), err
+}
+
+func (s failingSource) Open(ctx context.Context, ref string) (io.ReadCloser, error) {
+	if ref == "app/gone.jsonl" {
+		return nil, errors.New("NoSuchKey")
+	}
+	return s.LocalSource.Open(ctx, ref)
+}
+
+func TestRunFailures(t *testing.T) {
+	r, err := Run(context.Background(), failingSource{testdata}, Options{Location: time.UTC})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if r.Files != 3 || len(r.Failures) != 1 || r.Failures[0].Ref != "app/gone.jsonl" {
+		t.Errorf("files = %d, failures = %v; want 3 and app/gone.jsonl", r.Files, r.Failures)
+	}
+}
+
+func TestPrintReport(t *testing.T) {
+	r, err := Run(context.Background(), testdata, Options{Location: time.UTC})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	PrintReport(&buf, r)
+	out := buf.String()
+	for _, want := range []string{"Projects", "claude-opus-4", "2,550", "By day", "Busiest days: 2025-03-05 (2,563 tokens)", "6 responses, 3,702 tokens in 3 files (3 sessions)"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+
+	buf.Reset()
+	PrintReport(&buf, &Report{Files: 2})
+	if got := buf.String(); got != "No token usage found in 2 files.\n" {
+		t.Errorf("empty report = %q", got)
+	}
+}
@@ -0,0 +1,96 @@
+//go:build otel
+
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/13rac1/cclogs/internal/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// Setup installs OTLP/HTTP trace and metric exporters for cfg.OTLPEndpoint,
+// a collector base URL such as http://localhost:4318. It returns a function
+// that flushes and stops them within ShutdownTimeout; calls after the first
+// do nothing. With no endpoint it installs nothing. Export errors are written
+// to errOut once.
+func Setup(cfg types.TelemetryConfig, serviceVersion, machineID string, errOut io.Writer) (shutdown func(), err error) {
+	if cfg.OTLPEndpoint == "" {
+		return func() {}, nil
+	}
+
+	tracesURL, err := signalURL(cfg.OTLPEndpoint, "traces")
+	if err != nil {
+		return nil, err
+	}
+	metricsURL, err := signalURL(cfg.OTLPEndpoint, "metrics")
+	if err != nil {
+		return nil, err
+	}
+
+	// Exporters connect lazily, so creating them never blocks on the collector
+	ctx := context.Background()
+	traceExp, err := otlptracehttp.New(ctx,
+		otlptracehttp.WithEndpointURL(tracesURL),
+		otlptracehttp.WithHeaders(cfg.Headers),
+		otlptracehttp.WithTimeout(ExportTimeout),
+		otlptracehttp.WithRetry(otlptracehttp.RetryConfig{Enabled: false}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating trace exporter: %w", err)
+	}
+	metricExp, err := otlpmetrichttp.New(ctx,
+		otlpmetrichttp.WithEndpointURL(metricsURL),
+		otlpmetrichttp.WithHeaders(cfg.Headers),
+		otlpmetrichttp.WithTimeout(ExportTimeout),
+		otlpmetrichttp.WithRetry(otlpmetrichttp.RetryConfig{Enabled: false}),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("creating metric exporter: %w", err)
+	}
+
+	res := resource.NewSchemaless(
+		attribute.String("service.name", "cclogs"),
+		attribute.String("service.version", serviceVersion),
+		attribute.String("cclogs.machine_id", machineID),
+	)
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithResource(res),
+		sdktrace.WithBatcher(traceExp, sdktrace.WithExportTimeout(ExportTimeout)),
+	)
+	mp := sdkmetric.NewMeterProvider(
+		sdkmetric.WithResource(res),
+		sdkmetric.WithReader(sdkmetric.NewPeriodicReader(metricExp, sdkmetric.WithTimeout(ExportTimeout))),
+	)
+
+	var once sync.Once
+	warn := func(err error) {
+		once.Do(func() {
+			fmt.Fprintf(errOut, "Warning: exporting telemetry to %s: %v\n", cfg.OTLPEndpoint, err)
+		})
+	}
+	otel.SetErrorHandler(otel.ErrorHandlerFunc(warn))
+	otel.SetTracerProvider(tp)
+	otel.SetMeterProvider(mp)
+
+	var stopOnce sync.Once
+	return func() {
+		stopOnce.Do(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+			defer cancel()
+			if err := errors.Join(tp.Shutdown(ctx), mp.Shutdown(ctx)); err != nil {
+				warn(err)
+			}
+		})
+	}, nil
+}
@@ -0,0 +1,26 @@
+//go:build !otel
+
+package telemetry
+
+import (
+	"errors"
+	"io"
+
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+// errNotBuilt is returned by Setup for a configured endpoint in builds
+// without the otel tag.
+var errNotBuilt = errors.New("telemetry.otlp_endpoint is set, but this build of cclogs has no telemetry export (build with -tags otel)")
+
+// Setup reports that telemetry cannot be exported when cfg.OTLPEndpoint is
+// set; the global providers stay no-ops either way.
+func Setup(cfg types.TelemetryConfig, serviceVersion, machineID string, errOut io.Writer) (shutdown func(), err error) {
+	if cfg.OTLPEndpoint == "" {
+		return func() {}, nil
+	}
+	if _, err := signalURL(cfg.OTLPEndpoint, "traces"); err != nil {
+		return nil, err
+	}
+	return nil, errNotBuilt
+}
@@ -0,0 +1,28 @@
+//go:build !otel
+
+package telemetry
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/types"
+	"go.opentelemetry.io/otel"
+)
+
+func TestSetupNotBuilt(t *testing.T) {
+	resetProviders(t)
+	before := otel.GetTracerProvider()
+
+	_, err := Setup(types.TelemetryConfig{OTLPEndpoint: "http://localhost:4318"}, "dev", "m-1", nil)
+	if !errors.Is(err, errNotBuilt) {
+		t.Errorf("Setup() error = %v, want %v", err, errNotBuilt)
+	}
+	if otel.GetTracerProvider() != before {
+		t.Error("Setup() installed a tracer provider without the otel tag")
+	}
+
+	if _, err := Setup(types.TelemetryConfig{OTLPEndpoint: "localhost:4318"}, "dev", "m-1", nil); err == nil || errors.Is(err, errNotBuilt) {
+		t.Errorf("Setup() with an invalid endpoint: error = %v, want the URL error", err)
+	}
+}
@@ -0,0 +1,85 @@
+//go:build otel
+
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+func TestSetupExports(t *testing.T) {
+	resetProviders(t)
+
+	var mu sync.Mutex
+	paths := make(map[string]string)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths[r.URL.Path] = r.Header.Get("Authorization")
+		mu.Unlock()
+	}))
+	defer srv.Close()
+
+	cfg := types.TelemetryConfig{OTLPEndpoint: srv.URL + "/otlp/", Headers: map[string]string{"Authorization": "Bearer t"}}
+	shutdown, err := Setup(cfg, "dev", "m-1", &bytes.Buffer{})
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	_, span := Tracer().Start(context.Background(), "upload")
+	span.End()
+	c, err := Meter().Int64Counter("cclogs.files.uploaded")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Add(context.Background(), 1)
+	shutdown()
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, path := range []string{"/otlp/v1/traces", "/otlp/v1/metrics"} {
+		if auth, ok := paths[path]; !ok || auth != "Bearer t" {
+			t.Errorf("export to %s: received %v, Authorization %q", path, ok, auth)
+		}
+	}
+}
+
+func TestSetupUnreachableCollector(t *testing.T) {
+	resetProviders(t)
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	endpoint := srv.URL
+	srv.Close()
+
+	var errOut bytes.Buffer
+	shutdown, err := Setup(types.TelemetryConfig{OTLPEndpoint: endpoint}, "dev", "m-1", &errOut)
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+
+	start := time.Now()
+	for range 100 {
+		_, span := Tracer().Start(context.Background(), "file")
+		span.End()
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("recording spans took %v with the collector down", elapsed)
+	}
+
+	start = time.Now()
+	shutdown()
+	shutdown()
+	if elapsed := time.Since(start); elapsed > ShutdownTimeout+time.Second {
+		t.Errorf("shutdown took %v, want at most %v", elapsed, ShutdownTimeout)
+	}
+	if got := strings.Count(errOut.String(), "Warning:"); got != 1 {
+		t.Errorf("warnings = %q, want exactly one", errOut.String())
+	}
+}
@@ -0,0 +1,63 @@
+// Package telemetry exports OpenTelemetry traces and metrics of cclogs runs
+// to an OTLP/HTTP collector. Nothing is exported unless
+// telemetry.otlp_endpoint is set: until Setup installs providers, the global
+// OpenTelemetry providers are no-ops, so instrumented code costs next to
+// nothing. Export happens in the background and failures are reported once,
+// so an unreachable collector never fails or stalls a run.
+//
+// The exporters and SDK are only compiled in with the otel build tag
+// (go build -tags otel), which keeps their dependencies, gRPC among them, out
+// of the default binary. Without it Setup refuses a configured endpoint.
+package telemetry
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// InstrumentationName names the tracer and meter cclogs records with.
+const InstrumentationName = "github.com/13rac1/cclogs"
+
+const (
+	// ExportTimeout bounds each request to the collector.
+	ExportTimeout = 2 * time.Second
+	// ShutdownTimeout bounds flushing buffered spans and metrics at exit.
+	ShutdownTimeout = 3 * time.Second
+)
+
+// Tracer returns the tracer for cclogs spans.
+func Tracer() trace.Tracer {
+	return otel.Tracer(InstrumentationName)
+}
+
+// Meter returns the meter for cclogs counters.
+func Meter() metric.Meter {
+	return otel.Meter(InstrumentationName)
+}
+
+// signalURL returns the OTLP/HTTP URL for signal ("traces" or "metrics")
+// below the collector base URL endpoint.
+func signalURL(endpoint, signal string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("telemetry.otlp_endpoint must be an http or https URL, got %q", endpoint)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/v1/" + signal
+	return u.String(), nil
+}
+
+// EndSpan records err, if any, as the span's status and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,32 @@
+package telemetry
+
+import (
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/types"
+	"go.opentelemetry.io/otel"
+	metricnoop "go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// resetProviders restores the no-op providers Setup replaced.
+func resetProviders(t *testing.T) {
+	t.Cleanup(func() {
+		otel.SetTracerProvider(noop.NewTracerProvider())
+		otel.SetMeterProvider(metricnoop.NewMeterProvider())
+	})
+}
+
+func TestSetupDisabled(t *testing.T) {
+	resetProviders(t)
+	before := otel.GetTracerProvider()
+
+	shutdown, err := Setup(types.TelemetryConfig{}, "dev", "m-1", nil)
+	if err != nil {
+		t.Fatalf("Setup() error = %v", err)
+	}
+	shutdown()
+	if otel.GetTracerProvider() != before {
+		t.Error("Setup() without an endpoint installed a tracer provider")
+	}
+}
@@ -0,0 +1,119 @@
+// Package transcript reads Claude Code session logs and splits them into the
+// logical sessions they contain.
+package transcript
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Session is a run of consecutive records sharing a sessionId. A file that
+// Claude Code appended to after a crash can hold several.
+type Session struct {
+	ID           string // sessionId of the records ("" if they have none)
+	FirstLine    int    // 1-based line number of the first record
+	LastLine     int    // 1-based line number of the last record
+	Records      int    // Lines in the session, including ones that aren't JSON
+	Messages     int    // User and assistant records
+	InputTokens  int64  // Sum of message.usage.input_tokens
+	OutputTokens int64  // Sum of message.usage.output_tokens
+}
+
+// record holds the fields of a log line that matter for splitting and stats.
+type record struct {
+	SessionID string `json:"sessionId"`
+	Type      string `json:"type"`
+	Message   struct {
+		Usage struct {
+			InputTokens  int64 `json:"input_tokens"`
+			OutputTokens int64 `json:"output_tokens"`
+		} `json:"usage"`
+	} `json:"message"`
+}
+
+// Splitter assigns the lines of a JSONL session log to logical sessions as
+// they are read, for callers that scan the log themselves.
+type Splitter struct {
+	sessions []Session
+	lineNum  int
+}
+
+// Add reads the next line of the log, blank lines included so line numbers
+// stay right. A new session starts wherever the sessionId changes; lines
+// without one (summaries, malformed lines) stay with the session before them.
+func (sp *Splitter) Add(line []byte) {
+	sp.lineNum++
+	if len(line) == 0 {
+		return
+	}
+
+	var rec record
+	if err := json.Unmarshal(line, &rec); err != nil {
+		rec = record{}
+	}
+
+	n := len(sp.sessions)
+	switch {
+	case n == 0:
+		sp.sessions = append(sp.sessions, Session{ID: rec.SessionID, FirstLine: sp.lineNum})
+	case rec.SessionID != "" && sp.sessions[n-1].ID == "":
+		// Leading lines without an ID belong to the first identified session
+		sp.sessions[n-1].ID = rec.SessionID
+	case rec.SessionID != "" && rec.SessionID != sp.sessions[n-1].ID:
+		sp.sessions = append(sp.sessions, Session{ID: rec.SessionID, FirstLine: sp.lineNum})
+	}
+
+	s := &sp.sessions[len(sp.sessions)-1]
+	s.LastLine = sp.lineNum
+	s.Records++
+	if rec.Type == "user" || rec.Type == "assistant" {
+		s.Messages++
+	}
+	s.InputTokens += rec.Message.Usage.InputTokens
+	s.OutputTokens += rec.Message.Usage.OutputTokens
+}
+
+// Sessions returns the sessions of the lines added so far, in file order.
+func (sp *Splitter) Sessions() []Session {
+	return sp.sessions
+}
+
+// Split reads a JSONL session log and returns its logical sessions in file
+// order, as Splitter assigns them. A file without session IDs is returned as
+// a single session.
+func Split(r io.Reader) ([]Session, error) {
+	scanner := bufio.NewScanner(r)
+	// Increase buffer for large lines (10MB max)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	var sp Splitter
+	for scanner.Scan() {
+		sp.Add(scanner.Bytes())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("reading line %d: %w", sp.lineNum+1, err)
+	}
+	return sp.Sessions(), nil
+}
+
+// Extract copies the lines of s from r, a reader positioned at the start of
+// the same file Split read, to w.
+func Extract(r io.Reader, s Session, w io.Writer) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 10*1024*1024)
+
+	for lineNum := 1; lineNum <= s.LastLine && scanner.Scan(); lineNum++ {
+		if lineNum < s.FirstLine {
+			continue
+		}
+		if _, err := w.Write(append(scanner.Bytes(), '\n')); err != nil {
+			return fmt.Errorf("writing line %d: %w", lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("reading session %s: %w", s.ID, err)
+	}
+	return nil
+}
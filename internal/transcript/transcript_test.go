@@ -0,0 +1,83 @@
+package transcript
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+// twoSessions is a file Claude Code appended to after a crash: session "a"
+// ends abruptly and session "b" continues in the same file.
+const twoSessions = `{"type":"summary","summary":"Fix tests"}
+{"type":"user","sessionId":"a","uuid":"1","message":{"role":"user","content":"hi"}}
+{"type":"assistant","sessionId":"a","uuid":"2","message":{"usage":{"input_tokens":10,"output_tokens":5}}}
+{"type":"user","sessionId":"a","uuid":"3","message":{"role":"user","content":"run them"}}
+{"type":"user","sessionId":"b","uuid":"4","message":{"role":"user","content":"again"}}
+not json
+{"type":"assistant","sessionId":"b","uuid":"5","message":{"usage":{"input_tokens":7,"output_tokens":3}}}
+{"type":"assistant","sessionId":"b","uuid":"6","message":{"usage":{"input_tokens":1,"output_tokens":2}}}
+`
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    []Session
+	}{
+		{
+			name:    "two sequential sessions",
+			content: twoSessions,
+			want: []Session{
+				{ID: "a", FirstLine: 1, LastLine: 4, Records: 4, Messages: 3, InputTokens: 10, OutputTokens: 5},
+				{ID: "b", FirstLine: 5, LastLine: 8, Records: 4, Messages: 3, InputTokens: 8, OutputTokens: 5},
+			},
+		},
+		{
+			name:    "no session fields",
+			content: "{\"type\":\"user\"}\n{\"type\":\"assistant\",\"message\":{\"usage\":{\"output_tokens\":4}}}\n",
+			want: []Session{
+				{FirstLine: 1, LastLine: 2, Records: 2, Messages: 2, OutputTokens: 4},
+			},
+		},
+		{
+			name:    "empty file",
+			content: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Split(strings.NewReader(tt.content))
+			if err != nil {
+				t.Fatalf("Split() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Split() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("session %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestExtract(t *testing.T) {
+	sessions, err := Split(strings.NewReader(twoSessions))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.SplitAfter(twoSessions, "\n")
+	for _, s := range sessions {
+		var out bytes.Buffer
+		if err := Extract(strings.NewReader(twoSessions), s, &out); err != nil {
+			t.Fatalf("Extract(%s) error = %v", s.ID, err)
+		}
+		want := strings.Join(lines[s.FirstLine-1:s.LastLine], "")
+		if out.String() != want {
+			t.Errorf("Extract(%s) =\n%s\nwant\n%s", s.ID, out.String(), want)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Package trash moves files to the desktop trash instead of deleting them, so
+// a mistaken prune can be undone from the file manager. On Linux and other
+// Unix systems it follows the freedesktop.org trash specification; on macOS
+// files go to ~/.Trash.
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"time"
+)
+
+// Move moves the file at path into the user's trash.
+func Move(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return fmt.Errorf("resolving %s: %w", path, err)
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return fmt.Errorf("getting home directory: %w", err)
+	}
+
+	switch runtime.GOOS {
+	case "windows":
+		return errors.New("moving files to the Recycle Bin is not supported")
+	case "darwin":
+		return moveMac(abs, filepath.Join(home, ".Trash"))
+	default:
+		dataHome := os.Getenv("XDG_DATA_HOME")
+		if dataHome == "" {
+			dataHome = filepath.Join(home, ".local", "share")
+		}
+		return moveFreedesktop(abs, filepath.Join(dataHome, "Trash"), time.Now())
+	}
+}
+
+func moveMac(abs, dir string) error {
+	base := filepath.Base(abs)
+	for n := 1; ; n++ {
+		dst := filepath.Join(dir, candidate(base, n))
+		if _, err := os.Lstat(dst); err == nil {
+			continue
+		}
+		if err := os.Rename(abs, dst); err != nil {
+			return fmt.Errorf("moving %s to the trash: %w", abs, err)
+		}
+		return nil
+	}
+}
+
+// moveFreedesktop moves abs into trash/files and records where it came from
+// in trash/info, so file managers can restore it.
+func moveFreedesktop(abs, trash string, now time.Time) error {
+	filesDir := filepath.Join(trash, "files")
+	infoDir := filepath.Join(trash, "info")
+	for _, dir := range []string{filesDir, infoDir} {
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("creating trash directory: %w", err)
+		}
+	}
+
+	info := fmt.Sprintf("[Trash Info]\nPath=%s\nDeletionDate=%s\n",
+		(&url.URL{Path: abs}).EscapedPath(), now.Format("2006-01-02T15:04:05"))
+
+	base := filepath.Base(abs)
+	for n := 1; ; n++ {
+		name := candidate(base, n)
+		// Creating the info file exclusively reserves the name
+		infoPath := filepath.Join(infoDir, name+".trashinfo")
+		f, err := os.OpenFile(infoPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("writing trash info: %w", err)
+		}
+		_, werr := f.WriteString(info)
+		if cerr := f.Close(); werr == nil {
+			werr = cerr
+		}
+		if werr != nil {
+			_ = os.Remove(infoPath)
+			return fmt.Errorf("writing trash info: %w", werr)
+		}
+
+		dst := filepath.Join(filesDir, name)
+		if _, err := os.Lstat(dst); err == nil {
+			_ = os.Remove(infoPath)
+			continue
+		}
+		if err := os.Rename(abs, dst); err != nil {
+			_ = os.Remove(infoPath)
+			return fmt.Errorf("moving %s to the trash: %w", abs, err)
+		}
+		return nil
+	}
+}
+
+// candidate returns the n-th name tried for base in the trash: base itself,
+// then "name.2.ext", "name.3.ext", and so on.
+func candidate(base string, n int) string {
+	if n == 1 {
+		return base
+	}
+	ext := filepath.Ext(base)
+	return base[:len(base)-len(ext)] + "." + strconv.Itoa(n) + ext
+}
@@ -0,0 +1,62 @@
+package trash
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMoveFreedesktop(t *testing.T) {
+	src := filepath.Join(t.TempDir(), "my session.jsonl")
+	trash := filepath.Join(t.TempDir(), "Trash")
+	now := time.Date(2025, 6, 1, 9, 13, 5, 0, time.Local)
+
+	for _, want := range []string{"my session.jsonl", "my session.2.jsonl"} {
+		if err := os.WriteFile(src, []byte(want), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := moveFreedesktop(src, trash, now); err != nil {
+			t.Fatalf("moveFreedesktop() error = %v", err)
+		}
+
+		if _, err := os.Stat(src); !os.IsNotExist(err) {
+			t.Errorf("source still exists: %v", err)
+		}
+		data, err := os.ReadFile(filepath.Join(trash, "files", want))
+		if err != nil || string(data) != want {
+			t.Errorf("trashed file %s = %q, %v", want, data, err)
+		}
+		info, err := os.ReadFile(filepath.Join(trash, "info", want+".trashinfo"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, line := range []string{
+			"[Trash Info]",
+			"Path=" + strings.ReplaceAll(filepath.ToSlash(src), " ", "%20"),
+			"DeletionDate=2025-06-01T09:13:05",
+		} {
+			if !strings.Contains(string(info), line+"\n") {
+				t.Errorf("trash info missing %q:\n%s", line, info)
+			}
+		}
+	}
+}
+
+func TestCandidate(t *testing.T) {
+	tests := []struct {
+		base string
+		n    int
+		want string
+	}{
+		{"a.jsonl", 1, "a.jsonl"},
+		{"a.jsonl", 3, "a.3.jsonl"},
+		{"noext", 2, "noext.2"},
+	}
+	for _, tt := range tests {
+		if got := candidate(tt.base, tt.n); got != tt.want {
+			t.Errorf("candidate(%q, %d) = %q, want %q", tt.base, tt.n, got, tt.want)
+		}
+	}
+}
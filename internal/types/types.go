@@ -2,16 +2,69 @@
 // This includes configuration structs, project metadata, and shared types.
 package types
 
+import "time"
+
 // Config represents the complete configuration for cclogs.
 type Config struct {
-	Local LocalConfig `yaml:"local"`
-	S3    S3Config    `yaml:"s3"`
-	Auth  AuthConfig  `yaml:"auth"`
+	Local     LocalConfig     `yaml:"local"`
+	S3        S3Config        `yaml:"s3"`
+	Auth      AuthConfig      `yaml:"auth"`
+	Upload    UploadConfig    `yaml:"upload"`
+	Redact    RedactConfig    `yaml:"redact"`
+	Discovery DiscoveryConfig `yaml:"discovery"`
+	Notify    NotifyConfig    `yaml:"notify"`
+	Telemetry TelemetryConfig `yaml:"telemetry"`
+
+	// Targets are extra named destinations, each with its own s3 and auth
+	// sections. The top-level s3 and auth sections form the "default" target.
+	Targets map[string]Target `yaml:"targets"`
+
+	// Lang selects the message language, e.g. "de" (default: English).
+	// CCLOGS_LANG overrides it.
+	Lang string `yaml:"lang"`
+
+	// Identity is resolved at load time, never read from the config file.
+	Identity Identity `yaml:"-"`
+
+	// TargetName is the target S3 and Auth were taken from, set when one is
+	// selected by name (config.Target); empty otherwise.
+	TargetName string `yaml:"-"`
+}
+
+// Target is a named destination: a bucket and the credentials to reach it.
+type Target struct {
+	S3   S3Config   `yaml:"s3"`
+	Auth AuthConfig `yaml:"auth"`
+}
+
+// Identity identifies this machine across runs. ID is what key scoping,
+// manifests, and receipts record; Hostname is informational only.
+type Identity struct {
+	ID        string // Effective machine ID (sanitized for use in keys)
+	Source    string // "config" (local.machine_id) or "state" (generated)
+	Hostname  string // os.Hostname() at load time
+	StatePath string // File the generated ID is persisted in
+	Previous  string // ID used by the previous run, if it differs from ID
 }
 
 // LocalConfig holds local filesystem settings.
 type LocalConfig struct {
 	ProjectsRoot string `yaml:"projects_root"`
+
+	// MachineID names this machine in object keys, manifests, and receipts
+	// (default: the hostname of the first run, kept in the state file).
+	MachineID string `yaml:"machine_id"`
+
+	// Extensions lists the file extensions treated as session logs (default [".jsonl"]).
+	Extensions []string `yaml:"extensions"`
+
+	// ProjectDepth makes directories this many levels below the projects root
+	// the projects, named by their path, e.g. "client/app" (default 1).
+	ProjectDepth int `yaml:"project_depth"`
+
+	// ProjectMarker makes directories containing a file of this name the
+	// projects instead, found up to config.MaxProjectDepth levels down.
+	ProjectMarker string `yaml:"project_marker"`
 }
 
 // S3Config holds S3-compatible storage settings.
@@ -21,6 +74,47 @@ type S3Config struct {
 	Region         string `yaml:"region"`
 	Endpoint       string `yaml:"endpoint"`
 	ForcePathStyle bool   `yaml:"force_path_style"`
+
+	// CABundle is a PEM file of extra CA certificates to trust (e.g. private MinIO).
+	CABundle string `yaml:"ca_bundle"`
+	// InsecureSkipVerify disables TLS certificate verification (development only).
+	InsecureSkipVerify bool `yaml:"insecure_skip_verify"`
+	// SSECKey is a customer-provided encryption key (SSE-C): base64, or a file
+	// holding one. It is sent with every object read and write.
+	SSECKey string `yaml:"sse_c_key"`
+
+	// KeyLayout selects how object keys are built: "flat" (default) or "by_host".
+	KeyLayout string `yaml:"key_layout"`
+	// KeyTemplate builds object keys from placeholders (default follows KeyLayout).
+	KeyTemplate string `yaml:"key_template"`
+	// ManifestKey is the manifest's name under the key prefix (default .manifest.json).
+	ManifestKey string `yaml:"manifest_key"`
+	// ArchiveName names the archive in the manifest, so machines sharing a
+	// prefix can tell they are joining the same one (optional).
+	ArchiveName string `yaml:"archive_name"`
+	// ManifestHistory is how many backups of the manifest are kept before it
+	// is overwritten (default 5; negative disables backups).
+	ManifestHistory int `yaml:"manifest_history"`
+
+	// OperationTimeout bounds each individual S3 API call (default 60s).
+	OperationTimeout time.Duration `yaml:"operation_timeout"`
+
+	// ContentType is sent with uploaded logs (default application/x-ndjson).
+	ContentType string `yaml:"content_type"`
+	// CacheControl is an optional Cache-Control header for uploaded logs.
+	CacheControl string `yaml:"cache_control"`
+
+	// ObjectLock sets an Object Lock retention period on each uploaded log.
+	ObjectLock ObjectLockConfig `yaml:"object_lock"`
+}
+
+// ObjectLockConfig holds the retention applied to uploaded logs in buckets
+// with S3 Object Lock enabled. The manifest is never locked.
+type ObjectLockConfig struct {
+	// Mode is GOVERNANCE or COMPLIANCE (empty disables retention).
+	Mode string `yaml:"mode"`
+	// RetainDays is how long each log is retained after it is uploaded.
+	RetainDays int `yaml:"retain_days"`
 }
 
 // AuthConfig holds authentication credentials.
@@ -29,6 +123,80 @@ type AuthConfig struct {
 	AccessKeyID     string `yaml:"access_key_id"`
 	SecretAccessKey string `yaml:"secret_access_key"`
 	SessionToken    string `yaml:"session_token"`
+
+	// RoleARN is a role assumed with the credentials above before S3 is
+	// accessed; ExternalID and RoleSessionName are passed along with it.
+	RoleARN         string `yaml:"role_arn"`
+	ExternalID      string `yaml:"external_id"`
+	RoleSessionName string `yaml:"role_session_name"`
+}
+
+// UploadConfig holds upload tuning and compression settings.
+type UploadConfig struct {
+	PartSize        ByteSize `yaml:"part_size"`        // Multipart part size (min 5MiB)
+	PartConcurrency int      `yaml:"part_concurrency"` // Parts uploaded in parallel per file
+	Compress        string   `yaml:"compress"`         // Codec for uploaded objects: "gzip", "zstd", or "none"
+	CompressLevel   int      `yaml:"compress_level"`   // Codec level (gzip 1-9, zstd 1-22); 0 for the codec default
+	UploadEmpty     bool     `yaml:"upload_empty"`     // Upload zero-byte files instead of skipping them
+	Spool           string   `yaml:"spool"`            // Buffering for single-PUT files: "auto", "memory", or "disk"
+	SpoolThreshold  ByteSize `yaml:"spool_threshold"`  // Files below this size are spooled (default part_size)
+	SpoolMemory     ByteSize `yaml:"spool_memory"`     // In auto mode, spool in memory up to this size, then disk
+	MemoryLimit     ByteSize `yaml:"memory_limit"`     // Ceiling for upload buffers (default: a quarter of system memory)
+	RemoteLock      bool     `yaml:"remote_lock"`      // Hold an advisory lock object in the bucket while uploading
+
+	// AssumedThroughput is the upload rate per second dry runs estimate
+	// durations with (0: the rate observed in previous runs).
+	AssumedThroughput ByteSize `yaml:"assumed_throughput"`
+}
+
+// DiscoveryConfig tunes how local files are compared against the manifest.
+type DiscoveryConfig struct {
+	// Concurrency is how many files are hashed at once (default: number of CPUs).
+	Concurrency int `yaml:"concurrency"`
+}
+
+// NotifyConfig configures the webhook that upload runs are reported to.
+type NotifyConfig struct {
+	// WebhookURL receives a JSON summary after each upload (empty disables).
+	WebhookURL string `yaml:"webhook_url"`
+
+	// On filters which runs are sent: always (default), changes, or failure.
+	On string `yaml:"on"`
+}
+
+// TelemetryConfig holds OpenTelemetry export settings.
+type TelemetryConfig struct {
+	// OTLPEndpoint is the base URL of an OTLP/HTTP collector, e.g.
+	// http://localhost:4318 (empty disables export).
+	OTLPEndpoint string `yaml:"otlp_endpoint"`
+
+	// Headers are sent with each export request, e.g. for authentication.
+	Headers map[string]string `yaml:"headers"`
+}
+
+// RedactConfig holds redaction settings and sanity checks.
+type RedactConfig struct {
+	// MaxMatchShare flags patterns matching more than this share of processed lines (default 0.2).
+	MaxMatchShare float64 `yaml:"max_match_share"`
+	// EnvKeywords are env var name suffixes redacted in KEY=VALUE lines (default KEY, TOKEN, SECRET, ...).
+	EnvKeywords []string `yaml:"env_keywords"`
+	// Disable lists pattern tags (e.g. IP, PHONE_US) that are not redacted.
+	Disable []string `yaml:"disable"`
+	// Allowlist lists values never redacted, in addition to
+	// redactor.DefaultAllowlist: a match equal to an entry, or matched in
+	// full by an entry written /regex/, is left as it is.
+	Allowlist []string `yaml:"allowlist"`
+	// Mode is "placeholder" (<TAG-hash>, default) or "mask" (same-length mask).
+	Mode string `yaml:"mode"`
+	// MaskChar replaces each masked character in mask mode (default "*").
+	MaskChar string `yaml:"mask_char"`
+	// MaskKeep lists characters left as they are in mask mode, e.g. "@.".
+	MaskKeep string `yaml:"mask_keep"`
+	// CanonicalJSON writes redacted JSON lines in RFC 8785 canonical form.
+	CanonicalJSON bool `yaml:"canonical_json"`
+	// SpillThreshold encodes redacted JSON lines longer than this straight
+	// into the output instead of into memory (0 disables).
+	SpillThreshold ByteSize `yaml:"spill_threshold"`
 }
 
 // Project represents a local or remote project with JSONL file counts.
@@ -38,4 +206,21 @@ type Project struct {
 	LocalCount  int
 	RemotePath  string
 	RemoteCount int
+	RemoteBytes int64 // Stored size of the project's objects, from the manifest
+	// RemoteApprox is set when some entries predate stored sizes and their
+	// source size was counted instead.
+	RemoteApprox bool
+	// RemoteLastUpload is the latest upload time recorded for the project's
+	// objects; zero if the manifest predates upload times.
+	RemoteLastUpload time.Time
+	// RemoteFiles lists the project's uploaded logs, when asked for
+	// (list --files).
+	RemoteFiles []RemoteFile
+}
+
+// RemoteFile is an uploaded log as the manifest records it.
+type RemoteFile struct {
+	Key  string
+	Size int64    // Source file size
+	Tags []string // Redaction patterns that matched in it, sorted
 }
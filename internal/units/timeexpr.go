@@ -0,0 +1,74 @@
+// Package units parses the human-friendly quantities accepted on the command line.
+package units
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeHelp describes the accepted time expressions for command help text.
+const TimeHelp = `Time expressions (--since):
+  30d, 36h, 90m, 2w      rolling window ending now; 1d is always 24h
+  2024-03-10             start of that day at local midnight
+  2024-03-10T09:00:00Z   RFC3339 timestamp, using its own offset`
+
+// durationUnits maps duration suffixes to their length. Days and weeks are
+// fixed multiples of 24h so a rolling window never depends on DST.
+var durationUnits = map[string]time.Duration{
+	"s": time.Second,
+	"m": time.Minute,
+	"h": time.Hour,
+	"d": 24 * time.Hour,
+	"w": 7 * 24 * time.Hour,
+}
+
+// ParseTime resolves a time expression to an instant. Accepted forms:
+//   - a duration such as "30d": exactly now minus 30×24h, regardless of DST
+//     or time zone
+//   - a date "YYYY-MM-DD": midnight at the start of that day in loc
+//   - an RFC3339 timestamp: the instant it names, using its own offset
+func ParseTime(expr string, now time.Time, loc *time.Location) (time.Time, error) {
+	s := strings.TrimSpace(expr)
+	if s == "" {
+		return time.Time{}, fmt.Errorf("empty time expression")
+	}
+
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, s, loc); err == nil {
+		return t, nil
+	}
+
+	d, err := ParseDuration(s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q: want a duration (30d), date (2024-03-10), or RFC3339 timestamp", expr)
+	}
+	return now.Add(-d), nil
+}
+
+// ParseDuration parses a non-negative duration with a single unit suffix
+// (s, m, h, d, w), such as "30d" or "36h".
+func ParseDuration(s string) (time.Duration, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+
+	unit, ok := durationUnits[s[len(s)-1:]]
+	if !ok {
+		return 0, fmt.Errorf("invalid duration %q: unknown unit", s)
+	}
+
+	n, err := strconv.ParseInt(s[:len(s)-1], 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	if n > math.MaxInt64/int64(unit) {
+		return 0, fmt.Errorf("invalid duration %q: too large", s)
+	}
+	return time.Duration(n) * unit, nil
+}
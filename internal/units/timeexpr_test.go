@@ -0,0 +1,161 @@
+package units
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLoad(t testing.TB, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("loading %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestParseTime(t *testing.T) {
+	newYork := mustLoad(t, "America/New_York")
+	tokyo := mustLoad(t, "Asia/Tokyo")
+	now := time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		expr    string
+		loc     *time.Location
+		want    time.Time
+		wantErr bool
+	}{
+		{
+			name: "rolling days",
+			expr: "30d",
+			loc:  time.UTC,
+			want: now.Add(-30 * 24 * time.Hour),
+		},
+		{
+			// 2024-03-10 is 23 hours long in New York; 1d is still 24h
+			name: "rolling day across spring forward",
+			expr: "1d",
+			loc:  newYork,
+			want: time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "weeks and hours",
+			expr: "2w",
+			loc:  time.UTC,
+			want: now.Add(-14 * 24 * time.Hour),
+		},
+		{
+			name: "uppercase unit",
+			expr: "36H",
+			loc:  time.UTC,
+			want: now.Add(-36 * time.Hour),
+		},
+		{
+			name: "date is local midnight",
+			expr: "2024-03-01",
+			loc:  tokyo,
+			want: time.Date(2024, 2, 29, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "date on spring forward day",
+			expr: "2024-03-10",
+			loc:  newYork,
+			want: time.Date(2024, 3, 10, 5, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "date after spring forward",
+			expr: "2024-03-11",
+			loc:  newYork,
+			want: time.Date(2024, 3, 11, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "date on fall back day",
+			expr: "2024-11-03",
+			loc:  newYork,
+			want: time.Date(2024, 11, 3, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "RFC3339 keeps its own offset",
+			expr: "2024-03-10T01:30:00+09:00",
+			loc:  newYork,
+			want: time.Date(2024, 3, 9, 16, 30, 0, 0, time.UTC),
+		},
+		{
+			name: "RFC3339 UTC",
+			expr: "2024-03-10T01:30:00Z",
+			loc:  tokyo,
+			want: time.Date(2024, 3, 10, 1, 30, 0, 0, time.UTC),
+		},
+		{name: "empty", expr: "", loc: time.UTC, wantErr: true},
+		{name: "no unit", expr: "30", loc: time.UTC, wantErr: true},
+		{name: "unknown unit", expr: "3y", loc: time.UTC, wantErr: true},
+		{name: "negative", expr: "-1d", loc: time.UTC, wantErr: true},
+		{name: "fractional", expr: "1.5d", loc: time.UTC, wantErr: true},
+		{name: "overflow", expr: "999999999999w", loc: time.UTC, wantErr: true},
+		{name: "invalid date", expr: "2024-02-30", loc: time.UTC, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseTime(tt.expr, now, tt.loc)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseTime(%q) = %v, want error", tt.expr, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseTime(%q) failed: %v", tt.expr, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseTime(%q) = %v, want %v", tt.expr, got.UTC(), tt.want)
+			}
+		})
+	}
+}
+
+func FuzzParseTime(f *testing.F) {
+	for _, seed := range []string{
+		"30d", "1d", "2w", "90m", "36h", "0s", "+5d", "-1d", "9223372036854775807s",
+		"2024-03-10", "2024-11-03", "2024-03-10T01:30:00+09:00", "2024-03-10T01:30:00Z",
+		"", " ", "d", "1.5d", "2024-02-30",
+	} {
+		f.Add(seed)
+	}
+
+	newYork := mustLoad(f, "America/New_York")
+	now := time.Date(2024, 3, 11, 12, 0, 0, 0, time.UTC)
+
+	f.Fuzz(func(t *testing.T, expr string) {
+		got, err := ParseTime(expr, now, newYork)
+		if err != nil {
+			return
+		}
+
+		s := strings.TrimSpace(expr)
+		if _, rfcErr := time.Parse(time.RFC3339, s); rfcErr == nil {
+			return
+		}
+
+		// Dates resolve to local midnight of that day
+		if _, dateErr := time.ParseInLocation(time.DateOnly, s, newYork); dateErr == nil {
+			local := got.In(newYork)
+			if local.Format(time.DateOnly) != s || local.Hour() != 0 || local.Minute() != 0 {
+				t.Errorf("ParseTime(%q) = %v, want local midnight of that day", expr, local)
+			}
+			return
+		}
+
+		// Durations never resolve to a time in the future, so a cutoff can
+		// only ever cover less than "now", never more
+		if got.After(now) {
+			t.Errorf("ParseTime(%q) = %v, after now %v", expr, got, now)
+		}
+		if d, err := ParseDuration(s); err != nil || !now.Add(-d).Equal(got) {
+			t.Errorf("ParseTime(%q) = %v, inconsistent with ParseDuration (%v, %v)", expr, got, d, err)
+		}
+	})
+}
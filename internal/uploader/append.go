@@ -0,0 +1,119 @@
+package uploader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sync"
+
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/output"
+)
+
+// isAppend reports whether the file at path grew by appending to the content
+// entry was uploaded from: it is larger and its first entry.Size bytes hash
+// to the recorded source digest. Entries written before source digests were
+// recorded never match.
+func isAppend(ctx context.Context, path string, size int64, entry manifest.FileEntry) (bool, error) {
+	if entry.SourceSHA256 == "" || size <= entry.Size {
+		return false, nil
+	}
+
+	sum, n, err := hashPrefix(ctx, path, entry.Size)
+	if err != nil {
+		return false, err
+	}
+	return n == entry.Size && sum == entry.SourceSHA256, nil
+}
+
+// hashPrefix streams the first limit bytes of the file at path through
+// SHA-256, returning the hex digest and the number of bytes hashed. It stops
+// early when ctx is cancelled.
+func hashPrefix(ctx context.Context, path string, limit int64) (string, int64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, fmt.Errorf("opening file: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+
+	h := sha256.New()
+	n, err := io.Copy(h, &ctxReader{ctx: ctx, r: io.LimitReader(f, limit)})
+	if err != nil {
+		return "", n, fmt.Errorf("hashing previous content: %w", err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), n, nil
+}
+
+// ctxReader fails reads once its context is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read implements io.Reader.
+func (c *ctxReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
+
+// appendCheck is a file whose manifest entry may be a prefix of it.
+type appendCheck struct {
+	i     int // Index into the uploads being discovered
+	entry manifest.FileEntry
+}
+
+// checkAppends runs isAppend for each check with at most
+// discovery.concurrency files hashed at once, setting AppendedTo on the files
+// that grew by appending. Hashing errors only mean no append is reported.
+func (u *Uploader) checkAppends(ctx context.Context, uploads []FileUpload, checks []appendCheck) error {
+	limit := u.cfg.Discovery.Concurrency
+	if limit <= 0 {
+		limit = runtime.NumCPU()
+	}
+
+	var wg sync.WaitGroup
+	var logMu sync.Mutex
+	sem := make(chan struct{}, limit)
+	for _, c := range checks {
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			f := &uploads[c.i]
+			appended, err := isAppend(ctx, f.LocalPath, f.Size, c.entry)
+			if err != nil && u.debug {
+				logMu.Lock()
+				fmt.Fprintf(u.errOut, "[DEBUG] append check for %s: %v\n", f.LocalPath, err)
+				logMu.Unlock()
+			}
+			if appended {
+				f.AppendedTo = c.entry.Size
+			}
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// appendNote describes the appended delta of file for its progress line.
+func appendNote(file FileUpload) string {
+	if file.AppendedTo == 0 {
+		return ""
+	}
+	return fmt.Sprintf(", append detected: +%s", output.FormatSize(file.Size-file.AppendedTo))
+}
@@ -0,0 +1,264 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+func TestIsAppend(t *testing.T) {
+	previous := "{\"n\":1}\n{\"n\":2}\n"
+	sum := sha256.Sum256([]byte(previous))
+	entry := manifest.FileEntry{Size: int64(len(previous)), SourceSHA256: hex.EncodeToString(sum[:])}
+
+	tests := []struct {
+		name    string
+		content string
+		entry   manifest.FileEntry
+		want    bool
+	}{
+		{
+			name:    "lines appended",
+			content: previous + "{\"n\":3}\n",
+			entry:   entry,
+			want:    true,
+		},
+		{
+			name:    "earlier line rewritten",
+			content: "{\"n\":9}\n{\"n\":2}\n{\"n\":3}\n",
+			entry:   entry,
+			want:    false,
+		},
+		{
+			name:    "same size",
+			content: previous,
+			entry:   entry,
+			want:    false,
+		},
+		{
+			name:    "entry without source digest",
+			content: previous + "{\"n\":3}\n",
+			entry:   manifest.FileEntry{Size: entry.Size},
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "session.jsonl")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := isAppend(context.Background(), path, int64(len(tt.content)), tt.entry)
+			if err != nil {
+				t.Fatalf("isAppend() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("isAppend() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscoverFiles_AppendDetected(t *testing.T) {
+	tests := []struct {
+		name   string
+		update string // New content replacing "{\"n\":1}\n"
+		want   int64  // Expected AppendedTo
+	}{
+		{"append", "{\"n\":1}\n{\"n\":2}\n", 8},
+		{"rewrite", "{\"n\":7}\n{\"n\":2}\n", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := t.TempDir()
+			path := filepath.Join(root, "project", "session.jsonl")
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte("{\"n\":1}\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+
+			client, _ := newFakeS3(t)
+			cfg := &types.Config{
+				Local: types.LocalConfig{ProjectsRoot: root},
+				S3:    types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+			}
+			u := New(cfg, client, false, false)
+			u.SetOutput(nil, nil)
+
+			files, err := u.DiscoverFiles(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := u.Upload(context.Background(), files); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := os.WriteFile(path, []byte(tt.update), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			later := time.Now().Add(time.Hour)
+			if err := os.Chtimes(path, later, later); err != nil {
+				t.Fatal(err)
+			}
+
+			files, err = u.DiscoverFiles(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(files) != 1 || files[0].ShouldSkip {
+				t.Fatalf("files = %+v, want one file to upload", files)
+			}
+			if files[0].AppendedTo != tt.want {
+				t.Errorf("AppendedTo = %d, want %d", files[0].AppendedTo, tt.want)
+			}
+		})
+	}
+}
+
+// appendFixture writes n session files that each grew after an upload of
+// their first half, and a manifest recording that upload. Every third entry
+// records a different digest, as if the file had been rewritten.
+func appendFixture(tb testing.TB, n, size int) (*types.Config, *mockS3) {
+	tb.Helper()
+	root := tb.TempDir()
+	m := manifest.New()
+	line := []byte("{\"type\":\"assistant\",\"message\":\"0123456789abcdef\"}\n")
+	for i := range n {
+		path := filepath.Join(root, "project", fmt.Sprintf("s%03d.jsonl", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			tb.Fatal(err)
+		}
+		content := bytes.Repeat(line, size/len(line)+1)
+		content = append(content, byte('a'+i%26), '\n')
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			tb.Fatal(err)
+		}
+
+		prev := int64(len(content) / 2)
+		sum := sha256.Sum256(content[:prev])
+		if i%3 == 0 {
+			sum = sha256.Sum256([]byte("rewritten"))
+		}
+		m.Files[fmt.Sprintf("claude-code/project/s%03d.jsonl", i)] = manifest.FileEntry{
+			Mtime:        time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+			Size:         prev,
+			SourceSHA256: hex.EncodeToString(sum[:]),
+		}
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	client := newMockS3()
+	client.store("claude-code/.manifest.json", data)
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: root},
+		S3:    types.S3Config{Bucket: "bucket", Prefix: "claude-code/"},
+	}
+	return cfg, client
+}
+
+func TestDiscoverFiles_AppendConcurrency(t *testing.T) {
+	cfg, client := appendFixture(t, 24, 4096)
+
+	var want map[string]int64
+	for _, concurrency := range []int{1, 3, 16} {
+		cfg.Discovery.Concurrency = concurrency
+		files, err := newUploader(cfg, client, false, false).DiscoverFiles(context.Background())
+		if err != nil {
+			t.Fatalf("concurrency %d: DiscoverFiles() error = %v", concurrency, err)
+		}
+
+		got := make(map[string]int64)
+		appended := 0
+		for _, f := range files {
+			got[f.S3Key] = f.AppendedTo
+			if f.AppendedTo > 0 {
+				appended++
+			}
+		}
+		if appended != 16 {
+			t.Errorf("concurrency %d: %d appends detected, want 16", concurrency, appended)
+		}
+		if want == nil {
+			want = got
+			continue
+		}
+		if !maps.Equal(got, want) {
+			t.Errorf("concurrency %d: AppendedTo = %v, want %v", concurrency, got, want)
+		}
+	}
+}
+
+func TestHashPrefix(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	content := bytes.Repeat([]byte("{\"n\":1}\n"), 10000)
+	if err := os.WriteFile(path, content, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	sum, n, err := hashPrefix(context.Background(), path, 1000)
+	if err != nil {
+		t.Fatalf("hashPrefix() error = %v", err)
+	}
+	want := sha256.Sum256(content[:1000])
+	if n != 1000 || sum != hex.EncodeToString(want[:]) {
+		t.Errorf("hashPrefix() = %s, %d; want %x, 1000", sum, n, want)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, _, err := hashPrefix(ctx, path, int64(len(content))); !errors.Is(err, context.Canceled) {
+		t.Errorf("hashPrefix() with cancelled context error = %v, want context.Canceled", err)
+	}
+}
+
+func TestDiscoverFiles_AppendCheckCancelled(t *testing.T) {
+	cfg, client := appendFixture(t, 8, 4096)
+	u := newUploader(cfg, client, false, false)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// The manifest load fails first with a cancelled context; check directly
+	files, err := u.discoverLocal(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checks := []appendCheck{{i: 0, entry: manifest.FileEntry{Size: 1, SourceSHA256: "x"}}}
+	if err := u.checkAppends(ctx, files, checks); !errors.Is(err, context.Canceled) {
+		t.Errorf("checkAppends() error = %v, want context.Canceled", err)
+	}
+}
+
+func BenchmarkDiscoverFilesAppendCheck(b *testing.B) {
+	cfg, client := appendFixture(b, 32, 1<<20)
+	for _, concurrency := range []int{1, 4, 16} {
+		b.Run(fmt.Sprintf("concurrency=%d", concurrency), func(b *testing.B) {
+			cfg.Discovery.Concurrency = concurrency
+			u := newUploader(cfg, client, false, false)
+			for b.Loop() {
+				if _, err := u.DiscoverFiles(context.Background()); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package uploader
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+// ObjectCache answers existence and size checks for one run from a single
+// ListRemoteFiles call per project prefix, instead of a HeadObject request per
+// object. A project whose listing fails is not retried; its keys are reported
+// as not covered so callers fall back to HeadObject.
+type ObjectCache struct {
+	client  s3ClientInterface
+	bucket  string
+	prefix  string // Key prefix that project directories sit under
+	timeout time.Duration
+
+	listed map[string]map[string]ListedObject // Project prefix → key → object
+	failed map[string]bool                    // Project prefixes whose listing failed
+}
+
+// NewObjectCache returns a cache for objects under prefix, listing each
+// project directory below it the first time one of its keys is looked up.
+// Each request is bounded by timeout (non-positive disables the deadline).
+func NewObjectCache(client s3ClientInterface, bucket, prefix string, timeout time.Duration) *ObjectCache {
+	return &ObjectCache{
+		client:  client,
+		bucket:  bucket,
+		prefix:  prefix,
+		timeout: timeout,
+		listed:  make(map[string]map[string]ListedObject),
+		failed:  make(map[string]bool),
+	}
+}
+
+// Lookup returns the size of the object at key and whether it exists.
+// covered is false when the key's project could not be listed, in which case
+// size and exists mean nothing.
+func (c *ObjectCache) Lookup(ctx context.Context, key string) (size int64, exists, covered bool) {
+	obj, exists, covered := c.Stat(ctx, key)
+	return obj.Size, exists, covered
+}
+
+// Stat is Lookup returning the listed object, modification time included.
+func (c *ObjectCache) Stat(ctx context.Context, key string) (obj ListedObject, exists, covered bool) {
+	prefix := c.projectPrefix(key)
+	if c.failed[prefix] {
+		return ListedObject{}, false, false
+	}
+
+	objects, ok := c.listed[prefix]
+	if !ok {
+		var err error
+		objects, err = listObjects(ctx, c.client, c.bucket, prefix, c.timeout)
+		if err != nil {
+			c.failed[prefix] = true
+			return ListedObject{}, false, false
+		}
+		c.listed[prefix] = objects
+	}
+
+	obj, exists = objects[key]
+	return obj, exists, true
+}
+
+// projectPrefix returns the prefix of the project directory holding key, or
+// the cache prefix itself for keys directly under it.
+func (c *ObjectCache) projectPrefix(key string) string {
+	rest := strings.TrimPrefix(key, c.prefix)
+	i := strings.Index(rest, "/")
+	if i < 0 {
+		return c.prefix
+	}
+	return c.prefix + rest[:i+1]
+}
@@ -0,0 +1,136 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+func TestObjectCache(t *testing.T) {
+	client := newMockS3()
+	client.store("claude-code/a/one.jsonl", []byte("1\n"))
+	client.store("claude-code/a/sub/two.jsonl", []byte("22\n"))
+	client.store("claude-code/b/three.jsonl", []byte("333\n"))
+	client.failList = func(prefix string) bool { return prefix == "claude-code/c/" }
+
+	cache := NewObjectCache(client, "test-bucket", "claude-code/", 0)
+
+	tests := []struct {
+		key     string
+		size    int64
+		exists  bool
+		covered bool
+	}{
+		{key: "claude-code/a/one.jsonl", size: 2, exists: true, covered: true},
+		{key: "claude-code/a/sub/two.jsonl", size: 3, exists: true, covered: true},
+		{key: "claude-code/a/missing.jsonl", covered: true},
+		{key: "claude-code/b/three.jsonl", size: 4, exists: true, covered: true},
+		{key: "claude-code/c/unlisted.jsonl"},
+		{key: "claude-code/c/unlisted.jsonl"},
+	}
+	for _, tt := range tests {
+		size, exists, covered := cache.Lookup(context.Background(), tt.key)
+		if size != tt.size || exists != tt.exists || covered != tt.covered {
+			t.Errorf("Lookup(%q) = %d, %v, %v; want %d, %v, %v",
+				tt.key, size, exists, covered, tt.size, tt.exists, tt.covered)
+		}
+	}
+
+	// One listing per project, failed ones included, and no HeadObject
+	want := []string{"LIST claude-code/a/", "LIST claude-code/b/", "LIST claude-code/c/"}
+	if got := client.readLog(); !reflect.DeepEqual(got, want) {
+		t.Errorf("requests = %v, want %v", got, want)
+	}
+}
+
+func TestDiscoverFiles_NoManifestUsesListing(t *testing.T) {
+	tmpDir := t.TempDir()
+	local := map[string]string{
+		"a/same.jsonl":     "{\"n\":1}\n",
+		"a/new.jsonl":      "{\"n\":2}\n",
+		"a/changed.jsonl":  "{\"n\":33}\n",
+		"a/redacted.jsonl": "{\"email\":\"user@company.com\"}\n",
+		"b/same.jsonl":     "{\"n\":4}\n",
+		"c/same.jsonl":     "{\"n\":5}\n",
+	}
+	for name, content := range local {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := newMockS3()
+	client.store("claude-code/a/same.jsonl", []byte(local["a/same.jsonl"]))
+	client.store("claude-code/a/changed.jsonl", []byte("{}\n"))
+	// Redaction shrank the object, which was uploaded after the file's last
+	// change
+	client.store("claude-code/a/redacted.jsonl", []byte("{\"email\":\"<EMAIL>\"}\n"))
+	client.store("claude-code/b/same.jsonl", []byte(local["b/same.jsonl"]))
+	client.store("claude-code/c/same.jsonl", []byte(local["c/same.jsonl"]))
+	client.failList = func(prefix string) bool { return prefix == "claude-code/c/" }
+	// changed.jsonl was written to after its upload
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(tmpDir, "a", "changed.jsonl"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: tmpDir},
+		S3:    types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+	}
+	u := newUploader(cfg, client, false, false)
+	u.SetNoManifest(true)
+
+	files, err := u.DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+
+	skipped := make(map[string]bool)
+	for _, f := range files {
+		skipped[strings.TrimPrefix(f.S3Key, "claude-code/")] = f.ShouldSkip
+	}
+	wantSkipped := map[string]bool{
+		"a/same.jsonl":     true,
+		"a/new.jsonl":      false,
+		"a/changed.jsonl":  false,
+		"a/redacted.jsonl": true,
+		"b/same.jsonl":     true,
+		"c/same.jsonl":     true,
+	}
+	if !reflect.DeepEqual(skipped, wantSkipped) {
+		t.Errorf("skipped = %v, want %v", skipped, wantSkipped)
+	}
+
+	// Listed keys of matching size, or written after the local file changed
+	// (the redacted one), need no HeadObject; an object older than its
+	// changed file does (the source size is in metadata), as does an
+	// unlisted project
+	var lists, heads []string
+	for _, call := range client.readLog() {
+		if p, ok := strings.CutPrefix(call, "LIST "); ok {
+			lists = append(lists, p)
+		}
+		if k, ok := strings.CutPrefix(call, "HEAD "); ok {
+			heads = append(heads, k)
+		}
+	}
+	wantLists := []string{"claude-code/a/", "claude-code/b/", "claude-code/c/"}
+	if !reflect.DeepEqual(lists, wantLists) {
+		t.Errorf("listed %v, want %v", lists, wantLists)
+	}
+	wantHeads := []string{"claude-code/a/changed.jsonl", "claude-code/c/same.jsonl"}
+	if !reflect.DeepEqual(heads, wantHeads) {
+		t.Errorf("HeadObject calls = %v, want %v", heads, wantHeads)
+	}
+}
@@ -4,12 +4,21 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"time"
 
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/s3errors"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 )
 
+// sourceSizeMetadata is the object metadata key recording the size of the
+// local source file, which differs from the object size after redaction or
+// compression.
+const sourceSizeMetadata = "source-size"
+
 // s3ClientInterface defines the minimal S3 client interface needed for checking file existence.
 type s3ClientInterface interface {
 	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
@@ -19,8 +28,28 @@ type s3ClientInterface interface {
 // ListRemoteFiles fetches all objects under a given prefix and returns a map of S3 key to file size.
 // This allows efficient batch checking of multiple files with a single API call (or a few calls with pagination).
 // Returns an empty map if no objects exist under the prefix.
-func ListRemoteFiles(ctx context.Context, client s3ClientInterface, bucket, prefix string) (map[string]int64, error) {
-	remoteFiles := make(map[string]int64)
+// Each page request is bounded by timeout (non-positive disables the deadline).
+func ListRemoteFiles(ctx context.Context, client s3ClientInterface, bucket, prefix string, timeout time.Duration) (map[string]int64, error) {
+	objects, err := listObjects(ctx, client, bucket, prefix, timeout)
+	if err != nil {
+		return nil, err
+	}
+	remoteFiles := make(map[string]int64, len(objects))
+	for key, obj := range objects {
+		remoteFiles[key] = obj.Size
+	}
+	return remoteFiles, nil
+}
+
+// ListedObject is an object as a bucket listing reports it.
+type ListedObject struct {
+	Size         int64
+	LastModified time.Time // Zero if the listing did not include it
+}
+
+// listObjects is ListRemoteFiles keeping each object's modification time.
+func listObjects(ctx context.Context, client s3ClientInterface, bucket, prefix string, timeout time.Duration) (map[string]ListedObject, error) {
+	objects := make(map[string]ListedObject)
 
 	input := &s3.ListObjectsV2Input{
 		Bucket: aws.String(bucket),
@@ -28,14 +57,16 @@ func ListRemoteFiles(ctx context.Context, client s3ClientInterface, bucket, pref
 	}
 
 	for {
-		output, err := client.ListObjectsV2(ctx, input)
+		opCtx, cancel := config.WithOperationTimeout(ctx, timeout)
+		output, err := client.ListObjectsV2(opCtx, input)
+		cancel()
 		if err != nil {
-			return nil, fmt.Errorf("list objects with prefix %s: %w", prefix, err)
+			return nil, fmt.Errorf("list objects with prefix %s: %w", prefix, s3errors.Wrap(err))
 		}
 
 		for _, obj := range output.Contents {
 			if obj.Key != nil && obj.Size != nil {
-				remoteFiles[*obj.Key] = *obj.Size
+				objects[*obj.Key] = ListedObject{Size: *obj.Size, LastModified: aws.ToTime(obj.LastModified)}
 			}
 		}
 
@@ -46,13 +77,46 @@ func ListRemoteFiles(ctx context.Context, client s3ClientInterface, bucket, pref
 		input.ContinuationToken = output.NextContinuationToken
 	}
 
-	return remoteFiles, nil
+	return objects, nil
+}
+
+// UnmanagedObjects returns how many objects exist under prefix when it has no
+// manifest at manifestKey, meaning cclogs has never uploaded there and the bucket may belong
+// to something else. It returns 0 once a manifest exists.
+// Each request is bounded by timeout (non-positive disables the deadline).
+func UnmanagedObjects(ctx context.Context, client s3ClientInterface, bucket, prefix, manifestKey string, timeout time.Duration) (int, error) {
+	headCtx, cancel := config.WithOperationTimeout(ctx, timeout)
+	_, err := client.HeadObject(headCtx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(manifestKey),
+	})
+	cancel()
+	if err == nil {
+		return 0, nil
+	}
+	var nsk *types.NoSuchKey
+	var nf *types.NotFound
+	if !errors.As(err, &nsk) && !errors.As(err, &nf) {
+		return 0, fmt.Errorf("head object %s: %w", manifestKey, s3errors.Wrap(err))
+	}
+
+	objects, err := ListRemoteFiles(ctx, client, bucket, prefix, timeout)
+	if err != nil {
+		return 0, err
+	}
+	return len(objects), nil
 }
 
 // ShouldUpload checks if a file should be uploaded by comparing with remote.
+// The source size recorded in object metadata is compared when present,
+// otherwise the object size.
 // Returns true if file should be uploaded (missing or different).
 // Returns false if file should be skipped (exists and identical).
-func ShouldUpload(ctx context.Context, client s3ClientInterface, bucket, key string, localSize int64) (bool, error) {
+// The request is bounded by timeout (non-positive disables the deadline).
+func ShouldUpload(ctx context.Context, client s3ClientInterface, bucket, key string, localSize int64, timeout time.Duration) (bool, error) {
+	ctx, cancel := config.WithOperationTimeout(ctx, timeout)
+	defer cancel()
+
 	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
 		Bucket: aws.String(bucket),
 		Key:    aws.String(key),
@@ -64,7 +128,13 @@ func ShouldUpload(ctx context.Context, client s3ClientInterface, bucket, key str
 		if errors.As(err, &nsk) || errors.As(err, &nf) {
 			return true, nil
 		}
-		return false, fmt.Errorf("head object %s: %w", key, err)
+		return false, fmt.Errorf("head object %s: %w", key, s3errors.Wrap(err))
+	}
+
+	if v, ok := head.Metadata[sourceSizeMetadata]; ok {
+		if sourceSize, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return sourceSize != localSize, nil
+		}
 	}
 
 	if head.ContentLength == nil {
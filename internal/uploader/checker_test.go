@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
+	"github.com/13rac1/cclogs/internal/s3errors"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
 )
 
 // mockS3Client implements the minimal S3 client interface needed for testing.
@@ -99,6 +102,30 @@ func TestShouldUpload(t *testing.T) {
 			want:      true,
 			wantErr:   false,
 		},
+		{
+			name: "recorded source size matches - should skip",
+			setupMock: func(m *mockS3Client) {
+				m.headObjectResp = &s3.HeadObjectOutput{
+					ContentLength: int64Ptr(300),
+					Metadata:      map[string]string{"source-size": "1024"},
+				}
+			},
+			localSize: 1024,
+			want:      false,
+			wantErr:   false,
+		},
+		{
+			name: "recorded source size differs - should upload",
+			setupMock: func(m *mockS3Client) {
+				m.headObjectResp = &s3.HeadObjectOutput{
+					ContentLength: int64Ptr(1024),
+					Metadata:      map[string]string{"source-size": "900"},
+				}
+			},
+			localSize: 1024,
+			want:      true,
+			wantErr:   false,
+		},
 		{
 			name: "file exists but no ContentLength - should upload to be safe",
 			setupMock: func(m *mockS3Client) {
@@ -157,7 +184,7 @@ func TestShouldUpload(t *testing.T) {
 			mock := &mockS3Client{}
 			tt.setupMock(mock)
 
-			got, err := ShouldUpload(context.Background(), mock, "test-bucket", "test-key", tt.localSize)
+			got, err := ShouldUpload(context.Background(), mock, "test-bucket", "test-key", tt.localSize, 0)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ShouldUpload() error = %v, wantErr %v", err, tt.wantErr)
@@ -179,12 +206,28 @@ func TestShouldUploadContextCancellation(t *testing.T) {
 		headObjectErr: context.Canceled,
 	}
 
-	_, err := ShouldUpload(ctx, mock, "test-bucket", "test-key", 1024)
+	_, err := ShouldUpload(ctx, mock, "test-bucket", "test-key", 1024, 0)
 	if err == nil {
 		t.Error("expected error for canceled context, got nil")
 	}
 }
 
+func TestCheckerAccessDenied(t *testing.T) {
+	denied := &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied"}
+	mock := &mockS3Client{headObjectErr: denied, listObjectsV2Err: denied}
+	ctx := context.Background()
+
+	if _, err := ShouldUpload(ctx, mock, "bucket", "key", 1, 0); !errors.Is(err, s3errors.ErrAccessDenied) {
+		t.Errorf("ShouldUpload() error = %v, want ErrAccessDenied", err)
+	}
+	if _, err := ListRemoteFiles(ctx, mock, "bucket", "prefix/", 0); !errors.Is(err, s3errors.ErrAccessDenied) {
+		t.Errorf("ListRemoteFiles() error = %v, want ErrAccessDenied", err)
+	}
+	if _, err := UnmanagedObjects(ctx, mock, "bucket", "prefix/", "prefix/.manifest.json", 0); !errors.Is(err, s3errors.ErrAccessDenied) {
+		t.Errorf("UnmanagedObjects() error = %v, want ErrAccessDenied", err)
+	}
+}
+
 func TestListRemoteFiles(t *testing.T) {
 	tests := []struct {
 		name      string
@@ -316,7 +359,7 @@ func TestListRemoteFiles(t *testing.T) {
 			mock := &mockS3Client{}
 			tt.setupMock(mock)
 
-			got, err := ListRemoteFiles(context.Background(), mock, tt.bucket, tt.prefix)
+			got, err := ListRemoteFiles(context.Background(), mock, tt.bucket, tt.prefix, 0)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("ListRemoteFiles() error = %v, wantErr %v", err, tt.wantErr)
@@ -352,7 +395,7 @@ func TestListRemoteFilesPagination(t *testing.T) {
 		callCount: &callCount,
 	}
 
-	got, err := ListRemoteFiles(context.Background(), mock, "test-bucket", "project-a/")
+	got, err := ListRemoteFiles(context.Background(), mock, "test-bucket", "project-a/", 0)
 	if err != nil {
 		t.Fatalf("ListRemoteFiles() failed: %v", err)
 	}
@@ -417,3 +460,58 @@ func (m *paginatingMockS3Client) ListObjectsV2(ctx context.Context, params *s3.L
 		},
 	}, nil
 }
+
+// blockingMockS3Client blocks every call until its context is done.
+type blockingMockS3Client struct{}
+
+func (m *blockingMockS3Client) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (m *blockingMockS3Client) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestCheckerOperationTimeout(t *testing.T) {
+	mock := &blockingMockS3Client{}
+
+	_, err := ShouldUpload(context.Background(), mock, "test-bucket", "test-key", 1024, 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ShouldUpload() error = %v, want wrapped context.DeadlineExceeded", err)
+	}
+
+	_, err = ListRemoteFiles(context.Background(), mock, "test-bucket", "project-a/", 10*time.Millisecond)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("ListRemoteFiles() error = %v, want wrapped context.DeadlineExceeded", err)
+	}
+}
+
+func TestUnmanagedObjects(t *testing.T) {
+	tests := []struct {
+		name    string
+		objects []string
+		want    int
+	}{
+		{name: "empty bucket", want: 0},
+		{name: "objects without manifest", objects: []string{"claude-code/a.txt", "claude-code/b/c.jsonl", "other/d"}, want: 2},
+		{name: "objects with manifest", objects: []string{"claude-code/.manifest.json", "claude-code/p/s.jsonl"}, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := newMockS3()
+			for _, key := range tt.objects {
+				m.store(key, []byte("x"))
+			}
+			got, err := UnmanagedObjects(context.Background(), m, "bucket", "claude-code/", "claude-code/.manifest.json", 0)
+			if err != nil {
+				t.Fatalf("UnmanagedObjects() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("UnmanagedObjects() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/codec"
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/output"
+)
+
+// chooseCodec returns the codec for file, whose S3Key does not yet carry a
+// codec extension. A file m records under the configured codec's key, or
+// uncompressed, keeps that codec, so its key doesn't change from run to run.
+// Only other files are sampled: content that already looks compressed is
+// left as-is to avoid wasting CPU for no size benefit.
+func (u *Uploader) chooseCodec(file FileUpload, m *manifest.Manifest) codec.Codec {
+	c, err := codec.Parse(u.cfg.Upload.Compress)
+	if err != nil || c == codec.None {
+		return codec.None
+	}
+	if m != nil {
+		if _, ok := m.Files[file.S3Key+c.Extension()]; ok {
+			return c
+		}
+		if _, ok := m.Files[file.S3Key]; ok {
+			return codec.None
+		}
+	}
+	if file.ShouldSkip {
+		return c
+	}
+
+	path := file.LocalPath
+	highEntropy, err := sampleHighEntropy(path)
+	if err != nil {
+		// Unreadable files fail later with a clearer error during upload
+		return c
+	}
+	if highEntropy {
+		if u.debug {
+			fmt.Fprintf(u.errOut, "[DEBUG] %s: high-entropy content, uploading uncompressed\n", path)
+		}
+		return codec.None
+	}
+
+	return c
+}
+
+// sampleHighEntropy reads the start of a file and reports whether it looks
+// already compressed.
+func sampleHighEntropy(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = f.Close() }()
+
+	sample := make([]byte, codec.SampleSize)
+	n, err := io.ReadFull(f, sample)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+
+	return codec.HighEntropy(sample[:n]), nil
+}
+
+// estimateCodec returns the codec a dry run projects for file: the one
+// discovery chose when compression is configured, otherwise gzip unless the
+// content already looks compressed.
+func (u *Uploader) estimateCodec(file FileUpload) codec.Codec {
+	if c, err := codec.Parse(u.cfg.Upload.Compress); err == nil && c != codec.None {
+		if file.Codec == "" {
+			return codec.None
+		}
+		return file.Codec
+	}
+	if highEntropy, err := sampleHighEntropy(file.LocalPath); err == nil && highEntropy {
+		return codec.None
+	}
+	return codec.Gzip
+}
+
+// loadDictionary returns the zstd dictionary stored next to the manifest
+// (cclogs compression train-dict) if any of files is compressed with zstd.
+// Without one, or if it can't be read, files are compressed without a
+// dictionary; their manifest entries record none, so they stay readable.
+func (u *Uploader) loadDictionary(ctx context.Context, files []FileUpload) codec.Dictionary {
+	if u.client == nil || !slices.ContainsFunc(files, func(f FileUpload) bool { return !f.ShouldSkip && f.Codec == codec.Zstd }) {
+		return nil
+	}
+	dict, err := manifest.LoadDict(ctx, u.client, u.cfg.S3.Bucket, manifest.DictKey(u.cfg), u.cfg.S3.OperationTimeout)
+	if err != nil {
+		fmt.Fprintf(u.errOut, "Warning: compressing without a dictionary: %v\n", err)
+		return nil
+	}
+	if dict != nil && u.debug {
+		fmt.Fprintf(u.errOut, "[DEBUG] compressing zstd objects with dictionary %d\n", dict.ID())
+	}
+	return dict
+}
+
+// zstdDictID returns the ID of the dictionary content compressed with c was
+// compressed with: dict's for zstd, otherwise 0.
+func zstdDictID(c codec.Codec, dict codec.Dictionary) uint32 {
+	if c != codec.Zstd {
+		return 0
+	}
+	return dict.ID()
+}
+
+// newEstimate starts the dry-run compression total, labeled with the codec
+// and level the estimate projects.
+func (u *Uploader) newEstimate() *codec.Estimate {
+	c, err := codec.Parse(u.cfg.Upload.Compress)
+	if err != nil || c == codec.None {
+		c = codec.Gzip
+	}
+	return &codec.Estimate{Codec: c, Level: u.cfg.Upload.CompressLevel}
+}
+
+// formatEstimate describes a projected stored size, e.g. "1.2 MB (15.0%)".
+func formatEstimate(e codec.Estimate) string {
+	return fmt.Sprintf("%s (%.1f%%)", output.FormatSize(e.StoredBytes), 100*e.Ratio())
+}
+
+// printEstimateSummary reports the projected effect of compression and the
+// CPU time it took, so levels can be compared.
+func (u *Uploader) printEstimateSummary(e codec.Estimate) {
+	level := "default level"
+	if e.Level != 0 {
+		level = fmt.Sprintf("level %d", e.Level)
+	}
+	fmt.Fprintf(u.out, "\nCompression estimate (%s, %s):\n", e.Codec, level)
+	fmt.Fprintf(u.out, "  %s → %s stored (%.1f%% of redacted size, saves %s)\n",
+		output.FormatSize(e.InputBytes), output.FormatSize(e.StoredBytes), 100*e.Ratio(), output.FormatSize(max(e.Saved(), 0)))
+	fmt.Fprintf(u.out, "  Compressing took %s of CPU time for %s; higher levels cost more\n",
+		e.CPUTime.Round(time.Millisecond), plural(e.Files, "file"))
+}
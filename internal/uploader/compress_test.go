@@ -0,0 +1,357 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/codec"
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+func TestDiscoverFilesCompression(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	text := []byte(strings.Repeat(`{"type":"user","message":"hi"}`+"\n", 200))
+	if err := os.WriteFile(filepath.Join(projectDir, "text.jsonl"), text, 0644); err != nil {
+		t.Fatal(err)
+	}
+	random := make([]byte, 8192)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "random.jsonl"), random, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		compress string
+		want     map[string]codec.Codec
+	}{
+		{
+			compress: "none",
+			want: map[string]codec.Codec{
+				"claude-code/project/random.jsonl": codec.None,
+				"claude-code/project/text.jsonl":   codec.None,
+			},
+		},
+		{
+			compress: "gzip",
+			want: map[string]codec.Codec{
+				"claude-code/project/random.jsonl":  codec.None,
+				"claude-code/project/text.jsonl.gz": codec.Gzip,
+			},
+		},
+		{
+			compress: "zstd",
+			want: map[string]codec.Codec{
+				"claude-code/project/random.jsonl":   codec.None,
+				"claude-code/project/text.jsonl.zst": codec.Zstd,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.compress, func(t *testing.T) {
+			cfg := &types.Config{
+				Local:  types.LocalConfig{ProjectsRoot: tmpDir},
+				S3:     types.S3Config{Prefix: "claude-code/"},
+				Upload: types.UploadConfig{Compress: tt.compress},
+			}
+
+			files, err := New(cfg, nil, true, false).DiscoverFiles(context.Background())
+			if err != nil {
+				t.Fatalf("DiscoverFiles failed: %v", err)
+			}
+
+			if len(files) != len(tt.want) {
+				t.Fatalf("got %d files, want %d", len(files), len(tt.want))
+			}
+			for _, f := range files {
+				want, ok := tt.want[f.S3Key]
+				if !ok {
+					t.Errorf("unexpected key %q", f.S3Key)
+					continue
+				}
+				if f.Codec != want {
+					t.Errorf("%s: Codec = %q, want %q", f.S3Key, f.Codec, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDiscoverFilesKeepsRecordedCodec(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Both files would be sampled the other way; their entries win
+	text := []byte(strings.Repeat(`{"type":"user","message":"hi"}`+"\n", 200))
+	random := make([]byte, 8192)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string][]byte{"text.jsonl": text, "random.jsonl": random, "new.jsonl": text} {
+		if err := os.WriteFile(filepath.Join(projectDir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client, _ := newFakeS3(t)
+	seed := manifest.New()
+	seed.Files["claude-code/project/text.jsonl"] = manifest.FileEntry{Size: 1, Codec: string(codec.None)}
+	seed.Files["claude-code/project/random.jsonl.gz"] = manifest.FileEntry{Size: 1, Codec: string(codec.Gzip)}
+	if err := manifest.Save(context.Background(), client, "test-bucket", "claude-code/.manifest.json", seed, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &types.Config{
+		Local:  types.LocalConfig{ProjectsRoot: tmpDir},
+		S3:     types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+		Upload: types.UploadConfig{Compress: "gzip"},
+	}
+	files, err := New(cfg, client, true, false).DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+
+	want := map[string]codec.Codec{
+		"claude-code/project/text.jsonl":      codec.None,
+		"claude-code/project/random.jsonl.gz": codec.Gzip,
+		"claude-code/project/new.jsonl.gz":    codec.Gzip,
+	}
+	if len(files) != len(want) {
+		t.Fatalf("got %d files, want %d", len(files), len(want))
+	}
+	for _, f := range files {
+		if c, ok := want[f.S3Key]; !ok || f.Codec != c {
+			t.Errorf("%s: Codec = %q, want %q (known key: %v)", f.S3Key, f.Codec, c, ok)
+		}
+	}
+}
+
+func TestUploadCompressed(t *testing.T) {
+	for _, c := range []codec.Codec{codec.Gzip, codec.Zstd} {
+		t.Run(string(c), func(t *testing.T) {
+			testUploadCompressed(t, c)
+		})
+	}
+}
+
+func testUploadCompressed(t *testing.T, c codec.Codec) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte(strings.Repeat(`{"n":1}`+"\n", 500))
+	if err := os.WriteFile(filepath.Join(projectDir, "a.jsonl"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, fake := newFakeS3(t)
+	cfg := &types.Config{
+		Local:  types.LocalConfig{ProjectsRoot: tmpDir},
+		S3:     types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+		Upload: types.UploadConfig{Compress: string(c)},
+	}
+	u := New(cfg, client, true, false)
+
+	files, err := u.DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].S3Key < files[j].S3Key })
+
+	if _, err := u.Upload(context.Background(), files); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	key := "claude-code/project/a.jsonl" + c.Extension()
+	stored, ok := fake.object(key)
+	if !ok {
+		t.Fatalf("object %s not uploaded", key)
+	}
+
+	rc, err := codec.Decompress(c, nil, bytes.NewReader(stored))
+	if err != nil {
+		t.Fatalf("Decompress failed: %v", err)
+	}
+	decoded, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("reading decompressed object: %v", err)
+	}
+	if !bytes.Equal(decoded, content) {
+		t.Error("decompressed object does not match source file")
+	}
+
+	m, err := manifest.Load(context.Background(), client, "test-bucket", "claude-code/.manifest.json", 0)
+	if err != nil {
+		t.Fatalf("loading manifest: %v", err)
+	}
+	entry, ok := m.Files[key]
+	if !ok {
+		t.Fatalf("manifest missing entry for %s", key)
+	}
+	if entry.Codec != string(c) {
+		t.Errorf("entry.Codec = %q, want %q", entry.Codec, c)
+	}
+	if entry.UploadedSize != int64(len(stored)) {
+		t.Errorf("entry.UploadedSize = %d, want %d", entry.UploadedSize, len(stored))
+	}
+}
+
+func TestDryRunProcessEstimate(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	text := []byte(strings.Repeat(`{"type":"user","message":"hi"}`+"\n", 2000))
+	if err := os.WriteFile(filepath.Join(projectDir, "text.jsonl"), text, 0644); err != nil {
+		t.Fatal(err)
+	}
+	random := make([]byte, 64*1024)
+	if _, err := rand.Read(random); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "random.jsonl"), random, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, compress := range []string{"", "gzip"} {
+		t.Run("compress="+compress, func(t *testing.T) {
+			cfg := &types.Config{
+				Local:  types.LocalConfig{ProjectsRoot: tmpDir},
+				S3:     types.S3Config{Prefix: "claude-code/"},
+				Upload: types.UploadConfig{Compress: compress},
+			}
+			u := New(cfg, nil, true, false)
+			u.SetEstimateCompression(true)
+			var out bytes.Buffer
+			u.out = &out
+
+			files, err := u.DiscoverFiles(context.Background())
+			if err != nil {
+				t.Fatalf("DiscoverFiles failed: %v", err)
+			}
+			result, err := u.DryRunProcess(context.Background(), files)
+			if err != nil {
+				t.Fatalf("DryRunProcess failed: %v", err)
+			}
+
+			est := result.Compression
+			if est == nil {
+				t.Fatal("no compression estimate")
+			}
+			if est.Codec != codec.Gzip {
+				t.Errorf("Codec = %q, want gzip", est.Codec)
+			}
+			if est.Files != 2 {
+				t.Errorf("Files = %d, want 2", est.Files)
+			}
+			if want := int64(len(text) + len(random)); est.InputBytes != want {
+				t.Errorf("InputBytes = %d, want %d", est.InputBytes, want)
+			}
+			// Random content is stored as is; the text shrinks to almost nothing
+			if est.StoredBytes < int64(len(random)) || est.StoredBytes > int64(len(random))+int64(len(text))/20 {
+				t.Errorf("StoredBytes = %d, want just over %d", est.StoredBytes, len(random))
+			}
+			if want := float64(est.StoredBytes) / float64(est.InputBytes); est.Ratio() != want {
+				t.Errorf("Ratio() = %v, want %v", est.Ratio(), want)
+			}
+			if !strings.Contains(out.String(), "Compression estimate (gzip, default level)") {
+				t.Errorf("summary missing estimate:\n%s", out.String())
+			}
+		})
+	}
+}
+
+func TestUploadZstdDictionary(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	line := `{"type":"user","message":{"role":"user","content":"hello"}}` + "\n"
+	content := []byte(strings.Repeat(line, 20))
+	if err := os.WriteFile(filepath.Join(projectDir, "a.jsonl"), content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var samples [][]byte
+	for i := range 50 {
+		samples = append(samples, []byte(strings.Repeat(line, i+1)))
+	}
+	dict, err := codec.TrainDictionary(samples, 4096)
+	if err != nil {
+		t.Fatalf("TrainDictionary failed: %v", err)
+	}
+
+	client, fake := newFakeS3(t)
+	cfg := &types.Config{
+		Local:  types.LocalConfig{ProjectsRoot: tmpDir},
+		S3:     types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+		Upload: types.UploadConfig{Compress: "zstd"},
+	}
+	if err := manifest.SaveDict(context.Background(), client, cfg, dict, 0); err != nil {
+		t.Fatalf("SaveDict failed: %v", err)
+	}
+	idKey := manifest.DictIDKey(cfg, dict.ID())
+	if _, ok := fake.object(idKey); !ok {
+		t.Fatalf("dictionary not kept at %s", idKey)
+	}
+
+	u := New(cfg, client, true, false)
+	files, err := u.DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+	if _, err := u.Upload(context.Background(), files); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	key := "claude-code/project/a.jsonl.zst"
+	stored, ok := fake.object(key)
+	if !ok {
+		t.Fatalf("object %s not uploaded", key)
+	}
+	if _, err := codec.Decompress(codec.Zstd, nil, bytes.NewReader(stored)); !errors.Is(err, codec.ErrWrongDictionary) {
+		t.Errorf("Decompress without dictionary error = %v, want ErrWrongDictionary", err)
+	}
+	rc, err := codec.Decompress(codec.Zstd, dict, bytes.NewReader(stored))
+	if err != nil {
+		t.Fatalf("Decompress failed: %v", err)
+	}
+	decoded, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("reading decompressed object: %v", err)
+	}
+	if !bytes.Equal(decoded, content) {
+		t.Error("decompressed object does not match source file")
+	}
+
+	m, err := manifest.Load(context.Background(), client, "test-bucket", "claude-code/.manifest.json", 0)
+	if err != nil {
+		t.Fatalf("loading manifest: %v", err)
+	}
+	if got := m.Files[key].DictID; got != dict.ID() {
+		t.Errorf("entry.DictID = %d, want %d", got, dict.ID())
+	}
+}
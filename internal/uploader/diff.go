@@ -0,0 +1,164 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/fetch"
+	"github.com/13rac1/cclogs/internal/manifest"
+)
+
+// Sync states of a log as reported by Diff.
+const (
+	StateLocalOnly  = "local-only"  // Never uploaded
+	StateRemoteOnly = "remote-only" // In the manifest but not on this machine
+	StateModified   = "modified"    // Changed locally since the last upload
+	StateInSync     = "in-sync"
+
+	// States DiffObjects finds by also listing the bucket
+	StateMissing      = "missing"       // In the manifest, but its object is gone from the bucket
+	StateUnrecorded   = "unrecorded"    // In the bucket, but not in the manifest
+	StateSizeMismatch = "size-mismatch" // Object size differs from the one recorded at upload
+)
+
+// FileState is the sync state of one log.
+type FileState struct {
+	Project    string `json:"project"`
+	Path       string `json:"path"` // Slash-separated path relative to the project directory
+	Key        string `json:"key"`
+	State      string `json:"state"`
+	LocalSize  int64  `json:"localSize,omitempty"`
+	RemoteSize int64  `json:"remoteSize,omitempty"` // Source size recorded at upload
+	ObjectSize int64  `json:"objectSize,omitempty"` // Size of the listed object (DiffObjects only)
+}
+
+// syncState compares a local file with its manifest entry, the same test
+// DiscoverFiles uses to skip unchanged files. Modification times are
+// truncated to seconds for filesystem compatibility.
+func syncState(f FileUpload, entry manifest.FileEntry) string {
+	if f.ModTime.Truncate(time.Second).Equal(entry.Mtime.Truncate(time.Second)) {
+		return StateInSync
+	}
+	return StateModified
+}
+
+// Diff compares the local files of project (all projects if empty) with the
+// manifest and returns the state of each, sorted by project and path. Files
+// skipped as duplicates of another path are left out since they are never
+// uploaded under their own key.
+func (u *Uploader) Diff(ctx context.Context, project string) ([]FileState, error) {
+	states, _, err := u.diff(ctx, project)
+	return states, err
+}
+
+// DiffObjects is Diff, cross-checked against a listing of the bucket: logs
+// whose object is gone are missing, objects the manifest doesn't record are
+// unrecorded (whether or not the log is on this machine), and objects whose
+// size differs from the size recorded at upload are size-mismatch. Files that
+// are modified keep that state unless their object is missing, since the
+// next upload replaces them anyway.
+func (u *Uploader) DiffObjects(ctx context.Context, project string) ([]FileState, error) {
+	states, m, err := u.diff(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := ListRemoteFiles(ctx, u.client, u.cfg.S3.Bucket, config.KeyPrefix(u.cfg), u.cfg.S3.OperationTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("listing objects: %w", err)
+	}
+
+	// Objects the manifest accounts for, including other projects'
+	recorded := make(map[string]bool, len(m.Files))
+	for key, entry := range m.Files {
+		recorded[entry.ObjectKey(key)] = true
+	}
+
+	for i, s := range states {
+		entry, ok := m.Files[s.Key]
+		if !ok {
+			if size, listed := objects[s.Key]; listed {
+				states[i].State, states[i].ObjectSize = StateUnrecorded, size
+				recorded[s.Key] = true
+			}
+			continue
+		}
+		size, listed := objects[entry.ObjectKey(s.Key)]
+		switch {
+		case !listed:
+			states[i].State = StateMissing
+		case entry.UploadedSize > 0 && size != entry.UploadedSize && s.State != StateModified:
+			states[i].State, states[i].ObjectSize = StateSizeMismatch, size
+		default:
+			states[i].ObjectSize = size
+		}
+	}
+
+	unrecorded := make(map[string]int64)
+	for key, size := range objects {
+		if !recorded[key] {
+			unrecorded[key] = size
+		}
+	}
+	for _, t := range fetch.ListedTargets(u.cfg, unrecorded, project) {
+		states = append(states, FileState{Project: t.Project, Path: t.Path, Key: t.Key, State: StateUnrecorded, ObjectSize: objects[t.Key]})
+	}
+	sortStates(states)
+	return states, nil
+}
+
+// diff implements Diff, also returning the manifest it compared with.
+func (u *Uploader) diff(ctx context.Context, project string) ([]FileState, *manifest.Manifest, error) {
+	m, err := manifest.Load(ctx, u.client, u.cfg.S3.Bucket, manifest.ConfigKey(u.cfg), u.cfg.S3.OperationTimeout)
+	if err != nil {
+		return nil, nil, fmt.Errorf("loading manifest: %w", err)
+	}
+	files, err := u.discoverLocal(m)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var states []FileState
+	seen := make(map[string]bool)
+	for _, f := range files {
+		if (project != "" && f.ProjectDir != project) || strings.HasPrefix(f.SkipReason, "duplicate of ") {
+			continue
+		}
+		rel, err := filepath.Rel(filepath.Join(u.cfg.Local.ProjectsRoot, filepath.FromSlash(f.ProjectDir)), f.LocalPath)
+		if err != nil {
+			return nil, nil, fmt.Errorf("computing relative path for %s: %w", f.LocalPath, err)
+		}
+
+		s := FileState{Project: f.ProjectDir, Path: filepath.ToSlash(rel), Key: f.S3Key, State: StateLocalOnly, LocalSize: f.Size}
+		if entry, ok := m.Files[f.S3Key]; ok {
+			seen[f.S3Key] = true
+			s.State = syncState(f, entry)
+			s.RemoteSize = entry.Size
+		}
+		states = append(states, s)
+	}
+
+	for _, t := range fetch.Targets(u.cfg, m, project) {
+		if seen[t.Key] {
+			continue
+		}
+		states = append(states, FileState{Project: t.Project, Path: t.Path, Key: t.Key, State: StateRemoteOnly, RemoteSize: t.SourceSize})
+	}
+
+	sortStates(states)
+	return states, m, nil
+}
+
+// sortStates sorts states by project and path.
+func sortStates(states []FileState) {
+	sort.Slice(states, func(i, j int) bool {
+		if states[i].Project != states[j].Project {
+			return states[i].Project < states[j].Project
+		}
+		return states[i].Path < states[j].Path
+	})
+}
@@ -0,0 +1,162 @@
+package uploader
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+func TestDiff(t *testing.T) {
+	root := t.TempDir()
+	mtime := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"app/synced.jsonl", "app/changed.jsonl", "app/new.jsonl", "web/s1.jsonl"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("{}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := manifest.New()
+	m.Files["claude-code/app/synced.jsonl"] = manifest.FileEntry{Mtime: mtime, Size: 3}
+	m.Files["claude-code/app/changed.jsonl"] = manifest.FileEntry{Mtime: mtime.Add(-time.Hour), Size: 2}
+	m.Files["claude-code/app/gone.jsonl"] = manifest.FileEntry{Mtime: mtime, Size: 9}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newMockS3()
+	client.store("claude-code/.manifest.json", data)
+
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: root},
+		S3:    types.S3Config{Bucket: "bucket", Prefix: "claude-code/"},
+	}
+	u := newUploader(cfg, client, false, false)
+
+	tests := []struct {
+		project string
+		want    []FileState
+	}{
+		{
+			project: "app",
+			want: []FileState{
+				{Project: "app", Path: "changed.jsonl", Key: "claude-code/app/changed.jsonl", State: StateModified, LocalSize: 3, RemoteSize: 2},
+				{Project: "app", Path: "gone.jsonl", Key: "claude-code/app/gone.jsonl", State: StateRemoteOnly, RemoteSize: 9},
+				{Project: "app", Path: "new.jsonl", Key: "claude-code/app/new.jsonl", State: StateLocalOnly, LocalSize: 3},
+				{Project: "app", Path: "synced.jsonl", Key: "claude-code/app/synced.jsonl", State: StateInSync, LocalSize: 3, RemoteSize: 3},
+			},
+		},
+		{
+			project: "web",
+			want: []FileState{
+				{Project: "web", Path: "s1.jsonl", Key: "claude-code/web/s1.jsonl", State: StateLocalOnly, LocalSize: 3},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.project, func(t *testing.T) {
+			got, err := u.Diff(context.Background(), tt.project)
+			if err != nil {
+				t.Fatalf("Diff() error = %v", err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Diff() = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("state %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDiffObjects(t *testing.T) {
+	root := t.TempDir()
+	mtime := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"app/synced.jsonl", "app/changed.jsonl", "app/resized.jsonl", "app/lost.jsonl", "app/new.jsonl", "app/stray.jsonl"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("{}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := manifest.New()
+	m.Files["claude-code/app/synced.jsonl"] = manifest.FileEntry{Mtime: mtime, Size: 3, UploadedSize: 3}
+	m.Files["claude-code/app/changed.jsonl"] = manifest.FileEntry{Mtime: mtime.Add(-time.Hour), Size: 2, UploadedSize: 2}
+	m.Files["claude-code/app/resized.jsonl"] = manifest.FileEntry{Mtime: mtime, Size: 3, UploadedSize: 3}
+	m.Files["claude-code/app/lost.jsonl"] = manifest.FileEntry{Mtime: mtime, Size: 3, UploadedSize: 3}
+	m.Files["claude-code/app/kept.jsonl"] = manifest.FileEntry{Mtime: mtime, Size: 4, UploadedSize: 4}
+	m.Files["claude-code/app/gone.jsonl"] = manifest.FileEntry{Mtime: mtime, Size: 9}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newMockS3()
+	client.store("claude-code/.manifest.json", data)
+	client.store("claude-code/app/synced.jsonl", []byte("{}\n"))
+	client.store("claude-code/app/changed.jsonl", []byte("{}"))
+	client.store("claude-code/app/resized.jsonl", []byte("{}\n{}\n"))
+	client.store("claude-code/app/kept.jsonl", []byte("{}\n\n"))
+	client.store("claude-code/app/stray.jsonl", []byte("{}\n"))
+	client.store("claude-code/app/orphan.jsonl", []byte("{}\n{}\n"))
+	client.store("claude-code/web/other.jsonl", []byte("{}\n"))
+
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: root},
+		S3:    types.S3Config{Bucket: "bucket", Prefix: "claude-code/"},
+	}
+	u := newUploader(cfg, client, false, false)
+
+	got, err := u.DiffObjects(context.Background(), "app")
+	if err != nil {
+		t.Fatalf("DiffObjects() error = %v", err)
+	}
+	want := []FileState{
+		{Project: "app", Path: "changed.jsonl", Key: "claude-code/app/changed.jsonl", State: StateModified, LocalSize: 3, RemoteSize: 2, ObjectSize: 2},
+		{Project: "app", Path: "gone.jsonl", Key: "claude-code/app/gone.jsonl", State: StateMissing, RemoteSize: 9},
+		{Project: "app", Path: "kept.jsonl", Key: "claude-code/app/kept.jsonl", State: StateRemoteOnly, RemoteSize: 4, ObjectSize: 4},
+		{Project: "app", Path: "lost.jsonl", Key: "claude-code/app/lost.jsonl", State: StateMissing, LocalSize: 3, RemoteSize: 3},
+		{Project: "app", Path: "new.jsonl", Key: "claude-code/app/new.jsonl", State: StateLocalOnly, LocalSize: 3},
+		{Project: "app", Path: "orphan.jsonl", Key: "claude-code/app/orphan.jsonl", State: StateUnrecorded, ObjectSize: 6},
+		{Project: "app", Path: "resized.jsonl", Key: "claude-code/app/resized.jsonl", State: StateSizeMismatch, LocalSize: 3, RemoteSize: 3, ObjectSize: 6},
+		{Project: "app", Path: "stray.jsonl", Key: "claude-code/app/stray.jsonl", State: StateUnrecorded, LocalSize: 3, ObjectSize: 3},
+		{Project: "app", Path: "synced.jsonl", Key: "claude-code/app/synced.jsonl", State: StateInSync, LocalSize: 3, RemoteSize: 3, ObjectSize: 3},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("DiffObjects() = %+v, want %+v", got, want)
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("state %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+
+	// Without a project, the other project's unrecorded object is included
+	all, err := u.DiffObjects(context.Background(), "")
+	if err != nil {
+		t.Fatalf("DiffObjects() error = %v", err)
+	}
+	if last := all[len(all)-1]; last.Project != "web" || last.State != StateUnrecorded {
+		t.Errorf("last state = %+v, want web/other.jsonl unrecorded", last)
+	}
+}
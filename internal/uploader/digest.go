@@ -0,0 +1,48 @@
+package uploader
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"hash"
+	"io"
+)
+
+// digestReader wraps a reader, hashing and counting every byte read through it.
+type digestReader struct {
+	r    io.Reader
+	h    hash.Hash
+	size int64
+}
+
+// fileDigests holds the hashes computed while uploading one file.
+type fileDigests struct {
+	object *digestReader // Bytes sent to S3, after redaction and compression
+	source *digestReader // Bytes read from the local file
+	etag   string        // ETag S3 returned for the object
+}
+
+// newDigestReader returns a digestReader that computes SHA-256 over r.
+func newDigestReader(r io.Reader) *digestReader {
+	return &digestReader{r: r, h: sha256.New()}
+}
+
+// Read implements io.Reader.
+func (d *digestReader) Read(p []byte) (int, error) {
+	n, err := d.r.Read(p)
+	if n > 0 {
+		d.h.Write(p[:n])
+		d.size += int64(n)
+	}
+	return n, err
+}
+
+// sum returns the hex-encoded SHA-256 of all bytes read so far.
+func (d *digestReader) sum() string {
+	return hex.EncodeToString(d.h.Sum(nil))
+}
+
+// sumBase64 returns the base64-encoded SHA-256, as used by S3 checksum headers.
+func (d *digestReader) sumBase64() string {
+	return base64.StdEncoding.EncodeToString(d.h.Sum(nil))
+}
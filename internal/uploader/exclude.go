@@ -0,0 +1,60 @@
+package uploader
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+)
+
+// ContentScanLimit is how much of the start of each file SetExcludeContent's
+// pattern is matched against.
+const ContentScanLimit = 64 * 1024
+
+// SetExcludeContent skips files whose first ContentScanLimit bytes match re.
+// Only files that would otherwise be uploaded are read. A nil re disables the
+// check.
+func (u *Uploader) SetExcludeContent(re *regexp.Regexp) {
+	u.excludeContent = re
+}
+
+// excludeByContent marks the pending uploads whose content matches the
+// exclude pattern as skipped. A file that cannot be read is left for the
+// upload to report.
+func (u *Uploader) excludeByContent(uploads []FileUpload) {
+	if u.excludeContent == nil {
+		return
+	}
+	for i := range uploads {
+		if uploads[i].ShouldSkip {
+			continue
+		}
+		match, err := contentMatches(uploads[i].LocalPath, u.excludeContent)
+		if err != nil {
+			if u.debug {
+				fmt.Fprintf(u.errOut, "[DEBUG] content check of %s: %v\n", uploads[i].LocalPath, err)
+			}
+			continue
+		}
+		if match {
+			uploads[i].ShouldSkip = true
+			uploads[i].SkipReason = "matches --exclude-content-pattern"
+		}
+	}
+}
+
+// contentMatches reports whether re matches the first ContentScanLimit bytes
+// of the file at path.
+func contentMatches(path string, re *regexp.Regexp) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = f.Close() }()
+
+	head, err := io.ReadAll(io.LimitReader(f, ContentScanLimit))
+	if err != nil {
+		return false, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return re.Match(head), nil
+}
@@ -0,0 +1,59 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+func TestDiscoverFilesExcludeContent(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	padding := strings.Repeat(`{"type":"user"}`+"\n", ContentScanLimit/16+1)
+	files := map[string]string{
+		"fixture.jsonl": `{"type":"user"}` + "\n" + `{"test_fixture": true}` + "\n",
+		"spaced.jsonl":  `{"test_fixture":true}` + "\n",
+		"real.jsonl":    `{"type":"user","test_fixture": false}` + "\n",
+		"late.jsonl":    padding + `{"test_fixture": true}` + "\n", // Marker beyond the scan limit
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(projectDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: tmpDir},
+		S3:    types.S3Config{Prefix: "claude-code/"},
+	}
+	u := New(cfg, nil, true, false)
+	u.SetExcludeContent(regexp.MustCompile(`"test_fixture":\s*true`))
+
+	uploads, err := u.DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+
+	want := map[string]bool{"fixture.jsonl": true, "spaced.jsonl": true, "real.jsonl": false, "late.jsonl": false}
+	if len(uploads) != len(want) {
+		t.Fatalf("got %d files, want %d", len(uploads), len(want))
+	}
+	for _, f := range uploads {
+		name := filepath.Base(f.LocalPath)
+		if f.ShouldSkip != want[name] {
+			t.Errorf("%s: ShouldSkip = %v, want %v", name, f.ShouldSkip, want[name])
+		}
+		if want[name] && f.SkipReason != "matches --exclude-content-pattern" {
+			t.Errorf("%s: SkipReason = %q", name, f.SkipReason)
+		}
+	}
+}
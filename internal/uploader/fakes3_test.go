@@ -0,0 +1,105 @@
+package uploader
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// fakeS3 is a minimal in-memory S3 server supporting path-style GET, HEAD, and PUT.
+// onPut, if set, is called after each object is stored; beforeStore is
+// called once the body has been received but before it is stored.
+type fakeS3 struct {
+	mu          sync.Mutex
+	objects     map[string][]byte
+	headers     map[string]http.Header // Request headers of each object's last PUT
+	onPut       func(key string)
+	beforeStore func(key string)
+}
+
+// newFakeS3 starts a fake S3 server and returns a real S3 client pointed at it.
+func newFakeS3(t *testing.T) (*s3.Client, *fakeS3) {
+	t.Helper()
+
+	f := &fakeS3{objects: make(map[string][]byte), headers: make(map[string]http.Header)}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+
+	client := s3.New(s3.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(srv.URL),
+		UsePathStyle: true,
+		Credentials:  credentials.NewStaticCredentialsProvider("AKID", "SECRET", ""),
+	})
+
+	return client, f
+}
+
+// object returns the stored content for key.
+func (f *fakeS3) object(key string) ([]byte, bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	data, ok := f.objects[key]
+	return data, ok
+}
+
+// header returns the request headers of the last PUT to key.
+func (f *fakeS3) header(key string) http.Header {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.headers[key]
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// Path-style: /<bucket>/<key>
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) != 2 {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	key := parts[1]
+
+	switch r.Method {
+	case http.MethodPut:
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if f.beforeStore != nil {
+			f.beforeStore(key)
+		}
+		f.mu.Lock()
+		f.objects[key] = data
+		f.headers[key] = r.Header.Clone()
+		f.mu.Unlock()
+		w.Header().Set("ETag", `"etag"`)
+		w.WriteHeader(http.StatusOK)
+		if f.onPut != nil {
+			f.onPut(key)
+		}
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.object(key)
+		if !ok {
+			w.Header().Set("Content-Type", "application/xml")
+			w.WriteHeader(http.StatusNotFound)
+			if r.Method == http.MethodGet {
+				_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+			}
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
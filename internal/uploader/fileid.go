@@ -0,0 +1,43 @@
+package uploader
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// cleanedPathIdentity returns the absolute, symlink-resolved path for use as a file identity.
+func cleanedPathIdentity(path string) (string, error) {
+	resolved, err := filepath.EvalSymlinks(path)
+	if err != nil {
+		return "", fmt.Errorf("resolving symlinks for %s: %w", path, err)
+	}
+
+	abs, err := filepath.Abs(resolved)
+	if err != nil {
+		return "", fmt.Errorf("computing absolute path for %s: %w", path, err)
+	}
+
+	return filepath.Clean(abs), nil
+}
+
+// markDuplicates marks files that refer to the same physical file as an earlier
+// entry in the batch. The first occurrence is kept; later ones are skipped with
+// a reason naming the key that will be uploaded instead.
+func markDuplicates(uploads []FileUpload) {
+	seen := make(map[string]string) // identity → S3 key of first occurrence
+
+	for i := range uploads {
+		id, err := fileIdentity(uploads[i].LocalPath)
+		if err != nil {
+			// Can't identify the file; let the upload surface the real error
+			continue
+		}
+
+		if firstKey, ok := seen[id]; ok {
+			uploads[i].ShouldSkip = true
+			uploads[i].SkipReason = "duplicate of " + firstKey
+			continue
+		}
+		seen[id] = uploads[i].S3Key
+	}
+}
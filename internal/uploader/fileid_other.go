@@ -0,0 +1,9 @@
+//go:build !unix
+
+package uploader
+
+// fileIdentity returns a key that identifies the physical file at path.
+// Without inode information, the cleaned absolute path with symlinks resolved is used.
+func fileIdentity(path string) (string, error) {
+	return cleanedPathIdentity(path)
+}
@@ -0,0 +1,26 @@
+//go:build unix
+
+package uploader
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+// fileIdentity returns a key that uniquely identifies the physical file at path.
+// On unix systems this is the (device, inode) pair of the symlink target, so
+// hard links and symlinks to the same file resolve to the same identity.
+func fileIdentity(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("stat %s: %w", path, err)
+	}
+
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return cleanedPathIdentity(path)
+	}
+
+	return fmt.Sprintf("%d:%d", st.Dev, st.Ino), nil
+}
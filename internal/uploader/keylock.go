@@ -0,0 +1,76 @@
+package uploader
+
+import (
+	"sync"
+
+	"github.com/13rac1/cclogs/internal/manifest"
+)
+
+// keyLocks serializes uploads of the same S3 key across concurrent Upload
+// calls and remembers which snapshot each key stored last, so a slow upload
+// of an old snapshot can neither overwrite a newer object nor leave a stale
+// manifest entry behind.
+type keyLocks struct {
+	mu     sync.Mutex
+	locks  map[string]*sync.Mutex
+	stored map[string]storedSnapshot
+}
+
+// storedSnapshot is the manifest entry for the content a key stored last.
+// A torn upload (file modified mid-upload) has no valid entry.
+type storedSnapshot struct {
+	entry manifest.FileEntry
+	torn  bool
+}
+
+// lock blocks until no other upload of key is in flight and returns the
+// function that releases it.
+func (k *keyLocks) lock(key string) func() {
+	k.mu.Lock()
+	if k.locks == nil {
+		k.locks = make(map[string]*sync.Mutex)
+	}
+	l, ok := k.locks[key]
+	if !ok {
+		l = &sync.Mutex{}
+		k.locks[key] = l
+	}
+	k.mu.Unlock()
+
+	l.Lock()
+	return l.Unlock
+}
+
+// record notes the snapshot just stored under key. Call it while holding the
+// key's lock so records follow the order objects were stored.
+func (k *keyLocks) record(key string, s storedSnapshot) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if k.stored == nil {
+		k.stored = make(map[string]storedSnapshot)
+	}
+	k.stored[key] = s
+}
+
+// alreadyStored reports whether key's last stored snapshot is file, in which
+// case a queued upload of the same snapshot can be coalesced away.
+func (k *keyLocks) alreadyStored(file FileUpload) bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	s, ok := k.stored[file.S3Key]
+	return ok && !s.torn && s.entry.Size == file.Size && s.entry.Mtime.Equal(file.ModTime)
+}
+
+// apply overwrites m's entries with the snapshots stored last, dropping
+// entries whose latest upload was torn so the next run uploads them again.
+func (k *keyLocks) apply(m *manifest.Manifest) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	for key, s := range k.stored {
+		if s.torn {
+			delete(m.Files, key)
+		} else {
+			m.Files[key] = s.entry
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package uploader
+
+import (
+	"fmt"
+
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+// Defaults for the memory ceiling when upload.memory_limit is unset: a share
+// of detected system memory, or a fixed size where it can't be detected.
+const (
+	memoryLimitDivisor  = 4
+	fallbackMemoryLimit = 1 << 30
+)
+
+// MemoryEnvelope is the worst-case memory an upload run buffers: each file
+// in flight may hold its spool buffer plus part_concurrency parts.
+type MemoryEnvelope struct {
+	Files           int   // Files uploaded at once (uploads are sequential)
+	Buffer          int64 // In-memory spool buffer per file
+	PartSize        int64
+	PartConcurrency int
+}
+
+// NewMemoryEnvelope returns the envelope of cfg's upload settings.
+func NewMemoryEnvelope(cfg *types.Config) MemoryEnvelope {
+	return MemoryEnvelope{
+		Files:           1,
+		Buffer:          spoolBuffer(cfg),
+		PartSize:        int64(cfg.Upload.PartSize),
+		PartConcurrency: cfg.Upload.PartConcurrency,
+	}
+}
+
+// spoolBuffer returns the most a single-PUT file keeps in memory.
+func spoolBuffer(cfg *types.Config) int64 {
+	threshold := int64(cfg.Upload.SpoolThreshold)
+	switch cfg.Upload.Spool {
+	case spoolMemory:
+		return threshold
+	case spoolDisk:
+		return 0
+	default:
+		return min(threshold, int64(cfg.Upload.SpoolMemory))
+	}
+}
+
+// Total returns the envelope in bytes.
+func (e MemoryEnvelope) Total() int64 {
+	return int64(e.Files) * (e.Buffer + e.PartSize*int64(e.PartConcurrency))
+}
+
+// String shows the arithmetic, e.g. "1 file × (8MiB spool + 5MiB part × 5) = 33MiB".
+func (e MemoryEnvelope) String() string {
+	files := "files"
+	if e.Files == 1 {
+		files = "file"
+	}
+	return fmt.Sprintf("%d %s × (%s spool + %s part × %d) = %s",
+		e.Files, files, types.ByteSize(e.Buffer), types.ByteSize(e.PartSize), e.PartConcurrency, types.ByteSize(e.Total()))
+}
+
+// MemoryLimit returns the ceiling for the envelope: upload.memory_limit if
+// set, otherwise a quarter of systemMemory, or a fixed fallback when
+// systemMemory is unknown (zero).
+func MemoryLimit(cfg *types.Config, systemMemory int64) int64 {
+	if cfg.Upload.MemoryLimit > 0 {
+		return int64(cfg.Upload.MemoryLimit)
+	}
+	if systemMemory > 0 {
+		// Round down to MiB so the limit prints readably
+		return (systemMemory / memoryLimitDivisor) &^ (1<<20 - 1)
+	}
+	return fallbackMemoryLimit
+}
+
+// BoundMemory fits cfg's envelope under limit. If it is too large, the part
+// concurrency in cfg is lowered to the most that fits; if even one part at a
+// time exceeds the limit, an error explains which settings to change. It
+// returns the resulting envelope.
+func BoundMemory(cfg *types.Config, limit int64) (MemoryEnvelope, error) {
+	e := NewMemoryEnvelope(cfg)
+	if e.Total() <= limit {
+		return e, nil
+	}
+
+	perFile := limit / int64(e.Files)
+	fit := 0
+	if e.PartSize > 0 && perFile > e.Buffer {
+		fit = int((perFile - e.Buffer) / e.PartSize)
+	}
+	if fit < 1 {
+		e.PartConcurrency = 1
+		return e, fmt.Errorf("upload memory envelope %s exceeds the limit of %s even with part_concurrency 1; lower upload.part_size or upload.spool_memory, or raise upload.memory_limit",
+			e, types.ByteSize(limit))
+	}
+
+	e.PartConcurrency = fit
+	cfg.Upload.PartConcurrency = fit
+	return e, nil
+}
@@ -0,0 +1,31 @@
+package uploader
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// SystemMemory returns the memory available to this process: physical RAM,
+// or the cgroup limit when running in a container that sets a lower one.
+func SystemMemory() (int64, bool) {
+	var info syscall.Sysinfo_t
+	if err := syscall.Sysinfo(&info); err != nil {
+		return 0, false
+	}
+	total := int64(uint64(info.Totalram) * uint64(info.Unit))
+
+	// cgroup v2, then v1; "max" or an absurd v1 value means unlimited
+	for _, path := range []string{"/sys/fs/cgroup/memory.max", "/sys/fs/cgroup/memory/memory.limit_in_bytes"} {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		if limit, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64); err == nil && limit > 0 && limit < total {
+			return limit, true
+		}
+		break
+	}
+	return total, true
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package uploader
+
+// SystemMemory is not implemented on this platform.
+func SystemMemory() (int64, bool) {
+	return 0, false
+}
@@ -0,0 +1,136 @@
+package uploader
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+func memoryConfig(spool string, partSize types.ByteSize, partConcurrency int) *types.Config {
+	return &types.Config{Upload: types.UploadConfig{
+		PartSize:        partSize,
+		PartConcurrency: partConcurrency,
+		Spool:           spool,
+		SpoolThreshold:  partSize,
+		SpoolMemory:     8 << 20,
+	}}
+}
+
+func TestMemoryEnvelope(t *testing.T) {
+	tests := []struct {
+		name      string
+		cfg       *types.Config
+		wantTotal int64
+		wantText  string
+	}{
+		{
+			name:      "defaults",
+			cfg:       memoryConfig("auto", 5<<20, 5),
+			wantTotal: 30 << 20,
+			wantText:  "1 file × (5MiB spool + 5MiB part × 5) = 30MiB",
+		},
+		{
+			name:      "auto spool capped by spool_memory",
+			cfg:       memoryConfig("auto", 64<<20, 4),
+			wantTotal: (8 + 64*4) << 20,
+		},
+		{
+			name:      "memory spool holds whole threshold",
+			cfg:       memoryConfig("memory", 64<<20, 4),
+			wantTotal: (64 + 64*4) << 20,
+		},
+		{
+			name:      "disk spool holds nothing",
+			cfg:       memoryConfig("disk", 64<<20, 4),
+			wantTotal: 64 * 4 << 20,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := NewMemoryEnvelope(tt.cfg)
+			if got := e.Total(); got != tt.wantTotal {
+				t.Errorf("Total() = %d, want %d", got, tt.wantTotal)
+			}
+			if tt.wantText != "" && e.String() != tt.wantText {
+				t.Errorf("String() = %q, want %q", e.String(), tt.wantText)
+			}
+		})
+	}
+}
+
+func TestMemoryLimit(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured types.ByteSize
+		system     int64
+		want       int64
+	}{
+		{"configured wins", 256 << 20, 16 << 30, 256 << 20},
+		{"quarter of system memory", 0, 2 << 30, 512 << 20},
+		{"rounded down to MiB", 0, 2<<30 + 4096, 512 << 20},
+		{"fallback when undetected", 0, 0, fallbackMemoryLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &types.Config{Upload: types.UploadConfig{MemoryLimit: tt.configured}}
+			if got := MemoryLimit(cfg, tt.system); got != tt.want {
+				t.Errorf("MemoryLimit() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBoundMemory(t *testing.T) {
+	tests := []struct {
+		name            string
+		cfg             *types.Config
+		system          int64 // Injected system memory
+		wantConcurrency int
+		wantErr         string
+	}{
+		{
+			name:            "fits",
+			cfg:             memoryConfig("auto", 5<<20, 5),
+			system:          1 << 30,
+			wantConcurrency: 5,
+		},
+		{
+			name: "clamped on a small VPS",
+			// 8MiB + 64MiB × 32 = 2056MiB against a quarter of 1GiB
+			cfg:             memoryConfig("auto", 64<<20, 32),
+			system:          1 << 30,
+			wantConcurrency: 3,
+		},
+		{
+			name:    "refused when one part does not fit",
+			cfg:     memoryConfig("memory", 512<<20, 4),
+			system:  1 << 30,
+			wantErr: "even with part_concurrency 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limit := MemoryLimit(tt.cfg, tt.system)
+			e, err := BoundMemory(tt.cfg, limit)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("BoundMemory() error = %v, want containing %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("BoundMemory() error = %v", err)
+			}
+			if e.PartConcurrency != tt.wantConcurrency || tt.cfg.Upload.PartConcurrency != tt.wantConcurrency {
+				t.Errorf("part concurrency = %d (config %d), want %d", e.PartConcurrency, tt.cfg.Upload.PartConcurrency, tt.wantConcurrency)
+			}
+			if e.Total() > limit {
+				t.Errorf("envelope %s exceeds limit %d", e, limit)
+			}
+		})
+	}
+}
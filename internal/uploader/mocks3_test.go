@@ -0,0 +1,237 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// mockS3 is an in-memory s3API that records every completed object write.
+type mockS3 struct {
+	mu      sync.Mutex
+	objects map[string][]byte
+	written map[string]time.Time     // key → when it was last stored
+	headers map[string]objectHeaders // key → headers of the last write
+	meta    map[string]map[string]string
+	puts    []string            // keys in write order, including the manifest
+	reads   []string            // GetObject, HeadObject, and ListObjectsV2 calls as "GET key" / "HEAD key" / "LIST prefix"
+	parts   map[string][][]byte // multipart upload ID → parts
+
+	failPut  func(key string) bool    // If set, writes to matching keys fail
+	putErr   error                    // Error failed writes return, if not the default
+	failList func(prefix string) bool // If set, listings of matching prefixes fail
+}
+
+// objectHeaders captures the metadata sent when an object was created.
+type objectHeaders struct {
+	ContentType     string
+	CacheControl    string
+	ContentEncoding string
+	ObjectLockMode  string
+	RetainUntil     time.Time
+}
+
+func newMockS3() *mockS3 {
+	return &mockS3{
+		objects: make(map[string][]byte),
+		written: make(map[string]time.Time),
+		headers: make(map[string]objectHeaders),
+		meta:    make(map[string]map[string]string),
+		parts:   make(map[string][][]byte),
+	}
+}
+
+func (m *mockS3) captureHeaders(key string, h objectHeaders) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.headers[key] = h
+}
+
+// header returns the headers captured for key.
+func (m *mockS3) header(key string) objectHeaders {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.headers[key]
+}
+
+func (m *mockS3) store(key string, body []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.objects[key] = body
+	m.written[key] = time.Now()
+	m.puts = append(m.puts, key)
+}
+
+// object returns the stored body for key.
+func (m *mockS3) object(key string) ([]byte, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	body, ok := m.objects[key]
+	return body, ok
+}
+
+// putKeys returns the keys written, in order.
+func (m *mockS3) putKeys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.puts...)
+}
+
+// readLog returns the reads made, in order.
+func (m *mockS3) readLog() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.reads...)
+}
+
+func (m *mockS3) recordRead(call string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.reads = append(m.reads, call)
+}
+
+func (m *mockS3) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	key := aws.ToString(params.Key)
+	m.recordRead("HEAD " + key)
+	body, ok := m.object(key)
+	if !ok {
+		return nil, &s3types.NotFound{}
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return &s3.HeadObjectOutput{ContentLength: aws.Int64(int64(len(body))), Metadata: m.meta[key]}, nil
+}
+
+func (m *mockS3) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	m.recordRead("LIST " + aws.ToString(params.Prefix))
+	if m.failList != nil && m.failList(aws.ToString(params.Prefix)) {
+		return nil, errors.New("access denied")
+	}
+	var contents []s3types.Object
+	for _, key := range m.objectKeys() {
+		body, _ := m.object(key)
+		if strings.HasPrefix(key, aws.ToString(params.Prefix)) {
+			m.mu.Lock()
+			written := m.written[key]
+			m.mu.Unlock()
+			contents = append(contents, s3types.Object{Key: aws.String(key), Size: aws.Int64(int64(len(body))), LastModified: aws.Time(written)})
+		}
+	}
+	return &s3.ListObjectsV2Output{Contents: contents}, nil
+}
+
+func (m *mockS3) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	m.recordRead("GET " + aws.ToString(params.Key))
+	body, ok := m.object(aws.ToString(params.Key))
+	if !ok {
+		return nil, &s3types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+// putError returns the injected failure for a write to key, if any.
+func (m *mockS3) putError(key string) error {
+	if m.failPut != nil && m.failPut(key) {
+		if m.putErr != nil {
+			return m.putErr
+		}
+		return fmt.Errorf("injected failure writing %s", key)
+	}
+	return nil
+}
+
+func (m *mockS3) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	if err := m.putError(aws.ToString(params.Key)); err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.captureHeaders(aws.ToString(params.Key), objectHeaders{
+		ContentType:     aws.ToString(params.ContentType),
+		CacheControl:    aws.ToString(params.CacheControl),
+		ContentEncoding: aws.ToString(params.ContentEncoding),
+		ObjectLockMode:  string(params.ObjectLockMode),
+		RetainUntil:     aws.ToTime(params.ObjectLockRetainUntilDate),
+	})
+	m.mu.Lock()
+	m.meta[aws.ToString(params.Key)] = params.Metadata
+	m.mu.Unlock()
+	m.store(aws.ToString(params.Key), body)
+	return &s3.PutObjectOutput{ETag: aws.String(fmt.Sprintf(`"%x"`, md5.Sum(body)))}, nil
+}
+
+func (m *mockS3) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	if err := m.putError(aws.ToString(params.Key)); err != nil {
+		return nil, err
+	}
+	m.captureHeaders(aws.ToString(params.Key), objectHeaders{
+		ContentType:     aws.ToString(params.ContentType),
+		CacheControl:    aws.ToString(params.CacheControl),
+		ContentEncoding: aws.ToString(params.ContentEncoding),
+		ObjectLockMode:  string(params.ObjectLockMode),
+		RetainUntil:     aws.ToTime(params.ObjectLockRetainUntilDate),
+	})
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := fmt.Sprintf("upload-%d", len(m.parts)+1)
+	m.parts[id] = nil
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String(id)}, nil
+}
+
+func (m *mockS3) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	body, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	id := aws.ToString(params.UploadId)
+	n := int(aws.ToInt32(params.PartNumber))
+	for len(m.parts[id]) < n {
+		m.parts[id] = append(m.parts[id], nil)
+	}
+	m.parts[id][n-1] = body
+	return &s3.UploadPartOutput{ETag: aws.String(fmt.Sprintf("etag-%d", n))}, nil
+}
+
+func (m *mockS3) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	m.mu.Lock()
+	parts := m.parts[aws.ToString(params.UploadId)]
+	delete(m.parts, aws.ToString(params.UploadId))
+	m.mu.Unlock()
+
+	m.store(aws.ToString(params.Key), bytes.Join(parts, nil))
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (m *mockS3) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.parts, aws.ToString(params.UploadId))
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+// objectKeys returns all stored keys, sorted.
+func (m *mockS3) objectKeys() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	keys := make([]string, 0, len(m.objects))
+	for k := range m.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
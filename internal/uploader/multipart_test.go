@@ -0,0 +1,154 @@
+package uploader
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// recordingUploadClient records which upload API calls were made.
+type recordingUploadClient struct {
+	mu    sync.Mutex
+	calls []string
+	body  []byte
+	put   *s3.PutObjectInput
+}
+
+func (r *recordingUploadClient) record(call string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.calls = append(r.calls, call)
+}
+
+func (r *recordingUploadClient) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	r.record("PutObject")
+	data, err := io.ReadAll(params.Body)
+	if err != nil {
+		return nil, err
+	}
+	r.body = data
+	r.put = params
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (r *recordingUploadClient) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	r.record("UploadPart")
+	if _, err := io.Copy(io.Discard, params.Body); err != nil {
+		return nil, err
+	}
+	return &s3.UploadPartOutput{ETag: aws.String("etag")}, nil
+}
+
+func (r *recordingUploadClient) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	r.record("CreateMultipartUpload")
+	return &s3.CreateMultipartUploadOutput{UploadId: aws.String("upload-id")}, nil
+}
+
+func (r *recordingUploadClient) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	r.record("CompleteMultipartUpload")
+	return &s3.CompleteMultipartUploadOutput{}, nil
+}
+
+func (r *recordingUploadClient) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	r.record("AbortMultipartUpload")
+	return &s3.AbortMultipartUploadOutput{}, nil
+}
+
+func TestNewMultipartUploader(t *testing.T) {
+	tests := []struct {
+		name            string
+		upload          types.UploadConfig
+		wantPartSize    int64
+		wantConcurrency int
+	}{
+		{
+			name:            "configured values",
+			upload:          types.UploadConfig{PartSize: 16 << 20, PartConcurrency: 3},
+			wantPartSize:    16 << 20,
+			wantConcurrency: 3,
+		},
+		{
+			name:            "unset values use SDK defaults",
+			upload:          types.UploadConfig{},
+			wantPartSize:    5 << 20,
+			wantConcurrency: 5,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := New(&types.Config{Upload: tt.upload}, nil, true, false)
+			mu := u.newMultipartUploader(&recordingUploadClient{})
+
+			if mu.PartSize != tt.wantPartSize {
+				t.Errorf("PartSize = %d, want %d", mu.PartSize, tt.wantPartSize)
+			}
+			if mu.Concurrency != tt.wantConcurrency {
+				t.Errorf("Concurrency = %d, want %d", mu.Concurrency, tt.wantConcurrency)
+			}
+		})
+	}
+}
+
+func TestUploadFilePathSelection(t *testing.T) {
+	const partSize = 5 << 20
+
+	tests := []struct {
+		name      string
+		size      int
+		wantCalls []string
+	}{
+		{
+			name:      "small file uses single PUT",
+			size:      1024,
+			wantCalls: []string{"PutObject"},
+		},
+		{
+			name:      "file at part size uses multipart",
+			size:      partSize + 1,
+			wantCalls: []string{"CreateMultipartUpload", "UploadPart", "UploadPart", "CompleteMultipartUpload"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "session.jsonl")
+			content := strings.Repeat("x", tt.size)
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := &types.Config{
+				S3:     types.S3Config{Bucket: "test-bucket"},
+				Upload: types.UploadConfig{PartSize: partSize, PartConcurrency: 1},
+			}
+			u := New(cfg, nil, true, false)
+			client := &recordingUploadClient{}
+			mu := u.newMultipartUploader(client)
+
+			file := FileUpload{LocalPath: path, S3Key: "p/session.jsonl", Size: int64(tt.size)}
+			_, digests, err := u.uploadFile(context.Background(), client, mu, file, nil)
+			if err != nil {
+				t.Fatalf("uploadFile failed: %v", err)
+			}
+
+			if strings.Join(client.calls, ",") != strings.Join(tt.wantCalls, ",") {
+				t.Errorf("calls = %v, want %v", client.calls, tt.wantCalls)
+			}
+			if digests.object.size != int64(tt.size) {
+				t.Errorf("digest size = %d, want %d", digests.object.size, tt.size)
+			}
+			if digests.source.size != int64(tt.size) {
+				t.Errorf("source digest size = %d, want %d", digests.source.size, tt.size)
+			}
+		})
+	}
+}
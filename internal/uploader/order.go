@@ -0,0 +1,97 @@
+package uploader
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/13rac1/cclogs/internal/output"
+)
+
+// Upload orders accepted by SortFiles.
+const (
+	OrderOldest = "oldest"
+	OrderNewest = "newest"
+	OrderName   = "name"
+)
+
+// SortFiles orders files for upload: by modification time ("oldest" or
+// "newest" first) or by local path ("name"). Ties fall back to the path so
+// the order is deterministic.
+func SortFiles(files []FileUpload, order string) error {
+	var less func(a, b FileUpload) bool
+	switch order {
+	case OrderOldest:
+		less = func(a, b FileUpload) bool { return a.ModTime.Before(b.ModTime) }
+	case OrderNewest:
+		less = func(a, b FileUpload) bool { return a.ModTime.After(b.ModTime) }
+	case OrderName:
+		less = func(a, b FileUpload) bool { return false }
+	default:
+		return fmt.Errorf("unknown order %q (use %s, %s, or %s)", order, OrderOldest, OrderNewest, OrderName)
+	}
+
+	sort.SliceStable(files, func(i, j int) bool {
+		if less(files[i], files[j]) {
+			return true
+		}
+		if less(files[j], files[i]) {
+			return false
+		}
+		return files[i].LocalPath < files[j].LocalPath
+	})
+	return nil
+}
+
+// Deferred counts files left out of a run by Limit.
+type Deferred struct {
+	Files int
+	Bytes int64
+}
+
+// String describes the deferred work for the run summary.
+func (d Deferred) String() string {
+	word := "files"
+	if d.Files == 1 {
+		word = "file"
+	}
+	return fmt.Sprintf("%d %s (%s)", d.Files, word, output.FormatSize(d.Bytes))
+}
+
+// Limit stops queueing uploads once maxFiles files or maxBytes bytes are
+// queued; zero disables either cap. Skipped files pass through and do not
+// count. The first upload is always queued so a run makes progress even
+// when a single file exceeds maxBytes. Files after the cap are dropped and
+// counted in Deferred; the manifest picks them up on the next run.
+func Limit(files []FileUpload, maxFiles int, maxBytes int64) ([]FileUpload, Deferred) {
+	var (
+		queued      []FileUpload
+		deferred    Deferred
+		count       int
+		queuedBytes int64
+		full        bool
+	)
+
+	for _, file := range files {
+		if file.ShouldSkip {
+			queued = append(queued, file)
+			continue
+		}
+
+		if !full {
+			overFiles := maxFiles > 0 && count >= maxFiles
+			overBytes := maxBytes > 0 && count > 0 && queuedBytes+file.Size > maxBytes
+			full = overFiles || overBytes
+		}
+		if full {
+			deferred.Files++
+			deferred.Bytes += file.Size
+			continue
+		}
+
+		queued = append(queued, file)
+		count++
+		queuedBytes += file.Size
+	}
+
+	return queued, deferred
+}
@@ -0,0 +1,93 @@
+package uploader
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSortFiles(t *testing.T) {
+	base := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	files := func() []FileUpload {
+		return []FileUpload{
+			{LocalPath: "/p/b.jsonl", ModTime: base.Add(2 * time.Hour)},
+			{LocalPath: "/p/c.jsonl", ModTime: base},
+			{LocalPath: "/p/a.jsonl", ModTime: base.Add(time.Hour)},
+			{LocalPath: "/p/d.jsonl", ModTime: base},
+		}
+	}
+
+	tests := []struct {
+		order   string
+		want    string
+		wantErr bool
+	}{
+		{order: OrderOldest, want: "c,d,a,b"},
+		{order: OrderNewest, want: "b,a,c,d"},
+		{order: OrderName, want: "a,b,c,d"},
+		{order: "random", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.order, func(t *testing.T) {
+			fs := files()
+			err := SortFiles(fs, tt.order)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("SortFiles() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			var got []string
+			for _, f := range fs {
+				got = append(got, strings.TrimSuffix(strings.TrimPrefix(f.LocalPath, "/p/"), ".jsonl"))
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("order = %v, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLimit(t *testing.T) {
+	files := []FileUpload{
+		{LocalPath: "a", Size: 100},
+		{LocalPath: "skipped", Size: 1000, ShouldSkip: true},
+		{LocalPath: "b", Size: 200},
+		{LocalPath: "c", Size: 300},
+		{LocalPath: "d", Size: 50},
+	}
+
+	tests := []struct {
+		name         string
+		maxFiles     int
+		maxBytes     int64
+		want         string
+		wantDeferred Deferred
+	}{
+		{name: "no limits", want: "a,skipped,b,c,d"},
+		{name: "file limit", maxFiles: 2, want: "a,skipped,b", wantDeferred: Deferred{Files: 2, Bytes: 350}},
+		{name: "byte limit stops at first file that does not fit", maxBytes: 350, want: "a,skipped,b", wantDeferred: Deferred{Files: 2, Bytes: 350}},
+		{name: "first file always queued", maxBytes: 10, want: "a,skipped", wantDeferred: Deferred{Files: 3, Bytes: 550}},
+		{name: "both limits", maxFiles: 3, maxBytes: 10000, want: "a,skipped,b,c", wantDeferred: Deferred{Files: 1, Bytes: 50}},
+		{name: "skipped files do not count", maxFiles: 4, want: "a,skipped,b,c,d"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			queued, deferred := Limit(files, tt.maxFiles, tt.maxBytes)
+
+			var got []string
+			for _, f := range queued {
+				got = append(got, f.LocalPath)
+			}
+			if strings.Join(got, ",") != tt.want {
+				t.Errorf("queued = %v, want %s", got, tt.want)
+			}
+			if deferred != tt.wantDeferred {
+				t.Errorf("deferred = %+v, want %+v", deferred, tt.wantDeferred)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/manifest"
+)
+
+// SetDatePartition stores the objects uploaded by this run under the prefix
+// followed by day's UTC date (YYYY/MM/DD/). Files keep their undated key in
+// the manifest, so unchanged files are skipped as usual and only new or
+// changed content lands in the day's partition. The zero time disables it.
+func (u *Uploader) SetDatePartition(day time.Time) {
+	u.datePartition = day
+}
+
+// partition assigns dated object keys to the files left to upload. A file
+// whose mtime changed but whose content hashes to the one already uploaded
+// is skipped instead, so touching a file doesn't copy it into a new
+// partition.
+func (u *Uploader) partition(ctx context.Context, uploads []FileUpload, m *manifest.Manifest) {
+	prefix := config.KeyPrefix(u.cfg)
+	for i := range uploads {
+		if uploads[i].ShouldSkip {
+			continue
+		}
+
+		if entry, ok := m.Files[uploads[i].S3Key]; ok && entry.SourceSHA256 != "" && entry.Size == uploads[i].Size {
+			sum, n, err := hashPrefix(ctx, uploads[i].LocalPath, uploads[i].Size)
+			if err != nil {
+				fmt.Fprintf(u.errOut, "Warning: %s: %v\n", uploads[i].LocalPath, err)
+			} else if n == entry.Size && sum == entry.SourceSHA256 {
+				uploads[i].ShouldSkip = true
+				uploads[i].SkipReason = "unchanged content"
+				continue
+			}
+		}
+
+		uploads[i].ObjectKey = config.DatedKey(prefix, uploads[i].S3Key, u.datePartition)
+	}
+}
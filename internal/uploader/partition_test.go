@@ -0,0 +1,97 @@
+package uploader
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+func TestDatePartition(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "app")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string, mtime time.Time) {
+		t.Helper()
+		path := filepath.Join(projectDir, name)
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: tmpDir},
+		S3:    types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+	}
+	client := newMockS3()
+	ctx := context.Background()
+	run := func(day time.Time) {
+		t.Helper()
+		u := newUploader(cfg, client, false, false)
+		u.SetOutput(nil, nil)
+		u.SetDatePartition(day)
+		files, err := u.DiscoverFiles(ctx)
+		if err != nil {
+			t.Fatalf("DiscoverFiles() error = %v", err)
+		}
+		if _, err := u.Upload(ctx, files); err != nil {
+			t.Fatalf("Upload() error = %v", err)
+		}
+	}
+
+	day1 := time.Date(2025, 3, 7, 10, 0, 0, 0, time.UTC)
+	day2 := day1.Add(24 * time.Hour)
+	mtime := day1.Add(-time.Hour)
+	write("kept.jsonl", `{"n":1}`+"\n", mtime)
+	write("touched.jsonl", `{"n":2}`+"\n", mtime)
+	write("grown.jsonl", `{"n":3}`+"\n", mtime)
+	run(day1)
+
+	// Day 2: one file untouched, one touched without changing content, one
+	// appended to, and one new
+	write("touched.jsonl", `{"n":2}`+"\n", day2.Add(-time.Hour))
+	write("grown.jsonl", `{"n":3}`+"\n"+`{"n":4}`+"\n", day2.Add(-time.Hour))
+	write("new.jsonl", `{"n":5}`+"\n", day2.Add(-time.Hour))
+	run(day2)
+
+	wantObjects := []string{
+		"claude-code/.manifest.json",
+		"claude-code/2025/03/07/app/grown.jsonl",
+		"claude-code/2025/03/07/app/kept.jsonl",
+		"claude-code/2025/03/07/app/touched.jsonl",
+		"claude-code/2025/03/08/app/grown.jsonl",
+		"claude-code/2025/03/08/app/new.jsonl",
+	}
+	if got := client.objectKeys(); !slices.Equal(got, wantObjects) {
+		t.Errorf("objects = %v, want %v", got, wantObjects)
+	}
+
+	m, err := manifest.Load(ctx, client, "test-bucket", "claude-code/.manifest.json", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantEntries := map[string]string{
+		"claude-code/app/kept.jsonl":    "claude-code/2025/03/07/app/kept.jsonl",
+		"claude-code/app/touched.jsonl": "claude-code/2025/03/07/app/touched.jsonl",
+		"claude-code/app/grown.jsonl":   "claude-code/2025/03/08/app/grown.jsonl",
+		"claude-code/app/new.jsonl":     "claude-code/2025/03/08/app/new.jsonl",
+	}
+	if len(m.Files) != len(wantEntries) {
+		t.Errorf("manifest has %d entries, want %d", len(m.Files), len(wantEntries))
+	}
+	for key, object := range wantEntries {
+		if got := m.Files[key].ObjectKey(key); got != object {
+			t.Errorf("entry %s stored at %q, want %q", key, got, object)
+		}
+	}
+}
@@ -0,0 +1,127 @@
+package uploader
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/output"
+	"github.com/13rac1/cclogs/internal/types"
+	"github.com/olekukonko/tablewriter"
+)
+
+// Sources of the throughput a dry run estimates durations with.
+const (
+	ThroughputConfigured = "upload.assumed_throughput"
+	ThroughputObserved   = "previous runs"
+)
+
+// Throughput is the upload rate a dry run assumes.
+type Throughput struct {
+	BytesPerSecond float64 `json:"bytesPerSecond"`
+	Source         string  `json:"source"` // ThroughputConfigured or ThroughputObserved
+}
+
+// ChooseThroughput picks the rate for duration estimates: the configured
+// upload.assumed_throughput if set, otherwise the rate observed in previous
+// runs. It returns nil when neither is known.
+func ChooseThroughput(configured types.ByteSize, observed float64) *Throughput {
+	switch {
+	case configured > 0:
+		return &Throughput{BytesPerSecond: float64(configured), Source: ThroughputConfigured}
+	case observed > 0:
+		return &Throughput{BytesPerSecond: observed, Source: ThroughputObserved}
+	}
+	return nil
+}
+
+// Estimate returns how long uploading bytes takes at this rate.
+func (t Throughput) Estimate(bytes int64) time.Duration {
+	if t.BytesPerSecond <= 0 {
+		return 0
+	}
+	return time.Duration(float64(bytes) / t.BytesPerSecond * float64(time.Second)).Round(time.Second)
+}
+
+// ProjectPlan totals what a run would do with the files of one project.
+type ProjectPlan struct {
+	Project          string  `json:"project"`
+	Uploads          int     `json:"uploads"`
+	UploadBytes      int64   `json:"uploadBytes"`
+	Skipped          int     `json:"skipped"`
+	SkippedBytes     int64   `json:"skippedBytes"`
+	EstimatedSeconds float64 `json:"estimatedSeconds,omitempty"` // Zero without a known throughput
+}
+
+// Plan is the per-project summary of a dry run.
+type Plan struct {
+	Projects   []ProjectPlan `json:"projects"` // Sorted by project
+	Total      ProjectPlan   `json:"total"`    // Project is empty
+	Throughput *Throughput   `json:"throughput,omitempty"`
+}
+
+// NewPlan totals files per project and across all of them, estimating
+// upload durations at rate if it is not nil.
+func NewPlan(files []FileUpload, rate *Throughput) Plan {
+	plan := Plan{Projects: []ProjectPlan{}, Throughput: rate}
+	byProject := make(map[string]*ProjectPlan)
+	for _, f := range files {
+		p := byProject[f.ProjectDir]
+		if p == nil {
+			p = &ProjectPlan{Project: f.ProjectDir}
+			byProject[f.ProjectDir] = p
+		}
+		for _, sum := range []*ProjectPlan{p, &plan.Total} {
+			if f.ShouldSkip {
+				sum.Skipped++
+				sum.SkippedBytes += f.Size
+			} else {
+				sum.Uploads++
+				sum.UploadBytes += f.Size
+			}
+		}
+	}
+
+	for _, p := range byProject {
+		plan.Projects = append(plan.Projects, *p)
+	}
+	sort.Slice(plan.Projects, func(i, j int) bool { return plan.Projects[i].Project < plan.Projects[j].Project })
+	if rate != nil {
+		for i := range plan.Projects {
+			plan.Projects[i].EstimatedSeconds = rate.Estimate(plan.Projects[i].UploadBytes).Seconds()
+		}
+		plan.Total.EstimatedSeconds = rate.Estimate(plan.Total.UploadBytes).Seconds()
+	}
+	return plan
+}
+
+// Print writes the plan as a table with a total line, noting the throughput
+// durations were estimated at.
+func (p Plan) Print(w io.Writer) {
+	table := tablewriter.NewWriter(w)
+	table.Header("Project", "Upload", "Upload Size", "Skip", "Skip Size", "Estimated")
+	for _, pp := range p.Projects {
+		table.Append(pp.Project, strconv.Itoa(pp.Uploads), output.FormatSize(pp.UploadBytes),
+			strconv.Itoa(pp.Skipped), output.FormatSize(pp.SkippedBytes), p.duration(pp))
+	}
+	table.Render()
+
+	fmt.Fprintf(w, "Total: %d would upload (%s), %d would skip (%s)",
+		p.Total.Uploads, output.FormatSize(p.Total.UploadBytes), p.Total.Skipped, output.FormatSize(p.Total.SkippedBytes))
+	if p.Throughput == nil {
+		fmt.Fprintln(w, "; set upload.assumed_throughput to estimate the duration")
+		return
+	}
+	fmt.Fprintf(w, ", about %s at %s/s (%s)\n",
+		p.duration(p.Total), output.FormatSize(int64(p.Throughput.BytesPerSecond)), p.Throughput.Source)
+}
+
+// duration formats the estimated upload time of pp, or "-" if unknown.
+func (p Plan) duration(pp ProjectPlan) string {
+	if p.Throughput == nil {
+		return "-"
+	}
+	return (time.Duration(pp.EstimatedSeconds) * time.Second).String()
+}
@@ -0,0 +1,80 @@
+package uploader
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+func TestNewPlan(t *testing.T) {
+	files := []FileUpload{
+		{ProjectDir: "b", Size: 300},
+		{ProjectDir: "a", Size: 100},
+		{ProjectDir: "a", Size: 50, ShouldSkip: true},
+		{ProjectDir: "a", Size: 200},
+		{ProjectDir: "c", Size: 10, ShouldSkip: true},
+	}
+
+	plan := NewPlan(files, &Throughput{BytesPerSecond: 100, Source: ThroughputConfigured})
+
+	want := []ProjectPlan{
+		{Project: "a", Uploads: 2, UploadBytes: 300, Skipped: 1, SkippedBytes: 50, EstimatedSeconds: 3},
+		{Project: "b", Uploads: 1, UploadBytes: 300, EstimatedSeconds: 3},
+		{Project: "c", Skipped: 1, SkippedBytes: 10},
+	}
+	if len(plan.Projects) != len(want) {
+		t.Fatalf("Projects = %+v, want %+v", plan.Projects, want)
+	}
+	for i := range want {
+		if plan.Projects[i] != want[i] {
+			t.Errorf("Projects[%d] = %+v, want %+v", i, plan.Projects[i], want[i])
+		}
+	}
+	wantTotal := ProjectPlan{Uploads: 3, UploadBytes: 600, Skipped: 2, SkippedBytes: 60, EstimatedSeconds: 6}
+	if plan.Total != wantTotal {
+		t.Errorf("Total = %+v, want %+v", plan.Total, wantTotal)
+	}
+
+	var out bytes.Buffer
+	plan.Print(&out)
+	if !strings.Contains(out.String(), "about 6s at 100 B/s (upload.assumed_throughput)") {
+		t.Errorf("Print() total line missing the estimate:\n%s", out.String())
+	}
+}
+
+func TestNewPlanWithoutThroughput(t *testing.T) {
+	plan := NewPlan([]FileUpload{{ProjectDir: "a", Size: 100}}, nil)
+	if plan.Total.EstimatedSeconds != 0 || plan.Projects[0].EstimatedSeconds != 0 {
+		t.Errorf("estimated without a throughput: %+v", plan)
+	}
+
+	var out bytes.Buffer
+	plan.Print(&out)
+	if !strings.Contains(out.String(), "set upload.assumed_throughput") {
+		t.Errorf("Print() does not explain the missing estimate:\n%s", out.String())
+	}
+}
+
+func TestChooseThroughput(t *testing.T) {
+	tests := []struct {
+		name       string
+		configured int64
+		observed   float64
+		want       *Throughput
+	}{
+		{name: "configured wins", configured: 1000, observed: 500, want: &Throughput{BytesPerSecond: 1000, Source: ThroughputConfigured}},
+		{name: "observed", observed: 500, want: &Throughput{BytesPerSecond: 500, Source: ThroughputObserved}},
+		{name: "unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ChooseThroughput(types.ByteSize(tt.configured), tt.observed)
+			if (got == nil) != (tt.want == nil) || (got != nil && *got != *tt.want) {
+				t.Errorf("ChooseThroughput() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/redactor"
+	"github.com/13rac1/cclogs/internal/types"
+	"github.com/olekukonko/tablewriter"
+)
+
+// DefaultPreviewLines is how many lines of each file a redaction preview
+// samples.
+const DefaultPreviewLines = 200
+
+// ProjectPreview is what redaction found in the sampled lines of one
+// project's files.
+type ProjectPreview struct {
+	Project   string           `json:"project"`
+	Files     int              `json:"files"`
+	Lines     int64            `json:"lines"`
+	Matches   int64            `json:"matches"`
+	ByPattern map[string]int64 `json:"byPattern"`
+}
+
+// RedactionPreview is the per-project result of PreviewRedaction.
+type RedactionPreview struct {
+	Projects     []ProjectPreview `json:"projects"` // Sorted by project
+	Total        ProjectPreview   `json:"total"`    // Project is empty
+	LinesPerFile int              `json:"linesPerFile"`
+}
+
+// PreviewRedaction runs the first lines lines of every log in each project
+// through redaction, as an upload would, and counts the matches per pattern.
+// Nothing is written. lines of 0 or less reads whole files.
+func PreviewRedaction(ctx context.Context, projects []types.Project, exts []string, lines int) (RedactionPreview, error) {
+	preview := RedactionPreview{Projects: []ProjectPreview{}, LinesPerFile: lines}
+	total := redactor.NewStats()
+	for _, p := range projects {
+		if p.LocalPath == "" {
+			continue
+		}
+		pp, stats, err := previewProject(ctx, p, exts, lines)
+		if err != nil {
+			return RedactionPreview{}, err
+		}
+		preview.Projects = append(preview.Projects, pp)
+		preview.Total.Files += pp.Files
+		total.Add(stats)
+	}
+	sort.Slice(preview.Projects, func(i, j int) bool { return preview.Projects[i].Project < preview.Projects[j].Project })
+	preview.Total.Lines = total.LinesProcessed
+	preview.Total.Matches = total.TotalMatches
+	preview.Total.ByPattern = total.ByPattern
+	return preview, nil
+}
+
+// previewProject samples each log under the project's directory.
+func previewProject(ctx context.Context, p types.Project, exts []string, lines int) (ProjectPreview, *redactor.Stats, error) {
+	stats := redactor.NewStats()
+	pp := ProjectPreview{Project: p.Name}
+	err := filepath.WalkDir(p.LocalPath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !config.HasLogExtension(d.Name(), exts) {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		fileStats, err := sampleFile(path, lines)
+		if err != nil {
+			return err
+		}
+		pp.Files++
+		stats.Add(fileStats)
+		return nil
+	})
+	if err != nil {
+		return pp, nil, fmt.Errorf("previewing project %s: %w", p.Name, err)
+	}
+	pp.Lines = stats.LinesProcessed
+	pp.Matches = stats.TotalMatches
+	pp.ByPattern = stats.ByPattern
+	return pp, stats, nil
+}
+
+// sampleFile redacts the first lines lines of the log at path and returns
+// the stats, discarding the output.
+func sampleFile(path string, lines int) (*redactor.Stats, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	var r io.Reader = f
+	if lines > 0 {
+		r = &headReader{r: f, left: lines}
+	}
+	reader, statsCh := streamRedact(path, r, nil)
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		return nil, fmt.Errorf("reading %s: %w", path, err)
+	}
+	return <-statsCh, nil
+}
+
+// headReader reads from r up to and including the left'th newline.
+type headReader struct {
+	r    io.Reader
+	left int
+}
+
+func (h *headReader) Read(p []byte) (int, error) {
+	if h.left <= 0 {
+		return 0, io.EOF
+	}
+	n, err := h.r.Read(p)
+	for i, b := range p[:n] {
+		if b == '\n' {
+			if h.left--; h.left == 0 {
+				return i + 1, nil
+			}
+		}
+	}
+	return n, err
+}
+
+// Print writes the preview as a table of projects with their most frequent
+// patterns, followed by the totals per pattern grouped by severity.
+func (p RedactionPreview) Print(w io.Writer) {
+	table := tablewriter.NewWriter(w)
+	table.Header("Project", "Files", "Lines", "Matches", "Patterns")
+	for _, pp := range p.Projects {
+		table.Append(pp.Project, strconv.Itoa(pp.Files), strconv.FormatInt(pp.Lines, 10),
+			strconv.FormatInt(pp.Matches, 10), patternList(pp.ByPattern))
+	}
+	table.Render()
+
+	sample := "all lines"
+	if p.LinesPerFile > 0 {
+		sample = fmt.Sprintf("up to %d lines", p.LinesPerFile)
+	}
+	fmt.Fprintf(w, "Total: %d matches in %d lines of %d files (%s per file)\n",
+		p.Total.Matches, p.Total.Lines, p.Total.Files, sample)
+	stats := &redactor.Stats{ByPattern: p.Total.ByPattern}
+	for _, pc := range stats.PatternSummary() {
+		fmt.Fprintf(w, "  %s: %d (%s)\n", pc.Pattern, pc.Count, pc.Severity)
+	}
+}
+
+// patternList formats pattern counts highest severity first, e.g.
+// "AWS_KEY 1, EMAIL 3".
+func patternList(byPattern map[string]int64) string {
+	stats := &redactor.Stats{ByPattern: byPattern}
+	var parts []string
+	for _, pc := range stats.PatternSummary() {
+		parts = append(parts, fmt.Sprintf("%s %d", pc.Pattern, pc.Count))
+	}
+	if len(parts) == 0 {
+		return "-"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,74 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/output"
+)
+
+// Prunable returns the local logs last modified before cutoff whose manifest
+// entry records their current size and modification time, so deleting them
+// loses nothing that is not already in the archive. Files pending upload,
+// files never uploaded under their own key, and files the manifest knows
+// only by an older version are never returned. Results are sorted by path.
+func (u *Uploader) Prunable(ctx context.Context, cutoff time.Time) ([]FileUpload, error) {
+	// A manifest that cannot be read proves nothing; unlike upload, don't
+	// fall back to an empty one
+	m, err := manifest.Load(ctx, u.client, u.cfg.S3.Bucket, manifest.ConfigKey(u.cfg), u.cfg.S3.OperationTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("loading manifest: %w", err)
+	}
+	files, err := u.discoverLocal(m)
+	if err != nil {
+		return nil, err
+	}
+
+	var prunable []FileUpload
+	for _, f := range files {
+		if !f.ModTime.Before(cutoff) || strings.HasPrefix(f.SkipReason, "duplicate of ") {
+			continue
+		}
+		entry, ok := m.Files[f.S3Key]
+		if !ok || entry.Size != f.Size || syncState(f, entry) != StateInSync {
+			continue
+		}
+		prunable = append(prunable, f)
+	}
+
+	sort.Slice(prunable, func(i, j int) bool { return prunable[i].LocalPath < prunable[j].LocalPath })
+	return prunable, nil
+}
+
+// Unchanged reports whether the file at f.LocalPath still has the size and
+// modification time it had when discovered. Callers check it right before
+// deleting a pruned file, in case a session resumed in the meantime.
+func Unchanged(f FileUpload) (bool, error) {
+	info, err := os.Stat(f.LocalPath)
+	if err != nil {
+		return false, err
+	}
+	return info.Size() == f.Size && info.ModTime().UTC().Truncate(time.Second).Equal(f.ModTime.Truncate(time.Second)), nil
+}
+
+// PruneTotal counts pruned files and the disk space they took up.
+type PruneTotal struct {
+	Files int
+	Bytes int64
+}
+
+// Add counts f.
+func (p *PruneTotal) Add(f FileUpload) {
+	p.Files++
+	p.Bytes += f.Size
+}
+
+// String describes the total for the prune summary.
+func (p PruneTotal) String() string {
+	return fmt.Sprintf("%s (%s)", plural(p.Files, "file"), output.FormatSize(p.Bytes))
+}
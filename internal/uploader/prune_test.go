@@ -0,0 +1,111 @@
+package uploader
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+func TestPrunable(t *testing.T) {
+	root := t.TempDir()
+	old := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	recent := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	files := []struct {
+		name  string
+		mtime time.Time
+	}{
+		{"app/uploaded.jsonl", old},
+		{"app/never.jsonl", old},
+		{"app/appended.jsonl", old},
+		{"app/touched.jsonl", old},
+		{"app/recent.jsonl", recent},
+		{"web/uploaded.jsonl", old},
+	}
+	for _, f := range files {
+		path := filepath.Join(root, filepath.FromSlash(f.name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("{}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, f.mtime, f.mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := manifest.New()
+	m.Files["claude-code/app/uploaded.jsonl"] = manifest.FileEntry{Mtime: old, Size: 3}
+	m.Files["claude-code/app/appended.jsonl"] = manifest.FileEntry{Mtime: old, Size: 2}
+	m.Files["claude-code/app/touched.jsonl"] = manifest.FileEntry{Mtime: old.Add(-time.Hour), Size: 3}
+	m.Files["claude-code/app/recent.jsonl"] = manifest.FileEntry{Mtime: recent, Size: 3}
+	m.Files["claude-code/web/uploaded.jsonl"] = manifest.FileEntry{Mtime: old, Size: 3}
+	data, err := json.Marshal(m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := newMockS3()
+	client.store("claude-code/.manifest.json", data)
+
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: root},
+		S3:    types.S3Config{Bucket: "bucket", Prefix: "claude-code/"},
+	}
+	got, err := newUploader(cfg, client, false, false).Prunable(context.Background(), recent.Add(-24*time.Hour))
+	if err != nil {
+		t.Fatalf("Prunable() error = %v", err)
+	}
+
+	want := []string{
+		filepath.Join(root, "app", "uploaded.jsonl"),
+		filepath.Join(root, "web", "uploaded.jsonl"),
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Prunable() returned %d files, want %v", len(got), want)
+	}
+	for i, f := range got {
+		if f.LocalPath != want[i] {
+			t.Errorf("file %d = %s, want %s", i, f.LocalPath, want[i])
+		}
+		if ok, err := Unchanged(f); err != nil || !ok {
+			t.Errorf("Unchanged(%s) = %v, %v, want true", f.LocalPath, ok, err)
+		}
+	}
+
+	// A session resumed after discovery must not be deleted
+	if err := os.WriteFile(got[0].LocalPath, []byte("{}\n{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := Unchanged(got[0]); ok {
+		t.Error("Unchanged() = true after the file grew")
+	}
+}
+
+func TestPrunableNoManifest(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "app", "s.jsonl")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: root},
+		S3:    types.S3Config{Bucket: "bucket", Prefix: "claude-code/"},
+	}
+	got, err := newUploader(cfg, newMockS3(), false, false).Prunable(context.Background(), time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Prunable() error = %v", err)
+	}
+	if len(got) != 0 {
+		t.Errorf("Prunable() without a manifest = %d files, want none", len(got))
+	}
+}
@@ -0,0 +1,137 @@
+package uploader
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/13rac1/cclogs/internal/manifest"
+)
+
+// ResumeDirName is the directory under the state directory holding resume
+// files.
+const ResumeDirName = "state"
+
+// resumeRecord is one line of a resume file: a file Upload finished and the
+// manifest entry it was going to save for it.
+type resumeRecord struct {
+	Key   string             `json:"key"`
+	Entry manifest.FileEntry `json:"entry"`
+}
+
+// resumeLog is the resume file of one manifest. Upload appends a record as
+// each file finishes and removes the file once the manifest is saved, so a
+// run killed before saving leaves behind what it finished and the next run
+// skips those files instead of uploading them again.
+type resumeLog struct {
+	path   string
+	f      *os.File
+	failed bool // A write failed; later records are not attempted
+}
+
+// resumePath returns the resume file for the manifest at bucket/key under
+// dir. Resume files mirror the bucket layout like cached manifests, e.g.
+// state/<bucket>/<prefix>/.manifest.json.resume.
+func resumePath(dir, bucket, key string) string {
+	return filepath.Join(dir, bucket, filepath.FromSlash(key)+".resume")
+}
+
+// readResume returns the entries recorded in the resume file at path, keyed
+// by S3 key. A missing file has none. Lines that don't parse, such as one cut
+// short by the crash that left the file behind, are ignored.
+func readResume(path string) (map[string]manifest.FileEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = f.Close() }()
+
+	done := make(map[string]manifest.FileEntry)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var rec resumeRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil || rec.Key == "" {
+			continue
+		}
+		done[rec.Key] = rec.Entry
+	}
+	return done, scanner.Err()
+}
+
+// add appends a record for the finished file at key. The file is created on
+// the first record.
+func (l *resumeLog) add(key string, entry manifest.FileEntry) error {
+	if l.failed {
+		return nil
+	}
+	data, err := json.Marshal(resumeRecord{Key: key, Entry: entry})
+	if err != nil {
+		return err
+	}
+	if l.f == nil {
+		if err := os.MkdirAll(filepath.Dir(l.path), 0o700); err != nil {
+			l.failed = true
+			return err
+		}
+		l.f, err = os.OpenFile(l.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			l.failed = true
+			return err
+		}
+	}
+	if _, err := l.f.Write(append(data, '\n')); err != nil {
+		l.failed = true
+		return err
+	}
+	return nil
+}
+
+// close closes the resume file, leaving it in place for the next run.
+func (l *resumeLog) close() {
+	if l.f != nil {
+		_ = l.f.Close()
+		l.f = nil
+	}
+}
+
+// remove closes and deletes the resume file.
+func (l *resumeLog) remove() error {
+	l.close()
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// applyResume marks the files an interrupted run finished as skipped and
+// adds their entries to m, returning the updated copy of files and how many
+// were resumed. A recorded file only counts as finished if it hasn't changed
+// since, by the same test DiscoverFiles applies to manifest entries.
+func applyResume(files []FileUpload, done map[string]manifest.FileEntry, m *manifest.Manifest) ([]FileUpload, int) {
+	if len(done) == 0 {
+		return files, 0
+	}
+	files = slices.Clone(files)
+	resumed := 0
+	for i := range files {
+		if files[i].ShouldSkip {
+			continue
+		}
+		entry, ok := done[files[i].S3Key]
+		if !ok || entry.Size != files[i].Size || syncState(files[i], entry) != StateInSync {
+			continue
+		}
+		files[i].ShouldSkip = true
+		files[i].SkipReason = "finished by interrupted run"
+		m.Files[files[i].S3Key] = entry
+		resumed++
+	}
+	return files, resumed
+}
@@ -0,0 +1,204 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+func TestReadResume(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "bucket", ".manifest.json.resume")
+
+	done, err := readResume(path)
+	if err != nil || done != nil {
+		t.Fatalf("readResume(missing) = %v, %v; want nil, nil", done, err)
+	}
+
+	l := &resumeLog{path: path}
+	mtime := time.Date(2025, 3, 8, 12, 0, 0, 0, time.UTC)
+	if err := l.add("p/a.jsonl", manifest.FileEntry{Size: 3, Mtime: mtime}); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.add("p/b.jsonl", manifest.FileEntry{Size: 5, Mtime: mtime}); err != nil {
+		t.Fatal(err)
+	}
+	l.close()
+
+	// A crash mid-write leaves a partial last line
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.WriteString(`{"key":"p/c.jsonl","entry":{"si`); err != nil {
+		t.Fatal(err)
+	}
+	_ = f.Close()
+
+	done, err = readResume(path)
+	if err != nil {
+		t.Fatalf("readResume: %v", err)
+	}
+	if len(done) != 2 || done["p/a.jsonl"].Size != 3 || done["p/b.jsonl"].Size != 5 {
+		t.Errorf("readResume = %+v, want entries for a and b", done)
+	}
+
+	if err := l.remove(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("resume file still exists after remove: %v", err)
+	}
+	if err := l.remove(); err != nil {
+		t.Errorf("removing a missing resume file: %v", err)
+	}
+}
+
+func TestApplyResume(t *testing.T) {
+	mtime := time.Date(2025, 3, 8, 12, 0, 0, 0, time.UTC)
+	files := []FileUpload{
+		{S3Key: "p/done.jsonl", Size: 3, ModTime: mtime},
+		{S3Key: "p/grown.jsonl", Size: 9, ModTime: mtime.Add(time.Minute)},
+		{S3Key: "p/new.jsonl", Size: 4, ModTime: mtime},
+		{S3Key: "p/skipped.jsonl", Size: 3, ModTime: mtime, ShouldSkip: true, SkipReason: "unchanged"},
+	}
+	done := map[string]manifest.FileEntry{
+		"p/done.jsonl":    {Size: 3, Mtime: mtime},
+		"p/grown.jsonl":   {Size: 3, Mtime: mtime},
+		"p/skipped.jsonl": {Size: 3, Mtime: mtime},
+	}
+	m := manifest.New()
+
+	got, resumed := applyResume(files, done, m)
+	if resumed != 1 {
+		t.Errorf("resumed = %d, want 1", resumed)
+	}
+	if !got[0].ShouldSkip || got[0].SkipReason != "finished by interrupted run" {
+		t.Errorf("finished file = %+v, want skipped as resumed", got[0])
+	}
+	if got[1].ShouldSkip || got[2].ShouldSkip {
+		t.Errorf("changed or unrecorded files were skipped: %+v, %+v", got[1], got[2])
+	}
+	if got[3].SkipReason != "unchanged" {
+		t.Errorf("already skipped file = %+v, want its reason kept", got[3])
+	}
+	if files[0].ShouldSkip {
+		t.Error("applyResume modified the caller's slice")
+	}
+	if _, ok := m.Files["p/done.jsonl"]; !ok || len(m.Files) != 1 {
+		t.Errorf("manifest files = %v, want only p/done.jsonl", m.Files)
+	}
+}
+
+func TestUpload_Resume(t *testing.T) {
+	tests := []struct {
+		name     string
+		noResume bool
+		wantPuts int // Uploads of a.jsonl across both runs
+	}{
+		{name: "skips files the interrupted run finished", wantPuts: 1},
+		{name: "no-resume uploads them again", noResume: true, wantPuts: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			projectDir := filepath.Join(tmpDir, "project")
+			if err := os.MkdirAll(projectDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"a.jsonl", "b.jsonl"} {
+				if err := os.WriteFile(filepath.Join(projectDir, name), []byte("{\"n\":1}\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			stateDir := filepath.Join(tmpDir, ResumeDirName)
+			resumeFile := resumePath(stateDir, "test-bucket", "claude-code/.manifest.json")
+
+			cfg := &types.Config{
+				Local: types.LocalConfig{ProjectsRoot: tmpDir},
+				S3:    types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+			}
+			client := newMockS3()
+
+			// The first run is killed after a.jsonl: b.jsonl never finishes and
+			// the manifest is never saved
+			client.failPut = func(key string) bool {
+				return strings.HasSuffix(key, "/b.jsonl") || strings.HasSuffix(key, ".manifest.json")
+			}
+			u := newUploader(cfg, client, true, false)
+			u.SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+			u.SetResumeDir(stateDir)
+			files, err := u.DiscoverFiles(context.Background())
+			if err != nil {
+				t.Fatalf("DiscoverFiles failed: %v", err)
+			}
+			if err := SortFiles(files, OrderName); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := u.Upload(context.Background(), files); !errors.Is(err, ErrPartialFailure) {
+				t.Fatalf("first Upload error = %v, want ErrPartialFailure", err)
+			}
+			if _, err := os.Stat(resumeFile); err != nil {
+				t.Fatalf("resume file not kept after the manifest save failed: %v", err)
+			}
+
+			// The next run
+			client.failPut = nil
+			u = newUploader(cfg, client, true, false)
+			var out bytes.Buffer
+			u.SetOutput(&out, &bytes.Buffer{})
+			u.SetResumeDir(stateDir)
+			u.SetNoResume(tt.noResume)
+			files, err = u.DiscoverFiles(context.Background())
+			if err != nil {
+				t.Fatalf("DiscoverFiles failed: %v", err)
+			}
+			if err := SortFiles(files, OrderName); err != nil {
+				t.Fatal(err)
+			}
+			result, err := u.Upload(context.Background(), files)
+			if err != nil {
+				t.Fatalf("second Upload: %v", err)
+			}
+
+			puts := 0
+			for _, key := range client.putKeys() {
+				if key == "claude-code/project/a.jsonl" {
+					puts++
+				}
+			}
+			if puts != tt.wantPuts {
+				t.Errorf("a.jsonl uploaded %d times, want %d", puts, tt.wantPuts)
+			}
+			if !tt.noResume {
+				if result.Uploaded != 1 || result.Skipped != 1 {
+					t.Errorf("result = %d uploaded, %d skipped; want 1, 1", result.Uploaded, result.Skipped)
+				}
+				if !strings.Contains(out.String(), "finished by interrupted run") {
+					t.Errorf("output does not report the resumed file:\n%s", out.String())
+				}
+			}
+
+			m, err := manifest.Load(context.Background(), client, "test-bucket", "claude-code/.manifest.json", 0)
+			if err != nil {
+				t.Fatalf("loading saved manifest: %v", err)
+			}
+			for _, key := range []string{"claude-code/project/a.jsonl", "claude-code/project/b.jsonl"} {
+				if _, ok := m.Files[key]; !ok {
+					t.Errorf("manifest missing %s", key)
+				}
+			}
+			if _, err := os.Stat(resumeFile); !os.IsNotExist(err) {
+				t.Errorf("resume file not removed after a clean run: %v", err)
+			}
+		})
+	}
+}
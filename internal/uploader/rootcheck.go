@@ -0,0 +1,30 @@
+package uploader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrProjectsRootUnavailable is returned by Upload when the projects root
+// disappeared mid-run, e.g. because the volume holding it was unmounted.
+var ErrProjectsRootUnavailable = errors.New("projects root unavailable")
+
+// rootCheckAfter is the number of consecutive file failures after which the
+// projects root itself is checked.
+const rootCheckAfter = 3
+
+// rootUnavailable reports why root can no longer be used, or "" if it still
+// looks like the directory discovery walked. An empty directory is what an
+// unmounted mountpoint looks like, since discovery found files in it.
+func rootUnavailable(root string) string {
+	entries, err := os.ReadDir(root)
+	switch {
+	case err != nil:
+		return fmt.Sprintf("cannot read %s: %v", root, err)
+	case len(entries) == 0:
+		return fmt.Sprintf("%s is empty; is the volume holding it mounted?", root)
+	default:
+		return ""
+	}
+}
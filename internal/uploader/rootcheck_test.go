@@ -0,0 +1,86 @@
+package uploader
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+func TestUpload_ProjectsRootDisappears(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "projects")
+	for i := range 6 {
+		path := filepath.Join(root, "project", fmt.Sprintf("s%d.jsonl", i))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("{}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client, fake := newFakeS3(t)
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: root},
+		S3:    types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+	}
+	u := New(cfg, client, false, false)
+	u.SetOutput(nil, nil)
+
+	files, err := u.DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Unmount the volume once the second file is stored; that file then
+	// counts as changed during upload and is left out of the manifest
+	puts := 0
+	fake.onPut = func(key string) {
+		if puts++; puts == 2 {
+			_ = os.RemoveAll(root)
+		}
+	}
+
+	result, err := u.Upload(context.Background(), files)
+	if !errors.Is(err, ErrProjectsRootUnavailable) {
+		t.Fatalf("Upload() error = %v, want ErrProjectsRootUnavailable", err)
+	}
+	if result.Uploaded != 2 || len(result.Failures) != rootCheckAfter {
+		t.Errorf("uploaded %d, failed %d; want 2 and %d (remaining files not attempted)", result.Uploaded, len(result.Failures), rootCheckAfter)
+	}
+
+	data, ok := fake.object("claude-code/.manifest.json")
+	if !ok {
+		t.Fatal("manifest not saved for the files that completed")
+	}
+	var m manifest.Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Files) != 1 {
+		t.Errorf("manifest has %d entries, want only the first file", len(m.Files))
+	}
+}
+
+func TestRootUnavailable(t *testing.T) {
+	dir := t.TempDir()
+	if reason := rootUnavailable(filepath.Join(dir, "missing")); reason == "" {
+		t.Error("missing root reported as available")
+	}
+	if reason := rootUnavailable(dir); !strings.Contains(reason, "mounted") {
+		t.Errorf("empty root reason = %q, want mount hint", reason)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "project"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if reason := rootUnavailable(dir); reason != "" {
+		t.Errorf("populated root reported unavailable: %s", reason)
+	}
+}
@@ -0,0 +1,131 @@
+package uploader
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/13rac1/cclogs/internal/config"
+)
+
+// Spool modes for upload.spool.
+const (
+	spoolAuto   = "auto"
+	spoolMemory = "memory"
+	spoolDisk   = "disk"
+)
+
+// spooled holds fully buffered upload content so it can be sent with an
+// exact Content-Length. Content lives in memory, or in a temp file once it
+// outgrows the memory limit.
+type spooled struct {
+	buf  *bytes.Buffer
+	file *os.File
+	size int64
+}
+
+// spool reads r to EOF. Up to memLimit bytes are kept in memory before
+// spilling to a temp file; a negative memLimit never spills and zero always
+// uses a temp file.
+func spool(r io.Reader, memLimit int64) (*spooled, error) {
+	s := &spooled{buf: &bytes.Buffer{}}
+
+	if memLimit != 0 {
+		limit := memLimit
+		if limit < 0 {
+			n, err := io.Copy(s.buf, r)
+			s.size = n
+			if err != nil {
+				return nil, fmt.Errorf("buffering content: %w", err)
+			}
+			return s, nil
+		}
+
+		// Read one byte past the limit to learn whether the content fits
+		n, err := io.CopyN(s.buf, r, limit+1)
+		s.size = n
+		if err == io.EOF {
+			return s, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("buffering content: %w", err)
+		}
+	}
+
+	f, err := os.CreateTemp("", "cclogs-spool-*")
+	if err != nil {
+		return nil, fmt.Errorf("creating spool file: %w", err)
+	}
+	s.file = f
+
+	if _, err := s.buf.WriteTo(f); err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("writing spool file: %w", err)
+	}
+	n, err := io.Copy(f, r)
+	s.size += n
+	if err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("writing spool file: %w", err)
+	}
+
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		_ = s.Close()
+		return nil, fmt.Errorf("rewinding spool file: %w", err)
+	}
+
+	return s, nil
+}
+
+// reader returns a seekable reader over the spooled content.
+func (s *spooled) reader() io.ReadSeeker {
+	if s.file != nil {
+		return s.file
+	}
+	return bytes.NewReader(s.buf.Bytes())
+}
+
+// onDisk reports whether the content was spilled to a temp file.
+func (s *spooled) onDisk() bool {
+	return s.file != nil
+}
+
+// Close releases the buffer and removes the temp file, if any.
+func (s *spooled) Close() error {
+	s.buf = nil
+	if s.file == nil {
+		return nil
+	}
+	name := s.file.Name()
+	closeErr := s.file.Close()
+	s.file = nil
+	if err := os.Remove(name); err != nil {
+		return err
+	}
+	return closeErr
+}
+
+// spoolThreshold returns the file size below which content is spooled and
+// sent with a single PutObject. It defaults to the multipart part size.
+func (u *Uploader) spoolThreshold(partSize int64) int64 {
+	if u.cfg.Upload.SpoolThreshold > 0 {
+		return int64(u.cfg.Upload.SpoolThreshold)
+	}
+	return partSize
+}
+
+// spoolMemoryLimit returns the memLimit argument for spool based on upload.spool.
+func (u *Uploader) spoolMemoryLimit() int64 {
+	switch u.cfg.Upload.Spool {
+	case spoolMemory:
+		return -1
+	case spoolDisk:
+		return 0
+	default:
+		if u.cfg.Upload.SpoolMemory > 0 {
+			return int64(u.cfg.Upload.SpoolMemory)
+		}
+		return config.DefaultSpoolMemory
+	}
+}
@@ -0,0 +1,132 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+func TestSpool(t *testing.T) {
+	content := []byte(strings.Repeat("abcdefgh", 128)) // 1KiB
+
+	tests := []struct {
+		name       string
+		memLimit   int64
+		wantOnDisk bool
+	}{
+		{name: "fits in memory", memLimit: 4096, wantOnDisk: false},
+		{name: "exactly at limit", memLimit: int64(len(content)), wantOnDisk: false},
+		{name: "spills to disk", memLimit: 100, wantOnDisk: true},
+		{name: "memory only", memLimit: -1, wantOnDisk: false},
+		{name: "disk only", memLimit: 0, wantOnDisk: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sp, err := spool(bytes.NewReader(content), tt.memLimit)
+			if err != nil {
+				t.Fatalf("spool failed: %v", err)
+			}
+
+			if sp.onDisk() != tt.wantOnDisk {
+				t.Errorf("onDisk() = %v, want %v", sp.onDisk(), tt.wantOnDisk)
+			}
+			if sp.size != int64(len(content)) {
+				t.Errorf("size = %d, want %d", sp.size, len(content))
+			}
+
+			got, err := io.ReadAll(sp.reader())
+			if err != nil {
+				t.Fatalf("reading spooled content: %v", err)
+			}
+			if !bytes.Equal(got, content) {
+				t.Error("spooled content differs from input")
+			}
+
+			var path string
+			if sp.file != nil {
+				path = sp.file.Name()
+			}
+			if err := sp.Close(); err != nil {
+				t.Fatalf("Close failed: %v", err)
+			}
+			if path != "" {
+				if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+					t.Errorf("spool file %s not removed", path)
+				}
+			}
+		})
+	}
+}
+
+func TestUploadFileSpoolModes(t *testing.T) {
+	const partSize = 5 << 20
+	content := []byte(strings.Repeat(`{"n":1}`+"\n", 1000))
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+	sum := sha256.Sum256(content)
+	wantChecksum := base64.StdEncoding.EncodeToString(sum[:])
+
+	tests := []struct {
+		name        string
+		upload      types.UploadConfig
+		wantSpooled bool
+	}{
+		{name: "auto", upload: types.UploadConfig{Spool: "auto"}, wantSpooled: true},
+		{name: "memory", upload: types.UploadConfig{Spool: "memory"}, wantSpooled: true},
+		{name: "disk", upload: types.UploadConfig{Spool: "disk"}, wantSpooled: true},
+		{
+			// The manager streams content smaller than a part as one PutObject
+			name:        "above threshold streams through the manager",
+			upload:      types.UploadConfig{Spool: "auto", SpoolThreshold: 1024},
+			wantSpooled: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.upload.PartSize = partSize
+			tt.upload.PartConcurrency = 1
+			cfg := &types.Config{S3: types.S3Config{Bucket: "test-bucket"}, Upload: tt.upload}
+			u := New(cfg, nil, true, false)
+			client := &recordingUploadClient{}
+			mu := u.newMultipartUploader(client)
+
+			file := FileUpload{LocalPath: path, S3Key: "p/session.jsonl", Size: int64(len(content))}
+			if _, _, err := u.uploadFile(context.Background(), client, mu, file, nil); err != nil {
+				t.Fatalf("uploadFile failed: %v", err)
+			}
+
+			if got := strings.Join(client.calls, ","); got != "PutObject" {
+				t.Fatalf("calls = %s, want PutObject", got)
+			}
+			if !bytes.Equal(client.body, content) {
+				t.Error("uploaded body differs from file content")
+			}
+			if !tt.wantSpooled {
+				if client.put.ChecksumSHA256 != nil {
+					t.Error("streamed upload unexpectedly carries a precomputed checksum")
+				}
+				return
+			}
+
+			if got := *client.put.ContentLength; got != int64(len(content)) {
+				t.Errorf("ContentLength = %d, want %d", got, len(content))
+			}
+			if got := *client.put.ChecksumSHA256; got != wantChecksum {
+				t.Errorf("ChecksumSHA256 = %s, want %s", got, wantChecksum)
+			}
+		})
+	}
+}
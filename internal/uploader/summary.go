@@ -0,0 +1,92 @@
+package uploader
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/output"
+)
+
+// DeepVerifyWindow is how recent a deep verification must be to count
+// toward Summary.DeepVerified.
+const DeepVerifyWindow = 90 * 24 * time.Hour
+
+// Summary is the sync state of this machine in a form small enough for shell
+// prompts and cron jobs.
+type Summary struct {
+	Projects     int            `json:"projects"`
+	PendingFiles int            `json:"pendingFiles"`
+	PendingBytes int64          `json:"pendingBytes"`
+	LastUpload   *time.Time     `json:"lastUpload"`   // Nil if no upload recorded it in the manifest
+	Archive      manifest.Usage `json:"archive"`      // Totals of the objects in the manifest
+	DeepVerified int            `json:"deepVerified"` // Archive files verify --deep matched within DeepVerifyWindow
+}
+
+// Summarize discovers local files and counts those an upload would send.
+// Unlike an upload, it fails when the manifest can't be loaded rather than
+// reporting every file as pending.
+func (u *Uploader) Summarize(ctx context.Context) (Summary, error) {
+	u.strictManifest = true
+	defer func() { u.strictManifest = false }()
+	files, err := u.DiscoverFiles(ctx)
+	if err != nil {
+		return Summary{}, err
+	}
+
+	var s Summary
+	projects := make(map[string]bool)
+	for _, f := range files {
+		projects[f.ProjectDir] = true
+		if !f.ShouldSkip {
+			s.PendingFiles++
+			s.PendingBytes += f.Size
+		}
+	}
+	s.Projects = len(projects)
+	s.Archive = u.archive
+	s.DeepVerified = u.verified
+	if !u.lastUpload.IsZero() {
+		t := u.lastUpload
+		s.LastUpload = &t
+	}
+	return s, nil
+}
+
+// String formats the summary as a single line.
+func (s Summary) String() string {
+	last := "no upload recorded"
+	if s.LastUpload != nil {
+		last = "last upload " + s.LastUpload.UTC().Format("2006-01-02T15:04Z")
+	}
+	archive := output.FormatSize(s.Archive.Bytes)
+	if s.Archive.Approximate {
+		archive = "~" + archive
+	}
+	line := fmt.Sprintf("%s, %s pending upload (%s), %s, archive %s in %s",
+		plural(s.Projects, "project"), plural(s.PendingFiles, "file"), output.FormatSize(s.PendingBytes),
+		last, archive, plural(s.Archive.Files, "file"))
+	if s.DeepVerified > 0 {
+		line += fmt.Sprintf(" (%s deep-verified in the last %d days)",
+			CoveragePercent(s.DeepVerified, s.Archive.Files), int(DeepVerifyWindow.Hours()/24))
+	}
+	return line
+}
+
+// CoveragePercent formats verified out of total as a whole percentage,
+// rounded down so partial coverage never shows as 100%.
+func CoveragePercent(verified, total int) string {
+	if total == 0 {
+		return "0%"
+	}
+	return fmt.Sprintf("%d%%", verified*100/total)
+}
+
+// plural formats n with word, adding "s" unless n is 1.
+func plural(n int, word string) string {
+	if n == 1 {
+		return "1 " + word
+	}
+	return fmt.Sprintf("%d %ss", n, word)
+}
@@ -0,0 +1,140 @@
+package uploader
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/types"
+)
+
+func TestSummarize(t *testing.T) {
+	root := t.TempDir()
+	mtime := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	for _, name := range []string{"app/synced.jsonl", "app/new.jsonl", "web/s1.jsonl"} {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("{}\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: root},
+		S3:    types.S3Config{Bucket: "bucket", Prefix: "claude-code/"},
+	}
+
+	lastUpload := time.Date(2025, 6, 1, 9, 13, 42, 0, time.UTC)
+	tests := []struct {
+		name       string
+		lastUpload time.Time
+		verifiedAt time.Time
+		want       string
+	}{
+		{"recorded", lastUpload, time.Time{}, "2 projects, 2 files pending upload (6 B), last upload 2025-06-01T09:13Z, archive 2 B in 1 file"},
+		{"older manifest", time.Time{}, time.Time{}, "2 projects, 2 files pending upload (6 B), no upload recorded, archive ~3 B in 1 file"},
+		{"deep-verified", lastUpload, time.Now().AddDate(0, 0, -1), "2 projects, 2 files pending upload (6 B), last upload 2025-06-01T09:13Z, archive 2 B in 1 file (100% deep-verified in the last 90 days)"},
+		{"verified long ago", lastUpload, time.Now().AddDate(0, 0, -91), "2 projects, 2 files pending upload (6 B), last upload 2025-06-01T09:13Z, archive 2 B in 1 file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := manifest.New()
+			m.LastUpload = tt.lastUpload
+			entry := manifest.FileEntry{Mtime: mtime, Size: 3, VerifiedAt: tt.verifiedAt}
+			if !tt.lastUpload.IsZero() {
+				entry.UploadedSize = 2
+			}
+			m.Files["claude-code/app/synced.jsonl"] = entry
+			data, err := json.Marshal(m)
+			if err != nil {
+				t.Fatal(err)
+			}
+			client := newMockS3()
+			client.store("claude-code/.manifest.json", data)
+
+			got, err := newUploader(cfg, client, false, false).Summarize(context.Background())
+			if err != nil {
+				t.Fatalf("Summarize() error = %v", err)
+			}
+			if got.String() != tt.want {
+				t.Errorf("Summarize() = %q, want %q", got.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestSummarizeManifestError(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "app"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "app", "s1.jsonl"), []byte("{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: root},
+		S3:    types.S3Config{Bucket: "bucket", Prefix: "claude-code/"},
+	}
+	client := newMockS3()
+	client.store("claude-code/.manifest.json", []byte("not json"))
+
+	u := newUploader(cfg, client, false, false)
+	var errOut bytes.Buffer
+	u.SetOutput(io.Discard, &errOut)
+	if _, err := u.Summarize(context.Background()); err == nil || !strings.Contains(err.Error(), "loading manifest") {
+		t.Errorf("Summarize() error = %v, want a manifest load error", err)
+	}
+	if errOut.Len() > 0 {
+		t.Errorf("unexpected warning: %s", errOut.String())
+	}
+}
+
+func TestUploadRecordsLastUpload(t *testing.T) {
+	root := t.TempDir()
+	path := filepath.Join(root, "app", "s1.jsonl")
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("{}\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: root},
+		S3:    types.S3Config{Bucket: "bucket", Prefix: "claude-code/"},
+	}
+	client := newMockS3()
+	u := newUploader(cfg, client, false, false)
+	u.SetOutput(io.Discard, io.Discard)
+
+	files, err := u.DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now().UTC().Add(-time.Second)
+	if _, err := u.Upload(context.Background(), files); err != nil {
+		t.Fatalf("Upload() error = %v", err)
+	}
+
+	s, err := u.Summarize(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.PendingFiles != 0 {
+		t.Errorf("PendingFiles = %d, want 0", s.PendingFiles)
+	}
+	if s.LastUpload == nil || s.LastUpload.Before(before) {
+		t.Errorf("LastUpload = %v, want after %v", s.LastUpload, before)
+	}
+}
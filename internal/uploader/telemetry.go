@@ -0,0 +1,133 @@
+package uploader
+
+import (
+	"context"
+
+	"github.com/13rac1/cclogs/internal/redactor"
+	"github.com/13rac1/cclogs/internal/telemetry"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// runTrace records an upload run as a span per project, each holding a span
+// per file, and counts files, bytes, and redactions. Files of a project are
+// discovered together, so a project's span ends when the next one starts.
+type runTrace struct {
+	ctx context.Context // Run context, parent of the project spans
+
+	project     string
+	projectCtx  context.Context
+	projectSpan trace.Span
+	totals      projectTotals
+
+	uploadedFiles metric.Int64Counter
+	skippedFiles  metric.Int64Counter
+	failedFiles   metric.Int64Counter
+	uploadedBytes metric.Int64Counter
+	redactions    metric.Int64Counter
+}
+
+// projectTotals are the attributes of a project span.
+type projectTotals struct {
+	files, uploaded, skipped, failed int
+	bytes                            int64
+}
+
+func newRunTrace(ctx context.Context) *runTrace {
+	return &runTrace{
+		ctx:           ctx,
+		uploadedFiles: counter("cclogs.files.uploaded", "Files uploaded", "{file}"),
+		skippedFiles:  counter("cclogs.files.skipped", "Files skipped", "{file}"),
+		failedFiles:   counter("cclogs.files.failed", "Files that failed to upload", "{file}"),
+		uploadedBytes: counter("cclogs.bytes.uploaded", "Source bytes of uploaded files", "By"),
+		redactions:    counter("cclogs.redactions", "Redacted matches in uploaded files", "{match}"),
+	}
+}
+
+// counter returns the named counter, or a no-op one if it cannot be created.
+func counter(name, description, unit string) metric.Int64Counter {
+	c, err := telemetry.Meter().Int64Counter(name, metric.WithDescription(description), metric.WithUnit(unit))
+	if err != nil {
+		return noop.Int64Counter{}
+	}
+	return c
+}
+
+// startFile starts the span of uploading file, under its project's span, and
+// returns ctx carrying it.
+func (t *runTrace) startFile(ctx context.Context, file FileUpload) (context.Context, trace.Span) {
+	t.enterProject(file.ProjectDir)
+	t.totals.files++
+	_, span := telemetry.Tracer().Start(t.projectCtx, "file", trace.WithAttributes(
+		attribute.String("file.key", file.S3Key),
+		attribute.Int64("file.bytes", file.Size),
+	))
+	return trace.ContextWithSpan(ctx, span), span
+}
+
+// skipped records file as skipped for reason.
+func (t *runTrace) skipped(file FileUpload, reason string) {
+	_, span := t.startFile(t.ctx, file)
+	span.SetAttributes(attribute.String("file.skip_reason", reason))
+	span.End()
+	t.totals.skipped++
+	t.skippedFiles.Add(t.ctx, 1)
+}
+
+// failed ends the span of a file that failed to upload.
+func (t *runTrace) failed(span trace.Span, err error) {
+	telemetry.EndSpan(span, err)
+	t.totals.failed++
+	t.failedFiles.Add(t.ctx, 1)
+}
+
+// uploaded ends the span of a file stored as storedBytes.
+func (t *runTrace) uploaded(span trace.Span, file FileUpload, storedBytes int64, stats *redactor.Stats, torn bool) {
+	var matches int64
+	if stats != nil {
+		matches = stats.TotalMatches
+	}
+	span.SetAttributes(
+		attribute.Int64("file.uploaded_bytes", storedBytes),
+		attribute.Int64("redaction.matches", matches),
+		attribute.Bool("file.modified_during_upload", torn),
+	)
+	span.End()
+
+	t.totals.uploaded++
+	t.totals.bytes += file.Size
+	t.uploadedFiles.Add(t.ctx, 1)
+	t.uploadedBytes.Add(t.ctx, file.Size)
+	t.redactions.Add(t.ctx, matches)
+}
+
+// enterProject makes project the current project, ending the previous one's
+// span.
+func (t *runTrace) enterProject(project string) {
+	if t.projectSpan != nil && project == t.project {
+		return
+	}
+	t.end()
+	t.project = project
+	t.projectCtx, t.projectSpan = telemetry.Tracer().Start(t.ctx, "project",
+		trace.WithAttributes(attribute.String("project", project)))
+}
+
+// end ends the current project's span, if any.
+func (t *runTrace) end() {
+	if t.projectSpan == nil {
+		return
+	}
+	t.projectSpan.SetAttributes(
+		attribute.Int("project.files", t.totals.files),
+		attribute.Int("project.uploaded", t.totals.uploaded),
+		attribute.Int("project.skipped", t.totals.skipped),
+		attribute.Int("project.failed", t.totals.failed),
+		attribute.Int64("project.uploaded_bytes", t.totals.bytes),
+	)
+	t.projectSpan.End()
+	t.projectSpan = nil
+	t.totals = projectTotals{}
+}
@@ -0,0 +1,133 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/13rac1/cclogs/internal/types"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestUploadSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(tp)
+	defer otel.SetTracerProvider(noop.NewTracerProvider())
+
+	tmpDir := t.TempDir()
+	files := map[string]string{
+		"app/a.jsonl": `{"text":"mail alice@company.com"}` + "\n",
+		"app/b.jsonl": `{"n":1}` + "\n",
+		"lib/c.jsonl": `{"n":2}` + "\n",
+		"lib/d.jsonl": `{"n":3}` + "\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := newMockS3()
+	client.failPut = func(key string) bool { return strings.HasSuffix(key, "/b.jsonl") }
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: tmpDir},
+		S3:    types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+	}
+	u := newUploader(cfg, client, false, false)
+
+	ctx, run := tp.Tracer("test").Start(context.Background(), "run")
+	uploads, err := u.DiscoverFiles(ctx)
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+	if err := SortFiles(uploads, OrderName); err != nil {
+		t.Fatal(err)
+	}
+	for i := range uploads {
+		if strings.HasSuffix(uploads[i].S3Key, "/d.jsonl") {
+			uploads[i].ShouldSkip, uploads[i].SkipReason = true, "unchanged"
+		}
+	}
+	if _, err := u.Upload(ctx, uploads); !errors.Is(err, ErrPartialFailure) {
+		t.Fatalf("Upload error = %v, want ErrPartialFailure", err)
+	}
+	run.End()
+
+	spans := recorder.Ended()
+	byName := make(map[string][]sdktrace.ReadOnlySpan)
+	for _, s := range spans {
+		byName[s.Name()] = append(byName[s.Name()], s)
+	}
+	if len(byName["discover"]) != 1 || len(byName["manifest.save"]) != 1 || len(byName["manifest.load"]) != 2 {
+		t.Errorf("spans = %v, want discover, two manifest loads, and a manifest save", names(spans))
+	}
+
+	projects := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range byName["project"] {
+		if s.Parent().SpanID() != run.SpanContext().SpanID() {
+			t.Errorf("project span %v is not a child of the run", attrs(s)["project"])
+		}
+		projects[attrs(s)["project"].AsString()] = s
+	}
+	if got := attrs(projects["app"]); len(projects) != 2 || got["project.uploaded"].AsInt64() != 1 || got["project.failed"].AsInt64() != 1 {
+		t.Errorf("app project span = %v", got)
+	}
+	if got := attrs(projects["lib"]); got["project.files"].AsInt64() != 2 || got["project.skipped"].AsInt64() != 1 {
+		t.Errorf("lib project span = %v", got)
+	}
+
+	fileSpans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, s := range byName["file"] {
+		fileSpans[attrs(s)["file.key"].AsString()] = s
+	}
+	if len(fileSpans) != 4 {
+		t.Fatalf("file spans = %v, want 4", fileSpans)
+	}
+
+	a := fileSpans["claude-code/app/a.jsonl"]
+	if a.Parent().SpanID() != projects["app"].SpanContext().SpanID() {
+		t.Error("file span is not a child of its project's span")
+	}
+	if got := attrs(a); got["file.bytes"].AsInt64() != int64(len(files["app/a.jsonl"])) ||
+		got["redaction.matches"].AsInt64() != 1 || got["file.uploaded_bytes"].AsInt64() == 0 {
+		t.Errorf("uploaded file span = %v", got)
+	}
+	if b := fileSpans["claude-code/app/b.jsonl"]; b.Status().Code != codes.Error || len(b.Events()) == 0 {
+		t.Errorf("failed file span status = %v, events %v", b.Status(), b.Events())
+	}
+	if got := attrs(fileSpans["claude-code/lib/d.jsonl"]); got["file.skip_reason"].AsString() != "unchanged" {
+		t.Errorf("skipped file span = %v", got)
+	}
+}
+
+func names(spans []sdktrace.ReadOnlySpan) []string {
+	var out []string
+	for _, s := range spans {
+		out = append(out, s.Name())
+	}
+	return out
+}
+
+func attrs(s sdktrace.ReadOnlySpan) map[string]attribute.Value {
+	out := make(map[string]attribute.Value)
+	if s == nil {
+		return out
+	}
+	for _, kv := range s.Attributes() {
+		out[string(kv.Key)] = kv.Value
+	}
+	return out
+}
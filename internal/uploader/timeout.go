@@ -0,0 +1,92 @@
+package uploader
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/s3errors"
+	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// timeoutClient wraps an upload client so every API call the multipart
+// uploader makes (each part, not the whole file) gets its own deadline, and
+// its errors match s3errors.ErrAccessDenied when permission was refused.
+type timeoutClient struct {
+	client  manager.UploadAPIClient
+	timeout time.Duration
+}
+
+// PutObject implements manager.UploadAPIClient.
+func (c *timeoutClient) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	ctx, cancel := config.WithOperationTimeout(ctx, c.timeout)
+	defer cancel()
+	out, err := c.client.PutObject(ctx, params, optFns...)
+	return out, s3errors.Wrap(err)
+}
+
+// UploadPart implements manager.UploadAPIClient.
+func (c *timeoutClient) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	ctx, cancel := config.WithOperationTimeout(ctx, c.timeout)
+	defer cancel()
+	out, err := c.client.UploadPart(ctx, params, optFns...)
+	return out, s3errors.Wrap(err)
+}
+
+// CreateMultipartUpload implements manager.UploadAPIClient.
+func (c *timeoutClient) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	ctx, cancel := config.WithOperationTimeout(ctx, c.timeout)
+	defer cancel()
+	out, err := c.client.CreateMultipartUpload(ctx, params, optFns...)
+	return out, s3errors.Wrap(err)
+}
+
+// CompleteMultipartUpload implements manager.UploadAPIClient.
+func (c *timeoutClient) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	ctx, cancel := config.WithOperationTimeout(ctx, c.timeout)
+	defer cancel()
+	out, err := c.client.CompleteMultipartUpload(ctx, params, optFns...)
+	return out, s3errors.Wrap(err)
+}
+
+// AbortMultipartUpload implements manager.UploadAPIClient. The manager aborts
+// with the upload's own context, which is already cancelled when the upload
+// was interrupted; detaching keeps the abort from failing the same way and
+// leaving billed parts behind.
+func (c *timeoutClient) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	ctx, cancel := config.WithOperationTimeout(context.WithoutCancel(ctx), c.timeout)
+	defer cancel()
+	out, err := c.client.AbortMultipartUpload(ctx, params, optFns...)
+	return out, s3errors.Wrap(err)
+}
+
+// uploadGracePeriod is how long an in-progress file upload may continue
+// after the run is cancelled before it is aborted.
+const uploadGracePeriod = 10 * time.Second
+
+// withGracePeriod returns a context that is not cancelled when parent is,
+// but only grace later. This lets a nearly-finished upload complete after
+// Ctrl+C instead of discarding the work.
+func withGracePeriod(parent context.Context, grace time.Duration) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.WithoutCancel(parent))
+
+	var timer *time.Timer
+	var mu sync.Mutex
+	stop := context.AfterFunc(parent, func() {
+		mu.Lock()
+		defer mu.Unlock()
+		timer = time.AfterFunc(grace, cancel)
+	})
+
+	return ctx, func() {
+		stop()
+		mu.Lock()
+		if timer != nil {
+			timer.Stop()
+		}
+		mu.Unlock()
+		cancel()
+	}
+}
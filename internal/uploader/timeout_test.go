@@ -0,0 +1,118 @@
+package uploader
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/s3errors"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
+)
+
+// blockingUploadClient blocks every upload API call until its context is done.
+type blockingUploadClient struct{}
+
+func (b *blockingUploadClient) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (b *blockingUploadClient) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (b *blockingUploadClient) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (b *blockingUploadClient) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (b *blockingUploadClient) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestTimeoutClient(t *testing.T) {
+	client := &timeoutClient{client: &blockingUploadClient{}, timeout: 10 * time.Millisecond}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := client.PutObject(context.Background(), &s3.PutObjectInput{})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("PutObject() error = %v, want context.DeadlineExceeded", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("PutObject() did not time out")
+	}
+
+	if _, err := client.UploadPart(context.Background(), &s3.UploadPartInput{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("UploadPart() error = %v, want context.DeadlineExceeded", err)
+	}
+}
+
+// deniedUploadClient refuses every upload API call.
+type deniedUploadClient struct{}
+
+var errDenied = &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied"}
+
+func (deniedUploadClient) PutObject(ctx context.Context, params *s3.PutObjectInput, optFns ...func(*s3.Options)) (*s3.PutObjectOutput, error) {
+	return nil, errDenied
+}
+
+func (deniedUploadClient) UploadPart(ctx context.Context, params *s3.UploadPartInput, optFns ...func(*s3.Options)) (*s3.UploadPartOutput, error) {
+	return nil, errDenied
+}
+
+func (deniedUploadClient) CreateMultipartUpload(ctx context.Context, params *s3.CreateMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CreateMultipartUploadOutput, error) {
+	return nil, errDenied
+}
+
+func (deniedUploadClient) CompleteMultipartUpload(ctx context.Context, params *s3.CompleteMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.CompleteMultipartUploadOutput, error) {
+	return nil, errDenied
+}
+
+func (deniedUploadClient) AbortMultipartUpload(ctx context.Context, params *s3.AbortMultipartUploadInput, optFns ...func(*s3.Options)) (*s3.AbortMultipartUploadOutput, error) {
+	return nil, errDenied
+}
+
+func TestTimeoutClient_AccessDenied(t *testing.T) {
+	client := &timeoutClient{client: deniedUploadClient{}}
+	ctx := context.Background()
+
+	_, errCreate := client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{})
+	_, errPart := client.UploadPart(ctx, &s3.UploadPartInput{})
+	_, errComplete := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{})
+	for name, err := range map[string]error{"CreateMultipartUpload": errCreate, "UploadPart": errPart, "CompleteMultipartUpload": errComplete} {
+		if !errors.Is(err, s3errors.ErrAccessDenied) {
+			t.Errorf("%s() error = %v, want ErrAccessDenied", name, err)
+		}
+	}
+}
+
+func TestTimeoutClient_AbortSurvivesCancellation(t *testing.T) {
+	client := &timeoutClient{client: &blockingUploadClient{}, timeout: 10 * time.Millisecond}
+
+	// An interrupted upload aborts with its cancelled context; the abort must
+	// still be sent and only give up at the operation timeout
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.AbortMultipartUpload(ctx, &s3.AbortMultipartUploadInput{}); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("AbortMultipartUpload() error = %v, want context.DeadlineExceeded", err)
+	}
+
+	if _, err := client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{}); !errors.Is(err, context.Canceled) {
+		t.Errorf("CompleteMultipartUpload() error = %v, want context.Canceled", err)
+	}
+}
@@ -5,118 +5,239 @@ package uploader
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
 	"path/filepath"
-	"strings"
+	"regexp"
+	"strconv"
+	"sync"
 	"time"
 
+	"github.com/13rac1/cclogs/internal/codec"
+	"github.com/13rac1/cclogs/internal/config"
 	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/output"
 	"github.com/13rac1/cclogs/internal/redactor"
+	"github.com/13rac1/cclogs/internal/s3errors"
+	"github.com/13rac1/cclogs/internal/telemetry"
 	"github.com/13rac1/cclogs/internal/types"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	s3types "github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // FileUpload represents a file to be uploaded to S3.
 type FileUpload struct {
-	LocalPath  string    // Full path to local file
-	S3Key      string    // Destination S3 key
-	Size       int64     // File size in bytes
-	ModTime    time.Time // File modification time
-	ProjectDir string    // Project directory name
-	ShouldSkip bool      // True if file exists remotely and is identical
-	SkipReason string    // Reason for skipping (e.g., "unchanged")
+	LocalPath  string      // Full path to local file
+	S3Key      string      // Destination S3 key, and the file's manifest key
+	ObjectKey  string      // Key the object is stored under when it differs from S3Key (--date-partition)
+	Size       int64       // File size in bytes
+	ModTime    time.Time   // File modification time
+	ProjectDir string      // Project directory name
+	Codec      codec.Codec // Compression applied before upload (empty means none)
+	ShouldSkip bool        // True if file exists remotely and is identical
+	AppendedTo int64       // Previously uploaded size if the file only grew by appending (0 otherwise)
+	SkipReason string      // Reason for skipping (e.g., "unchanged")
+}
+
+// objectKey returns the key the file's object is stored under.
+func (f FileUpload) objectKey() string {
+	if f.ObjectKey != "" {
+		return f.ObjectKey
+	}
+	return f.S3Key
+}
+
+// s3API is the subset of the S3 API the upload path uses: single and
+// multipart object uploads, manifest reads and writes, and HeadObject for
+// the --no-manifest mode.
+type s3API interface {
+	manager.UploadAPIClient
+	s3ClientInterface
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
 }
 
 // Uploader orchestrates file uploads to S3.
 type Uploader struct {
-	cfg      *types.Config
-	client   *s3.Client
-	noRedact bool
-	debug    bool
+	cfg            *types.Config
+	client         s3API
+	noRedact       bool
+	debug          bool
+	allowShrink    bool
+	noManifest     bool
+	failFast       bool
+	estimate       bool           // Project compressed sizes in DryRunProcess
+	summaryOnly    bool           // Omit DryRunProcess's per-file lines
+	excludeContent *regexp.Regexp // Skip files whose start matches (SetExcludeContent)
+	since          time.Time
+	datePartition  time.Time      // Day objects are partitioned under (zero: no partition)
+	lastUpload     time.Time      // Manifest LastUpload seen by DiscoverFiles
+	archive        manifest.Usage // Manifest totals seen by DiscoverFiles
+	verified       int            // Manifest entries deep-verified within DeepVerifyWindow
+	strictManifest bool           // Fail DiscoverFiles when the manifest can't be loaded (Summarize)
+	out            io.Writer      // Progress and summaries (default output.Human())
+	errOut         io.Writer      // Warnings and debug output (default os.Stderr)
+	resumeDir      string         // Where resume files are kept (SetResumeDir; "" disables)
+	noResume       bool           // Discard resume files instead of consulting them
+
+	keys   keyLocks   // Serializes uploads per key across concurrent Upload calls
+	saveMu sync.Mutex // Serializes manifest saves
 }
 
 // New creates a new Uploader with the given configuration and S3 client.
+// A nil client disables all remote operations (dry runs and tests).
 func New(cfg *types.Config, client *s3.Client, noRedact, debug bool) *Uploader {
+	// Keep a nil *s3.Client from becoming a non-nil interface
+	if client == nil {
+		return newUploader(cfg, nil, noRedact, debug)
+	}
+	return newUploader(cfg, client, noRedact, debug)
+}
+
+// newUploader creates an Uploader over any s3API implementation.
+func newUploader(cfg *types.Config, client s3API, noRedact, debug bool) *Uploader {
 	return &Uploader{
 		cfg:      cfg,
 		client:   client,
 		noRedact: noRedact,
 		debug:    debug,
+		out:      output.Human(),
+		errOut:   os.Stderr,
 	}
 }
 
-// DiscoverFiles finds all .jsonl files across all local projects.
-// It scans each immediate child directory under projects_root,
-// recursively finds all .jsonl files, and computes their S3 keys.
-func (u *Uploader) DiscoverFiles(ctx context.Context) ([]FileUpload, error) {
-	projectsRoot := u.cfg.Local.ProjectsRoot
-
-	// Verify projects root exists and is a directory
-	info, err := os.Stat(projectsRoot)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, fmt.Errorf("projects root does not exist: %s", projectsRoot)
-		}
-		return nil, fmt.Errorf("accessing projects root %s: %w", projectsRoot, err)
+// SetOutput redirects progress and summaries to out, and warnings and debug
+// output to errOut. A nil writer discards that output.
+func (u *Uploader) SetOutput(out, errOut io.Writer) {
+	if out == nil {
+		out = io.Discard
 	}
-
-	if !info.IsDir() {
-		return nil, fmt.Errorf("projects root is not a directory: %s", projectsRoot)
+	if errOut == nil {
+		errOut = io.Discard
 	}
+	u.out = out
+	u.errOut = errOut
+}
 
-	// Read immediate children of projects root
-	entries, err := os.ReadDir(projectsRoot)
-	if err != nil {
-		return nil, fmt.Errorf("reading projects root %s: %w", projectsRoot, err)
-	}
+// SetAllowShrink controls whether files smaller than their manifest entry are
+// uploaded over the remote copy. By default they are skipped with a warning.
+func (u *Uploader) SetAllowShrink(allow bool) {
+	u.allowShrink = allow
+}
 
-	var uploads []FileUpload
+// SetNoManifest bypasses the manifest: DiscoverFiles decides each file with a
+// HeadObject check (ShouldUpload) and Upload neither loads nor saves it.
+func (u *Uploader) SetNoManifest(noManifest bool) {
+	u.noManifest = noManifest
+}
 
-	// Process each directory as a project
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			continue
-		}
+// SetResumeDir makes Upload record each file it finishes in a resume file
+// under dir until the manifest is saved, and skip the files an interrupted
+// run recorded there. An empty dir disables resume files.
+func (u *Uploader) SetResumeDir(dir string) {
+	u.resumeDir = dir
+}
 
-		projectDir := entry.Name()
-		projectPath := filepath.Join(projectsRoot, projectDir)
+// SetNoResume makes Upload discard a resume file left by an interrupted run
+// instead of skipping the files it recorded.
+func (u *Uploader) SetNoResume(noResume bool) {
+	u.noResume = noResume
+}
 
-		// Find all .jsonl files in this project
-		projectUploads, err := u.discoverProjectFiles(projectPath, projectDir)
-		if err != nil {
-			// Log warning but continue with other projects
-			fmt.Fprintf(os.Stderr, "Warning: failed to discover files in project %s: %v\n", projectDir, err)
-			continue
+// SetFailFast stops Upload at the first file that fails instead of
+// continuing with the rest of the batch.
+func (u *Uploader) SetFailFast(failFast bool) {
+	u.failFast = failFast
+}
+
+// SetEstimateCompression makes DryRunProcess compress each file's redacted
+// content to a counter and report the projected stored sizes.
+func (u *Uploader) SetEstimateCompression(estimate bool) {
+	u.estimate = estimate
+}
+
+// SetSummaryOnly makes DryRunProcess omit its per-file lines and print only
+// the totals.
+func (u *Uploader) SetSummaryOnly(summaryOnly bool) {
+	u.summaryOnly = summaryOnly
+}
+
+// SetSince limits uploads to files modified at or after t. The zero time
+// disables the filter.
+func (u *Uploader) SetSince(t time.Time) {
+	u.since = t
+}
+
+// DiscoverFiles finds all log files across all local projects.
+// It scans each immediate child directory under projects_root,
+// recursively finds all files with a local.extensions extension (default
+// .jsonl), and computes their S3 keys.
+func (u *Uploader) DiscoverFiles(ctx context.Context) (uploads []FileUpload, err error) {
+	ctx, span := telemetry.Tracer().Start(ctx, "discover")
+	defer func() {
+		pending := 0
+		for _, f := range uploads {
+			if !f.ShouldSkip {
+				pending++
+			}
 		}
+		span.SetAttributes(attribute.Int("discover.files", len(uploads)), attribute.Int("discover.pending", pending))
+		telemetry.EndSpan(span, err)
+	}()
 
-		uploads = append(uploads, projectUploads...)
+	// Without a manifest, ask S3 about each file instead
+	if u.client != nil && u.noManifest {
+		if uploads, err = u.discoverLocal(nil); err != nil {
+			return nil, err
+		}
+		if err := u.checkRemote(ctx, uploads); err != nil {
+			return nil, err
+		}
+		u.excludeByContent(uploads)
+		return uploads, nil
 	}
 
 	// Check files against manifest to determine if upload is needed
 	// Skip manifest checking if client is nil (for tests)
-	if u.client != nil {
-		// Compute manifest key
-		manifestKey := u.cfg.S3.Prefix
-		if manifestKey != "" && !strings.HasSuffix(manifestKey, "/") {
-			manifestKey += "/"
+	if u.client == nil {
+		if uploads, err = u.discoverLocal(nil); err != nil {
+			return nil, err
 		}
-		manifestKey += ".manifest.json"
+	} else {
+		manifestKey := manifest.ConfigKey(u.cfg)
 
 		// Load manifest from S3
-		m, err := manifest.Load(ctx, u.client, u.cfg.S3.Bucket, manifestKey)
+		m, err := manifest.Load(ctx, u.client, u.cfg.S3.Bucket, manifestKey, u.cfg.S3.OperationTimeout)
 		if err != nil {
+			if u.strictManifest {
+				return nil, fmt.Errorf("loading manifest: %w", err)
+			}
 			// Log warning but continue - treat as first run
-			fmt.Fprintf(os.Stderr, "Warning: failed to load manifest (treating as first run): %v\n", err)
+			fmt.Fprintf(u.errOut, "Warning: failed to load manifest (treating as first run): %v\n", err)
 			m = manifest.New()
 		}
+		if uploads, err = u.discoverLocal(m); err != nil {
+			return nil, err
+		}
+		u.lastUpload = m.LastUpload
+		u.archive = manifest.Usage{}
+		for _, usage := range m.UsageByProject(config.KeyPrefix(u.cfg)) {
+			u.archive.Add(usage)
+		}
+		u.verified, _ = m.VerifiedSince(config.KeyPrefix(u.cfg), time.Now().Add(-DeepVerifyWindow))
 
 		// Compare each local file against manifest
+		var appends []appendCheck
 		for i := range uploads {
+			if uploads[i].ShouldSkip {
+				continue
+			}
+
 			entry, exists := m.Files[uploads[i].S3Key]
 			if !exists {
 				// File not in manifest - needs upload
@@ -124,24 +245,73 @@ func (u *Uploader) DiscoverFiles(ctx context.Context) ([]FileUpload, error) {
 				continue
 			}
 
-			// Compare modification times (truncate to seconds for filesystem compatibility)
-			localMtime := uploads[i].ModTime.Truncate(time.Second)
-			remoteMtime := entry.Mtime.Truncate(time.Second)
-
-			if localMtime.Equal(remoteMtime) {
+			if syncState(uploads[i], entry) == StateInSync {
 				uploads[i].ShouldSkip = true
 				uploads[i].SkipReason = "unchanged"
-			} else {
-				uploads[i].ShouldSkip = false
+				continue
 			}
+
+			// A file that shrank was likely truncated; don't overwrite a good remote copy
+			if uploads[i].Size < entry.Size && !u.allowShrink {
+				fmt.Fprintf(u.errOut, "Warning: %s shrank from %s to %s since last upload; skipping (use --allow-shrink to overwrite)\n",
+					uploads[i].LocalPath, output.FormatSize(entry.Size), output.FormatSize(uploads[i].Size))
+				uploads[i].ShouldSkip = true
+				uploads[i].SkipReason = "shrank"
+				continue
+			}
+
+			// Sessions grow by appending; the whole file is still uploaded, but
+			// the delta is reported and the previous size kept as the offset
+			appends = append(appends, appendCheck{i: i, entry: entry})
+
+			uploads[i].ShouldSkip = false
 		}
+
+		if err := u.checkAppends(ctx, uploads, appends); err != nil {
+			return nil, err
+		}
+		if !u.datePartition.IsZero() {
+			u.partition(ctx, uploads, m)
+		}
+	}
+
+	u.excludeByContent(uploads)
+	return uploads, nil
+}
+
+// discoverLocal walks the projects root and returns every log file with
+// its S3 key, before any comparison with the remote side. Files m records
+// keep the codec, and so the key, they were uploaded with; m may be nil.
+func (u *Uploader) discoverLocal(m *manifest.Manifest) ([]FileUpload, error) {
+	dirs, err := config.ProjectDirs(u.cfg.Local)
+	if err != nil {
+		return nil, err
+	}
+
+	var uploads []FileUpload
+
+	// Process each project directory
+	for _, d := range dirs {
+		// Find all .jsonl files in this project
+		projectUploads, err := u.discoverProjectFiles(d.Path, d.Name, m)
+		if err != nil {
+			// Log warning but continue with other projects
+			fmt.Fprintf(u.errOut, "Warning: failed to discover files in project %s: %v\n", d.Name, err)
+			continue
+		}
+
+		uploads = append(uploads, projectUploads...)
 	}
 
+	// The same physical file can be reachable under several keys via symlinks
+	// or hard links; upload it only once
+	markDuplicates(uploads)
+
 	return uploads, nil
 }
 
-// discoverProjectFiles finds all .jsonl files within a single project directory.
-func (u *Uploader) discoverProjectFiles(projectPath, projectDir string) ([]FileUpload, error) {
+// discoverProjectFiles finds all log files within a single project directory.
+func (u *Uploader) discoverProjectFiles(projectPath, projectDir string, m *manifest.Manifest) ([]FileUpload, error) {
 	var uploads []FileUpload
 
 	err := filepath.WalkDir(projectPath, func(path string, d fs.DirEntry, err error) error {
@@ -153,8 +323,8 @@ func (u *Uploader) discoverProjectFiles(projectPath, projectDir string) ([]FileU
 			return nil
 		}
 
-		// Only process .jsonl files
-		if !strings.HasSuffix(strings.ToLower(d.Name()), ".jsonl") {
+		// Only process files with a configured log extension
+		if !config.HasLogExtension(d.Name(), u.cfg.Local.Extensions) {
 			return nil
 		}
 
@@ -170,8 +340,14 @@ func (u *Uploader) discoverProjectFiles(projectPath, projectDir string) ([]FileU
 			return fmt.Errorf("computing relative path for %s: %w", path, err)
 		}
 
-		// Compute S3 key
-		s3Key := ComputeS3Key(u.cfg.S3.Prefix, projectDir, relPath)
+		// Compute S3 key from the configured template
+		s3Key := config.RenderKey(config.KeyTemplate(u.cfg), config.KeyFields{
+			Prefix:  u.cfg.S3.Prefix,
+			Host:    u.cfg.Local.MachineID,
+			Project: projectDir,
+			Path:    relPath,
+			ModTime: info.ModTime(),
+		})
 
 		upload := FileUpload{
 			LocalPath:  path,
@@ -181,6 +357,21 @@ func (u *Uploader) discoverProjectFiles(projectPath, projectDir string) ([]FileU
 			ProjectDir: projectDir,
 		}
 
+		// Abandoned sessions leave empty files that only clutter the bucket
+		if upload.Size == 0 && !u.cfg.Upload.UploadEmpty {
+			upload.ShouldSkip = true
+			upload.SkipReason = "empty"
+		}
+
+		if !u.since.IsZero() && upload.ModTime.Before(u.since) {
+			upload.ShouldSkip = true
+			upload.SkipReason = "before --since"
+		}
+
+		// Compressed objects carry the codec's extension
+		upload.Codec = u.chooseCodec(upload, m)
+		upload.S3Key += upload.Codec.Extension()
+
 		uploads = append(uploads, upload)
 
 		return nil
@@ -193,42 +384,41 @@ func (u *Uploader) discoverProjectFiles(projectPath, projectDir string) ([]FileU
 	return uploads, nil
 }
 
-// ComputeS3Key generates the S3 key for a local file.
+// ComputeS3Key generates the S3 key for a local file using the default template.
 // Format: <prefix>/<project-dir>/<relative-path>
 // The prefix is normalized to have a trailing slash if non-empty.
 // Path separators are converted to forward slashes for S3 compatibility.
 func ComputeS3Key(prefix, projectDir, relPath string) string {
-	// Ensure prefix has trailing slash if non-empty
-	if prefix != "" && !strings.HasSuffix(prefix, "/") {
-		prefix += "/"
-	}
-
-	// Convert backslashes to forward slashes (handles Windows paths)
-	// filepath.ToSlash only converts the OS-specific separator, so we need
-	// to explicitly handle backslashes on Unix systems
-	prefix = strings.ReplaceAll(prefix, "\\", "/")
-	projectDir = strings.ReplaceAll(projectDir, "\\", "/")
-	relPath = strings.ReplaceAll(relPath, "\\", "/")
-
-	// Combine with forward slashes
-	var key string
-	if prefix != "" {
-		key = prefix + projectDir + "/" + relPath
-	} else {
-		key = projectDir + "/" + relPath
-	}
-
-	return key
+	return config.RenderKey(config.DefaultKeyTemplate, config.KeyFields{
+		Prefix:  prefix,
+		Project: projectDir,
+		Path:    relPath,
+	})
 }
 
 // UploadResult contains summary statistics from an upload operation.
 type UploadResult struct {
-	Uploaded       int             // Number of files uploaded
-	Skipped        int             // Number of files skipped
-	UploadedBytes  int64           // Total bytes uploaded
-	RedactionStats *redactor.Stats // Aggregated redaction statistics
+	Uploaded       int                // Number of files uploaded
+	Skipped        int                // Number of files skipped
+	UploadedBytes  int64              // Total bytes uploaded
+	RedactionStats *redactor.Stats    // Aggregated redaction statistics
+	Modified       []string           // Files that changed while uploading (not recorded in manifest)
+	Anomalies      []redactor.Anomaly // Patterns matching implausibly often
+	Failures       []FileFailure      // Files that failed to upload (not recorded in manifest)
+	Compression    *codec.Estimate    // Projected stored sizes (dry runs with SetEstimateCompression)
 }
 
+// FileFailure records a file that could not be uploaded.
+type FileFailure struct {
+	Key  string // Destination S3 key
+	Path string // Local file path
+	Err  error
+}
+
+// ErrPartialFailure is returned by Upload when some files failed to upload.
+// The successful uploads are still recorded in the manifest.
+var ErrPartialFailure = errors.New("some files failed to upload")
+
 // Upload uploads the provided files to S3, respecting the ShouldSkip field.
 // Files marked with ShouldSkip=true are skipped and reported as such.
 // Returns summary statistics and any error encountered.
@@ -237,189 +427,514 @@ func (u *Uploader) Upload(ctx context.Context, files []FileUpload) (*UploadResul
 		return &UploadResult{}, nil
 	}
 
-	// Early return for tests with nil client - just count skips
+	// Without a client nothing is sent; progress and the summary are printed
+	// as if every file uploaded (used by tests and library callers)
 	if u.client == nil {
 		result := &UploadResult{}
-		for _, file := range files {
-			// Check context cancellation
+		var interrupted error
+		for i, file := range files {
 			if err := ctx.Err(); err != nil {
-				return result, fmt.Errorf("upload cancelled: %w", err)
+				interrupted = err
+				break
 			}
 
 			if file.ShouldSkip {
+				fmt.Fprintf(u.out, "[%d/%d] Skipping %s (%s)\n", i+1, len(files), file.LocalPath, file.SkipReason)
 				result.Skipped++
 			} else {
+				fmt.Fprintf(u.out, "[%d/%d] Uploading %s (%s)\n", i+1, len(files), file.LocalPath, output.FormatSize(file.Size))
 				result.Uploaded++
 				result.UploadedBytes += file.Size
 			}
 		}
+		u.printUploadSummary(result, len(files), interrupted, "")
+		if interrupted != nil {
+			return result, fmt.Errorf("upload interrupted: %w", interrupted)
+		}
 		return result, nil
 	}
 
-	// Compute manifest key
-	manifestKey := u.cfg.S3.Prefix
-	if manifestKey != "" && !strings.HasSuffix(manifestKey, "/") {
-		manifestKey += "/"
-	}
-	manifestKey += ".manifest.json"
+	manifestKey := manifest.ConfigKey(u.cfg)
 
 	// Load existing manifest
-	m, err := manifest.Load(ctx, u.client, u.cfg.S3.Bucket, manifestKey)
-	if err != nil {
-		// Log warning but continue with empty manifest
-		fmt.Fprintf(os.Stderr, "Warning: failed to load manifest for update: %v\n", err)
-		m = manifest.New()
+	m := manifest.New()
+	if !u.noManifest {
+		loaded, err := manifest.Load(ctx, u.client, u.cfg.S3.Bucket, manifestKey, u.cfg.S3.OperationTimeout)
+		if err != nil {
+			// Log warning but continue with empty manifest
+			fmt.Fprintf(u.errOut, "Warning: failed to load manifest for update: %v\n", err)
+		} else {
+			m = loaded
+		}
 	}
 
-	// Configure uploader with multipart settings
-	uploader := manager.NewUploader(u.client, func(mu *manager.Uploader) {
-		mu.Concurrency = 5            // 5 concurrent parts per file
-		mu.PartSize = 5 * 1024 * 1024 // 5MB parts
-	})
+	// Files finished by a run that died before saving the manifest are
+	// taken from its resume file rather than uploaded again
+	var journal *resumeLog
+	resumed := 0
+	if !u.noManifest && u.resumeDir != "" {
+		journal = &resumeLog{path: resumePath(u.resumeDir, u.cfg.S3.Bucket, manifestKey)}
+		defer journal.close()
+		if u.noResume {
+			if err := journal.remove(); err != nil {
+				fmt.Fprintf(u.errOut, "Warning: failed to discard resume file: %v\n", err)
+			}
+		} else {
+			done, err := readResume(journal.path)
+			if err != nil {
+				fmt.Fprintf(u.errOut, "Warning: failed to read resume file: %v\n", err)
+			}
+			files, resumed = applyResume(files, done, m)
+			if resumed > 0 {
+				fmt.Fprintf(u.out, "Resuming: %d files finished by an interrupted run are skipped (use --no-resume to upload them again)\n", resumed)
+			}
+		}
+	}
+
+	// Each part request gets its own deadline via timeoutClient
+	client := &timeoutClient{client: u.client, timeout: u.cfg.S3.OperationTimeout}
+	uploader := u.newMultipartUploader(client)
+
+	if u.debug {
+		fmt.Fprintf(u.errOut, "[DEBUG] upload settings: part_size=%s part_concurrency=%d spool=%s below %s\n",
+			types.ByteSize(uploader.PartSize), uploader.Concurrency, u.cfg.Upload.Spool, types.ByteSize(u.spoolThreshold(uploader.PartSize)))
+	}
 
 	result := &UploadResult{
 		RedactionStats: redactor.NewStats(),
 	}
 	totalFiles := len(files)
+	dict := u.loadDictionary(ctx, files)
+
+	// Every scanned file counts towards match rates, not just files with matches
+	history := redactionHistory(m)
+	scanned := redactor.NewStats()
+
+	// Set when the context is cancelled (e.g. Ctrl+C) or the projects root
+	// disappears; stops the loop so the manifest can still be saved for files
+	// that completed
+	var interrupted error
+	consecutiveFailures := 0
+
+	run := newRunTrace(ctx)
 
 	for i, file := range files {
 		fileNum := i + 1
 
-		// Check context cancellation
+		// Check context cancellation between files
 		if err := ctx.Err(); err != nil {
-			return result, fmt.Errorf("upload cancelled: %w", err)
+			interrupted = err
+			break
 		}
 
 		// Skip files marked as unchanged
 		if file.ShouldSkip {
-			fmt.Printf("[%d/%d] Skipping %s (%s)\n", fileNum, totalFiles, file.LocalPath, file.SkipReason)
+			fmt.Fprintf(u.out, "[%d/%d] Skipping %s (%s)\n", fileNum, totalFiles, file.LocalPath, file.SkipReason)
 			result.Skipped++
+			run.skipped(file, file.SkipReason)
+			continue
+		}
+
+		// At most one upload per key is in flight; a queued upload of the
+		// snapshot that was stored meanwhile is coalesced away
+		unlock := u.keys.lock(file.S3Key)
+		if u.keys.alreadyStored(file) {
+			unlock()
+			fmt.Fprintf(u.out, "[%d/%d] Skipping %s (already uploaded)\n", fileNum, totalFiles, file.LocalPath)
+			result.Skipped++
+			run.skipped(file, "already uploaded")
 			continue
 		}
 
 		// Upload the file
-		fmt.Printf("[%d/%d] Uploading %s (%s)", fileNum, totalFiles, file.LocalPath, formatSize(file.Size))
+		fmt.Fprintf(u.out, "[%d/%d] Uploading %s (%s%s)", fileNum, totalFiles, file.LocalPath, output.FormatSize(file.Size), appendNote(file))
 
-		fileStats, err := u.uploadFile(ctx, uploader, file)
+		// The in-progress file gets a short grace period to finish after cancellation
+		fileCtx, cancelFile := withGracePeriod(ctx, uploadGracePeriod)
+		fileCtx, span := run.startFile(fileCtx, file)
+		fileStats, digests, err := u.uploadFile(fileCtx, client, uploader, file, dict)
+		cancelFile()
 		if err != nil {
-			fmt.Println() // Complete the line
-			return result, fmt.Errorf("uploading %s: %w", file.LocalPath, err)
+			run.failed(span, err)
+			unlock()
+			fmt.Fprintln(u.out) // Complete the line
+			if ctx.Err() != nil {
+				fmt.Fprintf(u.errOut, "Aborted %s: not finished within grace period\n", file.LocalPath)
+				interrupted = ctx.Err()
+				break
+			}
+			fmt.Fprintf(u.out, "  failed: %v\n", err)
+			result.Failures = append(result.Failures, FileFailure{Key: file.S3Key, Path: file.LocalPath, Err: err})
+			if u.failFast {
+				break
+			}
+			// A run of failures may mean the files are gone, not that each
+			// one is broken; stop instead of failing every remaining file
+			if consecutiveFailures++; consecutiveFailures >= rootCheckAfter {
+				if reason := rootUnavailable(u.cfg.Local.ProjectsRoot); reason != "" {
+					interrupted = fmt.Errorf("%w: %s", ErrProjectsRootUnavailable, reason)
+					break
+				}
+			}
+			continue
+		}
+		consecutiveFailures = 0
+
+		// A file written to mid-upload produced a torn object; it is recorded
+		// as such so no manifest entry claims it, and the next run uploads it again
+		torn := modifiedSinceDiscovery(file)
+		run.uploaded(span, file, digests.object.size, fileStats, torn)
+		entry := manifest.FileEntry{
+			Mtime:        file.ModTime,
+			Size:         file.Size,
+			UploadedSize: digests.object.size,
+			SHA256:       digests.object.sum(),
+			SourceSHA256: digests.source.sum(),
+			Project:      file.ProjectDir,
+			Codec:        string(file.Codec),
+			DictID:       zstdDictID(file.Codec, dict),
+			Machine:      u.cfg.Local.MachineID,
+			Object:       file.ObjectKey,
+			ETag:         digests.etag,
+			Host:         u.cfg.Identity.Hostname,
+			UploadedAt:   time.Now().UTC(),
+		}
+		if fileStats != nil {
+			entry.Lines = fileStats.LinesProcessed
+			entry.Redactions = fileStats.ByPattern
 		}
+		u.keys.record(file.S3Key, storedSnapshot{entry: entry, torn: torn})
+		unlock()
+
+		scanned.Add(fileStats)
 
 		// Display per-file redaction stats
 		if fileStats != nil && fileStats.TotalMatches > 0 {
-			fmt.Printf(" → %s (%.1f%% redacted, %d matches)\n",
-				formatSize(fileStats.RedactedBytes),
+			fmt.Fprintf(u.out, " → %s (%.1f%% redacted, %d matches)\n",
+				output.FormatSize(fileStats.RedactedBytes),
 				fileStats.PercentReduction(),
 				fileStats.TotalMatches)
 			result.RedactionStats.Add(fileStats)
 		} else {
-			fmt.Println() // No redaction to report
-		}
-
-		// Update manifest entry after successful upload
-		m.Files[file.S3Key] = manifest.FileEntry{
-			Mtime: file.ModTime,
-			Size:  file.Size,
+			fmt.Fprintln(u.out) // No redaction to report
 		}
 
 		result.Uploaded++
 		result.UploadedBytes += file.Size
+
+		if torn {
+			result.Modified = append(result.Modified, file.LocalPath)
+			continue
+		}
+		m.Files[file.S3Key] = entry
+		if journal != nil {
+			if err := journal.add(file.S3Key, entry); err != nil {
+				fmt.Fprintf(u.errOut, "Warning: failed to update resume file: %v\n", err)
+			}
+		}
 	}
 
-	// Save updated manifest if any files were uploaded
-	if result.Uploaded > 0 {
-		if err := manifest.Save(ctx, u.client, u.cfg.S3.Bucket, manifestKey, m); err != nil {
+	run.end()
+
+	// Save updated manifest if any files were uploaded. Detach from cancellation
+	// so an interrupted run still records the files it finished.
+	manifestStatus := ""
+	saved := true
+	if (result.Uploaded > 0 || resumed > 0) && !u.noManifest {
+		// Concurrent Upload calls may have stored newer snapshots of these keys
+		u.saveMu.Lock()
+		u.keys.apply(m)
+		m.LastUpload = time.Now().UTC()
+		m.Join(u.cfg.Local.MachineID, u.cfg.S3.ArchiveName)
+		err := manifest.Save(context.WithoutCancel(ctx), u.client, u.cfg.S3.Bucket, manifestKey, m, u.cfg.S3.OperationTimeout)
+		u.saveMu.Unlock()
+		manifestStatus = "manifest saved"
+		if err != nil {
 			// Log warning but don't fail - files were successfully uploaded
-			fmt.Fprintf(os.Stderr, "Warning: failed to save manifest (uploads succeeded): %v\n", err)
+			fmt.Fprintf(u.errOut, "Warning: failed to save manifest (uploads succeeded): %v\n", err)
+			manifestStatus = "manifest not saved"
+			saved = false
 		}
 	}
 
-	// Print summary
-	fmt.Printf("\nUpload complete: %d uploaded (%s), %d skipped\n",
-		result.Uploaded, formatSize(result.UploadedBytes), result.Skipped)
+	// Once the manifest holds everything the resume file does, it is no
+	// longer needed; it is kept if the save failed so the next run can use it
+	if journal != nil && saved {
+		if err := journal.remove(); err != nil {
+			fmt.Fprintf(u.errOut, "Warning: failed to remove resume file: %v\n", err)
+		}
+	}
 
-	// Print redaction summary if any matches were found
-	if result.RedactionStats != nil && result.RedactionStats.TotalMatches > 0 {
-		fmt.Printf("\nRedaction summary:\n")
-		fmt.Printf("  Total: %s → %s (%.1f%% reduction)\n",
-			formatSize(result.RedactionStats.OriginalBytes),
-			formatSize(result.RedactionStats.RedactedBytes),
-			result.RedactionStats.PercentReduction())
-		fmt.Printf("  Matches: %d total\n", result.RedactionStats.TotalMatches)
+	u.printUploadSummary(result, totalFiles, interrupted, manifestStatus)
 
-		// Print per-pattern breakdown
-		for _, pc := range result.RedactionStats.PatternSummary() {
-			fmt.Printf("    %s: %d\n", pc.Pattern, pc.Count)
-		}
+	result.Anomalies = redactor.DetectAnomalies(scanned, history, u.cfg.Redact.MaxMatchShare)
+	u.printAnomalies(result.Anomalies)
+
+	if interrupted != nil {
+		return result, fmt.Errorf("upload interrupted: %w", interrupted)
+	}
+	if len(result.Failures) > 0 {
+		return result, fmt.Errorf("%w: %d of %d files (first: %s: %v)",
+			ErrPartialFailure, len(result.Failures), totalFiles, result.Failures[0].Path, result.Failures[0].Err)
 	}
 
 	return result, nil
 }
 
-// uploadFile uploads a single file to S3 using the configured uploader.
-// Returns redaction stats if redaction was enabled, nil otherwise.
-func (u *Uploader) uploadFile(ctx context.Context, uploader *manager.Uploader, file FileUpload) (*redactor.Stats, error) {
+// printUploadSummary prints the totals of an upload run followed by the
+// failed, modified, and redaction sections that apply. manifestStatus, if
+// set, tells an interrupted run whether its finished files were recorded.
+func (u *Uploader) printUploadSummary(result *UploadResult, totalFiles int, interrupted error, manifestStatus string) {
+	remaining := totalFiles - result.Uploaded - result.Skipped - len(result.Failures)
+	switch {
+	case interrupted != nil:
+		if manifestStatus != "" {
+			manifestStatus = ", " + manifestStatus
+		}
+		fmt.Fprintf(u.out, "\nUpload interrupted: %d of %d uploaded (%s), %d skipped, %d failed, %d not processed%s\n",
+			result.Uploaded, totalFiles, output.FormatSize(result.UploadedBytes), result.Skipped, len(result.Failures), remaining, manifestStatus)
+	case remaining > 0:
+		fmt.Fprintf(u.out, "\nUpload stopped: %d uploaded (%s), %d skipped, %d failed, %d not processed\n",
+			result.Uploaded, output.FormatSize(result.UploadedBytes), result.Skipped, len(result.Failures), remaining)
+	default:
+		fmt.Fprintf(u.out, "\nUpload complete: %d uploaded (%s), %d skipped, %d failed\n",
+			result.Uploaded, output.FormatSize(result.UploadedBytes), result.Skipped, len(result.Failures))
+	}
+
+	if len(result.Failures) > 0 {
+		fmt.Fprintf(u.out, "\nFailed uploads (will be retried next run):\n")
+		for _, f := range result.Failures {
+			fmt.Fprintf(u.out, "  %s: %v\n", f.Path, f.Err)
+		}
+	}
+
+	if len(result.Modified) > 0 {
+		fmt.Fprintf(u.out, "\nModified during upload (will be uploaded again next run):\n")
+		for _, path := range result.Modified {
+			fmt.Fprintf(u.out, "  %s\n", path)
+		}
+	}
+
+	u.printRedactionSummary(result.RedactionStats)
+}
+
+// printRedactionSummary prints redaction totals and a per-pattern breakdown
+// grouped by severity, if any matches were found.
+func (u *Uploader) printRedactionSummary(stats *redactor.Stats) {
+	if stats == nil || stats.TotalMatches == 0 {
+		return
+	}
+	fmt.Fprintf(u.out, "\nRedaction summary:\n")
+	fmt.Fprintf(u.out, "  Total: %s → %s (%.1f%% reduction)\n",
+		output.FormatSize(stats.OriginalBytes), output.FormatSize(stats.RedactedBytes), stats.PercentReduction())
+	fmt.Fprintf(u.out, "  Matches: %d total, %d high-severity secrets found\n",
+		stats.TotalMatches, stats.CountAtLeast(redactor.SeverityHigh))
+
+	// Print per-pattern breakdown
+	for _, pc := range stats.PatternSummary() {
+		fmt.Fprintf(u.out, "    %s: %d (%s)\n", pc.Pattern, pc.Count, pc.Severity)
+	}
+}
+
+// checkRemote marks files whose remote copy already matches as skipped. Each
+// project is listed once; a file is sent to HeadObject only when the listing
+// can't decide, i.e. the project couldn't be listed, or the object size
+// differs from the local size (as redaction and compression make it) and the
+// object is older than the file, so the source size in its metadata is needed
+// to tell whether the file changed since.
+func (u *Uploader) checkRemote(ctx context.Context, uploads []FileUpload) error {
+	cache := NewObjectCache(u.client, u.cfg.S3.Bucket, config.KeyPrefix(u.cfg), u.cfg.S3.OperationTimeout)
+	for i := range uploads {
+		if uploads[i].ShouldSkip {
+			continue
+		}
+
+		obj, exists, covered := cache.Stat(ctx, uploads[i].S3Key)
+		if covered && (!exists || obj.Size == uploads[i].Size || obj.LastModified.After(uploads[i].ModTime)) {
+			if exists {
+				uploads[i].ShouldSkip = true
+				uploads[i].SkipReason = "exists remotely"
+			}
+			continue
+		}
+
+		upload, err := ShouldUpload(ctx, u.client, u.cfg.S3.Bucket, uploads[i].S3Key, uploads[i].Size, u.cfg.S3.OperationTimeout)
+		if err != nil {
+			return fmt.Errorf("checking %s: %w", uploads[i].LocalPath, err)
+		}
+		if !upload {
+			uploads[i].ShouldSkip = true
+			uploads[i].SkipReason = "exists remotely"
+		}
+	}
+	return nil
+}
+
+// modifiedSinceDiscovery reports whether the file's size or mtime differs from
+// what was seen at discovery. A file that can no longer be stat'd counts as modified.
+func modifiedSinceDiscovery(file FileUpload) bool {
+	info, err := os.Stat(file.LocalPath)
+	if err != nil {
+		return true
+	}
+	return info.Size() != file.Size || !info.ModTime().UTC().Equal(file.ModTime)
+}
+
+// newMultipartUploader creates a multipart uploader using the configured part
+// size and concurrency, falling back to SDK defaults for unset values.
+func (u *Uploader) newMultipartUploader(client manager.UploadAPIClient) *manager.Uploader {
+	return manager.NewUploader(client, func(mu *manager.Uploader) {
+		if u.cfg.Upload.PartSize > 0 {
+			mu.PartSize = int64(u.cfg.Upload.PartSize)
+		}
+		if u.cfg.Upload.PartConcurrency > 0 {
+			mu.Concurrency = u.cfg.Upload.PartConcurrency
+		}
+	})
+}
+
+// uploadFile uploads a single file to S3. Files below the spool threshold are
+// buffered and sent with a single PutObject; larger files stream through the
+// multipart uploader.
+// zstd content is compressed with dict, if not nil.
+// Returns redaction stats if redaction was enabled (nil otherwise) and digests
+// of the bytes that were read locally and actually sent.
+func (u *Uploader) uploadFile(ctx context.Context, client manager.UploadAPIClient, uploader *manager.Uploader, file FileUpload, dict codec.Dictionary) (*redactor.Stats, fileDigests, error) {
 	// Open the local file
 	f, err := os.Open(file.LocalPath)
 	if err != nil {
-		return nil, fmt.Errorf("opening file: %w", err)
+		return nil, fileDigests{}, fmt.Errorf("opening file: %w", err)
 	}
 	defer func() {
 		if closeErr := f.Close(); closeErr != nil {
 			// Log close error but don't override upload error
-			fmt.Fprintf(os.Stderr, "Warning: failed to close file %s: %v\n", file.LocalPath, closeErr)
+			fmt.Fprintf(u.errOut, "Warning: failed to close file %s: %v\n", file.LocalPath, closeErr)
 		}
 	}()
 
+	// Hash the source so a later run can tell an append from a rewrite
+	source := newDigestReader(f)
+
 	// Wrap with redactor unless disabled
-	var body io.Reader = f
+	var body io.Reader = source
 	var statsCh <-chan *redactor.Stats
 	if !u.noRedact {
 		var debugW io.Writer
 		if u.debug {
-			debugW = os.Stderr
+			debugW = u.errOut
 		}
-		body, statsCh = redactor.StreamRedactWithStatsDebug(f, debugW)
+		body, statsCh = streamRedact(file.LocalPath, source, debugW)
 	}
 
+	// Compress after redaction so patterns match the plain text
+	compressed, err := codec.Compress(file.Codec, u.cfg.Upload.CompressLevel, dict, body)
+	if err != nil {
+		return nil, fileDigests{}, fmt.Errorf("compressing: %w", err)
+	}
+	defer func() { _ = compressed.Close() }()
+	body = compressed
+
+	// Hash the uploaded bytes so the manifest can be verified later
+	digest := newDigestReader(body)
+
 	// Upload to S3
-	_, err = uploader.Upload(ctx, &s3.PutObjectInput{
-		Bucket: aws.String(u.cfg.S3.Bucket),
-		Key:    aws.String(file.S3Key),
-		Body:   body,
-	})
+	input := u.objectInput(file)
+	var etag string
+	if file.Size < u.spoolThreshold(uploader.PartSize) {
+		etag, err = u.putSpooled(ctx, client, input, digest)
+	} else {
+		input.Body = digest
+		var out *manager.UploadOutput
+		if out, err = uploader.Upload(ctx, input); err == nil {
+			etag = aws.ToString(out.ETag)
+		}
+	}
 	if err != nil {
-		return nil, fmt.Errorf("s3 upload: %w", err)
+		return nil, fileDigests{}, fmt.Errorf("s3 upload: %w", s3errors.Wrap(err))
 	}
+	digests := fileDigests{object: digest, source: source, etag: etag}
 
 	// Wait for stats after upload completes
 	if statsCh != nil {
 		stats := <-statsCh
-		return stats, nil
+		return stats, digests, nil
 	}
 
-	return nil, nil
+	return nil, digests, nil
 }
 
-// formatSize formats a byte count as a human-readable string.
-func formatSize(bytes int64) string {
-	const (
-		KB = 1024
-		MB = KB * 1024
-		GB = MB * 1024
-	)
+// objectInput returns the PutObjectInput for file without a body: bucket, key,
+// the configured content headers and Object Lock retention, and the source
+// size metadata.
+func (u *Uploader) objectInput(file FileUpload) *s3.PutObjectInput {
+	input := &s3.PutObjectInput{
+		Bucket:      aws.String(u.cfg.S3.Bucket),
+		Key:         aws.String(file.objectKey()),
+		ContentType: aws.String(u.contentType()),
+		Metadata:    map[string]string{sourceSizeMetadata: strconv.FormatInt(file.Size, 10)},
+	}
+	if u.cfg.S3.CacheControl != "" {
+		input.CacheControl = aws.String(u.cfg.S3.CacheControl)
+	}
+	if enc := file.Codec.ContentEncoding(); enc != "" {
+		input.ContentEncoding = aws.String(enc)
+	}
+	if lock := u.cfg.S3.ObjectLock; lock.Mode != "" {
+		input.ObjectLockMode = s3types.ObjectLockMode(lock.Mode)
+		input.ObjectLockRetainUntilDate = aws.Time(time.Now().UTC().AddDate(0, 0, lock.RetainDays))
+	}
+	return input
+}
 
-	switch {
-	case bytes >= GB:
-		return fmt.Sprintf("%.1f GB", float64(bytes)/GB)
-	case bytes >= MB:
-		return fmt.Sprintf("%.1f MB", float64(bytes)/MB)
-	case bytes >= KB:
-		return fmt.Sprintf("%.1f KB", float64(bytes)/KB)
-	default:
-		return fmt.Sprintf("%d B", bytes)
+// contentType returns the configured Content-Type for uploaded logs.
+func (u *Uploader) contentType() string {
+	if u.cfg.S3.ContentType != "" {
+		return u.cfg.S3.ContentType
+	}
+	return config.DefaultContentType
+}
+
+// sendChecksums makes single-PUT uploads carry their SHA-256 checksum.
+var sendChecksums = true
+
+// SetChecksums turns the SHA-256 checksum header of single-PUT uploads on or
+// off, for endpoints that reject it. It must be called before any upload
+// starts.
+func SetChecksums(on bool) {
+	sendChecksums = on
+}
+
+// putSpooled buffers the digested content and uploads it with a single
+// PutObject carrying an exact Content-Length and SHA-256 checksum (unless
+// turned off with SetChecksums), returning
+// the object's ETag. Some S3-compatible servers reject streaming uploads of
+// unknown length.
+func (u *Uploader) putSpooled(ctx context.Context, client manager.UploadAPIClient, input *s3.PutObjectInput, digest *digestReader) (string, error) {
+	key := aws.ToString(input.Key)
+	sp, err := spool(digest, u.spoolMemoryLimit())
+	if err != nil {
+		return "", err
+	}
+	defer func() {
+		if closeErr := sp.Close(); closeErr != nil {
+			fmt.Fprintf(u.errOut, "Warning: failed to remove spool file: %v\n", closeErr)
+		}
+	}()
+
+	if u.debug && sp.onDisk() {
+		fmt.Fprintf(u.errOut, "[DEBUG] spooled %s to disk (%s)\n", key, output.FormatSize(sp.size))
+	}
+
+	input.Body = sp.reader()
+	input.ContentLength = aws.Int64(sp.size)
+	if sendChecksums {
+		input.ChecksumSHA256 = aws.String(digest.sumBase64())
+	}
+	out, err := client.PutObject(ctx, input)
+	if err != nil {
+		return "", s3errors.Wrap(err)
 	}
+	return aws.ToString(out.ETag), nil
 }
 
 // DryRunProcess processes files through redaction but does not upload them.
@@ -435,6 +950,16 @@ func (u *Uploader) DryRunProcess(ctx context.Context, files []FileUpload) (*Uplo
 	}
 
 	totalFiles := len(files)
+	scanned := redactor.NewStats()
+	var dict codec.Dictionary
+	if u.estimate {
+		result.Compression = u.newEstimate()
+		dict = u.loadDictionary(ctx, files)
+	}
+	out := u.out
+	if u.summaryOnly {
+		out = io.Discard
+	}
 
 	for i, file := range files {
 		fileNum := i + 1
@@ -445,89 +970,146 @@ func (u *Uploader) DryRunProcess(ctx context.Context, files []FileUpload) (*Uplo
 		}
 
 		if file.ShouldSkip {
-			fmt.Printf("[%d/%d] Would skip %s (%s)\n", fileNum, totalFiles, file.LocalPath, file.SkipReason)
+			fmt.Fprintf(out, "[%d/%d] Would skip %s (%s)\n", fileNum, totalFiles, file.LocalPath, file.SkipReason)
 			result.Skipped++
 			continue
 		}
 
-		fmt.Printf("[%d/%d] Processing %s (%s)", fileNum, totalFiles, file.LocalPath, formatSize(file.Size))
+		fmt.Fprintf(out, "[%d/%d] Processing %s (%s)", fileNum, totalFiles, file.LocalPath, output.FormatSize(file.Size))
 
 		// Process file through redaction
-		fileStats, err := u.processFileForStats(ctx, file)
+		fileStats, est, err := u.processFileForStats(ctx, file, dict)
 		if err != nil {
-			fmt.Println() // Complete the line
+			fmt.Fprintln(out) // Complete the line
 			return result, fmt.Errorf("processing %s: %w", file.LocalPath, err)
 		}
+		scanned.Add(fileStats)
 
 		// Display per-file redaction stats
 		if fileStats != nil && fileStats.TotalMatches > 0 {
-			fmt.Printf(" → %s (%.1f%% redacted, %d matches)\n",
-				formatSize(fileStats.RedactedBytes),
+			fmt.Fprintf(out, " → %s (%.1f%% redacted, %d matches)",
+				output.FormatSize(fileStats.RedactedBytes),
 				fileStats.PercentReduction(),
 				fileStats.TotalMatches)
 			result.RedactionStats.Add(fileStats)
 		} else {
-			fmt.Println(" → no redactions")
+			fmt.Fprint(out, " → no redactions")
+		}
+		if est != nil {
+			fmt.Fprintf(out, ", stored %s", formatEstimate(*est))
+			result.Compression.Add(*est)
 		}
+		fmt.Fprintln(out)
 
 		result.Uploaded++ // Count as "would upload"
 		result.UploadedBytes += file.Size
 	}
 
 	// Print summary
-	fmt.Printf("\nDry-run complete: %d would upload (%s), %d would skip\n",
-		result.Uploaded, formatSize(result.UploadedBytes), result.Skipped)
+	fmt.Fprintf(u.out, "\nDry-run complete: %d would upload (%s), %d would skip\n",
+		result.Uploaded, output.FormatSize(result.UploadedBytes), result.Skipped)
 
-	// Print redaction summary if any matches were found
-	if result.RedactionStats != nil && result.RedactionStats.TotalMatches > 0 {
-		fmt.Printf("\nRedaction summary:\n")
-		fmt.Printf("  Total: %s → %s (%.1f%% reduction)\n",
-			formatSize(result.RedactionStats.OriginalBytes),
-			formatSize(result.RedactionStats.RedactedBytes),
-			result.RedactionStats.PercentReduction())
-		fmt.Printf("  Matches: %d total\n", result.RedactionStats.TotalMatches)
+	u.printRedactionSummary(result.RedactionStats)
+	if result.Compression != nil {
+		u.printEstimateSummary(*result.Compression)
+	}
+
+	result.Anomalies = redactor.DetectAnomalies(scanned, nil, u.cfg.Redact.MaxMatchShare)
+	u.printAnomalies(result.Anomalies)
 
-		// Print per-pattern breakdown
-		for _, pc := range result.RedactionStats.PatternSummary() {
-			fmt.Printf("    %s: %d\n", pc.Pattern, pc.Count)
+	return result, nil
+}
+
+// redactionHistory sums the line and match counts recorded in the manifest,
+// giving the usual per-line rate of each pattern.
+func redactionHistory(m *manifest.Manifest) *redactor.Stats {
+	history := redactor.NewStats()
+	for _, entry := range m.Files {
+		history.LinesProcessed += entry.Lines
+		for pattern, count := range entry.Redactions {
+			history.ByPattern[pattern] += count
+			history.TotalMatches += count
 		}
 	}
+	return history
+}
 
-	return result, nil
+// printAnomalies warns about patterns that matched implausibly often.
+func (u *Uploader) printAnomalies(anomalies []redactor.Anomaly) {
+	for _, a := range anomalies {
+		fmt.Fprintf(u.errOut, "Warning: %s\n", a)
+	}
+	if len(anomalies) > 0 {
+		fmt.Fprintln(u.errOut, "Inspect the matches with: cclogs upload --dry-run --debug")
+	}
+}
+
+// jsonLogExtensions are the log extensions whose lines are JSON documents.
+var jsonLogExtensions = []string{".jsonl", ".ndjson", ".json"}
+
+// streamRedact redacts r, the content of the log at path. JSON lines logs have
+// their string values redacted; other logs are redacted as plain text.
+func streamRedact(path string, r io.Reader, debugW io.Writer) (io.Reader, <-chan *redactor.Stats) {
+	if config.HasLogExtension(path, jsonLogExtensions) {
+		return redactor.StreamRedactWithStatsDebug(r, debugW)
+	}
+	return redactor.StreamRedactTextWithStatsDebug(r, debugW)
 }
 
-// processFileForStats reads a file and runs it through redaction to collect stats.
-// The redacted output is discarded; only stats are collected.
-func (u *Uploader) processFileForStats(ctx context.Context, file FileUpload) (*redactor.Stats, error) {
+// processFileForStats reads a file and runs it through redaction to collect
+// stats. The redacted output is discarded, after being compressed to a
+// counter (with dict, for zstd) when estimating compression; nothing is
+// written anywhere.
+func (u *Uploader) processFileForStats(ctx context.Context, file FileUpload, dict codec.Dictionary) (*redactor.Stats, *codec.Estimate, error) {
 	f, err := os.Open(file.LocalPath)
 	if err != nil {
-		return nil, fmt.Errorf("opening file: %w", err)
+		return nil, nil, fmt.Errorf("opening file: %w", err)
 	}
 	defer func() {
 		if closeErr := f.Close(); closeErr != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to close file %s: %v\n", file.LocalPath, closeErr)
+			fmt.Fprintf(u.errOut, "Warning: failed to close file %s: %v\n", file.LocalPath, closeErr)
 		}
 	}()
 
-	if u.noRedact {
-		return nil, nil
+	if u.noRedact && !u.estimate {
+		return nil, nil, nil
 	}
 
-	// Use debug writer if enabled
-	var debugW io.Writer
-	if u.debug {
-		debugW = os.Stderr
+	var reader io.Reader = f
+	var statsCh <-chan *redactor.Stats
+	if !u.noRedact {
+		// Use debug writer if enabled
+		var debugW io.Writer
+		if u.debug {
+			debugW = u.errOut
+		}
+		reader, statsCh = streamRedact(file.LocalPath, f, debugW)
 	}
 
-	// Process through redactor, discard output but collect stats
-	reader, statsCh := redactor.StreamRedactWithStatsDebug(f, debugW)
+	var sink io.Writer = io.Discard
+	var counter *codec.Counter
+	if u.estimate {
+		counter, err = codec.NewCounter(u.estimateCodec(file), u.cfg.Upload.CompressLevel, dict)
+		if err != nil {
+			return nil, nil, err
+		}
+		sink = counter
+	}
 
-	// Discard redacted output
-	if _, err := io.Copy(io.Discard, reader); err != nil {
-		return nil, fmt.Errorf("processing file: %w", err)
+	if _, err := io.Copy(sink, reader); err != nil {
+		return nil, nil, fmt.Errorf("processing file: %w", err)
 	}
 
-	// Wait for stats
-	stats := <-statsCh
-	return stats, nil
+	var stats *redactor.Stats
+	if statsCh != nil {
+		stats = <-statsCh
+	}
+	if counter == nil {
+		return stats, nil, nil
+	}
+	if err := counter.Close(); err != nil {
+		return nil, nil, fmt.Errorf("compressing file: %w", err)
+	}
+	est := counter.Estimate()
+	return stats, &est, nil
 }
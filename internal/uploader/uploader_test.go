@@ -1,13 +1,31 @@
 package uploader
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
 	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/13rac1/cclogs/internal/codec"
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/s3errors"
 	"github.com/13rac1/cclogs/internal/types"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/smithy-go"
 )
 
 func TestComputeS3Key(t *testing.T) {
@@ -302,6 +320,163 @@ func TestDiscoverFilesCaseInsensitiveExtension(t *testing.T) {
 	}
 }
 
+func TestDiscoverFilesExtensions(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "test-project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.jsonl", "b.NDJSON", "c.log", "d.txt", "e.json"} {
+		if err := os.WriteFile(filepath.Join(projectDir, name), []byte("test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: tmpDir, Extensions: []string{".jsonl", ".ndjson", ".log"}},
+	}
+	discovered, err := New(cfg, nil, true, false).DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+
+	var got []string
+	for _, f := range discovered {
+		got = append(got, filepath.Base(f.LocalPath))
+	}
+	sort.Strings(got)
+	if want := []string{"a.jsonl", "b.NDJSON", "c.log"}; !slices.Equal(got, want) {
+		t.Errorf("discovered %v, want %v", got, want)
+	}
+}
+
+func TestDiscoverFilesProjectDepth(t *testing.T) {
+	tmpDir := t.TempDir()
+	for _, name := range []string{"client/app/s.jsonl", "client/app/sub/t.jsonl", "other/lib/u.jsonl", "client/loose.jsonl"} {
+		path := filepath.Join(tmpDir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: tmpDir, ProjectDepth: 2},
+		S3:    types.S3Config{Prefix: "claude-code/"},
+	}
+	discovered, err := New(cfg, nil, true, false).DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+
+	got := make(map[string]string)
+	for _, f := range discovered {
+		got[f.S3Key] = f.ProjectDir
+	}
+	want := map[string]string{
+		"claude-code/client/app/s.jsonl":     "client/app",
+		"claude-code/client/app/sub/t.jsonl": "client/app",
+		"claude-code/other/lib/u.jsonl":      "other/lib",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("discovered %v, want %v", got, want)
+	}
+	for key, project := range want {
+		if got[key] != project {
+			t.Errorf("%s: ProjectDir = %q, want %q", key, got[key], project)
+		}
+	}
+}
+
+func TestUpload_TextLogRedaction(t *testing.T) {
+	// Valid JSON on purpose: the JSON path would re-encode it compactly
+	const line = `{"z": 1, "key": "AKIA1234567890123456"}`
+
+	tests := []struct {
+		name     string
+		file     string
+		wantJSON bool
+	}{
+		{"jsonl is parsed", "session.jsonl", true},
+		{"ndjson is parsed", "session.ndjson", true},
+		{"log is plain text", "session.log", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), tt.file)
+			if err := os.WriteFile(path, []byte(line+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := &types.Config{Upload: types.UploadConfig{PartSize: 5 << 20, PartConcurrency: 1}}
+			client := newMockS3()
+			u := newUploader(cfg, client, false, false)
+			u.SetOutput(io.Discard, io.Discard)
+
+			key := "p/" + tt.file
+			file := FileUpload{LocalPath: path, S3Key: key, Size: int64(len(line) + 1), ProjectDir: "p"}
+			if _, err := u.Upload(context.Background(), []FileUpload{file}); err != nil {
+				t.Fatalf("Upload failed: %v", err)
+			}
+
+			body, _ := client.object(key)
+			if strings.Contains(string(body), "AKIA1234567890123456") {
+				t.Errorf("secret not redacted: %s", body)
+			}
+			if gotJSON := strings.HasPrefix(string(body), `{"key":`); gotJSON != tt.wantJSON {
+				t.Errorf("uploaded %q, parsed as JSON = %v, want %v", body, gotJSON, tt.wantJSON)
+			}
+		})
+	}
+}
+
+func TestUpload_RecordsRedactionTags(t *testing.T) {
+	dir := t.TempDir()
+	lines := map[string]string{
+		"secret.jsonl": `{"key": "AKIA1234567890123456", "to": "dev@corp.io"}`,
+		"clean.jsonl":  `{"msg": "hello"}`,
+	}
+	var files []FileUpload
+	for name, line := range lines {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(line+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files = append(files, FileUpload{LocalPath: path, S3Key: "claude-code/p/" + name, Size: info.Size(), ModTime: info.ModTime(), ProjectDir: "p"})
+	}
+
+	cfg := &types.Config{
+		S3:     types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+		Upload: types.UploadConfig{PartSize: 5 << 20, PartConcurrency: 1},
+	}
+	client := newMockS3()
+	u := newUploader(cfg, client, false, false)
+	u.SetOutput(io.Discard, io.Discard)
+	if _, err := u.Upload(context.Background(), files); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	m, err := manifest.Load(context.Background(), client, "test-bucket", "claude-code/.manifest.json", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := m.Files["claude-code/p/secret.jsonl"].Tags(), []string{"AWS_KEY", "EMAIL"}; !slices.Equal(got, want) {
+		t.Errorf("secret.jsonl tags = %v, want %v", got, want)
+	}
+	if got := m.Files["claude-code/p/clean.jsonl"].Tags(); len(got) != 0 {
+		t.Errorf("clean.jsonl tags = %v, want none", got)
+	}
+	if got, want := m.FindTags([]string{"aws_key"}), []string{"claude-code/p/secret.jsonl"}; !slices.Equal(got, want) {
+		t.Errorf("FindTags(aws_key) = %v, want %v", got, want)
+	}
+}
+
 // TestUpload validates the upload logic with skip behavior.
 // Note: This test focuses on the skip logic and result aggregation.
 // Actual S3 upload testing would require integration tests with a mock S3 server.
@@ -329,7 +504,8 @@ func TestUpload_SkipLogic(t *testing.T) {
 	cfg := &types.Config{
 		S3: types.S3Config{Bucket: "test-bucket"},
 	}
-	uploader := New(cfg, nil, true, false)
+	client := newMockS3()
+	uploader := newUploader(cfg, client, true, false)
 
 	// All files are marked to skip, so no actual upload should be attempted
 	result, err := uploader.Upload(context.Background(), files)
@@ -337,6 +513,11 @@ func TestUpload_SkipLogic(t *testing.T) {
 		t.Fatalf("Upload failed: %v", err)
 	}
 
+	// Nothing uploaded means the manifest is not rewritten either
+	if keys := client.putKeys(); len(keys) != 0 {
+		t.Errorf("expected no writes, got %v", keys)
+	}
+
 	if result.Uploaded != 0 {
 		t.Errorf("expected 0 files uploaded, got %d", result.Uploaded)
 	}
@@ -348,47 +529,1237 @@ func TestUpload_SkipLogic(t *testing.T) {
 	}
 }
 
-func TestUpload_Empty(t *testing.T) {
+func TestUpload_CustomManifestKey(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "project"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "project", "a.jsonl"), []byte(`{"n":1}`+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
 	cfg := &types.Config{
-		S3: types.S3Config{Bucket: "test-bucket"},
+		Local: types.LocalConfig{ProjectsRoot: tmpDir},
+		S3:    types.S3Config{Bucket: "test-bucket", Prefix: "logs/", ManifestKey: "cclogs-v2.json"},
 	}
-	uploader := New(cfg, nil, true, false)
+	client := newMockS3()
+	u := newUploader(cfg, client, true, false)
 
-	result, err := uploader.Upload(context.Background(), []FileUpload{})
+	files, err := u.DiscoverFiles(context.Background())
 	if err != nil {
-		t.Fatalf("Upload of empty list failed: %v", err)
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+	if _, err := u.Upload(context.Background(), files); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if _, ok := client.object("logs/cclogs-v2.json"); !ok {
+		t.Fatalf("manifest not written to logs/cclogs-v2.json; writes: %v", client.putKeys())
+	}
+	if _, ok := client.object("logs/.manifest.json"); ok {
+		t.Error("manifest also written to the default key")
 	}
 
-	if result.Uploaded != 0 {
-		t.Errorf("expected 0 files uploaded, got %d", result.Uploaded)
+	// The next run reads the same manifest and finds nothing to upload
+	files, err = newUploader(cfg, client, true, false).DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
 	}
-	if result.Skipped != 0 {
-		t.Errorf("expected 0 files skipped, got %d", result.Skipped)
+	if len(files) != 1 || !files[0].ShouldSkip {
+		t.Errorf("second discovery = %+v, want the file skipped as unchanged", files)
 	}
 }
 
-func TestUpload_ContextCancelled(t *testing.T) {
+func TestUpload_MixedSkipAndUpload(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{
+		"unchanged.jsonl": "{\"n\":1}\n",
+		"new.jsonl":       "{\"email\":\"user@company.com\"}\n",
+		"empty.jsonl":     "",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(projectDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
 	cfg := &types.Config{
-		S3: types.S3Config{Bucket: "test-bucket"},
+		Local:    types.LocalConfig{ProjectsRoot: tmpDir},
+		S3:       types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+		Identity: types.Identity{Hostname: "laptop"},
 	}
-	uploader := New(cfg, nil, true, false)
+	client := newMockS3()
 
-	files := []FileUpload{
+	// Seed the manifest so unchanged.jsonl is already up to date
+	info, err := os.Stat(filepath.Join(projectDir, "unchanged.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	seed := manifest.New()
+	seed.Files["claude-code/project/unchanged.jsonl"] = manifest.FileEntry{
+		Mtime: info.ModTime().UTC(),
+		Size:  info.Size(),
+	}
+	if err := manifest.Save(context.Background(), client, "test-bucket", "claude-code/.manifest.json", seed, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	u := newUploader(cfg, client, false, false)
+	discovered, err := u.DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+
+	result, err := u.Upload(context.Background(), discovered)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	if result.Uploaded != 1 || result.Skipped != 2 {
+		t.Errorf("uploaded=%d skipped=%d, want 1 and 2", result.Uploaded, result.Skipped)
+	}
+	if want := int64(len(files["new.jsonl"])); result.UploadedBytes != want {
+		t.Errorf("UploadedBytes = %d, want %d", result.UploadedBytes, want)
+	}
+	if result.RedactionStats.TotalMatches == 0 {
+		t.Error("expected redaction matches in summary")
+	}
+
+	// Seed write, then the new object, then the updated manifest
+	wantPuts := []string{
+		"claude-code/.manifest.json",
+		"claude-code/project/new.jsonl",
+		"claude-code/.manifest.json",
+	}
+	if got := client.putKeys(); !slices.Equal(got, wantPuts) {
+		t.Errorf("puts = %v, want %v", got, wantPuts)
+	}
+
+	body, _ := client.object("claude-code/project/new.jsonl")
+	if strings.Contains(string(body), "user@company.com") {
+		t.Errorf("uploaded body was not redacted: %q", body)
+	}
+
+	m, err := manifest.Load(context.Background(), client, "test-bucket", "claude-code/.manifest.json", 0)
+	if err != nil {
+		t.Fatalf("loading manifest: %v", err)
+	}
+	entry, ok := m.Files["claude-code/project/new.jsonl"]
+	if !ok {
+		t.Fatal("manifest missing uploaded file")
+	}
+	sum := sha256.Sum256(body)
+	if entry.SHA256 != hex.EncodeToString(sum[:]) || entry.UploadedSize != int64(len(body)) {
+		t.Errorf("manifest entry = %+v, want digest of uploaded body", entry)
+	}
+	if entry.ETag != fmt.Sprintf(`"%x"`, md5.Sum(body)) || entry.Host != "laptop" || entry.UploadedAt.IsZero() {
+		t.Errorf("manifest entry = %+v, want ETag, host, and upload time", entry)
+	}
+	if _, ok := m.Files["claude-code/project/unchanged.jsonl"]; !ok {
+		t.Error("manifest dropped the unchanged entry")
+	}
+	if _, ok := m.Files["claude-code/project/empty.jsonl"]; ok {
+		t.Error("manifest recorded a skipped empty file")
+	}
+}
+
+func TestUpload_MultipartThroughSeam(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "big.jsonl")
+	content := strings.Repeat("x", 5<<20+10)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &types.Config{
+		S3:     types.S3Config{Bucket: "test-bucket"},
+		Upload: types.UploadConfig{PartSize: 5 << 20, PartConcurrency: 1},
+	}
+	client := newMockS3()
+	u := newUploader(cfg, client, true, false)
+
+	file := FileUpload{LocalPath: path, S3Key: "p/big.jsonl", Size: info.Size(), ModTime: info.ModTime().UTC(), ProjectDir: "p"}
+	if _, err := u.Upload(context.Background(), []FileUpload{file}); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	body, ok := client.object("p/big.jsonl")
+	if !ok || string(body) != content {
+		t.Errorf("multipart object not assembled correctly (%d bytes)", len(body))
+	}
+	if keys := client.objectKeys(); !slices.Equal(keys, []string{".manifest.json", "p/big.jsonl"}) {
+		t.Errorf("objects = %v", keys)
+	}
+}
+
+func TestUpload_ContentHeaders(t *testing.T) {
+	const partSize = 5 << 20
+
+	tests := []struct {
+		name  string
+		size  int
+		s3cfg types.S3Config
+		codec codec.Codec
+		want  objectHeaders
+	}{
 		{
-			LocalPath:  "/fake/path.jsonl",
-			S3Key:      "test.jsonl",
-			Size:       4,
-			ProjectDir: "test-project",
-			ShouldSkip: false,
+			name:  "default content type",
+			size:  100,
+			s3cfg: types.S3Config{Bucket: "test-bucket"},
+			want:  objectHeaders{ContentType: "application/x-ndjson"},
+		},
+		{
+			name: "configured content type and cache control",
+			size: 100,
+			s3cfg: types.S3Config{
+				Bucket:       "test-bucket",
+				ContentType:  "application/jsonl",
+				CacheControl: "private, max-age=86400",
+			},
+			want: objectHeaders{ContentType: "application/jsonl", CacheControl: "private, max-age=86400"},
+		},
+		{
+			name:  "compressed object",
+			size:  100,
+			s3cfg: types.S3Config{Bucket: "test-bucket"},
+			codec: codec.Gzip,
+			want:  objectHeaders{ContentType: "application/x-ndjson", ContentEncoding: "gzip"},
+		},
+		{
+			name:  "multipart upload",
+			size:  partSize + 1,
+			s3cfg: types.S3Config{Bucket: "test-bucket", CacheControl: "no-cache"},
+			want:  objectHeaders{ContentType: "application/x-ndjson", CacheControl: "no-cache"},
 		},
 	}
 
-	// Cancel context before upload
-	ctx, cancel := context.WithCancel(context.Background())
-	cancel()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "session.jsonl")
+			if err := os.WriteFile(path, []byte(strings.Repeat("x", tt.size)), 0644); err != nil {
+				t.Fatal(err)
+			}
 
-	_, err := uploader.Upload(ctx, files)
-	if err == nil {
-		t.Fatal("expected error for cancelled context, got nil")
+			cfg := &types.Config{
+				S3:     tt.s3cfg,
+				Upload: types.UploadConfig{PartSize: partSize, PartConcurrency: 1},
+			}
+			client := newMockS3()
+			u := newUploader(cfg, client, true, false)
+
+			file := FileUpload{LocalPath: path, S3Key: "p/session.jsonl", Size: int64(tt.size), ProjectDir: "p", Codec: tt.codec}
+			if _, err := u.Upload(context.Background(), []FileUpload{file}); err != nil {
+				t.Fatalf("Upload failed: %v", err)
+			}
+
+			if got := client.header("p/session.jsonl"); got != tt.want {
+				t.Errorf("headers = %+v, want %+v", got, tt.want)
+			}
+			if got := client.header(".manifest.json").ContentType; got != "application/json" {
+				t.Errorf("manifest Content-Type = %q, want application/json", got)
+			}
+		})
+	}
+}
+
+func TestUpload_AccessDenied(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := os.WriteFile(path, []byte("{\"n\":1}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client := newMockS3()
+	client.failPut = func(key string) bool { return key == "p/session.jsonl" }
+	client.putErr = &smithy.GenericAPIError{Code: "AccessDenied", Message: "Access Denied"}
+	u := newUploader(&types.Config{S3: types.S3Config{Bucket: "test-bucket"}}, client, true, false)
+
+	file := FileUpload{LocalPath: path, S3Key: "p/session.jsonl", Size: 8, ProjectDir: "p"}
+	result, err := u.Upload(context.Background(), []FileUpload{file})
+	if !errors.Is(err, ErrPartialFailure) {
+		t.Fatalf("Upload error = %v, want ErrPartialFailure", err)
+	}
+	if len(result.Failures) != 1 || !errors.Is(result.Failures[0].Err, s3errors.ErrAccessDenied) {
+		t.Errorf("failures = %+v, want one matching ErrAccessDenied", result.Failures)
+	}
+}
+
+func TestUpload_ObjectLock(t *testing.T) {
+	const partSize = 5 << 20
+
+	for _, size := range []int{100, partSize + 1} {
+		t.Run(fmt.Sprintf("%d bytes", size), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "session.jsonl")
+			if err := os.WriteFile(path, []byte(strings.Repeat("x", size)), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := &types.Config{
+				S3: types.S3Config{
+					Bucket:     "test-bucket",
+					ObjectLock: types.ObjectLockConfig{Mode: "COMPLIANCE", RetainDays: 30},
+				},
+				Upload: types.UploadConfig{PartSize: partSize, PartConcurrency: 1},
+			}
+			client := newMockS3()
+			u := newUploader(cfg, client, true, false)
+
+			before := time.Now().UTC()
+			file := FileUpload{LocalPath: path, S3Key: "p/session.jsonl", Size: int64(size), ProjectDir: "p"}
+			if _, err := u.Upload(context.Background(), []FileUpload{file}); err != nil {
+				t.Fatalf("Upload failed: %v", err)
+			}
+
+			got := client.header("p/session.jsonl")
+			if got.ObjectLockMode != "COMPLIANCE" {
+				t.Errorf("ObjectLockMode = %q, want COMPLIANCE", got.ObjectLockMode)
+			}
+			if min, max := before.AddDate(0, 0, 30), time.Now().UTC().AddDate(0, 0, 30); got.RetainUntil.Before(min) || got.RetainUntil.After(max) {
+				t.Errorf("RetainUntil = %v, want 30 days from now", got.RetainUntil)
+			}
+			if m := client.header(".manifest.json"); m.ObjectLockMode != "" || !m.RetainUntil.IsZero() {
+				t.Errorf("manifest was locked: %+v", m)
+			}
+		})
+	}
+}
+
+func TestUpload_ObjectLockHeaders(t *testing.T) {
+	tmpDir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(tmpDir, "project"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, "project", "a.jsonl"), []byte("{\"n\":1}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, fake := newFakeS3(t)
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: tmpDir},
+		S3: types.S3Config{
+			Bucket:     "test-bucket",
+			Prefix:     "claude-code/",
+			ObjectLock: types.ObjectLockConfig{Mode: "GOVERNANCE", RetainDays: 1},
+		},
+	}
+	u := New(cfg, client, true, false)
+	u.SetOutput(io.Discard, io.Discard)
+
+	files, err := u.DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+	if _, err := u.Upload(context.Background(), files); err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+
+	h := fake.header("claude-code/project/a.jsonl")
+	if got := h.Get("X-Amz-Object-Lock-Mode"); got != "GOVERNANCE" {
+		t.Errorf("x-amz-object-lock-mode = %q, want GOVERNANCE", got)
+	}
+	until, err := time.Parse(time.RFC3339, h.Get("X-Amz-Object-Lock-Retain-Until-Date"))
+	if err != nil {
+		t.Fatalf("x-amz-object-lock-retain-until-date: %v", err)
+	}
+	if d := time.Until(until); d < 23*time.Hour || d > 25*time.Hour {
+		t.Errorf("retain-until is %v away, want a day", d)
+	}
+
+	m := fake.header("claude-code/.manifest.json")
+	if m == nil {
+		t.Fatal("manifest was not written")
+	}
+	if got := m.Get("X-Amz-Object-Lock-Mode"); got != "" {
+		t.Errorf("manifest x-amz-object-lock-mode = %q, want none", got)
+	}
+}
+
+func TestUpload_RedactionAnomalies(t *testing.T) {
+	emailLines := strings.Repeat("{\"msg\":\"mail user@company.com\"}\n", 20)
+	plainLines := strings.Repeat("{\"msg\":\"hello\"}\n", 20)
+
+	tests := []struct {
+		name     string
+		content  string
+		maxShare float64
+		history  map[string]int64 // EMAIL matches over 10000 earlier lines
+		want     []string         // "pattern:reason"
+	}{
+		{
+			name:     "no matches",
+			content:  plainLines,
+			maxShare: 0.2,
+		},
+		{
+			name:     "share of lines",
+			content:  emailLines,
+			maxShare: 0.2,
+			want:     []string{"EMAIL:share"},
+		},
+		{
+			name:     "jump over manifest history",
+			content:  emailLines,
+			maxShare: 2,
+			history:  map[string]int64{"EMAIL": 10},
+			want:     []string{"EMAIL:jump"},
+		},
+		{
+			name:     "consistent with manifest history",
+			content:  emailLines,
+			maxShare: 2,
+			history:  map[string]int64{"EMAIL": 9000},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "session.jsonl")
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cfg := &types.Config{
+				S3:     types.S3Config{Bucket: "test-bucket"},
+				Redact: types.RedactConfig{MaxMatchShare: tt.maxShare},
+			}
+			client := newMockS3()
+			if tt.history != nil {
+				seed := manifest.New()
+				seed.Files["p/old.jsonl"] = manifest.FileEntry{Lines: 10000, Redactions: tt.history}
+				if err := manifest.Save(context.Background(), client, "test-bucket", ".manifest.json", seed, 0); err != nil {
+					t.Fatal(err)
+				}
+			}
+			u := newUploader(cfg, client, false, false)
+
+			file := FileUpload{LocalPath: path, S3Key: "p/session.jsonl", Size: info.Size(), ModTime: info.ModTime().UTC(), ProjectDir: "p"}
+			result, err := u.Upload(context.Background(), []FileUpload{file})
+			if err != nil {
+				t.Fatalf("Upload failed: %v", err)
+			}
+
+			var got []string
+			for _, a := range result.Anomalies {
+				got = append(got, a.Pattern+":"+a.Reason)
+			}
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("anomalies = %v, want %v", got, tt.want)
+			}
+
+			// Counts are recorded for the next run's comparison
+			m, err := manifest.Load(context.Background(), client, "test-bucket", ".manifest.json", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if entry := m.Files["p/session.jsonl"]; entry.Lines != 20 {
+				t.Errorf("manifest lines = %d, want 20", entry.Lines)
+			}
+		})
+	}
+}
+
+func TestUpload_Output(t *testing.T) {
+	a := FileUpload{LocalPath: "/logs/p/a.jsonl", S3Key: "claude-code/p/a.jsonl", Size: 2048}
+	b := FileUpload{LocalPath: "/logs/p/b.jsonl", S3Key: "claude-code/p/b.jsonl", Size: 100}
+	skipped := FileUpload{LocalPath: "/logs/p/old.jsonl", S3Key: "claude-code/p/old.jsonl", Size: 10, ShouldSkip: true, SkipReason: "unchanged"}
+
+	tests := []struct {
+		name      string
+		files     []FileUpload
+		cancelled bool
+		want      string
+	}{
+		{
+			name:  "all uploaded",
+			files: []FileUpload{a, b},
+			want: `[1/2] Uploading /logs/p/a.jsonl (2.0 KB)
+[2/2] Uploading /logs/p/b.jsonl (100 B)
+
+Upload complete: 2 uploaded (2.1 KB), 0 skipped, 0 failed
+`,
+		},
+		{
+			name:  "skips and uploads",
+			files: []FileUpload{skipped, b},
+			want: `[1/2] Skipping /logs/p/old.jsonl (unchanged)
+[2/2] Uploading /logs/p/b.jsonl (100 B)
+
+Upload complete: 1 uploaded (100 B), 1 skipped, 0 failed
+`,
+		},
+		{
+			name:      "interrupted before any file",
+			files:     []FileUpload{a, b},
+			cancelled: true,
+			want: `
+Upload interrupted: 0 of 2 uploaded (0 B), 0 skipped, 0 failed, 2 not processed
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelled {
+				cancel()
+			}
+
+			var out, errOut bytes.Buffer
+			u := New(&types.Config{}, nil, true, false)
+			u.SetOutput(&out, &errOut)
+
+			_, err := u.Upload(ctx, tt.files)
+			if (err != nil) != tt.cancelled {
+				t.Fatalf("Upload error = %v, cancelled = %v", err, tt.cancelled)
+			}
+			if got := out.String(); got != tt.want {
+				t.Errorf("output:\n%s\nwant:\n%s", got, tt.want)
+			}
+			if errOut.Len() != 0 {
+				t.Errorf("unexpected error output: %q", errOut.String())
+			}
+		})
+	}
+}
+
+func TestUpload_ContinueOnError(t *testing.T) {
+	tests := []struct {
+		name         string
+		failFast     bool
+		wantUploaded int
+		wantManifest []string
+	}{
+		{
+			name:         "continues past failures",
+			wantUploaded: 2,
+			wantManifest: []string{"claude-code/project/a.jsonl", "claude-code/project/c.jsonl"},
+		},
+		{
+			name:         "fail fast stops at first failure",
+			failFast:     true,
+			wantUploaded: 1,
+			wantManifest: []string{"claude-code/project/a.jsonl"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			projectDir := filepath.Join(tmpDir, "project")
+			if err := os.MkdirAll(projectDir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			for _, name := range []string{"a.jsonl", "b.jsonl", "c.jsonl"} {
+				if err := os.WriteFile(filepath.Join(projectDir, name), []byte("{\"n\":1}\n"), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			client := newMockS3()
+			client.failPut = func(key string) bool { return strings.HasSuffix(key, "/b.jsonl") }
+
+			cfg := &types.Config{
+				Local: types.LocalConfig{ProjectsRoot: tmpDir},
+				S3:    types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+			}
+			u := newUploader(cfg, client, false, false)
+			u.SetFailFast(tt.failFast)
+
+			files, err := u.DiscoverFiles(context.Background())
+			if err != nil {
+				t.Fatalf("DiscoverFiles failed: %v", err)
+			}
+			if err := SortFiles(files, OrderName); err != nil {
+				t.Fatal(err)
+			}
+
+			result, err := u.Upload(context.Background(), files)
+			if !errors.Is(err, ErrPartialFailure) {
+				t.Fatalf("Upload error = %v, want ErrPartialFailure", err)
+			}
+			if result.Uploaded != tt.wantUploaded {
+				t.Errorf("Uploaded = %d, want %d", result.Uploaded, tt.wantUploaded)
+			}
+			if len(result.Failures) != 1 {
+				t.Fatalf("Failures = %+v, want one", result.Failures)
+			}
+			f := result.Failures[0]
+			if f.Key != "claude-code/project/b.jsonl" || f.Path != filepath.Join(projectDir, "b.jsonl") || f.Err == nil {
+				t.Errorf("Failure = %+v", f)
+			}
+
+			m, err := manifest.Load(context.Background(), client, "test-bucket", "claude-code/.manifest.json", 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for key := range m.Files {
+				got = append(got, key)
+			}
+			sort.Strings(got)
+			if !slices.Equal(got, tt.wantManifest) {
+				t.Errorf("manifest keys = %v, want %v", got, tt.wantManifest)
+			}
+		})
+	}
+}
+
+func TestUpload_NoManifest(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	local := map[string]string{
+		"same.jsonl":     "{\"n\":1}\n",
+		"changed.jsonl":  "{\"n\":22}\n",
+		"new.jsonl":      "{\"n\":3}\n",
+		"redacted.jsonl": "{\"email\":\"user@company.com\"}\n",
+	}
+	for name, content := range local {
+		if err := os.WriteFile(filepath.Join(projectDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client := newMockS3()
+	put := func(key, body string, meta map[string]string) {
+		if _, err := client.PutObject(context.Background(), &s3.PutObjectInput{
+			Key:      aws.String(key),
+			Body:     strings.NewReader(body),
+			Metadata: meta,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	put("claude-code/project/same.jsonl", local["same.jsonl"], nil)
+	put("claude-code/project/changed.jsonl", "{}\n", nil)
+	// Redaction shrank the object; the recorded source size still matches
+	put("claude-code/project/redacted.jsonl", "{}\n", map[string]string{
+		sourceSizeMetadata: strconv.Itoa(len(local["redacted.jsonl"])),
+	})
+	// changed.jsonl was written to after its upload
+	later := time.Now().Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(projectDir, "changed.jsonl"), later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: tmpDir},
+		S3:    types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+	}
+	u := newUploader(cfg, client, false, false)
+	u.SetNoManifest(true)
+
+	files, err := u.DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+
+	wantSkip := map[string]bool{"same.jsonl": true, "changed.jsonl": false, "new.jsonl": false, "redacted.jsonl": true}
+	for _, f := range files {
+		should, err := ShouldUpload(context.Background(), client, "test-bucket", f.S3Key, f.Size, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.ShouldSkip == should {
+			t.Errorf("%s: ShouldSkip = %v but ShouldUpload = %v", f.S3Key, f.ShouldSkip, should)
+		}
+		if name := filepath.Base(f.LocalPath); f.ShouldSkip != wantSkip[name] {
+			t.Errorf("%s: ShouldSkip = %v, want %v", name, f.ShouldSkip, wantSkip[name])
+		}
+	}
+
+	result, err := u.Upload(context.Background(), files)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if result.Uploaded != 2 || result.Skipped != 2 {
+		t.Errorf("uploaded=%d skipped=%d, want 2 and 2", result.Uploaded, result.Skipped)
+	}
+
+	for _, call := range client.readLog() {
+		if strings.HasSuffix(call, ".manifest.json") {
+			t.Errorf("unexpected manifest read: %s", call)
+		}
+	}
+	for _, key := range client.putKeys() {
+		if strings.HasSuffix(key, ".manifest.json") {
+			t.Errorf("unexpected manifest write: %s", key)
+		}
+	}
+
+	// Uploaded objects record their source size, so the next run skips them
+	files, err = u.DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+	for _, f := range files {
+		if !f.ShouldSkip {
+			t.Errorf("%s not skipped on second run", f.S3Key)
+		}
+	}
+}
+
+func TestUpload_Empty(t *testing.T) {
+	cfg := &types.Config{
+		S3: types.S3Config{Bucket: "test-bucket"},
+	}
+	uploader := New(cfg, nil, true, false)
+
+	result, err := uploader.Upload(context.Background(), []FileUpload{})
+	if err != nil {
+		t.Fatalf("Upload of empty list failed: %v", err)
+	}
+
+	if result.Uploaded != 0 {
+		t.Errorf("expected 0 files uploaded, got %d", result.Uploaded)
+	}
+	if result.Skipped != 0 {
+		t.Errorf("expected 0 files skipped, got %d", result.Skipped)
+	}
+}
+
+func TestUpload_ContextCancelled(t *testing.T) {
+	cfg := &types.Config{
+		S3: types.S3Config{Bucket: "test-bucket"},
+	}
+	uploader := New(cfg, nil, true, false)
+
+	files := []FileUpload{
+		{
+			LocalPath:  "/fake/path.jsonl",
+			S3Key:      "test.jsonl",
+			Size:       4,
+			ProjectDir: "test-project",
+			ShouldSkip: false,
+		},
+	}
+
+	// Cancel context before upload
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := uploader.Upload(ctx, files)
+	if err == nil {
+		t.Fatal("expected error for cancelled context, got nil")
+	}
+}
+
+func TestDiscoverFilesDeduplicatesSymlinks(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	projectA := filepath.Join(tmpDir, "project-a")
+	projectB := filepath.Join(tmpDir, "project-b")
+	for _, dir := range []string{projectA, projectB} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	original := filepath.Join(projectA, "session.jsonl")
+	if err := os.WriteFile(original, []byte("{\"a\":1}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// project-b contains a symlink and a hard link to the same session file
+	if err := os.Symlink(original, filepath.Join(projectB, "alias.jsonl")); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+	if err := os.Link(original, filepath.Join(projectB, "hardlink.jsonl")); err != nil {
+		t.Skipf("hard links not supported: %v", err)
+	}
+
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: tmpDir},
+		S3:    types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+	}
+
+	u := New(cfg, nil, true, false)
+	files, err := u.DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+
+	if len(files) != 3 {
+		t.Fatalf("expected 3 discovered files, got %d", len(files))
+	}
+
+	wantReason := "duplicate of claude-code/project-a/session.jsonl"
+	for _, f := range files {
+		if f.S3Key == "claude-code/project-a/session.jsonl" {
+			if f.ShouldSkip {
+				t.Errorf("first occurrence %s should not be skipped", f.S3Key)
+			}
+			continue
+		}
+		if !f.ShouldSkip {
+			t.Errorf("%s should be skipped as duplicate", f.S3Key)
+		}
+		if f.SkipReason != wantReason {
+			t.Errorf("%s SkipReason = %q, want %q", f.S3Key, f.SkipReason, wantReason)
+		}
+	}
+
+	result, err := u.Upload(context.Background(), files)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if result.Uploaded != 1 {
+		t.Errorf("expected 1 file uploaded, got %d", result.Uploaded)
+	}
+	if result.Skipped != 2 {
+		t.Errorf("expected 2 files skipped, got %d", result.Skipped)
+	}
+}
+
+func TestUpload_InterruptedSavesManifest(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.jsonl", "b.jsonl"} {
+		if err := os.WriteFile(filepath.Join(projectDir, name), []byte("{\"n\":1}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client, fake := newFakeS3(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Simulate Ctrl+C while the first file is being uploaded
+	fake.onPut = func(key string) {
+		if key == "claude-code/project/a.jsonl" {
+			cancel()
+		}
+	}
+
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: tmpDir},
+		S3:    types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+	}
+	u := New(cfg, client, true, false)
+	var out bytes.Buffer
+	u.SetOutput(&out, io.Discard)
+
+	files, err := u.DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].S3Key < files[j].S3Key })
+
+	result, err := u.Upload(ctx, files)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Upload() error = %v, want wrapped context.Canceled", err)
+	}
+	if result.Uploaded != 1 {
+		t.Errorf("Uploaded = %d, want 1", result.Uploaded)
+	}
+	if want := "Upload interrupted: 1 of 2 uploaded"; !strings.Contains(out.String(), want) {
+		t.Errorf("output missing %q:\n%s", want, out.String())
+	}
+	if !strings.Contains(out.String(), "manifest saved") {
+		t.Errorf("output does not report the manifest save:\n%s", out.String())
+	}
+
+	if _, ok := fake.object("claude-code/project/b.jsonl"); ok {
+		t.Error("second file should not be uploaded after cancellation")
+	}
+
+	m, err := manifest.Load(context.Background(), client, "test-bucket", "claude-code/.manifest.json", 0)
+	if err != nil {
+		t.Fatalf("loading saved manifest: %v", err)
+	}
+	if _, ok := m.Files["claude-code/project/a.jsonl"]; !ok {
+		t.Error("manifest missing entry for file completed before cancellation")
+	}
+	if _, ok := m.Files["claude-code/project/b.jsonl"]; ok {
+		t.Error("manifest has entry for file never uploaded")
+	}
+}
+
+func TestDiscoverFilesByHostLayout(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "my-app")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "session.jsonl"), []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: tmpDir, MachineID: "work-laptop"},
+		S3:    types.S3Config{Prefix: "claude-code/", KeyLayout: "by_host"},
+	}
+
+	files, err := New(cfg, nil, true, false).DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(files))
+	}
+	if want := "claude-code/work-laptop/my-app/session.jsonl"; files[0].S3Key != want {
+		t.Errorf("S3Key = %q, want %q", files[0].S3Key, want)
+	}
+}
+
+func TestDiscoverFilesKeyTemplate(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "my-app")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(projectDir, "session.jsonl")
+	if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2025, 11, 2, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: tmpDir},
+		S3:    types.S3Config{Prefix: "claude-code/", KeyTemplate: "claude/{year}/{month}/{project}/{filename}"},
+	}
+
+	files, err := New(cfg, nil, true, false).DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(files))
+	}
+	if want := "claude/2025/11/my-app/session.jsonl"; files[0].S3Key != want {
+		t.Errorf("S3Key = %q, want %q", files[0].S3Key, want)
+	}
+}
+
+func TestDiscoverFilesSkipsEmpty(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "empty.jsonl"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		uploadEmpty bool
+		wantSkip    bool
+	}{
+		{name: "skipped by default", uploadEmpty: false, wantSkip: true},
+		{name: "uploaded when configured", uploadEmpty: true, wantSkip: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &types.Config{
+				Local:  types.LocalConfig{ProjectsRoot: tmpDir},
+				S3:     types.S3Config{Prefix: "claude-code/"},
+				Upload: types.UploadConfig{UploadEmpty: tt.uploadEmpty},
+			}
+
+			files, err := New(cfg, nil, true, false).DiscoverFiles(context.Background())
+			if err != nil {
+				t.Fatalf("DiscoverFiles failed: %v", err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("expected 1 file, got %d", len(files))
+			}
+			if files[0].ShouldSkip != tt.wantSkip {
+				t.Errorf("ShouldSkip = %v, want %v", files[0].ShouldSkip, tt.wantSkip)
+			}
+			if tt.wantSkip && files[0].SkipReason != "empty" {
+				t.Errorf("SkipReason = %q, want %q", files[0].SkipReason, "empty")
+			}
+		})
+	}
+}
+
+func TestDiscoverFilesSince(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(projectDir, "session.jsonl")
+	if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	mtime := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		since    time.Time
+		wantSkip bool
+	}{
+		{name: "no filter", since: time.Time{}, wantSkip: false},
+		{name: "modified after cutoff", since: mtime.Add(-time.Hour), wantSkip: false},
+		{name: "modified at cutoff", since: mtime, wantSkip: false},
+		{name: "modified before cutoff", since: mtime.Add(time.Second), wantSkip: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &types.Config{
+				Local: types.LocalConfig{ProjectsRoot: tmpDir},
+				S3:    types.S3Config{Prefix: "claude-code/"},
+			}
+			u := New(cfg, nil, true, false)
+			u.SetSince(tt.since)
+
+			files, err := u.DiscoverFiles(context.Background())
+			if err != nil {
+				t.Fatalf("DiscoverFiles failed: %v", err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("expected 1 file, got %d", len(files))
+			}
+			if files[0].ShouldSkip != tt.wantSkip {
+				t.Errorf("ShouldSkip = %v, want %v", files[0].ShouldSkip, tt.wantSkip)
+			}
+		})
+	}
+}
+
+func TestDiscoverFilesShrunkFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(projectDir, "a.jsonl"), []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, _ := newFakeS3(t)
+	m := manifest.New()
+	m.Files["claude-code/project/a.jsonl"] = manifest.FileEntry{
+		Mtime: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Size:  1000,
+	}
+	if err := manifest.Save(context.Background(), client, "test-bucket", "claude-code/.manifest.json", m, 0); err != nil {
+		t.Fatalf("saving manifest: %v", err)
+	}
+
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: tmpDir},
+		S3:    types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+	}
+
+	tests := []struct {
+		name        string
+		allowShrink bool
+		wantSkip    bool
+	}{
+		{name: "skipped by default", allowShrink: false, wantSkip: true},
+		{name: "uploaded with allow-shrink", allowShrink: true, wantSkip: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := New(cfg, client, true, false)
+			u.SetAllowShrink(tt.allowShrink)
+
+			files, err := u.DiscoverFiles(context.Background())
+			if err != nil {
+				t.Fatalf("DiscoverFiles failed: %v", err)
+			}
+			if len(files) != 1 {
+				t.Fatalf("expected 1 file, got %d", len(files))
+			}
+			if files[0].ShouldSkip != tt.wantSkip {
+				t.Errorf("ShouldSkip = %v, want %v (%s)", files[0].ShouldSkip, tt.wantSkip, files[0].SkipReason)
+			}
+			if tt.wantSkip && files[0].SkipReason != "shrank" {
+				t.Errorf("SkipReason = %q, want %q", files[0].SkipReason, "shrank")
+			}
+		})
+	}
+}
+
+func TestUpload_SameKeySerialized(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(projectDir, "session.jsonl")
+	oldContent := "{\"n\":1}\n"
+	newContent := "{\"n\":1}\n{\"n\":2}\n"
+	if err := os.WriteFile(path, []byte(oldContent), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	client, fake := newFakeS3(t)
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: tmpDir},
+		S3:    types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+	}
+	u := New(cfg, client, true, false)
+
+	oldFiles, err := u.DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+
+	// While the old snapshot is in flight, the session grows and a second
+	// upload of the same key is triggered. Without serialization the newer
+	// upload would finish first and then be overwritten by the stale one.
+	newMtime := time.Now().Add(time.Minute).Truncate(time.Second)
+	done := make(chan error, 1)
+	var once sync.Once
+	fake.beforeStore = func(key string) {
+		if key != "claude-code/project/session.jsonl" {
+			return
+		}
+		once.Do(func() {
+			if err := os.WriteFile(path, []byte(newContent), 0644); err != nil {
+				t.Errorf("rewriting file: %v", err)
+			}
+			if err := os.Chtimes(path, newMtime, newMtime); err != nil {
+				t.Errorf("setting mtime: %v", err)
+			}
+			newFiles, err := u.DiscoverFiles(context.Background())
+			if err != nil {
+				t.Errorf("DiscoverFiles failed: %v", err)
+				return
+			}
+			go func() {
+				_, err := u.Upload(context.Background(), newFiles)
+				done <- err
+			}()
+
+			select {
+			case <-done:
+				t.Error("second upload of the same key finished while the first was in flight")
+			case <-time.After(200 * time.Millisecond):
+			}
+		})
+	}
+
+	result, err := u.Upload(context.Background(), oldFiles)
+	if err != nil {
+		t.Fatalf("first Upload failed: %v", err)
+	}
+	if len(result.Modified) != 1 {
+		t.Errorf("first upload Modified = %v, want the rewritten file", result.Modified)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("second Upload failed: %v", err)
+	}
+
+	data, ok := fake.object("claude-code/project/session.jsonl")
+	if !ok || string(data) != newContent {
+		t.Errorf("stored object = %q, want newer snapshot %q", data, newContent)
+	}
+
+	m, err := manifest.Load(context.Background(), client, "test-bucket", "claude-code/.manifest.json", 0)
+	if err != nil {
+		t.Fatalf("loading manifest: %v", err)
+	}
+	entry, ok := m.Files["claude-code/project/session.jsonl"]
+	if !ok {
+		t.Fatal("manifest missing entry for the newer snapshot")
+	}
+	if entry.Size != int64(len(newContent)) || !entry.Mtime.Equal(newMtime.UTC()) || entry.UploadedSize != int64(len(newContent)) {
+		t.Errorf("manifest entry = %+v, want size %d and mtime %v", entry, len(newContent), newMtime.UTC())
+	}
+}
+
+func TestUpload_ModifiedDuringUpload(t *testing.T) {
+	tmpDir := t.TempDir()
+	projectDir := filepath.Join(tmpDir, "project")
+	if err := os.MkdirAll(projectDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"active.jsonl", "idle.jsonl"} {
+		if err := os.WriteFile(filepath.Join(projectDir, name), []byte("{\"n\":1}\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	client, fake := newFakeS3(t)
+
+	// Simulate Claude appending to the session while it is being uploaded
+	activePath := filepath.Join(projectDir, "active.jsonl")
+	fake.onPut = func(key string) {
+		if key != "claude-code/project/active.jsonl" {
+			return
+		}
+		f, err := os.OpenFile(activePath, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Errorf("opening file: %v", err)
+			return
+		}
+		defer f.Close()
+		if _, err := f.WriteString("{\"n\":2}\n"); err != nil {
+			t.Errorf("appending: %v", err)
+		}
+	}
+
+	cfg := &types.Config{
+		Local: types.LocalConfig{ProjectsRoot: tmpDir},
+		S3:    types.S3Config{Bucket: "test-bucket", Prefix: "claude-code/"},
+	}
+	u := New(cfg, client, true, false)
+
+	files, err := u.DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+
+	result, err := u.Upload(context.Background(), files)
+	if err != nil {
+		t.Fatalf("Upload failed: %v", err)
+	}
+	if len(result.Modified) != 1 || result.Modified[0] != activePath {
+		t.Errorf("Modified = %v, want [%s]", result.Modified, activePath)
+	}
+
+	m, err := manifest.Load(context.Background(), client, "test-bucket", "claude-code/.manifest.json", 0)
+	if err != nil {
+		t.Fatalf("loading manifest: %v", err)
+	}
+	if _, ok := m.Files["claude-code/project/active.jsonl"]; ok {
+		t.Error("manifest recorded a file modified during upload")
+	}
+	if _, ok := m.Files["claude-code/project/idle.jsonl"]; !ok {
+		t.Error("manifest missing unmodified file")
+	}
+
+	// The next run picks the modified file up again
+	files, err = u.DiscoverFiles(context.Background())
+	if err != nil {
+		t.Fatalf("DiscoverFiles failed: %v", err)
+	}
+	for _, f := range files {
+		wantSkip := f.LocalPath != activePath
+		if f.ShouldSkip != wantSkip {
+			t.Errorf("%s: ShouldSkip = %v, want %v", f.LocalPath, f.ShouldSkip, wantSkip)
+		}
 	}
 }
@@ -0,0 +1,328 @@
+// Package verify checks that objects recorded in the manifest are present and intact in S3.
+// A shallow check compares the stored size, listing each project once and
+// issuing HeadObject only where the listing fails; a deep check also downloads
+// each object and recomputes its SHA-256 against the hash recorded at upload time.
+package verify
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/config"
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/13rac1/cclogs/internal/uploader"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/olekukonko/tablewriter"
+)
+
+// S3Client defines the minimal S3 client interface needed for verification.
+type S3Client interface {
+	HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error)
+	GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error)
+	ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error)
+}
+
+// Status is the outcome of verifying a single object.
+type Status string
+
+const (
+	StatusOK       Status = "OK"
+	StatusMissing  Status = "Missing"
+	StatusMismatch Status = "Mismatch"
+	StatusError    Status = "Error"
+)
+
+// Result records the verification outcome for one manifest entry.
+type Result struct {
+	Key     string
+	Project string
+	Status  Status
+	Detail  string
+
+	hashed string // SHA-256 the downloaded content matched (deep checks only)
+}
+
+// Hashed reports whether the object was downloaded and matched its recorded
+// hash.
+func (r Result) Hashed() bool {
+	return r.hashed != ""
+}
+
+// Options controls which entries are verified and how thoroughly.
+type Options struct {
+	Prefix   string  // S3 prefix used to derive project names
+	Project  string  // Only verify entries in this project (empty for all)
+	Sample   int     // Verify a sample of N entries (0 for all)
+	Percent  float64 // Verify a sample of this percentage of entries instead of Sample
+	Deep     bool    // Download and re-hash each object
+	MaxBytes int64   // With Deep, stop sampling before downloads exceed this many bytes (0 for no cap)
+
+	// Now seeds the sample (a new selection each day) and is time.Now() if
+	// zero. Deep samples take the least recently verified entries first.
+	Now time.Time
+
+	Timeout time.Duration // Per-request timeout (non-positive disables the deadline)
+}
+
+// Run verifies manifest entries against the bucket and returns one result per entry checked.
+// Results are sorted by key. Context cancellation aborts the run with an error.
+func Run(ctx context.Context, client S3Client, bucket string, m *manifest.Manifest, opts Options) ([]Result, error) {
+	keys := selectKeys(m, opts)
+	cache := uploader.NewObjectCache(client, bucket, opts.Prefix, opts.Timeout)
+
+	results := make([]Result, 0, len(keys))
+	for _, key := range keys {
+		if err := ctx.Err(); err != nil {
+			return results, fmt.Errorf("verify cancelled: %w", err)
+		}
+
+		result := checkObject(ctx, client, cache, bucket, key, m.Files[key], opts)
+		result.Project = m.Project(key, opts.Prefix)
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// selectKeys returns the sorted manifest keys to verify after applying
+// project filtering, sampling, and the download cap.
+func selectKeys(m *manifest.Manifest, opts Options) []string {
+	var keys []string
+	for key := range m.Files {
+		if opts.Project != "" && m.Project(key, opts.Prefix) != opts.Project {
+			continue
+		}
+		keys = append(keys, key)
+	}
+
+	n := len(keys)
+	switch {
+	case opts.Percent > 0:
+		n = int(math.Ceil(float64(len(keys)) * min(opts.Percent, 100) / 100))
+	case opts.Sample > 0:
+		n = min(opts.Sample, len(keys))
+	}
+	capped := opts.Deep && opts.MaxBytes > 0
+
+	if n < len(keys) || capped {
+		now := opts.Now
+		if now.IsZero() {
+			now = time.Now()
+		}
+		order := sampleOrder(now)
+		sort.Slice(keys, func(i, j int) bool {
+			if opts.Deep {
+				a, b := m.Files[keys[i]].VerifiedAt, m.Files[keys[j]].VerifiedAt
+				if !a.Equal(b) {
+					return a.Before(b)
+				}
+			}
+			return order(keys[i]) < order(keys[j])
+		})
+		keys = keys[:n]
+	}
+
+	if capped {
+		var total int64
+		for i, key := range keys {
+			total += storedSize(m.Files[key])
+			if total > opts.MaxBytes {
+				keys = keys[:i]
+				break
+			}
+		}
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+// sampleOrder returns a ranking of keys that is stable within a UTC day and
+// different the next, so daily samples cover different entries.
+func sampleOrder(now time.Time) func(key string) uint64 {
+	seed := now.UTC().Format(time.DateOnly)
+	return func(key string) uint64 {
+		sum := sha256.Sum256([]byte(seed + "\x00" + key))
+		return binary.BigEndian.Uint64(sum[:8])
+	}
+}
+
+// storedSize returns the bytes a deep check downloads for entry.
+func storedSize(entry manifest.FileEntry) int64 {
+	if entry.UploadedSize > 0 {
+		return entry.UploadedSize
+	}
+	return entry.Size
+}
+
+// Record stamps the entries of m whose objects a deep check hashed with at,
+// so coverage accumulates across runs. Entries re-uploaded since the check
+// (their hash changed) are left alone. It returns the number stamped.
+func Record(m *manifest.Manifest, results []Result, at time.Time) int {
+	n := 0
+	for _, r := range results {
+		entry, ok := m.Files[r.Key]
+		if !ok || !r.Hashed() || entry.SHA256 != r.hashed {
+			continue
+		}
+		entry.VerifiedAt = at.UTC()
+		m.Files[r.Key] = entry
+		n++
+	}
+	return n
+}
+
+// checkObject verifies a single object against its manifest entry.
+func checkObject(ctx context.Context, client S3Client, cache *uploader.ObjectCache, bucket, key string, entry manifest.FileEntry, opts Options) Result {
+	result := Result{Key: key, Status: StatusOK}
+	object := entry.ObjectKey(key)
+
+	size, exists, covered := cache.Lookup(ctx, object)
+	if !covered {
+		var err error
+		size, exists, err = headObject(ctx, client, bucket, object, opts.Timeout)
+		if err != nil {
+			result.Status = StatusError
+			result.Detail = err.Error()
+			return result
+		}
+	}
+	if !exists {
+		result.Status = StatusMissing
+		result.Detail = "object not found"
+		return result
+	}
+
+	if entry.UploadedSize > 0 && size >= 0 && size != entry.UploadedSize {
+		result.Status = StatusMismatch
+		result.Detail = fmt.Sprintf("size %d, manifest %d", size, entry.UploadedSize)
+		return result
+	}
+
+	if !opts.Deep {
+		return result
+	}
+
+	if entry.SHA256 == "" {
+		result.Detail = "no hash recorded"
+		return result
+	}
+
+	sum, err := hashObject(ctx, client, bucket, object, opts.Timeout)
+	if err != nil {
+		result.Status = StatusError
+		result.Detail = err.Error()
+		return result
+	}
+
+	if sum != entry.SHA256 {
+		result.Status = StatusMismatch
+		result.Detail = fmt.Sprintf("sha256 %s, manifest %s", shortHash(sum), shortHash(entry.SHA256))
+		return result
+	}
+	result.hashed = sum
+
+	return result
+}
+
+// headObject returns the size of the object at key (-1 if not reported) and
+// whether it exists.
+func headObject(ctx context.Context, client S3Client, bucket, key string, timeout time.Duration) (int64, bool, error) {
+	ctx, cancel := config.WithOperationTimeout(ctx, timeout)
+	defer cancel()
+
+	head, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		if isNotFound(err) {
+			return 0, false, nil
+		}
+		return 0, false, err
+	}
+	if head.ContentLength == nil {
+		return -1, true, nil
+	}
+	return *head.ContentLength, true, nil
+}
+
+// hashObject downloads an object and returns the hex SHA-256 of its content.
+// The download, including reading the body, is bounded by timeout.
+func hashObject(ctx context.Context, client S3Client, bucket, key string, timeout time.Duration) (string, error) {
+	ctx, cancel := config.WithOperationTimeout(ctx, timeout)
+	defer cancel()
+
+	output, err := client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", fmt.Errorf("downloading object: %w", err)
+	}
+	defer func() { _ = output.Body.Close() }()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, output.Body); err != nil {
+		return "", fmt.Errorf("reading object: %w", err)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// isNotFound reports whether err indicates the object does not exist.
+func isNotFound(err error) bool {
+	var nsk *types.NoSuchKey
+	var nf *types.NotFound
+	return errors.As(err, &nsk) || errors.As(err, &nf)
+}
+
+// shortHash truncates a hex hash for display.
+func shortHash(h string) string {
+	if len(h) > 12 {
+		return h[:12]
+	}
+	return h
+}
+
+// HasProblems reports whether any result is not OK.
+func HasProblems(results []Result) bool {
+	for _, r := range results {
+		if r.Status != StatusOK {
+			return true
+		}
+	}
+	return false
+}
+
+// PrintResults writes a table of verification results followed by a summary line.
+func PrintResults(w io.Writer, results []Result) {
+	if len(results) == 0 {
+		fmt.Fprintln(w, "No manifest entries to verify.")
+		return
+	}
+
+	table := tablewriter.NewWriter(w)
+	table.Header("Key", "Status", "Detail")
+	for _, r := range results {
+		table.Append(r.Key, string(r.Status), r.Detail)
+	}
+	table.Render()
+
+	counts := make(map[Status]int)
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	fmt.Fprintf(w, "\nVerified %d objects: %d OK, %d missing, %d mismatched, %d errors\n",
+		len(results), counts[StatusOK], counts[StatusMissing], counts[StatusMismatch], counts[StatusError])
+}
@@ -0,0 +1,373 @@
+package verify
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/manifest"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// mockS3Client serves objects from an in-memory map.
+type mockS3Client struct {
+	objects map[string][]byte
+	headErr error
+	listErr error
+	heads   int
+	lists   []string // Listed prefixes, in order
+}
+
+func (m *mockS3Client) HeadObject(ctx context.Context, params *s3.HeadObjectInput, optFns ...func(*s3.Options)) (*s3.HeadObjectOutput, error) {
+	m.heads++
+	if m.headErr != nil {
+		return nil, m.headErr
+	}
+	data, ok := m.objects[*params.Key]
+	if !ok {
+		return nil, &types.NotFound{}
+	}
+	size := int64(len(data))
+	return &s3.HeadObjectOutput{ContentLength: &size}, nil
+}
+
+func (m *mockS3Client) GetObject(ctx context.Context, params *s3.GetObjectInput, optFns ...func(*s3.Options)) (*s3.GetObjectOutput, error) {
+	data, ok := m.objects[*params.Key]
+	if !ok {
+		return nil, &types.NoSuchKey{}
+	}
+	return &s3.GetObjectOutput{Body: io.NopCloser(bytes.NewReader(data))}, nil
+}
+
+func (m *mockS3Client) ListObjectsV2(ctx context.Context, params *s3.ListObjectsV2Input, optFns ...func(*s3.Options)) (*s3.ListObjectsV2Output, error) {
+	prefix := aws.ToString(params.Prefix)
+	m.lists = append(m.lists, prefix)
+	if m.listErr != nil {
+		return nil, m.listErr
+	}
+	var contents []types.Object
+	for key, data := range m.objects {
+		if strings.HasPrefix(key, prefix) {
+			contents = append(contents, types.Object{Key: aws.String(key), Size: aws.Int64(int64(len(data)))})
+		}
+	}
+	return &s3.ListObjectsV2Output{Contents: contents}, nil
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func entryFor(data []byte) manifest.FileEntry {
+	return manifest.FileEntry{
+		Mtime:        time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Size:         int64(len(data)),
+		UploadedSize: int64(len(data)),
+		SHA256:       sha256Hex(data),
+	}
+}
+
+func TestRun(t *testing.T) {
+	good := []byte(`{"ok":true}` + "\n")
+	corrupt := []byte(`{"ok":false}` + "\n") // same length as good
+
+	m := manifest.New()
+	m.Files["claude-code/project-a/good.jsonl"] = entryFor(good)
+	m.Files["claude-code/project-a/missing.jsonl"] = entryFor(good)
+	m.Files["claude-code/project-b/resized.jsonl"] = entryFor(good)
+	m.Files["claude-code/project-b/corrupt.jsonl"] = entryFor([]byte(`{"ok":!true}` + "\n"))
+
+	client := &mockS3Client{objects: map[string][]byte{
+		"claude-code/project-a/good.jsonl":    good,
+		"claude-code/project-b/resized.jsonl": []byte("short\n"),
+		"claude-code/project-b/corrupt.jsonl": corrupt,
+	}}
+
+	tests := []struct {
+		name string
+		opts Options
+		want map[string]Status
+	}{
+		{
+			name: "shallow",
+			opts: Options{Prefix: "claude-code/"},
+			want: map[string]Status{
+				"claude-code/project-a/good.jsonl":    StatusOK,
+				"claude-code/project-a/missing.jsonl": StatusMissing,
+				"claude-code/project-b/resized.jsonl": StatusMismatch,
+				"claude-code/project-b/corrupt.jsonl": StatusOK,
+			},
+		},
+		{
+			name: "deep detects content mismatch",
+			opts: Options{Prefix: "claude-code/", Deep: true},
+			want: map[string]Status{
+				"claude-code/project-a/good.jsonl":    StatusOK,
+				"claude-code/project-a/missing.jsonl": StatusMissing,
+				"claude-code/project-b/resized.jsonl": StatusMismatch,
+				"claude-code/project-b/corrupt.jsonl": StatusMismatch,
+			},
+		},
+		{
+			name: "project filter",
+			opts: Options{Prefix: "claude-code/", Project: "project-a"},
+			want: map[string]Status{
+				"claude-code/project-a/good.jsonl":    StatusOK,
+				"claude-code/project-a/missing.jsonl": StatusMissing,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			results, err := Run(context.Background(), client, "bucket", m, tt.opts)
+			if err != nil {
+				t.Fatalf("Run failed: %v", err)
+			}
+
+			if len(results) != len(tt.want) {
+				t.Fatalf("got %d results, want %d", len(results), len(tt.want))
+			}
+
+			for _, r := range results {
+				if r.Status != tt.want[r.Key] {
+					t.Errorf("%s: Status = %s, want %s (%s)", r.Key, r.Status, tt.want[r.Key], r.Detail)
+				}
+			}
+		})
+	}
+}
+
+func TestRunListsOncePerProject(t *testing.T) {
+	data := []byte("x\n")
+	m := manifest.New()
+	client := &mockS3Client{objects: map[string][]byte{}}
+	for _, key := range []string{"claude-code/a/1.jsonl", "claude-code/a/2.jsonl", "claude-code/b/3.jsonl"} {
+		m.Files[key] = entryFor(data)
+		client.objects[key] = data
+	}
+	m.Files["claude-code/b/missing.jsonl"] = entryFor(data)
+
+	results, err := Run(context.Background(), client, "bucket", m, Options{Prefix: "claude-code/"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if got := len(results); got != 4 || results[3].Status != StatusMissing {
+		t.Errorf("results = %+v, want 4 with b/missing.jsonl missing", results)
+	}
+	if want := []string{"claude-code/a/", "claude-code/b/"}; !reflect.DeepEqual(client.lists, want) {
+		t.Errorf("listed %v, want %v", client.lists, want)
+	}
+	if client.heads != 0 {
+		t.Errorf("HeadObject called %d times for listed keys, want 0", client.heads)
+	}
+}
+
+func TestRunListErrorFallsBackToHead(t *testing.T) {
+	data := []byte("x\n")
+	m := manifest.New()
+	m.Files["p/a.jsonl"] = entryFor(data)
+	m.Files["p/b.jsonl"] = entryFor(data)
+	client := &mockS3Client{objects: map[string][]byte{"p/a.jsonl": data}, listErr: errors.New("access denied")}
+
+	results, err := Run(context.Background(), client, "bucket", m, Options{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if results[0].Status != StatusOK || results[1].Status != StatusMissing {
+		t.Errorf("results = %+v, want OK and Missing", results)
+	}
+	if len(client.lists) != 1 || client.heads != 2 {
+		t.Errorf("lists = %v, heads = %d; want one listing and 2 heads", client.lists, client.heads)
+	}
+}
+
+func TestRunDeepRecordsCoverage(t *testing.T) {
+	good := []byte(`{"ok":true}` + "\n")
+	m := manifest.New()
+	client := &mockS3Client{objects: map[string][]byte{}}
+	for _, key := range []string{"p/a.jsonl", "p/b.jsonl", "p/c.jsonl"} {
+		m.Files[key] = entryFor(good)
+		client.objects[key] = good
+	}
+	// Bit rot: same size, different content
+	client.objects["p/b.jsonl"] = []byte(`{"ok":tru3}` + "\n")
+
+	results, err := Run(context.Background(), client, "bucket", m, Options{Deep: true})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+	if results[1].Key != "p/b.jsonl" || results[1].Status != StatusMismatch {
+		t.Errorf("results[1] = %+v, want p/b.jsonl mismatched", results[1])
+	}
+
+	// An entry re-uploaded during the run is not stamped
+	fresh := manifest.New()
+	for key, entry := range m.Files {
+		fresh.Files[key] = entry
+	}
+	reuploaded := fresh.Files["p/c.jsonl"]
+	reuploaded.SHA256 = sha256Hex([]byte("new\n"))
+	fresh.Files["p/c.jsonl"] = reuploaded
+
+	at := time.Date(2025, 6, 1, 0, 0, 0, 0, time.UTC)
+	if n := Record(fresh, results, at); n != 1 {
+		t.Errorf("Record() = %d, want 1", n)
+	}
+	if got := fresh.Files["p/a.jsonl"].VerifiedAt; !got.Equal(at) {
+		t.Errorf("p/a.jsonl VerifiedAt = %v, want %v", got, at)
+	}
+	for _, key := range []string{"p/b.jsonl", "p/c.jsonl"} {
+		if got := fresh.Files[key].VerifiedAt; !got.IsZero() {
+			t.Errorf("%s VerifiedAt = %v, want unset", key, got)
+		}
+	}
+
+	if verified, total := fresh.VerifiedSince("", at.AddDate(0, 0, -90)); verified != 1 || total != 3 {
+		t.Errorf("VerifiedSince() = %d of %d, want 1 of 3", verified, total)
+	}
+	if verified, _ := fresh.VerifiedSince("", at.AddDate(0, 0, 1)); verified != 0 {
+		t.Errorf("VerifiedSince() after the check = %d, want 0", verified)
+	}
+}
+
+func TestSelectKeys(t *testing.T) {
+	day := time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+	m := manifest.New()
+	for i := range 20 {
+		entry := entryFor([]byte("0123456789")) // 10 bytes
+		if i >= 10 {
+			entry.VerifiedAt = day.AddDate(0, 0, -1)
+		}
+		m.Files[fmt.Sprintf("p/%02d.jsonl", i)] = entry
+	}
+
+	tests := []struct {
+		name  string
+		opts  Options
+		count int
+		check func(t *testing.T, keys []string)
+	}{
+		{name: "all", opts: Options{Now: day}, count: 20},
+		{name: "count", opts: Options{Sample: 5, Now: day}, count: 5},
+		{name: "percent rounds up", opts: Options{Percent: 12, Now: day}, count: 3},
+		{
+			name:  "deep takes unverified first",
+			opts:  Options{Sample: 10, Deep: true, Now: day},
+			count: 10,
+			check: func(t *testing.T, keys []string) {
+				for _, key := range keys {
+					if !m.Files[key].VerifiedAt.IsZero() {
+						t.Errorf("sampled recently verified %s", key)
+					}
+				}
+			},
+		},
+		{name: "download cap", opts: Options{Deep: true, MaxBytes: 45, Now: day}, count: 4},
+		{name: "cap ignored without deep", opts: Options{MaxBytes: 50, Now: day}, count: 20},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			keys := selectKeys(m, tt.opts)
+			if len(keys) != tt.count {
+				t.Fatalf("selected %d keys, want %d", len(keys), tt.count)
+			}
+			if tt.check != nil {
+				tt.check(t, keys)
+			}
+		})
+	}
+
+	// Same day, same sample; another day, another sample
+	a := selectKeys(m, Options{Sample: 5, Now: day})
+	if b := selectKeys(m, Options{Sample: 5, Now: day.Add(6 * time.Hour)}); !reflect.DeepEqual(a, b) {
+		t.Errorf("samples differ within a day: %v and %v", a, b)
+	}
+	if c := selectKeys(m, Options{Sample: 5, Now: day.AddDate(0, 0, 1)}); reflect.DeepEqual(a, c) {
+		t.Errorf("sample did not change the next day: %v", a)
+	}
+}
+
+func TestRunSample(t *testing.T) {
+	data := []byte("x\n")
+	m := manifest.New()
+	client := &mockS3Client{objects: map[string][]byte{}}
+	for _, name := range []string{"a", "b", "c", "d", "e"} {
+		key := "p/" + name + ".jsonl"
+		m.Files[key] = entryFor(data)
+		client.objects[key] = data
+	}
+
+	results, err := Run(context.Background(), client, "bucket", m, Options{Sample: 2})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("got %d results, want 2", len(results))
+	}
+	if HasProblems(results) {
+		t.Errorf("HasProblems() = true, want false")
+	}
+}
+
+func TestRunHeadError(t *testing.T) {
+	m := manifest.New()
+	m.Files["p/a.jsonl"] = entryFor([]byte("x"))
+
+	client := &mockS3Client{headErr: errors.New("access denied"), listErr: errors.New("access denied")}
+
+	results, err := Run(context.Background(), client, "bucket", m, Options{})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	if results[0].Status != StatusError {
+		t.Errorf("Status = %s, want %s", results[0].Status, StatusError)
+	}
+	if !HasProblems(results) {
+		t.Error("HasProblems() = false, want true")
+	}
+}
+
+func TestRunContextCancelled(t *testing.T) {
+	m := manifest.New()
+	m.Files["p/a.jsonl"] = entryFor([]byte("x"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := Run(ctx, &mockS3Client{}, "bucket", m, Options{})
+	if err == nil {
+		t.Fatal("expected error for cancelled context, got nil")
+	}
+}
+
+func TestPrintResults(t *testing.T) {
+	var buf bytes.Buffer
+	PrintResults(&buf, []Result{
+		{Key: "p/a.jsonl", Status: StatusOK},
+		{Key: "p/b.jsonl", Status: StatusMissing, Detail: "object not found"},
+	})
+
+	out := buf.String()
+	for _, want := range []string{"p/a.jsonl", "Missing", "object not found", "1 OK, 1 missing"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q\nGot:\n%s", want, out)
+		}
+	}
+}
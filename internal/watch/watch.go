@@ -0,0 +1,115 @@
+// Package watch finds session logs that changed and then went quiet, so a
+// long-running process can upload a session once it pauses instead of after
+// every appended line. It polls the projects root rather than subscribing to
+// file system events, which also picks up project directories created while
+// it runs and keeps working on network mounts.
+package watch
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/13rac1/cclogs/internal/config"
+)
+
+// stat is the part of a file's state that changes when a session appends.
+type stat struct {
+	size  int64
+	mtime time.Time
+}
+
+// Tracker compares successive scans of a projects root.
+type Tracker struct {
+	root    string
+	exts    []string
+	settle  time.Duration
+	last    map[string]stat      // Files seen by the previous scan
+	changed map[string]time.Time // Changed files and when they last changed
+}
+
+// New returns a Tracker for the files with one of exts (default .jsonl) below
+// root that reports a changed file once it has not changed for settle.
+func New(root string, exts []string, settle time.Duration) *Tracker {
+	return &Tracker{root: root, exts: exts, settle: settle, changed: make(map[string]time.Time)}
+}
+
+// Prime records the current state of the root without reporting changes, so
+// only files written after it are returned by Poll.
+func (t *Tracker) Prime() error {
+	files, err := scan(t.root, t.exts)
+	if err != nil {
+		return err
+	}
+	t.last = files
+	return nil
+}
+
+// Pending returns the number of changed files still settling.
+func (t *Tracker) Pending() int {
+	return len(t.changed)
+}
+
+// Poll scans the root and returns, sorted, the files that changed since an
+// earlier scan and have been quiet for the settle period as of now. Returned
+// files are forgotten until they change again.
+func (t *Tracker) Poll(now time.Time) ([]string, error) {
+	files, err := scan(t.root, t.exts)
+	if err != nil {
+		return nil, err
+	}
+
+	for path, st := range files {
+		if prev, ok := t.last[path]; !ok || prev != st {
+			t.changed[path] = now
+		}
+	}
+	t.last = files
+
+	var settled []string
+	for path, at := range t.changed {
+		if _, ok := files[path]; !ok {
+			delete(t.changed, path) // Deleted before it settled
+			continue
+		}
+		if now.Sub(at) >= t.settle {
+			settled = append(settled, path)
+			delete(t.changed, path)
+		}
+	}
+	sort.Strings(settled)
+	return settled, nil
+}
+
+// scan returns the state of every log file below root.
+func scan(root string, exts []string) (map[string]stat, error) {
+	files := make(map[string]stat)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// A file or directory removed mid-walk is not an error
+			if path != root && os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || !config.HasLogExtension(d.Name(), exts) {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		files[path] = stat{size: info.Size(), mtime: info.ModTime()}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("scanning projects root: %w", err)
+	}
+	return files, nil
+}
@@ -0,0 +1,103 @@
+package watch
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func writeLog(t *testing.T, path, content string, mtime time.Time) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(path, mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTrackerPoll(t *testing.T) {
+	root := t.TempDir()
+	start := time.Date(2025, 6, 1, 9, 0, 0, 0, time.UTC)
+	existing := filepath.Join(root, "app", "old.jsonl")
+	active := filepath.Join(root, "app", "active.jsonl")
+	created := filepath.Join(root, "new-project", "s1.jsonl")
+	writeLog(t, existing, "{}\n", start)
+
+	tr := New(root, nil, 30*time.Second)
+	if err := tr.Prime(); err != nil {
+		t.Fatalf("Prime() error = %v", err)
+	}
+
+	steps := []struct {
+		name  string
+		at    time.Duration
+		write func()
+		want  []string
+	}{
+		{"unchanged files are not reported", 10 * time.Second, nil, nil},
+		{"new files start settling", 20 * time.Second, func() {
+			writeLog(t, active, "{}\n", start.Add(20*time.Second))
+			writeLog(t, created, "{}\n", start.Add(20*time.Second))
+		}, nil},
+		{"appends restart the settle period", 40 * time.Second, func() {
+			writeLog(t, active, "{}\n{}\n", start.Add(40*time.Second))
+		}, nil},
+		{"quiet files settle", 55 * time.Second, nil, []string{created}},
+		{"settled files are forgotten", 60 * time.Second, nil, nil},
+		{"active file settles later", 75 * time.Second, nil, []string{active}},
+	}
+
+	for _, step := range steps {
+		if step.write != nil {
+			step.write()
+		}
+		got, err := tr.Poll(start.Add(step.at))
+		if err != nil {
+			t.Fatalf("%s: Poll() error = %v", step.name, err)
+		}
+		if !slices.Equal(got, step.want) {
+			t.Errorf("%s: Poll() = %v, want %v", step.name, got, step.want)
+		}
+	}
+	if tr.Pending() != 0 {
+		t.Errorf("Pending() = %d, want 0", tr.Pending())
+	}
+}
+
+func TestTrackerDeletedBeforeSettling(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	tr := New(root, nil, time.Second)
+	if err := tr.Prime(); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(root, "app", "s1.jsonl")
+	writeLog(t, path, "{}\n", now)
+	if _, err := tr.Poll(now); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	got, err := tr.Poll(now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 0 || tr.Pending() != 0 {
+		t.Errorf("Poll() = %v with %d pending, want nothing", got, tr.Pending())
+	}
+}
+
+func TestTrackerMissingRoot(t *testing.T) {
+	tr := New(filepath.Join(t.TempDir(), "missing"), nil, time.Second)
+	if err := tr.Prime(); err == nil {
+		t.Error("Prime() error = nil, want error for missing root")
+	}
+}